@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"image/jpeg"
+	"sync/atomic"
+	"time"
+)
+
+// streamLevel is a step of stream quality. Quality 0 passes the camera's
+// JPEG through unchanged; Skip sends only every Skip-th frame.
+type streamLevel struct {
+	Quality int
+	Skip    int
+}
+
+// streamLevels go from the camera's own frames down to what a weak Wi-Fi
+// link can still keep up with
+var streamLevels = []streamLevel{
+	{Quality: 0, Skip: 1},
+	{Quality: 70, Skip: 1},
+	{Quality: 50, Skip: 1},
+	{Quality: 50, Skip: 2},
+	{Quality: 35, Skip: 3},
+	{Quality: 25, Skip: 5},
+}
+
+const (
+	// streamSlowWrite is how long writing one frame may take before the
+	// client counts as falling behind
+	streamSlowWrite = 100 * time.Millisecond
+	// streamRecoverFrames is how many frames in a row must go out quickly
+	// before the quality steps up again
+	streamRecoverFrames = 50
+)
+
+// streamClient is one viewer of a camera's MJPEG stream. Its quality adapts
+// to how fast it takes the frames: frames dropped because its queue is full,
+// or writes blocked on a full socket buffer, step the quality down; a run of
+// quick writes steps it back up. Each client adapts on its own, so a slow
+// viewer does not hold back the others.
+type streamClient struct {
+	frames chan Frame
+	// dropped counts the frames Publish could not queue
+	dropped atomic.Int32
+
+	level  int
+	smooth int
+	count  int
+	// hold skips judging the frames that were already queued when the
+	// level changed
+	hold int
+	// fixed turns adaptation off, for clients that ask for ?adaptive=0
+	fixed bool
+}
+
+func newStreamClient() *streamClient {
+	return &streamClient{frames: make(chan Frame, apiStreamQueue)}
+}
+
+// offer queues a frame, dropping it if the client is behind
+func (c *streamClient) offer(frame Frame) {
+	select {
+	case c.frames <- frame:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// prepare returns the frame data to send at the client's current level, or
+// nil if the frame is skipped
+func (c *streamClient) prepare(frame Frame) []byte {
+	level := streamLevels[c.level]
+	c.count++
+	if c.count%level.Skip != 0 {
+		return nil
+	}
+	if level.Quality == 0 {
+		return frame.Data
+	}
+	img, err := jpeg.Decode(bytes.NewReader(frame.Data))
+	if err != nil {
+		return frame.Data
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: level.Quality}); err != nil {
+		return frame.Data
+	}
+	return buf.Bytes()
+}
+
+// sent adapts the level after a frame took the given time to write
+func (c *streamClient) sent(took time.Duration) {
+	dropped := c.dropped.Swap(0) > 0
+	if c.fixed {
+		return
+	}
+	if c.hold > 0 {
+		c.hold--
+		return
+	}
+	if dropped || took > streamSlowWrite {
+		c.smooth = 0
+		if c.level < len(streamLevels)-1 {
+			c.level++
+			c.hold = apiStreamQueue
+		}
+		return
+	}
+	c.smooth++
+	if c.smooth >= streamRecoverFrames && c.level > 0 {
+		c.smooth = 0
+		c.level--
+		c.hold = apiStreamQueue
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/ebitengine/purego"
+)
+
+type alertEvent int
+
+const (
+	alertMotion alertEvent = iota
+	alertOffline
+	alertRecording
+	alertEventCount
+)
+
+// alertEventNames are the event names used by -alerts and -alert-sound
+var alertEventNames = [alertEventCount]string{"motion", "offline", "recording"}
+
+const (
+	// alertInterval is the shortest time between two sounds of one event
+	alertInterval = 5 * time.Second
+	// offlineTimeout is how long a streaming camera may send no frames
+	// before it counts as offline
+	offlineTimeout = 5 * time.Second
+	// motionStep is the spacing of the pixels compared by motion detection;
+	// motionThreshold is the luma change that counts a pixel as changed and
+	// motionFraction the share of changed pixels that counts as motion
+	motionStep      = 8
+	motionThreshold = 25
+	motionFraction  = 0.02
+	// alertSampleRate is the rate of the built-in beeps
+	alertSampleRate = 22050
+	// audioDeviceDefaultPlayback is SDL_AUDIO_DEVICE_DEFAULT_PLAYBACK
+	audioDeviceDefaultPlayback = 0xFFFFFFFF
+)
+
+// audioFunctions are the SDL audio functions the app uses. go-sdl3 does not
+// bind opening a device yet, so they are looked up like the tray functions.
+var audioFunctions struct {
+	OpenAudioDevice    func(devid uint32, spec *sdl.AudioSpec) uint32
+	CreateAudioStream  func(src, dst *sdl.AudioSpec) uintptr
+	BindAudioStream    func(devid uint32, stream uintptr) bool
+	PutAudioStreamData func(stream uintptr, buf unsafe.Pointer, length int32) bool
+	ClearAudioStream   func(stream uintptr) bool
+	GetError           func() string
+}
+
+// Alerts plays a sound when something needs attention at the machine:
+// motion in front of a camera, a camera that stopped sending frames or a
+// recording that failed. Each event has a built-in beep pattern, which a WAV
+// file can replace.
+type Alerts struct {
+	enabled [alertEventCount]bool
+	sounds  [alertEventCount]string
+	// streams holds one SDL audio stream per enabled event, bound to the
+	// default playback device
+	streams [alertEventCount]uintptr
+	data    [alertEventCount][]byte
+	last    [alertEventCount]time.Time
+}
+
+var alerts Alerts
+
+// parseAlertEvent returns the event with the given name
+func parseAlertEvent(name string) (alertEvent, error) {
+	for event, eventName := range alertEventNames {
+		if name == eventName {
+			return alertEvent(event), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown alert event %q, expected one of %s", name, strings.Join(alertEventNames[:], ", "))
+}
+
+// configureAlerts enables the events of a comma-separated list and sets the
+// sounds of a list of "event=file.wav" pairs
+func configureAlerts(events, sounds string) error {
+	for _, name := range strings.Split(events, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		event, err := parseAlertEvent(name)
+		if err != nil {
+			return err
+		}
+		alerts.enabled[event] = true
+	}
+	for _, pair := range strings.Split(sounds, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid alert sound %q, expected event=file.wav", pair)
+		}
+		event, err := parseAlertEvent(name)
+		if err != nil {
+			return err
+		}
+		alerts.sounds[event] = path
+	}
+	return nil
+}
+
+// Enabled reports whether an event plays a sound
+func (a *Alerts) Enabled(event alertEvent) bool {
+	return a.enabled[event]
+}
+
+func loadAudioFunctions() (err error) {
+	// purego panics on missing symbols and unsupported platforms
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("audio unavailable on %s/%s: %v", runtime.GOOS, runtime.GOARCH, r)
+		}
+	}()
+
+	lib, err := purego.Dlopen("libSDL3.so.0", purego.RTLD_NOW|purego.RTLD_GLOBAL)
+	if err != nil {
+		return fmt.Errorf("audio unavailable: %w", err)
+	}
+	purego.RegisterLibFunc(&audioFunctions.OpenAudioDevice, lib, "SDL_OpenAudioDevice")
+	purego.RegisterLibFunc(&audioFunctions.CreateAudioStream, lib, "SDL_CreateAudioStream")
+	purego.RegisterLibFunc(&audioFunctions.BindAudioStream, lib, "SDL_BindAudioStream")
+	purego.RegisterLibFunc(&audioFunctions.PutAudioStreamData, lib, "SDL_PutAudioStreamData")
+	purego.RegisterLibFunc(&audioFunctions.ClearAudioStream, lib, "SDL_ClearAudioStream")
+	purego.RegisterLibFunc(&audioFunctions.GetError, lib, "SDL_GetError")
+	return nil
+}
+
+// startAlerts opens the default playback device and prepares the sounds of
+// the enabled events. Without a working audio device the alerts are turned
+// off, since the app is still useful without them.
+func startAlerts() {
+	enabled := false
+	for _, on := range alerts.enabled {
+		enabled = enabled || on
+	}
+	if !enabled {
+		return
+	}
+	if err := openAlertSounds(); err != nil {
+		log.Printf("Audio alerts disabled: %v", err)
+		alerts.enabled = [alertEventCount]bool{}
+	}
+}
+
+func openAlertSounds() error {
+	if err := sdl.InitSubSystem(sdl.INIT_AUDIO); err != nil {
+		return err
+	}
+	if err := loadAudioFunctions(); err != nil {
+		return err
+	}
+
+	beepSpec := sdl.AudioSpec{Format: sdl.AUDIO_S16, Channels: 1, Freq: alertSampleRate}
+	device := audioFunctions.OpenAudioDevice(audioDeviceDefaultPlayback, &beepSpec)
+	if device == 0 {
+		return fmt.Errorf("failed to open audio device: %s", audioFunctions.GetError())
+	}
+
+	for event := range alertEventCount {
+		if !alerts.enabled[event] {
+			continue
+		}
+		spec := beepSpec
+		data := alertBeep(event)
+		if path := alerts.sounds[event]; path != "" {
+			var err error
+			if data, err = sdl.LoadWAV(path, &spec); err != nil {
+				return fmt.Errorf("failed to load alert sound %s: %w", path, err)
+			}
+		}
+		stream := audioFunctions.CreateAudioStream(&spec, &beepSpec)
+		if stream == 0 || !audioFunctions.BindAudioStream(device, stream) {
+			return fmt.Errorf("failed to create audio stream: %s", audioFunctions.GetError())
+		}
+		alerts.streams[event] = stream
+		alerts.data[event] = data
+	}
+	log.Printf("Audio alerts enabled")
+	return nil
+}
+
+// alertBeep generates the built-in sound of an event: a double beep for
+// motion, a falling tone for an offline camera and a fast triple beep for a
+// failed recording
+func alertBeep(event alertEvent) []byte {
+	type tone struct {
+		freq float64 // 0 is a pause
+		ms   int
+	}
+	var pattern []tone
+	switch event {
+	case alertMotion:
+		pattern = []tone{{880, 120}, {0, 80}, {880, 120}}
+	case alertOffline:
+		pattern = []tone{{660, 250}, {440, 400}}
+	case alertRecording:
+		pattern = []tone{{1000, 80}, {0, 60}, {1000, 80}, {0, 60}, {1000, 80}}
+	}
+
+	var data []byte
+	for _, t := range pattern {
+		n := alertSampleRate * t.ms / 1000
+		// Short fades keep the tones from clicking
+		fade := min(alertSampleRate/200, n/2)
+		for i := range n {
+			var v float64
+			if t.freq > 0 {
+				v = 0.4 * math.Sin(2*math.Pi*t.freq*float64(i)/alertSampleRate)
+				if i < fade {
+					v *= float64(i) / float64(fade)
+				} else if n-i < fade {
+					v *= float64(n-i) / float64(fade)
+				}
+			}
+			sample := int16(v * math.MaxInt16)
+			data = append(data, byte(sample), byte(sample>>8))
+		}
+	}
+	return data
+}
+
+// raiseAlert plays the sound of an event, at most once per alertInterval,
+// and shows the message in the status bar
+func raiseAlert(appData *CameraAppData, event alertEvent, message string) {
+	if !alerts.enabled[event] {
+		return
+	}
+	appData.StatusText = message
+	appData.StatusColor = theme.Error
+	if time.Since(alerts.last[event]) < alertInterval {
+		return
+	}
+	alerts.last[event] = time.Now()
+	log.Printf("Alert: %s", message)
+
+	stream, data := alerts.streams[event], alerts.data[event]
+	if stream == 0 || len(data) == 0 {
+		return
+	}
+	// Restart the sound rather than queueing it behind the last one
+	audioFunctions.ClearAudioStream(stream)
+	if !audioFunctions.PutAudioStreamData(stream, unsafe.Pointer(&data[0]), int32(len(data))) {
+		log.Printf("Failed to play alert: %s", audioFunctions.GetError())
+	}
+}
+
+// MotionDetector compares a sparse grid of pixels between consecutive
+// frames of a camera
+type MotionDetector struct {
+	previous []uint8
+	// Detected is set when the last frame differed from the one before
+	Detected bool
+}
+
+// Update compares a frame with the previous one
+func (m *MotionDetector) Update(pix []uint8, stride, width, height int) {
+	n := ((width + motionStep - 1) / motionStep) * ((height + motionStep - 1) / motionStep)
+	if len(m.previous) != n {
+		// First frame or a new resolution: nothing to compare with yet
+		m.previous = make([]uint8, n)
+		m.Detected = false
+		m.sample(pix, stride, width, height, nil)
+		return
+	}
+	changed := m.sample(pix, stride, width, height, m.previous)
+	m.Detected = float64(changed) > motionFraction*float64(n)
+}
+
+// sample stores the luma of the grid pixels in m.previous and returns how
+// many changed by more than motionThreshold from compare, if given
+func (m *MotionDetector) sample(pix []uint8, stride, width, height int, compare []uint8) int {
+	changed, i := 0, 0
+	for y := 0; y < height; y += motionStep {
+		row := pix[y*stride:]
+		for x := 0; x < width; x += motionStep {
+			p := row[x*4:]
+			luma := uint8((77*int(p[0]) + 150*int(p[1]) + 29*int(p[2])) >> 8)
+			if compare != nil && abs(int(luma)-int(compare[i])) > motionThreshold {
+				changed++
+			}
+			m.previous[i] = luma
+			i++
+		}
+	}
+	return changed
+}
+
+// checkOfflineCameras marks streaming cameras that stopped sending frames
+// offline, with a toast and, if enabled, an alert. Watch folders only send frames when a new image arrives,
+// so they are never offline.
+func checkOfflineCameras(appData *CameraAppData) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if !camera.Active || camera.Disabled || camera.LastFrameAt.IsZero() || camera.Offline ||
+			isFolderSource(camera) {
+			continue
+		}
+		if time.Since(camera.LastFrameAt) > offlineTimeout {
+			camera.Offline = true
+			notify(toastWarning, tr("alert.offline", camera.Info.Name))
+			raiseAlert(appData, alertOffline, tr("alert.offline", camera.Info.Name))
+		}
+	}
+}
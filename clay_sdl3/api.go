@@ -0,0 +1,690 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// The API lets other programs and other camapp instances use the cameras
+// over HTTP:
+//
+//	GET  /api/cameras                  cameras as JSON
+//	GET  /api/cameras/{id}/stream      MJPEG stream (multipart/x-mixed-replace)
+//	GET  /api/cameras/{id}/snapshot    latest frame as JPEG
+//	POST /api/cameras/{id}/snapshot    save a snapshot on this machine
+//	POST /api/cameras/{id}/record?on=1 start (on=1) or stop (on=0) recording
+//	GET  /api/cameras/{id}/profiles    control profiles and the one applied
+//	POST /api/cameras/{id}/profile?name=NAME apply a control profile
+//	GET  /api/cameras/{id}/controls    V4L2 controls with their ranges
+//	POST /api/cameras/{id}/controls?id=ID&value=N set a control
+//	GET  /api/cameras/{id}/moment?format=gif&seconds=N the last seconds as
+//	                                   an animated GIF or WebP (format=webp)
+//	GET  /api/privacy                  whether privacy mode is on
+//	POST /api/privacy?on=1             switch privacy mode on (on=1) or off (on=0)
+//	GET  /events                       WebSocket of state changes, see events.go
+//	GET  /                             web UI for phones and tablets
+//	GET  /webcam/?action=stream        mjpg-streamer's URLs, see moonraker.go
+//
+// Streams adapt to each viewer's connection: the JPEG quality and frame rate
+// drop while a viewer falls behind and recover once it keeps up again
+// (?adaptive=0 always sends the camera's own frames).
+//
+// When the API has tokens, saving a snapshot, recording and switching privacy
+// mode take an operator's token, applying a profile or setting a control an
+// admin's and the rest a viewer's, see apiauth.go. The web UI itself is
+// served to everyone and asks for a token when the API wants one.
+//
+// -grpc-listen serves the cameras, their controls, the events and the frames
+// over gRPC as well, see grpc.go and proto/camapp.proto.
+//
+// Every stream part carries the frame's capture time in an X-Capture-Time
+// header, in nanoseconds since the Unix epoch. Cameras shown from other
+// instances are not served again, so instances pointing at each other do not
+// loop.
+
+const (
+	// apiBoundary separates the parts of an MJPEG stream
+	apiBoundary = "camappframe"
+	// apiStreamQueue is how many frames a stream client may fall behind
+	// before frames are dropped for it
+	apiStreamQueue = 2
+	// apiCommandTimeout bounds how long a request waits for the main loop
+	apiCommandTimeout = 5 * time.Second
+	// apiCaptureTimeHeader carries a frame's capture time in a stream part
+	apiCaptureTimeHeader = "X-Capture-Time"
+)
+
+// apiCamera describes a camera in the camera list
+type apiCamera struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Active bool   `json:"active"`
+	// Offline is set while an active camera sends no frames
+	Offline bool `json:"offline"`
+	// Recording is set while the camera is recorded
+	Recording bool `json:"recording"`
+	// LostFrames never reached the app, DroppedFrames were dropped by it
+	LostFrames    uint64 `json:"lost_frames"`
+	DroppedFrames uint64 `json:"dropped_frames"`
+}
+
+// apiCommand is a request that has to run on the main loop, which owns the
+// cameras
+type apiCommand struct {
+	run   func(appData *CameraAppData) (any, error)
+	reply chan apiReply
+}
+
+type apiReply struct {
+	value any
+	err   error
+}
+
+// APIServer serves the cameras' frames to HTTP clients. The main loop
+// publishes every frame and refreshes the camera list; requests that change
+// state are queued for the main loop.
+type APIServer struct {
+	mu      sync.Mutex
+	cameras []apiCamera
+	latest  map[int]Frame
+	streams map[int]map[*streamClient]bool
+
+	// eventClients are the event streams; listening counts them for the
+	// main loop
+	eventClients map[*eventClient]bool
+	listening    atomic.Int32
+	// statsAt and motionAt are when stats and each camera's motion were
+	// last sent, used by the main loop only
+	statsAt  time.Time
+	motionAt map[int]time.Time
+
+	commands chan apiCommand
+}
+
+// api is the running API server, or nil when -api-listen is not set
+var api *APIServer
+
+// advertiseMDNS announces the API on the LAN, set with -mdns
+var advertiseMDNS = true
+
+// listenAPI serves the API on a TCP address
+func listenAPI(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for API clients: %w", err)
+	}
+	if err := startAPI(); err != nil {
+		listener.Close()
+		return err
+	}
+	mux := api.routes()
+
+	log.Printf("Serving the camera API on %s", listener.Addr())
+	if advertiseMDNS {
+		if err := advertiseAPI(listener.Addr()); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	go func() {
+		err := http.Serve(listener, mux)
+		log.Printf("API server stopped: %v", err)
+	}()
+	return nil
+}
+
+// startAPI loads the API tokens and sets up the API server, unless the
+// HTTP or the gRPC API already did
+func startAPI() error {
+	if api != nil {
+		return nil
+	}
+	if err := loadAPITokens(apiTokensFile); err != nil {
+		return err
+	}
+	if len(apiTokens) > 0 {
+		log.Printf("The camera API accepts %d tokens from %s", len(apiTokens), apiTokensFile)
+	}
+	api = newAPIServer()
+	return nil
+}
+
+// newAPIServer returns an API server without clients
+func newAPIServer() *APIServer {
+	return &APIServer{
+		latest:   make(map[int]Frame),
+		streams:  make(map[int]map[*streamClient]bool),
+		commands: make(chan apiCommand, 16),
+
+		eventClients: make(map[*eventClient]bool),
+		motionAt:     make(map[int]time.Time),
+	}
+}
+
+// routes returns the handler of the API's requests
+func (s *APIServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/cameras", requireRole(roleViewer, s.handleCameras))
+	mux.HandleFunc("GET /api/cameras/{id}/stream", requireRole(roleViewer, s.handleStream))
+	mux.HandleFunc("GET /api/cameras/{id}/snapshot", requireRole(roleViewer, s.handleLatest))
+	mux.HandleFunc("POST /api/cameras/{id}/snapshot", requireRole(roleOperator, s.handleSnapshot))
+	mux.HandleFunc("POST /api/cameras/{id}/record", requireRole(roleOperator, s.handleRecord))
+	mux.HandleFunc("GET /api/cameras/{id}/profiles", requireRole(roleViewer, s.handleProfiles))
+	mux.HandleFunc("POST /api/cameras/{id}/profile", requireRole(roleAdmin, s.handleProfile))
+	mux.HandleFunc("GET /api/cameras/{id}/controls", requireRole(roleViewer, s.handleControls))
+	mux.HandleFunc("POST /api/cameras/{id}/controls", requireRole(roleAdmin, s.handleSetControl))
+	mux.HandleFunc("GET /api/cameras/{id}/moment", requireRole(roleViewer, s.handleMoment))
+	mux.HandleFunc("GET /api/privacy", requireRole(roleViewer, s.handlePrivacy))
+	mux.HandleFunc("POST /api/privacy", requireRole(roleOperator, s.handleSetPrivacy))
+	mux.HandleFunc("GET /api/version", requireRole(roleViewer, handleVersion))
+	mux.HandleFunc("GET /events", requireRole(roleViewer, s.handleEvents))
+	for i := range maxWebcams {
+		mux.HandleFunc("GET "+mjpgStreamerPath(i)+"{$}", requireRole(roleViewer, s.handleMJPGStreamer(i)))
+	}
+	mux.HandleFunc("GET /{$}", s.handleRoot)
+	return mux
+}
+
+// Update refreshes the camera list and runs the queued commands. It is
+// called from the main loop.
+func (s *APIServer) Update(appData *CameraAppData) {
+	if s == nil {
+		return
+	}
+	cameras := make([]apiCamera, 0, len(appData.Cameras))
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if isRemoteSource(camera) {
+			continue
+		}
+		cameras = append(cameras, apiCamera{
+			ID:     i,
+			Name:   camera.Info.Name,
+			Width:  camera.Width,
+			Height: camera.Height,
+			Active: camera.Active && !camera.Disabled,
+
+			Offline: camera.Offline,
+
+			Recording: camera.Recorder != nil,
+
+			LostFrames:    atomic.LoadUint64(&camera.LostFrames),
+			DroppedFrames: atomic.LoadUint64(&camera.DroppedFrames),
+		})
+	}
+	s.mu.Lock()
+	old := s.cameras
+	s.cameras = cameras
+	s.mu.Unlock()
+	s.publishChanges(appData, old, cameras)
+	s.publishStats(cameras, time.Now())
+
+	for {
+		select {
+		case command := <-s.commands:
+			value, err := command.run(appData)
+			command.reply <- apiReply{value, err}
+		default:
+			return
+		}
+	}
+}
+
+// Publish hands a new frame of a camera to its stream clients
+func (s *APIServer) Publish(id int, camera *CameraInstance, frame Frame) {
+	if s == nil || isRemoteSource(camera) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latest[id] = frame
+	for client := range s.streams[id] {
+		client.offer(frame)
+	}
+}
+
+// Watched reports whether a camera has stream clients
+func (s *APIServer) Watched(id int) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams[id]) > 0
+}
+
+// Clients is the number of stream clients of all cameras
+func (s *APIServer) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := 0
+	for _, streams := range s.streams {
+		clients += len(streams)
+	}
+	return clients
+}
+
+// camera looks up the camera named by the request's {id}
+func (s *APIServer) camera(r *http.Request) (apiCamera, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return apiCamera{}, false
+	}
+	return s.cameraByID(id)
+}
+
+// cameraByID looks up a camera in the camera list
+func (s *APIServer) cameraByID(id int) (apiCamera, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, camera := range s.cameras {
+		if camera.ID == id {
+			return camera, true
+		}
+	}
+	return apiCamera{}, false
+}
+
+// command runs a function on the main loop and waits for its result
+func (s *APIServer) command(run func(appData *CameraAppData) (any, error)) (any, error) {
+	command := apiCommand{run: run, reply: make(chan apiReply, 1)}
+	select {
+	case s.commands <- command:
+	case <-time.After(apiCommandTimeout):
+		return nil, errors.New("the app is busy")
+	}
+	select {
+	case reply := <-command.reply:
+		return reply.value, reply.err
+	case <-time.After(apiCommandTimeout):
+		return nil, errors.New("the app did not answer in time")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (s *APIServer) handleCameras(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	cameras := s.cameras
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, cameras)
+}
+
+// handleVersion reports the build the app runs
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentBuild())
+}
+
+func (s *APIServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	client := newStreamClient()
+	client.fixed = r.URL.Query().Get("adaptive") == "0"
+	s.addStream(camera.ID, client)
+	defer s.removeStream(camera.ID, client)
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+apiBoundary)
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case frame := <-client.frames:
+			data := client.prepare(frame)
+			if data == nil {
+				continue
+			}
+			start := time.Now()
+			_, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n%s: %d\r\n\r\n",
+				apiBoundary, len(data), apiCaptureTimeHeader, frame.Captured.UnixNano())
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err == nil {
+				_, err = w.Write([]byte("\r\n"))
+			}
+			if err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			client.sent(time.Since(start))
+		}
+	}
+}
+
+// addStream adds a stream client of a camera, which is offered its frames
+func (s *APIServer) addStream(id int, client *streamClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams[id] == nil {
+		s.streams[id] = make(map[*streamClient]bool)
+	}
+	s.streams[id][client] = true
+}
+
+// removeStream removes a stream client of a camera
+func (s *APIServer) removeStream(id int, client *streamClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.streams[id], client)
+}
+
+func (s *APIServer) handleLatest(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	frame, ok := s.latest[camera.ID]
+	s.mu.Unlock()
+	if !ok {
+		writeAPIError(w, http.StatusServiceUnavailable, trErr("error.not_stream", camera.Name))
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set(apiCaptureTimeHeader, strconv.FormatInt(frame.Captured.UnixNano(), 10))
+	_, _ = w.Write(frame.Data)
+}
+
+func (s *APIServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := s.snapshot(camera)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"path": path})
+}
+
+// snapshot saves a snapshot of a camera on this machine and returns its
+// path. A snapshot that needs the light is taken by the main loop once a
+// frame with the light on arrives, which also switches the light off, even
+// if this has given up waiting.
+func (s *APIServer) snapshot(camera apiCamera) (string, error) {
+	// Buffered, so the main loop never waits for this
+	result := make(chan snapshotResult, 1)
+	value, err := s.command(func(appData *CameraAppData) (any, error) {
+		path, err := queueLitSnapshot(appData, camera.ID, result)
+		return path, err
+	})
+	if err != nil {
+		return "", err
+	}
+	if path := value.(string); path != "" {
+		return path, nil
+	}
+	select {
+	case lit := <-result:
+		return lit.Path, lit.Err
+	case <-time.After(lightWarmup + litFrameWait + apiCommandTimeout):
+		return "", errors.New("the app did not answer in time")
+	}
+}
+
+func (s *APIServer) handleMoment(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	format := cmp.Or(r.URL.Query().Get("format"), "gif")
+	if !validMomentFormat(format) {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q, expected one of %v", format, momentFormats))
+		return
+	}
+	seconds := momentSeconds
+	if value := r.URL.Query().Get("seconds"); value != "" {
+		var err error
+		if seconds, err = strconv.ParseFloat(value, 64); err != nil || seconds <= 0 {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid seconds %q", value))
+			return
+		}
+	}
+	value, err := s.command(func(appData *CameraAppData) (any, error) {
+		return appData.Cameras[camera.ID].History.Since(time.Duration(seconds * float64(time.Second))), nil
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	frames := value.([]Frame)
+	if len(frames) == 0 {
+		writeAPIError(w, http.StatusServiceUnavailable, trErr("error.no_history"))
+		return
+	}
+	var buf bytes.Buffer
+	if err := encodeMoment(&buf, format, frames); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "image/"+format)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (s *APIServer) handleRecord(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	on, err := strconv.ParseBool(r.URL.Query().Get("on"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("on must be 1 or 0"))
+		return
+	}
+	path, err := s.record(camera.ID, on)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"recording": on, "path": path})
+}
+
+// record starts or stops recording a camera and returns the path of the
+// recording started
+func (s *APIServer) record(id int, on bool) (string, error) {
+	path, err := s.command(func(appData *CameraAppData) (any, error) {
+		c := &appData.Cameras[id]
+		if !on {
+			return "", stopRecording(c)
+		}
+		if !c.Active || c.Disabled {
+			return "", trErr("error.not_stream", c.Info.Name)
+		}
+		if err := startRecording(c); err != nil {
+			return "", err
+		}
+		return c.Recorder.Path, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return path.(string), nil
+}
+
+func (s *APIServer) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	value, err := s.command(func(appData *CameraAppData) (any, error) {
+		c := &appData.Cameras[camera.ID]
+		return map[string]any{"profiles": profileNames(c), "active": c.Profile}, nil
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, value)
+}
+
+func (s *APIServer) handleProfile(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+	value, _ := s.command(func(appData *CameraAppData) (any, error) {
+		_, ok := profileFor(&appData.Cameras[camera.ID], name)
+		return ok, nil
+	})
+	if found, _ := value.(bool); !found {
+		writeAPIError(w, http.StatusNotFound, trErr("error.no_profile", camera.Name, name))
+		return
+	}
+	_, err := s.command(func(appData *CameraAppData) (any, error) {
+		err := applyProfile(&appData.Cameras[camera.ID], name)
+		if appData.ControlsPanel.Open && appData.ControlsPanel.Camera == camera.ID {
+			refreshControls(appData)
+		}
+		return nil, err
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profile": name})
+}
+
+// apiControl describes a V4L2 control of a camera
+type apiControl struct {
+	ID      v4l2.CtrlID `json:"id"`
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Minimum int32       `json:"min"`
+	Maximum int32       `json:"max"`
+	Step    int32       `json:"step"`
+	Default int32       `json:"default"`
+	Value   int32       `json:"value"`
+}
+
+// apiControlTypes names the control types the API lists
+var apiControlTypes = map[v4l2.CtrlType]string{
+	v4l2.CtrlTypeInt:         "int",
+	v4l2.CtrlTypeBool:        "bool",
+	v4l2.CtrlTypeMenu:        "menu",
+	v4l2.CtrlTypeIntegerMenu: "menu",
+}
+
+func (s *APIServer) handleControls(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	controls, err := s.controls(camera.ID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, controls)
+}
+
+// controls lists the V4L2 controls of a camera, sorted by ID
+func (s *APIServer) controls(id int) ([]apiControl, error) {
+	value, err := s.command(func(appData *CameraAppData) (any, error) {
+		c := &appData.Cameras[id]
+		if c.Device == nil {
+			return []apiControl{}, nil
+		}
+		controls, err := c.Device.QueryControls()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the controls of %s: %w", c.Info.Name, err)
+		}
+		list := []apiControl{}
+		for _, ctrl := range controls {
+			if kind, ok := apiControlTypes[ctrl.Type]; ok {
+				list = append(list, apiControl{
+					ID: ctrl.ID, Name: ctrl.Name, Type: kind,
+					Minimum: ctrl.Minimum, Maximum: ctrl.Maximum, Step: ctrl.Step, Default: ctrl.Default, Value: ctrl.Value,
+				})
+			}
+		}
+		slices.SortFunc(list, func(a, b apiControl) int { return int(a.ID) - int(b.ID) })
+		return list, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]apiControl), nil
+}
+
+func (s *APIServer) handleSetControl(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id, errID := strconv.ParseUint(r.URL.Query().Get("id"), 10, 32)
+	value, errValue := strconv.ParseInt(r.URL.Query().Get("value"), 10, 32)
+	if errID != nil || errValue != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("id and value must be integers"))
+		return
+	}
+	if err := s.setControl(camera.ID, v4l2.CtrlID(id), int32(value)); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "value": value})
+}
+
+// setControl sets a V4L2 control of a camera
+func (s *APIServer) setControl(camera int, id v4l2.CtrlID, value int32) error {
+	_, err := s.command(func(appData *CameraAppData) (any, error) {
+		c := &appData.Cameras[camera]
+		if c.Device == nil {
+			return nil, trErr("error.not_stream", c.Info.Name)
+		}
+		ctrl, err := c.Device.GetControl(id)
+		if err != nil {
+			return nil, fmt.Errorf("%s has no control %d", c.Info.Name, id)
+		}
+		if err := c.Device.SetControlValue(ctrl.ID, value); err != nil {
+			return nil, trErr("error.set_control", ctrl.Name, err)
+		}
+		if appData.ControlsPanel.Open && appData.ControlsPanel.Camera == camera {
+			refreshControls(appData)
+		}
+		return nil, nil
+	})
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// serveAPI runs the main loop's side of an API server until the test ends
+func serveAPI(t *testing.T, appData *CameraAppData) http.Handler {
+	t.Helper()
+	s := newAPIServer()
+	serveAPIServer(t, s, appData)
+	return s.routes()
+}
+
+// serveAPIServer runs the main loop's side of s until the test ends
+func serveAPIServer(t *testing.T, s *APIServer, appData *CameraAppData) {
+	t.Helper()
+	s.Update(appData)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+				s.Update(appData)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(done)
+		<-stopped
+	})
+}
+
+func TestAPIControls(t *testing.T) {
+	dev := newFakeDevice(0)
+	dev.Controls[ctrlGain] = v4l2.Control{ID: ctrlGain, Type: v4l2.CtrlTypeInt, Name: "Gain", Minimum: 0, Maximum: 255, Step: 1}
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "bench"}, Device: dev, Active: true}}}
+	handler := serveAPI(t, appData)
+
+	request := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := request(http.MethodGet, "/api/cameras/0/controls")
+	var controls []apiControl
+	if err := json.Unmarshal(w.Body.Bytes(), &controls); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("controls %d %s: %v", w.Code, w.Body, err)
+	}
+	if len(controls) != 2 || controls[0].ID != v4l2.CtrlBrightness || controls[0].Type != "int" || controls[0].Maximum != 255 {
+		t.Errorf("controls %+v", controls)
+	}
+
+	brightness := fmt.Sprintf("/api/cameras/0/controls?id=%d", v4l2.CtrlBrightness)
+	if w := request(http.MethodPost, brightness+"&value=42"); w.Code != http.StatusOK {
+		t.Fatalf("set %d %s", w.Code, w.Body)
+	}
+	if value, _ := dev.GetControlValue(v4l2.CtrlBrightness); value != 42 {
+		t.Errorf("brightness %d", value)
+	}
+	for _, target := range []string{
+		brightness + "&value=999",
+		"/api/cameras/0/controls?id=1&value=1",
+		"/api/cameras/0/controls?id=x",
+	} {
+		if w := request(http.MethodPost, target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s answered %d", target, w.Code)
+		}
+	}
+	if w := request(http.MethodGet, "/api/cameras/7/controls"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown camera answered %d", w.Code)
+	}
+}
+
+func TestWebUI(t *testing.T) {
+	handler := newAPIServer().routes()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(w.Body.String(), "/api/cameras") {
+		t.Errorf("web UI %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown page answered %d", w.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAPITokens(t *testing.T) {
+	t.Cleanup(func() { apiTokens = nil })
+	path := filepath.Join(t.TempDir(), "api_tokens.json")
+	if err := loadAPITokens(path); err != nil || apiTokens != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	for _, bad := range []string{
+		`{"tokens": [{"token": "a", "role": "root"}]}`,
+		`{"tokens": [{"token": "", "role": "viewer"}]}`,
+		`{"tokens": [{"token": "a", "role": "viewer"}, {"token": "a", "role": "admin"}]}`,
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadAPITokens(path); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+	if err := os.WriteFile(path, []byte(`{"tokens": [{"token": "a", "role": "Operator"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadAPITokens(path); err != nil || len(apiTokens) != 1 || apiTokens[0].role != roleOperator {
+		t.Errorf("tokens %+v: %v", apiTokens, err)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	t.Cleanup(func() { apiTokens = nil })
+	handler := requireRole(roleOperator, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	status := func(setup func(r *http.Request)) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/cameras/0/record?on=0", nil)
+		setup(r)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+	none := func(r *http.Request) {}
+	if got := status(none); got != http.StatusNoContent {
+		t.Errorf("open API answered %d", got)
+	}
+
+	apiTokens = []APIToken{
+		{Token: "look", role: roleViewer},
+		{Token: "work", role: roleOperator},
+		{Token: "boss", role: roleAdmin},
+	}
+	tests := []struct {
+		setup func(r *http.Request)
+		want  int
+	}{
+		{none, http.StatusUnauthorized},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer look") }, http.StatusForbidden},
+		{func(r *http.Request) { r.Header.Set("Authorization", "Bearer work") }, http.StatusNoContent},
+		{func(r *http.Request) { r.SetBasicAuth("remote", "boss") }, http.StatusNoContent},
+		{func(r *http.Request) { r.URL.RawQuery += "&token=look" }, http.StatusForbidden},
+		{func(r *http.Request) {
+			r.SetBasicAuth("", "work")
+			r.Header.Set("Sec-Fetch-Site", "same-origin")
+			r.Header.Set("Origin", "http://example.com")
+		}, http.StatusNoContent},
+		// A form on another site, with the credentials the browser keeps
+		{func(r *http.Request) {
+			r.SetBasicAuth("", "work")
+			r.Header.Set("Sec-Fetch-Site", "cross-site")
+		}, http.StatusForbidden},
+		{func(r *http.Request) {
+			r.SetBasicAuth("", "boss")
+			r.Header.Set("Origin", "http://evil.example")
+		}, http.StatusForbidden},
+	}
+	for i, test := range tests {
+		if got := status(test.setup); got != test.want {
+			t.Errorf("request %d answered %d, want %d", i, got, test.want)
+		}
+	}
+}
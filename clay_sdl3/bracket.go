@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// An exposure bracket takes a burst of frames of a camera at exposures
+// spread evenly around the current one, for parts with both shiny and dark
+// surfaces such as machined metal. The camera is switched to manual
+// exposure for the burst, the exposure time is stepped from frame to frame
+// and the camera is put back as it was when the burst is done. The frames
+// are saved as PNG files named after the stop they were taken at, and
+// unless -bracket-merge=false fused into one where every area comes from
+// the frames that exposed it best.
+
+var (
+	// bracketFrames is how many frames a bracket takes, set with -bracket
+	bracketFrames = 5
+	// bracketStep is the exposure step between frames in stops, set with
+	// -bracket-step
+	bracketStep = 1.0
+	// bracketMerge fuses the frames of a bracket, set with -bracket-merge
+	bracketMerge = true
+)
+
+// bracketSettle is how many frames are skipped after each exposure change,
+// since the frames already queued by the driver were taken before it
+const bracketSettle = 4
+
+// bracketTimeout is how long a bracket may take before it is given up, for
+// cameras that stop delivering frames
+const bracketTimeout = 15 * time.Second
+
+// fusionSigma is the spread, in normalized luma, of the weight the fusion
+// gives a pixel around mid grey
+const fusionSigma = 0.2
+
+// ExposureBracket is a burst of frames being taken at stepped exposures
+type ExposureBracket struct {
+	// Stops are the exposure offsets of the frames in EV, Values the
+	// exposure control values they map to
+	Stops  []float64
+	Values []int32
+	// Frames are the frames taken so far
+	Frames  []*image.RGBA
+	Started time.Time
+
+	// settle counts the frames still to skip after an exposure change
+	settle int
+	// base and auto are the exposure time and mode to restore, auto only
+	// if hasAuto
+	base, auto int32
+	hasAuto    bool
+	err        error
+}
+
+// bracketValues spreads frames exposures step stops apart around base,
+// within the control's range, and returns them with their stops
+func bracketValues(base, minimum, maximum int32, frames int, step float64) ([]float64, []int32) {
+	stops := make([]float64, frames)
+	values := make([]int32, frames)
+	for i := range frames {
+		stops[i] = (float64(i) - float64(frames-1)/2) * step
+		value := math.Round(float64(max(base, 1)) * math.Exp2(stops[i]))
+		values[i] = int32(min(max(value, float64(minimum)), float64(maximum)))
+	}
+	return stops, values
+}
+
+// startBracket switches a camera to manual exposure and begins taking a
+// bracket
+func startBracket(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+	switch {
+	case !camera.Active || camera.Disabled:
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	case !hasControl(camera, ctrlExposureAbsolute):
+		setErrorStatus(appData, trErr("error.bracket_exposure", camera.Info.Name))
+		return
+	case camera.Metering != nil:
+		setErrorStatus(appData, trErr("error.bracket_metering", camera.Info.Name))
+		return
+	case overMemoryBudget():
+		setErrorStatus(appData, trErr("error.memory_budget"))
+		return
+	}
+
+	exposure, err := camera.Device.GetControl(ctrlExposureAbsolute)
+	if err != nil {
+		setErrorStatus(appData, fmt.Errorf("failed to read the exposure of %s: %w", camera.Info.Name, err))
+		return
+	}
+	bracket := &ExposureBracket{base: exposure.Value, Started: time.Now(), settle: bracketSettle}
+	bracket.Stops, bracket.Values = bracketValues(exposure.Value, exposure.Minimum, exposure.Maximum,
+		max(bracketFrames, 2), bracketStep)
+	if hasControl(camera, ctrlExposureAuto) {
+		if auto, err := camera.Device.GetControl(ctrlExposureAuto); err == nil {
+			bracket.auto, bracket.hasAuto = auto.Value, true
+		}
+		if err := camera.Device.SetControlValue(ctrlExposureAuto, exposureManual); err != nil {
+			log.Printf("Failed to switch %s to manual exposure: %v", camera.Info.Name, err)
+		}
+	}
+	if err := camera.Device.SetControlValue(ctrlExposureAbsolute, bracket.Values[0]); err != nil {
+		restoreExposure(camera, bracket)
+		setErrorStatus(appData, trErr("error.set_control", exposure.Name, err))
+		return
+	}
+
+	camera.FrameMutex.Lock()
+	camera.Bracket = bracket
+	camera.FrameMutex.Unlock()
+	appData.StatusText = tr("status.bracketing", camera.Info.Name, 0, len(bracket.Values))
+	appData.StatusColor = theme.Text
+}
+
+// cancelBracket stops a bracket without saving and puts the exposure back
+func cancelBracket(camera *CameraInstance) {
+	camera.FrameMutex.Lock()
+	bracket := camera.Bracket
+	camera.Bracket = nil
+	camera.FrameMutex.Unlock()
+	if bracket != nil {
+		restoreExposure(camera, bracket)
+	}
+}
+
+// restoreExposure puts back the exposure a camera had before a bracket
+func restoreExposure(camera *CameraInstance, bracket *ExposureBracket) {
+	if camera.Device == nil {
+		return
+	}
+	if err := camera.Device.SetControlValue(ctrlExposureAbsolute, bracket.base); err != nil {
+		log.Printf("Failed to restore the exposure of %s: %v", camera.Info.Name, err)
+	}
+	if bracket.hasAuto {
+		if err := camera.Device.SetControlValue(ctrlExposureAuto, bracket.auto); err != nil {
+			log.Printf("Failed to restore auto exposure on %s: %v", camera.Info.Name, err)
+		}
+	}
+}
+
+// captureBracket keeps a settled frame for the camera's bracket and moves
+// the exposure on to the next stop. It is called with the decoded frame
+// while FrameMutex is held.
+func captureBracket(camera *CameraInstance, img *image.RGBA) {
+	b := camera.Bracket
+	if b == nil || b.err != nil || len(b.Frames) >= len(b.Values) {
+		return
+	}
+	if b.settle > 0 {
+		b.settle--
+		return
+	}
+	frame := image.NewRGBA(img.Bounds())
+	copy(frame.Pix, img.Pix)
+	b.Frames = append(b.Frames, frame)
+	if next := len(b.Frames); next < len(b.Values) {
+		b.err = camera.Device.SetControlValue(ctrlExposureAbsolute, b.Values[next])
+		b.settle = bracketSettle
+	}
+}
+
+// fuseExposures blends frames of the same scene taken at different
+// exposures, weighting each pixel by how close its luma is to mid grey, so
+// that bright areas come from the short exposures and dark ones from the
+// long ones
+func fuseExposures(frames []*image.RGBA) *image.RGBA {
+	var weights [256]float64
+	for i := range weights {
+		d := float64(i)/255 - 0.5
+		// The floor keeps pixels clipped in every frame defined
+		weights[i] = math.Exp(-d*d/(2*fusionSigma*fusionSigma)) + 1e-6
+	}
+
+	bounds := frames[0].Bounds()
+	fused := image.NewRGBA(bounds)
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			var r, g, b, total float64
+			for _, frame := range frames {
+				if frame.Bounds() != bounds {
+					continue
+				}
+				p := frame.Pix[y*frame.Stride+x*4:]
+				w := weights[(77*uint32(p[0])+150*uint32(p[1])+29*uint32(p[2]))>>8]
+				r += w * float64(p[0])
+				g += w * float64(p[1])
+				b += w * float64(p[2])
+				total += w
+			}
+			q := fused.Pix[y*fused.Stride+x*4:]
+			q[0] = uint8(r/total + 0.5)
+			q[1] = uint8(g/total + 0.5)
+			q[2] = uint8(b/total + 0.5)
+			q[3] = 255
+		}
+	}
+	return fused
+}
+
+// saveBracketFrame writes an image of a bracket as a PNG file
+func saveBracketFrame(path string, img image.Image) error {
+	f, err := createOutputFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to save bracket: %w", err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to encode bracket: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save bracket: %w", err)
+	}
+	return nil
+}
+
+// saveBracket writes the frames of a finished bracket, and their fusion if
+// enabled, as PNG files in the snapshots folder, and returns the paths
+func saveBracket(camera *CameraInstance, b *ExposureBracket) ([]string, error) {
+	path, err := outputPath(snapshotDir, camera, "png")
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(path, ".png")
+
+	var paths []string
+	for i, frame := range b.Frames {
+		path := fmt.Sprintf("%s_ev%+.1f.png", base, b.Stops[i])
+		if err := saveBracketFrame(path, frame); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	if bracketMerge {
+		path := base + "_fused.png"
+		if err := saveBracketFrame(path, fuseExposures(b.Frames)); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		if err := saveMetadataSidecar(path, camera); err != nil {
+			log.Printf("Bracket of %s: %v", camera.Info.Name, err)
+		}
+	}
+	return paths, nil
+}
+
+// updateBrackets shows the progress of running brackets, saves the finished
+// ones and gives up on the ones that failed or stalled
+func updateBrackets(appData *CameraAppData) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		camera.FrameMutex.RLock()
+		b := camera.Bracket
+		var taken int
+		var err error
+		if b != nil {
+			taken, err = len(b.Frames), b.err
+		}
+		camera.FrameMutex.RUnlock()
+		if b == nil {
+			continue
+		}
+
+		switch {
+		case err != nil:
+			cancelBracket(camera)
+			setErrorStatus(appData, trErr("error.bracket_failed", camera.Info.Name, err))
+			continue
+		case taken < len(b.Values) && time.Since(b.Started) > bracketTimeout:
+			cancelBracket(camera)
+			setErrorStatus(appData, trErr("error.bracket_timeout", camera.Info.Name, taken, len(b.Values)))
+			continue
+		case taken < len(b.Values):
+			if i == appData.SelectedCamera {
+				appData.StatusText = tr("status.bracketing", camera.Info.Name, taken, len(b.Values))
+			}
+			continue
+		}
+
+		cancelBracket(camera)
+		paths, err := saveBracket(camera, b)
+		if err != nil {
+			setErrorStatus(appData, err)
+			continue
+		}
+		appData.StatusText = tr("status.bracket_saved", len(paths), paths[len(paths)-1])
+		appData.StatusColor = theme.Success
+	}
+}
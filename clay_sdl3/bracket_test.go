@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+func TestBracketValues(t *testing.T) {
+	stops, values := bracketValues(100, 3, 300, 5, 1)
+	if !slices.Equal(stops, []float64{-2, -1, 0, 1, 2}) {
+		t.Errorf("stops %v", stops)
+	}
+	// The longest exposures are clamped to the control's range
+	if !slices.Equal(values, []int32{25, 50, 100, 200, 300}) {
+		t.Errorf("values %v", values)
+	}
+	if _, values := bracketValues(0, 1, 1000, 3, 0.5); values[0] != 1 || values[2] != 1 {
+		t.Errorf("values around 0: %v", values)
+	}
+}
+
+func TestCaptureBracket(t *testing.T) {
+	dev := newFakeDevice(0)
+	dev.Controls[ctrlExposureAbsolute] = v4l2.Control{ID: ctrlExposureAbsolute, Name: "Exposure", Minimum: 1, Maximum: 1000, Step: 1, Value: 100}
+	dev.Controls[ctrlExposureAuto] = v4l2.Control{ID: ctrlExposureAuto, Name: "Auto Exposure", Minimum: 0, Maximum: 3, Step: 1, Value: exposureAperturePriority}
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "test"}, Device: dev, Active: true}}}
+	camera := &appData.Cameras[0]
+
+	startBracket(appData, 0)
+	if camera.Bracket == nil {
+		t.Fatalf("bracket not started: %s", appData.StatusText)
+	}
+	if auto, _ := dev.GetControlValue(ctrlExposureAuto); auto != exposureManual {
+		t.Errorf("exposure mode during the bracket is %d", auto)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	var exposures []int32
+	for range len(camera.Bracket.Values) * (bracketSettle + 1) {
+		exposure, _ := dev.GetControlValue(ctrlExposureAbsolute)
+		before := len(camera.Bracket.Frames)
+		captureBracket(camera, img)
+		if len(camera.Bracket.Frames) > before {
+			exposures = append(exposures, exposure)
+		}
+	}
+	if !slices.Equal(exposures, camera.Bracket.Values) {
+		t.Errorf("frames taken at %v, want %v", exposures, camera.Bracket.Values)
+	}
+
+	cancelBracket(camera)
+	exposure, _ := dev.GetControlValue(ctrlExposureAbsolute)
+	auto, _ := dev.GetControlValue(ctrlExposureAuto)
+	if exposure != 100 || auto != exposureAperturePriority {
+		t.Errorf("after the bracket exposure is %d in mode %d", exposure, auto)
+	}
+}
+
+func TestFuseExposures(t *testing.T) {
+	// The left pixel is clipped in the long exposure, the right one nearly
+	// black in the short one
+	short := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	long := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	copy(short.Pix, []uint8{120, 120, 120, 255, 5, 5, 5, 255})
+	copy(long.Pix, []uint8{255, 255, 255, 255, 130, 130, 130, 255})
+
+	fused := fuseExposures([]*image.RGBA{short, long})
+	if left := fused.Pix[0]; left < 120 || left > 160 {
+		t.Errorf("bright area fused to %d", left)
+	}
+	if right := fused.Pix[4]; right < 100 || right > 130 {
+		t.Errorf("dark area fused to %d", right)
+	}
+	if fused.Pix[3] != 255 {
+		t.Errorf("alpha %d", fused.Pix[3])
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A configuration bundle is one zip file with everything needed to set up
+// another machine like this one, for installs replicated across several
+// Pis: the command line settings, camera names, layout, control profiles,
+// schedule and saved sessions, and the current state as a session, which
+// carries the calibrations and overlays. Importing it, from the sessions
+// panel or with -import-bundle, writes the files in place, keeping the
+// replaced ones as .bak, and applies them. The command line settings are
+// written to settingsFile, which is read at every start before the command
+// line, so they apply from the next start. Settings holding passwords,
+// tokens or keys are left out of bundles.
+
+const (
+	bundleDir = "bundles"
+	// bundleManifest describes a bundle and holds its settings
+	bundleManifest = "bundle.json"
+	// bundleState is the state of the exporting app as a session
+	bundleState = "state.json"
+	// maxBundleFile bounds the files read from a bundle
+	maxBundleFile = 16 << 20
+	// maxListedBundles is how many bundles the sessions panel offers
+	maxListedBundles = 5
+)
+
+// settingsFile holds command line arguments, one per line, that apply
+// before the ones given on the command line
+var settingsFile = "settings.args"
+
+// launchArgs are the arguments the app was started with, those from
+// settingsFile first
+var launchArgs []string
+
+// BundleManifest describes a configuration bundle
+type BundleManifest struct {
+	Version string    `json:"version"`
+	Created time.Time `json:"created"`
+	Host    string    `json:"host"`
+	// Args are the command line settings, without those holding secrets
+	Args []string `json:"args"`
+	// Omitted counts the settings left out for holding secrets
+	Omitted int `json:"omitted,omitempty"`
+}
+
+// bundleFiles maps the names of the files in a bundle to where they are
+// kept
+func bundleFiles() map[string]string {
+	return map[string]string{
+		"camera_names.json": namesFile,
+		"layout.json":       layoutFile,
+		"profiles.json":     profilesFile,
+		"schedule.json":     scheduleFile,
+	}
+}
+
+// parseFlags parses the settings from settingsFile followed by the command
+// line, which overrides them
+func parseFlags() {
+	saved, err := readSettings(settingsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	launchArgs = append(saved, os.Args[1:]...)
+	// The command line exits on errors itself
+	_ = flag.CommandLine.Parse(launchArgs)
+}
+
+// settingsFlags are the flags settings are checked against before they
+// are saved
+var settingsFlags = flag.CommandLine
+
+// checkSettings parses args with stand-ins for settingsFlags, leaving the
+// flags themselves alone, so settings that would stop the app from starting
+// are refused instead of saved. The values of flags with their own parsers
+// are not checked.
+func checkSettings(args []string) error {
+	set := flag.NewFlagSet("settings", flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+	settingsFlags.VisitAll(func(f *flag.Flag) {
+		var value any
+		if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+		switch value.(type) {
+		case bool:
+			set.Bool(f.Name, false, "")
+		case int:
+			set.Int(f.Name, 0, "")
+		case int64:
+			set.Int64(f.Name, 0, "")
+		case uint:
+			set.Uint(f.Name, 0, "")
+		case uint64:
+			set.Uint64(f.Name, 0, "")
+		case float64:
+			set.Float64(f.Name, 0, "")
+		case time.Duration:
+			set.Duration(f.Name, 0, "")
+		default:
+			set.Func(f.Name, "", func(string) error { return nil })
+		}
+	})
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+	if set.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", set.Arg(0))
+	}
+	return nil
+}
+
+// readSettings reads the arguments in a settings file, skipping blank lines
+// and # comments
+func readSettings(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	defer file.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			args = append(args, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	return args, nil
+}
+
+// writeSettings replaces settingsFile with args, keeping the old one as
+// .bak
+func writeSettings(args []string) error {
+	text := "# Settings imported from a configuration bundle, one argument per line;\n# the command line overrides them\n"
+	for _, arg := range args {
+		text += arg + "\n"
+	}
+	return replaceFile(settingsFile, []byte(text))
+}
+
+// isBoolFlag reports whether a flag is a boolean one, which takes no
+// separate value
+func isBoolFlag(name string) bool {
+	f := flag.CommandLine.Lookup(strings.TrimLeft(name, "-"))
+	if f == nil {
+		return false
+	}
+	value, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && value.IsBoolFlag()
+}
+
+// bundleArgs returns the arguments worth carrying to another machine,
+// leaving out those holding passwords, tokens or keys, and how many were
+// left out
+func bundleArgs(args []string) ([]string, int) {
+	var kept []string
+	omitted := 0
+	for i := 0; i < len(args); i++ {
+		group := args[i : i+1]
+		name, _, hasValue := strings.Cut(args[i], "=")
+		if !hasValue && !isBoolFlag(name) && i+1 < len(args) {
+			group = args[i : i+2]
+			i++
+		}
+		switch {
+		case strings.TrimLeft(name, "-") == "import-bundle":
+		case secretFlagPattern.MatchString(name) || slices.ContainsFunc(group, credentialsPattern.MatchString):
+			omitted++
+		default:
+			kept = append(kept, group...)
+		}
+	}
+	return kept, omitted
+}
+
+// exportBundle writes a configuration bundle and returns its path and
+// manifest
+func exportBundle(appData *CameraAppData) (string, BundleManifest, error) {
+	host, _ := os.Hostname()
+	manifest := BundleManifest{Version: currentBuild().Version, Created: time.Now(), Host: host}
+	manifest.Args, manifest.Omitted = bundleArgs(launchArgs)
+
+	if err := os.MkdirAll(bundleDir, 0o755); err != nil {
+		return "", manifest, fmt.Errorf("failed to create %s: %w", bundleDir, err)
+	}
+	name := "config_" + manifest.Created.Format("20060102_150405")
+	if host != "" {
+		name = "config_" + fileSafeName(host) + "_" + manifest.Created.Format("20060102_150405")
+	}
+	bundlePath := filepath.Join(bundleDir, name+".zip")
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		return "", manifest, fmt.Errorf("failed to export configuration: %w", err)
+	}
+	archive := zip.NewWriter(file)
+
+	add := func(name string, data []byte) error {
+		w, err := archive.Create(name)
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		return err
+	}
+	addJSON := func(name string, value any) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, data)
+	}
+
+	err = addJSON(bundleManifest, manifest)
+	if err == nil {
+		err = addJSON(bundleState, captureSession(appData, tr("bundle.state", host)))
+	}
+	for name, kept := range bundleFiles() {
+		if err != nil {
+			break
+		}
+		data, readErr := os.ReadFile(kept)
+		if errors.Is(readErr, fs.ErrNotExist) {
+			continue
+		} else if readErr != nil {
+			err = readErr
+			break
+		}
+		err = add(name, data)
+	}
+	if err == nil {
+		sessions, listErr := listSessions()
+		err = listErr
+		for _, session := range sessions {
+			data, readErr := os.ReadFile(sessionPath(session))
+			if readErr != nil {
+				err = readErr
+				break
+			}
+			if err = add(path.Join(sessionDir, filepath.Base(sessionPath(session))), data); err != nil {
+				break
+			}
+		}
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(bundlePath)
+		return "", manifest, fmt.Errorf("failed to export configuration: %w", err)
+	}
+	return bundlePath, manifest, nil
+}
+
+// importBundle applies a configuration bundle and returns its manifest.
+// Everything in it is read and checked before any file is replaced.
+func importBundle(appData *CameraAppData, bundlePath string) (BundleManifest, error) {
+	var manifest BundleManifest
+	archive, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to open configuration bundle: %w", err)
+	}
+	defer archive.Close()
+
+	files := bundleFiles()
+	writes := make(map[string][]byte)
+	var state *Session
+	hasManifest := false
+	for _, entry := range archive.File {
+		target, known := files[entry.Name]
+		dir, base := path.Split(entry.Name)
+		if !known && dir == sessionDir+"/" && path.Ext(base) == ".json" && fileSafeName(strings.TrimSuffix(base, ".json")) == strings.TrimSuffix(base, ".json") {
+			target, known = filepath.Join(sessionDir, base), true
+		}
+		if !known && entry.Name != bundleManifest && entry.Name != bundleState {
+			continue
+		}
+
+		data, err := readBundleFile(entry)
+		if err != nil {
+			return manifest, fmt.Errorf("failed to read %s from the bundle: %w", entry.Name, err)
+		}
+		if !json.Valid(data) {
+			return manifest, fmt.Errorf("%s in the bundle is not valid JSON", entry.Name)
+		}
+		switch entry.Name {
+		case bundleManifest:
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return manifest, fmt.Errorf("failed to parse %s: %w", entry.Name, err)
+			}
+			hasManifest = true
+		case bundleState:
+			state = new(Session)
+			if err := json.Unmarshal(data, state); err != nil {
+				return manifest, fmt.Errorf("failed to parse %s: %w", entry.Name, err)
+			}
+		default:
+			writes[target] = data
+		}
+	}
+	if !hasManifest {
+		return manifest, fmt.Errorf("%s is not a configuration bundle", bundlePath)
+	}
+	if err := checkSettings(manifest.Args); err != nil {
+		return manifest, fmt.Errorf("the settings in %s do not work with this version: %w", bundlePath, err)
+	}
+
+	if err := os.MkdirAll(sessionDir, 0o755); err != nil {
+		return manifest, fmt.Errorf("failed to create %s: %w", sessionDir, err)
+	}
+	for target, data := range writes {
+		if err := replaceFile(target, data); err != nil {
+			return manifest, err
+		}
+	}
+	if err := writeSettings(manifest.Args); err != nil {
+		return manifest, err
+	}
+
+	// Apply what can change while running
+	var errs []error
+	if err := loadCameraNames(); err != nil {
+		errs = append(errs, err)
+	}
+	for i := range appData.Cameras {
+		info := &appData.Cameras[i].Info
+		if name := cameraNames[cameraID(*info)]; name != "" {
+			info.Name = name
+		}
+	}
+	cameraProfiles = nil
+	if err := loadLayout(appData); err != nil {
+		errs = append(errs, err)
+	}
+	if err := loadSchedule(scheduleFile, time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+	if state != nil {
+		applySession(appData, state)
+	}
+	return manifest, errors.Join(errs...)
+}
+
+// readBundleFile reads a file of a bundle, up to maxBundleFile
+func readBundleFile(entry *zip.File) ([]byte, error) {
+	if entry.UncompressedSize64 > maxBundleFile {
+		return nil, fmt.Errorf("%d bytes is too large", entry.UncompressedSize64)
+	}
+	r, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, maxBundleFile))
+}
+
+// replaceFile writes data to target, keeping the file it replaces as .bak
+func replaceFile(target string, data []byte) error {
+	if old, err := os.ReadFile(target); err == nil {
+		if err := os.WriteFile(target+".bak", old, 0o644); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", target, err)
+		}
+	}
+	if err := os.WriteFile(target, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
+
+// listBundles returns the paths of the newest bundles in bundleDir, newest
+// first
+func listBundles() []string {
+	bundles, _ := filepath.Glob(filepath.Join(bundleDir, "*.zip"))
+	slices.SortFunc(bundles, func(a, b string) int {
+		return modTime(b).Compare(modTime(a))
+	})
+	return bundles[:min(len(bundles), maxListedBundles)]
+}
+
+// modTime returns when a file was last written, zero if it cannot tell
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reportBundleImport shows the outcome of importing a bundle
+func reportBundleImport(appData *CameraAppData, bundlePath string, manifest BundleManifest, err error) {
+	if err != nil {
+		setErrorStatus(appData, err)
+		return
+	}
+	appData.StatusText = tr("status.bundle_imported", filepath.Base(bundlePath), manifest.Host)
+	if len(manifest.Args) > 0 {
+		appData.StatusText += tr("status.bundle_restart")
+	}
+	appData.StatusColor = theme.Success
+	notify(toastInfo, appData.StatusText)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+type calibrationStage int
+
+const (
+	calibrationOff calibrationStage = iota
+	// calibrationWaiting waits for a drag across a feature of known length
+	calibrationWaiting
+	calibrationDragging
+	// calibrationEntering reads the length of the dragged line in mm
+	calibrationEntering
+)
+
+// CalibrationState tracks setting a camera's scale by dragging across a
+// feature of known length on the main view and typing that length
+type CalibrationState struct {
+	Stage  calibrationStage
+	Camera int
+	// Start and End are the line in normalized frame coordinates
+	Start, End sdl.FPoint
+	Text       string
+}
+
+// startCalibration shows a camera in the main view and waits for the line
+// to be drawn
+func startCalibration(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+	if !camera.Active || camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+	selectCamera(appData, index)
+	appData.Calibration = CalibrationState{Stage: calibrationWaiting, Camera: index}
+	appData.StatusText = tr("status.calibrate_drag", camera.Info.Name)
+}
+
+// startCalibrationDrag begins the calibration line on a click in the main
+// view. It reports whether the click was consumed.
+func startCalibrationDrag(appData *CameraAppData, x, y float32) bool {
+	calibration := &appData.Calibration
+	if calibration.Stage != calibrationWaiting {
+		return false
+	}
+	rect, ok := mainCameraRect()
+	if !ok || calibration.Camera != appData.SelectedCamera ||
+		x < rect.X || x > rect.X+rect.W || y < rect.Y || y > rect.Y+rect.H {
+		cancelCalibration(appData)
+		return false
+	}
+	u, v := viewToFrame(rect, x, y)
+	calibration.Start = sdl.FPoint{X: u, Y: v}
+	calibration.End = calibration.Start
+	calibration.Stage = calibrationDragging
+	return true
+}
+
+// finishCalibrationDrag ends the calibration line and asks for its length
+func finishCalibrationDrag(appData *CameraAppData, x, y float32) {
+	calibration := &appData.Calibration
+	if calibration.Stage != calibrationDragging {
+		return
+	}
+	rect, ok := mainCameraRect()
+	if !ok {
+		cancelCalibration(appData)
+		return
+	}
+	u, v := viewToFrame(rect, x, y)
+	calibration.End = sdl.FPoint{X: u, Y: v}
+	if calibrationPixels(appData) < 10 {
+		// Too short to be useful; draw it again
+		calibration.Stage = calibrationWaiting
+		return
+	}
+
+	calibration.Stage = calibrationEntering
+	calibration.Text = ""
+	if err := appData.Window.StartTextInput(); err != nil {
+		log.Printf("Failed to start text input: %v", err)
+	}
+	appData.StatusText = tr("status.calibrate_length", "_")
+}
+
+// calibrationPixels returns the length of the calibration line in frame
+// pixels
+func calibrationPixels(appData *CameraAppData) float32 {
+	calibration := &appData.Calibration
+	camera := &appData.Cameras[calibration.Camera]
+	dx := (calibration.End.X - calibration.Start.X) * float32(camera.Width)
+	dy := (calibration.End.Y - calibration.Start.Y) * float32(camera.Height)
+	return float32(math.Hypot(float64(dx), float64(dy)))
+}
+
+// handleCalibrationKey edits the length being typed and applies it on Enter
+func handleCalibrationKey(appData *CameraAppData, scancode sdl.Scancode) {
+	calibration := &appData.Calibration
+	switch scancode {
+	case sdl.SCANCODE_RETURN, sdl.SCANCODE_KP_ENTER:
+		text := strings.Replace(strings.TrimSpace(calibration.Text), ",", ".", 1)
+		mm, err := strconv.ParseFloat(text, 32)
+		if err != nil || mm <= 0 {
+			setErrorStatus(appData, trErr("error.calibrate_length", calibration.Text))
+			return
+		}
+		camera := &appData.Cameras[calibration.Camera]
+		camera.PixelsPerMM = calibrationPixels(appData) / float32(mm)
+		cancelCalibration(appData)
+		appData.StatusText = tr("status.calibrated", camera.Info.Name, camera.PixelsPerMM)
+		return
+	case sdl.SCANCODE_ESCAPE:
+		cancelCalibration(appData)
+		appData.StatusText = tr("status.ready")
+		return
+	case sdl.SCANCODE_BACKSPACE:
+		text := []rune(calibration.Text)
+		if len(text) > 0 {
+			calibration.Text = string(text[:len(text)-1])
+		}
+	}
+	appData.StatusText = tr("status.calibrate_length", calibration.Text+"_")
+}
+
+// handleCalibrationText adds typed text to the length being entered
+func handleCalibrationText(appData *CameraAppData, text string) {
+	appData.Calibration.Text += text
+	appData.StatusText = tr("status.calibrate_length", appData.Calibration.Text+"_")
+}
+
+// cancelCalibration ends the calibration, stopping any text input
+func cancelCalibration(appData *CameraAppData) {
+	if appData.Calibration.Stage == calibrationEntering {
+		if err := appData.Window.StopTextInput(); err != nil {
+			log.Printf("Failed to stop text input: %v", err)
+		}
+	}
+	appData.Calibration = CalibrationState{}
+}
+
+// renderCalibrationLine draws the calibration line while it is drawn and
+// its length entered
+func renderCalibrationLine(appData *CameraAppData, pointer sdl.FPoint) {
+	calibration := &appData.Calibration
+	if calibration.Stage != calibrationDragging && calibration.Stage != calibrationEntering {
+		return
+	}
+	rect, ok := mainCameraRect()
+	if !ok {
+		return
+	}
+	x0, y0 := frameToView(rect, calibration.Start.X, calibration.Start.Y)
+	x1, y1 := pointer.X, pointer.Y
+	if calibration.Stage == calibrationEntering {
+		x1, y1 = frameToView(rect, calibration.End.X, calibration.End.Y)
+	}
+	_ = appData.Renderer.SetDrawColor(uint8(theme.Accent.R), uint8(theme.Accent.G), uint8(theme.Accent.B), 255)
+	_ = appData.Renderer.RenderLine(x0, y0, x1, y1)
+}
@@ -1,13 +1,10 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"github.com/TotallyGamerJet/clay"
 	"github.com/Zyko0/go-sdl3/sdl"
 	"github.com/vladimirvivien/go4vl/device"
-	"github.com/vladimirvivien/go4vl/v4l2"
 	"image"
 	"image/draw"
 	"image/jpeg"
@@ -59,7 +56,7 @@ func findCameraDevices() ([]CameraInfo, error) {
 		// Clean up the name string by removing null bytes
 		name = strings.TrimRight(name, "\x00")
 		if name == "" {
-			name = fmt.Sprintf("Camera %d", index)
+			name = tr("camera.default", index)
 		}
 
 		// Add to our list
@@ -67,6 +64,7 @@ func findCameraDevices() ([]CameraInfo, error) {
 			Path:  devicePath,
 			Name:  name,
 			Index: index,
+			ID:    stableID(devicePath, caps),
 		})
 
 		// Close the device as we're just checking
@@ -143,22 +141,33 @@ func findRaspberryPiCameras() ([]string, error) {
 }
 
 func initAllCameras(appData *CameraAppData) {
-	// List available camera devices
-	devices, err := findCameraDevices()
-	if err != nil {
-		appData.StatusText = "Error listing devices: " + err.Error()
-		appData.StatusColor = clay.Color{R: 255, G: 100, B: 100, A: 255}
-		return
+	// List available camera devices; a replay stands in for all of them
+	var devices []CameraInfo
+	var err error
+	if replay != nil {
+		devices = findReplayCameras(0)
+	} else {
+		devices, err = findCameraDevices()
+		if err != nil {
+			appData.StatusText = tr("status.list_error", err)
+			appData.StatusColor = theme.Error
+			return
+		}
+
+		devices = append(devices, findWatchFolders(len(devices))...)
+		devices = append(devices, findRemoteCameras(len(devices))...)
+		devices = append(devices, findPlaybackCameras(len(devices))...)
+		applyCameraNames(devices)
 	}
 
 	if len(devices) == 0 {
-		appData.StatusText = "No camera devices found"
-		appData.StatusColor = clay.Color{R: 255, G: 100, B: 100, A: 255}
+		appData.StatusText = tr("status.no_devices")
+		appData.StatusColor = theme.Error
 		return
 	}
 
-	appData.StatusText = fmt.Sprintf("Found %d camera devices", len(devices))
-	appData.StatusColor = clay.Color{R: 100, G: 255, B: 100, A: 255}
+	appData.StatusText = tr("status.found", len(devices))
+	appData.StatusColor = theme.Success
 	log.Printf("Found %d camera devices: %v", len(devices), devices)
 
 	// Initialize cameras array
@@ -168,31 +177,37 @@ func initAllCameras(appData *CameraAppData) {
 	for i, deviceInfo := range devices {
 		camera := &appData.Cameras[i]
 		camera.Info = deviceInfo
+		camera.ThumbnailVisible = true
 
 		// Initialize the camera device
 		err = initSingleCamera(camera, appData.Renderer)
 		if err != nil {
 			log.Printf("Failed to initialize camera %s: %v", deviceInfo.Name, err)
+			notify(toastError, tr("toast.init_failed", deviceInfo.Name, err))
 			camera.Active = false
+			camera.Device = nil
+			if scheduleStartRetry(camera) {
+				log.Printf("Retrying camera %s in %s", deviceInfo.Name, camera.StartRetry.Delay)
+			}
 		} else {
 			// Start frame capture for this camera
-			go captureFramesForCamera(camera)
+			startCapture(camera)
 		}
 	}
 
 	// Update status
 	activeCameras := 0
-	for _, camera := range appData.Cameras {
-		if camera.Active {
+	for i := range appData.Cameras {
+		if appData.Cameras[i].Active {
 			activeCameras++
 		}
 	}
 
-	appData.StatusText = fmt.Sprintf("Initialized %d/%d cameras", activeCameras, len(devices))
+	appData.StatusText = tr("status.initialized", activeCameras, len(devices))
 	if activeCameras > 0 {
-		appData.StatusColor = clay.Color{R: 100, G: 255, B: 100, A: 255}
+		appData.StatusColor = theme.Success
 	} else {
-		appData.StatusColor = clay.Color{R: 255, G: 100, B: 100, A: 255}
+		appData.StatusColor = theme.Error
 	}
 }
 func initSingleCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
@@ -200,32 +215,25 @@ func initSingleCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
 	if strings.HasPrefix(camera.Info.Path, "rpicam:") {
 		return initRaspberryPiCamera(camera, renderer)
 	}
-
-	// Handle regular V4L2 cameras (existing code)
-	dev, err := device.Open(
-		camera.Info.Path,
-		device.WithIOType(v4l2.IOTypeMMAP),
-		device.WithPixFormat(v4l2.PixFormat{
-			Width:       640,
-			Height:      480,
-			PixelFormat: v4l2.PixelFmtMJPEG,
-			Field:       v4l2.FieldNone,
-		}),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to open camera: %w", err)
+	if isFolderSource(camera) {
+		return initFolderSource(camera, renderer)
+	}
+	if isRemoteSource(camera) {
+		return initRemoteCamera(camera, renderer)
+	}
+	if isReplaySource(camera) {
+		return initReplayCamera(camera, renderer)
+	}
+	if isPlaybackSource(camera) {
+		return initPlaybackCamera(camera, renderer)
 	}
 
-	camera.Device = dev
-
-	// Get actual camera format
-	format, err := dev.GetPixFormat()
+	// Handle regular V4L2 cameras (existing code)
+	frames, stopStream, err := openDeviceStream(camera)
 	if err != nil {
-		dev.Close()
-		return fmt.Errorf("failed to get pixel format: %w", err)
+		return err
 	}
-
-	log.Printf("Camera %s format: %+v", camera.Info.Name, format)
+	format := camera.PixFormat
 	camera.Width = int(format.Width)
 	camera.Height = int(format.Height)
 
@@ -237,7 +245,7 @@ func initSingleCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
 		camera.Height,
 	)
 	if err != nil {
-		dev.Close()
+		stopStream()
 		return fmt.Errorf("failed to create main texture: %w", err)
 	}
 
@@ -257,24 +265,82 @@ func initSingleCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
 	)
 	if err != nil {
 		camera.Texture.Destroy()
-		dev.Close()
+		camera.Texture = nil
+		stopStream()
 		return fmt.Errorf("failed to create thumbnail texture: %w", err)
 	}
 
-	// Start the camera stream
-	if err = dev.Start(context.Background()); err != nil {
-		camera.ThumbnailTexture.Destroy()
-		camera.Texture.Destroy()
-		dev.Close()
-		return fmt.Errorf("failed to start camera: %w", err)
-	}
-
+	camera.DeviceFrames = frames
 	camera.Active = true
-	camera.FrameChan = make(chan []byte, 10)
+	camera.FrameChan = make(chan Frame, frameQueueSize(camera.StreamFPS))
 
 	return nil
 }
 
+// openDeviceStream opens a V4L2 camera, applies its crop, negotiates its
+// format and starts streaming. The returned function stops the stream and
+// closes the device again, for when the rest of the setup fails.
+func openDeviceStream(camera *CameraInstance) (<-chan []byte, func(), error) {
+	lock, err := acquireDeviceLock(camera.Info.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	opened, err := openDevice(camera.Info.Path)
+	if err != nil {
+		lock.release()
+		return nil, nil, fmt.Errorf("failed to open camera: %w", explainBusy(camera.Info.Path, err))
+	}
+	// Closing the device releases the lock
+	dev := lockedDevice{opened, lock}
+
+	camera.Device = dev
+
+	// A crop has to be set before the format, which takes its size
+	width, height := requestedWidth, requestedHeight
+	crop, cropped := cropFor(camera.Info.Path)
+	if cropped {
+		rect, err := applyCrop(dev, crop)
+		if err != nil {
+			log.Printf("Camera %s: %v, streaming the full frame", camera.Info.Name, err)
+			cropped = false
+		} else {
+			log.Printf("Camera %s: cropped to %dx%d+%d+%d", camera.Info.Name, rect.Width, rect.Height, rect.Left, rect.Top)
+			width, height = rect.Width, rect.Height
+		}
+	}
+	mode, err := negotiateFormat(dev, camera.Info.Name, width, height, cropped)
+	if err != nil {
+		dev.Close()
+		return nil, nil, explainBusy(camera.Info.Path, err)
+	}
+
+	// Start the camera stream
+	ctx, cancel := context.WithCancel(context.Background())
+	camera.DroppedFrames, camera.LostFrames = 0, 0
+	frames, err := startDeviceStream(ctx, camera, mode)
+	if err != nil {
+		cancel()
+		dev.Close()
+		return nil, nil, explainBusy(camera.Info.Path, err)
+	}
+	stopStream := func() {
+		cancel()
+		for range frames {
+		}
+		dev.Close()
+		camera.StopStream = nil
+	}
+	if !formatSupported(camera.PixFormat.PixelFormat) {
+		stopStream()
+		return nil, nil, fmt.Errorf("unsupported pixel format %s", formatName(camera.PixFormat.PixelFormat))
+	}
+	if isGreyCamera(camera) {
+		camera.Palette = defaultPalette
+	}
+	camera.StopStream = cancel
+	return frames, stopStream, nil
+}
+
 // initRaspberryPiCamera initializes a Raspberry Pi camera using rpicam-vid
 func initRaspberryPiCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
 	// Set default dimensions for RPi camera
@@ -309,11 +375,12 @@ func initRaspberryPiCamera(camera *CameraInstance, renderer *sdl.Renderer) error
 	)
 	if err != nil {
 		camera.Texture.Destroy()
+		camera.Texture = nil
 		return fmt.Errorf("failed to create thumbnail texture: %w", err)
 	}
 
 	camera.Active = true
-	camera.FrameChan = make(chan []byte, 10)
+	camera.FrameChan = make(chan Frame, 10)
 
 	log.Printf("Initialized Raspberry Pi camera: %s (%dx%d)", camera.Info.Name, camera.Width, camera.Height)
 
@@ -327,20 +394,52 @@ func captureFramesForCamera(camera *CameraInstance) {
 		captureRaspberryPiFrames(camera)
 		return
 	}
+	if isFolderSource(camera) {
+		captureFolderFrames(camera)
+		return
+	}
+	if isRemoteSource(camera) {
+		captureRemoteFrames(camera)
+		return
+	}
+	if isReplaySource(camera) {
+		captureReplayFrames(camera)
+		return
+	}
+	if isPlaybackSource(camera) {
+		capturePlaybackFrames(camera)
+		return
+	}
 
 	// Handle regular V4L2 cameras (existing code)
 	for camera.Active {
 		// Read the next frame from the device
-		frame := <-camera.Device.GetOutput()
-		if frame == nil {
+		frame, ok := <-camera.DeviceFrames
+		if !ok {
+			return
+		}
+		if skipForMemory(camera) {
+			atomic.AddUint64(&camera.DroppedFrames, 1)
+			continue
+		}
+
+		captured := time.Now()
+		if skipForRate(camera, captured) {
+			continue
+		}
+		recordReplayFrame(camera, frame, captured)
+		next, err := deviceFrame(camera, frame)
+		if err != nil {
+			log.Printf("Error converting frame from %s: %v", camera.Info.Name, err)
+			notify(toastWarning, tr("toast.frame_error", camera.Info.Name, err))
 			atomic.AddUint64(&camera.DroppedFrames, 1)
-			time.Sleep(16 * time.Millisecond)
 			continue
 		}
+		next.Captured = captured
 
 		// Send the frame to our channel
 		select {
-		case camera.FrameChan <- frame:
+		case camera.FrameChan <- next:
 		default:
 			// Channel buffer full, drop the frame
 			atomic.AddUint64(&camera.DroppedFrames, 1)
@@ -375,7 +474,11 @@ func captureRaspberryPiFrames(camera *CameraInstance) {
 		}
 
 		// Read MJPEG stream from rpicam-vid
-		go readRPiMJPEGStream(stdout, camera.FrameChan, &camera.Active)
+		readerDone := make(chan struct{})
+		go func() {
+			defer close(readerDone)
+			readRPiMJPEGStream(stdout, camera)
+		}()
 
 		// Wait for the command to finish or camera to be deactivated
 		for camera.Active {
@@ -395,6 +498,9 @@ func captureRaspberryPiFrames(camera *CameraInstance) {
 		}
 		cmd.Wait()
 		stdout.Close()
+		// The reader sends to the frame channel, which is closed once this
+		// function returns
+		<-readerDone
 
 		if !camera.Active {
 			break
@@ -406,56 +512,34 @@ func captureRaspberryPiFrames(camera *CameraInstance) {
 }
 
 // readRPiMJPEGStream reads MJPEG frames from rpicam-vid stdout
-func readRPiMJPEGStream(reader io.Reader, frames chan<- []byte, active *bool) {
+func readRPiMJPEGStream(reader io.Reader, camera *CameraInstance) {
 	buffer := make([]byte, 1024*1024) // 1MB buffer
-	frameBuffer := bytes.NewBuffer(nil)
+	var splitter mjpegSplitter
+	defer func() {
+		if splitter.Discarded > 0 {
+			log.Printf("Skipped %d bytes of damaged data from rpicam-vid", splitter.Discarded)
+		}
+	}()
 
-	for *active {
+	for camera.Active {
 		n, err := reader.Read(buffer)
 		if err != nil {
 			if err != io.EOF {
 				log.Printf("Error reading from rpicam-vid: %v", err)
+				notify(toastError, tr("toast.capture_stopped", camera.Info.Name, err))
 			}
 			break
 		}
 
-		frameBuffer.Write(buffer[:n])
-
-		// Look for JPEG frame boundaries
-		data := frameBuffer.Bytes()
-		for {
-			// Find JPEG start marker (0xFF 0xD8)
-			startIdx := bytes.Index(data, []byte{0xFF, 0xD8})
-			if startIdx == -1 {
-				break
-			}
-
-			// Find JPEG end marker (0xFF 0xD9)
-			endIdx := bytes.Index(data[startIdx+2:], []byte{0xFF, 0xD9})
-			if endIdx == -1 {
-				// Incomplete frame, wait for more data
-				break
-			}
-
-			endIdx += startIdx + 2 + 2 // Include the end marker
-
-			// Extract the complete JPEG frame
-			frame := make([]byte, endIdx-startIdx)
-			copy(frame, data[startIdx:endIdx])
-
-			// Send frame to channel
+		splitter.Write(buffer[:n], func(frame []byte) {
+			captured := time.Now()
+			recordReplayFrame(camera, frame, captured)
 			select {
-			case frames <- frame:
+			case camera.FrameChan <- Frame{Data: frame, Captured: captured}:
 			default:
 				// Channel full, drop frame
 			}
-
-			// Remove processed frame from buffer
-			remaining := data[endIdx:]
-			frameBuffer.Reset()
-			frameBuffer.Write(remaining)
-			data = frameBuffer.Bytes()
-		}
+		})
 	}
 }
 
@@ -466,57 +550,247 @@ func updateCameraFrames(appData *CameraAppData) {
 			continue
 		}
 
-		// Try to get a new frame
-		select {
-		case frame, ok := <-camera.FrameChan:
-			if !ok {
-				continue
+		frame, ok := takeFrames(appData, i)
+		if camera.Freeze != nil && !camera.Freeze.advance(time.Now()) {
+			// The slow-motion review is over
+			camera.Freeze = nil
+		}
+		if camera.Freeze != nil {
+			// A paused view shows the frame stepped to instead
+			frame, ok = camera.Freeze.take()
+		}
+		if !ok {
+			// No new frame available, continue
+			continue
+		}
+		if overMemoryBudget() && evictable(appData, i) {
+			// Nothing shows the frame, so it is neither decoded nor kept
+			continue
+		}
+
+		if appData.Hidden || !shownInPane(appData, i) && backgroundPaused() {
+			// Nothing is drawn while the window is hidden, and background
+			// cameras wait while the user interacts; keep the frame for
+			// snapshots without decoding it
+			camera.FrameMutex.Lock()
+			camera.LastFrame = frame.Data
+			camera.LastRaw = frame.Raw
+			camera.LastSensor = frame.Sensor
+			camera.FrameMutex.Unlock()
+			continue
+		}
+
+		frame.DecodeTo = decodeSize(appData, i)
+		if decodeQueue != nil && camera.Freeze == nil {
+			// Show the newest frame the workers decoded, if any
+			if camera.Decoder == nil {
+				camera.Decoder = &frameDecoder{}
 			}
-			// Update textures with new frame
-			err := updateCameraTextures(camera, frame)
+			camera.Decoder.submit(frame, shownInPane(appData, i))
+			decoded, superseded, err := camera.Decoder.take()
+			atomic.AddUint64(&camera.DroppedFrames, superseded)
 			if err != nil {
 				log.Printf("Error updating textures for camera %s: %v", camera.Info.Name, err)
+				notify(toastWarning, tr("toast.frame_error", camera.Info.Name, err))
+				continue
+			}
+			if decoded == nil {
+				continue
 			}
+			frame = *decoded
+		}
+
+		// Update textures with new frame
+		err := updateCameraTextures(camera, frame, i == appData.SelectedCamera)
+		if err != nil {
+			log.Printf("Error updating textures for camera %s: %v", camera.Info.Name, err)
+			notify(toastWarning, tr("toast.frame_error", camera.Info.Name, err))
+		} else if camera.Motion.Detected && camera.Freeze == nil {
+			raiseAlert(appData, alertMotion, tr("alert.motion", camera.Info.Name))
+			api.Motion(i, camera, time.Now())
+		}
+	}
+}
+
+// takeFrames takes the frames a camera queued since the last pass of the
+// main loop, recording and publishing each, and returns the newest of those
+// -display-every lets through to be shown. A UI paced slower than the camera so drops no frame from
+// recordings and streams, and shows the camera without lag.
+func takeFrames(appData *CameraAppData, index int) (Frame, bool) {
+	camera := &appData.Cameras[index]
+	var newest Frame
+	taken := false
+	// A camera delivering while the queue is drained can't hold up the loop
+	for range max(cap(camera.FrameChan), 1) {
+		var frame Frame
+		select {
+		case next, ok := <-camera.FrameChan:
+			if !ok {
+				return newest, taken
+			}
+			frame = next
 		default:
-			// No new frame available, continue
+			return newest, taken
+		}
+		camera.LastFrameAt = time.Now()
+		camera.Offline = false
+		framesReceived.Add(camera.Info.Path, 1)
+		addToHistory(camera, frame)
+		output := frame
+		if camera.Recorder != nil || api.Watched(index) {
+			output = encodeOutput(frame)
+		}
+		api.Publish(index, camera, output)
+		recordFrame(appData, camera, output)
+		if displayFrame(camera) {
+			newest, taken = frame, true
 		}
 	}
+	return newest, taken
+}
+
+// encodeOutput returns a frame as recordings and API streams get it, which
+// may be smaller than the display. Raw sources have no JPEG until something
+// needs one.
+func encodeOutput(frame Frame) Frame {
+	if outputEncoding.Enabled() || frame.Data == nil {
+		return outputEncoding.Encode(frame)
+	}
+	return frame
 }
 
-func updateCameraTextures(camera *CameraInstance, frameData []byte) error {
+// recordFrame appends a frame to the camera's recording, if any, stopping
+// the recording if the write fails
+func recordFrame(appData *CameraAppData, camera *CameraInstance, output Frame) {
+	if camera.Recorder == nil {
+		return
+	}
+	if err := camera.Recorder.WriteFrame(output); err != nil {
+		log.Printf("Error recording camera %s: %v", camera.Info.Name, err)
+		notify(toastError, tr("toast.recording_failed", camera.Info.Name, err))
+		_ = stopRecording(camera)
+		raiseAlert(appData, alertRecording, tr("alert.recording", camera.Info.Name))
+	}
+}
+
+// decodeFrame decodes a frame to RGBA and runs the camera's processing
+// stages on it. It is called with FrameMutex held.
+func decodeFrame(camera *CameraInstance, frame Frame) (*image.RGBA, error) {
+	rgbaImg, err := decodeImage(frame)
+	if err != nil {
+		return nil, err
+	}
+	processFrame(camera, rgbaImg)
+	return rgbaImg, nil
+}
+
+// updateCameraTextures decodes a frame into the camera's textures. The main
+// texture of the selected camera shows the current visualization.
+func updateCameraTextures(camera *CameraInstance, frame Frame, selected bool) error {
 	camera.FrameMutex.Lock()
 	defer camera.FrameMutex.Unlock()
 
-	// Decode the JPEG image
-	img, err := jpeg.Decode(io.NewSectionReader(bytes.NewReader(frameData), 0, int64(len(frameData))))
+	// Keep the encoded frame around for snapshots
+	camera.LastFrame = frame.Data
+	camera.LastRaw = frame.Raw
+	camera.LastSensor = frame.Sensor
+
+	rgbaImg, err := decodeFrame(camera, frame)
 	if err != nil {
-		return fmt.Errorf("failed to decode frame: %w", err)
+		return err
+	}
+	bounds := rgbaImg.Bounds()
+	if bounds.Dx() != camera.Width || bounds.Dy() != camera.Height {
+		if err := resizeCameraTextures(camera, bounds.Dx(), bounds.Dy()); err != nil {
+			return err
+		}
+	}
+	if alerts.Enabled(alertMotion) || api.Listening() {
+		camera.Motion.Update(rgbaImg.Pix, rgbaImg.Stride, bounds.Dx(), bounds.Dy())
+	}
+	if mosaic != nil {
+		mosaic.AddFrame(camera, rgbaImg)
 	}
-
-	// Convert to RGBA
-	bounds := img.Bounds()
-	rgbaImg := image.NewRGBA(bounds)
-	draw.Draw(rgbaImg, bounds, img, bounds.Min, draw.Src)
 
 	// Update main texture
 	if camera.Texture != nil {
-		err = camera.Texture.Update(nil, rgbaImg.Pix, int32(rgbaImg.Stride))
+		mainImg := rgbaImg
+		if selected {
+			analyzeFrame(camera, rgbaImg)
+			follower.Update(rgbaImg)
+			mainImg = visualization.Apply(camera, rgbaImg)
+		}
+		err = camera.Texture.Update(nil, mainImg.Pix, int32(mainImg.Stride))
 		if err != nil {
 			return fmt.Errorf("failed to update main texture: %w", err)
 		}
 	}
+	if camera.PopOut != nil {
+		err = camera.PopOut.Texture.Update(nil, rgbaImg.Pix, int32(rgbaImg.Stride))
+		if err != nil {
+			return fmt.Errorf("failed to update pop-out texture: %w", err)
+		}
+	}
+
+	// The thumbnail is uploaded by updateThumbnails when its turn comes
+	if camera.ThumbnailTexture != nil {
+		camera.ThumbnailPending = rgbaImg
+	}
+
+	return nil
+}
+
+// createCameraTextures creates the main and thumbnail textures of a camera
+// at its frame size
+func createCameraTextures(camera *CameraInstance, renderer *sdl.Renderer) error {
+	var err error
+	camera.Texture, err = renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STATIC,
+		camera.Width, camera.Height)
+	if err != nil {
+		return fmt.Errorf("failed to create main texture: %w", err)
+	}
+	// The thumbnail is the frame scaled down by scaleImage
+	camera.ThumbnailTexture, err = renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STATIC,
+		max(camera.Width/4, 1), max(camera.Height/4, 1))
+	if err != nil {
+		camera.Texture.Destroy()
+		camera.Texture = nil
+		return fmt.Errorf("failed to create thumbnail texture: %w", err)
+	}
+	return nil
+}
 
-	// Create and update thumbnail texture
+// resizeCameraTextures recreates a camera's textures when its frames change
+// size, as the images of a watch folder may. It is called with FrameMutex
+// held.
+func resizeCameraTextures(camera *CameraInstance, width, height int) error {
+	if camera.Texture == nil {
+		camera.Width, camera.Height = width, height
+		return nil
+	}
+	renderer, err := camera.Texture.Renderer()
+	if err != nil {
+		return fmt.Errorf("failed to resize textures: %w", err)
+	}
+	log.Printf("Camera %s frame size changed to %dx%d", camera.Info.Name, width, height)
+	camera.Texture.Destroy()
 	if camera.ThumbnailTexture != nil {
-		// Scale down the image for thumbnail
-		thumbnailImg := scaleImage(rgbaImg, 4) // Scale down by factor of 4
+		camera.ThumbnailTexture.Destroy()
+	}
+	camera.Texture, camera.ThumbnailTexture = nil, nil
+	camera.Width, camera.Height = width, height
+	if err := createCameraTextures(camera, renderer); err != nil {
+		return err
+	}
 
-		err = camera.ThumbnailTexture.Update(nil, thumbnailImg.Pix, int32(thumbnailImg.Stride))
+	if popOut := camera.PopOut; popOut != nil {
+		texture, err := popOut.Renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STATIC, width, height)
 		if err != nil {
-			return fmt.Errorf("failed to update thumbnail texture: %w", err)
+			return fmt.Errorf("failed to create pop-out texture: %w", err)
 		}
+		popOut.Texture.Destroy()
+		popOut.Texture = texture
 	}
-
 	return nil
 }
 
@@ -593,19 +867,128 @@ func loadPlaceholderImage(appData *CameraAppData) error {
 	return nil
 }
 
+// captureStopTimeout bounds how long disabling a camera waits for its
+// capture goroutine to exit
+const captureStopTimeout = 2 * time.Second
+
+// disableCamera stops a camera's stream and closes its device to save CPU,
+// keeping it in the list so it can be enabled again. The textures are kept
+// so the thumbnail can still show the last frame, greyed out.
+func disableCamera(camera *CameraInstance) {
+	if err := stopRecording(camera); err != nil {
+		log.Printf("Error stopping recording: %v", err)
+	}
+	closePopOut(camera)
+	camera.Freeze = nil
+	camera.Disabled = true
+	if !camera.Active {
+		return
+	}
+
+	camera.Active = false
+	if camera.StopStream != nil {
+		camera.StopStream()
+		camera.StopStream = nil
+	}
+
+	// Wait for the capture goroutine to close the frame channel
+	timeout := time.After(captureStopTimeout)
+drain:
+	for {
+		select {
+		case _, ok := <-camera.FrameChan:
+			if !ok {
+				break drain
+			}
+		case <-timeout:
+			log.Printf("Timed out stopping capture for %s", camera.Info.Name)
+			break drain
+		}
+	}
+
+	if camera.Device != nil {
+		camera.Device.Close()
+		camera.Device = nil
+	}
+	camera.SupportedControls = nil
+	camera.Metering = nil
+	camera.Stack = nil
+	camera.Bracket = nil
+}
+
+// enableCamera reopens a disabled camera and restarts its stream
+func enableCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
+	if privacy.Enabled {
+		return trErr("error.privacy", camera.Info.Name)
+	}
+	if camera.Active {
+		camera.Disabled = false
+		return nil
+	}
+
+	// initSingleCamera creates new textures
+	camera.FrameMutex.Lock()
+	if camera.Texture != nil {
+		camera.Texture.Destroy()
+		camera.Texture = nil
+	}
+	if camera.ThumbnailTexture != nil {
+		camera.ThumbnailTexture.Destroy()
+		camera.ThumbnailTexture = nil
+	}
+	camera.LastFrame = nil
+	camera.LastRaw = nil
+	camera.LastSensor = nil
+	camera.FrameMutex.Unlock()
+
+	if err := initSingleCamera(camera, renderer); err != nil {
+		camera.Device = nil
+		return fmt.Errorf("failed to enable %s: %w", camera.Info.Name, err)
+	}
+	camera.Disabled = false
+	startCapture(camera)
+	return nil
+}
+
+// setCameraEnabled enables or disables a camera and reports the result in the
+// status bar
+func setCameraEnabled(appData *CameraAppData, index int, enabled bool) {
+	camera := &appData.Cameras[index]
+	if enabled == !camera.Disabled {
+		return
+	}
+	if !enabled {
+		disableCamera(camera)
+		appData.StatusText = tr("status.disabled", camera.Info.Name)
+		return
+	}
+	if err := enableCamera(camera, appData.Renderer); err != nil {
+		setErrorStatus(appData, err)
+		return
+	}
+	appData.StatusText = tr("status.enabled", camera.Info.Name)
+}
+
+// cleanupCameras shuts the cameras down on exit: capture stops and its
+// queued frames reach the recordings first, then recordings are closed and
+// only then are devices closed and textures destroyed
 func cleanupCameras(appData *CameraAppData) {
+	deadline := time.Now().Add(shutdownTimeout)
+	stopCaptures(appData, deadline)
+	// Captures have ended, so the replay holds every frame they delivered
+	stopReplayRecording()
+
 	for i := range appData.Cameras {
 		camera := &appData.Cameras[i]
-
-		// Stop camera activity
-		camera.Active = false
-
-		// Give time for goroutines to finish
-		time.Sleep(100 * time.Millisecond)
+		closePopOut(camera)
+		if err := stopRecording(camera); err != nil {
+			log.Printf("Error stopping recording: %v", err)
+		}
 
 		// Close device
 		if camera.Device != nil {
 			camera.Device.Close()
+			camera.Device = nil
 		}
 
 		// Destroy textures
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// startFakeCamera opens a camera on dev and runs its capture goroutine like
+// initSingleCamera and initAllCameras do, minus the textures
+func startFakeCamera(t *testing.T, dev *fakeDevice) *CameraInstance {
+	t.Helper()
+	useFakeDevice(t, dev)
+	camera := &CameraInstance{Info: CameraInfo{Path: "/dev/video-fake", Name: "Fake"}}
+	frames, _, err := openDeviceStream(camera)
+	if err != nil {
+		t.Fatalf("openDeviceStream: %v", err)
+	}
+	camera.DeviceFrames = frames
+	camera.Active = true
+	camera.FrameChan = make(chan Frame, 10)
+	startCapture(camera)
+	t.Cleanup(func() {
+		// Capture ends when the stream's channel is closed
+		if camera.StopStream != nil {
+			camera.StopStream()
+		}
+		for range camera.FrameChan {
+		}
+		captures.Wait()
+	})
+	return camera
+}
+
+// receiveFrames takes n frames from a camera or fails the test
+func receiveFrames(t *testing.T, camera *CameraInstance, n int) []Frame {
+	t.Helper()
+	var frames []Frame
+	timeout := time.After(5 * time.Second)
+	for len(frames) < n {
+		select {
+		case frame := <-camera.FrameChan:
+			frames = append(frames, frame)
+		case <-timeout:
+			t.Fatalf("received %d of %d frames", len(frames), n)
+		}
+	}
+	return frames
+}
+
+func TestNegotiatePicksRequestedMode(t *testing.T) {
+	dev := newFakeDevice(0)
+	for _, test := range []struct {
+		width, height uint32
+		wantW, wantH  uint32
+	}{
+		{640, 480, 640, 480},
+		{320, 240, 320, 240},
+		{1920, 1080, 640, 480},
+		{300, 200, 320, 240},
+	} {
+		mode, err := negotiateFormat(dev, "Fake", test.width, test.height, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode.PixelFormat != v4l2.PixelFmtMJPEG || mode.Width != test.wantW || mode.Height != test.wantH {
+			t.Errorf("requested %dx%d: got %s %dx%d, want MJPG %dx%d", test.width, test.height,
+				formatName(mode.PixelFormat), mode.Width, mode.Height, test.wantW, test.wantH)
+		}
+	}
+}
+
+func TestNegotiateFollowsPreference(t *testing.T) {
+	dev := newFakeDevice(0)
+	delete(dev.Sizes, v4l2.PixelFmtMJPEG)
+	mode, err := negotiateFormat(dev, "Fake", 640, 480, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode.PixelFormat != v4l2.PixelFmtYUYV {
+		t.Errorf("got %s, want YUYV", formatName(mode.PixelFormat))
+	}
+
+	dev.Sizes = map[v4l2.FourCCType][][2]uint32{fourcc("H264"): {{640, 480}}}
+	if _, err := negotiateFormat(dev, "Fake", 640, 480, false); err == nil {
+		t.Error("negotiated a format the app cannot decode")
+	}
+}
+
+func TestStreamFallsBackWhenRefused(t *testing.T) {
+	dev := newFakeDevice(1)
+	dev.Refuse = func(format v4l2.PixFormat, fps uint32) bool {
+		return format.Width > 320 || fps > 15
+	}
+	camera := startFakeCamera(t, dev)
+	if camera.PixFormat.Width != 320 || camera.PixFormat.Height != 240 {
+		t.Errorf("streaming at %dx%d, want 320x240", camera.PixFormat.Width, camera.PixFormat.Height)
+	}
+	if !strings.Contains(camera.Mode, "15 fps") {
+		t.Errorf("mode %q, want 15 fps", camera.Mode)
+	}
+	receiveFrames(t, camera, 1)
+}
+
+func TestStreamFailsWhenAllModesRefused(t *testing.T) {
+	dev := newFakeDevice(1)
+	dev.Refuse = func(v4l2.PixFormat, uint32) bool { return true }
+	useFakeDevice(t, dev)
+	camera := &CameraInstance{Info: CameraInfo{Path: "/dev/video-fake", Name: "Fake"}}
+	if _, _, err := openDeviceStream(camera); err == nil {
+		t.Fatal("started a stream the device refused")
+	}
+	if !dev.closed {
+		t.Error("device left open")
+	}
+}
+
+func TestCaptureDropsFramesWhenBehind(t *testing.T) {
+	const frames = 25
+	camera := startFakeCamera(t, newFakeDevice(frames))
+
+	// Nothing reads the frame channel, so once it is full, frames are dropped
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadUint64(&camera.DroppedFrames) < frames-uint64(cap(camera.FrameChan)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("dropped %d frames, want %d", atomic.LoadUint64(&camera.DroppedFrames), frames-cap(camera.FrameChan))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	got := receiveFrames(t, camera, cap(camera.FrameChan))
+	// The oldest frames are kept
+	if want := fakeFrame(camera.PixFormat, 0); string(got[0].Data) != string(want) {
+		t.Error("first frame is not the first frame streamed")
+	}
+	if dropped := atomic.LoadUint64(&camera.DroppedFrames); dropped != frames-uint64(cap(camera.FrameChan)) {
+		t.Errorf("dropped %d frames, want %d", dropped, frames-cap(camera.FrameChan))
+	}
+}
+
+func TestCaptureConvertsYUYV(t *testing.T) {
+	dev := newFakeDevice(3)
+	delete(dev.Sizes, v4l2.PixelFmtMJPEG)
+	camera := startFakeCamera(t, dev)
+	for _, frame := range receiveFrames(t, camera, 3) {
+		if frame.Raw == nil || frame.Raw.Bounds().Dx() != 640 || frame.Raw.Bounds().Dy() != 480 {
+			t.Fatalf("YUYV frame not converted to a 640x480 image")
+		}
+		if frame.Captured.IsZero() {
+			t.Error("frame without capture time")
+		}
+	}
+}
+
+func TestRecordFakeStream(t *testing.T) {
+	t.Chdir(t.TempDir())
+	const frames = 8
+	camera := startFakeCamera(t, newFakeDevice(frames))
+	if err := startRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	path := camera.Recorder.Path
+	size := 0
+	for _, frame := range receiveFrames(t, camera, frames) {
+		recordFrame(&CameraAppData{}, camera, encodeOutput(frame))
+		size += len(frame.Data)
+	}
+	if err := stopRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != size {
+		t.Errorf("recording has %d bytes, want %d", len(data), size)
+	}
+	index, err := os.ReadFile(recordingIndexPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(strings.TrimPrefix(string(index), recordingIndexHeader), "\n"); lines != frames {
+		t.Errorf("index has %d frames, want %d", lines, frames)
+	}
+	if _, err := os.Stat(path + recordingMarkerSuffix); !os.IsNotExist(err) {
+		t.Error("marker left after a clean stop")
+	}
+
+	// A recording cut off mid-frame recovers to the frames before
+	if err := os.Truncate(path, int64(size-10)); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := recoverRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != frames-1 {
+		t.Errorf("recovered %d frames, want %d", recovered, frames-1)
+	}
+}
+
+// TestRecoverSkipsOpenRecordings plays a second instance starting while
+// the first is still recording
+func TestRecoverSkipsOpenRecordings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	const frames = 4
+	camera := startFakeCamera(t, newFakeDevice(frames))
+	if err := startRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	path := camera.Recorder.Path
+	for _, frame := range receiveFrames(t, camera, frames) {
+		recordFrame(&CameraAppData{}, camera, encodeOutput(frame))
+	}
+	// Half a frame more, as if it were being written
+	if _, err := camera.Recorder.file.Write([]byte{0xff, 0xd8, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(path)
+
+	recoverRecordings(recordingDir)
+	if after, _ := os.Stat(path); after.Size() != before.Size() {
+		t.Errorf("open recording trimmed from %d to %d bytes", before.Size(), after.Size())
+	}
+	if owner, err := os.ReadFile(path + recordingMarkerSuffix); err != nil || string(owner) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("marker %q: %v", owner, err)
+	}
+
+	// The instance dies without stopping: the lock goes with it
+	camera.Recorder.marker.Close()
+	recoverRecordings(recordingDir)
+	if after, _ := os.Stat(path); after.Size() != before.Size()-3 {
+		t.Errorf("recovered to %d bytes, want %d", after.Size(), before.Size()-3)
+	}
+	if _, err := os.Stat(path + recordingMarkerSuffix); !os.IsNotExist(err) {
+		t.Error("marker left after recovery")
+	}
+	camera.Recorder.marker = nil
+	_ = stopRecording(camera)
+}
+
+func TestCaptureControls(t *testing.T) {
+	dev := newFakeDevice(0)
+	camera := &CameraInstance{Info: CameraInfo{Name: "Fake"}, Device: dev}
+	if err := dev.SetControlValue(v4l2.CtrlBrightness, 200); err != nil {
+		t.Fatal(err)
+	}
+	saved := captureControls(camera)
+	if len(saved) != 1 || saved[0].ID != v4l2.CtrlBrightness || saved[0].Value != 200 {
+		t.Errorf("captured %+v, want brightness 200", saved)
+	}
+}
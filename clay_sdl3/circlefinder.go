@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// circleRays is how many rays are cast from the crosshair to find the
+	// circle's edge
+	circleRays = 90
+	// circleSearch is how far the rays reach, as a fraction of the smaller
+	// frame side
+	circleSearch = 0.45
+	// circleMinRadius skips the first pixels of each ray, where the
+	// crosshair's own neighbourhood would give false edges
+	circleMinRadius = 4
+	// circleEdgeMin is the smallest luma step accepted as an edge
+	circleEdgeMin = 30
+	// circleMinEdges is how many rays must find an edge for a fit
+	circleMinEdges = circleRays / 2
+)
+
+// CircleFit is a circle fitted to the edge around the crosshair, in frame
+// pixels
+type CircleFit struct {
+	Found  bool
+	X, Y   float32
+	Radius float32
+	// DX and DY are the offset of the center from the frame center
+	DX, DY float32
+}
+
+// CircleFinder finds a hole or boss under the crosshair: the classic camera
+// edge finder for locating a part on a CNC machine. Rays are cast outwards
+// from the frame center to the strongest edge, and a circle is fitted
+// through the edge points. The crosshair must be inside the feature.
+type CircleFinder struct {
+	Enabled bool
+	Result  CircleFit
+
+	points []sdl.FPoint
+}
+
+var circleFinder CircleFinder
+
+// Update fits the circle in a frame's luma
+func (f *CircleFinder) Update(luma []uint8, width, height int) {
+	f.Result = CircleFit{}
+	cx, cy := float32(width)/2, float32(height)/2
+	reach := int(circleSearch * float32(min(width, height)))
+
+	f.points = f.points[:0]
+	for i := range circleRays {
+		angle := 2 * math.Pi * float64(i) / circleRays
+		dx, dy := float32(math.Cos(angle)), float32(math.Sin(angle))
+		sample := func(r int) int {
+			x := int(cx + dx*float32(r))
+			y := int(cy + dy*float32(r))
+			if x < 0 || y < 0 || x >= width || y >= height {
+				return -1
+			}
+			return int(luma[y*width+x])
+		}
+
+		// Strongest step between pixels two apart along the ray
+		best, bestAt := 0, 0
+		for r := circleMinRadius; r+2 <= reach; r++ {
+			a, b := sample(r), sample(r+2)
+			if a < 0 || b < 0 {
+				break
+			}
+			if step := abs(b - a); step > best {
+				best, bestAt = step, r+1
+			}
+		}
+		if best >= circleEdgeMin {
+			f.points = append(f.points, sdl.FPoint{X: cx + dx*float32(bestAt), Y: cy + dy*float32(bestAt)})
+		}
+	}
+	if len(f.points) < circleMinEdges {
+		return
+	}
+
+	x, y, r, ok := fitCircle(f.points)
+	if !ok {
+		return
+	}
+	// Drop edge points far off the first fit, such as rays that hit a
+	// scratch or chip instead of the edge, and fit again
+	residuals := make([]float32, len(f.points))
+	for i, p := range f.points {
+		residuals[i] = abs32(float32(math.Hypot(float64(p.X-x), float64(p.Y-y))) - r)
+	}
+	sorted := append([]float32(nil), residuals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	limit := max(3*sorted[len(sorted)/2], 1.5)
+	kept := f.points[:0]
+	for i, p := range f.points {
+		if residuals[i] <= limit {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) < circleMinEdges {
+		return
+	}
+	if x, y, r, ok = fitCircle(kept); !ok {
+		return
+	}
+
+	f.Result = CircleFit{Found: true, X: x, Y: y, Radius: r, DX: x - cx, DY: y - cy}
+}
+
+// fitCircle returns the algebraic least-squares circle through the points,
+// solving x² + y² + Dx + Ey + F = 0 for D, E and F
+func fitCircle(points []sdl.FPoint) (x, y, r float32, ok bool) {
+	// Normal equations of the linear system, accumulated around the mean
+	// to keep them well conditioned
+	var mx, my float64
+	for _, p := range points {
+		mx += float64(p.X)
+		my += float64(p.Y)
+	}
+	n := float64(len(points))
+	mx /= n
+	my /= n
+
+	var suu, suv, svv, suuu, svvv, suvv, svuu float64
+	for _, p := range points {
+		u, v := float64(p.X)-mx, float64(p.Y)-my
+		suu += u * u
+		suv += u * v
+		svv += v * v
+		suuu += u * u * u
+		svvv += v * v * v
+		suvv += u * v * v
+		svuu += v * u * u
+	}
+	det := suu*svv - suv*suv
+	if det == 0 {
+		return 0, 0, 0, false
+	}
+	// Center in the shifted coordinates
+	bu := (suuu + suvv) / 2
+	bv := (svvv + svuu) / 2
+	uc := (bu*svv - bv*suv) / det
+	vc := (bv*suu - bu*suv) / det
+	radius := math.Sqrt(uc*uc + vc*vc + (suu+svv)/n)
+	return float32(uc + mx), float32(vc + my), float32(radius), true
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// renderCircleFit outlines the fitted circle and marks its center
+func renderCircleFit(appData *CameraAppData, camera *CameraInstance, rect sdl.FRect) {
+	fit := circleFinder.Result
+	if !circleFinder.Enabled || !fit.Found || camera.Width <= 0 || camera.Height <= 0 {
+		return
+	}
+	w, h := float32(camera.Width), float32(camera.Height)
+	points := make([]sdl.FPoint, 0, 65)
+	for i := range 65 {
+		angle := 2 * math.Pi * float64(i) / 64
+		x := fit.X + fit.Radius*float32(math.Cos(angle))
+		y := fit.Y + fit.Radius*float32(math.Sin(angle))
+		vx, vy := frameToView(rect, x/w, y/h)
+		points = append(points, sdl.FPoint{X: vx, Y: vy})
+	}
+	renderer := appData.Renderer
+	_ = renderer.SetDrawColor(0, 255, 0, 255)
+	_ = renderer.RenderLines(points)
+
+	cx, cy := frameToView(rect, fit.X/w, fit.Y/h)
+	arm := dp(6)
+	_ = renderer.RenderLine(cx-arm, cy, cx+arm, cy)
+	_ = renderer.RenderLine(cx, cy-arm, cx, cy+arm)
+}
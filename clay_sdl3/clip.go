@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// A recording played back with -play can be cut into a clip. A timeline
+// under the view shows where playback is and the range selected; Space
+// pauses, Shift+Left and Shift+Right step a frame, clicking the timeline
+// seeks, [ and ] set the first and last frame of the clip at the one shown,
+// and E exports it next to the recordings. The export copies the frames'
+// JPEGs unchanged with their capture times and readings, so clips are
+// frame accurate and lose nothing. Shift+E transcodes them instead with the
+// output settings (-output-quality, -output-size and the watermark),
+// burning the capture time and the readings into every frame.
+
+// clipPartSuffix marks the files of a clip being exported
+const clipPartSuffix = ".part"
+
+// selectedPlayback returns the selected camera if it plays back a recording
+func selectedPlayback(appData *CameraAppData) (*CameraInstance, bool) {
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return nil, false
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	return camera, camera.PlaybackControl != nil && camera.Active
+}
+
+// handleClipKey handles the playback keys while a recording is selected,
+// reporting whether the key was one of them
+func handleClipKey(appData *CameraAppData, scancode sdl.Scancode) bool {
+	camera, ok := selectedPlayback(appData)
+	if !ok {
+		return false
+	}
+	control := camera.PlaybackControl
+	shown, paused := control.State()
+	switch {
+	case scancode == sdl.SCANCODE_SPACE:
+		control.Pause(!paused)
+	case scancode == sdl.SCANCODE_LEFT && shiftHeld(appData):
+		control.Pause(true)
+		control.Seek(max(shown-1, 0))
+	case scancode == sdl.SCANCODE_RIGHT && shiftHeld(appData):
+		control.Pause(true)
+		control.Seek(min(shown+1, len(camera.PlaybackFrames)-1))
+	case scancode == sdl.SCANCODE_LEFTBRACKET:
+		setClipPoint(control, true)
+		appData.StatusText = tr("status.clip_in", shown+1)
+	case scancode == sdl.SCANCODE_RIGHTBRACKET:
+		setClipPoint(control, false)
+		appData.StatusText = tr("status.clip_out", shown+1)
+	case scancode == sdl.SCANCODE_E:
+		startClipExport(camera, shiftHeld(appData))
+	default:
+		return false
+	}
+	return true
+}
+
+// setClipPoint sets the first or the last frame of the clip at the frame
+// shown, moving the other end along if the range would turn inside out
+func setClipPoint(control *playbackControl, in bool) {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	if in {
+		control.In = control.shown
+		control.Out = max(control.Out, control.In)
+	} else {
+		control.Out = control.shown
+		control.In = min(control.In, control.Out)
+	}
+}
+
+// clipPath returns where the frames in to out of a recording are exported
+func clipPath(src string, in, out int) string {
+	ext := filepath.Ext(src)
+	name := fmt.Sprintf("%s_clip%d-%d%s", strings.TrimSuffix(filepath.Base(src), ext), in+1, out+1, ext)
+	return filepath.Join(recordingDir, name)
+}
+
+// startClipExport exports the selected range of a playback camera in the
+// background, reporting the result as a toast
+func startClipExport(camera *CameraInstance, transcode bool) {
+	src := strings.TrimPrefix(camera.Info.Path, playbackPrefix)
+	in, out := camera.PlaybackControl.Clip()
+	dst := clipPath(src, in, out)
+	notify(toastInfo, tr("toast.clip_exporting", out-in+1, filepath.Base(dst)))
+	go func() {
+		n, err := exportClip(src, dst, in, out, transcode)
+		if err != nil {
+			log.Printf("Exporting a clip of %s: %v", src, err)
+			notify(toastError, tr("toast.clip_failed", err))
+			return
+		}
+		log.Printf("Exported %d frames of %s to %s", n, src, dst)
+		notify(toastInfo, tr("toast.clip_exported", n, dst))
+		queueUpload(dst, recordingIndexPath(dst), recordingMetadataPath(dst))
+	}()
+}
+
+// exportClip writes the frames in to out of a recording, with their index
+// and readings, to dst, copying them unless transcode is set. The files
+// appear when they are complete.
+func exportClip(src, dst string, in, out int, transcode bool) (int, error) {
+	frames, err := readRecordingIndex(src)
+	if err != nil {
+		return 0, err
+	}
+	if in < 0 || out < in || out >= len(frames) {
+		return 0, fmt.Errorf("invalid range %d-%d of %d frames", in+1, out+1, len(frames))
+	}
+	records, err := readMetadataRecords(recordingMetadataPath(src))
+	if err != nil {
+		return 0, err
+	}
+	file, err := openInputFile(src)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+
+	paths := []string{dst, recordingIndexPath(dst)}
+	video, err := createOutputFile(dst + clipPartSuffix)
+	if err != nil {
+		return 0, err
+	}
+	index, err := os.Create(recordingIndexPath(dst) + clipPartSuffix)
+	if err != nil {
+		video.Close()
+		os.Remove(dst + clipPartSuffix)
+		return 0, err
+	}
+	fail := func(err error) (int, error) {
+		video.Close()
+		index.Close()
+		for _, path := range append(paths, recordingMetadataPath(dst)) {
+			os.Remove(path + clipPartSuffix)
+		}
+		return 0, err
+	}
+
+	buf := bufio.NewWriter(index)
+	buf.WriteString(recordingIndexHeader)
+	var offset int64
+	for i, recorded := range frames[in : out+1] {
+		data := make([]byte, recorded.size)
+		if _, err := file.ReadAt(data, recorded.offset); err != nil && !errors.Is(err, io.EOF) {
+			return fail(fmt.Errorf("failed to read frame %d of %s: %w", in+i+1, src, err))
+		}
+		if transcode {
+			if data, err = transcodeClipFrame(data, recorded.captured, readingsAt(records, in+i)); err != nil {
+				return fail(fmt.Errorf("failed to transcode frame %d of %s: %w", in+i+1, src, err))
+			}
+		}
+		if _, err := video.Write(data); err != nil {
+			return fail(err)
+		}
+		fmt.Fprintf(buf, "%d,%d,%d,%d,%s\n", i, offset, len(data),
+			recorded.captured.UnixNano(), recorded.captured.Format(time.RFC3339Nano))
+		offset += int64(len(data))
+	}
+	if err := buf.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := video.Close(); err != nil {
+		index.Close()
+		return fail(err)
+	}
+	if err := index.Close(); err != nil {
+		return fail(err)
+	}
+	if records != nil {
+		if err := writeClipMetadata(recordingMetadataPath(dst)+clipPartSuffix, records, in, out); err != nil {
+			return fail(err)
+		}
+		paths = append(paths, recordingMetadataPath(dst))
+	}
+	for _, path := range paths {
+		if err := os.Rename(path+clipPartSuffix, path); err != nil {
+			return fail(err)
+		}
+	}
+	return out - in + 1, nil
+}
+
+// readMetadataRecords reads the readings logged with a recording, none if
+// it has no log
+func readMetadataRecords(path string) ([]metadataRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []metadataRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record metadataRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Frame == nil {
+			return nil, fmt.Errorf("%s: invalid record %q", path, scanner.Text())
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// readingsAt returns the readings logged last at or before a frame
+func readingsAt(records []metadataRecord, frame int) map[string]string {
+	var readings map[string]string
+	for _, record := range records {
+		if *record.Frame > frame {
+			break
+		}
+		readings = record.Metadata
+	}
+	return readings
+}
+
+// writeClipMetadata writes the readings of the frames in to out, numbered
+// from the clip's start, which gets the readings logged last before it
+func writeClipMetadata(path string, records []metadataRecord, in, out int) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i, record := range records {
+		frame := *record.Frame
+		started := i+1 < len(records) && *records[i+1].Frame <= in
+		if frame > out || started {
+			continue
+		}
+		frame = max(frame-in, 0)
+		record.Frame = &frame
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// transcodeClipFrame re-encodes a frame with the output settings, the time
+// it was captured and the readings then burned into its bottom-left corner
+func transcodeClipFrame(data []byte, captured time.Time, readings map[string]string) ([]byte, error) {
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(decoded.Bounds())
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	lines := []string{captured.Format("2006-01-02 15:04:05.000")}
+	for key, value := range readings {
+		lines = append(lines, key+": "+value)
+	}
+	slices.Sort(lines[1:])
+	bounds := img.Bounds()
+	face := newTextFace(max(bounds.Dy()/32, 8))
+	defer face.Close()
+	height := face.Metrics().Height.Ceil()
+	margin := watermarkMargin(bounds.Size())
+	y := bounds.Max.Y - margin - face.Metrics().Descent.Ceil() - height*(len(lines)-1)
+	for _, line := range lines {
+		drawShadowedText(img, face, bounds.Min.X+margin, y, line)
+		y += height
+	}
+
+	encoded := outputEncoding.Encode(Frame{Raw: img, Captured: captured})
+	if encoded.Data == nil {
+		return nil, errors.New("failed to encode the frame")
+	}
+	return encoded.Data, nil
+}
+
+// formatClipTime formats a time into a recording as minutes and seconds
+func formatClipTime(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	return fmt.Sprintf("%02d:%06.3f", minutes, (d - time.Duration(minutes)*time.Minute).Seconds())
+}
+
+// createClipLayout declares the timeline of a selected playback camera at
+// the bottom of the main pane
+func createClipLayout(data *CameraAppData, pane clay.ElementId) {
+	camera, ok := selectedPlayback(data)
+	if !ok {
+		return
+	}
+	paneData := clay.GetElementData(pane)
+	if !paneData.Found {
+		return
+	}
+	frames := camera.PlaybackFrames
+	shown, paused := camera.PlaybackControl.State()
+	in, out := camera.PlaybackControl.Clip()
+	width := paneData.BoundingBox.Width * 0.9
+	n := float32(len(frames))
+	state := tr("clip.playing")
+	if paused {
+		state = tr("clip.paused")
+	}
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("ClipPanel"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Padding:         clay.PaddingAll(dpu(6)),
+			ChildGap:        dpu(4),
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:   clay.Vector2{Y: -dp(10)},
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_PARENT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_BOTTOM,
+				Parent:  clay.ATTACH_POINT_CENTER_BOTTOM,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(4)),
+	}, func() {
+		safeText("clip-position", tr("clip.position", state, shown+1, len(frames), formatClipTime(frames[shown].at),
+			in+1, out+1, formatClipTime(frames[out].at-frames[in].at)), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  12,
+			TextColor: theme.Text,
+		})
+		clay.UI()(clay.ElementDeclaration{
+			Id: SafeID("ClipTimeline"),
+			Layout: clay.LayoutConfig{
+				Sizing: clay.Sizing{
+					Width:  clay.SizingFixed(width),
+					Height: clay.SizingFixed(dp(10)),
+				},
+			},
+			BackgroundColor: theme.Item,
+			CornerRadius:    clay.CornerRadiusAll(dp(2)),
+		}, func() {
+			clay.UI()(clay.ElementDeclaration{
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{Width: clay.SizingFixed(width * float32(in) / n)},
+				},
+			}, func() {})
+			clay.UI()(clay.ElementDeclaration{
+				Id: SafeID("ClipRange"),
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{
+						Width:  clay.SizingFixed(width * float32(out-in+1) / n),
+						Height: clay.SizingGrow(0),
+					},
+				},
+				BackgroundColor: theme.Accent,
+			}, func() {})
+			clay.UI()(clay.ElementDeclaration{
+				Id: SafeID("ClipPlayhead"),
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{
+						Width:  clay.SizingFixed(dp(2)),
+						Height: clay.SizingFixed(dp(14)),
+					},
+				},
+				Floating: clay.FloatingElementConfig{
+					Offset:   clay.Vector2{X: width * (float32(shown) + 0.5) / n, Y: -dp(2)},
+					ZIndex:   overlayZIndex + 1,
+					AttachTo: clay.ATTACH_TO_PARENT,
+					AttachPoints: clay.FloatingAttachPoints{
+						Element: clay.ATTACH_POINT_CENTER_TOP,
+						Parent:  clay.ATTACH_POINT_LEFT_TOP,
+					},
+				},
+				BackgroundColor: theme.Text,
+			}, func() {})
+		})
+		safeText("clip-keys", tr("clip.keys"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  10,
+			TextColor: theme.TextDim,
+		})
+	})
+}
+
+// handleClipTimelineClick seeks to the frame under a click on the timeline
+func handleClipTimelineClick(appData *CameraAppData, x, y float32) bool {
+	camera, ok := selectedPlayback(appData)
+	if !ok {
+		return false
+	}
+	timeline := clay.GetElementData(SafeID("ClipTimeline"))
+	bbox := timeline.BoundingBox
+	// The timeline is thin, so clicks a little above or below it count
+	slack := dp(4)
+	if !timeline.Found || x < bbox.X || x > bbox.X+bbox.Width || y < bbox.Y-slack || y > bbox.Y+bbox.Height+slack {
+		return false
+	}
+	n := len(camera.PlaybackFrames)
+	camera.PlaybackControl.Seek(min(max(int((x-bbox.X)/bbox.Width*float32(n)), 0), n-1))
+	return true
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"image/jpeg"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestRecording records n test frames 40ms apart, logging a reading at
+// the frames in readings
+func writeTestRecording(t *testing.T, n int, readings map[int]string) (string, [][]byte) {
+	t.Helper()
+	saved := metadata
+	metadata = &Metadata{fields: make(map[string]string), Enabled: true}
+	t.Cleanup(func() { metadata = saved })
+	camera := &CameraInstance{Info: CameraInfo{Name: "bench"}}
+	if err := startRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	path := camera.Recorder.Path
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var written [][]byte
+	for i := range n {
+		if value, ok := readings[i]; ok {
+			metadata.Set("temp", value)
+		}
+		data := testJPEG(t, i)
+		if err := camera.Recorder.WriteFrame(Frame{Data: data, Captured: start.Add(time.Duration(i) * 40 * time.Millisecond)}); err != nil {
+			t.Fatal(err)
+		}
+		written = append(written, data)
+	}
+	if err := stopRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	return path, written
+}
+
+func TestExportClip(t *testing.T) {
+	t.Chdir(t.TempDir())
+	src, written := writeTestRecording(t, 10, map[int]string{0: "20", 4: "21", 7: "22"})
+	dst := clipPath(src, 5, 8)
+	if n, err := exportClip(src, dst, 5, 8, false); n != 4 || err != nil {
+		t.Fatalf("exported %d frames, %v", n, err)
+	}
+
+	source, _ := readRecordingIndex(src)
+	frames, err := readRecordingIndex(dst)
+	if err != nil || len(frames) != 4 {
+		t.Fatalf("clip of %d frames, %v", len(frames), err)
+	}
+	data, _ := os.ReadFile(dst)
+	for i, frame := range frames {
+		if !bytes.Equal(data[frame.offset:frame.offset+int64(frame.size)], written[5+i]) {
+			t.Errorf("frame %d is not a copy", i)
+		}
+		if !frame.captured.Equal(source[5+i].captured) {
+			t.Errorf("frame %d captured at %v, expected %v", i, frame.captured, source[5+i].captured)
+		}
+	}
+
+	// The clip starts with the readings in effect at its first frame
+	records, err := readMetadataRecords(recordingMetadataPath(dst))
+	if err != nil || len(records) != 2 {
+		t.Fatalf("clip readings %+v, %v", records, err)
+	}
+	if *records[0].Frame != 0 || records[0].Metadata["temp"] != "21" || *records[1].Frame != 2 || records[1].Metadata["temp"] != "22" {
+		t.Errorf("clip readings %+v", records)
+	}
+	if leftovers, _ := os.ReadDir(recordingDir); len(leftovers) != 6 {
+		t.Errorf("%d files after the export", len(leftovers))
+	}
+
+	for _, r := range [][2]int{{-1, 3}, {4, 3}, {8, 10}} {
+		if _, err := exportClip(src, clipPath(src, r[0], r[1]), r[0], r[1], false); err == nil {
+			t.Errorf("range %v was exported", r)
+		}
+	}
+}
+
+func TestExportClipTranscoded(t *testing.T) {
+	t.Chdir(t.TempDir())
+	src, written := writeTestRecording(t, 4, map[int]string{0: "20"})
+	dst := clipPath(src, 1, 2)
+	if n, err := exportClip(src, dst, 1, 2, true); n != 2 || err != nil {
+		t.Fatalf("exported %d frames, %v", n, err)
+	}
+	frames, _ := readRecordingIndex(dst)
+	data, _ := os.ReadFile(dst)
+	for i, frame := range frames {
+		frameData := data[frame.offset : frame.offset+int64(frame.size)]
+		if bytes.Equal(frameData, written[1+i]) {
+			t.Errorf("frame %d was copied", i)
+		}
+		if img, err := jpeg.Decode(bytes.NewReader(frameData)); err != nil || img.Bounds().Dx() != 32 {
+			t.Errorf("frame %d decoded %v", i, err)
+		}
+	}
+}
+
+func TestClipPath(t *testing.T) {
+	if got := clipPath("/media/bench_20250101_120000.000.mjpeg", 0, 99); !strings.HasSuffix(got, "recordings/bench_20250101_120000.000_clip1-100.mjpeg") {
+		t.Errorf("clip path %s", got)
+	}
+}
+
+func TestSetClipPoint(t *testing.T) {
+	control := newPlaybackControl(100)
+	control.shown = 60
+	setClipPoint(control, false)
+	control.shown = 80
+	setClipPoint(control, true)
+	if in, out := control.Clip(); in != 80 || out != 80 {
+		t.Errorf("clip %d-%d, expected the end moved to the start", in, out)
+	}
+	control.shown = 20
+	setClipPoint(control, true)
+	if in, out := control.Clip(); in != 20 || out != 80 {
+		t.Errorf("clip %d-%d", in, out)
+	}
+}
+
+func TestPlaybackSeek(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path, written := writeTestRecording(t, 8, nil)
+	frames, err := readRecordingIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := openInputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	camera := &CameraInstance{
+		Info:            CameraInfo{Name: "bench", Path: playbackPrefix + path},
+		Playback:        file,
+		PlaybackFrames:  frames,
+		PlaybackControl: newPlaybackControl(len(frames)),
+		Active:          true,
+		FrameChan:       make(chan Frame, 10),
+	}
+	camera.PlaybackControl.Pause(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		capturePlaybackFrames(camera)
+	}()
+	t.Cleanup(func() {
+		camera.Active = false
+		<-done
+	})
+
+	// A paused playback shows the frames sought and nothing else
+	for _, frame := range []int{3, 6, 2} {
+		camera.PlaybackControl.Seek(frame)
+		select {
+		case got := <-camera.FrameChan:
+			if !bytes.Equal(got.Data, written[frame]) {
+				t.Errorf("sought frame %d, got another", frame)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d did not arrive", frame)
+		}
+	}
+	select {
+	case <-camera.FrameChan:
+		t.Error("a paused playback went on")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if shown, paused := camera.PlaybackControl.State(); shown != 2 || !paused {
+		t.Errorf("shows frame %d, paused %v", shown, paused)
+	}
+
+	// Resumed, it plays on from there
+	camera.PlaybackControl.Pause(false)
+	select {
+	case got := <-camera.FrameChan:
+		if !bytes.Equal(got.Data, written[3]) {
+			t.Error("resumed at another frame")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("playback did not resume")
+	}
+}
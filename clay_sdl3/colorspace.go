@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// YUV frames come in a color space: the matrix relating Y'CbCr to R'G'B',
+// BT.601 for standard definition and BT.709 for HD, and the range of the
+// samples, full (0-255) or limited (16-235 for luma, 16-240 for chroma).
+// JPEG frames are full-range BT.601 by the JFIF standard, which is what the
+// rest of the pipeline converts from and image/jpeg encodes to, so the
+// capture goroutine turns YUYV and NV12 frames of any other color space into
+// full-range BT.601 as soon as they are unpacked. The display, snapshots,
+// recordings and streams then show the same colors other tools do.
+//
+// The color space is the one the driver reports, by V4L2's rules for its
+// defaults: BT.709 for the Rec. 709 and DCI-P3 color spaces and BT.601
+// otherwise, limited range unless the color space is JPEG. -yuv-matrix and
+// -yuv-range override it for cameras that report it wrong. H.264 recordings
+// are converted to -output-colorspace in limited range and tagged with it.
+
+// colorMatrix is a Y'CbCr matrix, by the luma weights of red and blue
+type colorMatrix struct {
+	Name   string
+	Kr, Kb float64
+}
+
+var (
+	bt601 = colorMatrix{Name: "bt601", Kr: 0.299, Kb: 0.114}
+	bt709 = colorMatrix{Name: "bt709", Kr: 0.2126, Kb: 0.0722}
+)
+
+// tag returns ffmpeg's name of the matrix for tagging streams
+func (m colorMatrix) tag() string {
+	if m == bt601 {
+		return "smpte170m"
+	}
+	return m.Name
+}
+
+// colorMatrices are the matrices by name
+var colorMatrices = map[string]colorMatrix{bt601.Name: bt601, bt709.Name: bt709}
+
+// colorSpace is the matrix and range of YUV samples
+type colorSpace struct {
+	Matrix colorMatrix
+	Full   bool
+}
+
+// String describes a color space for the log, as in "bt709 limited"
+func (s colorSpace) String() string {
+	if s.Full {
+		return s.Matrix.Name + " full"
+	}
+	return s.Matrix.Name + " limited"
+}
+
+// jfifColorSpace is the color space of JPEG frames and the pipeline
+var jfifColorSpace = colorSpace{Matrix: bt601, Full: true}
+
+// yuvMatrixOverride and yuvRangeOverride replace what drivers report, ""
+// to keep it, set with -yuv-matrix and -yuv-range
+var yuvMatrixOverride, yuvRangeOverride string
+
+// outputMatrix is the matrix H.264 recordings are encoded in, set with
+// -output-colorspace
+var outputMatrix = bt709
+
+// configureColorSpaces sets the color space overrides, each "auto" to keep
+// what the driver reports, and the matrix of H.264 recordings
+func configureColorSpaces(matrix, yuvRange, output string) error {
+	matrix, yuvRange, output = strings.ToLower(matrix), strings.ToLower(yuvRange), strings.ToLower(output)
+	if _, ok := colorMatrices[matrix]; !ok && matrix != "auto" {
+		return fmt.Errorf("unknown YUV matrix %q, expected auto, bt601 or bt709", matrix)
+	}
+	if yuvRange != "auto" && yuvRange != "limited" && yuvRange != "full" {
+		return fmt.Errorf("unknown YUV range %q, expected auto, limited or full", yuvRange)
+	}
+	out, ok := colorMatrices[output]
+	if !ok {
+		return fmt.Errorf("unknown output color space %q, expected bt601 or bt709", output)
+	}
+	yuvMatrixOverride, yuvRangeOverride, outputMatrix = strings.TrimPrefix(matrix, "auto"), strings.TrimPrefix(yuvRange, "auto"), out
+	return nil
+}
+
+// yuvColorSpace returns the color space of a V4L2 format's samples
+func yuvColorSpace(format v4l2.PixFormat) colorSpace {
+	encoding := format.YcbcrEnc
+	if encoding == v4l2.YCbCrEncodingDefault {
+		encoding = v4l2.ColorspaceToYCbCrEnc(format.Colorspace)
+	}
+	space := colorSpace{Matrix: bt601}
+	if encoding == v4l2.YCbCrEncoding709 || encoding == v4l2.YCbCrEncodingXV709 {
+		space.Matrix = bt709
+	}
+	switch format.Quantization {
+	case v4l2.QuantizationFullRange:
+		space.Full = true
+	case v4l2.QuantizationDefault:
+		space.Full = format.Colorspace == v4l2.ColorspaceJPEG
+	}
+
+	if yuvMatrixOverride != "" {
+		space.Matrix = colorMatrices[yuvMatrixOverride]
+	}
+	if yuvRangeOverride != "" {
+		space.Full = yuvRangeOverride == "full"
+	}
+	return space
+}
+
+// yuvNormalization maps samples of a color space to full-range BT.601 in
+// fixed point with yuvNormShift fractional bits:
+//
+//	Y  = (Y' - LumaOffset) * LumaGain + LumaCb * Cb' + LumaCr * Cr'
+//	Cb = CbCb * Cb' + CbCr * Cr' + 128
+//	Cr = CrCb * Cb' + CrCr * Cr' + 128
+//
+// where Cb' and Cr' are the chroma samples less 128. Chroma only moves luma
+// between matrices, by the chroma of the pixel, so subsampled frames map
+// exactly as they are later converted, with their chroma repeated.
+type yuvNormalization struct {
+	LumaOffset, LumaGain, LumaCb, LumaCr int32
+	CbCb, CbCr, CrCb, CrCr               int32
+}
+
+// yuvNormShift is the number of fractional bits of the normalization
+const yuvNormShift = 14
+
+// newYUVNormalization works out the mapping of a color space to JFIF
+func newYUVNormalization(space colorSpace) yuvNormalization {
+	lumaGain, chromaGain, lumaOffset := 1.0, 1.0, 0.0
+	if !space.Full {
+		lumaGain, chromaGain, lumaOffset = 255.0/219, 255.0/224, 16
+	}
+	// R'G'B' from the source matrix's Y'CbCr, then BT.601's Y'CbCr from
+	// that; a chroma unit's contribution follows from the linearity
+	toRGB := func(m colorMatrix, cb, cr float64) (r, g, b float64) {
+		kg := 1 - m.Kr - m.Kb
+		r = 2 * (1 - m.Kr) * cr
+		b = 2 * (1 - m.Kb) * cb
+		g = -(m.Kr*r + m.Kb*b) / kg
+		return r, g, b
+	}
+	fromRGB := func(r, g, b float64) (y, cb, cr float64) {
+		m := bt601
+		y = m.Kr*r + (1-m.Kr-m.Kb)*g + m.Kb*b
+		return y, (b - y) / (2 * (1 - m.Kb)), (r - y) / (2 * (1 - m.Kr))
+	}
+	fixed := func(v float64) int32 {
+		v *= 1 << yuvNormShift
+		if v < 0 {
+			return int32(v - 0.5)
+		}
+		return int32(v + 0.5)
+	}
+	yb, cbb, crb := fromRGB(toRGB(space.Matrix, chromaGain, 0))
+	yr, cbr, crr := fromRGB(toRGB(space.Matrix, 0, chromaGain))
+	return yuvNormalization{
+		LumaOffset: int32(lumaOffset), LumaGain: fixed(lumaGain), LumaCb: fixed(yb), LumaCr: fixed(yr),
+		CbCb: fixed(cbb), CbCr: fixed(cbr), CrCb: fixed(crb), CrCr: fixed(crr),
+	}
+}
+
+// normClamp rounds a fixed point sample and clamps it to a byte
+func normClamp(v int32) uint8 {
+	v = (v + 1<<(yuvNormShift-1)) >> yuvNormShift
+	return uint8(min(max(v, 0), 255))
+}
+
+// normalizeYCbCr converts a 4:2:2 or 4:2:0 image from a color space to
+// full-range BT.601 in place
+func normalizeYCbCr(img *image.YCbCr, space colorSpace) {
+	if space == jfifColorSpace {
+		return
+	}
+	n := newYUVNormalization(space)
+	bounds := img.Bounds()
+	chromaRows := bounds.Dy()
+	if img.SubsampleRatio == image.YCbCrSubsampleRatio420 {
+		chromaRows = (chromaRows + 1) / 2
+	}
+	// Luma first, while the chroma is still the source's
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ci := img.COffset(x, y)
+			cb, cr := int32(img.Cb[ci])-128, int32(img.Cr[ci])-128
+			yi := img.YOffset(x, y)
+			img.Y[yi] = normClamp((int32(img.Y[yi])-n.LumaOffset)*n.LumaGain + n.LumaCb*cb + n.LumaCr*cr)
+		}
+	}
+	chromaWidth := (bounds.Dx() + 1) / 2
+	for y := range chromaRows {
+		row := y * img.CStride
+		for x := range chromaWidth {
+			cb, cr := int32(img.Cb[row+x])-128, int32(img.Cr[row+x])-128
+			img.Cb[row+x] = normClamp(n.CbCb*cb + n.CbCr*cr + 128<<yuvNormShift)
+			img.Cr[row+x] = normClamp(n.CrCb*cb + n.CrCr*cr + 128<<yuvNormShift)
+		}
+	}
+}
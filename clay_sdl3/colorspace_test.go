@@ -0,0 +1,113 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// normalizedPixel converts one 4:2:2 pixel from a color space to JFIF
+func normalizedPixel(space colorSpace, y, cb, cr uint8) (uint8, uint8, uint8) {
+	img := image.NewYCbCr(image.Rect(0, 0, 2, 1), image.YCbCrSubsampleRatio422)
+	img.Y[0], img.Y[1], img.Cb[0], img.Cr[0] = y, y, cb, cr
+	normalizeYCbCr(img, space)
+	return img.Y[0], img.Cb[0], img.Cr[0]
+}
+
+func TestNormalizeLimitedRange(t *testing.T) {
+	limited := colorSpace{Matrix: bt601}
+	for _, tc := range []struct{ in, want [3]uint8 }{
+		{[3]uint8{16, 128, 128}, [3]uint8{0, 128, 128}},
+		{[3]uint8{235, 128, 128}, [3]uint8{255, 128, 128}},
+		{[3]uint8{0, 128, 128}, [3]uint8{0, 128, 128}},
+		{[3]uint8{255, 128, 128}, [3]uint8{255, 128, 128}},
+		// The chroma extremes stretch to -127.5 and 127.5, rounded up
+		{[3]uint8{126, 16, 240}, [3]uint8{128, 1, 255}},
+	} {
+		y, cb, cr := normalizedPixel(limited, tc.in[0], tc.in[1], tc.in[2])
+		if [3]uint8{y, cb, cr} != tc.want {
+			t.Errorf("%v: got %d,%d,%d, want %v", tc.in, y, cb, cr, tc.want)
+		}
+	}
+	if y, cb, cr := normalizedPixel(jfifColorSpace, 16, 40, 200); y != 16 || cb != 40 || cr != 200 {
+		t.Errorf("full-range BT.601 changed to %d,%d,%d", y, cb, cr)
+	}
+}
+
+// TestNormalizeBT709 compares the normalized samples, converted to RGB as
+// the display does, with the colors the BT.709 samples stand for
+func TestNormalizeBT709(t *testing.T) {
+	space := colorSpace{Matrix: bt709}
+	for _, sample := range [][3]uint8{{16, 128, 128}, {63, 102, 240}, {173, 42, 26}, {32, 240, 118}, {200, 100, 160}} {
+		// Limited-range BT.709 to R'G'B' in 0-255
+		y := (float64(sample[0]) - 16) * 255 / 219
+		cb := (float64(sample[1]) - 128) * 255 / 224
+		cr := (float64(sample[2]) - 128) * 255 / 224
+		want := [3]float64{
+			y + 1.5748*cr,
+			y - 0.1873*cb - 0.4681*cr,
+			y + 1.8556*cb,
+		}
+		ny, ncb, ncr := normalizedPixel(space, sample[0], sample[1], sample[2])
+		r, g, b := color.YCbCrToRGB(ny, ncb, ncr)
+		for i, v := range []uint8{r, g, b} {
+			if w := min(max(want[i], 0), 255); math.Abs(float64(v)-w) > 2.5 {
+				t.Errorf("%v: channel %d is %d, want %.1f", sample, i, v, w)
+			}
+		}
+	}
+}
+
+func TestYUVColorSpace(t *testing.T) {
+	defer configureColorSpaces("auto", "auto", "bt709")
+	for _, tc := range []struct {
+		format v4l2.PixFormat
+		want   string
+	}{
+		{v4l2.PixFormat{}, "bt601 limited"},
+		{v4l2.PixFormat{Colorspace: v4l2.ColorspaceJPEG}, "bt601 full"},
+		{v4l2.PixFormat{Colorspace: v4l2.ColorspaceREC709}, "bt709 limited"},
+		{v4l2.PixFormat{Colorspace: v4l2.ColorspaceREC709, Quantization: v4l2.QuantizationFullRange}, "bt709 full"},
+		{v4l2.PixFormat{Colorspace: v4l2.ColorspaceJPEG, YcbcrEnc: v4l2.YCbCrEncoding709, Quantization: v4l2.QuantizationLimitedRange}, "bt709 limited"},
+	} {
+		if got := yuvColorSpace(tc.format).String(); got != tc.want {
+			t.Errorf("%+v: got %s, want %s", tc.format, got, tc.want)
+		}
+	}
+
+	if err := configureColorSpaces("BT709", "full", "bt601"); err != nil {
+		t.Fatal(err)
+	}
+	if got := yuvColorSpace(v4l2.PixFormat{}).String(); got != "bt709 full" {
+		t.Errorf("overridden: got %s", got)
+	}
+	for _, bad := range [][3]string{{"bt2020", "auto", "bt709"}, {"auto", "tv", "bt709"}, {"auto", "auto", "auto"}} {
+		if err := configureColorSpaces(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("%v accepted", bad)
+		}
+	}
+}
+
+func TestFFmpegColorTags(t *testing.T) {
+	defer configureColorSpaces("auto", "auto", "bt709")
+	for _, output := range []string{"bt709", "bt601"} {
+		if err := configureColorSpaces("auto", "auto", output); err != nil {
+			t.Fatal(err)
+		}
+		for _, backend := range videoEncoderBackends {
+			args := backend.ffmpegArgs()
+			vf := slices.Index(args, "-vf")
+			if vf < 0 || !strings.Contains(args[vf+1], "out_color_matrix="+output+":out_range=tv,"+backend.Filter) {
+				t.Errorf("%s %s: filter missing from %v", backend.Name, output, args)
+			}
+			if tag := slices.Index(args, "-colorspace"); tag < 0 || args[tag+1] != outputMatrix.tag() {
+				t.Errorf("%s %s: color space not tagged in %v", backend.Name, output, args)
+			}
+		}
+	}
+}
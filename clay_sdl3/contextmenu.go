@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// ContextMenuState tracks the right-click popup shown over a thumbnail
+type ContextMenuState struct {
+	Open     bool
+	Camera   int
+	Position clay.Vector2
+}
+
+// RenameState tracks an in-progress inline camera rename
+type RenameState struct {
+	Active bool
+	Camera int
+	Text   string
+}
+
+type contextMenuAction int
+
+const (
+	menuRename contextMenuAction = iota
+	menuToggleStream
+	menuControls
+	menuSnapshot
+	menuLight
+	menuToggleRecording
+	menuPopOut
+	menuWhiteBalance
+	menuDenoise
+	menuStackAverage
+	menuStackMax
+	menuCancelStack
+	menuBracket
+	menuCancelBracket
+	menuCalibrate
+	menuKeystone
+	menuStitch
+	menuStopStitch
+	menuPalette
+	menuMomentGIF
+	menuMomentWebP
+)
+
+type contextMenuItem struct {
+	Label  string
+	Action contextMenuAction
+}
+
+// contextMenuItems returns the entries of the context menu for a camera,
+// labelled according to its current state
+func contextMenuItems(data *CameraAppData, index int) []contextMenuItem {
+	camera := &data.Cameras[index]
+	streamLabel := tr("menu.disable")
+	if camera.Disabled {
+		streamLabel = tr("menu.enable")
+	}
+	recordLabel := tr("menu.start_rec")
+	if camera.Recorder != nil {
+		recordLabel = tr("menu.stop_rec")
+	}
+	popOutLabel := tr("menu.pop_out")
+	if camera.PopOut != nil {
+		popOutLabel = tr("menu.dock")
+	}
+	whiteBalanceLabel := tr("menu.white_balance")
+	if camera.WhiteBalance != nil {
+		whiteBalanceLabel = tr("menu.reset_wb")
+	}
+
+	items := []contextMenuItem{
+		{Label: tr("menu.rename"), Action: menuRename},
+		{Label: streamLabel, Action: menuToggleStream},
+		{Label: tr("menu.controls"), Action: menuControls},
+		{Label: tr("menu.snapshot"), Action: menuSnapshot},
+		{Label: recordLabel, Action: menuToggleRecording},
+		{Label: popOutLabel, Action: menuPopOut},
+		{Label: whiteBalanceLabel, Action: menuWhiteBalance},
+		{Label: tr("menu.denoise", denoiseLevelName(camera)), Action: menuDenoise},
+		{Label: tr("menu.calibrate"), Action: menuCalibrate},
+		{Label: tr("menu.keystone"), Action: menuKeystone},
+	}
+	if lightFor(camera) != nil {
+		lightLabel := tr("menu.light_on")
+		if camera.LightOn {
+			lightLabel = tr("menu.light_off")
+		}
+		items = append(items, contextMenuItem{Label: lightLabel, Action: menuLight})
+	}
+	if isGreyCamera(camera) {
+		items = append(items, contextMenuItem{Label: tr("menu.palette", paletteName(camera)), Action: menuPalette})
+	}
+	if camera.History.Len() > 0 {
+		items = append(items,
+			contextMenuItem{Label: tr("menu.moment_gif", momentSeconds), Action: menuMomentGIF},
+			contextMenuItem{Label: tr("menu.moment_webp", momentSeconds), Action: menuMomentWebP},
+		)
+	}
+	if mosaic != nil {
+		items = append(items, contextMenuItem{Label: tr("menu.stop_stitch"), Action: menuStopStitch})
+	} else if index != data.SelectedCamera && data.SelectedCamera < len(data.Cameras) {
+		items = append(items, contextMenuItem{
+			Label:  tr("menu.stitch", data.Cameras[data.SelectedCamera].Info.Name),
+			Action: menuStitch,
+		})
+	}
+	if camera.Bracket != nil {
+		items = append(items, contextMenuItem{Label: tr("menu.cancel_bracket"), Action: menuCancelBracket})
+	} else if hasControl(camera, ctrlExposureAbsolute) {
+		items = append(items, contextMenuItem{Label: tr("menu.bracket", bracketFrames), Action: menuBracket})
+	}
+	if camera.Stack != nil {
+		return append(items, contextMenuItem{Label: tr("menu.cancel_stack"), Action: menuCancelStack})
+	}
+	return append(items,
+		contextMenuItem{Label: tr("menu.stack_average", stackFrames), Action: menuStackAverage},
+		contextMenuItem{Label: tr("menu.stack_max", stackFrames), Action: menuStackMax},
+	)
+}
+
+// createContextMenuLayout declares the context menu as a floating element on
+// top of the rest of the layout
+func createContextMenuLayout(data *CameraAppData) {
+	menu := &data.ContextMenu
+	if !menu.Open || menu.Camera >= len(data.Cameras) {
+		return
+	}
+	camera := &data.Cameras[menu.Camera]
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("ContextMenu"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Sizing: clay.Sizing{
+				Width: clay.SizingFixed(tp(160)),
+			},
+			Padding:  clay.PaddingAll(dpu(4)),
+			ChildGap: dpu(2),
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:   menu.Position,
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(4)),
+		Border: clay.BorderElementConfig{
+			Color: theme.PopupBorder,
+			Width: clay.BorderOutside(dpu(1)),
+		},
+	}, func() {
+		safeText("menu-title", camera.Info.Name, clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  10,
+			TextColor: theme.TextDim,
+		})
+
+		for i, item := range contextMenuItems(data, menu.Camera) {
+			id := SafeID(fmt.Sprintf("ContextMenuItem%d", i))
+			hovered := clay.PointerOver(id)
+			clay.UI()(clay.ElementDeclaration{
+				Id: id,
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{
+						Width: clay.SizingGrow(0),
+					},
+					Padding: clay.Padding{Left: tpu(8), Right: tpu(8), Top: tpu(4), Bottom: tpu(4)},
+				},
+				BackgroundColor: func() clay.Color {
+					if hovered {
+						return theme.Accent
+					}
+					return theme.Popup
+				}(),
+				CornerRadius: clay.CornerRadiusAll(dp(2)),
+			}, func() {
+				textColor := theme.Text
+				if hovered {
+					textColor = theme.AccentText
+				}
+				safeText("menu-item", item.Label, clay.TextElementConfig{
+					FontId:    FontIdBody16,
+					FontSize:  12,
+					TextColor: textColor,
+				})
+			})
+		}
+	})
+}
+
+// openContextMenu shows the context menu for a camera at the given position
+func openContextMenu(appData *CameraAppData, index int, x, y float32) {
+	appData.ContextMenu = ContextMenuState{
+		Open:     true,
+		Camera:   index,
+		Position: clay.Vector2{X: x, Y: y},
+	}
+}
+
+// handleContextMenuClick runs the menu entry under the pointer, if any, and
+// closes the menu. It reports whether the menu consumed the click.
+func handleContextMenuClick(appData *CameraAppData, x, y float32) bool {
+	menu := &appData.ContextMenu
+	if !menu.Open {
+		return false
+	}
+	menu.Open = false
+
+	if menu.Camera >= len(appData.Cameras) {
+		return true
+	}
+
+	for i, item := range contextMenuItems(appData, menu.Camera) {
+		if pointInElement(SafeID(fmt.Sprintf("ContextMenuItem%d", i)), x, y) {
+			runContextMenuAction(appData, menu.Camera, item.Action)
+			return true
+		}
+	}
+
+	// A click outside the menu only dismisses it
+	return pointInElement(SafeID("ContextMenu"), x, y)
+}
+
+func runContextMenuAction(appData *CameraAppData, index int, action contextMenuAction) {
+	camera := &appData.Cameras[index]
+
+	switch action {
+	case menuRename:
+		startRename(appData, index)
+
+	case menuToggleStream:
+		setCameraEnabled(appData, index, camera.Disabled)
+
+	case menuControls:
+		openControlsPanel(appData, index)
+
+	case menuSnapshot:
+		requestSnapshot(appData, index)
+
+	case menuLight:
+		toggleLight(appData, index)
+
+	case menuToggleRecording:
+		if camera.Recorder != nil {
+			if err := stopRecording(camera); err != nil {
+				setErrorStatus(appData, err)
+				return
+			}
+			appData.StatusText = tr("status.rec_stopped", camera.Info.Name)
+			return
+		}
+		if camera.Disabled || !camera.Active {
+			setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+			return
+		}
+		if err := startRecording(camera); err != nil {
+			setErrorStatus(appData, err)
+			return
+		}
+		appData.StatusText = tr("status.recording", camera.Info.Name)
+
+	case menuPopOut:
+		if camera.PopOut != nil {
+			closePopOut(camera)
+			return
+		}
+		if err := openPopOut(appData, index); err != nil {
+			setErrorStatus(appData, err)
+		}
+
+	case menuWhiteBalance:
+		if camera.WhiteBalance != nil {
+			resetWhiteBalance(appData, index)
+			return
+		}
+		startWhiteBalancePick(appData, index)
+
+	case menuDenoise:
+		cycleDenoise(camera)
+		appData.StatusText = tr("status.denoise", camera.Info.Name, denoiseLevelName(camera))
+
+	case menuPalette:
+		cyclePalette(camera)
+		appData.StatusText = tr("status.palette", camera.Info.Name, paletteName(camera))
+
+	case menuMomentGIF:
+		exportMoment(camera, "gif", momentSeconds)
+
+	case menuMomentWebP:
+		exportMoment(camera, "webp", momentSeconds)
+
+	case menuStackAverage:
+		startStack(appData, index, stackAverage)
+
+	case menuStackMax:
+		startStack(appData, index, stackMax)
+
+	case menuCalibrate:
+		startCalibration(appData, index)
+
+	case menuKeystone:
+		startKeystoneEdit(appData, index)
+
+	case menuStitch:
+		// The camera in the main view is the reference the other is
+		// placed against
+		startMosaic(appData, appData.SelectedCamera, index)
+
+	case menuStopStitch:
+		stopMosaic()
+		appData.StatusText = tr("status.stitch_off")
+
+	case menuCancelStack:
+		cancelStack(camera)
+		appData.StatusText = tr("status.ready")
+
+	case menuBracket:
+		startBracket(appData, index)
+
+	case menuCancelBracket:
+		cancelBracket(camera)
+		appData.StatusText = tr("status.ready")
+	}
+}
+
+// setErrorStatus logs err and shows it in the status bar and as a toast
+func setErrorStatus(appData *CameraAppData, err error) {
+	log.Printf("Error: %v", err)
+	appData.StatusText = err.Error()
+	appData.StatusColor = theme.Error
+	notify(toastError, err.Error())
+}
+
+// startRename begins inline renaming of a camera, capturing text input
+func startRename(appData *CameraAppData, index int) {
+	appData.Rename = RenameState{
+		Active: true,
+		Camera: index,
+		Text:   appData.Cameras[index].Info.Name,
+	}
+	if err := appData.Window.StartTextInput(); err != nil {
+		log.Printf("Failed to start text input: %v", err)
+	}
+}
+
+// finishRename ends inline renaming, applying the new name if commit is set
+func finishRename(appData *CameraAppData, commit bool) {
+	rename := &appData.Rename
+	if !rename.Active {
+		return
+	}
+	rename.Active = false
+	if err := appData.Window.StopTextInput(); err != nil {
+		log.Printf("Failed to stop text input: %v", err)
+	}
+
+	name := sanitizeText(rename.Text)
+	if commit && rename.Text != "" && rename.Camera < len(appData.Cameras) {
+		camera := &appData.Cameras[rename.Camera]
+		if name == camera.Info.Name {
+			return
+		}
+		camera.Info.Name = name
+		if err := saveCameraName(camera.Info, name); err != nil {
+			setErrorStatus(appData, err)
+		}
+	}
+}
+
+// startRenameAt starts renaming the camera whose thumbnail label was
+// double-clicked, and reports whether there was one
+func startRenameAt(appData *CameraAppData, x, y float32) bool {
+	for i := range appData.Cameras {
+		if pointInElement(SafeID(fmt.Sprintf("ThumbnailLabel%d", i)), x, y) {
+			finishRename(appData, true)
+			thumbnailDrag = ThumbnailDrag{}
+			startRename(appData, i)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// ControlsPanelState tracks the floating panel with a camera's V4L2 controls
+type ControlsPanelState struct {
+	Open     bool
+	Camera   int
+	Controls []v4l2.Control
+	// Profiles are the camera's control profiles, listed while
+	// ProfilesOpen is set
+	Profiles     []string
+	ProfilesOpen bool
+	// Naming is set while the name of a new profile is being typed
+	Naming bool
+	Text   string
+}
+
+// openControlsPanel queries the controls of a camera and shows them
+func openControlsPanel(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+
+	var controls []v4l2.Control
+	if camera.Device != nil {
+		var err error
+		controls, err = camera.Device.QueryControls()
+		if err != nil {
+			log.Printf("Failed to query controls for %s: %v", camera.Info.Name, err)
+		}
+	}
+
+	appData.ControlsPanel = ControlsPanelState{
+		Open:     true,
+		Camera:   index,
+		Controls: controls,
+		Profiles: profileNames(camera),
+	}
+}
+
+// refreshControls reads the values of the controls panel's controls again,
+// after a profile changed them
+func refreshControls(appData *CameraAppData) {
+	panel := &appData.ControlsPanel
+	camera := &appData.Cameras[panel.Camera]
+	if camera.Device == nil {
+		return
+	}
+	controls, err := camera.Device.QueryControls()
+	if err != nil {
+		log.Printf("Failed to query controls for %s: %v", camera.Info.Name, err)
+		return
+	}
+	panel.Controls = controls
+}
+
+// createControlsPanelLayout declares the controls panel as a floating element
+// centered over the window
+func createControlsPanelLayout(data *CameraAppData) {
+	panel := &data.ControlsPanel
+	if !panel.Open || panel.Camera >= len(data.Cameras) {
+		return
+	}
+	camera := &data.Cameras[panel.Camera]
+
+	textConfig := clay.TextElementConfig{
+		FontId:    FontIdBody16,
+		FontSize:  12,
+		TextColor: theme.Text,
+	}
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("ControlsPanel"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Sizing: clay.Sizing{
+				Width: clay.SizingFixed(tp(360)),
+			},
+			Padding:  clay.PaddingAll(dpu(12)),
+			ChildGap: dpu(6),
+		},
+		Floating: clay.FloatingElementConfig{
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_CENTER,
+				Parent:  clay.ATTACH_POINT_CENTER_CENTER,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(6)),
+		Border: clay.BorderElementConfig{
+			Color: theme.AccentBorder,
+			Width: clay.BorderOutside(dpu(2)),
+		},
+	}, func() {
+		safeText("controls-title", tr("controls.title", camera.Info.Name), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+		if camera.Mode != "" {
+			safeText("controls-mode", tr("controls.mode", camera.Mode), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.TextDim,
+			})
+		}
+
+		// The profile list drops down under its button
+		clay.UI()(clay.ElementDeclaration{
+			Layout: clay.LayoutConfig{
+				ChildGap: dpu(6),
+				ChildAlignment: clay.ChildAlignment{
+					Y: clay.ALIGN_Y_CENTER,
+				},
+			},
+		}, func() {
+			profile := camera.Profile
+			if profile == "" {
+				profile = tr("controls.no_profile")
+			}
+			controlButton("ControlProfiles", tr("controls.profile", profile))
+			if panel.Naming {
+				safeText("controls-profile-name", tr("controls.profile_name", panel.Text+"_"), textConfig)
+			} else {
+				controlButton("ControlProfileSave", tr("controls.save_profile"))
+			}
+		})
+		if panel.ProfilesOpen {
+			if len(panel.Profiles) == 0 {
+				safeText("controls-no-profiles", tr("controls.no_profiles"), clay.TextElementConfig{
+					FontId:    FontIdBody16,
+					FontSize:  12,
+					TextColor: theme.TextDim,
+				})
+			}
+			for i, name := range panel.Profiles {
+				controlButton(fmt.Sprintf("ControlProfile%d", i), name)
+			}
+		}
+
+		if len(panel.Controls) == 0 {
+			safeText("controls-none", tr("controls.none"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.Error,
+			})
+		}
+
+		for i := range panel.Controls {
+			ctrl := &panel.Controls[i]
+			clay.UI()(clay.ElementDeclaration{
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{
+						Width: clay.SizingGrow(0),
+					},
+					ChildGap: dpu(6),
+					ChildAlignment: clay.ChildAlignment{
+						Y: clay.ALIGN_Y_CENTER,
+					},
+				},
+			}, func() {
+				clay.UI()(clay.ElementDeclaration{
+					Layout: clay.LayoutConfig{
+						Sizing: clay.Sizing{
+							Width: clay.SizingGrow(0),
+						},
+					},
+				}, func() {
+					safeText("control-name", ctrl.Name, textConfig)
+				})
+				controlButton(fmt.Sprintf("ControlDec%d", i), "-")
+				safeText("control-value", fmt.Sprintf("%d", ctrl.Value), textConfig)
+				controlButton(fmt.Sprintf("ControlInc%d", i), "+")
+			})
+		}
+
+		controlButton("ControlsClose", tr("controls.close"))
+	})
+}
+
+// controlButton declares a small clickable button with a text label
+func controlButton(id, label string) {
+	elementID := SafeID(id)
+	hovered := clay.PointerOver(elementID)
+	clay.UI()(clay.ElementDeclaration{
+		Id: elementID,
+		Layout: clay.LayoutConfig{
+			Padding: clay.Padding{Left: tpu(8), Right: tpu(8), Top: tpu(4), Bottom: tpu(4)},
+		},
+		BackgroundColor: func() clay.Color {
+			if hovered {
+				return theme.Accent
+			}
+			return theme.Item
+		}(),
+		CornerRadius: clay.CornerRadiusAll(dp(3)),
+	}, func() {
+		textColor := theme.Text
+		if hovered {
+			textColor = theme.AccentText
+		}
+		safeText("button", label, clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  12,
+			TextColor: textColor,
+		})
+	})
+}
+
+// handleControlsPanelClick handles clicks on the controls panel buttons. It
+// reports whether the click landed on the panel.
+func handleControlsPanelClick(appData *CameraAppData, x, y float32) bool {
+	panel := &appData.ControlsPanel
+	if !panel.Open {
+		return false
+	}
+	if !pointInElement(SafeID("ControlsPanel"), x, y) {
+		return false
+	}
+
+	switch {
+	case pointInElement(SafeID("ControlsClose"), x, y):
+		closeControlsPanel(appData)
+		return true
+	case pointInElement(SafeID("ControlProfiles"), x, y):
+		panel.ProfilesOpen = !panel.ProfilesOpen
+		return true
+	case pointInElement(SafeID("ControlProfileSave"), x, y):
+		if !panel.Naming {
+			panel.Naming = true
+			panel.Text = ""
+			if err := appData.Window.StartTextInput(); err != nil {
+				log.Printf("Failed to start text input: %v", err)
+			}
+		}
+		return true
+	}
+	if panel.ProfilesOpen {
+		for i, name := range panel.Profiles {
+			if pointInElement(SafeID(fmt.Sprintf("ControlProfile%d", i)), x, y) {
+				selectProfile(appData, name)
+				return true
+			}
+		}
+	}
+
+	for i := range panel.Controls {
+		switch {
+		case pointInElement(SafeID(fmt.Sprintf("ControlDec%d", i)), x, y):
+			adjustControl(appData, &panel.Controls[i], -1)
+		case pointInElement(SafeID(fmt.Sprintf("ControlInc%d", i)), x, y):
+			adjustControl(appData, &panel.Controls[i], 1)
+		}
+	}
+	return true
+}
+
+// selectProfile applies a profile to the controls panel's camera
+func selectProfile(appData *CameraAppData, name string) {
+	panel := &appData.ControlsPanel
+	camera := &appData.Cameras[panel.Camera]
+	panel.ProfilesOpen = false
+	err := applyProfile(camera, name)
+	refreshControls(appData)
+	if err != nil {
+		setErrorStatus(appData, err)
+		return
+	}
+	appData.StatusText = tr("status.profile_applied", camera.Info.Name, name)
+	appData.StatusColor = theme.Text
+}
+
+// handleProfileNameKey edits the name of the profile being saved
+func handleProfileNameKey(appData *CameraAppData, scancode sdl.Scancode) {
+	panel := &appData.ControlsPanel
+	switch scancode {
+	case sdl.SCANCODE_RETURN, sdl.SCANCODE_KP_ENTER:
+		name := strings.TrimSpace(panel.Text)
+		if name == "" {
+			return
+		}
+		camera := &appData.Cameras[panel.Camera]
+		if err := saveProfile(camera, name); err != nil {
+			setErrorStatus(appData, err)
+		} else {
+			appData.StatusText = tr("status.profile_saved", camera.Info.Name, name)
+			appData.StatusColor = theme.Text
+			panel.Profiles = profileNames(camera)
+		}
+		stopProfileNaming(appData)
+	case sdl.SCANCODE_ESCAPE:
+		stopProfileNaming(appData)
+	case sdl.SCANCODE_BACKSPACE:
+		text := []rune(panel.Text)
+		if len(text) > 0 {
+			panel.Text = string(text[:len(text)-1])
+		}
+	}
+}
+
+// stopProfileNaming ends the entry of a profile name
+func stopProfileNaming(appData *CameraAppData) {
+	panel := &appData.ControlsPanel
+	if !panel.Naming {
+		return
+	}
+	if err := appData.Window.StopTextInput(); err != nil {
+		log.Printf("Failed to stop text input: %v", err)
+	}
+	panel.Naming = false
+	panel.Text = ""
+}
+
+// closeControlsPanel hides the controls panel, ending any name entry
+func closeControlsPanel(appData *CameraAppData) {
+	stopProfileNaming(appData)
+	appData.ControlsPanel = ControlsPanelState{}
+}
+
+// adjustControl moves a control by steps increments within its range and
+// applies it to the device
+func adjustControl(appData *CameraAppData, ctrl *v4l2.Control, steps int32) {
+	camera := &appData.Cameras[appData.ControlsPanel.Camera]
+	if camera.Device == nil {
+		return
+	}
+
+	step := ctrl.Step
+	if step <= 0 {
+		step = 1
+	}
+	value := ctrl.Value + steps*step
+	if value < ctrl.Minimum {
+		value = ctrl.Minimum
+	}
+	if value > ctrl.Maximum {
+		value = ctrl.Maximum
+	}
+
+	if err := camera.Device.SetControlValue(ctrl.ID, value); err != nil {
+		setErrorStatus(appData, trErr("error.set_control", ctrl.Name, err))
+		return
+	}
+	ctrl.Value = value
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// A crop makes the driver read out only part of the sensor, so a 4K sensor
+// can stream a 1280x720 region at the frame rate of a 720p mode, without
+// the USB bandwidth and decode time of the full frame. It is set with the
+// V4L2 selection API, falling back to the older crop API.
+
+// v4l2Selection mirrors struct v4l2_selection
+type v4l2Selection struct {
+	Type     uint32
+	Target   uint32
+	Flags    uint32
+	Rect     v4l2.Rect
+	Reserved [9]uint32
+}
+
+const (
+	v4l2SelectionCrop       = 0x0000
+	v4l2SelectionCropBounds = 0x0002
+	// VIDIOC_G_SELECTION and VIDIOC_S_SELECTION, _IOWR('V', 94/95, struct
+	// v4l2_selection)
+	vidiocGetSelection = 0xc040565e
+	vidiocSetSelection = 0xc040565f
+)
+
+// cameraCrop is the region a camera is cropped to. Without an offset the
+// region is centered on the sensor.
+type cameraCrop struct {
+	Width, Height uint32
+	Left, Top     int32
+	Centered      bool
+}
+
+// cameraCrops are the crops set with -crop by device path; the empty path
+// applies to every V4L2 camera without its own
+var cameraCrops = make(map[string]cameraCrop)
+
+// parseCrop adds a -crop value, "[DEVICE=]WIDTHxHEIGHT[+LEFT+TOP]"
+func parseCrop(value string) error {
+	path, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		path, spec = "", value
+	}
+	invalid := fmt.Errorf("invalid crop %q, expected [DEVICE=]WIDTHxHEIGHT[+LEFT+TOP]", value)
+	size, offset, hasOffset := strings.Cut(spec, "+")
+	w, h, ok := strings.Cut(strings.ToLower(size), "x")
+	width, errW := strconv.ParseUint(w, 10, 32)
+	height, errH := strconv.ParseUint(h, 10, 32)
+	if !ok || errW != nil || errH != nil || width == 0 || height == 0 {
+		return invalid
+	}
+	crop := cameraCrop{Width: uint32(width), Height: uint32(height), Centered: !hasOffset}
+	if hasOffset {
+		l, t, ok := strings.Cut(offset, "+")
+		left, errL := strconv.ParseInt(l, 10, 32)
+		top, errT := strconv.ParseInt(t, 10, 32)
+		if !ok || errL != nil || errT != nil {
+			return invalid
+		}
+		crop.Left, crop.Top = int32(left), int32(top)
+	}
+	cameraCrops[path] = crop
+	return nil
+}
+
+// cropFor returns the crop of a camera, if any
+func cropFor(path string) (cameraCrop, bool) {
+	if crop, ok := cameraCrops[path]; ok {
+		return crop, true
+	}
+	crop, ok := cameraCrops[""]
+	return crop, ok
+}
+
+// applyCrop sets a crop on a device and returns the region the driver
+// settled on. It has to run before the format is set, which then takes the
+// size of the region.
+func applyCrop(dev videoDevice, crop cameraCrop) (v4l2.Rect, error) {
+	bounds := v4l2.Rect{Width: crop.Width, Height: crop.Height}
+	selection := v4l2Selection{Type: uint32(v4l2.BufTypeVideoCapture), Target: v4l2SelectionCropBounds}
+	if err := dev.GetSelection(&selection); err == nil {
+		bounds = selection.Rect
+	} else if capability, err := dev.GetCropCapability(); err == nil {
+		bounds = capability.Bounds
+	} else {
+		return v4l2.Rect{}, fmt.Errorf("the device cannot crop: %w", err)
+	}
+
+	rect := v4l2.Rect{
+		Left:   bounds.Left + crop.Left,
+		Top:    bounds.Top + crop.Top,
+		Width:  min(crop.Width, bounds.Width),
+		Height: min(crop.Height, bounds.Height),
+	}
+	if crop.Centered {
+		rect.Left = bounds.Left + int32(bounds.Width-rect.Width)/2
+		rect.Top = bounds.Top + int32(bounds.Height-rect.Height)/2
+	}
+
+	selection = v4l2Selection{Type: uint32(v4l2.BufTypeVideoCapture), Target: v4l2SelectionCrop, Rect: rect}
+	if err := dev.SetSelection(&selection); err == nil {
+		// The driver writes back the region it actually uses
+		return selection.Rect, nil
+	}
+	if err := dev.SetCropRect(rect); err != nil {
+		return v4l2.Rect{}, fmt.Errorf("failed to crop to %dx%d+%d+%d: %w", rect.Width, rect.Height, rect.Left, rect.Top, err)
+	}
+	return rect, nil
+}
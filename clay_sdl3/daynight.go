@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cameras watching around the clock can switch between a day and a night
+// control profile by the brightness of the scene. -day-night names the two
+// profiles and the mean luma below which the camera goes to night and above
+// which it goes back to day; the gap between the two keeps a camera at
+// dusk from flapping, and the luma has to stay past a threshold for
+// -day-night-dwell before the profile changes, so that headlights or a
+// passing shadow don't switch it. The night profile usually brightens the
+// picture, so the day threshold has to be above what the night profile
+// shows at dusk. At startup the camera gets the profile the first measured
+// luma calls for straight away.
+
+const (
+	// defaultDarkLuma and defaultBrightLuma are the thresholds when
+	// -day-night does not give them
+	defaultDarkLuma   = 40
+	defaultBrightLuma = 100
+	// sceneLumaSmoothing is the weight of a new frame in the smoothed luma
+	sceneLumaSmoothing = 0.1
+)
+
+// DayNightConfig is how a camera switches between its day and night
+// profiles
+type DayNightConfig struct {
+	Day, Night string
+	// Dark is the luma below which the night profile is used, Bright the
+	// luma above which the day profile is
+	Dark, Bright float32
+}
+
+// DayNightState tracks a camera's day and night switching
+type DayNightState struct {
+	// Profile is the profile applied, empty until the first switch
+	Profile string
+	// Luma is the smoothed mean luma of the frames, 0 until measured. It
+	// is written by processFrame with FrameMutex held.
+	Luma float32
+	// since is when the luma crossed the threshold towards the other
+	// profile, zero while it has not
+	since time.Time
+}
+
+var (
+	// dayNightConfigs are set with -day-night by device path; the empty
+	// path applies to every camera without its own
+	dayNightConfigs = make(map[string]DayNightConfig)
+	// dayNightDwell is how long the luma stays past a threshold before the
+	// profile changes, set with -day-night-dwell
+	dayNightDwell = 30 * time.Second
+)
+
+// parseDayNight adds a -day-night value, "[DEVICE=]DAY,NIGHT[,DARK,BRIGHT]"
+func parseDayNight(value string) error {
+	path, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		path, spec = "", value
+	}
+	invalid := fmt.Errorf("invalid day/night profiles %q, expected [DEVICE=]DAY,NIGHT[,DARK,BRIGHT] with 0 <= DARK < BRIGHT <= 255", value)
+	parts := strings.Split(spec, ",")
+	if len(parts) != 2 && len(parts) != 4 || parts[0] == "" || parts[1] == "" {
+		return invalid
+	}
+	config := DayNightConfig{Day: parts[0], Night: parts[1], Dark: defaultDarkLuma, Bright: defaultBrightLuma}
+	if len(parts) == 4 {
+		dark, errDark := strconv.ParseFloat(parts[2], 32)
+		bright, errBright := strconv.ParseFloat(parts[3], 32)
+		if errDark != nil || errBright != nil || dark < 0 || bright > 255 || dark >= bright {
+			return invalid
+		}
+		config.Dark, config.Bright = float32(dark), float32(bright)
+	}
+	dayNightConfigs[path] = config
+	return nil
+}
+
+// dayNightFor returns a camera's day and night switching, if it has any
+func dayNightFor(camera *CameraInstance) (DayNightConfig, bool) {
+	if config, ok := dayNightConfigs[camera.Info.Path]; ok {
+		return config, true
+	}
+	config, ok := dayNightConfigs[""]
+	return config, ok
+}
+
+// measureSceneLuma updates the smoothed luma of a camera that switches
+// between day and night. It is called with the decoded frame while
+// FrameMutex is held.
+func measureSceneLuma(camera *CameraInstance, img *image.RGBA) {
+	if _, ok := dayNightFor(camera); !ok {
+		return
+	}
+	bounds := img.Bounds()
+	// Every eighth pixel in each direction is plenty for a mean
+	var sum, count uint64
+	for y := 0; y < bounds.Dy(); y += 8 {
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < bounds.Dx(); x += 8 {
+			p := row[x*4 : x*4+3]
+			sum += (77*uint64(p[0]) + 150*uint64(p[1]) + 29*uint64(p[2])) >> 8
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	mean := float32(sum) / float32(count)
+	state := &camera.DayNight
+	if state.Luma == 0 {
+		state.Luma = max(mean, 0.5)
+		return
+	}
+	state.Luma += (mean - state.Luma) * sceneLumaSmoothing
+}
+
+// dayNightTarget returns the profile a luma calls for, given the profile
+// applied, and whether that is a change
+func dayNightTarget(config DayNightConfig, current string, luma float32) (string, bool) {
+	switch {
+	case current == "":
+		if luma < (config.Dark+config.Bright)/2 {
+			return config.Night, true
+		}
+		return config.Day, true
+	case current == config.Day && luma < config.Dark:
+		return config.Night, true
+	case current == config.Night && luma > config.Bright:
+		return config.Day, true
+	}
+	return current, false
+}
+
+// updateDayNight switches cameras to the profile their scene calls for once
+// it has called for it for dayNightDwell
+func updateDayNight(appData *CameraAppData, now time.Time) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		config, ok := dayNightFor(camera)
+		if !ok || !camera.Active || camera.Disabled {
+			continue
+		}
+		camera.FrameMutex.RLock()
+		luma := camera.DayNight.Luma
+		camera.FrameMutex.RUnlock()
+		state := &camera.DayNight
+		if luma == 0 {
+			continue
+		}
+
+		target, change := dayNightTarget(config, state.Profile, luma)
+		if !change {
+			state.since = time.Time{}
+			continue
+		}
+		if state.Profile != "" {
+			if state.since.IsZero() {
+				state.since = now
+			}
+			if now.Sub(state.since) < dayNightDwell {
+				continue
+			}
+		}
+		state.Profile = target
+		state.since = time.Time{}
+		if err := applyProfile(camera, target); err != nil {
+			setErrorStatus(appData, err)
+			continue
+		}
+		appData.StatusText = tr("status.day_night", camera.Info.Name, target, luma)
+		appData.StatusColor = theme.Text
+		notify(toastInfo, appData.StatusText)
+	}
+}
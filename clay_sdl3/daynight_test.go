@@ -0,0 +1,96 @@
+package main
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+func TestParseDayNight(t *testing.T) {
+	t.Cleanup(func() { dayNightConfigs = make(map[string]DayNightConfig) })
+	if err := parseDayNight("day,night"); err != nil {
+		t.Fatal(err)
+	}
+	if got := dayNightConfigs[""]; got != (DayNightConfig{Day: "day", Night: "night", Dark: defaultDarkLuma, Bright: defaultBrightLuma}) {
+		t.Errorf("defaults %+v", got)
+	}
+	if err := parseDayNight("/dev/video2=sun,moon,20,60"); err != nil {
+		t.Fatal(err)
+	}
+	if got := dayNightConfigs["/dev/video2"]; got.Night != "moon" || got.Dark != 20 || got.Bright != 60 {
+		t.Errorf("thresholds %+v", got)
+	}
+	for _, value := range []string{"day", "day,", "day,night,60,20", "day,night,10", "day,night,0,300"} {
+		if err := parseDayNight(value); err == nil {
+			t.Errorf("%q accepted", value)
+		}
+	}
+}
+
+func TestDayNightSwitching(t *testing.T) {
+	t.Cleanup(func() {
+		dayNightConfigs = make(map[string]DayNightConfig)
+		cameraProfiles = nil
+	})
+	dayNightConfigs[""] = DayNightConfig{Day: "day", Night: "night", Dark: 40, Bright: 100}
+	cameraProfiles = map[string]map[string]ControlProfile{"*": {
+		"day":   {Controls: []SessionControl{{Name: "Gain", Value: 0}}},
+		"night": {Controls: []SessionControl{{Name: "gain", Value: 200}}, MaxFPS: 10},
+	}}
+	dev := newFakeDevice(0)
+	dev.Controls[ctrlGain] = v4l2.Control{ID: ctrlGain, Name: "Gain", Minimum: 0, Maximum: 255, Step: 1}
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "yard"}, Device: dev, Active: true}}}
+	camera := &appData.Cameras[0]
+	gain := func() int32 {
+		value, _ := dev.GetControlValue(ctrlGain)
+		return value
+	}
+
+	// The first measurement picks the profile straight away
+	frame := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for i := range frame.Pix {
+		frame.Pix[i] = 20
+	}
+	measureSceneLuma(camera, frame)
+	start := time.Now()
+	updateDayNight(appData, start)
+	if camera.DayNight.Profile != "night" || gain() != 200 || camera.FrameInterval.Load() != int64(100*time.Millisecond) {
+		t.Fatalf("dark start: profile %q, gain %d", camera.DayNight.Profile, gain())
+	}
+
+	// Between the thresholds nothing changes; past the bright one only
+	// after the dwell
+	camera.DayNight.Luma = 70
+	updateDayNight(appData, start.Add(time.Minute))
+	camera.DayNight.Luma = 120
+	updateDayNight(appData, start.Add(2*time.Minute))
+	updateDayNight(appData, start.Add(2*time.Minute+dayNightDwell/2))
+	if camera.DayNight.Profile != "night" {
+		t.Fatalf("switched to %q before the dwell", camera.DayNight.Profile)
+	}
+	updateDayNight(appData, start.Add(2*time.Minute+dayNightDwell))
+	if camera.DayNight.Profile != "day" || gain() != 0 || camera.FrameInterval.Load() != 0 {
+		t.Errorf("bright scene: profile %q, gain %d", camera.DayNight.Profile, gain())
+	}
+}
+
+func TestSkipForRate(t *testing.T) {
+	camera := &CameraInstance{}
+	now := time.Now()
+	if skipForRate(camera, now) {
+		t.Error("skipped without a cap")
+	}
+	setMaxFPS(camera, 10)
+	kept := 0
+	for i := range 30 {
+		// Frames at 30 fps
+		if !skipForRate(camera, now.Add(time.Duration(i)*time.Second/30)) {
+			kept++
+		}
+	}
+	if kept != 10 {
+		t.Errorf("kept %d of a second at 30 fps capped at 10", kept)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// With -debug-http the app serves the Go profiler under /debug/pprof/ and
+// counters under /debug/vars, for looking into long runs, e.g.
+//
+//	go tool pprof http://host:6060/debug/pprof/heap
+//
+// The address should not be reachable from untrusted networks. The command
+// line is served with its passwords, tokens and keys redacted, as in support
+// bundles, both from the profiler and in the cmdline counter.
+
+// debugVarsInterval is how often the main loop refreshes the camera counters
+const debugVarsInterval = time.Second
+
+// cameraVars are the counters of a camera published under /debug/vars
+type cameraVars struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Active    bool   `json:"active"`
+	Dropped   uint64 `json:"dropped_frames"`
+	Lost      uint64 `json:"lost_frames"`
+	LastFrame int    `json:"last_frame_bytes"`
+	Recording bool   `json:"recording"`
+}
+
+var (
+	// framesReceived counts the frames the main loop took from each camera,
+	// by device path
+	framesReceived = expvar.NewMap("frames_received")
+
+	debugCameras     atomic.Pointer[[]cameraVars]
+	debugRestarts    atomic.Int64
+	debugVarsUpdated time.Time
+)
+
+func init() {
+	expvar.Publish("cameras", expvar.Func(func() any {
+		if cameras := debugCameras.Load(); cameras != nil {
+			return *cameras
+		}
+		return []cameraVars{}
+	}))
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("watchdog_restarts", expvar.Func(func() any { return debugRestarts.Load() }))
+	expvar.Publish("version", expvar.Func(func() any { return currentBuild() }))
+}
+
+// listenDebug serves the profiler and counters on address
+func listenDebug(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for debugging: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", handleDebugCmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", handleDebugVars)
+
+	log.Printf("Serving pprof and expvar on %s", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("Debug server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// handleDebugCmdline serves the redacted command line the way the
+// profiler does, with the arguments separated by NUL bytes
+func handleDebugCmdline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, strings.Join(redactedArgs(os.Args), "\x00"))
+}
+
+// handleDebugVars serves the counters like expvar.Handler, but with the
+// command line expvar publishes redacted
+func handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+		value := kv.Value.String()
+		if kv.Key == "cmdline" {
+			data, _ := json.Marshal(redactedArgs(os.Args))
+			value = string(data)
+		}
+		fmt.Fprintf(w, "%q: %s", kv.Key, value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
+
+// updateDebugVars publishes the counters of the cameras, which belong to the
+// main loop, for the debug server
+func updateDebugVars(appData *CameraAppData) {
+	if time.Since(debugVarsUpdated) < debugVarsInterval {
+		return
+	}
+	debugVarsUpdated = time.Now()
+	cameras := make([]cameraVars, len(appData.Cameras))
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		cameras[i] = cameraVars{
+			Name:      camera.Info.Name,
+			Path:      camera.Info.Path,
+			Active:    camera.Active,
+			Dropped:   atomic.LoadUint64(&camera.DroppedFrames),
+			Lost:      atomic.LoadUint64(&camera.LostFrames),
+			LastFrame: len(camera.LastFrame),
+			Recording: camera.Recorder != nil,
+		}
+	}
+	debugCameras.Store(&cameras)
+	debugRestarts.Store(int64(watchdog.Restarts))
+}
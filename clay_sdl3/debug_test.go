@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebugCmdlineRedacted(t *testing.T) {
+	saved := os.Args
+	t.Cleanup(func() { os.Args = saved })
+	os.Args = []string{"camapp", "-upload", "s3://KEY:SECRET@minio/bucket", "-kiosk-pin=2468", "-resolution=1280x720"}
+
+	w := httptest.NewRecorder()
+	handleDebugCmdline(w, httptest.NewRequest("GET", "/debug/pprof/cmdline", nil))
+	if body := w.Body.String(); strings.Contains(body, "SECRET") || strings.Contains(body, "2468") || !strings.Contains(body, "-resolution=1280x720") {
+		t.Errorf("cmdline %q", body)
+	}
+
+	w = httptest.NewRecorder()
+	handleDebugVars(w, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars struct {
+		Cmdline []string `json:"cmdline"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if joined := strings.Join(vars.Cmdline, " "); len(vars.Cmdline) != len(os.Args) || strings.Contains(joined, "SECRET") || strings.Contains(joined, "2468") {
+		t.Errorf("cmdline var %q", vars.Cmdline)
+	}
+}
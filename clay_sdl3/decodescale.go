@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// Adaptive decoding converts frames at the size they are shown at rather
+// than the size the camera sends: a 1080p camera drawn in a 400 pixel main
+// view or only as a thumbnail is converted to RGBA at half or a quarter of
+// its size, which also shrinks the processing stages and the texture
+// upload. Go's JPEG decoder has no DCT scaling, so the scaling happens when
+// the decoded YCbCr image is converted, averaging the luma of each block.
+// Snapshots and recordings keep the original frames, and whatever works in
+// frame coordinates - measuring, calibration, keystone, metering, stacks,
+// zoom - asks for the full size.
+
+// adaptiveDecode is set with -adaptive-decode
+var adaptiveDecode = true
+
+// decodeScales are the factors frames may be scaled down by, largest first
+var decodeScales = []int{4, 2}
+
+// thumbnailScale is how much smaller thumbnails are than their frames, as
+// scaleImage makes them
+const thumbnailScale = 4
+
+// decodeSize is the size a camera's frames need to be decoded at to look
+// sharp where they are shown, zero for their full size
+func decodeSize(appData *CameraAppData, index int) image.Point {
+	camera := &appData.Cameras[index]
+	if !adaptiveDecode || camera.PopOut != nil || mosaic != nil ||
+		camera.Keystone != nil || camera.Metering != nil || camera.Stack != nil || camera.Bracket != nil || camera.Baseline != nil ||
+		camera.Denoise != nil || camera.WhiteBalanceSample != nil || camera.PixelsPerMM != 0 {
+		return image.Point{}
+	}
+
+	var need image.Point
+	if index == appData.SelectedCamera {
+		if measuring() || follower.Active || touchMode.Zoom > 1 || overlays.Grid != gridOff {
+			return image.Point{}
+		}
+		rect, ok := mainCameraRect()
+		if !ok {
+			return image.Point{}
+		}
+		need = image.Pt(int(rect.W+0.5), int(rect.H+0.5))
+	}
+	if dualView.Enabled && dualView.Other.Camera == index {
+		if dualView.Other.Zoom > 1 || dualView.Other.Overlays.Grid != gridOff {
+			return image.Point{}
+		}
+		if rect, ok := otherPaneRect(); ok {
+			need.X = max(need.X, int(rect.W+0.5))
+			need.Y = max(need.Y, int(rect.H+0.5))
+		}
+	}
+	thumbnail := clay.GetElementData(SafeID(fmt.Sprintf("Thumbnail%d", index)))
+	if thumbnail.Found {
+		bbox := thumbnail.BoundingBox
+		need.X = max(need.X, int(bbox.Width+0.5)*thumbnailScale)
+		need.Y = max(need.Y, int(bbox.Height+0.5)*thumbnailScale)
+	}
+	if need.X <= 0 || need.Y <= 0 {
+		// Neither view was laid out yet
+		return image.Point{}
+	}
+	return need
+}
+
+// decodeScale is the largest factor a frame of the given size can be scaled
+// down by and still cover need, 1 if it can't
+func decodeScale(size, need image.Point) int {
+	if need.X <= 0 || need.Y <= 0 {
+		return 1
+	}
+	for _, scale := range decodeScales {
+		if size.X/scale >= need.X && size.Y/scale >= need.Y {
+			return scale
+		}
+	}
+	return 1
+}
+
+// ycbcrToRGBAScaled converts a YCbCr image to RGBA scaled down by scale,
+// averaging the luma of each block and taking the chroma at its corner
+func ycbcrToRGBAScaled(src *image.YCbCr, scale int) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := bounds.Dx()/scale, bounds.Dy()/scale
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	area := scale * scale
+	for y := range height {
+		sy := bounds.Min.Y + y*scale
+		row := dst.Pix[y*dst.Stride:]
+		for x := range width {
+			sx := bounds.Min.X + x*scale
+			sum := 0
+			for by := range scale {
+				yi := src.YOffset(sx, sy+by)
+				for _, luma := range src.Y[yi : yi+scale] {
+					sum += int(luma)
+				}
+			}
+			ci := src.COffset(sx, sy)
+			b, r := int32(src.Cb[ci])-128, int32(src.Cr[ci])-128
+			red, green, blue := yuvTerm(r, yuvRedCr), -(yuvTerm(b, yuvGreenCb) + yuvTerm(r, yuvGreenCr)), yuvTerm(b, yuvBlueCb)
+			yuvPixel(row[x*4:x*4+4:x*4+4], uint8((sum+area/2)/area), red, green, blue)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestYCbCrToRGBAScaled(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, ratio := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio444} {
+		img := randomYCbCr(rng, 64, 48, ratio)
+		// Luma that is even across each 4x4 block averages to itself
+		for y := range 48 {
+			for x := range 64 {
+				img.Y[img.YOffset(x, y)] = img.Y[img.YOffset(x&^3, y&^3)]
+			}
+		}
+		whole := toRGBA(img)
+		for _, scale := range []int{2, 4} {
+			got := ycbcrToRGBAScaled(img, scale)
+			if size := got.Bounds().Size(); size != image.Pt(64/scale, 48/scale) {
+				t.Fatalf("%v scaled by %d is %v", ratio, scale, size)
+			}
+			for y := range 48 / scale {
+				for x := range 64 / scale {
+					// 4:4:4 is converted by the image package, within 1
+					p, q := got.RGBAAt(x, y), whole.RGBAAt(x*scale, y*scale)
+					if max(absDiff(p.R, q.R), absDiff(p.G, q.G), absDiff(p.B, q.B)) > 1 {
+						t.Fatalf("%v scaled by %d: pixel %d,%d differs from the full conversion", ratio, scale, x, y)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeScale(t *testing.T) {
+	hd := image.Pt(1920, 1080)
+	for _, c := range []struct {
+		need image.Point
+		want int
+	}{
+		{image.Point{}, 1},
+		{image.Pt(400, 225), 4},
+		{image.Pt(480, 270), 4},
+		{image.Pt(481, 270), 2},
+		{image.Pt(800, 600), 1},
+		{image.Pt(1920, 1080), 1},
+		{image.Pt(3840, 2160), 1},
+	} {
+		if got := decodeScale(hd, c.need); got != c.want {
+			t.Errorf("scale for %v is %d, want %d", c.need, got, c.want)
+		}
+	}
+}
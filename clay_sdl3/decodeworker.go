@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"runtime"
+	"sync"
+)
+
+// With -async-decode, frames are decoded to RGBA on worker goroutines, so a
+// burst of large JPEGs no longer holds up the main loop until it misses a
+// present. SDL renderers may only be used from the thread that created
+// them, so the upload itself stays on the main loop, which now only copies
+// a decoded frame into its texture. Each camera has one frame being decoded
+// and one decoded frame waiting for upload at most; newer frames replace
+// waiting ones, which count as dropped.
+//
+// The cameras of the main panes go first: their frames have a queue of
+// their own, which every worker takes from before the background queue of
+// the other cameras, and with more than one worker the first serves it
+// alone, so a frame of the main view never waits behind a batch of
+// thumbnails.
+
+// decodeQueue feeds background cameras with frames waiting to be decoded to
+// the workers, nil without -async-decode
+var decodeQueue chan *frameDecoder
+
+// priorityDecodeQueue feeds the cameras of the main panes to the workers
+var priorityDecodeQueue chan *frameDecoder
+
+// frameDecoder passes a camera's frames to the decode workers and back. A
+// camera is decoded by one worker at a time, so its frames stay in order.
+type frameDecoder struct {
+	mu sync.Mutex
+	// pending is the newest frame waiting to be decoded
+	pending *Frame
+	// queued is set while the camera is queued or being decoded
+	queued bool
+	// ready is the newest decoded frame waiting for upload, and err the
+	// error decoding it
+	ready *Frame
+	err   error
+	// superseded counts frames replaced before they were decoded or shown
+	superseded uint64
+}
+
+// startDecodeWorkers starts the goroutines that decode frames, leaving a
+// core to the main loop
+func startDecodeWorkers() {
+	workers := max(min(runtime.NumCPU()-1, 4), 1)
+	decodeQueue = make(chan *frameDecoder, 64)
+	priorityDecodeQueue = make(chan *frameDecoder, 64)
+	for i := range workers {
+		if i == 0 && workers > 1 {
+			go func() {
+				for d := range priorityDecodeQueue {
+					d.decode()
+				}
+			}()
+			continue
+		}
+		go decodeWorker()
+	}
+	log.Printf("Decoding frames on %d worker goroutines", workers)
+}
+
+// decodeWorker decodes cameras from both queues, the selected one first
+func decodeWorker() {
+	for {
+		select {
+		case d := <-priorityDecodeQueue:
+			d.decode()
+			continue
+		default:
+		}
+		select {
+		case d := <-priorityDecodeQueue:
+			d.decode()
+		case d := <-decodeQueue:
+			d.decode()
+		}
+	}
+}
+
+// submit hands a frame to the workers, replacing one still waiting. Frames
+// of cameras in a main pane go to the priority queue.
+func (d *frameDecoder) submit(frame Frame, selected bool) {
+	d.mu.Lock()
+	if d.pending != nil {
+		d.superseded++
+	}
+	d.pending = &frame
+	queue := !d.queued
+	d.queued = true
+	d.mu.Unlock()
+	if !queue {
+		return
+	}
+	if selected {
+		priorityDecodeQueue <- d
+	} else {
+		decodeQueue <- d
+	}
+}
+
+// decode decodes the pending frame, and the next ones as long as more come
+// in meanwhile. It runs on a worker.
+func (d *frameDecoder) decode() {
+	for {
+		d.mu.Lock()
+		frame := d.pending
+		d.pending = nil
+		if frame == nil {
+			d.queued = false
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+
+		decoded, err := decodeImage(*frame)
+		frame.Decoded = decoded
+
+		d.mu.Lock()
+		if d.ready != nil {
+			d.superseded++
+		}
+		d.ready, d.err = frame, err
+		d.mu.Unlock()
+	}
+}
+
+// take returns the newest decoded frame, nil if there is none, and how many
+// frames were replaced since the last call
+func (d *frameDecoder) take() (*Frame, uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ready, superseded, err := d.ready, d.superseded, d.err
+	d.ready, d.superseded, d.err = nil, 0, nil
+	return ready, superseded, err
+}
+
+// decodeImage decodes a frame to RGBA, unless the source delivered it
+// decoded
+func decodeImage(frame Frame) (*image.RGBA, error) {
+	if frame.Decoded != nil {
+		return frame.Decoded, nil
+	}
+	img := frame.Raw
+	if img == nil {
+		var err error
+		img, err = jpeg.Decode(io.NewSectionReader(bytes.NewReader(frame.Data), 0, int64(len(frame.Data))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame: %w", err)
+		}
+	}
+	if ycbcr, ok := img.(*image.YCbCr); ok {
+		if scale := decodeScale(ycbcr.Bounds().Size(), frame.DecodeTo); scale > 1 {
+			return ycbcrToRGBAScaled(ycbcr, scale), nil
+		}
+	}
+	return toRGBA(img), nil
+}
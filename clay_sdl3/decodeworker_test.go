@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDecodeWorkers(t *testing.T) {
+	data, err := os.ReadFile("640x480.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decodeQueue == nil {
+		startDecodeWorkers()
+	}
+
+	d := &frameDecoder{}
+	const frames = 5
+	for i := range frames {
+		d.submit(Frame{Data: data, Captured: time.Unix(int64(i), 0)}, i%2 == 0)
+	}
+	var last *Frame
+	var shown, superseded uint64
+	deadline := time.Now().Add(5 * time.Second)
+	for last == nil || last.Captured.Unix() != frames-1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the newest frame was not decoded")
+		}
+		ready, dropped, err := d.take()
+		if err != nil {
+			t.Fatal(err)
+		}
+		superseded += dropped
+		if ready != nil {
+			if ready.Decoded == nil || ready.Decoded.Bounds().Dx() != 640 {
+				t.Fatalf("frame %d not decoded to 640 pixels wide", ready.Captured.Unix())
+			}
+			if last != nil && !ready.Captured.After(last.Captured) {
+				t.Errorf("frame %d came after frame %d", ready.Captured.Unix(), last.Captured.Unix())
+			}
+			last = ready
+			shown++
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Every frame is either shown or counted as dropped
+	if shown+superseded != frames {
+		t.Errorf("%d frames shown and %d superseded of %d", shown, superseded, frames)
+	}
+
+	// A decoded frame is not decoded again
+	if img, err := decodeImage(*last); err != nil || img != last.Decoded {
+		t.Errorf("decoded frame decoded again: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/vladimirvivien/go4vl/device"
+	"github.com/vladimirvivien/go4vl/v4l2"
+	"golang.org/x/sys/unix"
+)
+
+// The app reaches V4L2 cameras through videoDevice rather than go4vl's
+// Device and the file descriptor, so format negotiation, capture and
+// recording can run against a fake device in tests.
+
+// videoDevice is an open V4L2 capture device
+type videoDevice interface {
+	Name() string
+	Close() error
+	Capability() v4l2.Capability
+
+	GetFormatDescriptions() ([]v4l2.FormatDescription, error)
+	GetPixFormat() (v4l2.PixFormat, error)
+	SetPixFormat(format v4l2.PixFormat) error
+	SetFrameRate(fps uint32) error
+	GetStreamParam() (v4l2.StreamParam, error)
+	// FrameSizes lists the frame sizes of a format
+	FrameSizes(format v4l2.FourCCType) ([]v4l2.FrameSizeEnum, error)
+	// FrameInterval returns a format's index'th frame interval at a size
+	FrameInterval(index uint32, format v4l2.FourCCType, width, height uint32) (v4l2.FrameIntervalEnum, error)
+
+	GetCropCapability() (v4l2.CropCapability, error)
+	SetCropRect(rect v4l2.Rect) error
+	// GetSelection and SetSelection run VIDIOC_G_SELECTION and
+	// VIDIOC_S_SELECTION
+	GetSelection(selection *v4l2Selection) error
+	SetSelection(selection *v4l2Selection) error
+
+	QueryControls() ([]v4l2.Control, error)
+	QueryControl(id v4l2.CtrlID) (v4l2.Control, error)
+	// GetControl returns a control with its current value
+	GetControl(id v4l2.CtrlID) (v4l2.Control, error)
+	GetControlValue(id v4l2.CtrlID) (v4l2.CtrlValue, error)
+	SetControlValue(id v4l2.CtrlID, value v4l2.CtrlValue) error
+
+	// Stream starts streaming at the current format, see streamDevice
+	Stream(ctx context.Context, camera *CameraInstance) (<-chan []byte, error)
+}
+
+// openDevice opens the V4L2 device at path; tests replace it with a fake
+var openDevice = func(path string) (videoDevice, error) {
+	dev, err := device.Open(path, device.WithIOType(v4l2.IOTypeMMAP))
+	if err != nil {
+		return nil, err
+	}
+	return v4l2Device{dev}, nil
+}
+
+// v4l2Device is a videoDevice backed by go4vl
+type v4l2Device struct {
+	*device.Device
+}
+
+func (d v4l2Device) FrameSizes(format v4l2.FourCCType) ([]v4l2.FrameSizeEnum, error) {
+	return v4l2.GetFormatFrameSizes(d.Fd(), format)
+}
+
+func (d v4l2Device) FrameInterval(index uint32, format v4l2.FourCCType, width, height uint32) (v4l2.FrameIntervalEnum, error) {
+	return v4l2.GetFormatFrameInterval(d.Fd(), index, format, width, height)
+}
+
+func (d v4l2Device) GetSelection(selection *v4l2Selection) error {
+	return d.selectionIoctl(vidiocGetSelection, selection)
+}
+
+func (d v4l2Device) SetSelection(selection *v4l2Selection) error {
+	return d.selectionIoctl(vidiocSetSelection, selection)
+}
+
+func (d v4l2Device) selectionIoctl(request uintptr, selection *v4l2Selection) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, d.Fd(), request, uintptr(unsafe.Pointer(selection)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (d v4l2Device) QueryControls() ([]v4l2.Control, error) {
+	return v4l2.QueryAllControls(d.Fd())
+}
+
+func (d v4l2Device) QueryControl(id v4l2.CtrlID) (v4l2.Control, error) {
+	return v4l2.QueryControlInfo(d.Fd(), id)
+}
+
+func (d v4l2Device) GetControl(id v4l2.CtrlID) (v4l2.Control, error) {
+	return v4l2.GetControl(d.Fd(), id)
+}
+
+func (d v4l2Device) GetControlValue(id v4l2.CtrlID) (v4l2.CtrlValue, error) {
+	return v4l2.GetControlValue(d.Fd(), id)
+}
+
+func (d v4l2Device) SetControlValue(id v4l2.CtrlID, value v4l2.CtrlValue) error {
+	return v4l2.SetControlValue(d.Fd(), id, value)
+}
+
+func (d v4l2Device) Stream(ctx context.Context, camera *CameraInstance) (<-chan []byte, error) {
+	return streamDevice(ctx, d.Device, camera)
+}
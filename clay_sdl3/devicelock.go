@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// A V4L2 camera streams to one process at a time, and a second instance of
+// the app started by mistake used to fail on every camera with a bare
+// "device or resource busy". Each instance now holds a lock per camera
+// while it has the device open, a file in deviceLockDir with its PID, so a
+// second instance reports which PID has the camera and retries it as the
+// start retries go, picking it up once the first lets go. Started with
+// -take-over, it asks the holder to hand the cameras it opens at startup
+// over instead, before the window shows anything: the holder disables the
+// camera as if it had been switched off and says so in its status bar.
+// Cameras opened later, from the main loop, never wait for a hand-over.
+// Cameras busy in another program are reported with that program's PID and
+// name, found in /proc.
+//
+// deviceLockDir is writable by every user, so nothing in it is followed
+// through a symbolic link, and take-over requests count only from the
+// holder's own user.
+
+// deviceLockDir holds the lock files, shared by the instances of all users
+var deviceLockDir = filepath.Join(os.TempDir(), "camapp-locks")
+
+// procRoot is where the processes are listed
+var procRoot = "/proc"
+
+// takeOver asks the instances holding cameras to hand them over, set with
+// -take-over and cleared once the cameras have started
+var takeOver bool
+
+const (
+	// takeOverTimeout is how long a camera's holder gets to hand it over
+	takeOverTimeout = 5 * time.Second
+	// takeOverPoll is how often the lock is tried while waiting for it
+	takeOverPoll = 100 * time.Millisecond
+	// takeOverCheck is how often a holder looks for requests
+	takeOverCheck = 250 * time.Millisecond
+	// lockPIDWait is how long the PID of a lock just taken may take to
+	// appear in its file
+	lockPIDWait = 50 * time.Millisecond
+)
+
+// lockPIDFormat is how the holder's PID is written, at a fixed width so a
+// single write replaces any PID there
+const lockPIDFormat = "%10d\n"
+
+// deviceLock is the lock of a camera, held until released
+type deviceLock struct {
+	file *os.File
+	// request is the file another instance writes its PID to for the camera
+	request string
+	once    sync.Once
+}
+
+// deviceInUseError tells who has a camera: another instance of the app,
+// holding its lock, or another program, with the device open
+type deviceInUseError struct {
+	Path string
+	PID  int
+	// Command is the name of the other program, "" for an instance
+	Command string
+	Err     error
+}
+
+func (e *deviceInUseError) Error() string {
+	if e.Command == "" {
+		return tr("error.device_locked", e.Path, e.PID)
+	}
+	return tr("error.device_busy", e.Path, e.PID, e.Command)
+}
+
+func (e *deviceInUseError) Unwrap() error {
+	return e.Err
+}
+
+// lockPath returns the lock file of a device
+func lockPath(device string) string {
+	return filepath.Join(deviceLockDir, strings.ReplaceAll(strings.Trim(device, "/"), "/", "_")+".lock")
+}
+
+// lockDevice takes the lock of a device, failing with a deviceInUseError if
+// another instance holds it
+func lockDevice(device string) (*deviceLock, error) {
+	if err := os.MkdirAll(deviceLockDir, 0o777); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(deviceLockDir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", deviceLockDir)
+	}
+	// Other users' instances must be able to lock it too
+	_ = os.Chmod(deviceLockDir, 0o777|os.ModeSticky)
+	name := lockPath(device)
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|unix.O_NOFOLLOW, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a lock file", name)
+	}
+	// The umask took the other users' write permission; only the owner
+	// can give it back
+	_ = file.Chmod(0o666)
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, &deviceInUseError{Path: device, PID: lockHolder(name), Err: err}
+		}
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(file, lockPIDFormat, os.Getpid()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &deviceLock{file: file, request: name + ".takeover"}, nil
+}
+
+// lockHolder returns the PID in a lock file, giving a holder that has just
+// taken the lock a moment to write it
+func lockHolder(name string) int {
+	deadline := time.Now().Add(lockPIDWait)
+	for {
+		data, _ := os.ReadFile(name)
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		if pid != 0 || time.Now().After(deadline) {
+			return pid
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// acquireDeviceLock takes the lock of a device and, with -take-over, asks
+// the instance holding it to hand it over
+func acquireDeviceLock(device string) (*deviceLock, error) {
+	lock, err := lockDevice(device)
+	var inUse *deviceInUseError
+	if !takeOver || !errors.As(err, &inUse) {
+		return lock, err
+	}
+	log.Printf("Asking PID %d to hand %s over", inUse.PID, device)
+	request := lockPath(device) + ".takeover"
+	file, err := os.OpenFile(request, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|unix.O_NOFOLLOW, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.WriteString(strconv.Itoa(os.Getpid()))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	defer os.Remove(request)
+	if err != nil {
+		return nil, err
+	}
+	for deadline := time.Now().Add(takeOverTimeout); time.Now().Before(deadline); {
+		time.Sleep(takeOverPoll)
+		if lock, err = lockDevice(device); !errors.As(err, &inUse) {
+			return lock, err
+		}
+	}
+	return nil, err
+}
+
+// release lets go of the lock; it may be called more than once
+func (l *deviceLock) release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		_ = l.file.Truncate(0)
+		l.file.Close()
+	})
+}
+
+// lockedDevice is a device that holds its lock until it is closed
+type lockedDevice struct {
+	videoDevice
+	lock *deviceLock
+}
+
+func (d lockedDevice) Close() error {
+	err := d.videoDevice.Close()
+	d.lock.release()
+	return err
+}
+
+// lastTakeOverCheck is when handleTakeOverRequests last looked
+var lastTakeOverCheck time.Time
+
+// handleTakeOverRequests disables the cameras another instance asked for
+// with -take-over, which releases their locks
+func handleTakeOverRequests(appData *CameraAppData) {
+	if time.Since(lastTakeOverCheck) < takeOverCheck {
+		return
+	}
+	lastTakeOverCheck = time.Now()
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		locked, ok := camera.Device.(lockedDevice)
+		if !ok || !camera.Active {
+			continue
+		}
+		data, err := readTakeOverRequest(locked.lock.request)
+		if err != nil {
+			continue
+		}
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		log.Printf("Handing camera %s over to PID %d", camera.Info.Name, pid)
+		disableCamera(camera)
+		appData.StatusText = tr("status.taken_over", camera.Info.Name, pid)
+		appData.StatusColor = theme.Warning
+		notify(toastInfo, appData.StatusText)
+	}
+}
+
+// readTakeOverRequest reads a take-over request and removes it, leaving
+// the requests of other users and anything but plain files alone
+func readTakeOverRequest(request string) ([]byte, error) {
+	file, err := os.OpenFile(request, os.O_RDONLY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &stat); err != nil {
+		return nil, err
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFREG || int(stat.Uid) != os.Getuid() {
+		return nil, fmt.Errorf("%s was not written by this user", request)
+	}
+	if err := os.Remove(request); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
+
+// explainBusy names the program that has a device open when opening it
+// failed because it is busy
+func explainBusy(device string, err error) error {
+	if !errors.Is(err, unix.EBUSY) {
+		return err
+	}
+	for _, pid := range devicePIDs(device) {
+		if pid == os.Getpid() {
+			continue
+		}
+		command, _ := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "comm"))
+		return &deviceInUseError{Path: device, PID: pid, Command: strings.TrimSpace(string(command)), Err: err}
+	}
+	return err
+}
+
+// devicePIDs returns the processes with a device open, as far as they can
+// be seen
+func devicePIDs(device string) []int {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fds, err := os.ReadDir(filepath.Join(procRoot, entry.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(procRoot, entry.Name(), "fd", fd.Name())); err == nil && target == device {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	return pids
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDeviceLock(t *testing.T) {
+	defer func(dir string) { deviceLockDir = dir }(deviceLockDir)
+	deviceLockDir = t.TempDir()
+	lock, err := lockDevice("/dev/video7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(lockPath("/dev/video7")); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0o666 {
+		t.Errorf("lock file mode %v, want it writable by everyone", info.Mode())
+	}
+	_, err = lockDevice("/dev/video7")
+	var inUse *deviceInUseError
+	if !errors.As(err, &inUse) || inUse.PID != os.Getpid() || inUse.Command != "" {
+		t.Fatalf("second lock: %v", err)
+	}
+	if other, err := lockDevice("/dev/video8"); err != nil {
+		t.Errorf("other device: %v", err)
+	} else {
+		other.release()
+	}
+
+	lock.release()
+	lock.release()
+	again, err := lockDevice("/dev/video7")
+	if err != nil {
+		t.Fatalf("after release: %v", err)
+	}
+	again.release()
+}
+
+// TestDeviceLockSymlink plays another user planting links in the shared
+// lock directory
+func TestDeviceLockSymlink(t *testing.T) {
+	defer func(dir string) { deviceLockDir = dir }(deviceLockDir)
+	deviceLockDir = t.TempDir()
+	victim := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(victim, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(victim, lockPath("/dev/video7")); err != nil {
+		t.Fatal(err)
+	}
+	if lock, err := lockDevice("/dev/video7"); err == nil {
+		lock.release()
+		t.Error("locked through a symbolic link")
+	}
+	if err := os.Symlink(victim, lockPath("/dev/video8")+".takeover"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readTakeOverRequest(lockPath("/dev/video8") + ".takeover"); err == nil {
+		t.Error("take-over request read through a symbolic link")
+	}
+	if data, _ := os.ReadFile(victim); string(data) != "keep me" {
+		t.Errorf("linked file now holds %q", data)
+	}
+}
+
+// TestDeviceTakeOver plays the holder, which lets go once asked
+func TestDeviceTakeOver(t *testing.T) {
+	defer func(dir string) { deviceLockDir, takeOver = dir, false }(deviceLockDir)
+	deviceLockDir = t.TempDir()
+	held, err := lockDevice("/dev/video7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			if data, err := os.ReadFile(held.request); err == nil && string(data) == fmt.Sprint(os.Getpid()) {
+				held.release()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	takeOver = true
+	lock, err := acquireDeviceLock("/dev/video7")
+	if err != nil {
+		t.Fatalf("take over: %v", err)
+	}
+	lock.release()
+	if _, err := os.Stat(held.request); !os.IsNotExist(err) {
+		t.Error("take-over request left behind")
+	}
+}
+
+func TestExplainBusy(t *testing.T) {
+	procRoot = t.TempDir()
+	defer func() { procRoot = "/proc" }()
+	for pid, target := range map[string]string{"1234": "/dev/video3", "99": "/dev/null"} {
+		fd := filepath.Join(procRoot, pid, "fd")
+		if err := os.MkdirAll(fd, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, filepath.Join(fd, "5")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procRoot, pid, "comm"), []byte("ffmpeg\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := explainBusy("/dev/video3", fmt.Errorf("set format: %w", unix.EBUSY))
+	var inUse *deviceInUseError
+	if !errors.As(err, &inUse) || inUse.PID != 1234 || inUse.Command != "ffmpeg" || !errors.Is(err, unix.EBUSY) {
+		t.Fatalf("got %v", err)
+	}
+	other := errors.New("no such format")
+	if err := explainBusy("/dev/video3", other); err != other {
+		t.Errorf("other error became %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// The dual view shows two main panes side by side, each with its own
+// camera, zoom and overlays, for two operators sharing a screen. One pane
+// has the focus: it is the one selection, zoom, overlays, measuring and
+// everything else that works on "the main view" apply to, and it keeps its
+// state where the single view does. The other pane's state is kept aside
+// and swapped in when the focus moves, by clicking the pane or with Tab, so
+// the rest of the app never needs to know which pane it is acting on.
+
+// PaneView is the state of a main pane without the focus
+type PaneView struct {
+	Camera           int
+	Zoom             float32
+	CenterX, CenterY float32
+	Overlays         Overlays
+}
+
+// DualView is the layout of the main panes
+type DualView struct {
+	Enabled bool
+	// Focus is the pane with the focus, 0 for the left and 1 for the right
+	Focus int
+	// Other is the state of the pane without the focus
+	Other PaneView
+}
+
+var dualView DualView
+
+// paneID is the element ID of a main pane; the single view only has pane 0
+func paneID(pane int) clay.ElementId {
+	if pane == 0 {
+		return SafeID("MainCameraContainer")
+	}
+	return SafeID(fmt.Sprintf("MainCameraContainer%d", pane))
+}
+
+// focusedPaneID is the element ID of the pane with the focus
+func focusedPaneID() clay.ElementId {
+	if !dualView.Enabled {
+		return paneID(0)
+	}
+	return paneID(dualView.Focus)
+}
+
+// toggleDualView splits the main view into two panes, the new one showing
+// the camera after the selected one, or joins them again
+func toggleDualView(appData *CameraAppData) {
+	if dualView.Enabled {
+		if dualView.Focus != 0 {
+			dualView.swap(appData)
+		}
+		dualView.Enabled = false
+		appData.StatusText = tr("status.dual_off")
+		return
+	}
+	camera := 0
+	if len(appData.Cameras) > 0 {
+		camera = (appData.SelectedCamera + 1) % len(appData.Cameras)
+	}
+	dualView = DualView{
+		Enabled: true,
+		Other:   PaneView{Camera: camera, Zoom: 1, CenterX: 0.5, CenterY: 0.5, Overlays: Overlays{GridPixels: 50, GridMM: 1}},
+	}
+	appData.StatusText = tr("status.dual")
+}
+
+// swap moves the focus to the other pane, trading the focused state for the
+// state kept aside
+func (d *DualView) swap(appData *CameraAppData) {
+	other := d.Other
+	d.Other = PaneView{
+		Camera:   appData.SelectedCamera,
+		Zoom:     touchMode.Zoom,
+		CenterX:  touchMode.CenterX,
+		CenterY:  touchMode.CenterY,
+		Overlays: overlays,
+	}
+	appData.SelectedCamera = other.Camera
+	touchMode.Zoom, touchMode.CenterX, touchMode.CenterY = other.Zoom, other.CenterX, other.CenterY
+	overlays = other.Overlays
+	d.Focus = 1 - d.Focus
+}
+
+// switchPaneFocus moves the focus to the other pane of the dual view
+func switchPaneFocus(appData *CameraAppData) {
+	if !dualView.Enabled {
+		return
+	}
+	follower.Active = false
+	dualView.swap(appData)
+}
+
+// handlePaneFocusClick moves the focus to the pane without it when that is
+// clicked, and reports whether it did
+func handlePaneFocusClick(appData *CameraAppData, x, y float32) bool {
+	if !dualView.Enabled || !pointInElement(paneID(1-dualView.Focus), x, y) {
+		return false
+	}
+	switchPaneFocus(appData)
+	return true
+}
+
+// shownInPane reports whether a camera is shown in a main pane
+func shownInPane(appData *CameraAppData, index int) bool {
+	return index == appData.SelectedCamera || dualView.Enabled && dualView.Other.Camera == index
+}
+
+// otherPaneRect returns where the pane without the focus is drawn, from the
+// last layout
+func otherPaneRect() (sdl.FRect, bool) {
+	if !dualView.Enabled {
+		return sdl.FRect{}, false
+	}
+	return paneRect(paneID(1 - dualView.Focus))
+}
+
+// renderOtherPane draws the pane without the focus, with its own zoom and
+// overlays, by briefly giving it the focus
+func renderOtherPane(appData *CameraAppData) {
+	if !dualView.Enabled {
+		return
+	}
+	dualView.swap(appData)
+	renderMainCameraView(appData)
+	renderOverlays(appData)
+	dualView.swap(appData)
+}
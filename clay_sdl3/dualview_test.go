@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestDualViewSwap(t *testing.T) {
+	defer func() {
+		dualView = DualView{}
+		touchMode.resetZoom()
+		overlays = Overlays{GridPixels: 50, GridMM: 1}
+	}()
+	appData := &CameraAppData{Cameras: make([]CameraInstance, 3), SelectedCamera: 2}
+	touchMode.Zoom, touchMode.CenterX, touchMode.CenterY = 2, 0.3, 0.6
+	overlays.CenterMark = true
+
+	toggleDualView(appData)
+	if !dualView.Enabled || dualView.Other.Camera != 0 || dualView.Other.Zoom != 1 {
+		t.Fatalf("second pane is %+v", dualView.Other)
+	}
+	if !shownInPane(appData, 0) || !shownInPane(appData, 2) || shownInPane(appData, 1) {
+		t.Error("cameras in the panes are not the selected and the new one")
+	}
+
+	// The focus takes its pane's own state along
+	switchPaneFocus(appData)
+	if appData.SelectedCamera != 0 || touchMode.Zoom != 1 || overlays.CenterMark || dualView.Focus != 1 {
+		t.Errorf("focused pane shows camera %d at zoom %g", appData.SelectedCamera, touchMode.Zoom)
+	}
+	appData.SelectedCamera = 1
+	overlays.SafeAreas = true
+
+	// Joining the panes keeps the left one
+	toggleDualView(appData)
+	if dualView.Enabled || appData.SelectedCamera != 2 || touchMode.Zoom != 2 || touchMode.CenterX != 0.3 ||
+		!overlays.CenterMark || overlays.SafeAreas {
+		t.Errorf("single view shows camera %d at zoom %g, overlays %+v", appData.SelectedCamera, touchMode.Zoom, overlays)
+	}
+}
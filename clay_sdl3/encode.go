@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"strconv"
+	"strings"
+)
+
+// OutputEncoding re-encodes the frames that leave the app, recordings and
+// API streams, independently of what is captured and displayed. Quality 0
+// keeps the camera's JPEG; MaxWidth and MaxHeight (0 for no limit) shrink
+// larger frames, keeping their aspect ratio. Watermark, if set, is drawn
+// over them.
+type OutputEncoding struct {
+	Quality   int
+	MaxWidth  int
+	MaxHeight int
+	Watermark *Watermark
+}
+
+// outputEncoding is set with -output-quality and -output-size
+var outputEncoding OutputEncoding
+
+// configureOutputEncoding sets the output quality and the size limit, given
+// as "WIDTHxHEIGHT" where either side may be 0
+func configureOutputEncoding(quality int, size string) error {
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("invalid output quality %d, expected 1-100", quality)
+	}
+	outputEncoding.Quality = quality
+	if size == "" {
+		return nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(size), "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width < 0 || height < 0 {
+		return fmt.Errorf("invalid output size %q, expected WIDTHxHEIGHT", size)
+	}
+	outputEncoding.MaxWidth, outputEncoding.MaxHeight = width, height
+	return nil
+}
+
+// Enabled reports whether frames are re-encoded
+func (e OutputEncoding) Enabled() bool {
+	return e.Quality > 0 || e.MaxWidth > 0 || e.MaxHeight > 0 || e.Watermark != nil
+}
+
+// Encode returns the frame re-encoded with the output settings, or encoded
+// for the first time for raw sources. Frames that fail to decode are passed
+// on unchanged.
+func (e OutputEncoding) Encode(frame Frame) Frame {
+	img := frame.Raw
+	if img == nil {
+		var err error
+		if img, err = jpeg.Decode(bytes.NewReader(frame.Data)); err != nil {
+			return frame
+		}
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	scale := 1.0
+	if e.MaxWidth > 0 && width > e.MaxWidth {
+		scale = float64(e.MaxWidth) / float64(width)
+	}
+	if e.MaxHeight > 0 && float64(height)*scale > float64(e.MaxHeight) {
+		scale = float64(e.MaxHeight) / float64(height)
+	}
+	if scale < 1 || e.Watermark != nil {
+		// A copy, since raw frames are shared with the display
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+		if scale < 1 {
+			rgba = shrinkImage(rgba, max(int(float64(width)*scale), 1), max(int(float64(height)*scale), 1))
+		}
+		if e.Watermark != nil {
+			e.Watermark.Draw(rgba)
+		}
+		img = rgba
+	}
+
+	quality := e.Quality
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return frame
+	}
+	return Frame{Data: buf.Bytes(), Captured: frame.Captured}
+}
+
+// shrinkImage scales an image down by averaging the source pixels that fall
+// into each output pixel
+func shrinkImage(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := range height {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := range width {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i, v := range row {
+					sum[i%4] += int(v)
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			p := dst.Pix[y*dst.Stride+x*4:]
+			for c := range 4 {
+				p[c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
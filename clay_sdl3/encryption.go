@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Recordings and snapshots of cameras covering sensitive areas can be
+// encrypted at rest: with -encryption-key, a file holding a 256-bit key as
+// 64 hex digits (openssl rand -hex 32 makes one), every recording and
+// snapshot is written encrypted with AES-256-GCM under its usual name, and
+// the app decrypts them transparently wherever it reads them back: playback
+// with -play, watch folders and crash recovery. The sidecars stay readable,
+// since they only hold frame offsets, times and sensor readings.
+//
+// An encrypted file starts with encryptedHeader and a random nonce prefix,
+// followed by chunks of
+//
+//	length uint32 (big endian), sealed data
+//
+// each holding up to encryptedChunk bytes. A chunk's nonce is the prefix,
+// its number and a flag set only on the last chunk, so chunks cannot be
+// reordered, and a file cut off by a crash is told from a complete one.
+// Recordings seal a short chunk whenever they are flushed, so a crash loses
+// no more than it does unencrypted.
+
+const (
+	// encryptedHeader starts every encrypted file
+	encryptedHeader = "go-camApp encrypted 1\n"
+	// encryptedChunk is the most plain bytes a chunk holds
+	encryptedChunk = 64 * 1024
+	// encryptedPrefixSize is the random part of the nonces
+	encryptedPrefixSize = 7
+)
+
+// encryptionKey is the key files are encrypted with, nil to write them
+// plain, set with -encryption-key
+var encryptionKey []byte
+
+// loadEncryptionKey reads a hex encoded AES-256 key from path
+func loadEncryptionKey(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the encryption key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("%s does not hold a 256-bit key as 64 hex digits", path)
+	}
+	encryptionKey = key
+	return nil
+}
+
+// newGCM returns the AEAD of a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce is the nonce of chunk n
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, 0, encryptedPrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, n)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// outputFile is a recording or snapshot being written
+type outputFile interface {
+	io.WriteCloser
+	// Sync flushes what was written to disk
+	Sync() error
+}
+
+// createOutputFile creates a recording or snapshot, encrypted if a key is
+// set
+func createOutputFile(path string) (outputFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if encryptionKey == nil {
+		return file, nil
+	}
+	w, err := newEncryptedWriter(file, encryptionKey)
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeOutputFile writes a whole recording or snapshot, encrypted if a key
+// is set
+func writeOutputFile(path string, data []byte) error {
+	f, err := createOutputFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// encryptedWriter encrypts what is written to a file chunk by chunk
+type encryptedWriter struct {
+	file   *os.File
+	aead   cipher.AEAD
+	prefix []byte
+	chunks uint32
+	buf    []byte
+	err    error
+}
+
+func newEncryptedWriter(file *os.File, key []byte) (*encryptedWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	w := &encryptedWriter{file: file, aead: aead, prefix: make([]byte, encryptedPrefixSize)}
+	if _, err := rand.Read(w.prefix); err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(append([]byte(encryptedHeader), w.prefix...)); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// seal writes data as the next chunk
+func (w *encryptedWriter) seal(data []byte, last bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	sealed := w.aead.Seal(make([]byte, 4, 4+len(data)+w.aead.Overhead()), chunkNonce(w.prefix, w.chunks, last), data, nil)
+	binary.BigEndian.PutUint32(sealed, uint32(len(sealed)-4))
+	w.chunks++
+	_, w.err = w.file.Write(sealed)
+	return w.err
+}
+
+func (w *encryptedWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf = append(w.buf, p...)
+	for len(w.buf) > encryptedChunk {
+		if err := w.seal(w.buf[:encryptedChunk], false); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[encryptedChunk:]...)
+	}
+	return len(p), nil
+}
+
+// Sync seals what is buffered as a short chunk and flushes the file
+func (w *encryptedWriter) Sync() error {
+	if len(w.buf) > 0 {
+		if err := w.seal(w.buf, false); err != nil {
+			return err
+		}
+		w.buf = w.buf[:0]
+	}
+	return w.file.Sync()
+}
+
+// Close seals the last chunk and closes the file
+func (w *encryptedWriter) Close() error {
+	err := w.seal(w.buf, true)
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// inputFile is a recording or snapshot opened for reading, decrypted if it
+// was encrypted
+type inputFile interface {
+	io.ReaderAt
+	io.Closer
+	// Size is the number of plain bytes
+	Size() int64
+}
+
+// plainFile is an unencrypted input file
+type plainFile struct {
+	*os.File
+	size int64
+}
+
+func (f *plainFile) Size() int64 { return f.size }
+
+// sealedChunk locates a chunk of an encrypted file
+type sealedChunk struct {
+	// plain is the offset of the chunk's first plain byte
+	plain  int64
+	offset int64
+	size   int
+}
+
+// encryptedFile reads an encrypted file, decrypting a chunk at a time
+type encryptedFile struct {
+	file   *os.File
+	aead   cipher.AEAD
+	prefix []byte
+	chunks []sealedChunk
+	size   int64
+	// Complete is set if the last chunk was found, and unset for a file
+	// cut off by a crash, which reads up to its last whole chunk
+	Complete bool
+
+	mu     sync.Mutex
+	cached int
+	data   []byte
+}
+
+// openInputFile opens a recording or snapshot, decrypting it if it starts
+// with encryptedHeader
+func openInputFile(path string) (inputFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptedHeader)+encryptedPrefixSize)
+	n, _ := io.ReadFull(file, header)
+	if n < len(encryptedHeader) || string(header[:len(encryptedHeader)]) != encryptedHeader {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &plainFile{File: file, size: info.Size()}, nil
+	}
+	if encryptionKey == nil {
+		file.Close()
+		return nil, fmt.Errorf("%s is encrypted, give its key with -encryption-key", path)
+	}
+	f, err := readEncryptedFile(file, header[len(encryptedHeader):n], encryptionKey)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// readEncryptedFile indexes the chunks of an encrypted file, checking them
+func readEncryptedFile(file *os.File, prefix, key []byte) (*encryptedFile, error) {
+	if len(prefix) != encryptedPrefixSize {
+		return nil, errors.New("the encryption header is cut off")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	f := &encryptedFile{file: file, aead: aead, prefix: prefix, cached: -1}
+	offset := int64(len(encryptedHeader) + encryptedPrefixSize)
+	for !f.Complete {
+		var length [4]byte
+		if _, err := file.ReadAt(length[:], offset); err != nil {
+			break
+		}
+		size := int(binary.BigEndian.Uint32(length[:]))
+		if size < aead.Overhead() || size > encryptedChunk+aead.Overhead() {
+			break
+		}
+		sealed := make([]byte, size)
+		if _, err := file.ReadAt(sealed, offset+4); err != nil {
+			break
+		}
+		n := uint32(len(f.chunks))
+		plain, err := aead.Open(nil, chunkNonce(prefix, n, false), sealed, nil)
+		if err != nil {
+			if plain, err = aead.Open(nil, chunkNonce(prefix, n, true), sealed, nil); err != nil {
+				if n == 0 {
+					return nil, errors.New("wrong encryption key")
+				}
+				break
+			}
+			f.Complete = true
+		}
+		f.chunks = append(f.chunks, sealedChunk{plain: f.size, offset: offset + 4, size: size})
+		f.size += int64(len(plain))
+		offset += 4 + int64(size)
+	}
+	return f, nil
+}
+
+func (f *encryptedFile) Size() int64 { return f.size }
+
+func (f *encryptedFile) Close() error { return f.file.Close() }
+
+func (f *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	read := 0
+	for read < len(p) {
+		at := off + int64(read)
+		if at >= f.size {
+			return read, io.EOF
+		}
+		i := sort.Search(len(f.chunks), func(i int) bool { return f.chunks[i].plain > at }) - 1
+		if i != f.cached {
+			chunk := f.chunks[i]
+			sealed := make([]byte, chunk.size)
+			if _, err := f.file.ReadAt(sealed, chunk.offset); err != nil {
+				return read, err
+			}
+			last := f.Complete && i == len(f.chunks)-1
+			data, err := f.aead.Open(f.data[:0], chunkNonce(f.prefix, uint32(i), last), sealed, nil)
+			if err != nil {
+				return read, fmt.Errorf("chunk %d: %w", i, err)
+			}
+			f.cached, f.data = i, data
+		}
+		read += copy(p[read:], f.data[at-f.chunks[i].plain:])
+	}
+	return read, nil
+}
+
+// readInputFile reads a whole recording or snapshot, decrypting it if it was
+// encrypted
+func readInputFile(path string) ([]byte, error) {
+	f, err := openInputFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data := make([]byte, f.Size())
+	if _, err := f.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// isEncryptedFile reports whether a file starts with encryptedHeader
+func isEncryptedFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header := make([]byte, len(encryptedHeader))
+	_, err = io.ReadFull(file, header)
+	return err == nil && bytes.Equal(header, []byte(encryptedHeader))
+}
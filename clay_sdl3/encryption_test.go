@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withEncryptionKey encrypts the files written during a test
+func withEncryptionKey(t *testing.T) {
+	t.Helper()
+	encryptionKey = bytes.Repeat([]byte{0x42}, 32)
+	t.Cleanup(func() { encryptionKey = nil })
+}
+
+func TestEncryptedFile(t *testing.T) {
+	withEncryptionKey(t)
+	path := filepath.Join(t.TempDir(), "clip.mjpeg")
+	data := make([]byte, 3*encryptedChunk+1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	f, err := createOutputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A flush in between seals a short chunk
+	for _, part := range [][]byte{data[:5000], data[5000 : 2*encryptedChunk], data[2*encryptedChunk:]} {
+		if _, err := f.Write(part); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Sync(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, data[:64]) {
+		t.Fatal("the file holds plain data")
+	}
+
+	got, err := readInputFile(path)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read back %d bytes, %v", len(got), err)
+	}
+	in, err := openInputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := make([]byte, 300)
+	if _, err := in.ReadAt(part, encryptedChunk-100); err != nil || !bytes.Equal(part, data[encryptedChunk-100:encryptedChunk+200]) {
+		t.Errorf("read across chunks %v", err)
+	}
+	if n, err := in.ReadAt(part, int64(len(data))-10); n != 10 || err != io.EOF {
+		t.Errorf("read %d bytes at the end, %v", n, err)
+	}
+	if !in.(*encryptedFile).Complete {
+		t.Error("a closed file is not complete")
+	}
+	in.Close()
+
+	// A file cut off keeps its whole chunks, here all but the last 1000
+	// bytes and the empty last chunk
+	if err := os.WriteFile(path, raw[:len(raw)-50], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in, err = openInputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.(*encryptedFile).Complete || in.Size() != 3*encryptedChunk {
+		t.Errorf("cut off file complete %v with %d bytes", in.(*encryptedFile).Complete, in.Size())
+	}
+	in.Close()
+
+	// Changed data is refused
+	tampered := bytes.Clone(raw)
+	tampered[len(encryptedHeader)+encryptedPrefixSize+10] ^= 1
+	if err := os.WriteFile(path, tampered, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openInputFile(path); err == nil {
+		t.Error("a tampered file was read")
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	encryptionKey = bytes.Repeat([]byte{0x43}, 32)
+	if _, err := openInputFile(path); err == nil || !strings.Contains(err.Error(), "wrong encryption key") {
+		t.Errorf("read with the wrong key: %v", err)
+	}
+	encryptionKey = nil
+	if _, err := openInputFile(path); err == nil || !strings.Contains(err.Error(), "-encryption-key") {
+		t.Errorf("read without a key: %v", err)
+	}
+}
+
+func TestPlainInputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.jpg")
+	if err := writeOutputFile(path, []byte("jpeg")); err != nil {
+		t.Fatal(err)
+	}
+	if isEncryptedFile(path) {
+		t.Error("written encrypted without a key")
+	}
+	if data, err := readInputFile(path); err != nil || string(data) != "jpeg" {
+		t.Errorf("read %q, %v", data, err)
+	}
+}
+
+func TestLoadEncryptionKey(t *testing.T) {
+	t.Cleanup(func() { encryptionKey = nil })
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.key")
+	bad := filepath.Join(dir, "bad.key")
+	os.WriteFile(good, []byte(strings.Repeat("ab", 32)+"\n"), 0o600)
+	os.WriteFile(bad, []byte(strings.Repeat("ab", 16)), 0o600)
+	if err := loadEncryptionKey(good); err != nil || len(encryptionKey) != 32 || encryptionKey[0] != 0xab {
+		t.Errorf("loaded %x, %v", encryptionKey, err)
+	}
+	if err := loadEncryptionKey(bad); err == nil {
+		t.Error("a 128-bit key was accepted")
+	}
+}
+
+func TestRecordEncrypted(t *testing.T) {
+	t.Chdir(t.TempDir())
+	withEncryptionKey(t)
+	const frames = 8
+	camera := startFakeCamera(t, newFakeDevice(frames))
+	if err := startRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	path := camera.Recorder.Path
+	var written [][]byte
+	for i, frame := range receiveFrames(t, camera, frames) {
+		frame = encodeOutput(frame)
+		recordFrame(&CameraAppData{}, camera, frame)
+		written = append(written, frame.Data)
+		if i == frames/2-1 {
+			if err := camera.Recorder.file.Sync(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := stopRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	if !isEncryptedFile(path) {
+		t.Fatal("the recording is not encrypted")
+	}
+
+	// Playback reads the frames through the index
+	index, err := readRecordingIndex(path)
+	if err != nil || len(index) != frames {
+		t.Fatalf("index of %d frames, %v", len(index), err)
+	}
+	in, err := openInputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, frame := range index {
+		data := make([]byte, frame.size)
+		if _, err := in.ReadAt(data, frame.offset); err != nil || !bytes.Equal(data, written[i]) {
+			t.Errorf("frame %d read back wrong, %v", i, err)
+		}
+	}
+	in.Close()
+
+	// A recording cut off in its last chunk recovers to the frames in the
+	// chunks before, at least those flushed, sealed again
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	in, err = openInputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole := 0
+	for whole < frames && index[whole].offset+int64(index[whole].size) <= in.Size() {
+		whole++
+	}
+	in.Close()
+	recovered, err := recoverRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered != whole || recovered < frames/2 || recovered == frames {
+		t.Errorf("recovered %d frames, want %d", recovered, whole)
+	}
+	in, err = openInputFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if !in.(*encryptedFile).Complete {
+		t.Error("the recovered recording is not complete")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// GET /events is a WebSocket pushing what changes as JSON messages, so the
+// web UI and dashboards stay current without polling:
+//
+//	{"type": "cameras", "time": ..., "data": [camera, ...]}
+//	{"type": "camera", "time": ..., "data": camera}
+//	{"type": "recording", "time": ..., "data": {"id": 0, "recording": true, "path": "..."}}
+//	{"type": "motion", "time": ..., "data": {"id": 0, "name": "..."}}
+//	{"type": "stats", "time": ..., "data": {"ui_fps": 30, "cameras": [...], ...}}
+//
+// A client first gets the camera list, as from /api/cameras, and then a
+// camera message whenever one is renamed, goes offline or back, or changes
+// resolution. Motion is reported at most every apiMotionInterval per camera
+// and the frame rates every apiStatsInterval. A client that falls
+// apiEventQueue messages behind is disconnected, to reconnect and start over
+// from a fresh camera list. Browsers cannot set headers on a WebSocket, so
+// the web UI passes its token as ?token=.
+
+const (
+	// apiEventQueue is how many messages an event client may fall behind
+	apiEventQueue = 64
+	// apiEventWriteTimeout bounds sending a message to an event client
+	apiEventWriteTimeout = 10 * time.Second
+	// apiMotionInterval is how often motion is reported per camera
+	apiMotionInterval = 2 * time.Second
+	// apiStatsInterval is how often the frame rates are sent
+	apiStatsInterval = 5 * time.Second
+)
+
+// apiEvent is a message of the event stream
+type apiEvent struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// apiRecordingEvent tells that a camera started or stopped recording
+type apiRecordingEvent struct {
+	ID        int    `json:"id"`
+	Recording bool   `json:"recording"`
+	Path      string `json:"path,omitempty"`
+}
+
+// apiMotionEvent tells that a camera sees motion
+type apiMotionEvent struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// apiStats are the figures of the status bar
+type apiStats struct {
+	UIFPS     float64 `json:"ui_fps"`
+	DiskFree  uint64  `json:"disk_free"`
+	DiskTotal uint64  `json:"disk_total"`
+	// Clients counts the stream clients
+	Clients int              `json:"clients"`
+	Cameras []apiCameraStats `json:"cameras"`
+}
+
+type apiCameraStats struct {
+	ID            int     `json:"id"`
+	FPS           float64 `json:"fps"`
+	LostFrames    uint64  `json:"lost_frames"`
+	DroppedFrames uint64  `json:"dropped_frames"`
+}
+
+// eventClient is a connected event stream
+type eventClient struct {
+	events chan apiEvent
+}
+
+// subscribe adds an event client, which gets the camera list first
+func (s *APIServer) subscribe() *eventClient {
+	client := &eventClient{events: make(chan apiEvent, apiEventQueue)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client.events <- apiEvent{Type: "cameras", Time: time.Now(), Data: s.cameras}
+	s.eventClients[client] = true
+	s.listening.Store(int32(len(s.eventClients)))
+	return client
+}
+
+// unsubscribe removes an event client, ending its stream
+func (s *APIServer) unsubscribe(client *eventClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropEventClient(client)
+}
+
+// dropEventClient removes an event client with s.mu held
+func (s *APIServer) dropEventClient(client *eventClient) {
+	if s.eventClients[client] {
+		delete(s.eventClients, client)
+		close(client.events)
+		s.listening.Store(int32(len(s.eventClients)))
+	}
+}
+
+// publishEvent sends an event to every event client, dropping those that
+// fell behind
+func (s *APIServer) publishEvent(kind string, data any) {
+	event := apiEvent{Type: kind, Time: time.Now(), Data: data}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.eventClients {
+		select {
+		case client.events <- event:
+		default:
+			s.dropEventClient(client)
+		}
+	}
+}
+
+// Listening reports whether event clients are connected, so that motion is
+// detected for them
+func (s *APIServer) Listening() bool {
+	return s != nil && s.listening.Load() > 0
+}
+
+// publishChanges sends the events for the differences between the old and
+// the new camera list. It is called from the main loop.
+func (s *APIServer) publishChanges(appData *CameraAppData, old, cameras []apiCamera) {
+	if !s.Listening() {
+		return
+	}
+	if !slices.EqualFunc(old, cameras, func(a, b apiCamera) bool { return a.ID == b.ID }) {
+		s.publishEvent("cameras", cameras)
+		return
+	}
+	for i, camera := range cameras {
+		before := old[i]
+		if camera.Recording != before.Recording {
+			event := apiRecordingEvent{ID: camera.ID, Recording: camera.Recording}
+			if recorder := appData.Cameras[camera.ID].Recorder; recorder != nil {
+				event.Path = recorder.Path
+			}
+			s.publishEvent("recording", event)
+		}
+		// Frame counters change all the time and go out with the stats
+		before.Recording, before.LostFrames, before.DroppedFrames = camera.Recording, camera.LostFrames, camera.DroppedFrames
+		if before != camera {
+			s.publishEvent("camera", camera)
+		}
+	}
+}
+
+// publishStats sends the status bar's figures when due. It is called from
+// the main loop.
+func (s *APIServer) publishStats(cameras []apiCamera, now time.Time) {
+	if !s.Listening() || now.Sub(s.statsAt) < apiStatsInterval {
+		return
+	}
+	s.statsAt = now
+	stats := apiStats{
+		UIFPS:     statusBar.UIFPS,
+		DiskFree:  statusBar.DiskFree,
+		DiskTotal: statusBar.DiskTotal,
+		Clients:   s.Clients(),
+		Cameras:   make([]apiCameraStats, 0, len(cameras)),
+	}
+	for _, camera := range cameras {
+		cameraStats := apiCameraStats{ID: camera.ID, LostFrames: camera.LostFrames, DroppedFrames: camera.DroppedFrames}
+		if camera.ID < len(statusBar.Cameras) {
+			cameraStats.FPS = statusBar.Cameras[camera.ID].FPS
+		}
+		stats.Cameras = append(stats.Cameras, cameraStats)
+	}
+	s.publishEvent("stats", stats)
+}
+
+// Motion reports motion seen by a camera to the event clients, at most
+// every apiMotionInterval. It is called from the main loop.
+func (s *APIServer) Motion(id int, camera *CameraInstance, now time.Time) {
+	if !s.Listening() || isRemoteSource(camera) || now.Sub(s.motionAt[id]) < apiMotionInterval {
+		return
+	}
+	s.motionAt[id] = now
+	s.publishEvent("motion", apiMotionEvent{ID: id, Name: camera.Info.Name})
+}
+
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	server := websocket.Server{
+		// Pages of other sites would read the events with the
+		// credentials the browser keeps, or none when the API is open;
+		// clients that are not browsers send no Origin
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			if crossSite(r) {
+				return errors.New("cross-site event streams are refused")
+			}
+			return nil
+		},
+		Handler: s.serveEvents,
+	}
+	server.ServeHTTP(w, r)
+}
+
+// serveEvents sends the events to a WebSocket until either side closes it
+func (s *APIServer) serveEvents(ws *websocket.Conn) {
+	client := s.subscribe()
+	defer s.unsubscribe(client)
+	go func() {
+		// Clients send nothing but the close
+		_, _ = io.Copy(io.Discard, ws)
+		s.unsubscribe(client)
+	}()
+	for event := range client.events {
+		_ = ws.SetWriteDeadline(time.Now().Add(apiEventWriteTimeout))
+		if err := websocket.JSON.Send(ws, event); err != nil {
+			return
+		}
+	}
+}
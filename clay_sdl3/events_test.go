@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestEventStream(t *testing.T) {
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "bench"}, Active: true}}}
+	camera := &appData.Cameras[0]
+	s := newAPIServer()
+	s.Update(appData)
+	server := httptest.NewServer(s.routes())
+	t.Cleanup(server.Close)
+
+	if ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/events", "", "http://evil.example"); err == nil {
+		ws.Close()
+		t.Error("event stream opened from another site")
+	}
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/events", "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	receive := func(kind string, data any) {
+		t.Helper()
+		var event struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := websocket.JSON.Receive(ws, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != kind {
+			t.Fatalf("got a %s event, expected %s: %s", event.Type, kind, event.Data)
+		}
+		if err := json.Unmarshal(event.Data, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cameras []apiCamera
+	receive("cameras", &cameras)
+	if len(cameras) != 1 || cameras[0].Name != "bench" {
+		t.Fatalf("cameras %+v", cameras)
+	}
+
+	// Only changes are sent, the stats when due
+	camera.Offline = true
+	s.Update(appData)
+	var changed apiCamera
+	receive("camera", &changed)
+	if !changed.Offline {
+		t.Errorf("camera %+v", changed)
+	}
+	var stats apiStats
+	receive("stats", &stats)
+	if len(stats.Cameras) != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+
+	// Motion is reported once per apiMotionInterval
+	now := time.Now()
+	s.Motion(0, camera, now)
+	s.Motion(0, camera, now.Add(apiMotionInterval/2))
+	var motion apiMotionEvent
+	receive("motion", &motion)
+	if motion.Name != "bench" {
+		t.Errorf("motion %+v", motion)
+	}
+	camera.Info.Name = "lathe"
+	s.Update(appData)
+	receive("camera", &changed)
+	if changed.Name != "lathe" {
+		t.Errorf("renamed camera %+v", changed)
+	}
+}
+
+func TestEventClientFallingBehind(t *testing.T) {
+	s := newAPIServer()
+	client := s.subscribe()
+	if !s.Listening() {
+		t.Fatal("not listening with a client")
+	}
+	for range apiEventQueue {
+		s.publishEvent("motion", apiMotionEvent{})
+	}
+	if s.Listening() {
+		t.Error("a client falling behind was kept")
+	}
+	received := 0
+	for range client.events {
+		received++
+	}
+	if received != apiEventQueue {
+		t.Errorf("received %d events before the stream ended", received)
+	}
+}
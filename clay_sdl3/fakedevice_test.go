@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"sync"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// fakeDevice is a videoDevice that streams deterministic frames, for
+// testing capture without a camera
+type fakeDevice struct {
+	mu sync.Mutex
+	// Sizes are the discrete frame sizes offered per format
+	Sizes map[v4l2.FourCCType][][2]uint32
+	// Rates are the frame rates offered at every size, fastest first
+	Rates []uint32
+	// Refuse makes Stream fail at a mode, like a camera short of USB
+	// bandwidth
+	Refuse func(format v4l2.PixFormat, fps uint32) bool
+	// Frames is how many frames a stream delivers before it idles
+	Frames int
+	// Controls are the device's controls by ID
+	Controls map[v4l2.CtrlID]v4l2.Control
+
+	format v4l2.PixFormat
+	fps    uint32
+	closed bool
+}
+
+// newFakeDevice returns a device offering MJPEG and YUYV at 640x480 and
+// 320x240, at 30 and 15 fps
+func newFakeDevice(frames int) *fakeDevice {
+	sizes := [][2]uint32{{640, 480}, {320, 240}}
+	return &fakeDevice{
+		Sizes:  map[v4l2.FourCCType][][2]uint32{v4l2.PixelFmtMJPEG: sizes, v4l2.PixelFmtYUYV: sizes},
+		Rates:  []uint32{30, 15},
+		Frames: frames,
+		Controls: map[v4l2.CtrlID]v4l2.Control{
+			v4l2.CtrlBrightness: {ID: v4l2.CtrlBrightness, Type: v4l2.CtrlTypeInt, Name: "Brightness", Minimum: 0, Maximum: 255, Step: 1, Default: 128, Value: 128},
+		},
+	}
+}
+
+// useFakeDevice makes openDevice return dev for the rest of the test
+func useFakeDevice(t *testing.T, dev *fakeDevice) {
+	t.Helper()
+	open, locks := openDevice, deviceLockDir
+	openDevice = func(string) (videoDevice, error) { return dev, nil }
+	// Tests stop streams without closing the device, so the locks are
+	// left to their own directory
+	deviceLockDir = t.TempDir()
+	t.Cleanup(func() { openDevice, deviceLockDir = open, locks })
+}
+
+// fakeFrame returns the n'th frame of a stream: a gradient shifted by n, as
+// a JPEG or packed YUYV
+func fakeFrame(format v4l2.PixFormat, n int) []byte {
+	width, height := int(format.Width), int(format.Height)
+	if format.PixelFormat == v4l2.PixelFmtYUYV {
+		data := make([]byte, width*height*2)
+		for i := 0; i < len(data); i += 2 {
+			x := i / 2 % width
+			data[i], data[i+1] = byte(x+n), 128
+		}
+		return data
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetGray(x, y, color.Gray{Y: uint8(x + y + n)})
+		}
+	}
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	return buf.Bytes()
+}
+
+func (d *fakeDevice) Name() string { return "fake" }
+
+func (d *fakeDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return nil
+}
+
+func (d *fakeDevice) Capability() v4l2.Capability {
+	return v4l2.Capability{Driver: "fake", Card: "Fake Camera", BusInfo: "test"}
+}
+
+func (d *fakeDevice) GetFormatDescriptions() ([]v4l2.FormatDescription, error) {
+	var descriptions []v4l2.FormatDescription
+	for _, format := range []v4l2.FourCCType{v4l2.PixelFmtMJPEG, v4l2.PixelFmtYUYV, v4l2.PixelFmtGrey} {
+		if _, ok := d.Sizes[format]; ok {
+			descriptions = append(descriptions, v4l2.FormatDescription{Index: uint32(len(descriptions)), PixelFormat: format, Description: formatName(format)})
+		}
+	}
+	return descriptions, nil
+}
+
+func (d *fakeDevice) GetPixFormat() (v4l2.PixFormat, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.format, nil
+}
+
+func (d *fakeDevice) SetPixFormat(format v4l2.PixFormat) error {
+	if _, ok := d.Sizes[format.PixelFormat]; !ok {
+		return fmt.Errorf("format %s not offered", formatName(format.PixelFormat))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.format, d.fps = format, 0
+	return nil
+}
+
+func (d *fakeDevice) SetFrameRate(fps uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fps = fps
+	return nil
+}
+
+func (d *fakeDevice) GetStreamParam() (v4l2.StreamParam, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var param v4l2.StreamParam
+	if d.fps > 0 {
+		param.Capture.TimePerFrame = v4l2.Fract{Numerator: 1, Denominator: d.fps}
+	}
+	return param, nil
+}
+
+func (d *fakeDevice) FrameSizes(format v4l2.FourCCType) ([]v4l2.FrameSizeEnum, error) {
+	var sizes []v4l2.FrameSizeEnum
+	for i, size := range d.Sizes[format] {
+		sizes = append(sizes, v4l2.FrameSizeEnum{Index: uint32(i), Type: v4l2.FrameSizeTypeDiscrete, PixelFormat: format,
+			Size: v4l2.FrameSize{MinWidth: size[0], MaxWidth: size[0], MinHeight: size[1], MaxHeight: size[1]}})
+	}
+	return sizes, nil
+}
+
+func (d *fakeDevice) FrameInterval(index uint32, format v4l2.FourCCType, width, height uint32) (v4l2.FrameIntervalEnum, error) {
+	if int(index) >= len(d.Rates) {
+		return v4l2.FrameIntervalEnum{}, errors.New("no more intervals")
+	}
+	interval := v4l2.Fract{Numerator: 1, Denominator: d.Rates[index]}
+	return v4l2.FrameIntervalEnum{Index: index, PixelFormat: format, Width: width, Height: height,
+		Type: v4l2.FrameIntervalTypeDiscrete, Interval: v4l2.FrameInterval{Min: interval, Max: interval}}, nil
+}
+
+func (d *fakeDevice) GetCropCapability() (v4l2.CropCapability, error) {
+	return v4l2.CropCapability{}, errors.New("cropping not supported")
+}
+
+func (d *fakeDevice) SetCropRect(v4l2.Rect) error {
+	return errors.New("cropping not supported")
+}
+
+func (d *fakeDevice) GetSelection(*v4l2Selection) error {
+	return errors.New("cropping not supported")
+}
+
+func (d *fakeDevice) SetSelection(*v4l2Selection) error {
+	return errors.New("cropping not supported")
+}
+
+func (d *fakeDevice) QueryControls() ([]v4l2.Control, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var controls []v4l2.Control
+	for _, control := range d.Controls {
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
+
+func (d *fakeDevice) QueryControl(id v4l2.CtrlID) (v4l2.Control, error) {
+	return d.GetControl(id)
+}
+
+func (d *fakeDevice) GetControl(id v4l2.CtrlID) (v4l2.Control, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	control, ok := d.Controls[id]
+	if !ok {
+		return v4l2.Control{}, fmt.Errorf("no control %d", id)
+	}
+	return control, nil
+}
+
+func (d *fakeDevice) GetControlValue(id v4l2.CtrlID) (v4l2.CtrlValue, error) {
+	control, err := d.GetControl(id)
+	return control.Value, err
+}
+
+func (d *fakeDevice) SetControlValue(id v4l2.CtrlID, value v4l2.CtrlValue) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	control, ok := d.Controls[id]
+	if !ok {
+		return fmt.Errorf("no control %d", id)
+	}
+	if value < control.Minimum || value > control.Maximum {
+		return fmt.Errorf("value %d out of range", value)
+	}
+	control.Value = value
+	d.Controls[id] = control
+	return nil
+}
+
+// Stream delivers the device's Frames frames as fast as they are taken,
+// then idles until ctx is cancelled
+func (d *fakeDevice) Stream(ctx context.Context, camera *CameraInstance) (<-chan []byte, error) {
+	d.mu.Lock()
+	format, fps, refuse := d.format, d.fps, d.Refuse
+	d.mu.Unlock()
+	if refuse != nil && refuse(format, fps) {
+		return nil, errors.New("no space left on device")
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		for n := range d.Frames {
+			select {
+			case out <- fakeFrame(format, n):
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return out, nil
+}
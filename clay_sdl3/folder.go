@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// folderPrefix marks the path of a watch folder source
+	folderPrefix = "folder:"
+	// folderPollInterval is how often a watch folder is scanned
+	folderPollInterval = 200 * time.Millisecond
+	// folderJPEGQuality is used to re-encode images that are not JPEG
+	folderJPEGQuality = 95
+)
+
+// folderImageExtensions are the files a watch folder picks up
+var folderImageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// watchFolders are the directories given with -watch, each shown as a camera
+var watchFolders []string
+
+// isFolderSource reports whether a camera is a watch folder
+func isFolderSource(camera *CameraInstance) bool {
+	return strings.HasPrefix(camera.Info.Path, folderPrefix)
+}
+
+// findWatchFolders returns the watch folders as camera entries after the
+// devices
+func findWatchFolders(firstIndex int) []CameraInfo {
+	cameras := make([]CameraInfo, 0, len(watchFolders))
+	for i, dir := range watchFolders {
+		cameras = append(cameras, CameraInfo{
+			Path:  folderPrefix + dir,
+			Name:  tr("camera.folder", filepath.Base(filepath.Clean(dir))),
+			Index: firstIndex + i,
+		})
+	}
+	return cameras
+}
+
+// folderImage is a file seen while scanning a watch folder
+type folderImage struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// newestFolderImage returns the most recently modified image in dir. Hidden
+// files are skipped, since tools often write to one and rename it when done.
+func newestFolderImage(dir string) (folderImage, bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return folderImage{}, false, err
+	}
+	var newest folderImage
+	found := false
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !folderImageExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if !found || info.ModTime().After(newest.modTime) {
+			newest = folderImage{path: filepath.Join(dir, name), size: info.Size(), modTime: info.ModTime()}
+			found = true
+		}
+	}
+	return newest, found, nil
+}
+
+// readFolderFrame reads an image file as a JPEG frame, re-encoding other
+// formats
+func readFolderFrame(path string) ([]byte, error) {
+	data, err := readInputFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return data, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: folderJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// initFolderSource prepares a watch folder, sizing the textures after the
+// newest image in it
+func initFolderSource(camera *CameraInstance, renderer *sdl.Renderer) error {
+	dir := strings.TrimPrefix(camera.Info.Path, folderPrefix)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("watch folder %s is not a directory", dir)
+	}
+
+	camera.Width, camera.Height = 640, 480
+	if newest, ok, _ := newestFolderImage(dir); ok {
+		if data, err := readInputFile(newest.path); err == nil {
+			if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+				camera.Width, camera.Height = config.Width, config.Height
+			}
+		}
+	}
+	if err := createCameraTextures(camera, renderer); err != nil {
+		return err
+	}
+
+	camera.Active = true
+	camera.FrameChan = make(chan Frame, 10)
+	log.Printf("Watching %s for new images (%dx%d)", dir, camera.Width, camera.Height)
+	return nil
+}
+
+// captureFolderFrames sends the newest image of a watch folder whenever it
+// changes. An image is only read once its size and time stopped changing
+// between two scans, so files still being written are not shown half done.
+func captureFolderFrames(camera *CameraInstance) {
+	dir := strings.TrimPrefix(camera.Info.Path, folderPrefix)
+	var sent, pending folderImage
+	for camera.Active {
+		time.Sleep(folderPollInterval)
+		newest, ok, err := newestFolderImage(dir)
+		if err != nil {
+			log.Printf("Error scanning %s: %v", dir, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if !ok || newest == sent {
+			continue
+		}
+		if newest != pending {
+			pending = newest
+			continue
+		}
+
+		sent = newest
+		data, err := readFolderFrame(newest.path)
+		if err != nil {
+			log.Printf("Skipping %s: %v", newest.path, err)
+			continue
+		}
+		recordReplayFrame(camera, data, time.Now())
+		select {
+		case camera.FrameChan <- Frame{Data: data, Captured: newest.modTime}:
+		default:
+			atomic.AddUint64(&camera.DroppedFrames, 1)
+		}
+	}
+}
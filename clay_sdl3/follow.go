@@ -0,0 +1,183 @@
+package main
+
+import (
+	"image"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// followScale is the downsampling of the frames the follower searches
+	followScale = 2
+	// followTemplateSize is the size of the tracked patch, as a fraction of
+	// the smaller frame side
+	followTemplateSize = 0.12
+	// followZoom is the zoom applied when following starts unzoomed
+	followZoom = 2
+	// followLostSAD is the mean absolute difference per pixel above which
+	// the object counts as lost; followAdaptSAD is the one below which the
+	// template is updated to follow slow changes in the object's look
+	followLostSAD  = 30
+	followAdaptSAD = 12
+	// followSmoothing is how far the zoom window moves towards the object
+	// on each frame, to hide small jitter in the match
+	followSmoothing = 0.5
+)
+
+// ObjectFollower keeps the digital zoom of the main view centered on an
+// object. The patch around the object is matched in every frame of the
+// selected camera by the sum of absolute differences, searching around its
+// last position.
+type ObjectFollower struct {
+	Active bool
+	// X and Y are the object's center in normalized frame coordinates
+	X, Y float32
+	Lost bool
+
+	// pending is set until the template is cut from the next frame
+	pending  bool
+	template []uint8
+	size     int
+	luma     []uint8
+}
+
+var follower ObjectFollower
+
+// toggleFollow starts following the object under the pointer, or the one in
+// the middle of the main view, or stops following
+func toggleFollow(appData *CameraAppData) {
+	if follower.Active {
+		follower.Active = false
+		appData.StatusText = tr("status.follow_off")
+		return
+	}
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	rect, ok := mainCameraRect()
+	if !ok || !camera.Active || camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+
+	u, v := touchMode.CenterX, touchMode.CenterY
+	_, x, y := sdl.GetMouseState()
+	x, y = x*pixelDensity, y*pixelDensity
+	if x >= rect.X && x <= rect.X+rect.W && y >= rect.Y && y <= rect.Y+rect.H {
+		u, v = viewToFrame(rect, x, y)
+	}
+	follower = ObjectFollower{Active: true, X: u, Y: v, pending: true}
+	if touchMode.Zoom < followZoom {
+		touchMode.setZoom(followZoom)
+	}
+	appData.StatusText = tr("status.follow", camera.Info.Name)
+}
+
+// Update tracks the object in a frame of the selected camera and moves the
+// zoom window towards it
+func (f *ObjectFollower) Update(img *image.RGBA) {
+	if !f.Active {
+		return
+	}
+	bounds := img.Bounds()
+	f.luma = frameLuma(img, f.luma)
+	small, width, height := downsample(f.luma, bounds.Dx(), bounds.Dy(), followScale)
+	if width < 4 || height < 4 {
+		return
+	}
+
+	if f.pending {
+		f.pending = false
+		f.size = max(int(followTemplateSize*float32(min(width, height))), 4)
+		x0, y0 := f.topLeft(width, height)
+		f.template = make([]uint8, f.size*f.size)
+		for y := range f.size {
+			copy(f.template[y*f.size:(y+1)*f.size], small[(y0+y)*width+x0:])
+		}
+		return
+	}
+
+	// Search up to one patch size away from the last position
+	size := f.size
+	lastX, lastY := f.topLeft(width, height)
+	bestX, bestY, best := lastX, lastY, -1
+	for y := max(lastY-size, 0); y <= min(lastY+size, height-size); y++ {
+		for x := max(lastX-size, 0); x <= min(lastX+size, width-size); x++ {
+			if sad := f.difference(small, width, x, y, best); best < 0 || sad < best {
+				bestX, bestY, best = x, y, sad
+			}
+		}
+	}
+
+	mean := best / (size * size)
+	f.Lost = mean > followLostSAD
+	if f.Lost {
+		return
+	}
+	if mean < followAdaptSAD {
+		for y := range size {
+			row := small[(bestY+y)*width+bestX:]
+			for x := range size {
+				t := &f.template[y*size+x]
+				*t = uint8((7*int(*t) + int(row[x])) / 8)
+			}
+		}
+	}
+
+	f.X = (float32(bestX) + float32(size)/2) / float32(width)
+	f.Y = (float32(bestY) + float32(size)/2) / float32(height)
+	touchMode.CenterX += (f.X - touchMode.CenterX) * followSmoothing
+	touchMode.CenterY += (f.Y - touchMode.CenterY) * followSmoothing
+	touchMode.clampCenter()
+}
+
+// topLeft returns the corner of the template at the object's position, in
+// downsampled pixels, kept inside the frame
+func (f *ObjectFollower) topLeft(width, height int) (int, int) {
+	x := int(f.X*float32(width)) - f.size/2
+	y := int(f.Y*float32(height)) - f.size/2
+	return min(max(x, 0), width-f.size), min(max(y, 0), height-f.size)
+}
+
+// difference returns the sum of absolute differences between the template
+// and the patch at (x, y), giving up once it exceeds limit (if limit >= 0)
+func (f *ObjectFollower) difference(small []uint8, width, x, y, limit int) int {
+	sum := 0
+	for ty := range f.size {
+		row := small[(y+ty)*width+x:]
+		template := f.template[ty*f.size:]
+		for tx := range f.size {
+			d := int(row[tx]) - int(template[tx])
+			if d < 0 {
+				d = -d
+			}
+			sum += d
+		}
+		if limit >= 0 && sum >= limit {
+			return sum
+		}
+	}
+	return sum
+}
+
+// renderFollowTarget outlines the followed object, in red while it is lost
+func renderFollowTarget(appData *CameraAppData) {
+	if !follower.Active || follower.pending || appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	rect, ok := mainCameraRect()
+	if !ok || camera.Width <= 0 || camera.Height <= 0 {
+		return
+	}
+	half := followTemplateSize / 2 * float32(min(camera.Width, camera.Height))
+	x0, y0 := frameToView(rect, follower.X-half/float32(camera.Width), follower.Y-half/float32(camera.Height))
+	x1, y1 := frameToView(rect, follower.X+half/float32(camera.Width), follower.Y+half/float32(camera.Height))
+	if follower.Lost {
+		_ = appData.Renderer.SetDrawColor(255, 60, 60, 255)
+	} else {
+		_ = appData.Renderer.SetDrawColor(0, 255, 0, 255)
+	}
+	_ = appData.Renderer.RenderRect(&sdl.FRect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// The live view of a camera can be paused onto its frame history to look at
+// something fast that just happened: Space or the Pause button freezes the
+// view on the newest frame, Shift+Left and Shift+Right step through the
+// history one frame at a time, showing each frame's index and capture time,
+// and Space or the Live button return to the camera. Recording, streams and
+// the history itself carry on while the view is paused.
+
+// liveFreeze is the paused live view of a camera
+type liveFreeze struct {
+	// Frames is the history when the view was paused, oldest first
+	Frames []Frame
+	// Index is the frame to show
+	Index int
+	// shown is the frame last shown, -1 before the first
+	shown int
+	// Speed is how fast a slow-motion review plays the frames from
+	// started on, 0 while the view stays on a frame, see slowmo.go
+	Speed   float64
+	started time.Time
+}
+
+// take returns the frame to show if it is not shown yet
+func (f *liveFreeze) take() (Frame, bool) {
+	if f.Index == f.shown {
+		return Frame{}, false
+	}
+	f.shown = f.Index
+	return f.Frames[f.Index], true
+}
+
+// step moves delta frames through the history, stopping at either end. A
+// slow-motion review stops where it is.
+func (f *liveFreeze) step(delta int) {
+	f.Speed = 0
+	f.Index = min(max(f.Index+delta, 0), len(f.Frames)-1)
+}
+
+// selectedLive returns the selected camera if it shows a live source
+func selectedLive(appData *CameraAppData) (*CameraInstance, bool) {
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return nil, false
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	return camera, camera.PlaybackControl == nil && camera.Active && !camera.Disabled
+}
+
+// toggleFreeze pauses the live view of a camera on its newest frame, or
+// resumes it
+func toggleFreeze(appData *CameraAppData, camera *CameraInstance) {
+	if camera.Freeze != nil {
+		camera.Freeze = nil
+		appData.StatusText = tr("status.live", camera.Info.Name)
+		return
+	}
+	frames := camera.History.Since(historyDuration)
+	if len(frames) == 0 {
+		setErrorStatus(appData, trErr("error.no_history"))
+		return
+	}
+	// The newest frame is the one already shown
+	camera.Freeze = &liveFreeze{Frames: frames, Index: len(frames) - 1, shown: len(frames) - 1}
+	appData.StatusText = tr("status.frozen", camera.Info.Name, len(frames))
+}
+
+// handleFreezeKey handles the pause keys while a live camera is selected,
+// reporting whether the key was one of them
+func handleFreezeKey(appData *CameraAppData, scancode sdl.Scancode) bool {
+	camera, ok := selectedLive(appData)
+	if !ok {
+		return false
+	}
+	switch {
+	case scancode == sdl.SCANCODE_SPACE:
+		toggleFreeze(appData, camera)
+	case scancode == sdl.SCANCODE_M:
+		speed := 0.5
+		if shiftHeld(appData) {
+			speed = 0.25
+		}
+		startSlowMotion(appData, camera, speed)
+	case camera.Freeze == nil || !shiftHeld(appData):
+		return false
+	case scancode == sdl.SCANCODE_LEFT:
+		camera.Freeze.step(-1)
+	case scancode == sdl.SCANCODE_RIGHT:
+		camera.Freeze.step(1)
+	default:
+		return false
+	}
+	return true
+}
+
+// handleFreezeClick runs the Pause or Live button under a click
+func handleFreezeClick(appData *CameraAppData, x, y float32) bool {
+	camera, ok := selectedLive(appData)
+	if !ok || !pointInElement(SafeID("FreezeButton"), x, y) {
+		return false
+	}
+	toggleFreeze(appData, camera)
+	return true
+}
+
+// createFreezeLayout declares the Pause button of a selected live camera at
+// the bottom of its pane, and where in the history a paused view is
+func createFreezeLayout(data *CameraAppData, pane clay.ElementId) {
+	camera, ok := selectedLive(data)
+	if !ok || camera.Freeze == nil && camera.History.Len() == 0 {
+		return
+	}
+	createSlowMotionLayout(camera.Freeze)
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("FreezePanel"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Padding:         clay.PaddingAll(dpu(6)),
+			ChildGap:        dpu(4),
+			ChildAlignment:  clay.ChildAlignment{X: clay.ALIGN_X_CENTER},
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:   clay.Vector2{Y: -dp(10)},
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_PARENT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_BOTTOM,
+				Parent:  clay.ATTACH_POINT_CENTER_BOTTOM,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(4)),
+	}, func() {
+		label := tr("freeze.pause")
+		if freeze := camera.Freeze; freeze != nil {
+			label = tr("freeze.live")
+			frame := freeze.Frames[freeze.Index]
+			age := freeze.Frames[len(freeze.Frames)-1].Captured.Sub(frame.Captured)
+			safeText("freeze-position", tr("freeze.position", freeze.Index+1, len(freeze.Frames),
+				frame.Captured.Format("15:04:05.000"), age.Seconds()), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.Text,
+			})
+			safeText("freeze-keys", tr("freeze.keys"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  10,
+				TextColor: theme.TextDim,
+			})
+		}
+		id := SafeID("FreezeButton")
+		background := theme.Item
+		if clay.PointerOver(id) {
+			background = theme.Accent
+		}
+		clay.UI()(clay.ElementDeclaration{
+			Id: id,
+			Layout: clay.LayoutConfig{
+				Padding: clay.Padding{Left: tpu(10), Right: tpu(10), Top: tpu(4), Bottom: tpu(4)},
+			},
+			BackgroundColor: background,
+			CornerRadius:    clay.CornerRadiusAll(dp(3)),
+		}, func() {
+			safeText("freeze-button", label, clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.Text,
+			})
+		})
+	})
+}
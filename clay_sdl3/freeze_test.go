@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+func TestFreezeKeys(t *testing.T) {
+	appData := &CameraAppData{
+		Cameras:   []CameraInstance{{Info: CameraInfo{Name: "bench"}, Active: true}},
+		KeyStates: make(map[sdl.Scancode]bool),
+	}
+	camera := &appData.Cameras[0]
+	if !handleFreezeKey(appData, sdl.SCANCODE_SPACE) || camera.Freeze != nil {
+		t.Fatal("paused without a history")
+	}
+
+	camera.History = testHistory(t, 5)
+	handleFreezeKey(appData, sdl.SCANCODE_SPACE)
+	freeze := camera.Freeze
+	if freeze == nil || freeze.Index != 4 {
+		t.Fatal("not paused on the newest frame")
+	}
+	if _, ok := freeze.take(); ok {
+		t.Error("the frame shown was shown again")
+	}
+
+	// Stepping takes Shift and stops at the oldest frame
+	if handleFreezeKey(appData, sdl.SCANCODE_LEFT) {
+		t.Error("stepped without Shift")
+	}
+	appData.KeyStates[sdl.SCANCODE_LSHIFT] = true
+	for range 6 {
+		handleFreezeKey(appData, sdl.SCANCODE_LEFT)
+	}
+	frame, ok := freeze.take()
+	if !ok || freeze.Index != 0 || !frame.Captured.Equal(freeze.Frames[0].Captured) {
+		t.Errorf("stepped to frame %d", freeze.Index)
+	}
+	handleFreezeKey(appData, sdl.SCANCODE_RIGHT)
+	if _, ok := freeze.take(); !ok || freeze.Index != 1 {
+		t.Errorf("stepped to frame %d", freeze.Index)
+	}
+
+	// The history goes on while the view is paused
+	camera.History.add(Frame{Captured: frame.Captured.Add(time.Second)})
+	if len(freeze.Frames) != 5 || camera.History.Len() != 6 {
+		t.Errorf("paused on %d frames of %d", len(freeze.Frames), camera.History.Len())
+	}
+
+	handleFreezeKey(appData, sdl.SCANCODE_SPACE)
+	if camera.Freeze != nil {
+		t.Error("still paused")
+	}
+	camera.PlaybackControl = newPlaybackControl(1)
+	if handleFreezeKey(appData, sdl.SCANCODE_SPACE) {
+		t.Error("a playback was paused as a live camera")
+	}
+}
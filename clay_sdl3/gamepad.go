@@ -0,0 +1,208 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// V4L2 camera class controls for pan, tilt and zoom, which go4vl does not
+// define
+const (
+	ctrlPanAbsolute  v4l2.CtrlID = 0x009a0908
+	ctrlTiltAbsolute v4l2.CtrlID = 0x009a0909
+	ctrlZoomAbsolute v4l2.CtrlID = 0x009a090d
+)
+
+const (
+	// stickDeadZone ignores small stick and trigger deflections
+	stickDeadZone = 8000
+	// ptzInterval limits how often PTZ controls are written to the device
+	ptzInterval = 100 * time.Millisecond
+	// ptzStepsPerRange is how many full-deflection steps cross a control's
+	// whole range
+	ptzStepsPerRange = 50
+)
+
+// gamepads holds the open gamepads by joystick ID
+var gamepads = make(map[sdl.JoystickID]*sdl.Gamepad)
+
+var lastPTZUpdate time.Time
+
+// handleGamepadEvent opens and closes gamepads as they are plugged in and
+// maps their buttons to camera actions:
+//
+//	D-pad left/right, shoulders  previous/next camera
+//	A (south)                    snapshot
+//	X (west)                     start/stop recording
+//	Y (north)                    reset zoom
+//
+// The sticks and triggers are polled every frame by updateGamepads.
+func handleGamepadEvent(appData *CameraAppData, event *sdl.Event) {
+	switch event.Type {
+	case sdl.EVENT_GAMEPAD_ADDED:
+		id := event.GamepadDeviceEvent().Which
+		gamepad, err := id.OpenGamepad()
+		if err != nil {
+			log.Printf("Failed to open gamepad %d: %v", id, err)
+			return
+		}
+		gamepads[id] = gamepad
+		log.Printf("Gamepad connected: %s", gamepad.Name())
+
+	case sdl.EVENT_GAMEPAD_REMOVED:
+		id := event.GamepadDeviceEvent().Which
+		if gamepad, ok := gamepads[id]; ok {
+			log.Printf("Gamepad disconnected: %s", gamepad.Name())
+			gamepad.Close()
+			delete(gamepads, id)
+		}
+
+	case sdl.EVENT_GAMEPAD_BUTTON_DOWN:
+		if appData.SelectedCamera >= len(appData.Cameras) {
+			return
+		}
+		switch sdl.GamepadButton(event.GamepadButtonEvent().Button) {
+		case sdl.GAMEPAD_BUTTON_DPAD_LEFT, sdl.GAMEPAD_BUTTON_LEFT_SHOULDER:
+			stepCamera(appData, -1)
+		case sdl.GAMEPAD_BUTTON_DPAD_RIGHT, sdl.GAMEPAD_BUTTON_RIGHT_SHOULDER:
+			stepCamera(appData, 1)
+		case sdl.GAMEPAD_BUTTON_SOUTH:
+			if kiosk.Locked {
+				return
+			}
+			runContextMenuAction(appData, appData.SelectedCamera, menuSnapshot)
+		case sdl.GAMEPAD_BUTTON_WEST:
+			if kiosk.Locked {
+				return
+			}
+			runContextMenuAction(appData, appData.SelectedCamera, menuToggleRecording)
+		case sdl.GAMEPAD_BUTTON_NORTH:
+			touchMode.resetZoom()
+		}
+	}
+}
+
+// closeGamepads closes all open gamepads
+func closeGamepads() {
+	for id, gamepad := range gamepads {
+		gamepad.Close()
+		delete(gamepads, id)
+	}
+}
+
+// axisValue returns the deflection of an axis on any gamepad in [-1, 1],
+// ignoring the dead zone
+func axisValue(axis sdl.GamepadAxis) float32 {
+	var value int16
+	for _, gamepad := range gamepads {
+		if v := gamepad.Axis(axis); abs32(float32(v)) > abs32(float32(value)) {
+			value = v
+		}
+	}
+	if abs32(float32(value)) < stickDeadZone {
+		return 0
+	}
+	return float32(value) / 32767
+}
+
+// updateGamepads applies the analog inputs to the selected camera: the left
+// stick pans and tilts, the triggers zoom. Cameras with PTZ controls move
+// the hardware; others pan and zoom the main view digitally.
+func updateGamepads(appData *CameraAppData) {
+	if len(gamepads) == 0 || appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+
+	pan := axisValue(sdl.GAMEPAD_AXIS_LEFTX)
+	tilt := -axisValue(sdl.GAMEPAD_AXIS_LEFTY)
+	zoom := axisValue(sdl.GAMEPAD_AXIS_RIGHT_TRIGGER) - axisValue(sdl.GAMEPAD_AXIS_LEFT_TRIGGER)
+	if pan == 0 && tilt == 0 && zoom == 0 {
+		return
+	}
+
+	// Hardware controls are written at most every ptzInterval, the digital
+	// fallback is applied every frame for smooth movement
+	writePTZ := time.Since(lastPTZUpdate) >= ptzInterval
+	if writePTZ {
+		lastPTZUpdate = time.Now()
+	}
+
+	if zoom != 0 {
+		if hasControl(camera, ctrlZoomAbsolute) {
+			if writePTZ {
+				nudgeControl(camera, ctrlZoomAbsolute, zoom)
+			}
+		} else {
+			touchMode.setZoom(touchMode.Zoom * (1 + zoom*0.03))
+		}
+	}
+	if pan != 0 {
+		if hasControl(camera, ctrlPanAbsolute) {
+			if writePTZ {
+				nudgeControl(camera, ctrlPanAbsolute, pan)
+			}
+		} else {
+			touchMode.CenterX += pan * 0.01 / touchMode.Zoom
+			touchMode.clampCenter()
+		}
+	}
+	if tilt != 0 {
+		if hasControl(camera, ctrlTiltAbsolute) {
+			if writePTZ {
+				nudgeControl(camera, ctrlTiltAbsolute, tilt)
+			}
+		} else {
+			touchMode.CenterY -= tilt * 0.01 / touchMode.Zoom
+			touchMode.clampCenter()
+		}
+	}
+}
+
+// hasControl reports whether the camera's device supports a control,
+// caching the answer
+func hasControl(camera *CameraInstance, id v4l2.CtrlID) bool {
+	if camera.Device == nil {
+		return false
+	}
+	if supported, ok := camera.SupportedControls[id]; ok {
+		return supported
+	}
+	if camera.SupportedControls == nil {
+		camera.SupportedControls = make(map[v4l2.CtrlID]bool)
+	}
+	_, err := camera.Device.QueryControl(id)
+	camera.SupportedControls[id] = err == nil
+	return err == nil
+}
+
+// nudgeControl moves a camera control by a fraction of its range in the
+// given direction
+func nudgeControl(camera *CameraInstance, id v4l2.CtrlID, direction float32) {
+	if camera.Disabled {
+		return
+	}
+	ctrl, err := camera.Device.GetControl(id)
+	if err != nil {
+		log.Printf("Failed to read control %#x on %s: %v", id, camera.Info.Name, err)
+		return
+	}
+
+	step := (ctrl.Maximum - ctrl.Minimum) / ptzStepsPerRange
+	if ctrl.Step > 0 {
+		// Round to a multiple of the control's own step
+		step = max(step/ctrl.Step, 1) * ctrl.Step
+	}
+	value := ctrl.Value + int32(direction*float32(step))
+	value = min(max(value, ctrl.Minimum), ctrl.Maximum)
+	if value == ctrl.Value {
+		return
+	}
+
+	if err := camera.Device.SetControlValue(id, value); err != nil {
+		log.Printf("Failed to set %s on %s: %v", ctrl.Name, camera.Info.Name, err)
+	}
+}
@@ -7,12 +7,15 @@ toolchain go1.24.3
 require (
 	github.com/TotallyGamerJet/clay v0.0.5
 	github.com/Zyko0/go-sdl3 v0.0.0-20250601142725-2fefbd8ac5cd
+	github.com/ebitengine/purego v0.9.0-alpha.6
 	github.com/vladimirvivien/go4vl v0.0.5
+	golang.org/x/image v0.28.0
+	golang.org/x/net v0.41.0
+	golang.org/x/sys v0.33.0
 )
 
 require (
 	github.com/Zyko0/purego-gen v0.0.0-20250601142424-aec919327f6e // indirect
-	github.com/ebitengine/purego v0.9.0-alpha.6 // indirect
 	github.com/gotranspile/cxgo v0.5.2 // indirect
-	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
 )
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// The golden tests push known frames through the capture conversion and
+// processing stages and compare the result with images in testdata/golden.
+// After an intended change, regenerate them with
+//
+//	go test -run Golden -update
+//
+// and review the new images before committing them.
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden images")
+
+const goldenDir = "testdata/golden"
+
+// goldenWidth and goldenHeight are the size of the test scene
+const goldenWidth, goldenHeight = 96, 64
+
+// goldenTolerance is how far a channel may stray from the golden image,
+// and goldenOutliers the share of pixels allowed to stray further, leaving
+// room for rounding in decoders without hiding real regressions
+const (
+	goldenTolerance = 3
+	goldenOutliers  = 0.002
+)
+
+// goldenScene is the test scene: color bars over the top half, a grey ramp
+// with a bright diagonal line below
+func goldenScene() *image.RGBA {
+	bars := []color.RGBA{
+		{255, 255, 255, 255}, {255, 255, 0, 255}, {0, 255, 255, 255}, {0, 255, 0, 255},
+		{255, 0, 255, 255}, {255, 0, 0, 255}, {0, 0, 255, 255}, {16, 16, 16, 255},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, goldenWidth, goldenHeight))
+	for y := range goldenHeight {
+		for x := range goldenWidth {
+			c := bars[x*len(bars)/goldenWidth]
+			if y >= goldenHeight/2 {
+				v := uint8(x * 255 / (goldenWidth - 1))
+				c = color.RGBA{v, v, v, 255}
+				if x-y*2 >= -2 && x-y*2 <= 2 {
+					c = color.RGBA{240, 240, 240, 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// goldenInput encodes the scene as a device would deliver it in format
+func goldenInput(t *testing.T, format v4l2.FourCCType) []byte {
+	t.Helper()
+	scene := goldenScene()
+	ycbcr := func(x, y int) (uint8, uint8, uint8) {
+		c := scene.RGBAAt(x, y)
+		return color.RGBToYCbCr(c.R, c.G, c.B)
+	}
+	switch format {
+	case v4l2.PixelFmtMJPEG:
+		// Stored rather than encoded here, so a change in the encoder does
+		// not move the input under the decoder
+		path := filepath.Join(goldenDir, "scene.jpg")
+		if *updateGolden {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, scene, &jpeg.Options{Quality: 95}); err != nil {
+				t.Fatal(err)
+			}
+			writeGoldenFile(t, path, buf.Bytes())
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%v (run with -update to create it)", err)
+		}
+		return data
+	case v4l2.PixelFmtYUYV:
+		data := make([]byte, 0, goldenWidth*goldenHeight*2)
+		for y := range goldenHeight {
+			for x := 0; x < goldenWidth; x += 2 {
+				y0, cb0, cr0 := ycbcr(x, y)
+				y1, cb1, cr1 := ycbcr(x+1, y)
+				data = append(data, y0, uint8((int(cb0)+int(cb1))/2), y1, uint8((int(cr0)+int(cr1))/2))
+			}
+		}
+		return data
+	case fourcc("NV12"):
+		data := make([]byte, goldenWidth*goldenHeight, goldenWidth*goldenHeight*3/2)
+		for y := range goldenHeight {
+			for x := range goldenWidth {
+				data[y*goldenWidth+x], _, _ = ycbcr(x, y)
+			}
+		}
+		for y := 0; y < goldenHeight; y += 2 {
+			for x := 0; x < goldenWidth; x += 2 {
+				var cb, cr int
+				for _, p := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+					_, b, r := ycbcr(x+p[0], y+p[1])
+					cb, cr = cb+int(b), cr+int(r)
+				}
+				data = append(data, uint8(cb/4), uint8(cr/4))
+			}
+		}
+		return data
+	case fourcc("Y16 "):
+		// A thermal scene: the ramp around 20 degrees, the line a hot spot
+		data := make([]byte, goldenWidth*goldenHeight*2)
+		for y := range goldenHeight {
+			for x := range goldenWidth {
+				luma, _, _ := ycbcr(x, y)
+				binary.LittleEndian.PutUint16(data[2*(y*goldenWidth+x):], 29000+uint16(luma)*4)
+			}
+		}
+		return data
+	case fourcc("RGGB"):
+		data := make([]byte, goldenWidth*goldenHeight)
+		for y := range goldenHeight {
+			for x := range goldenWidth {
+				c := scene.RGBAAt(x, y)
+				switch {
+				case y%2 == 0 && x%2 == 0:
+					data[y*goldenWidth+x] = c.R
+				case y%2 == 1 && x%2 == 1:
+					data[y*goldenWidth+x] = c.B
+				default:
+					data[y*goldenWidth+x] = c.G
+				}
+			}
+		}
+		return data
+	}
+	t.Fatalf("no golden input for %s", formatName(format))
+	return nil
+}
+
+// goldenFrame converts the scene in format like the capture goroutine does
+func goldenFrame(t *testing.T, camera *CameraInstance, format v4l2.FourCCType) Frame {
+	t.Helper()
+	// The YUV scenes are full-range BT.601, as the JPEG color space is
+	camera.PixFormat = v4l2.PixFormat{Width: goldenWidth, Height: goldenHeight, PixelFormat: format, Colorspace: v4l2.ColorspaceJPEG}
+	frame, err := deviceFrame(camera, goldenInput(t, format))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func writeGoldenFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compareGolden compares an image with the golden image of that name
+func compareGolden(t *testing.T, name string, got *image.RGBA) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".png")
+	if *updateGolden {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, got); err != nil {
+			t.Fatal(err)
+		}
+		writeGoldenFile(t, path, buf.Bytes())
+		return
+	}
+
+	if err := goldenMismatch(path, got); err != nil {
+		actual := filepath.Join(t.TempDir(), name+".png")
+		var buf bytes.Buffer
+		_ = png.Encode(&buf, got)
+		_ = os.WriteFile(actual, buf.Bytes(), 0o644)
+		t.Errorf("%s: %v, output in %s", name, err, actual)
+	}
+}
+
+// goldenMismatch reports how an image differs from the golden image at
+// path, nil if within tolerance
+func goldenMismatch(path string, got *image.RGBA) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w (run with -update to create it)", err)
+	}
+	defer file.Close()
+	decoded, err := png.Decode(file)
+	if err != nil {
+		return err
+	}
+	want := toRGBA(decoded)
+	if want.Bounds().Size() != got.Bounds().Size() {
+		return fmt.Errorf("size %v, golden %v", got.Bounds().Size(), want.Bounds().Size())
+	}
+
+	bounds := got.Bounds()
+	outliers, worst := 0, 0
+	for y := range bounds.Dy() {
+		for x := range bounds.Dx() {
+			g, w := got.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y), want.RGBAAt(x, y)
+			diff := max(absDiff(g.R, w.R), absDiff(g.G, w.G), absDiff(g.B, w.B))
+			worst = max(worst, diff)
+			if diff > goldenTolerance {
+				outliers++
+			}
+		}
+	}
+	if limit := int(goldenOutliers * float64(bounds.Dx()*bounds.Dy())); outliers > limit {
+		return fmt.Errorf("%d pixels differ by more than %d (worst %d)", outliers, goldenTolerance, worst)
+	}
+	return nil
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func TestGoldenPipeline(t *testing.T) {
+	defer func(mode viewMode) { visualization.Mode = mode }(visualization.Mode)
+
+	for _, test := range []struct {
+		name   string
+		format v4l2.FourCCType
+		// setup configures the camera's processing
+		setup func(camera *CameraInstance)
+		// output derives what is compared from the processed frame
+		output func(camera *CameraInstance, img *image.RGBA) *image.RGBA
+	}{
+		{name: "mjpeg", format: v4l2.PixelFmtMJPEG},
+		{name: "yuyv", format: v4l2.PixelFmtYUYV},
+		{name: "nv12", format: fourcc("NV12")},
+		{name: "bayer_rggb", format: fourcc("RGGB")},
+		{name: "y16_ironbow", format: fourcc("Y16 "), setup: func(camera *CameraInstance) {
+			camera.Palette = paletteIronbow
+		}},
+		{name: "white_balance", format: v4l2.PixelFmtMJPEG, setup: func(camera *CameraInstance) {
+			camera.WhiteBalance = newWhiteBalance([3]float32{1.2, 1, 0.8})
+		}},
+		{name: "keystone", format: v4l2.PixelFmtMJPEG, setup: func(camera *CameraInstance) {
+			camera.Keystone = newKeystone([4]sdl.FPoint{{X: 0.1, Y: 0}, {X: 0.9, Y: 0.05}, {X: 1, Y: 1}, {X: 0, Y: 0.95}})
+			if camera.Keystone == nil {
+				t.Fatal("invalid keystone corners")
+			}
+		}},
+		{name: "false_color", format: v4l2.PixelFmtMJPEG, output: func(camera *CameraInstance, img *image.RGBA) *image.RGBA {
+			visualization.Mode = viewFalseColor
+			defer func() { visualization.Mode = viewNormal }()
+			return visualization.Apply(camera, img)
+		}},
+		{name: "thumbnail", format: v4l2.PixelFmtMJPEG, output: func(camera *CameraInstance, img *image.RGBA) *image.RGBA {
+			return scaleImage(img, 4)
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			camera := &CameraInstance{Info: CameraInfo{Name: test.name}}
+			if test.setup != nil {
+				test.setup(camera)
+			}
+			img, err := decodeFrame(camera, goldenFrame(t, camera, test.format))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.output != nil {
+				img = test.output(camera, img)
+			}
+			compareGolden(t, test.name, img)
+		})
+	}
+}
+
+// TestGoldenDetectsChanges guards the comparison itself: a visibly wrong
+// frame must not pass
+func TestGoldenDetectsChanges(t *testing.T) {
+	if *updateGolden {
+		t.Skip("updating golden images")
+	}
+	camera := &CameraInstance{}
+	img, err := decodeFrame(camera, goldenFrame(t, camera, v4l2.PixelFmtYUYV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Swap red and blue, a classic conversion bug
+	for i := 0; i+2 < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+2] = img.Pix[i+2], img.Pix[i]
+	}
+	if goldenMismatch(filepath.Join(goldenDir, "yuyv.png"), img) == nil {
+		t.Error("a red/blue swap passed the golden comparison")
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// The gRPC API serves the same cameras as the HTTP API to robotics stacks
+// and other programs that would rather use generated clients than scrape
+// MJPEG: the service is defined in proto/camapp.proto and served with
+// -grpc-listen over HTTP/2 without TLS. Like the MQTT client, it is a
+// minimal implementation of its own, covering what the service needs:
+// unary and server streaming calls, uncompressed messages and the fields
+// of camapp.proto. Tokens and roles are those of the HTTP API.
+
+const (
+	// grpcMaxRequest bounds the size of a request message
+	grpcMaxRequest = 1 << 20
+	// grpcService prefixes the paths of the service's methods
+	grpcService = "/camapp.v1.CamApp/"
+)
+
+// gRPC status codes
+const (
+	grpcOK               = 0
+	grpcInvalidArgument  = 3
+	grpcNotFound         = 5
+	grpcPermissionDenied = 7
+	grpcUnimplemented    = 12
+	grpcInternal         = 13
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+// grpcError is an error with the gRPC status it ends a call with
+type grpcError struct {
+	code int
+	err  error
+}
+
+func (e *grpcError) Error() string { return e.err.Error() }
+
+func (e *grpcError) Unwrap() error { return e.err }
+
+// grpcStatus gives an error a gRPC status code
+func grpcStatus(code int, err error) error {
+	return &grpcError{code: code, err: err}
+}
+
+// grpcMethod is a method of the service; send writes a response message,
+// once for unary methods
+type grpcMethod struct {
+	role  apiRole
+	serve func(s *APIServer, r *http.Request, request []byte, send func(protoMessage) error) error
+}
+
+var grpcMethods = map[string]grpcMethod{
+	"ListCameras":     {roleViewer, (*APIServer).grpcListCameras},
+	"ListControls":    {roleViewer, (*APIServer).grpcListControls},
+	"SetControl":      {roleAdmin, (*APIServer).grpcSetControl},
+	"SubscribeEvents": {roleViewer, (*APIServer).grpcSubscribeEvents},
+	"StreamFrames":    {roleViewer, (*APIServer).grpcStreamFrames},
+}
+
+// listenGRPC serves the gRPC API on a TCP address
+func listenGRPC(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC clients: %w", err)
+	}
+	if err := startAPI(); err != nil {
+		listener.Close()
+		return err
+	}
+	server := api.grpcServer()
+	log.Printf("Serving the gRPC API on %s", listener.Addr())
+	go func() {
+		err := server.Serve(listener)
+		log.Printf("gRPC server stopped: %v", err)
+	}()
+	return nil
+}
+
+// grpcServer returns an HTTP/2 server without TLS for the gRPC API
+func (s *APIServer) grpcServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+grpcService+"{method}", s.handleGRPC)
+	server := &http.Server{Handler: mux, Protocols: new(http.Protocols)}
+	server.Protocols.SetUnencryptedHTTP2(true)
+	return server
+}
+
+func (s *APIServer) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "expected a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	err := s.serveGRPC(w, r)
+
+	code := grpcOK
+	var status *grpcError
+	if errors.As(err, &status) {
+		code = status.code
+	} else if err != nil {
+		code = grpcInternal
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if err != nil {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(err.Error()))
+	}
+}
+
+// serveGRPC checks a call's token, reads its request and runs its method
+func (s *APIServer) serveGRPC(w http.ResponseWriter, r *http.Request) error {
+	method, ok := grpcMethods[r.PathValue("method")]
+	if !ok {
+		return grpcStatus(grpcUnimplemented, fmt.Errorf("unknown method %s", r.PathValue("method")))
+	}
+	if len(apiTokens) > 0 {
+		switch role := tokenRole(requestToken(r)); {
+		case role == 0:
+			return grpcStatus(grpcUnauthenticated, errors.New("a valid API token is required"))
+		case role < method.role:
+			return grpcStatus(grpcPermissionDenied, fmt.Errorf("the %s role is required, the token is for a %s", method.role, role))
+		}
+	}
+	request, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	send := func(message protoMessage) error {
+		header := make([]byte, 5, 5+len(message))
+		binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+		if _, err := w.Write(append(header, message...)); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	return method.serve(s, r, request, send)
+}
+
+// readGRPCMessage reads the request message of a call
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, grpcStatus(grpcInvalidArgument, fmt.Errorf("failed to read the request: %w", err))
+	}
+	if header[0] != 0 {
+		return nil, grpcStatus(grpcUnimplemented, errors.New("compressed messages are not supported"))
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > grpcMaxRequest {
+		return nil, grpcStatus(grpcInvalidArgument, fmt.Errorf("a request of %d bytes is too large", size))
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, grpcStatus(grpcInvalidArgument, fmt.Errorf("failed to read the request: %w", err))
+	}
+	return message, nil
+}
+
+// grpcCamera parses the camera_id field of a request and looks the camera up
+func (s *APIServer) grpcCamera(request []byte) (apiCamera, map[int]uint64, error) {
+	fields, err := parseProto(request)
+	if err != nil {
+		return apiCamera{}, nil, grpcStatus(grpcInvalidArgument, err)
+	}
+	camera, ok := s.cameraByID(int(int32(fields[1])))
+	if !ok {
+		return apiCamera{}, nil, grpcStatus(grpcNotFound, fmt.Errorf("no camera %d", int32(fields[1])))
+	}
+	return camera, fields, nil
+}
+
+func (s *APIServer) grpcListCameras(r *http.Request, request []byte, send func(protoMessage) error) error {
+	s.mu.Lock()
+	cameras := s.cameras
+	s.mu.Unlock()
+	var response protoMessage
+	for _, camera := range cameras {
+		var m protoMessage
+		m.int(1, int64(camera.ID))
+		m.string(2, camera.Name)
+		m.int(3, int64(camera.Width))
+		m.int(4, int64(camera.Height))
+		m.bool(5, camera.Active)
+		m.bool(6, camera.Offline)
+		m.bool(7, camera.Recording)
+		m.varint(8, camera.LostFrames)
+		m.varint(9, camera.DroppedFrames)
+		response.message(1, m)
+	}
+	return send(response)
+}
+
+func (s *APIServer) grpcListControls(r *http.Request, request []byte, send func(protoMessage) error) error {
+	camera, _, err := s.grpcCamera(request)
+	if err != nil {
+		return err
+	}
+	controls, err := s.controls(camera.ID)
+	if err != nil {
+		return grpcStatus(grpcUnavailable, err)
+	}
+	var response protoMessage
+	for _, ctrl := range controls {
+		var m protoMessage
+		m.varint(1, uint64(ctrl.ID))
+		m.string(2, ctrl.Name)
+		m.string(3, ctrl.Type)
+		m.int(4, int64(ctrl.Minimum))
+		m.int(5, int64(ctrl.Maximum))
+		m.int(6, int64(ctrl.Step))
+		m.int(7, int64(ctrl.Default))
+		m.int(8, int64(ctrl.Value))
+		response.message(1, m)
+	}
+	return send(response)
+}
+
+func (s *APIServer) grpcSetControl(r *http.Request, request []byte, send func(protoMessage) error) error {
+	camera, fields, err := s.grpcCamera(request)
+	if err != nil {
+		return err
+	}
+	if err := s.setControl(camera.ID, v4l2.CtrlID(fields[2]), int32(fields[3])); err != nil {
+		return grpcStatus(grpcInvalidArgument, err)
+	}
+	return send(nil)
+}
+
+func (s *APIServer) grpcSubscribeEvents(r *http.Request, request []byte, send func(protoMessage) error) error {
+	client := s.subscribe()
+	defer s.unsubscribe(client)
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, ok := <-client.events:
+			if !ok {
+				return grpcStatus(grpcUnavailable, errors.New("the client fell behind the events"))
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				return err
+			}
+			var m protoMessage
+			m.string(1, event.Type)
+			m.int(2, event.Time.UnixNano())
+			m.string(3, string(data))
+			if err := send(m); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *APIServer) grpcStreamFrames(r *http.Request, request []byte, send func(protoMessage) error) error {
+	camera, fields, err := s.grpcCamera(request)
+	if err != nil {
+		return err
+	}
+	client := newStreamClient()
+	client.fixed = fields[2] == 0
+	s.addStream(camera.ID, client)
+	defer s.removeStream(camera.ID, client)
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case frame := <-client.frames:
+			data := client.prepare(frame)
+			if data == nil {
+				continue
+			}
+			start := time.Now()
+			var m protoMessage
+			m.int(1, int64(camera.ID))
+			m.int(2, frame.Captured.UnixNano())
+			m.bytes(3, data)
+			if err := send(m); err != nil {
+				return nil
+			}
+			client.sent(time.Since(start))
+		}
+	}
+}
+
+// protoMessage is an encoded protobuf message. Fields with zero values are
+// left out, as proto3 does.
+type protoMessage []byte
+
+// Protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func (m *protoMessage) tag(field, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(field)<<3|uint64(wireType))
+}
+
+// varint adds an unsigned integer field
+func (m *protoMessage) varint(field int, value uint64) {
+	if value != 0 {
+		m.tag(field, protoVarint)
+		*m = binary.AppendUvarint(*m, value)
+	}
+}
+
+// int adds a signed integer field, int32 or int64
+func (m *protoMessage) int(field int, value int64) {
+	m.varint(field, uint64(value))
+}
+
+func (m *protoMessage) bool(field int, value bool) {
+	if value {
+		m.varint(field, 1)
+	}
+}
+
+func (m *protoMessage) bytes(field int, value []byte) {
+	if len(value) > 0 {
+		m.message(field, value)
+	}
+}
+
+func (m *protoMessage) string(field int, value string) {
+	m.bytes(field, []byte(value))
+}
+
+// message adds an embedded message, which repeated fields need even when
+// it is empty
+func (m *protoMessage) message(field int, value []byte) {
+	m.tag(field, protoBytes)
+	*m = binary.AppendUvarint(*m, uint64(len(value)))
+	*m = append(*m, value...)
+}
+
+// parseProto returns the integer fields of a protobuf message by number,
+// skipping the others, which requests do not have
+func parseProto(data []byte) (map[int]uint64, error) {
+	fields := make(map[int]uint64)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid protobuf message")
+		}
+		data = data[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case protoVarint:
+			value, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf message")
+			}
+			fields[field] = value
+			data = data[n:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return nil, errors.New("invalid protobuf message")
+			}
+			data = data[n+int(size):]
+		case protoFixed64:
+			if len(data) < 8 {
+				return nil, errors.New("invalid protobuf message")
+			}
+			data = data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return nil, errors.New("invalid protobuf message")
+			}
+			data = data[4:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", tag&7)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// callGRPC makes a gRPC call and returns its response messages and status
+func callGRPC(t *testing.T, url, method, token string, request protoMessage) ([][]byte, string) {
+	t.Helper()
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	defer transport.CloseIdleConnections()
+
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(request)))
+	req, err := http.NewRequest(http.MethodPost, url+grpcService+method, bytes.NewReader(append(body, request...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages [][]byte
+	for len(data) >= 5 {
+		size := binary.BigEndian.Uint32(data[1:])
+		messages = append(messages, data[5:5+size])
+		data = data[5+size:]
+	}
+	return messages, resp.Trailer.Get("Grpc-Status")
+}
+
+func TestGRPC(t *testing.T) {
+	t.Cleanup(func() { apiTokens = nil })
+	dev := newFakeDevice(0)
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "bench"}, Device: dev, Active: true}}}
+	s := newAPIServer()
+	serveAPIServer(t, s, appData)
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = s.grpcServer()
+	server.Start()
+	t.Cleanup(server.Close)
+
+	messages, status := callGRPC(t, server.URL, "ListCameras", "", nil)
+	if status != "0" || len(messages) != 1 {
+		t.Fatalf("ListCameras: status %s, %d messages", status, len(messages))
+	}
+	var cameras []map[int]uint64
+	for len(messages[0]) > 0 {
+		// Each camera is field 1, a length and the message
+		size := int(messages[0][1])
+		fields, err := parseProto(messages[0][2 : 2+size])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cameras = append(cameras, fields)
+		messages[0] = messages[0][2+size:]
+	}
+	if len(cameras) != 1 || cameras[0][5] != 1 {
+		t.Errorf("cameras %v", cameras)
+	}
+
+	var set protoMessage
+	set.int(1, 0)
+	set.varint(2, uint64(v4l2.CtrlBrightness))
+	set.int(3, 42)
+	if _, status := callGRPC(t, server.URL, "SetControl", "", set); status != "0" {
+		t.Fatalf("SetControl: status %s", status)
+	}
+	if value, _ := dev.GetControlValue(v4l2.CtrlBrightness); value != 42 {
+		t.Errorf("brightness %d", value)
+	}
+	var missing protoMessage
+	missing.int(1, 7)
+	if _, status := callGRPC(t, server.URL, "ListControls", "", missing); status != "5" {
+		t.Errorf("unknown camera: status %s", status)
+	}
+	if _, status := callGRPC(t, server.URL, "Reboot", "", nil); status != "12" {
+		t.Errorf("unknown method: status %s", status)
+	}
+
+	apiTokens = []APIToken{{Token: "watch", role: roleViewer}}
+	if _, status := callGRPC(t, server.URL, "ListCameras", "", nil); status != "16" {
+		t.Errorf("without a token: status %s", status)
+	}
+	if _, status := callGRPC(t, server.URL, "SetControl", "watch", set); status != "7" {
+		t.Errorf("viewer setting a control: status %s", status)
+	}
+}
+
+func TestProtoMessage(t *testing.T) {
+	var m protoMessage
+	m.int(1, -1)
+	m.string(2, "skipped")
+	m.varint(3, 300)
+	m.bool(4, false)
+	fields, err := parseProto(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int32(fields[1]) != -1 || fields[3] != 300 || len(fields) != 2 {
+		t.Errorf("fields %v", fields)
+	}
+	if _, err := parseProto([]byte{0x08}); err == nil {
+		t.Error("truncated varint accepted")
+	}
+}
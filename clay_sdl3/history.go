@@ -0,0 +1,113 @@
+package main
+
+import (
+	"image"
+	"sync"
+	"time"
+)
+
+// The frame history keeps the last -history seconds of each camera as its
+// frames arrived, so the moment that just happened can still be looked at
+// and exported after the fact. JPEG frames are kept as received and raw
+// sources keep their decoded frame, up to historyMaxBytes a camera, beyond
+// which the oldest frames go first. It counts towards the memory budget,
+// over which the histories of cameras nobody looks at are dropped, and is
+// emptied when privacy mode starts.
+
+// historyMaxBytes bounds the history of a camera
+const historyMaxBytes = 64 << 20
+
+// historyDuration is how far back the histories reach, 0 to keep none, set
+// with -history
+var historyDuration = 10 * time.Second
+
+// frameHistory holds the recent frames of a camera, oldest first. Its
+// methods may be called on a nil history, which holds nothing.
+type frameHistory struct {
+	mu     sync.Mutex
+	frames []Frame
+	bytes  int64
+}
+
+// frameBytes is the memory a frame in the history holds
+func frameBytes(frame Frame) int64 {
+	return int64(len(frame.Data)) + imageBytes(frame.Raw)
+}
+
+// add appends a frame, dropping those that fell out of the history
+func (h *frameHistory) add(frame Frame) {
+	// What is derived from the frame for the display is not kept
+	frame.Decoded, frame.Sensor, frame.DecodeTo = nil, nil, image.Point{}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.frames = append(h.frames, frame)
+	h.bytes += frameBytes(frame)
+	drop := 0
+	for drop < len(h.frames)-1 && (frame.Captured.Sub(h.frames[drop].Captured) > historyDuration || h.bytes > historyMaxBytes) {
+		h.bytes -= frameBytes(h.frames[drop])
+		drop++
+	}
+	if drop > 0 {
+		clear(h.frames[:drop])
+		h.frames = h.frames[drop:]
+	}
+}
+
+// Since returns the frames captured in the last d before the newest one
+func (h *frameHistory) Since(d time.Duration) []Frame {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.frames) == 0 {
+		return nil
+	}
+	newest := h.frames[len(h.frames)-1].Captured
+	first := len(h.frames) - 1
+	for first > 0 && newest.Sub(h.frames[first-1].Captured) <= d {
+		first--
+	}
+	return append([]Frame(nil), h.frames[first:]...)
+}
+
+// Len returns the number of frames held
+func (h *frameHistory) Len() int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.frames)
+}
+
+// Bytes returns the memory the history holds
+func (h *frameHistory) Bytes() int64 {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.bytes
+}
+
+// clear drops every frame
+func (h *frameHistory) clear() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.frames, h.bytes = nil, 0
+}
+
+// addToHistory adds a frame to its camera's history, starting one if needed
+func addToHistory(camera *CameraInstance, frame Frame) {
+	if historyDuration <= 0 {
+		return
+	}
+	if camera.History == nil {
+		camera.History = &frameHistory{}
+	}
+	camera.History.add(frame)
+}
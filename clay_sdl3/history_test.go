@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFrameHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := &frameHistory{}
+	for i := range 30 {
+		h.add(Frame{Data: make([]byte, 100), Captured: start.Add(time.Duration(i) * time.Second), Decoded: grayFrame(4, 4)})
+	}
+	// Frames older than the history are dropped
+	if n := h.Len(); n != int(historyDuration/time.Second)+1 {
+		t.Errorf("history holds %d frames", n)
+	}
+	if h.Bytes() != int64(h.Len())*100 {
+		t.Errorf("history holds %d bytes", h.Bytes())
+	}
+	frames := h.Since(2 * time.Second)
+	if len(frames) != 3 || !frames[0].Captured.Equal(start.Add(27*time.Second)) {
+		t.Errorf("last 2s holds %d frames", len(frames))
+	}
+	if frames[0].Decoded != nil {
+		t.Error("the decoded frame was kept")
+	}
+
+	// The newest frame stays however large it is
+	h.add(Frame{Data: make([]byte, historyMaxBytes), Captured: start.Add(31 * time.Second)})
+	if h.Len() != 1 {
+		t.Errorf("history holds %d frames over its size", h.Len())
+	}
+
+	h.clear()
+	var none *frameHistory
+	if h.Len() != 0 || h.Bytes() != 0 || none.Since(time.Hour) != nil || none.Len() != 0 {
+		t.Error("history not empty")
+	}
+}
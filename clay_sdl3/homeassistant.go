@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Home Assistant finds the cameras by itself through MQTT discovery:
+// -ha-mqtt [user:password@]host[:port] publishes, for each camera, a camera
+// entity fed with a frame every -ha-image-interval, a motion binary sensor
+// and a recording switch, all retained under -ha-prefix (homeassistant by
+// default) so that the broker hands them to Home Assistant whenever it
+// starts. The states live under camapp/HOST/CAMERA/, and camapp/HOST/status
+// turns offline through the connection's will when the app goes away.
+
+const (
+	// haRetry is how long a lost broker connection waits before connecting
+	// again
+	haRetry = 10 * time.Second
+	// haMotionHold is how long the motion sensor stays on after the last
+	// motion
+	haMotionHold = 10 * time.Second
+)
+
+var (
+	// haPrefix is Home Assistant's discovery prefix, set with -ha-prefix
+	haPrefix = "homeassistant"
+	// haImageInterval is how often camera entities get a frame, set with
+	// -ha-image-interval
+	haImageInterval = 10 * time.Second
+)
+
+// haDevice is the device entry of a discovery payload
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version"`
+}
+
+// haEntity is a discovery payload; each component uses some of the fields
+type haEntity struct {
+	Name              *string  `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	Device            haDevice `json:"device"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	Topic             string   `json:"topic,omitempty"`
+	StateTopic        string   `json:"state_topic,omitempty"`
+	CommandTopic      string   `json:"command_topic,omitempty"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	Icon              string   `json:"icon,omitempty"`
+}
+
+// haDiscovery is a discovery message
+type haDiscovery struct {
+	Topic   string
+	Payload haEntity
+}
+
+// haBaseTopic is where this machine's states are published
+func haBaseTopic(host string) string {
+	return "camapp/" + fileSafeName(host)
+}
+
+// haDiscoveries returns the discovery messages of a camera
+func haDiscoveries(host string, camera apiCamera) []haDiscovery {
+	base := haBaseTopic(host)
+	object := fileSafeName(host) + "_" + fileSafeName(camera.Name)
+	topic := base + "/" + fileSafeName(camera.Name)
+	device := haDevice{
+		Identifiers:  []string{"camapp_" + object},
+		Name:         camera.Name,
+		Manufacturer: "amken3d",
+		Model:        "go-camApp on " + host,
+		SWVersion:    currentBuild().Version,
+	}
+	entity := func(name *string, id string) haEntity {
+		return haEntity{Name: name, UniqueID: "camapp_" + object + id, Device: device, AvailabilityTopic: base + "/status"}
+	}
+	named := func(name string) *string { return &name }
+
+	// The camera entity takes the device's name
+	camEntity := entity(nil, "")
+	camEntity.Topic = topic + "/image"
+	motion := entity(named("Motion"), "_motion")
+	motion.StateTopic = topic + "/motion"
+	motion.DeviceClass = "motion"
+	recording := entity(named("Recording"), "_recording")
+	recording.StateTopic = topic + "/record"
+	recording.CommandTopic = topic + "/record/set"
+	recording.Icon = "mdi:record-rec"
+	return []haDiscovery{
+		{haPrefix + "/camera/camapp_" + object + "/config", camEntity},
+		{haPrefix + "/binary_sensor/camapp_" + object + "_motion/config", motion},
+		{haPrefix + "/switch/camapp_" + object + "_recording/config", recording},
+	}
+}
+
+// haSwitchState is an ON or OFF payload
+func haSwitchState(on bool) []byte {
+	if on {
+		return []byte("ON")
+	}
+	return []byte("OFF")
+}
+
+// startHomeAssistant announces the cameras to Home Assistant and keeps
+// their states current, reconnecting whenever the connection is lost
+func startHomeAssistant(broker string) error {
+	if haImageInterval <= 0 {
+		return fmt.Errorf("invalid Home Assistant image interval %v", haImageInterval)
+	}
+	if err := startAPI(); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			if err := runHomeAssistant(api, broker); err != nil {
+				log.Printf("Home Assistant: %v", err)
+			}
+			time.Sleep(haRetry)
+		}
+	}()
+	return nil
+}
+
+// runHomeAssistant publishes the cameras until the connection fails or the
+// cameras change, which changes the entities
+func runHomeAssistant(s *APIServer, broker string) error {
+	events := s.subscribe()
+	defer s.unsubscribe(events)
+	var cameras []apiCamera
+	for len(cameras) == 0 {
+		event, ok := <-events.events
+		if !ok {
+			return errors.New("fell behind the camera events")
+		}
+		if event.Type == "cameras" {
+			cameras = event.Data.([]apiCamera)
+		}
+	}
+
+	host := mdnsHostName()
+	base := haBaseTopic(host)
+	client, err := dialMQTT(broker, "camapp-ha-"+fileSafeName(host), &MQTTWill{Topic: base + "/status", Payload: []byte("offline"), Retain: true})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Cameras by the name in their topics
+	byTopic := make(map[string]apiCamera)
+	for _, camera := range cameras {
+		byTopic[fileSafeName(camera.Name)] = camera
+		for _, discovery := range haDiscoveries(host, camera) {
+			payload, err := json.Marshal(discovery.Payload)
+			if err != nil {
+				return err
+			}
+			if err := client.Publish(discovery.Topic, payload, true); err != nil {
+				return err
+			}
+		}
+		topic := base + "/" + fileSafeName(camera.Name)
+		if err := client.Publish(topic+"/record", haSwitchState(camera.Recording), true); err != nil {
+			return err
+		}
+		if err := client.Publish(topic+"/motion", haSwitchState(false), true); err != nil {
+			return err
+		}
+	}
+	if err := client.Publish(base+"/status", []byte("online"), true); err != nil {
+		return err
+	}
+	if err := client.Subscribe(base + "/+/record/set"); err != nil {
+		return err
+	}
+	log.Printf("Announced %d cameras to Home Assistant through %s", len(cameras), redactSecrets(broker))
+
+	lost := make(chan error, 1)
+	go func() {
+		lost <- client.Run(func(topic string, payload []byte) {
+			name, ok := strings.CutSuffix(strings.TrimPrefix(topic, base+"/"), "/record/set")
+			camera, known := byTopic[name]
+			if !ok || !known {
+				return
+			}
+			on := strings.EqualFold(string(payload), "ON")
+			go func() {
+				if _, err := s.record(camera.ID, on); err != nil {
+					log.Printf("Home Assistant: %v", err)
+					// Put the switch back
+					_ = client.Publish(base+"/"+name+"/record", haSwitchState(!on), true)
+				}
+			}()
+		})
+	}()
+
+	ticker := time.NewTicker(min(haImageInterval, haMotionHold/2))
+	defer ticker.Stop()
+	var imagesAt time.Time
+	motionUntil := make(map[int]time.Time)
+	topicOf := func(id int) string {
+		for name, camera := range byTopic {
+			if camera.ID == id {
+				return base + "/" + name
+			}
+		}
+		return ""
+	}
+	for {
+		select {
+		case err := <-lost:
+			return err
+		case event, ok := <-events.events:
+			if !ok {
+				return errors.New("fell behind the camera events")
+			}
+			switch data := event.Data.(type) {
+			case []apiCamera:
+				log.Printf("Home Assistant: the cameras changed, announcing them again")
+				return nil
+			case apiCamera:
+				if topicOf(data.ID) != base+"/"+fileSafeName(data.Name) {
+					log.Printf("Home Assistant: the cameras changed, announcing them again")
+					return nil
+				}
+			case apiRecordingEvent:
+				err = client.Publish(topicOf(data.ID)+"/record", haSwitchState(data.Recording), true)
+			case apiMotionEvent:
+				if motionUntil[data.ID].IsZero() {
+					err = client.Publish(topicOf(data.ID)+"/motion", haSwitchState(true), true)
+				}
+				motionUntil[data.ID] = time.Now().Add(haMotionHold)
+			}
+		case now := <-ticker.C:
+			for id, until := range motionUntil {
+				if now.After(until) {
+					delete(motionUntil, id)
+					err = errors.Join(err, client.Publish(topicOf(id)+"/motion", haSwitchState(false), true))
+				}
+			}
+			if now.Sub(imagesAt) >= haImageInterval {
+				imagesAt = now
+				for name, camera := range byTopic {
+					s.mu.Lock()
+					frame, ok := s.latest[camera.ID]
+					s.mu.Unlock()
+					if ok {
+						err = errors.Join(err, client.Publish(base+"/"+name+"/image", frame.Data, false))
+					}
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to publish: %w", err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHomeAssistantDiscovery(t *testing.T) {
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "Front Door"}, Active: true}}}
+	s := newAPIServer()
+	serveAPIServer(t, s, appData)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go runHomeAssistant(s, listener.Addr().String())
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	broker := &MQTTClient{conn: conn, reader: bufio.NewReader(conn)}
+	kind, connect, err := broker.read()
+	// The flags follow the protocol name and level
+	if err != nil || kind != mqttConnect || connect[7]&0x24 != 0x24 {
+		t.Fatalf("connect %d %v: %v", kind, connect, err)
+	}
+	if err := broker.write(mqttConnAck<<4, []byte{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	base := haBaseTopic(mdnsHostName())
+	published := make(map[string]string)
+	next := func() (string, string) {
+		t.Helper()
+		for {
+			kind, body, err := broker.read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind == mqttPublish {
+				n := int(binary.BigEndian.Uint16(body))
+				topic, payload := string(body[2:2+n]), string(body[2+n:])
+				published[topic] = payload
+				return topic, payload
+			}
+		}
+	}
+	for {
+		if topic, payload := next(); topic == base+"/status" && payload == "online" {
+			break
+		}
+	}
+
+	object := "camapp_" + fileSafeName(mdnsHostName()) + "_Front_Door"
+	var recording haEntity
+	if err := json.Unmarshal([]byte(published["homeassistant/switch/"+object+"_recording/config"]), &recording); err != nil {
+		t.Fatalf("recording switch: %v in %v", err, published)
+	}
+	if recording.CommandTopic != base+"/Front_Door/record/set" || recording.UniqueID != object+"_recording" {
+		t.Errorf("recording switch %+v", recording)
+	}
+	if published["homeassistant/camera/"+object+"/config"] == "" || published[base+"/Front_Door/record"] != "OFF" {
+		t.Errorf("published %v", published)
+	}
+
+	s.Motion(0, &appData.Cameras[0], time.Now())
+	if topic, payload := next(); topic != base+"/Front_Door/motion" || payload != "ON" {
+		t.Errorf("motion published %s %s", topic, payload)
+	}
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// catalogs maps a language code to its UI messages. English is the reference
+// catalog: every message ID must exist there, and other languages fall back
+// to it for missing entries.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"app.title":               "Multi-Camera App",
+		"header.title":            "Multi-Camera System",
+		"panel.cameras":           "Cameras",
+		"panel.no_cameras":        "No cameras found",
+		"camera.default":          "Camera %d",
+		"camera.folder":           "Folder %s",
+		"camera.replay":           "%s (replay)",
+		"camera.playback":         "%s (playback)",
+		"camera.remote":           "%s on %s",
+		"status.init":             "Initializing cameras...",
+		"status.ready":            "Ready",
+		"status.found":            "Found %d camera devices",
+		"status.initialized":      "Initialized %d/%d cameras",
+		"status.no_devices":       "No camera devices found",
+		"status.list_error":       "Error listing devices: %v",
+		"status.selected":         "%s | Selected: %s | Use arrows or numbers",
+		"status.retry_started":    "%s started after retrying",
+		"status.taken_over":       "%s handed over to the instance with PID %d",
+		"status.watchdog_restart": "Pipeline stalled, restarted %d cameras",
+		"error.retry_failed":      "%s did not start after %d retries",
+		"controls.mode":           "Mode: %s",
+		"mode.downgraded":         "%s (fallback, %dx%d refused)",
+		"status.frame_loss":       " | Frames lost by device: %d, dropped by app: %d",
+		"status.bar_fps":          "%.0f fps",
+		"status.bar_rec":          "REC %d",
+		"status.bar_disk":         "%s free",
+		"status.bar_clients":      "%d clients",
+		"status.bar_locked":       "Locked",
+		"status.bar_unlocked":     "Unlocked",
+		"status.kiosk_locked":     "Locked: only switching cameras and zooming work; press K to unlock",
+		"status.kiosk_unlocked":   "Unlocked; press K to lock again",
+		"error.kiosk_pin":         "Wrong PIN",
+		"error.kiosk_lockout":     "Too many wrong PINs, try again in %v",
+		"kiosk.enter_pin":         "Enter the PIN to unlock",
+		"kiosk.back":              "Del",
+		"kiosk.ok":                "OK",
+		"kiosk.cancel":            "Cancel",
+		"status.health_ok":        "%s: streaming at %.0f fps",
+		"status.health_degraded":  "%s: %.0f fps, %d frames lost by the device, %d dropped by the app",
+		"status.health_offline":   "%s: not delivering frames",
+		"status.health_off":       "%s: disabled",
+		"status.fps_detail":       "UI: %.0f fps | Cameras: %.0f frames per second in total",
+		"status.rec_detail":       "Recording %s",
+		"status.disk_detail":      "%s free of %s for %s",
+		"status.clients_detail":   "%d stream clients on the camera API",
+		"status.bar_toasts":       "%d notices",
+		"status.bar_schedule":     "Next: %s %s",
+		"status.bar_mirror":       "Mirror %s",
+		"status.bar_mirroring":    "Mirroring %d%%",
+		"status.mirror_detail":    "Mirror to %s",
+		"status.mirror_progress":  ", %s of %s copied",
+		"mirror.result":           "%d files, %s copied",
+		"schedule.done":           "Scheduled %s done for %d cameras",
+		"schedule.failed":         "Scheduled %s failed: %v",
+		"schedule.entry":          "%s next %s",
+		"schedule.last":           " (last %s: %s)",
+		"schedule.never":          "never",
+		"toast.history":           "Notifications",
+		"toast.none":              "No notifications yet",
+		"toast.close":             "Close",
+		"toast.at":                "%s  %s",
+		"toast.repeated":          "%s (%d times)",
+		"toast.init_failed":       "%s failed to start: %v",
+		"toast.capture_stopped":   "Capture from %s stopped: %v",
+		"toast.frame_error":       "Bad frame from %s: %v",
+		"toast.recording_failed":  "Recording of %s stopped: %v",
+		"toast.sidecar_failed":    "Snapshot of %s saved without metadata: %v",
+		"toast.disk_low":          "Only %s left for recordings in %s",
+		"toast.upload_failed":     "Upload of %s failed, retrying: %v",
+		"toast.mirror_failed":     "Mirror to %s failed: %v",
+		"status.theme":            "Theme: %s",
+		"status.language":         "Language: %s",
+		"status.disabled":         "Disabled %s",
+		"status.enabled":          "Enabled %s",
+		"status.privacy_on":       "Privacy mode on, cameras stopped",
+		"status.privacy_off":      "Privacy mode off",
+		"status.bar_privacy":      "PRIVACY",
+		"status.privacy_detail":   "Privacy mode since %s, %d cameras resume when it ends (P)",
+		"status.clip_in":          "Clip starts at frame %d",
+		"status.clip_out":         "Clip ends at frame %d",
+		"toast.clip_exporting":    "Exporting %d frames to %s",
+		"toast.clip_exported":     "Exported %d frames to %s",
+		"toast.clip_failed":       "Clip export failed: %v",
+		"toast.moment_exported":   "Saved moment to %s",
+		"toast.moment_failed":     "Saving the moment failed: %v",
+		"clip.playing":            "Playing",
+		"clip.paused":             "Paused",
+		"clip.position":           "%s · frame %d/%d · %s · clip %d-%d (%s)",
+		"clip.keys":               "Space pause · Shift+←/→ step · [ ] clip start/end · E export · Shift+E transcode",
+		"freeze.pause":            "Pause",
+		"freeze.live":             "Live",
+		"freeze.position":         "Paused · frame %d/%d · %s · %.2fs before the newest",
+		"freeze.keys":             "Space live · Shift+←/→ step · M slow motion ½× · Shift+M ¼×",
+		"status.frozen":           "%s paused on its last %d frames",
+		"status.live":             "%s is live again",
+		"status.slowmo":           "Replaying %s at %s",
+		"slowmo.indicator":        "REPLAY %s",
+		"slowmo.speed":            "%g×",
+		"privacy.title":           "Privacy mode",
+		"privacy.since":           "Cameras off since %s",
+		"error.privacy":           "%s stays off in privacy mode",
+		"schedule.privacy_done":   "Scheduled %s done",
+		"status.saved":            "Saved %s",
+		"status.recording":        "Recording %s",
+		"status.rec_stopped":      "Stopped recording %s",
+		"status.rec_all":          "Recording %d cameras with frame timestamps",
+		"status.rec_all_stopped":  "Stopped %d recordings",
+		"alert.motion":            "Motion on %s",
+		"alert.offline":           "%s stopped sending frames",
+		"alert.recording":         "Recording of %s failed",
+		"error.not_stream":        "%s is not streaming",
+		"error.no_history":        "no frames received recently",
+		"error.device_locked":     "%s is in use by another instance of the app, PID %d; start with -take-over to take it over",
+		"error.device_busy":       "%s is in use by PID %d (%s)",
+		"error.memory_budget":     "Over the memory budget, close cameras or views first",
+		"error.stitch_align":      "could not align the cameras (match %.2f); check that their views overlap",
+		"error.set_control":       "failed to set %s: %w",
+		"menu.rename":             "Rename",
+		"menu.disable":            "Disable stream",
+		"menu.enable":             "Enable stream",
+		"menu.controls":           "Controls...",
+		"menu.snapshot":           "Snapshot",
+		"menu.start_rec":          "Start recording",
+		"menu.stop_rec":           "Stop recording",
+		"menu.pop_out":            "Pop out window",
+		"menu.dock":               "Close pop-out",
+		"menu.white_balance":      "White balance...",
+		"menu.reset_wb":           "Reset white balance",
+		"menu.denoise":            "Denoise: %s",
+		"menu.palette":            "Palette: %s",
+		"menu.moment_gif":         "Last %gs as GIF",
+		"menu.moment_webp":        "Last %gs as WebP",
+		"palette.grey":            "grey",
+		"palette.ironbow":         "ironbow",
+		"palette.rainbow":         "rainbow",
+		"denoise.off":             "off",
+		"denoise.low":             "low",
+		"denoise.medium":          "medium",
+		"denoise.high":            "high",
+		"menu.stack_average":      "Average %d frames",
+		"menu.stack_max":          "Max-stack %d frames",
+		"menu.cancel_stack":       "Cancel stacking",
+		"menu.bracket":            "Bracket exposure (%d frames)",
+		"menu.cancel_bracket":     "Cancel bracketing",
+		"menu.light_on":           "Light on",
+		"menu.light_off":          "Light off",
+		"menu.calibrate":          "Calibrate scale...",
+		"menu.keystone":           "Perspective correction...",
+		"menu.stitch":             "Stitch with %s",
+		"menu.stop_stitch":        "Stop stitching",
+		"controls.title":          "Controls: %s",
+		"controls.none":           "No controls available",
+		"controls.close":          "Close",
+		"controls.profile":        "Profile: %s",
+		"controls.no_profile":     "none",
+		"controls.no_profiles":    "No saved profiles",
+		"controls.save_profile":   "Save profile...",
+		"controls.profile_name":   "Profile name: %s",
+		"tray.show":               "Show window",
+		"tray.hide":               "Hide window",
+		"tray.quit":               "Quit",
+		"sessions.title":          "Sessions",
+		"sessions.none":           "No saved sessions",
+		"about.title":             "About go-camApp",
+		"about.version":           "Version: %s",
+		"about.revision":          "Commit: %s",
+		"about.built":             "Built: %s",
+		"about.runtime":           "Runtime: %s %s",
+		"about.checking":          "Checking for updates...",
+		"about.check_failed":      "Update check failed, see the log",
+		"about.update_available":  "Update available: %s\n%s",
+		"about.up_to_date":        "Up to date (latest release %s)",
+		"status.update_available": "Update available: %s (F1 for details)",
+		"about.support_bundle":    "Create support bundle",
+		"status.support_bundle":   "Support bundle saved to %s",
+		"sessions.save":           "Save current as...",
+		"sessions.bundles":        "Configuration bundles",
+		"sessions.import":         "Import %s",
+		"sessions.export":         "Export configuration",
+		"bundle.state":            "Exported from %s",
+		"status.bundle_exported":  "Configuration exported to %s",
+		"status.bundle_secrets":   " (%d settings with passwords left out)",
+		"status.bundle_imported":  "Imported configuration %s from %s",
+		"status.bundle_restart":   "; its command line settings apply after a restart",
+		"sessions.name":           "Name: %s",
+		"sessions.loaded":         "Loaded session %s",
+		"touch.prev":              "< Prev",
+		"touch.next":              "Next >",
+		"status.touch":            "Touch mode: swipe to switch cameras, pinch to zoom",
+		"status.metering":         "Metering exposure of %s on the selected region",
+		"status.metering_off":     "Auto exposure restored for %s",
+		"status.wb_pick":          "Click a neutral grey area of %s",
+		"status.wb_set":           "White balance calibrated for %s",
+		"status.wb_reset":         "White balance reset for %s",
+		"status.denoise":          "Denoise for %s: %s",
+		"status.palette":          "Palette for %s: %s",
+		"status.stacking":         "Stacking %s: %d/%d frames",
+		"status.bracketing":       "Bracketing %s: %d/%d frames",
+		"status.bracket_saved":    "Saved %d bracket images, last %s",
+		"error.bracket_exposure":  "%s has no exposure time control to bracket",
+		"error.bracket_metering":  "clear the metering region of %s before bracketing",
+		"error.bracket_timeout":   "bracketing %s stalled after %d of %d frames",
+		"error.bracket_failed":    "bracketing %s failed: %w",
+		"status.light_on":         "Light of %s on",
+		"status.light_off":        "Light of %s off",
+		"status.light_warmup":     "Switching on the light of %s for the snapshot...",
+		"error.no_light":          "%s has no light; set one with -light",
+		"status.day_night":        "%s switched to the %s profile (scene luma %.0f)",
+		"error.no_profile":        "%s has no control profile %q",
+		"status.profile_applied":  "%s: profile %q applied",
+		"status.profile_saved":    "%s: profile %q saved",
+		"status.view":             "View: %s",
+		"status.dual":             "Dual view: click a pane or press Tab to control it",
+		"status.dual_off":         "Single view",
+		"status.threshold":        "Threshold: %d",
+		"status.baseline":         "Baseline of %s frozen; changes are highlighted",
+		"status.baseline_cleared": "Baseline of %s cleared",
+		"status.follow":           "Following the object on %s (F to stop)",
+		"status.follow_off":       "Stopped following",
+		"view.normal":             "normal",
+		"view.edges":              "edges",
+		"view.threshold":          "threshold",
+		"view.false_color":        "false color",
+		"view.difference":         "difference (B freezes the baseline)",
+		"status.grid_off":         "Grid off",
+		"status.grid_px":          "Grid: %.0f px",
+		"status.grid_mm":          "Grid: %g mm",
+		"status.calibrate_drag":   "Drag across a feature of known length on %s",
+		"status.calibrate_length": "Length in mm: %s (Enter to apply, Esc to cancel)",
+		"status.calibrated":       "%s calibrated: %.2f px/mm",
+		"status.keystone_edit":    "Drag the corners of %s onto a rectangle, Enter to apply, Backspace to reset",
+		"status.keystone_set":     "Perspective correction applied to %s",
+		"status.keystone_off":     "Perspective correction removed from %s",
+		"status.stitch_aligning":  "Aligning %s and %s...",
+		"status.stitched":         "Stitched %s and %s, offset %d, %d px",
+		"status.stitch_off":       "Stitching stopped",
+		"error.calibrate_length":  "invalid length %q",
+		"measure.line":            "Line: %+.2f° offset %s",
+		"measure.no_line":         "Line: not found",
+		"measure.circle_x":        "Circle X: %s",
+		"measure.circle_y":        "Circle Y: %s",
+		"measure.circle_d":        "Diameter: %s",
+		"measure.no_circle":       "Circle: not found",
+	},
+	"de": {
+		"app.title":               "Multi-Kamera-App",
+		"header.title":            "Multi-Kamera-System",
+		"panel.cameras":           "Kameras",
+		"panel.no_cameras":        "Keine Kameras gefunden",
+		"camera.default":          "Kamera %d",
+		"camera.folder":           "Ordner %s",
+		"camera.replay":           "%s (Wiedergabe)",
+		"camera.playback":         "%s (Aufnahme)",
+		"camera.remote":           "%s auf %s",
+		"status.init":             "Kameras werden initialisiert...",
+		"status.ready":            "Bereit",
+		"status.found":            "%d Kamerageräte gefunden",
+		"status.initialized":      "%d/%d Kameras initialisiert",
+		"status.no_devices":       "Keine Kamerageräte gefunden",
+		"status.list_error":       "Fehler beim Auflisten der Geräte: %v",
+		"status.selected":         "%s | Ausgewählt: %s | Pfeiltasten oder Ziffern verwenden",
+		"status.retry_started":    "%s nach erneutem Versuch gestartet",
+		"status.taken_over":       "%s an die Instanz mit PID %d übergeben",
+		"status.watchdog_restart": "Pipeline blockiert, %d Kameras neu gestartet",
+		"error.retry_failed":      "%s nach %d Versuchen nicht gestartet",
+		"controls.mode":           "Modus: %s",
+		"mode.downgraded":         "%s (Ausweichmodus, %dx%d abgelehnt)",
+		"status.frame_loss":       " | Frames verloren am Gerät: %d, verworfen von der App: %d",
+		"status.bar_fps":          "%.0f fps",
+		"status.bar_rec":          "REC %d",
+		"status.bar_disk":         "%s frei",
+		"status.bar_clients":      "%d Clients",
+		"status.bar_locked":       "Gesperrt",
+		"status.bar_unlocked":     "Entsperrt",
+		"status.kiosk_locked":     "Gesperrt: nur Kamerawechsel und Zoom möglich; K entsperrt",
+		"status.kiosk_unlocked":   "Entsperrt; K sperrt wieder",
+		"error.kiosk_pin":         "Falsche PIN",
+		"error.kiosk_lockout":     "Zu viele falsche PINs, erneut versuchen in %v",
+		"kiosk.enter_pin":         "PIN zum Entsperren eingeben",
+		"kiosk.back":              "Entf",
+		"kiosk.ok":                "OK",
+		"kiosk.cancel":            "Abbrechen",
+		"status.health_ok":        "%s: liefert %.0f fps",
+		"status.health_degraded":  "%s: %.0f fps, %d Frames verloren am Gerät, %d verworfen von der App",
+		"status.health_offline":   "%s: liefert keine Frames",
+		"status.health_off":       "%s: deaktiviert",
+		"status.fps_detail":       "UI: %.0f fps | Kameras: insgesamt %.0f Frames pro Sekunde",
+		"status.rec_detail":       "Aufnahme von %s",
+		"status.disk_detail":      "%s frei von %s für %s",
+		"status.clients_detail":   "%d Stream-Clients an der Kamera-API",
+		"status.bar_toasts":       "%d Meldungen",
+		"status.bar_schedule":     "Nächste: %s %s",
+		"status.bar_mirror":       "Spiegel %s",
+		"status.bar_mirroring":    "Spiegeln %d%%",
+		"status.mirror_detail":    "Spiegel nach %s",
+		"status.mirror_progress":  ", %s von %s kopiert",
+		"mirror.result":           "%d Dateien, %s kopiert",
+		"schedule.done":           "Geplante Aktion %s für %d Kameras ausgeführt",
+		"schedule.failed":         "Geplante Aktion %s fehlgeschlagen: %v",
+		"schedule.entry":          "%s als Nächstes %s",
+		"schedule.last":           " (zuletzt %s: %s)",
+		"schedule.never":          "nie",
+		"toast.history":           "Benachrichtigungen",
+		"toast.none":              "Noch keine Benachrichtigungen",
+		"toast.close":             "Schließen",
+		"toast.at":                "%s  %s",
+		"toast.repeated":          "%s (%d-mal)",
+		"toast.init_failed":       "%s konnte nicht gestartet werden: %v",
+		"toast.capture_stopped":   "Bilderfassung von %s angehalten: %v",
+		"toast.frame_error":       "Fehlerhaftes Bild von %s: %v",
+		"toast.recording_failed":  "Aufzeichnung von %s beendet: %v",
+		"toast.sidecar_failed":    "Schnappschuss von %s ohne Metadaten gespeichert: %v",
+		"toast.disk_low":          "Nur noch %s frei für Aufnahmen in %s",
+		"toast.upload_failed":     "Hochladen von %s fehlgeschlagen, neuer Versuch folgt: %v",
+		"toast.mirror_failed":     "Spiegeln nach %s fehlgeschlagen: %v",
+		"status.theme":            "Design: %s",
+		"status.language":         "Sprache: %s",
+		"status.disabled":         "%s deaktiviert",
+		"status.enabled":          "%s aktiviert",
+		"status.privacy_on":       "Privatsphäre-Modus an, Kameras gestoppt",
+		"status.privacy_off":      "Privatsphäre-Modus aus",
+		"status.bar_privacy":      "PRIVAT",
+		"status.privacy_detail":   "Privatsphäre-Modus seit %s, %d Kameras starten danach wieder (P)",
+		"status.clip_in":          "Clip beginnt bei Bild %d",
+		"status.clip_out":         "Clip endet bei Bild %d",
+		"toast.clip_exporting":    "Exportiere %d Bilder nach %s",
+		"toast.clip_exported":     "%d Bilder nach %s exportiert",
+		"toast.clip_failed":       "Clip-Export fehlgeschlagen: %v",
+		"toast.moment_exported":   "Moment in %s gespeichert",
+		"toast.moment_failed":     "Speichern des Moments fehlgeschlagen: %v",
+		"clip.playing":            "Wiedergabe",
+		"clip.paused":             "Pause",
+		"clip.position":           "%s · Bild %d/%d · %s · Clip %d-%d (%s)",
+		"clip.keys":               "Leertaste Pause · Umschalt+←/→ Einzelbild · [ ] Clip-Anfang/-Ende · E Export · Umschalt+E umkodieren",
+		"freeze.pause":            "Pause",
+		"freeze.live":             "Live",
+		"freeze.position":         "Angehalten · Bild %d/%d · %s · %.2fs vor dem neuesten",
+		"freeze.keys":             "Leertaste live · Umschalt+←/→ Einzelbild · M Zeitlupe ½× · Umschalt+M ¼×",
+		"status.frozen":           "%s angehalten auf den letzten %d Bildern",
+		"status.live":             "%s ist wieder live",
+		"status.slowmo":           "Wiederholung von %s mit %s",
+		"slowmo.indicator":        "WIEDERHOLUNG %s",
+		"slowmo.speed":            "%g×",
+		"privacy.title":           "Privatsphäre-Modus",
+		"privacy.since":           "Kameras aus seit %s",
+		"error.privacy":           "%s bleibt im Privatsphäre-Modus aus",
+		"schedule.privacy_done":   "Geplante Aktion %s ausgeführt",
+		"status.saved":            "%s gespeichert",
+		"status.recording":        "Aufnahme von %s",
+		"status.rec_stopped":      "Aufnahme von %s beendet",
+		"status.rec_all":          "%d Kameras werden mit Zeitstempeln aufgenommen",
+		"status.rec_all_stopped":  "%d Aufnahmen beendet",
+		"alert.motion":            "Bewegung bei %s",
+		"alert.offline":           "%s sendet keine Bilder mehr",
+		"alert.recording":         "Aufnahme von %s fehlgeschlagen",
+		"error.not_stream":        "%s streamt nicht",
+		"error.no_history":        "in letzter Zeit keine Bilder empfangen",
+		"error.device_locked":     "%s wird von einer anderen Instanz der App verwendet, PID %d; mit -take-over übernehmen",
+		"error.device_busy":       "%s wird von PID %d (%s) verwendet",
+		"error.memory_budget":     "Speicherbudget überschritten, zuerst Kameras oder Ansichten schließen",
+		"error.stitch_align":      "Kameras konnten nicht ausgerichtet werden (Übereinstimmung %.2f); überlappen die Bilder?",
+		"error.set_control":       "%s konnte nicht gesetzt werden: %w",
+		"menu.rename":             "Umbenennen",
+		"menu.disable":            "Stream deaktivieren",
+		"menu.enable":             "Stream aktivieren",
+		"menu.controls":           "Regler...",
+		"menu.snapshot":           "Schnappschuss",
+		"menu.start_rec":          "Aufnahme starten",
+		"menu.stop_rec":           "Aufnahme beenden",
+		"menu.pop_out":            "In eigenem Fenster",
+		"menu.dock":               "Eigenes Fenster schließen",
+		"menu.white_balance":      "Weißabgleich...",
+		"menu.reset_wb":           "Weißabgleich zurücksetzen",
+		"menu.denoise":            "Rauschfilter: %s",
+		"menu.palette":            "Palette: %s",
+		"menu.moment_gif":         "Letzte %gs als GIF",
+		"menu.moment_webp":        "Letzte %gs als WebP",
+		"palette.grey":            "Graustufen",
+		"palette.ironbow":         "Ironbow",
+		"palette.rainbow":         "Regenbogen",
+		"denoise.off":             "aus",
+		"denoise.low":             "schwach",
+		"denoise.medium":          "mittel",
+		"denoise.high":            "stark",
+		"menu.stack_average":      "%d Bilder mitteln",
+		"menu.stack_max":          "%d Bilder maximal stapeln",
+		"menu.cancel_stack":       "Stapeln abbrechen",
+		"menu.bracket":            "Belichtungsreihe (%d Bilder)",
+		"menu.cancel_bracket":     "Belichtungsreihe abbrechen",
+		"menu.light_on":           "Licht an",
+		"menu.light_off":          "Licht aus",
+		"menu.calibrate":          "Maßstab kalibrieren...",
+		"menu.keystone":           "Perspektivkorrektur...",
+		"menu.stitch":             "Mit %s zusammenfügen",
+		"menu.stop_stitch":        "Zusammenfügen beenden",
+		"controls.title":          "Regler: %s",
+		"controls.none":           "Keine Regler verfügbar",
+		"controls.close":          "Schließen",
+		"controls.profile":        "Profil: %s",
+		"controls.no_profile":     "keins",
+		"controls.no_profiles":    "Keine gespeicherten Profile",
+		"controls.save_profile":   "Profil speichern...",
+		"controls.profile_name":   "Profilname: %s",
+		"tray.show":               "Fenster anzeigen",
+		"tray.hide":               "Fenster ausblenden",
+		"tray.quit":               "Beenden",
+		"sessions.title":          "Sitzungen",
+		"sessions.none":           "Keine gespeicherten Sitzungen",
+		"about.title":             "Über go-camApp",
+		"about.version":           "Version: %s",
+		"about.revision":          "Commit: %s",
+		"about.built":             "Erstellt: %s",
+		"about.runtime":           "Laufzeit: %s %s",
+		"about.checking":          "Suche nach Updates...",
+		"about.check_failed":      "Update-Prüfung fehlgeschlagen, siehe Log",
+		"about.update_available":  "Update verfügbar: %s\n%s",
+		"about.up_to_date":        "Aktuell (neueste Version %s)",
+		"status.update_available": "Update verfügbar: %s (F1 für Details)",
+		"about.support_bundle":    "Support-Paket erstellen",
+		"status.support_bundle":   "Support-Paket gespeichert unter %s",
+		"sessions.save":           "Aktuelle speichern unter...",
+		"sessions.bundles":        "Konfigurationspakete",
+		"sessions.import":         "%s importieren",
+		"sessions.export":         "Konfiguration exportieren",
+		"bundle.state":            "Exportiert von %s",
+		"status.bundle_exported":  "Konfiguration exportiert nach %s",
+		"status.bundle_secrets":   " (%d Einstellungen mit Passwörtern ausgelassen)",
+		"status.bundle_imported":  "Konfiguration %s von %s importiert",
+		"status.bundle_restart":   "; ihre Befehlszeilen-Einstellungen gelten nach einem Neustart",
+		"sessions.name":           "Name: %s",
+		"sessions.loaded":         "Sitzung %s geladen",
+		"touch.prev":              "< Zurück",
+		"touch.next":              "Weiter >",
+		"status.touch":            "Touch-Modus: Wischen wechselt die Kamera, Spreizen zoomt",
+		"status.metering":         "Belichtung von %s wird im gewählten Bereich gemessen",
+		"status.metering_off":     "Automatische Belichtung für %s wiederhergestellt",
+		"status.wb_pick":          "Auf einen neutralgrauen Bereich von %s klicken",
+		"status.wb_set":           "Weißabgleich für %s kalibriert",
+		"status.wb_reset":         "Weißabgleich für %s zurückgesetzt",
+		"status.denoise":          "Rauschfilter für %s: %s",
+		"status.palette":          "Palette für %s: %s",
+		"status.stacking":         "Stapeln von %s: %d/%d Bilder",
+		"status.bracketing":       "Belichtungsreihe von %s: %d/%d Bilder",
+		"status.bracket_saved":    "%d Bilder der Belichtungsreihe gespeichert, zuletzt %s",
+		"error.bracket_exposure":  "%s hat keine Belichtungszeit-Steuerung für eine Belichtungsreihe",
+		"error.bracket_metering":  "vor der Belichtungsreihe den Messbereich von %s entfernen",
+		"error.bracket_timeout":   "Belichtungsreihe von %s nach %d von %d Bildern hängen geblieben",
+		"error.bracket_failed":    "Belichtungsreihe von %s fehlgeschlagen: %w",
+		"status.light_on":         "Licht von %s an",
+		"status.light_off":        "Licht von %s aus",
+		"status.light_warmup":     "Licht von %s wird für den Schnappschuss eingeschaltet...",
+		"error.no_light":          "%s hat kein Licht; mit -light einrichten",
+		"status.day_night":        "%s auf Profil %s umgeschaltet (Szenenhelligkeit %.0f)",
+		"error.no_profile":        "%s hat kein Steuerungsprofil %q",
+		"status.profile_applied":  "%s: Profil %q angewendet",
+		"status.profile_saved":    "%s: Profil %q gespeichert",
+		"status.view":             "Ansicht: %s",
+		"status.dual":             "Doppelansicht: Bereich anklicken oder Tab drücken, um ihn zu steuern",
+		"status.dual_off":         "Einzelansicht",
+		"status.threshold":        "Schwelle: %d",
+		"status.baseline":         "Referenzbild von %s eingefroren; Änderungen werden hervorgehoben",
+		"status.baseline_cleared": "Referenzbild von %s gelöscht",
+		"status.follow":           "Objekt auf %s wird verfolgt (F beendet)",
+		"status.follow_off":       "Verfolgung beendet",
+		"view.normal":             "normal",
+		"view.edges":              "Kanten",
+		"view.threshold":          "Schwellwert",
+		"view.false_color":        "Falschfarben",
+		"view.difference":         "Differenz (B friert die Referenz ein)",
+		"status.grid_off":         "Raster aus",
+		"status.grid_px":          "Raster: %.0f px",
+		"status.grid_mm":          "Raster: %g mm",
+		"status.calibrate_drag":   "Über ein Merkmal bekannter Länge auf %s ziehen",
+		"status.calibrate_length": "Länge in mm: %s (Enter übernimmt, Esc bricht ab)",
+		"status.calibrated":       "%s kalibriert: %.2f px/mm",
+		"status.keystone_edit":    "Ecken von %s auf ein Rechteck ziehen, Enter übernimmt, Rücktaste setzt zurück",
+		"status.keystone_set":     "Perspektivkorrektur für %s übernommen",
+		"status.keystone_off":     "Perspektivkorrektur für %s entfernt",
+		"status.stitch_aligning":  "%s und %s werden ausgerichtet...",
+		"status.stitched":         "%s und %s zusammengefügt, Versatz %d, %d px",
+		"status.stitch_off":       "Zusammenfügen beendet",
+		"error.calibrate_length":  "ungültige Länge %q",
+		"measure.line":            "Linie: %+.2f° Versatz %s",
+		"measure.no_line":         "Linie: nicht gefunden",
+		"measure.circle_x":        "Kreis X: %s",
+		"measure.circle_y":        "Kreis Y: %s",
+		"measure.circle_d":        "Durchmesser: %s",
+		"measure.no_circle":       "Kreis: nicht gefunden",
+	},
+}
+
+// language is the active catalog's language code
+var language = "en"
+
+// languageNames lists the available languages in a stable order
+func languageNames() []string {
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setLanguage activates the catalog for a language code. Region and encoding
+// suffixes are ignored, so "de_DE.UTF-8" selects "de".
+func setLanguage(code string) error {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "_-."); i >= 0 {
+		code = code[:i]
+	}
+	if _, ok := catalogs[code]; !ok {
+		return fmt.Errorf("unknown language %q (available: %s)", code, strings.Join(languageNames(), ", "))
+	}
+	language = code
+	return nil
+}
+
+// cycleLanguage switches to the next language and returns its code
+func cycleLanguage() string {
+	names := languageNames()
+	for i, name := range names {
+		if name == language {
+			language = names[(i+1)%len(names)]
+			break
+		}
+	}
+	return language
+}
+
+// defaultLanguage returns the language code from the environment's locale,
+// or "en" when it has none
+func defaultLanguage() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(env); value != "" && value != "C" && value != "POSIX" {
+			return value
+		}
+	}
+	return "en"
+}
+
+// message looks up id in the active language. Missing translations fall back
+// to English, and unknown IDs to the ID itself so they stand out in the UI.
+func message(id string) string {
+	if msg, ok := catalogs[language][id]; ok {
+		return msg
+	}
+	if msg, ok := catalogs["en"][id]; ok {
+		return msg
+	}
+	return id
+}
+
+// tr returns the message for id in the active language, formatted with args
+func tr(id string, args ...any) string {
+	if len(args) == 0 {
+		return message(id)
+	}
+	return fmt.Sprintf(message(id), args...)
+}
+
+// trErr is tr for error messages, so %w wraps its argument
+func trErr(id string, args ...any) error {
+	return fmt.Errorf(message(id), args...)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Inspector draws the bounding boxes and IDs of layout elements on top of the
+// UI, toggled with F12. The Go port of Clay does not render its own debug
+// view (SetDebugModeEnabled only reserves space for it), so the inspector
+// works from the element IDs handed out by SafeID during the frame.
+type Inspector struct {
+	Enabled bool
+	seen    map[uint32]bool
+	ids     []clay.ElementId
+}
+
+var inspector Inspector
+
+// Toggle switches the inspector on or off
+func (in *Inspector) Toggle() {
+	in.Enabled = !in.Enabled
+	in.BeginFrame()
+}
+
+// BeginFrame forgets the elements tracked during the previous frame
+func (in *Inspector) BeginFrame() {
+	if in.seen == nil {
+		in.seen = make(map[uint32]bool)
+	}
+	clear(in.seen)
+	in.ids = in.ids[:0]
+}
+
+// Track records an element ID so it can be outlined at the end of the frame
+func (in *Inspector) Track(id clay.ElementId) {
+	if !in.Enabled || in.seen[id.Id] {
+		return
+	}
+	in.seen[id.Id] = true
+	in.ids = append(in.ids, id)
+}
+
+// Render outlines every tracked element and details the innermost one under
+// the pointer
+func (in *Inspector) Render(renderer *sdl.Renderer, pointer clay.Vector2) {
+	if !in.Enabled {
+		return
+	}
+
+	var (
+		hovered     clay.ElementId
+		hoveredBox  clay.BoundingBox
+		hoveredArea float32 = -1
+	)
+
+	_ = renderer.SetDrawColor(255, 0, 255, 160)
+	for _, id := range in.ids {
+		element := clay.GetElementData(id)
+		if !element.Found {
+			continue
+		}
+		bbox := element.BoundingBox
+		rect := sdl.FRect{X: bbox.X, Y: bbox.Y, W: bbox.Width, H: bbox.Height}
+		_ = renderer.RenderRect(&rect)
+		_ = renderer.DebugText(bbox.X+2, bbox.Y+2, SafeIDName(id))
+
+		inside := pointer.X >= bbox.X && pointer.X <= bbox.X+bbox.Width &&
+			pointer.Y >= bbox.Y && pointer.Y <= bbox.Y+bbox.Height
+		area := bbox.Width * bbox.Height
+		if inside && (hoveredArea < 0 || area < hoveredArea) {
+			hovered, hoveredBox, hoveredArea = id, bbox, area
+		}
+	}
+
+	if hoveredArea < 0 {
+		return
+	}
+
+	// Highlight the hovered element and describe it next to the pointer
+	rect := sdl.FRect{X: hoveredBox.X, Y: hoveredBox.Y, W: hoveredBox.Width, H: hoveredBox.Height}
+	_ = renderer.SetDrawColor(0, 255, 255, 60)
+	_ = renderer.RenderFillRect(&rect)
+
+	info := fmt.Sprintf("%s  x=%.0f y=%.0f w=%.0f h=%.0f", SafeIDName(hovered),
+		hoveredBox.X, hoveredBox.Y, hoveredBox.Width, hoveredBox.Height)
+	infoRect := sdl.FRect{
+		X: pointer.X + 12,
+		Y: pointer.Y + 12,
+		W: float32(len(info)*sdl.DEBUG_TEXT_FONT_CHARACTER_SIZE) + 8,
+		H: sdl.DEBUG_TEXT_FONT_CHARACTER_SIZE + 8,
+	}
+	_ = renderer.SetDrawColor(0, 0, 0, 220)
+	_ = renderer.RenderFillRect(&infoRect)
+	_ = renderer.SetDrawColor(255, 255, 255, 255)
+	_ = renderer.DebugText(infoRect.X+4, infoRect.Y+4, info)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+// While the window is being resized, scrolled or dragged in, background
+// cameras are not decoded and their thumbnails are not uploaded, so that
+// weak hardware spends the frame on the main view and the layout. Their
+// frames are still recorded, streamed and kept for snapshots, and the
+// thumbnails catch up once the interaction is over.
+
+// interactionHold is how long after the last resize, scroll or drag event
+// background decoding stays paused
+const interactionHold = 300 * time.Millisecond
+
+// interactingUntil is when background decoding resumes
+var interactingUntil time.Time
+
+// noteInteraction pauses background decoding for interactionHold
+func noteInteraction() {
+	interactingUntil = time.Now().Add(interactionHold)
+}
+
+// backgroundPaused reports whether cameras outside the main panes are left
+// undecoded, which they are during an interaction
+func backgroundPaused() bool {
+	return time.Now().Before(interactingUntil)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// keystoneHandleSize is the on-screen size of the corner handles, before UI
+// scaling
+const keystoneHandleSize = 12
+
+// fullFrameCorners are the corners of an uncorrected frame
+var fullFrameCorners = [4]sdl.FPoint{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 1, Y: 1}, {X: 0, Y: 1}}
+
+// Keystone rectifies the frames of an angled camera into a top-down view:
+// the quadrilateral Corners, in normalized frame coordinates clockwise from
+// the top-left, is stretched over the whole frame. Measurements, overlays and
+// the rest of the processing then see the rectified frame.
+type Keystone struct {
+	Corners [4]sdl.FPoint
+
+	// The sampling map is built for one frame size: for every output
+	// pixel the offset of the top-left source pixel, or -1 outside the
+	// frame, and the bilinear weights of the pixels right and below it
+	width, height int
+	offsets       []int32
+	fx, fy        []uint8
+	source        []uint8
+}
+
+// KeystoneEditState tracks the corners being dragged on the main view
+type KeystoneEditState struct {
+	Active  bool
+	Camera  int
+	Corners [4]sdl.FPoint
+	// Dragging is the corner being dragged, or -1
+	Dragging int
+	// Previous is the correction in use before editing started, which the
+	// uncorrected preview replaces until the edit is applied or cancelled
+	Previous *Keystone
+}
+
+// newKeystone returns the correction for the given corners, or nil if they
+// are the full frame or do not form a usable quadrilateral
+func newKeystone(corners [4]sdl.FPoint) *Keystone {
+	if corners == fullFrameCorners {
+		return nil
+	}
+	if _, ok := keystoneMapping(corners); !ok {
+		return nil
+	}
+	return &Keystone{Corners: corners}
+}
+
+// projective is a mapping from the unit square to a quadrilateral
+type projective struct {
+	a, b, c, d, e, f, g, h float64
+}
+
+// keystoneMapping returns the projective mapping of the unit square onto
+// the corners (Heckbert's square-to-quad solution)
+func keystoneMapping(corners [4]sdl.FPoint) (projective, bool) {
+	x0, y0 := float64(corners[0].X), float64(corners[0].Y)
+	x1, y1 := float64(corners[1].X), float64(corners[1].Y)
+	x2, y2 := float64(corners[2].X), float64(corners[2].Y)
+	x3, y3 := float64(corners[3].X), float64(corners[3].Y)
+
+	sx, sy := x0-x1+x2-x3, y0-y1+y2-y3
+	dx1, dx2 := x1-x2, x3-x2
+	dy1, dy2 := y1-y2, y3-y2
+	den := dx1*dy2 - dx2*dy1
+	if math.Abs(den) < 1e-9 {
+		return projective{}, false
+	}
+	g := (sx*dy2 - dx2*sy) / den
+	h := (dx1*sy - sx*dy1) / den
+	return projective{
+		a: x1 - x0 + g*x1, b: x3 - x0 + h*x3, c: x0,
+		d: y1 - y0 + g*y1, e: y3 - y0 + h*y3, f: y0,
+		g: g, h: h,
+	}, true
+}
+
+// apply maps a point of the unit square into the quadrilateral
+func (p projective) apply(u, v float64) (float64, float64, bool) {
+	w := p.g*u + p.h*v + 1
+	if w <= 0 {
+		return 0, 0, false
+	}
+	return (p.a*u + p.b*v + p.c) / w, (p.d*u + p.e*v + p.f) / w, true
+}
+
+// buildMap computes the sampling map for a frame size
+func (k *Keystone) buildMap(width, height, stride int) {
+	k.width, k.height = width, height
+	k.offsets = make([]int32, width*height)
+	k.fx = make([]uint8, width*height)
+	k.fy = make([]uint8, width*height)
+	mapping, _ := keystoneMapping(k.Corners)
+
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			k.offsets[i] = -1
+			u, v, ok := mapping.apply((float64(x)+0.5)/float64(width), (float64(y)+0.5)/float64(height))
+			if !ok {
+				continue
+			}
+			sx, sy := u*float64(width)-0.5, v*float64(height)-0.5
+			if sx < 0 || sy < 0 || sx > float64(width-1) || sy > float64(height-1) {
+				continue
+			}
+			// Keep the right and lower neighbours inside the frame
+			ix, iy := min(int(sx), width-2), min(int(sy), height-2)
+			k.offsets[i] = int32(iy*stride + ix*4)
+			k.fx[i] = uint8(min((sx-float64(ix))*256, 255))
+			k.fy[i] = uint8(min((sy-float64(iy))*256, 255))
+		}
+	}
+}
+
+// applyKeystone rectifies the frame in place. It is called with FrameMutex
+// held.
+func applyKeystone(camera *CameraInstance, img *image.RGBA) {
+	k := camera.Keystone
+	if k == nil {
+		return
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 2 || height < 2 {
+		return
+	}
+	if k.width != width || k.height != height {
+		k.buildMap(width, height, img.Stride)
+	}
+	k.source = append(k.source[:0], img.Pix...)
+
+	src := k.source
+	for y := range height {
+		row := img.Pix[y*img.Stride:]
+		for x := range width {
+			i := y*width + x
+			out := row[x*4 : x*4+4]
+			offset := k.offsets[i]
+			if offset < 0 {
+				out[0], out[1], out[2] = 0, 0, 0
+				continue
+			}
+			fx, fy := uint32(k.fx[i]), uint32(k.fy[i])
+			p00 := src[offset:]
+			p10 := src[offset+4:]
+			p01 := src[int(offset)+img.Stride:]
+			p11 := src[int(offset)+img.Stride+4:]
+			for c := range 3 {
+				top := uint32(p00[c])*(256-fx) + uint32(p10[c])*fx
+				bottom := uint32(p01[c])*(256-fx) + uint32(p11[c])*fx
+				out[c] = uint8((top*(256-fy) + bottom*fy) >> 16)
+			}
+		}
+	}
+}
+
+// startKeystoneEdit shows a camera uncorrected in the main view with its
+// corners ready to be dragged
+func startKeystoneEdit(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+	if !camera.Active || camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+	selectCamera(appData, index)
+
+	edit := KeystoneEditState{Active: true, Camera: index, Corners: fullFrameCorners, Dragging: -1}
+	camera.FrameMutex.Lock()
+	edit.Previous = camera.Keystone
+	if camera.Keystone != nil {
+		edit.Corners = camera.Keystone.Corners
+	}
+	camera.Keystone = nil
+	camera.FrameMutex.Unlock()
+	appData.Keystone = edit
+	appData.StatusText = tr("status.keystone_edit", camera.Info.Name)
+}
+
+// finishKeystoneEdit applies the dragged corners, or restores the previous
+// correction when cancelled
+func finishKeystoneEdit(appData *CameraAppData, apply bool) {
+	edit := &appData.Keystone
+	if !edit.Active {
+		return
+	}
+	camera := &appData.Cameras[edit.Camera]
+	keystone := edit.Previous
+	if apply {
+		keystone = newKeystone(edit.Corners)
+	}
+	camera.FrameMutex.Lock()
+	camera.Keystone = keystone
+	camera.FrameMutex.Unlock()
+	*edit = KeystoneEditState{}
+
+	switch {
+	case !apply:
+		appData.StatusText = tr("status.ready")
+	case keystone == nil:
+		appData.StatusText = tr("status.keystone_off", camera.Info.Name)
+	default:
+		appData.StatusText = tr("status.keystone_set", camera.Info.Name)
+	}
+}
+
+// handleKeystoneKey applies the edit on Enter, cancels it on Escape and
+// resets the corners to the full frame on Backspace
+func handleKeystoneKey(appData *CameraAppData, scancode sdl.Scancode) bool {
+	if !appData.Keystone.Active {
+		return false
+	}
+	switch scancode {
+	case sdl.SCANCODE_RETURN, sdl.SCANCODE_KP_ENTER:
+		finishKeystoneEdit(appData, true)
+	case sdl.SCANCODE_ESCAPE:
+		finishKeystoneEdit(appData, false)
+	case sdl.SCANCODE_BACKSPACE, sdl.SCANCODE_DELETE:
+		appData.Keystone.Corners = fullFrameCorners
+	default:
+		return false
+	}
+	return true
+}
+
+// startKeystoneDrag picks up the corner handle under a click. It reports
+// whether the click was consumed; clicks elsewhere on the main view are
+// ignored while editing.
+func startKeystoneDrag(appData *CameraAppData, x, y float32) bool {
+	edit := &appData.Keystone
+	if !edit.Active {
+		return false
+	}
+	rect, ok := mainCameraRect()
+	if !ok || edit.Camera != appData.SelectedCamera {
+		finishKeystoneEdit(appData, false)
+		return false
+	}
+	reach := dp(keystoneHandleSize)
+	for i, corner := range edit.Corners {
+		cx, cy := frameToView(rect, corner.X, corner.Y)
+		if abs32(x-cx) <= reach && abs32(y-cy) <= reach {
+			edit.Dragging = i
+			return true
+		}
+	}
+	return x >= rect.X && x <= rect.X+rect.W && y >= rect.Y && y <= rect.Y+rect.H
+}
+
+// updateKeystoneDrag moves the dragged corner to the pointer
+func updateKeystoneDrag(appData *CameraAppData, pointer sdl.FPoint) {
+	edit := &appData.Keystone
+	if !edit.Active || edit.Dragging < 0 {
+		return
+	}
+	rect, ok := mainCameraRect()
+	if !ok {
+		return
+	}
+	u, v := viewToFrame(rect, pointer.X, pointer.Y)
+	edit.Corners[edit.Dragging] = sdl.FPoint{X: min(max(u, 0), 1), Y: min(max(v, 0), 1)}
+}
+
+// finishKeystoneDrag drops the dragged corner
+func finishKeystoneDrag(appData *CameraAppData) {
+	appData.Keystone.Dragging = -1
+}
+
+// renderKeystoneEditor outlines the corrected area and draws the corner
+// handles over the uncorrected preview
+func renderKeystoneEditor(appData *CameraAppData) {
+	edit := &appData.Keystone
+	if !edit.Active || edit.Camera != appData.SelectedCamera {
+		return
+	}
+	rect, ok := mainCameraRect()
+	if !ok {
+		return
+	}
+	renderer := appData.Renderer
+	points := make([]sdl.FPoint, 0, 5)
+	for _, corner := range edit.Corners {
+		x, y := frameToView(rect, corner.X, corner.Y)
+		points = append(points, sdl.FPoint{X: x, Y: y})
+	}
+	points = append(points, points[0])
+
+	_ = renderer.SetDrawColor(uint8(theme.Accent.R), uint8(theme.Accent.G), uint8(theme.Accent.B), 255)
+	_ = renderer.RenderLines(points)
+	size := dp(keystoneHandleSize)
+	for i, p := range points[:4] {
+		handle := sdl.FRect{X: p.X - size/2, Y: p.Y - size/2, W: size, H: size}
+		if i == edit.Dragging {
+			_ = renderer.RenderFillRect(&handle)
+		} else {
+			_ = renderer.RenderRect(&handle)
+		}
+	}
+}
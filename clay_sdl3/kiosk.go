@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Kiosk mode locks the app for installations where operators should only
+// watch: switching cameras and zooming still work, while the context menu,
+// panels, recording, snapshots, renaming, rearranging and every key that
+// changes a setting are ignored. -kiosk-pin starts the app locked; K or the
+// lock in the status bar asks for the PIN, typed or tapped on the keypad,
+// and K locks the app again. After kioskMaxAttempts wrong PINs the prompt is
+// refused for kioskLockout.
+
+const (
+	kioskMaxAttempts = 5
+	kioskLockout     = 30 * time.Second
+	kioskMaxPIN      = 12
+)
+
+// KioskState tracks kiosk mode
+type KioskState struct {
+	// PIN unlocks the app, empty when kiosk mode is off
+	PIN    string
+	Locked bool
+	// Entering is set while the PIN prompt is shown, with the digits typed
+	// so far
+	Entering bool
+	Text     string
+
+	failures  int
+	lockedOut time.Time
+}
+
+var kiosk KioskState
+
+// enableKiosk locks the app behind a PIN, set with -kiosk-pin
+func enableKiosk(pin string) error {
+	if pin == "" {
+		return nil
+	}
+	if len(pin) > kioskMaxPIN || strings.Trim(pin, "0123456789") != "" {
+		return fmt.Errorf("invalid kiosk PIN, expected up to %d digits", kioskMaxPIN)
+	}
+	kiosk = KioskState{PIN: pin, Locked: true}
+	return nil
+}
+
+// lockKiosk locks the app again, closing anything open that kiosk mode
+// does not allow
+func lockKiosk(appData *CameraAppData) {
+	if kiosk.PIN == "" || kiosk.Locked {
+		return
+	}
+	appData.ContextMenu.Open = false
+	closeControlsPanel(appData)
+	closeSessionsPanel(appData)
+	appData.AboutPanel.Open = false
+	appData.WhiteBalancePick.Active = false
+	cancelCalibration(appData)
+	if appData.Keystone.Active {
+		finishKeystoneEdit(appData, false)
+	}
+	finishRename(appData, false)
+	thumbnailDrag = ThumbnailDrag{}
+	kiosk.Locked = true
+	appData.StatusText = tr("status.kiosk_locked")
+	appData.StatusColor = theme.Text
+}
+
+// startKioskEntry shows the PIN prompt, unless too many wrong PINs were
+// entered lately
+func startKioskEntry(appData *CameraAppData, now time.Time) {
+	if wait := kiosk.lockedOut.Sub(now); wait > 0 {
+		setErrorStatus(appData, trErr("error.kiosk_lockout", wait.Round(time.Second)))
+		return
+	}
+	kiosk.Entering = true
+	kiosk.Text = ""
+}
+
+// addKioskDigit adds a digit to the PIN being entered
+func addKioskDigit(digit byte) {
+	if len(kiosk.Text) < kioskMaxPIN {
+		kiosk.Text += string(digit)
+	}
+}
+
+// submitKioskPIN unlocks the app if the PIN entered is right
+func submitKioskPIN(appData *CameraAppData, now time.Time) {
+	entered := kiosk.Text
+	kiosk.Entering, kiosk.Text = false, ""
+	if subtle.ConstantTimeCompare([]byte(entered), []byte(kiosk.PIN)) == 1 {
+		kiosk.Locked = false
+		kiosk.failures = 0
+		appData.StatusText = tr("status.kiosk_unlocked")
+		appData.StatusColor = theme.Success
+		return
+	}
+	kiosk.failures++
+	if kiosk.failures >= kioskMaxAttempts {
+		kiosk.failures = 0
+		kiosk.lockedOut = now.Add(kioskLockout)
+		setErrorStatus(appData, trErr("error.kiosk_lockout", kioskLockout))
+		return
+	}
+	setErrorStatus(appData, trErr("error.kiosk_pin"))
+}
+
+// scancodeDigit returns the digit a key types, if it types one
+func scancodeDigit(scancode sdl.Scancode) (byte, bool) {
+	switch {
+	case scancode >= sdl.SCANCODE_1 && scancode <= sdl.SCANCODE_9:
+		return byte('1' + scancode - sdl.SCANCODE_1), true
+	case scancode >= sdl.SCANCODE_KP_1 && scancode <= sdl.SCANCODE_KP_9:
+		return byte('1' + scancode - sdl.SCANCODE_KP_1), true
+	case scancode == sdl.SCANCODE_0, scancode == sdl.SCANCODE_KP_0:
+		return '0', true
+	}
+	return 0, false
+}
+
+// handleKioskKey handles a key press while the app is locked. It reports
+// whether the key was handled or refused; camera switching keys are left to
+// handleKeyPress.
+func handleKioskKey(appData *CameraAppData, scancode sdl.Scancode) bool {
+	if !kiosk.Locked {
+		return false
+	}
+	if kiosk.Entering {
+		if digit, ok := scancodeDigit(scancode); ok {
+			addKioskDigit(digit)
+			return true
+		}
+		switch scancode {
+		case sdl.SCANCODE_RETURN, sdl.SCANCODE_KP_ENTER:
+			submitKioskPIN(appData, time.Now())
+		case sdl.SCANCODE_BACKSPACE:
+			if len(kiosk.Text) > 0 {
+				kiosk.Text = kiosk.Text[:len(kiosk.Text)-1]
+			}
+		case sdl.SCANCODE_ESCAPE:
+			kiosk.Entering, kiosk.Text = false, ""
+		}
+		return true
+	}
+
+	switch scancode {
+	case sdl.SCANCODE_LEFT, sdl.SCANCODE_RIGHT, sdl.SCANCODE_PAGEUP, sdl.SCANCODE_PAGEDOWN,
+		sdl.SCANCODE_HOME, sdl.SCANCODE_END, sdl.SCANCODE_TAB,
+		sdl.SCANCODE_1, sdl.SCANCODE_2, sdl.SCANCODE_3, sdl.SCANCODE_4,
+		sdl.SCANCODE_5, sdl.SCANCODE_6, sdl.SCANCODE_7, sdl.SCANCODE_8, sdl.SCANCODE_9:
+		return false
+	case sdl.SCANCODE_K:
+		startKioskEntry(appData, time.Now())
+	}
+	return true
+}
+
+// createKioskLayout declares the PIN prompt as a floating element centered
+// over the window
+func createKioskLayout(data *CameraAppData) {
+	if !kiosk.Entering {
+		return
+	}
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("KioskPrompt"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Padding:         clay.PaddingAll(dpu(12)),
+			ChildGap:        dpu(6),
+			ChildAlignment: clay.ChildAlignment{
+				X: clay.ALIGN_X_CENTER,
+			},
+		},
+		Floating: clay.FloatingElementConfig{
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_CENTER,
+				Parent:  clay.ATTACH_POINT_CENTER_CENTER,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(6)),
+		Border: clay.BorderElementConfig{
+			Color: theme.AccentBorder,
+			Width: clay.BorderOutside(dpu(2)),
+		},
+	}, func() {
+		safeText("kiosk-title", tr("kiosk.enter_pin"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+		safeText("kiosk-pin", strings.Repeat("*", len(kiosk.Text))+"_", clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+
+		// A keypad for touch screens
+		rows := [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7", "8", "9"}, {"Back", "0", "OK"}}
+		for _, row := range rows {
+			clay.UI()(clay.ElementDeclaration{
+				Layout: clay.LayoutConfig{
+					ChildGap: tpu(6),
+				},
+			}, func() {
+				for _, key := range row {
+					switch key {
+					case "Back":
+						controlButton("KioskBack", tr("kiosk.back"))
+					case "OK":
+						controlButton("KioskOK", tr("kiosk.ok"))
+					default:
+						controlButton("KioskDigit"+key, " "+key+" ")
+					}
+				}
+			})
+		}
+		controlButton("KioskCancel", tr("kiosk.cancel"))
+	})
+}
+
+// handleKioskClick handles clicks on the PIN prompt, which takes every
+// click while it is shown, and on the lock in the status bar. It reports
+// whether the click was handled.
+func handleKioskClick(appData *CameraAppData, x, y float32) bool {
+	if kiosk.PIN == "" {
+		return false
+	}
+	if !kiosk.Entering {
+		if !pointInElement(SafeID("StatusKiosk"), x, y) {
+			return false
+		}
+		if kiosk.Locked {
+			startKioskEntry(appData, time.Now())
+		} else {
+			lockKiosk(appData)
+		}
+		return true
+	}
+
+	switch {
+	case pointInElement(SafeID("KioskOK"), x, y):
+		submitKioskPIN(appData, time.Now())
+	case pointInElement(SafeID("KioskBack"), x, y):
+		if len(kiosk.Text) > 0 {
+			kiosk.Text = kiosk.Text[:len(kiosk.Text)-1]
+		}
+	case pointInElement(SafeID("KioskCancel"), x, y):
+		kiosk.Entering, kiosk.Text = false, ""
+	default:
+		for digit := byte('0'); digit <= '9'; digit++ {
+			if pointInElement(SafeID("KioskDigit"+string(digit)), x, y) {
+				addKioskDigit(digit)
+				break
+			}
+		}
+	}
+	return true
+}
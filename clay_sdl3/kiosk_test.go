@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+func TestKioskPIN(t *testing.T) {
+	t.Cleanup(func() { kiosk = KioskState{} })
+	for _, pin := range []string{"12a4", "1234567890123"} {
+		if err := enableKiosk(pin); err == nil {
+			t.Errorf("PIN %q accepted", pin)
+		}
+	}
+	if err := enableKiosk("2468"); err != nil || !kiosk.Locked {
+		t.Fatalf("enable: %v", err)
+	}
+
+	appData := &CameraAppData{}
+	// Only camera switching gets through while locked
+	if !handleKioskKey(appData, sdl.SCANCODE_R) || handleKioskKey(appData, sdl.SCANCODE_RIGHT) {
+		t.Error("locked keys")
+	}
+
+	now := time.Now()
+	enter := func(pin string) {
+		startKioskEntry(appData, now)
+		for _, digit := range []byte(pin) {
+			addKioskDigit(digit)
+		}
+		submitKioskPIN(appData, now)
+	}
+	for range kioskMaxAttempts {
+		enter("1111")
+	}
+	if !kiosk.Locked || kiosk.lockedOut.IsZero() {
+		t.Fatal("wrong PINs did not lock out")
+	}
+	startKioskEntry(appData, now)
+	if kiosk.Entering {
+		t.Error("prompt shown during the lockout")
+	}
+
+	now = now.Add(kioskLockout)
+	enter("2468")
+	if kiosk.Locked {
+		t.Fatal("right PIN did not unlock")
+	}
+	if handleKioskKey(appData, sdl.SCANCODE_R) {
+		t.Error("keys refused while unlocked")
+	}
+}
+
+func TestScancodeDigit(t *testing.T) {
+	for scancode, want := range map[sdl.Scancode]byte{
+		sdl.SCANCODE_1: '1', sdl.SCANCODE_9: '9', sdl.SCANCODE_0: '0',
+		sdl.SCANCODE_KP_5: '5', sdl.SCANCODE_KP_0: '0',
+	} {
+		if got, ok := scancodeDigit(scancode); !ok || got != want {
+			t.Errorf("scancode %d: %q", scancode, got)
+		}
+	}
+	if _, ok := scancodeDigit(sdl.SCANCODE_A); ok {
+		t.Error("A is a digit")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/TotallyGamerJet/clay"
 	"github.com/Zyko0/go-sdl3/sdl"
 	"log"
+	"sync/atomic"
 )
 
 func createMultiCameraLayout(data *CameraAppData, renderer *sdl.Renderer) clay.RenderCommandArray {
@@ -13,15 +14,15 @@ func createMultiCameraLayout(data *CameraAppData, renderer *sdl.Renderer) clay.R
 	// Main container
 	clay.UI()(clay.ElementDeclaration{
 		Id:              SafeID("MainContainer"),
-		BackgroundColor: clay.Color{R: 20, G: 20, B: 20, A: 255},
+		BackgroundColor: theme.Background,
 		Layout: clay.LayoutConfig{
 			LayoutDirection: clay.TOP_TO_BOTTOM,
 			Sizing: clay.Sizing{
 				Width:  clay.SizingGrow(0),
 				Height: clay.SizingGrow(0),
 			},
-			Padding:  clay.PaddingAll(16),
-			ChildGap: 16,
+			Padding:  clay.PaddingAll(dpu(16)),
+			ChildGap: dpu(16),
 		},
 	}, func() {
 		// Header
@@ -29,148 +30,56 @@ func createMultiCameraLayout(data *CameraAppData, renderer *sdl.Renderer) clay.R
 			Id: SafeID("HeaderBar"),
 			Layout: clay.LayoutConfig{
 				Sizing: clay.Sizing{
-					Height: clay.SizingFixed(50),
+					Height: clay.SizingFixed(tp(50)),
 					Width:  clay.SizingGrow(0),
 				},
-				Padding: clay.Padding{Left: 16, Right: 16, Top: 12, Bottom: 12},
+				Padding: clay.Padding{Left: dpu(16), Right: dpu(16), Top: dpu(8), Bottom: dpu(8)},
 				ChildAlignment: clay.ChildAlignment{
 					Y: clay.ALIGN_Y_CENTER,
 				},
 			},
-			BackgroundColor: clay.Color{R: 100, G: 50, B: 50, A: 200},
-			CornerRadius:    clay.CornerRadiusAll(1),
+			BackgroundColor: theme.Header,
+			CornerRadius:    clay.CornerRadiusAll(dp(1)),
 		}, func() {
-			safeText("header-title", "Multi-Camera System", clay.TextElementConfig{
+			safeText("header-title", tr("header.title"), clay.TextElementConfig{
 				FontId:    FontIdBody16,
 				FontSize:  12,
-				TextColor: clay.Color{R: 255, G: 255, B: 255, A: 255},
+				TextColor: theme.Text,
 			})
+			// Push the touch toolbar to the right edge
+			clay.UI()(clay.ElementDeclaration{
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{
+						Width: clay.SizingGrow(0),
+					},
+				},
+			}, func() {})
+			createTouchToolbarLayout(data)
 		})
 
 		// Main content area
 		clay.UI()(clay.ElementDeclaration{
 			Id: SafeID("ContentArea"),
 			Layout: clay.LayoutConfig{
-				LayoutDirection: clay.LEFT_TO_RIGHT,
+				LayoutDirection: contentDirection(),
 				Sizing: clay.Sizing{
 					Width:  clay.SizingGrow(0),
 					Height: clay.SizingGrow(0),
 				},
-				ChildGap: 16,
+				ChildGap: dpu(16),
 			},
 		}, func() {
-			// Main camera view (left side)
-			clay.UI()(clay.ElementDeclaration{
-				Id: SafeID("MainCameraContainer"),
-				Layout: clay.LayoutConfig{
-					Sizing: clay.Sizing{
-						Width:  clay.SizingPercent(0.7), // 70% of available width
-						Height: clay.SizingGrow(0),
-					},
-					Padding: clay.PaddingAll(5),
-				},
-				BackgroundColor: clay.Color{R: 40, G: 40, B: 40, A: 255},
-				CornerRadius:    clay.CornerRadiusAll(8),
-				Border: func() clay.BorderElementConfig {
-					if data.SelectedCamera < len(data.Cameras) {
-						return clay.BorderElementConfig{
-							Color: clay.Color{R: 0, G: 150, B: 255, A: 255},
-							Width: clay.BorderAll(3),
-						}
-					}
-					return clay.BorderElementConfig{}
-				}(),
-			}, func() {
-				// Camera view placeholder - actual rendering happens separately
-			})
-
-			// Thumbnails panel (right side)
-			clay.UI()(clay.ElementDeclaration{
-				Id: SafeID("ThumbnailsPanel"),
-				Layout: clay.LayoutConfig{
-					LayoutDirection: clay.TOP_TO_BOTTOM,
-					Sizing: clay.Sizing{
-						Width:  clay.SizingFit(84, 0), // 30% of available width
-						Height: clay.SizingGrow(0),
-					},
-					Padding:  clay.PaddingAll(8),
-					ChildGap: 12,
-				},
-				BackgroundColor: clay.Color{R: 30, G: 30, B: 30, A: 200},
-				CornerRadius:    clay.CornerRadiusAll(4),
-			}, func() {
-				// Thumbnails header
-				if len(data.Cameras) > 0 {
-					//clay.Text("Cameras", clay.TextConfig(clay.TextElementConfig{
-					//	FontId:    FontIdBody16,
-					//	FontSize:  20,
-					//	TextColor: clay.Color{R: 255, G: 255, B: 255, A: 255},
-					//}))
-					safeText("thumbnail", "Cameras", clay.TextElementConfig{
-						FontId:    FontIdBody16,
-						FontSize:  10,
-						TextColor: clay.Color{R: 255, G: 255, B: 255, A: 255},
-					})
-					// Camera thumbnails
-
-					for i := range data.Cameras {
-						//camera := &data.Cameras[i]
-						isSelected := i == data.SelectedCamera
-
-						// Create safe thumbnail ID
-						thumbnailID := fmt.Sprintf("Thumbnail%d", i)
-
-						clay.UI()(clay.ElementDeclaration{
-							Id: SafeID(thumbnailID),
-							Layout: clay.LayoutConfig{
-								Sizing: clay.Sizing{
-									Width:  clay.SizingGrow(80),
-									Height: clay.SizingFixed(60),
-								},
-								Padding: clay.PaddingAll(2),
-							},
-							BackgroundColor: func() clay.Color {
-								if isSelected {
-									return clay.Color{R: 0, G: 100, B: 200, A: 255}
-								} else if clay.Hovered() {
-									return clay.Color{R: 60, G: 60, B: 60, A: 255}
-								}
-								return clay.Color{R: 50, G: 50, B: 50, A: 255}
-							}(),
-							CornerRadius: clay.CornerRadiusAll(4),
-							Border: func() clay.BorderElementConfig {
-								if isSelected {
-									return clay.BorderElementConfig{
-										Color: clay.Color{R: 0, G: 150, B: 255, A: 255},
-										Width: clay.BorderAll(2),
-									}
-								}
-								return clay.BorderElementConfig{}
-							}(),
-						}, func() {
-							// Thumbnail content
-							clay.UI()(clay.ElementDeclaration{
-								Layout: clay.LayoutConfig{
-									LayoutDirection: clay.TOP_TO_BOTTOM,
-									ChildGap:        4,
-									Padding:         clay.PaddingAll(4),
-								},
-							}, func() {})
-						})
-						safeText("thumbnail", fmt.Sprintf("Cam %x", i), clay.TextElementConfig{
-							FontId:    FontIdBody16,
-							FontSize:  8,
-							TextColor: clay.Color{R: 255, G: 255, B: 255, A: 255},
-						})
-					}
-				} else {
-					safeText("no_cam", "No cameras found", clay.TextElementConfig{
-						FontId:    FontIdBody16,
-						FontSize:  16,
-						TextColor: clay.Color{R: 255, G: 100, B: 100, A: 255},
-					})
-				}
-			})
+			if thumbnailEdge == stripTop {
+				createThumbnailStripLayout(data)
+			}
+			// Main camera view, split in two by the dual view
+			createMainPaneLayout(data, 0)
+			if dualView.Enabled {
+				createMainPaneLayout(data, 1)
+			}
+			if thumbnailEdge != stripTop {
+				createThumbnailStripLayout(data)
+			}
 		})
 
 		// Status bar
@@ -178,16 +87,17 @@ func createMultiCameraLayout(data *CameraAppData, renderer *sdl.Renderer) clay.R
 			Id: SafeID("StatusBar"),
 			Layout: clay.LayoutConfig{
 				Sizing: clay.Sizing{
-					Height: clay.SizingFixed(40),
+					Height: clay.SizingFixed(dp(40)),
 					Width:  clay.SizingGrow(0),
 				},
-				Padding: clay.Padding{Left: 16, Right: 16, Top: 8, Bottom: 8},
+				Padding:  clay.Padding{Left: dpu(16), Right: dpu(16), Top: dpu(8), Bottom: dpu(8)},
+				ChildGap: dpu(6),
 				ChildAlignment: clay.ChildAlignment{
 					Y: clay.ALIGN_Y_CENTER,
 				},
 			},
-			BackgroundColor: clay.Color{R: 40, G: 40, B: 40, A: 200},
-			CornerRadius:    clay.CornerRadiusAll(5),
+			BackgroundColor: theme.StatusBar,
+			CornerRadius:    clay.CornerRadiusAll(dp(5)),
 		}, func() {
 			statusText := sanitizeText(data.StatusText)
 			if len(data.Cameras) > 0 && data.SelectedCamera < len(data.Cameras) {
@@ -195,10 +105,16 @@ func createMultiCameraLayout(data *CameraAppData, renderer *sdl.Renderer) clay.R
 				// Clean camera name for display
 				cameraName := sanitizeText(selectedCamera.Info.Name)
 				if cameraName == "" || cameraName == "Unknown" {
-					cameraName = fmt.Sprintf("Camera %d", data.SelectedCamera+1)
+					cameraName = tr("camera.default", data.SelectedCamera+1)
+				}
+				statusText = tr("status.selected", sanitizeText(data.StatusText), cameraName)
+				// Frames lost before reaching the app point at the camera
+				// or USB bandwidth, dropped ones at the CPU
+				lost := atomic.LoadUint64(&selectedCamera.LostFrames)
+				dropped := atomic.LoadUint64(&selectedCamera.DroppedFrames)
+				if lost > 0 || dropped > 0 {
+					statusText += tr("status.frame_loss", lost, dropped)
 				}
-				statusText = fmt.Sprintf("%s | Selected: %s | Use arrows or numbers",
-					sanitizeText(data.StatusText), cameraName)
 			}
 
 			//clay.Text(statusText, clay.TextConfig(clay.TextElementConfig{
@@ -206,39 +122,244 @@ func createMultiCameraLayout(data *CameraAppData, renderer *sdl.Renderer) clay.R
 			//	FontSize:  14,
 			//	TextColor: data.StatusColor,
 			//}))
-			safeText("stat", statusText, clay.TextElementConfig{
-				FontId:    FontIdBody16,
-				FontSize:  14,
-				TextColor: data.StatusColor,
+			// The message takes the room the indicators leave
+			clay.UI()(clay.ElementDeclaration{
+				Id: SafeID("StatusMessage"),
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{Width: clay.SizingGrow(0)},
+				},
+				Clip: clay.ClipElementConfig{Horizontal: true},
+			}, func() {
+				safeText("stat", statusText, clay.TextElementConfig{
+					FontId:    FontIdBody16,
+					FontSize:  14,
+					TextColor: data.StatusColor,
+				})
 			})
+			createStatusIndicatorsLayout(data)
 		})
+
+		// Popups
+		createContextMenuLayout(data)
+		createControlsPanelLayout(data)
+		createSessionsPanelLayout(data)
+		createAboutPanelLayout(data)
+		createToastsLayout(data)
+		createKioskLayout(data)
+		createPrivacyLayout()
 	})
 
 	renderCommands := clay.EndLayout()
 	return renderCommands
 }
 
-func renderMainCameraView(appData *CameraAppData) {
-	// Get the main camera container position and size
-	mainCameraElement := clay.GetElementData(SafeID("MainCameraContainer"))
+// createThumbnailStripLayout declares the thumbnails panel, a column at
+// the right or, in the portrait layout, a row at the top or bottom
+func createThumbnailStripLayout(data *CameraAppData) {
+	direction := clay.TOP_TO_BOTTOM
+	sizing := clay.Sizing{
+		Width:  clay.SizingFit(tp(84), 0), // 30% of available width
+		Height: clay.SizingGrow(0),
+	}
+	entryWidth := clay.SizingGrow(0)
+	if portraitLayout() {
+		direction = clay.LEFT_TO_RIGHT
+		sizing = clay.Sizing{Width: clay.SizingGrow(0), Height: clay.SizingFit(0, 0)}
+		entryWidth = clay.SizingFit(0, 0)
+	}
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("ThumbnailsPanel"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: direction,
+			Sizing:          sizing,
+			Padding:         clay.PaddingAll(dpu(8)),
+			ChildGap:        dpu(12),
+		},
+		BackgroundColor: theme.Sidebar,
+		CornerRadius:    clay.CornerRadiusAll(dp(4)),
+		// Scroll along the strip when there are more thumbnails than fit
+		Clip: clay.ClipElementConfig{
+			Vertical:    !portraitLayout(),
+			Horizontal:  portraitLayout(),
+			ChildOffset: clay.GetScrollOffset(),
+		},
+	}, func() {
+		// Thumbnails header
+		if len(data.Cameras) > 0 {
+			//clay.Text("Cameras", clay.TextConfig(clay.TextElementConfig{
+			//	FontId:    FontIdBody16,
+			//	FontSize:  20,
+			//	TextColor: clay.Color{R: 255, G: 255, B: 255, A: 255},
+			//}))
+			safeText("thumbnail", tr("panel.cameras"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  10,
+				TextColor: theme.Text,
+			})
+			// Camera thumbnails
+
+			for _, i := range stripOrder(data) {
+				//camera := &data.Cameras[i]
+				isSelected := i == data.SelectedCamera
+
+				// Create safe thumbnail ID
+				thumbnailID := fmt.Sprintf("Thumbnail%d", i)
+
+				// The thumbnail over its label, a column of its own so the
+				// two stay together in a row of thumbnails
+				clay.UI()(clay.ElementDeclaration{
+					Layout: clay.LayoutConfig{
+						LayoutDirection: clay.TOP_TO_BOTTOM,
+						Sizing:          clay.Sizing{Width: entryWidth},
+						ChildGap:        dpu(12),
+					},
+				}, func() {
+					clay.UI()(clay.ElementDeclaration{
+						Id: SafeID(thumbnailID),
+						Layout: clay.LayoutConfig{
+							Sizing: clay.Sizing{
+								Width:  clay.SizingGrow(tp(80)),
+								Height: clay.SizingFixed(tp(60)),
+							},
+							Padding: clay.PaddingAll(dpu(2)),
+						},
+						BackgroundColor: func() clay.Color {
+							if isSelected {
+								return theme.Accent
+							} else if clay.Hovered() {
+								return theme.ItemHover
+							}
+							return theme.Item
+						}(),
+						CornerRadius: clay.CornerRadiusAll(dp(4)),
+						Border: func() clay.BorderElementConfig {
+							if isSelected {
+								return clay.BorderElementConfig{
+									Color: theme.AccentBorder,
+									Width: clay.BorderAll(dpu(2)),
+								}
+							}
+							return clay.BorderElementConfig{}
+						}(),
+					}, func() {
+						// Thumbnail content
+						clay.UI()(clay.ElementDeclaration{
+							Layout: clay.LayoutConfig{
+								LayoutDirection: clay.TOP_TO_BOTTOM,
+								ChildGap:        dpu(4),
+								Padding:         clay.PaddingAll(dpu(4)),
+							},
+						}, func() {})
+					})
+					label := data.Cameras[i].Info.Name
+					if data.Rename.Active && data.Rename.Camera == i {
+						label = data.Rename.Text + "_"
+					}
+					labelColor := theme.Text
+					if data.Cameras[i].Disabled {
+						labelColor = theme.TextDim
+					}
+					// Double-clicking the label renames the camera
+					clay.UI()(clay.ElementDeclaration{
+						Id: SafeID(fmt.Sprintf("ThumbnailLabel%d", i)),
+					}, func() {
+						safeText("thumbnail", label, clay.TextElementConfig{
+							FontId:    FontIdBody16,
+							FontSize:  8,
+							TextColor: labelColor,
+						})
+					})
+				})
+			}
+		} else {
+			safeText("no_cam", tr("panel.no_cameras"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  16,
+				TextColor: theme.Error,
+			})
+		}
+	})
+}
+
+// createMainPaneLayout declares a main camera pane. The camera itself is
+// drawn over it separately; the pane with the focus carries the measurement
+// and metadata labels.
+func createMainPaneLayout(data *CameraAppData, pane int) {
+	focused := !dualView.Enabled || pane == dualView.Focus
+	width := clay.SizingPercent(0.7) // 70% of available width
+	if dualView.Enabled || portraitLayout() {
+		width = clay.SizingGrow(0)
+	}
+	clay.UI()(clay.ElementDeclaration{
+		Id: paneID(pane),
+		Layout: clay.LayoutConfig{
+			Sizing: clay.Sizing{
+				Width:  width,
+				Height: clay.SizingGrow(0),
+			},
+			Padding: clay.PaddingAll(dpu(5)),
+		},
+		BackgroundColor: theme.Panel,
+		CornerRadius:    clay.CornerRadiusAll(dp(8)),
+		Border: func() clay.BorderElementConfig {
+			if focused && data.SelectedCamera < len(data.Cameras) {
+				return clay.BorderElementConfig{
+					Color: theme.AccentBorder,
+					Width: clay.BorderAll(dpu(3)),
+				}
+			}
+			return clay.BorderElementConfig{}
+		}(),
+	}, func() {
+		if focused {
+			createMeasurementLayout(data)
+			createMetadataLayout()
+			createClipLayout(data, paneID(pane))
+			createFreezeLayout(data, paneID(pane))
+		}
+	})
+}
+
+// mainCameraRect returns where the main camera view is drawn, inside the
+// main camera container from the last layout. In the dual view that is the
+// pane with the focus.
+func mainCameraRect() (sdl.FRect, bool) {
+	return paneRect(focusedPaneID())
+}
+
+// paneRect returns where the camera of a main pane is drawn
+func paneRect(id clay.ElementId) (sdl.FRect, bool) {
+	mainCameraElement := clay.GetElementData(id)
 	if !mainCameraElement.Found {
-		return
+		return sdl.FRect{}, false
 	}
 
 	bbox := mainCameraElement.BoundingBox
-	cameraRect := sdl.FRect{
-		X: bbox.X + 5,
-		Y: bbox.Y + 5,
-		W: bbox.Width - 10,
-		H: bbox.Height - 10,
+	return sdl.FRect{
+		X: bbox.X + dp(5),
+		Y: bbox.Y + dp(5),
+		W: bbox.Width - dp(10),
+		H: bbox.Height - dp(10),
+	}, true
+}
+
+func renderMainCameraView(appData *CameraAppData) {
+	// Get the main camera container position and size
+	cameraRect, ok := mainCameraRect()
+	if !ok {
+		return
+	}
+	if mosaicShown(appData) {
+		renderMosaic(appData, cameraRect)
+		return
 	}
 
 	// Render the selected camera or placeholder
 	if appData.SelectedCamera < len(appData.Cameras) {
 		camera := &appData.Cameras[appData.SelectedCamera]
 		camera.FrameMutex.RLock()
-		if camera.Texture != nil && camera.Active {
-			err := appData.Renderer.RenderTexture(camera.Texture, nil, &cameraRect)
+		if camera.Texture != nil && camera.Active && !camera.Disabled {
+			err := appData.Renderer.RenderTexture(camera.Texture, touchMode.zoomSourceRect(camera.Texture), &cameraRect)
 			if err != nil {
 				log.Printf("Error rendering camera texture: %v", err)
 				return
@@ -260,37 +381,149 @@ func renderMainCameraView(appData *CameraAppData) {
 	}
 }
 
+// disabledColorMod darkens the thumbnails of disabled cameras
+const disabledColorMod = 70
+
 func renderThumbnailViews(appData *CameraAppData) {
+	panel := clay.GetElementData(SafeID("ThumbnailsPanel"))
+	if !panel.Found {
+		return
+	}
+
+	// Thumbnail textures are drawn outside of Clay, so clip them to the
+	// scroll container ourselves
+	panelBox := panel.BoundingBox
+	clipRect := sdl.Rect{
+		X: int32(panelBox.X),
+		Y: int32(panelBox.Y),
+		W: int32(panelBox.Width),
+		H: int32(panelBox.Height),
+	}
+	_ = appData.Renderer.SetClipRect(&clipRect)
+	defer appData.Renderer.SetClipRect(nil)
+
 	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
 		thumbnailID := fmt.Sprintf("Thumbnail%d", i)
 		thumbnailElement := clay.GetElementData(SafeID(thumbnailID))
 		if !thumbnailElement.Found {
+			camera.ThumbnailVisible = false
 			continue
 		}
 
 		bbox := thumbnailElement.BoundingBox
+		start, length := stripSpan(bbox)
+		panelStart, panelLength := stripSpan(panelBox)
+		camera.ThumbnailVisible = start+length > panelStart && start < panelStart+panelLength
+		if !camera.ThumbnailVisible {
+			continue
+		}
+
 		thumbnailRect := sdl.FRect{
-			X: bbox.X + 2,
-			Y: bbox.Y + 2,
-			W: bbox.Width - 4,
-			H: bbox.Height - 4,
+			X: bbox.X + dp(2),
+			Y: bbox.Y + dp(2),
+			W: bbox.Width - dp(4),
+			H: bbox.Height - dp(4),
 		}
 
-		camera := &appData.Cameras[i]
 		camera.FrameMutex.RLock()
-		if camera.ThumbnailTexture != nil && camera.Active {
+		if camera.Disabled {
+			// Grey out the last frame seen before the camera was disabled
+			texture := appData.PlaceholderTexture
+			if camera.ThumbnailTexture != nil && (camera.LastFrame != nil || camera.LastRaw != nil) {
+				texture = camera.ThumbnailTexture
+			}
+			if texture != nil {
+				_ = texture.SetColorMod(disabledColorMod, disabledColorMod, disabledColorMod)
+				err := appData.Renderer.RenderTexture(texture, nil, &thumbnailRect)
+				if err != nil {
+					log.Printf("Error rendering disabled thumbnail: %v", err)
+				}
+				_ = texture.SetColorMod(255, 255, 255)
+			}
+		} else if camera.ThumbnailTexture != nil && camera.Active {
 			err := appData.Renderer.RenderTexture(camera.ThumbnailTexture, nil, &thumbnailRect)
 			if err != nil {
 				log.Printf("Error rendering camera thumbnail: %v", err)
-				return
 			}
 		} else if appData.PlaceholderTexture != nil {
 			err := appData.Renderer.RenderTexture(appData.PlaceholderTexture, nil, &thumbnailRect)
 			if err != nil {
 				log.Printf("Error rendering placeholder texture: %v", err)
-				return
 			}
 		}
 		camera.FrameMutex.RUnlock()
 	}
 }
+
+// pointInElement reports whether the point lies inside the element's bounding
+// box from the last layout
+func pointInElement(id clay.ElementId, x, y float32) bool {
+	element := clay.GetElementData(id)
+	if !element.Found {
+		return false
+	}
+	bbox := element.BoundingBox
+	return x >= bbox.X && x <= bbox.X+bbox.Width &&
+		y >= bbox.Y && y <= bbox.Y+bbox.Height
+}
+
+// scrollThumbnails moves the thumbnails panel by delta pixels (positive
+// scrolls towards the end of the list), clamped to the content size.
+func scrollThumbnails(delta float32) {
+	scroll := clay.GetScrollContainerData(SafeID("ThumbnailsPanel"))
+	if !scroll.Found || scroll.ScrollPosition == nil {
+		return
+	}
+
+	content, shown, position := scroll.ContentDimensions.Height, scroll.ScrollContainerDimensions.Height, &scroll.ScrollPosition.Y
+	if portraitLayout() {
+		content, shown, position = scroll.ContentDimensions.Width, scroll.ScrollContainerDimensions.Width, &scroll.ScrollPosition.X
+	}
+	maxScroll := content - shown
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+
+	// Clay stores the scroll position as a negative offset
+	offset := *position - delta
+	if offset > 0 {
+		offset = 0
+	}
+	if offset < -maxScroll {
+		offset = -maxScroll
+	}
+	*position = offset
+}
+
+// pageThumbnails scrolls the thumbnails panel by whole pages.
+func pageThumbnails(pages float32) {
+	scroll := clay.GetScrollContainerData(SafeID("ThumbnailsPanel"))
+	if !scroll.Found {
+		return
+	}
+	page := scroll.ScrollContainerDimensions.Height
+	if portraitLayout() {
+		page = scroll.ScrollContainerDimensions.Width
+	}
+	scrollThumbnails(pages * page)
+}
+
+// scrollThumbnailIntoView scrolls the panel so the thumbnail of the given
+// camera is fully visible.
+func scrollThumbnailIntoView(index int) {
+	panel := clay.GetElementData(SafeID("ThumbnailsPanel"))
+	thumbnail := clay.GetElementData(SafeID(fmt.Sprintf("Thumbnail%d", index)))
+	if !panel.Found || !thumbnail.Found {
+		return
+	}
+
+	top, length := stripSpan(panel.BoundingBox)
+	bottom := top + length
+	start, size := stripSpan(thumbnail.BoundingBox)
+	if start < top {
+		scrollThumbnails(start - top)
+	} else if start+size > bottom {
+		scrollThumbnails(start + size - bottom)
+	}
+}
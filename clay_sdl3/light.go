@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inspection cameras often have a ring light, switched by a GPIO pin, a USB
+// relay or the camera itself. A light is set per camera with -light: I or
+// the context menu switches the selected camera's light, and snapshots are
+// taken with the light on, switching it on for -light-warmup beforehand and
+// off again afterwards if it was off. Cameras set up with the same -light
+// value share one light.
+
+// lightKind is how a light is switched
+type lightKind int
+
+const (
+	// lightGPIO drives a GPIO pin through sysfs
+	lightGPIO lightKind = iota
+	// lightCommand runs a command with "on" or "off" as its argument
+	lightCommand
+	// lightUVC sets a control of the camera, such as a UVC LED mode
+	lightUVC
+)
+
+// Light is a camera's illumination
+type Light struct {
+	Kind lightKind
+	// Pin is the sysfs GPIO number, driven low for on if ActiveLow
+	Pin       int
+	ActiveLow bool
+	// Command is run through the shell for lightCommand
+	Command string
+	// Control is the name of the camera control for lightUVC, set to
+	// OnValue or OffValue
+	Control           string
+	OnValue, OffValue int32
+}
+
+var (
+	// cameraLights are the lights set with -light by device path; the
+	// empty path applies to every camera without its own
+	cameraLights = make(map[string]*Light)
+	// lightWarmup is how long a light is on before a snapshot is taken
+	lightWarmup = 300 * time.Millisecond
+	// litFrameWait is how long after lightWarmup a snapshot waits for a
+	// frame taken with the light on before it settles for the last one
+	litFrameWait = time.Second
+	// gpioDir is the sysfs GPIO directory
+	gpioDir = "/sys/class/gpio"
+)
+
+// parseLight adds a -light value, "[DEVICE=]gpio:PIN[:low]",
+// "[DEVICE=]cmd:COMMAND" or "[DEVICE=]uvc:CONTROL[=ON[,OFF]]"
+func parseLight(value string) error {
+	path, spec := "", value
+	// Device paths start with a slash, unlike the light specs, which may
+	// contain = themselves
+	if strings.HasPrefix(value, "/") {
+		var ok bool
+		if path, spec, ok = strings.Cut(value, "="); !ok {
+			path, spec = "", value
+		}
+	}
+	invalid := fmt.Errorf("invalid light %q, expected [DEVICE=]gpio:PIN[:low], [DEVICE=]cmd:COMMAND or [DEVICE=]uvc:CONTROL[=ON[,OFF]]", value)
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return invalid
+	}
+
+	var light Light
+	switch kind {
+	case "gpio":
+		pin, polarity, _ := strings.Cut(arg, ":")
+		n, err := strconv.Atoi(pin)
+		if err != nil || n < 0 || polarity != "" && polarity != "low" {
+			return invalid
+		}
+		light = Light{Kind: lightGPIO, Pin: n, ActiveLow: polarity == "low"}
+	case "cmd":
+		light = Light{Kind: lightCommand, Command: arg}
+	case "uvc":
+		name, values, hasValues := strings.Cut(arg, "=")
+		light = Light{Kind: lightUVC, Control: name, OnValue: 1}
+		if hasValues {
+			on, off, _ := strings.Cut(values, ",")
+			onValue, errOn := strconv.ParseInt(on, 10, 32)
+			offValue, errOff := int64(0), error(nil)
+			if off != "" {
+				offValue, errOff = strconv.ParseInt(off, 10, 32)
+			}
+			if errOn != nil || errOff != nil {
+				return invalid
+			}
+			light.OnValue, light.OffValue = int32(onValue), int32(offValue)
+		}
+	default:
+		return invalid
+	}
+	cameraLights[path] = &light
+	return nil
+}
+
+// lightFor returns the light of a camera, nil if it has none
+func lightFor(camera *CameraInstance) *Light {
+	if light, ok := cameraLights[camera.Info.Path]; ok {
+		return light
+	}
+	return cameraLights[""]
+}
+
+// switchTo turns a light on or off for a camera
+func (l *Light) switchTo(camera *CameraInstance, on bool) error {
+	state := map[bool]string{true: "on", false: "off"}[on]
+	switch l.Kind {
+	case lightGPIO:
+		return l.setGPIO(on)
+	case lightCommand:
+		out, err := exec.Command("sh", "-c", l.Command+` "$@"`, "sh", state).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("light command failed: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	case lightUVC:
+		if camera.Device == nil {
+			return trErr("error.not_stream", camera.Info.Name)
+		}
+		controls, err := camera.Device.QueryControls()
+		if err != nil {
+			return fmt.Errorf("failed to list the controls of %s: %w", camera.Info.Name, err)
+		}
+		for _, ctrl := range controls {
+			if !strings.EqualFold(ctrl.Name, l.Control) {
+				continue
+			}
+			value := l.OffValue
+			if on {
+				value = l.OnValue
+			}
+			if err := camera.Device.SetControlValue(ctrl.ID, value); err != nil {
+				return trErr("error.set_control", ctrl.Name, err)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s has no control %q", camera.Info.Name, l.Control)
+	}
+	return nil
+}
+
+// setGPIO exports the light's pin as an output if needed and drives it
+func (l *Light) setGPIO(on bool) error {
+	pin := filepath.Join(gpioDir, fmt.Sprintf("gpio%d", l.Pin))
+	if _, err := os.Stat(pin); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(filepath.Join(gpioDir, "export"), []byte(strconv.Itoa(l.Pin)), 0o644); err != nil {
+			return fmt.Errorf("failed to export GPIO %d: %w", l.Pin, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(pin, "direction"), []byte("out"), 0o644); err != nil {
+		return fmt.Errorf("failed to set up GPIO %d: %w", l.Pin, err)
+	}
+	level := "0"
+	if on != l.ActiveLow {
+		level = "1"
+	}
+	if err := os.WriteFile(filepath.Join(pin, "value"), []byte(level), 0o644); err != nil {
+		return fmt.Errorf("failed to set GPIO %d: %w", l.Pin, err)
+	}
+	return nil
+}
+
+// setLight turns a camera's light on or off, updating every camera that
+// shares it
+func setLight(appData *CameraAppData, index int, on bool) error {
+	camera := &appData.Cameras[index]
+	light := lightFor(camera)
+	if light == nil {
+		return trErr("error.no_light", camera.Info.Name)
+	}
+	if err := light.switchTo(camera, on); err != nil {
+		return err
+	}
+	for i := range appData.Cameras {
+		// A light switched through the camera belongs to that camera
+		if lightFor(&appData.Cameras[i]) == light && (light.Kind != lightUVC || i == index) {
+			appData.Cameras[i].LightOn = on
+		}
+	}
+	return nil
+}
+
+// toggleLight switches a camera's light and shows the result
+func toggleLight(appData *CameraAppData, index int) {
+	if index < 0 || index >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[index]
+	if err := setLight(appData, index, !camera.LightOn); err != nil {
+		setErrorStatus(appData, err)
+		return
+	}
+	if camera.LightOn {
+		appData.StatusText = tr("status.light_on", camera.Info.Name)
+	} else {
+		appData.StatusText = tr("status.light_off", camera.Info.Name)
+	}
+	appData.StatusColor = theme.Text
+}
+
+// lightForSnapshot switches on the light of a camera about to be
+// snapshotted, and reports whether it did, so the caller waits for
+// lightWarmup and switches it off afterwards
+func lightForSnapshot(appData *CameraAppData, index int) bool {
+	camera := &appData.Cameras[index]
+	if camera.LightOn || lightFor(camera) == nil {
+		return false
+	}
+	if err := setLight(appData, index, true); err != nil {
+		log.Printf("Snapshot of %s without light: %v", camera.Info.Name, err)
+		return false
+	}
+	return true
+}
+
+// requestSnapshot saves a snapshot of a camera, first switching its light
+// on if it is off; the snapshot is then taken by updateLitSnapshots once
+// the light has warmed up
+func requestSnapshot(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+	if camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+	if !camera.LitSnapshotAt.IsZero() {
+		return
+	}
+	if lightForSnapshot(appData, index) {
+		camera.LitSnapshotAt = time.Now().Add(lightWarmup)
+		appData.StatusText = tr("status.light_warmup", camera.Info.Name)
+		appData.StatusColor = theme.Text
+		return
+	}
+	reportSnapshot(appData, camera)
+}
+
+// snapshotResult is a snapshot's path, or why it failed
+type snapshotResult struct {
+	Path string
+	Err  error
+}
+
+// queueLitSnapshot saves a snapshot for a caller outside the main loop. It
+// returns the path at once if the camera has no light to switch on;
+// otherwise the snapshot waits for the light like requestSnapshot's and
+// is sent to result.
+func queueLitSnapshot(appData *CameraAppData, index int, result chan<- snapshotResult) (string, error) {
+	camera := &appData.Cameras[index]
+	if camera.LitSnapshotAt.IsZero() {
+		if !lightForSnapshot(appData, index) {
+			return saveSnapshot(camera)
+		}
+		camera.LitSnapshotAt = time.Now().Add(lightWarmup)
+	}
+	camera.LitSnapshotWaiters = append(camera.LitSnapshotWaiters, result)
+	return "", nil
+}
+
+// reportSnapshot saves a snapshot and shows where it went
+func reportSnapshot(appData *CameraAppData, camera *CameraInstance) snapshotResult {
+	path, err := saveSnapshot(camera)
+	if err != nil {
+		setErrorStatus(appData, err)
+	} else {
+		appData.StatusText = tr("status.saved", path)
+	}
+	return snapshotResult{Path: path, Err: err}
+}
+
+// updateLitSnapshots takes the snapshots waiting for a light once it has
+// warmed up and a frame taken with it has arrived, and switches the light
+// off again
+func updateLitSnapshots(appData *CameraAppData) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		due := camera.LitSnapshotAt
+		if due.IsZero() || time.Now().Before(due) {
+			continue
+		}
+		if !camera.LastFrameAt.After(due) && time.Since(due) < litFrameWait {
+			continue
+		}
+		camera.LitSnapshotAt = time.Time{}
+		result := reportSnapshot(appData, camera)
+		for _, waiter := range camera.LitSnapshotWaiters {
+			waiter <- result
+		}
+		camera.LitSnapshotWaiters = nil
+		if err := setLight(appData, i, false); err != nil {
+			log.Printf("Failed to switch off the light of %s: %v", camera.Info.Name, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+func TestParseLight(t *testing.T) {
+	t.Cleanup(func() { cameraLights = make(map[string]*Light) })
+	tests := []struct {
+		value string
+		path  string
+		want  Light
+	}{
+		{"gpio:17", "", Light{Kind: lightGPIO, Pin: 17}},
+		{"/dev/video2=gpio:4:low", "/dev/video2", Light{Kind: lightGPIO, Pin: 4, ActiveLow: true}},
+		{"cmd:usbrelay RING=1", "", Light{Kind: lightCommand, Command: "usbrelay RING=1"}},
+		{"uvc:LED1 Mode=3,0", "", Light{Kind: lightUVC, Control: "LED1 Mode", OnValue: 3}},
+		{"/dev/video0=uvc:LED1 Mode", "/dev/video0", Light{Kind: lightUVC, Control: "LED1 Mode", OnValue: 1}},
+	}
+	for _, test := range tests {
+		if err := parseLight(test.value); err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+		if got := cameraLights[test.path]; got == nil || *got != test.want {
+			t.Errorf("%q = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+	for _, value := range []string{"gpio:x", "gpio:3:high", "cmd:", "uvc:LED=on", "relay:1"} {
+		if err := parseLight(value); err == nil {
+			t.Errorf("%q accepted", value)
+		}
+	}
+}
+
+func TestLightSwitching(t *testing.T) {
+	dir := t.TempDir()
+	gpio := gpioDir
+	gpioDir = dir
+	t.Cleanup(func() {
+		gpioDir = gpio
+		cameraLights = make(map[string]*Light)
+	})
+	if err := os.Mkdir(filepath.Join(dir, "gpio5"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A shared active-low GPIO light switches for every camera
+	cameraLights[""] = &Light{Kind: lightGPIO, Pin: 5, ActiveLow: true}
+	appData := &CameraAppData{Cameras: make([]CameraInstance, 2)}
+	toggleLight(appData, 0)
+	if level, _ := os.ReadFile(filepath.Join(dir, "gpio5", "value")); string(level) != "0" {
+		t.Errorf("active-low pin on at level %q", level)
+	}
+	if !appData.Cameras[0].LightOn || !appData.Cameras[1].LightOn {
+		t.Errorf("shared light not on for both cameras")
+	}
+
+	// A command gets the state as its argument
+	state := filepath.Join(dir, "state")
+	cameraLights[""] = &Light{Kind: lightCommand, Command: "echo > " + state}
+	if err := setLight(appData, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := os.ReadFile(state); string(out) != "off\n" {
+		t.Errorf("command got %q", out)
+	}
+
+	// A camera control switches only its camera
+	dev := newFakeDevice(0)
+	dev.Controls[0x0a046d05] = v4l2.Control{ID: 0x0a046d05, Name: "LED1 Mode", Minimum: 0, Maximum: 3, Step: 1}
+	appData.Cameras[0] = CameraInstance{Info: CameraInfo{Path: "/dev/video0"}, Device: dev}
+	cameraLights[""] = &Light{Kind: lightUVC, Control: "led1 mode", OnValue: 3}
+	if !lightForSnapshot(appData, 0) {
+		t.Fatal("light not switched on for the snapshot")
+	}
+	if value, _ := dev.GetControlValue(0x0a046d05); value != 3 || appData.Cameras[1].LightOn {
+		t.Errorf("LED mode %d, other camera lit %v", value, appData.Cameras[1].LightOn)
+	}
+	if lightForSnapshot(appData, 0) {
+		t.Error("light already on switched again")
+	}
+}
+
+// TestLitSnapshotWaitsForFrame checks that a snapshot through the API waits
+// for a frame taken with the light on and switches the light off after it
+func TestLitSnapshotWaitsForFrame(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Cleanup(func() { cameraLights = make(map[string]*Light) })
+	state := filepath.Join(dir, "state")
+	cameraLights[""] = &Light{Kind: lightCommand, Command: "echo > " + state}
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "bench"}, Active: true, LastFrame: testJPEG(t, 1)}}}
+	camera := &appData.Cameras[0]
+
+	result := make(chan snapshotResult, 1)
+	if path, err := queueLitSnapshot(appData, 0, result); path != "" || err != nil {
+		t.Fatalf("saved at once: %q, %v", path, err)
+	}
+	if !camera.LightOn {
+		t.Fatal("light not switched on")
+	}
+
+	// The frame from before the light came on is not the snapshot
+	camera.LitSnapshotAt = time.Now().Add(-time.Millisecond)
+	camera.LastFrameAt = camera.LitSnapshotAt.Add(-time.Millisecond)
+	updateLitSnapshots(appData)
+	select {
+	case got := <-result:
+		t.Fatalf("snapshot taken of an unlit frame: %+v", got)
+	default:
+	}
+
+	camera.LastFrameAt = time.Now()
+	updateLitSnapshots(appData)
+	select {
+	case got := <-result:
+		if got.Err != nil || filepath.Dir(got.Path) != snapshotDir {
+			t.Fatalf("got %+v", got)
+		}
+	default:
+		t.Fatal("no snapshot once a lit frame arrived")
+	}
+	if out, _ := os.ReadFile(state); camera.LightOn || string(out) != "off\n" {
+		t.Errorf("light left on, command got %q", out)
+	}
+}
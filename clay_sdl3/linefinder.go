@@ -0,0 +1,177 @@
+package main
+
+import (
+	"math"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// linePeakMin and lineContrastMin are how bright a scanline's peak must
+	// be, absolutely and above the scanline's mean, to count as the line
+	linePeakMin     = 100
+	lineContrastMin = 40
+	// lineScanStep is the spacing of the scanlines
+	lineScanStep = 2
+	// lineMinCoverage is the fraction of scanlines that must cross the line
+	lineMinCoverage = 0.25
+	// linePeakRadius is how far from the peak pixels count towards its
+	// sub-pixel centroid
+	linePeakRadius = 8
+)
+
+// LineFit is a straight line fitted to the bright line in a frame, in frame
+// pixels. Mostly horizontal lines are fitted as y = Slope*x + Intercept,
+// mostly vertical ones as x = Slope*y + Intercept.
+type LineFit struct {
+	Found     bool
+	Vertical  bool
+	Slope     float32
+	Intercept float32
+	// AngleDeg is the tilt from horizontal (or vertical) in degrees
+	AngleDeg float32
+	// Offset is the perpendicular distance from the frame center to the
+	// line; positive when the line is below (or right of) the center
+	Offset float32
+}
+
+// LineFinder detects a bright line, such as a laser line or a lit edge, in
+// the selected camera's frame. It is used to square stock under the camera
+// by reading the line's angle and offset from the crosshair.
+type LineFinder struct {
+	Enabled bool
+	Result  LineFit
+
+	points []sdl.FPoint
+}
+
+var lineFinder LineFinder
+
+// Update fits the line in a frame's luma. Scanning down the columns finds
+// horizontal lines and along the rows vertical ones; the scan that crosses
+// the line on more scanlines wins.
+func (f *LineFinder) Update(luma []uint8, width, height int) {
+	horizontal, horizontalHits := f.scan(luma, width, height, false)
+	vertical, verticalHits := f.scan(luma, width, height, true)
+	f.Result = horizontal
+	if verticalHits > horizontalHits {
+		f.Result = vertical
+	}
+}
+
+// scan finds the brightest point on every scanline and fits a line through
+// them. It returns the fit and how many scanlines crossed the line.
+func (f *LineFinder) scan(luma []uint8, width, height int, vertical bool) (LineFit, int) {
+	// Scanlines run along "along" and are spaced across "across"
+	across, along := width, height
+	at := func(a, s int) uint8 { return luma[s*width+a] }
+	if vertical {
+		across, along = height, width
+		at = func(a, s int) uint8 { return luma[a*width+s] }
+	}
+
+	f.points = f.points[:0]
+	scanlines := 0
+	for a := 0; a < across; a += lineScanStep {
+		scanlines++
+		peak, peakAt, sum := uint8(0), 0, 0
+		for s := range along {
+			v := at(a, s)
+			sum += int(v)
+			if v > peak {
+				peak, peakAt = v, s
+			}
+		}
+		if peak < linePeakMin || int(peak)-sum/along < lineContrastMin {
+			continue
+		}
+
+		// Sub-pixel center of the line, weighting the pixels near the peak
+		// by how far they are above three quarters of it
+		floor := int(peak) * 3 / 4
+		var weight, moment int
+		for s := max(peakAt-linePeakRadius, 0); s <= min(peakAt+linePeakRadius, along-1); s++ {
+			if w := int(at(a, s)) - floor; w > 0 {
+				weight += w
+				moment += w * s
+			}
+		}
+		if weight > 0 {
+			f.points = append(f.points, sdl.FPoint{X: float32(a), Y: float32(moment) / float32(weight)})
+		}
+	}
+
+	hits := len(f.points)
+	if scanlines == 0 || float32(hits) < lineMinCoverage*float32(scanlines) {
+		return LineFit{}, hits
+	}
+
+	slope, intercept := fitLine(f.points)
+	// Refit without the points far from the first fit, such as reflections
+	residual := float32(0)
+	for _, p := range f.points {
+		residual += abs32(p.Y - (slope*p.X + intercept))
+	}
+	limit := max(3*residual/float32(hits), 1.5)
+	kept := f.points[:0]
+	for _, p := range f.points {
+		if abs32(p.Y-(slope*p.X+intercept)) <= limit {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) >= 2 {
+		slope, intercept = fitLine(kept)
+	}
+
+	// Distance of the center from the line, measured across it
+	centerAcross, centerAlong := float32(across)/2, float32(along)/2
+	norm := float32(math.Sqrt(float64(1 + slope*slope)))
+	return LineFit{
+		Found:     true,
+		Vertical:  vertical,
+		Slope:     slope,
+		Intercept: intercept,
+		AngleDeg:  float32(math.Atan(float64(slope)) * 180 / math.Pi),
+		Offset:    (slope*centerAcross + intercept - centerAlong) / norm,
+	}, hits
+}
+
+// fitLine returns the least-squares fit y = slope*x + intercept
+func fitLine(points []sdl.FPoint) (slope, intercept float32) {
+	var sx, sy, sxx, sxy float64
+	for _, p := range points {
+		x, y := float64(p.X), float64(p.Y)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	n := float64(len(points))
+	denominator := n*sxx - sx*sx
+	if denominator == 0 {
+		return 0, float32(sy / n)
+	}
+	s := (n*sxy - sx*sy) / denominator
+	return float32(s), float32((sy - s*sx) / n)
+}
+
+// renderLineFit draws the fitted line across the main view
+func renderLineFit(appData *CameraAppData, camera *CameraInstance, rect sdl.FRect) {
+	fit := lineFinder.Result
+	if !lineFinder.Enabled || !fit.Found || camera.Width <= 0 || camera.Height <= 0 {
+		return
+	}
+	w, h := float32(camera.Width), float32(camera.Height)
+	var x0, y0, x1, y1 float32
+	if fit.Vertical {
+		x0, y0 = fit.Intercept, 0
+		x1, y1 = fit.Slope*h+fit.Intercept, h
+	} else {
+		x0, y0 = 0, fit.Intercept
+		x1, y1 = w, fit.Slope*w+fit.Intercept
+	}
+	vx0, vy0 := frameToView(rect, x0/w, y0/h)
+	vx1, vy1 := frameToView(rect, x1/w, y1/h)
+	_ = appData.Renderer.SetDrawColor(0, 255, 0, 255)
+	_ = appData.Renderer.RenderLine(vx0, vy0, vx1, vy1)
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Instances with an API advertise it with DNS-SD over multicast DNS as a
+// _camapp._tcp service, so others on the LAN can find them with -discover
+// (or avahi-browse/dns-sd) instead of typing addresses.
+
+const (
+	mdnsService = "_camapp._tcp.local."
+	// mdnsServices is the DNS-SD name listing all service types
+	mdnsServices = "_services._dns-sd._udp.local."
+	mdnsTTL      = 120
+	// mdnsCacheFlush marks records this host is the only owner of;
+	// mdnsUnicastResponse is the same bit in a question, asking for a
+	// unicast reply
+	mdnsCacheFlush      = 0x8000
+	mdnsUnicastResponse = 0x8000
+	// discoverTimeout is how long -discover listens for answers
+	discoverTimeout = 2 * time.Second
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsResponder answers queries for this instance's API
+type mdnsResponder struct {
+	conn     *net.UDPConn
+	instance dnsmessage.Name
+	host     dnsmessage.Name
+	port     uint16
+}
+
+// mdnsInstanceName is the advertised service instance, so discovery can skip
+// this instance; empty when not advertising
+var mdnsInstanceName string
+
+// mdnsHostName returns the host name as a single DNS label
+func mdnsHostName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "camapp"
+	}
+	host, _, _ = strings.Cut(host, ".")
+	return host
+}
+
+// advertiseAPI announces the API listening on address over mDNS and answers
+// queries for it
+func advertiseAPI(address net.Addr) error {
+	tcp, ok := address.(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("cannot advertise %s", address)
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("failed to join the mDNS group: %w", err)
+	}
+
+	host := mdnsHostName()
+	mdnsInstanceName = fmt.Sprintf("camapp on %s.%s", host, mdnsService)
+	r := &mdnsResponder{
+		conn:     conn,
+		instance: dnsmessage.MustNewName(mdnsInstanceName),
+		host:     dnsmessage.MustNewName(host + ".local."),
+		port:     uint16(tcp.Port),
+	}
+	log.Printf("Advertising the camera API as %q", mdnsInstanceName)
+
+	// Announce twice, as RFC 6762 recommends, then answer queries
+	go func() {
+		for range 2 {
+			if packet, err := r.response(0, nil, r.serviceRecords(), r.hostRecords()); err == nil {
+				_, _ = conn.WriteToUDP(packet, mdnsGroup)
+			}
+			time.Sleep(time.Second)
+		}
+	}()
+	go r.serve()
+	return nil
+}
+
+// serve answers queries until the socket fails
+func (r *mdnsResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			log.Printf("mDNS responder stopped: %v", err)
+			return
+		}
+		var p dnsmessage.Parser
+		header, err := p.Start(buf[:n])
+		if err != nil || header.Response {
+			continue
+		}
+		questions, err := p.AllQuestions()
+		if err != nil {
+			continue
+		}
+
+		var answers, additionals []dnsmessage.Resource
+		unicast := from.Port != mdnsGroup.Port
+		for _, q := range questions {
+			name := strings.ToLower(q.Name.String())
+			all := q.Type == dnsmessage.TypeALL
+			switch {
+			case name == mdnsService && (q.Type == dnsmessage.TypePTR || all):
+				answers = append(answers, r.pointer())
+				additionals = append(additionals, r.serviceRecords()[1:]...)
+				additionals = append(additionals, r.hostRecords()...)
+			case name == mdnsServices && (q.Type == dnsmessage.TypePTR || all):
+				answers = append(answers, r.serviceType())
+			case name == strings.ToLower(r.instance.String()) && (q.Type == dnsmessage.TypeSRV || q.Type == dnsmessage.TypeTXT || all):
+				answers = append(answers, r.serviceRecords()[1:]...)
+				additionals = append(additionals, r.hostRecords()...)
+			case name == strings.ToLower(r.host.String()) && (q.Type == dnsmessage.TypeA || all):
+				answers = append(answers, r.hostRecords()...)
+			default:
+				continue
+			}
+			unicast = unicast || q.Class&mdnsUnicastResponse != 0
+		}
+		if len(answers) == 0 {
+			continue
+		}
+
+		var packet []byte
+		if from.Port != mdnsGroup.Port {
+			// A legacy resolver expects its ID and questions back
+			packet, err = r.response(header.ID, questions, answers, additionals)
+		} else {
+			packet, err = r.response(0, nil, answers, additionals)
+		}
+		if err != nil {
+			continue
+		}
+		if unicast {
+			_, _ = r.conn.WriteToUDP(packet, from)
+		} else {
+			_, _ = r.conn.WriteToUDP(packet, mdnsGroup)
+		}
+	}
+}
+
+func (r *mdnsResponder) response(id uint16, questions []dnsmessage.Question, answers, additionals []dnsmessage.Resource) ([]byte, error) {
+	message := dnsmessage.Message{
+		Header:      dnsmessage.Header{ID: id, Response: true, Authoritative: true},
+		Questions:   questions,
+		Answers:     answers,
+		Additionals: additionals,
+	}
+	return message.Pack()
+}
+
+func mdnsHeader(name dnsmessage.Name, kind dnsmessage.Type, unique bool) dnsmessage.ResourceHeader {
+	class := dnsmessage.ClassINET
+	if unique {
+		class |= mdnsCacheFlush
+	}
+	return dnsmessage.ResourceHeader{Name: name, Type: kind, Class: class, TTL: mdnsTTL}
+}
+
+func (r *mdnsResponder) pointer() dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: mdnsHeader(dnsmessage.MustNewName(mdnsService), dnsmessage.TypePTR, false),
+		Body:   &dnsmessage.PTRResource{PTR: r.instance},
+	}
+}
+
+func (r *mdnsResponder) serviceType() dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: mdnsHeader(dnsmessage.MustNewName(mdnsServices), dnsmessage.TypePTR, false),
+		Body:   &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(mdnsService)},
+	}
+}
+
+// serviceRecords returns the PTR, SRV and TXT records of the instance
+func (r *mdnsResponder) serviceRecords() []dnsmessage.Resource {
+	return []dnsmessage.Resource{
+		r.pointer(),
+		{
+			Header: mdnsHeader(r.instance, dnsmessage.TypeSRV, true),
+			Body:   &dnsmessage.SRVResource{Target: r.host, Port: r.port},
+		},
+		{
+			Header: mdnsHeader(r.instance, dnsmessage.TypeTXT, true),
+			Body:   &dnsmessage.TXTResource{TXT: []string{"path=/api/cameras"}},
+		},
+	}
+}
+
+// hostRecords returns an A record for every IPv4 address of the host
+func (r *mdnsResponder) hostRecords() []dnsmessage.Resource {
+	var records []dnsmessage.Resource
+	addresses, _ := net.InterfaceAddrs()
+	for _, address := range addresses {
+		ipNet, ok := address.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			records = append(records, dnsmessage.Resource{
+				Header: mdnsHeader(r.host, dnsmessage.TypeA, true),
+				Body:   &dnsmessage.AResource{A: [4]byte(ip4)},
+			})
+		}
+	}
+	return records
+}
+
+// discoverInstances asks the LAN for camapp instances and returns their API
+// addresses as "ip:port", leaving out this instance
+func discoverInstances() ([]string, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(os.Getpid())},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(mdnsService),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(packet, mdnsGroup); err != nil {
+		return nil, fmt.Errorf("failed to send mDNS query: %w", err)
+	}
+
+	// Answers may arrive in any order and spread over several packets
+	instances := make(map[string]bool)
+	services := make(map[string]dnsmessage.SRVResource)
+	hosts := make(map[string]net.IP)
+	_ = conn.SetReadDeadline(time.Now().Add(discoverTimeout))
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		var p dnsmessage.Parser
+		if header, err := p.Start(buf[:n]); err != nil || !header.Response {
+			continue
+		}
+		if err := p.SkipAllQuestions(); err != nil {
+			continue
+		}
+		answers, _ := p.AllAnswers()
+		_ = p.SkipAllAuthorities()
+		additionals, _ := p.AllAdditionals()
+		for _, record := range append(answers, additionals...) {
+			name := strings.ToLower(record.Header.Name.String())
+			switch body := record.Body.(type) {
+			case *dnsmessage.PTRResource:
+				if name == mdnsService {
+					instances[strings.ToLower(body.PTR.String())] = true
+				}
+			case *dnsmessage.SRVResource:
+				services[name] = *body
+			case *dnsmessage.AResource:
+				if _, ok := hosts[name]; !ok {
+					hosts[name] = net.IP(body.A[:])
+				}
+			}
+		}
+	}
+
+	var addresses []string
+	for instance := range instances {
+		if instance == strings.ToLower(mdnsInstanceName) {
+			continue
+		}
+		srv, ok := services[instance]
+		if !ok {
+			continue
+		}
+		ip, ok := hosts[strings.ToLower(srv.Target.String())]
+		if !ok {
+			continue
+		}
+		addresses = append(addresses, net.JoinHostPort(ip.String(), strconv.Itoa(int(srv.Port))))
+	}
+	return addresses, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// measureLuma is the luma of the selected camera's last frame, shared by the
+// measurement tools
+var measureLuma []uint8
+
+// analyzeFrame runs the enabled measurement tools on the selected camera's
+// processed frame and publishes the results
+func analyzeFrame(camera *CameraInstance, img *image.RGBA) {
+	defer publishOffsets(camera)
+	if !measuring() {
+		return
+	}
+	measureLuma = frameLuma(img, measureLuma)
+	bounds := img.Bounds()
+	if lineFinder.Enabled {
+		lineFinder.Update(measureLuma, bounds.Dx(), bounds.Dy())
+	}
+	if circleFinder.Enabled {
+		circleFinder.Update(measureLuma, bounds.Dx(), bounds.Dy())
+	}
+}
+
+// measuring reports whether any measurement tool is enabled
+func measuring() bool {
+	return lineFinder.Enabled || circleFinder.Enabled
+}
+
+// formatLength formats a length in frame pixels, adding mm for calibrated
+// cameras
+func formatLength(camera *CameraInstance, pixels float32) string {
+	if camera.PixelsPerMM > 0 {
+		return fmt.Sprintf("%+.1f px (%+.3f mm)", pixels, pixels/camera.PixelsPerMM)
+	}
+	return fmt.Sprintf("%+.1f px", pixels)
+}
+
+// measurementLines returns the readout of the enabled measurement tools
+func measurementLines(camera *CameraInstance) []string {
+	var lines []string
+	if lineFinder.Enabled {
+		if fit := lineFinder.Result; fit.Found {
+			lines = append(lines, tr("measure.line", fit.AngleDeg, formatLength(camera, fit.Offset)))
+		} else {
+			lines = append(lines, tr("measure.no_line"))
+		}
+	}
+	if circleFinder.Enabled {
+		if fit := circleFinder.Result; fit.Found {
+			lines = append(lines,
+				tr("measure.circle_x", formatLength(camera, fit.DX)),
+				tr("measure.circle_y", formatLength(camera, fit.DY)),
+				tr("measure.circle_d", formatLength(camera, 2*fit.Radius)))
+		} else {
+			lines = append(lines, tr("measure.no_circle"))
+		}
+	}
+	return lines
+}
+
+// createMeasurementLayout declares the measurement readout floating in the
+// top-left corner of the main view
+func createMeasurementLayout(data *CameraAppData) {
+	if !measuring() || data.SelectedCamera >= len(data.Cameras) {
+		return
+	}
+	camera := &data.Cameras[data.SelectedCamera]
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("MeasurementReadout"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Padding:         clay.PaddingAll(dpu(6)),
+			ChildGap:        dpu(2),
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:   clay.Vector2{X: dp(10), Y: dp(10)},
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_PARENT,
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(4)),
+	}, func() {
+		for _, line := range measurementLines(camera) {
+			safeText("measure-line", line, clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  14,
+				TextColor: theme.Text,
+			})
+		}
+	})
+}
+
+// renderMeasurements draws the fits of the enabled measurement tools over
+// the main view
+func renderMeasurements(appData *CameraAppData) {
+	if !measuring() || appData.SelectedCamera >= len(appData.Cameras) || mosaicShown(appData) {
+		return
+	}
+	rect, ok := mainCameraRect()
+	if !ok {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	renderLineFit(appData, camera, rect)
+	renderCircleFit(appData, camera, rect)
+}
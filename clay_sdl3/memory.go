@@ -0,0 +1,196 @@
+package main
+
+import (
+	"expvar"
+	"image"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// The memory budget keeps many cameras on a small board from running out of
+// memory. The main loop adds up the bytes held for each camera: frames
+// queued by capture, the last frame kept for snapshots, stacks, filters, the
+// textures and the frame history. Over the budget, capture queues at most
+// one frame per camera instead of a backlog, the cached frames and histories
+// of cameras that are neither shown nor recorded are dropped, and no new
+// stacks are started, until usage is back under the budget.
+
+// memoryCheckInterval is how often usage is added up
+const memoryCheckInterval = 500 * time.Millisecond
+
+// MemoryUsage is what the app holds for the cameras, in bytes
+type MemoryUsage struct {
+	Queued     int64 `json:"queued"`
+	Frames     int64 `json:"frames"`
+	Stacks     int64 `json:"stacks"`
+	Filters    int64 `json:"filters"`
+	Textures   int64 `json:"textures"`
+	Thumbnails int64 `json:"thumbnails"`
+	History    int64 `json:"history"`
+}
+
+// Total is the sum of all parts
+func (u MemoryUsage) Total() int64 {
+	return u.Queued + u.Frames + u.Stacks + u.Filters + u.Textures + u.Thumbnails + u.History
+}
+
+// MemoryBudget bounds the memory held for the cameras
+type MemoryBudget struct {
+	// Limit is the budget in bytes, 0 for none
+	Limit int64
+	// Evictions counts the cached frames dropped over the budget
+	Evictions atomic.Int64
+
+	usage     atomic.Pointer[MemoryUsage]
+	over      atomic.Bool
+	lastCheck time.Time
+}
+
+// memoryBudget is set with -memory-budget
+var memoryBudget MemoryBudget
+
+func init() {
+	expvar.Publish("memory_budget", expvar.Func(func() any {
+		usage := memoryBudget.usage.Load()
+		if usage == nil {
+			usage = &MemoryUsage{}
+		}
+		return map[string]any{
+			"limit":     memoryBudget.Limit,
+			"over":      memoryBudget.over.Load(),
+			"held":      usage.Total(),
+			"usage":     usage,
+			"evictions": memoryBudget.Evictions.Load(),
+		}
+	}))
+}
+
+// overMemoryBudget reports whether the app holds more than its budget. It is
+// safe to call from capture goroutines.
+func overMemoryBudget() bool {
+	return memoryBudget.over.Load()
+}
+
+// evictable reports whether a camera's frames may go undecoded and
+// uncached over the budget: it is not in a main pane, its thumbnail is
+// scrolled out of view, and it is neither popped out nor recording
+func evictable(appData *CameraAppData, index int) bool {
+	camera := &appData.Cameras[index]
+	return !shownInPane(appData, index) && !camera.ThumbnailVisible && camera.PopOut == nil && camera.Recorder == nil
+}
+
+// skipForMemory reports whether capture should drop a frame rather than
+// queue it behind another one, which it does while over the budget
+func skipForMemory(camera *CameraInstance) bool {
+	return overMemoryBudget() && len(camera.FrameChan) > 0
+}
+
+// imageBytes is the size of an image's pixel data
+func imageBytes(img image.Image) int64 {
+	switch img := img.(type) {
+	case nil:
+		return 0
+	case *image.RGBA:
+		return int64(len(img.Pix))
+	case *image.Gray:
+		return int64(len(img.Pix))
+	case *image.Gray16:
+		return int64(len(img.Pix))
+	case *image.RGBA64:
+		return int64(len(img.Pix))
+	case *image.YCbCr:
+		return int64(len(img.Y) + len(img.Cb) + len(img.Cr))
+	default:
+		size := img.Bounds().Size()
+		return int64(size.X * size.Y * 4)
+	}
+}
+
+// cameraMemory adds up what is held for a camera. It is called with
+// FrameMutex held.
+func cameraMemory(camera *CameraInstance, usage *MemoryUsage) {
+	frame := int64(len(camera.LastFrame)) + imageBytes(camera.LastRaw)
+	if camera.LastSensor != nil {
+		frame += int64(len(camera.LastSensor.Data))
+	}
+	usage.Frames += frame
+	// Queued frames are taken to be the size of the last one
+	usage.Queued += int64(len(camera.FrameChan)) * frame
+
+	if s := camera.Stack; s != nil {
+		usage.Stacks += int64(len(s.sum)*4 + len(s.max))
+	}
+	if b := camera.Bracket; b != nil {
+		for _, frame := range b.Frames {
+			usage.Stacks += int64(len(frame.Pix))
+		}
+	}
+	if d := camera.Denoise; d != nil {
+		usage.Filters += int64(len(d.average) * 2)
+	}
+	if b := camera.Baseline; b != nil {
+		usage.Filters += int64(len(b.Luma))
+	}
+	usage.Filters += int64(len(camera.Motion.previous))
+
+	if camera.Texture != nil {
+		usage.Textures += int64(camera.Width * camera.Height * 4)
+	}
+	if camera.PopOut != nil {
+		usage.Textures += int64(camera.Width * camera.Height * 4)
+	}
+	if camera.ThumbnailTexture != nil {
+		usage.Thumbnails += int64(max(camera.Width/4, 1) * max(camera.Height/4, 1) * 4)
+	}
+	if camera.ThumbnailPending != nil {
+		usage.Thumbnails += int64(len(camera.ThumbnailPending.Pix))
+	}
+	usage.History += camera.History.Bytes()
+}
+
+// checkMemoryBudget adds up the memory held for the cameras and, over the
+// budget, drops the cached frames of evictable cameras
+func checkMemoryBudget(appData *CameraAppData) {
+	if memoryBudget.Limit <= 0 || time.Since(memoryBudget.lastCheck) < memoryCheckInterval {
+		return
+	}
+	memoryBudget.lastCheck = time.Now()
+
+	var usage MemoryUsage
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		camera.FrameMutex.RLock()
+		cameraMemory(camera, &usage)
+		camera.FrameMutex.RUnlock()
+	}
+	if usage.Total() > memoryBudget.Limit {
+		for i := range appData.Cameras {
+			if !evictable(appData, i) {
+				continue
+			}
+			camera := &appData.Cameras[i]
+			camera.FrameMutex.Lock()
+			if camera.LastFrame != nil || camera.LastRaw != nil || camera.LastSensor != nil || camera.ThumbnailPending != nil {
+				memoryBudget.Evictions.Add(1)
+			}
+			camera.LastFrame = nil
+			camera.LastRaw = nil
+			camera.LastSensor = nil
+			camera.ThumbnailPending = nil
+			camera.FrameMutex.Unlock()
+			camera.History.clear()
+		}
+	}
+	memoryBudget.usage.Store(&usage)
+
+	over := usage.Total() > memoryBudget.Limit
+	if over != memoryBudget.over.Swap(over) {
+		if over {
+			log.Printf("Over the memory budget: %d of %d MB held (%d MB queued, %d MB frames, %d MB stacks)",
+				usage.Total()>>20, memoryBudget.Limit>>20, usage.Queued>>20, usage.Frames>>20, usage.Stacks>>20)
+		} else {
+			log.Printf("Back under the memory budget: %d of %d MB held", usage.Total()>>20, memoryBudget.Limit>>20)
+		}
+	}
+}
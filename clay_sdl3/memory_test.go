@@ -0,0 +1,50 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestMemoryBudgetEvictsHiddenCameras(t *testing.T) {
+	defer func(limit int64) {
+		memoryBudget.Limit = limit
+		memoryBudget.over.Store(false)
+	}(memoryBudget.Limit)
+
+	frame := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	appData := &CameraAppData{Cameras: make([]CameraInstance, 3)}
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		camera.LastRaw = frame
+		camera.FrameChan = make(chan Frame, 10)
+	}
+	// The selected camera and a visible thumbnail are kept, the camera
+	// scrolled out of view is evicted
+	appData.SelectedCamera = 0
+	appData.Cameras[1].ThumbnailVisible = true
+
+	memoryBudget.Limit = int64(len(frame.Pix)) * 4
+	memoryBudget.lastCheck = memoryBudget.lastCheck.AddDate(-1, 0, 0)
+	checkMemoryBudget(appData)
+	if overMemoryBudget() {
+		t.Fatal("over the budget with three frames of four")
+	}
+
+	// Queued frames count too
+	appData.Cameras[2].FrameChan <- Frame{}
+	appData.Cameras[2].FrameChan <- Frame{}
+	memoryBudget.lastCheck = memoryBudget.lastCheck.AddDate(-1, 0, 0)
+	checkMemoryBudget(appData)
+	if !overMemoryBudget() {
+		t.Fatal("not over the budget with five frames of four")
+	}
+	if appData.Cameras[0].LastRaw == nil || appData.Cameras[1].LastRaw == nil {
+		t.Error("evicted a shown camera's frame")
+	}
+	if appData.Cameras[2].LastRaw != nil {
+		t.Error("kept the frame of a camera out of view")
+	}
+	if !skipForMemory(&appData.Cameras[2]) || skipForMemory(&appData.Cameras[0]) {
+		t.Error("capture should skip frames only behind a queued one")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+const (
+	// boardTemperaturePath is the SoC temperature on a Raspberry Pi and most
+	// other Linux boards, in millidegrees Celsius
+	boardTemperaturePath     = "/sys/class/thermal/thermal_zone0/temp"
+	boardTemperatureInterval = 5 * time.Second
+	// metadataRetry is how long a failed sensor connection waits before
+	// connecting again
+	metadataRetry = 5 * time.Second
+	// maxMetadataValue truncates long MQTT payloads
+	maxMetadataValue = 64
+)
+
+// Metadata holds the latest readings of external sensors: board
+// temperature, a GPS receiver through gpsd and machine state read from MQTT.
+// The readings are saved in sidecar files with snapshots and recordings and
+// shown as an on-screen display over the main view.
+type Metadata struct {
+	mu     sync.Mutex
+	fields map[string]string
+	// version counts the changes, so recordings only log new readings
+	version uint64
+	// Enabled is set once a sensor source is configured; OSD shows the
+	// readings over the main view
+	Enabled bool
+	OSD     bool
+}
+
+var metadata = &Metadata{fields: make(map[string]string)}
+
+// Set stores a reading
+func (m *Metadata) Set(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fields[key] == value {
+		return
+	}
+	m.fields[key] = value
+	m.version++
+}
+
+// Fields returns a copy of the readings and their version
+func (m *Metadata) Fields() (map[string]string, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fields := make(map[string]string, len(m.fields))
+	for k, v := range m.fields {
+		fields[k] = v
+	}
+	return fields, m.version
+}
+
+// enableMetadata marks a sensor source as configured, turning the display on
+func enableMetadata() {
+	metadata.Enabled = true
+	metadata.OSD = true
+}
+
+// startBoardTemperature polls the board's SoC temperature
+func startBoardTemperature() {
+	enableMetadata()
+	go func() {
+		for {
+			data, err := os.ReadFile(boardTemperaturePath)
+			if err == nil {
+				if milli, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+					metadata.Set("temperature_c", fmt.Sprintf("%.1f", float64(milli)/1000))
+				}
+			}
+			time.Sleep(boardTemperatureInterval)
+		}
+	}()
+}
+
+// gpsdReport is the part of a gpsd TPV (time-position-velocity) report the
+// app uses
+type gpsdReport struct {
+	Class string  `json:"class"`
+	Mode  int     `json:"mode"`
+	Time  string  `json:"time"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Alt   float64 `json:"alt"`
+}
+
+// startGPSD reads position fixes from a gpsd daemon, reconnecting when the
+// connection drops
+func startGPSD(address string) {
+	enableMetadata()
+	go func() {
+		for {
+			if err := readGPSD(address); err != nil {
+				log.Printf("gpsd: %v", err)
+			}
+			metadata.Set("gps_fix", "none")
+			time.Sleep(metadataRetry)
+		}
+	}()
+}
+
+// readGPSD streams reports from gpsd until the connection fails
+func readGPSD(address string) error {
+	conn, err := net.DialTimeout("tcp", address, metadataRetry)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("?WATCH={\"enable\":true,\"json\":true}\n")); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var report gpsdReport
+		if json.Unmarshal(scanner.Bytes(), &report) != nil || report.Class != "TPV" {
+			continue
+		}
+		switch report.Mode {
+		case 2:
+			metadata.Set("gps_fix", "2d")
+		case 3:
+			metadata.Set("gps_fix", "3d")
+			metadata.Set("gps_alt_m", fmt.Sprintf("%.1f", report.Alt))
+		default:
+			metadata.Set("gps_fix", "none")
+			continue
+		}
+		metadata.Set("gps_lat", fmt.Sprintf("%.6f", report.Lat))
+		metadata.Set("gps_lon", fmt.Sprintf("%.6f", report.Lon))
+		if report.Time != "" {
+			metadata.Set("gps_time", report.Time)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection to %s closed", address)
+}
+
+// startMQTTMetadata subscribes to machine state topics on an MQTT broker;
+// each topic becomes a field named after it
+func startMQTTMetadata(broker, filter string) {
+	enableMetadata()
+	go func() {
+		for {
+			if err := readMQTTMetadata(broker, filter); err != nil {
+				log.Printf("MQTT metadata: %v", err)
+			}
+			time.Sleep(metadataRetry)
+		}
+	}()
+}
+
+// readMQTTMetadata stores the messages of a topic filter until the
+// connection fails
+func readMQTTMetadata(broker, filter string) error {
+	client, err := dialMQTT(broker, fmt.Sprintf("camapp-meta-%d", os.Getpid()), nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Subscribe(filter); err != nil {
+		return err
+	}
+	log.Printf("Reading metadata from MQTT topics %s", filter)
+	return client.Run(func(topic string, payload []byte) {
+		value := strings.TrimSpace(string(payload))
+		if len(value) > maxMetadataValue {
+			value = value[:maxMetadataValue]
+		}
+		metadata.Set(topic, value)
+	})
+}
+
+// metadataRecord is one entry of a metadata sidecar
+type metadataRecord struct {
+	Camera   string            `json:"camera,omitempty"`
+	Frame    *int              `json:"frame,omitempty"`
+	Time     time.Time         `json:"time"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// metadataSidecarPath returns the path of the metadata saved next to a
+// snapshot or recording
+func metadataSidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".meta.json"
+}
+
+// saveMetadataSidecar writes the current readings next to a snapshot
+func saveMetadataSidecar(path string, camera *CameraInstance) error {
+	if !metadata.Enabled {
+		return nil
+	}
+	fields, _ := metadata.Fields()
+	data, err := json.MarshalIndent(metadataRecord{
+		Camera:   camera.Info.Name,
+		Time:     time.Now(),
+		Metadata: fields,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(metadataSidecarPath(path), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
+
+// metadataLines returns the readings as sorted "key: value" lines
+func metadataLines() []string {
+	fields, _ := metadata.Fields()
+	lines := make([]string, 0, len(fields))
+	for key, value := range fields {
+		lines = append(lines, key+": "+value)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// createMetadataLayout declares the on-screen display of the readings in
+// the bottom-left corner of the main view, or in the portrait layout in a
+// row across its top, where a column would cover the narrow view
+func createMetadataLayout() {
+	if !metadata.Enabled || !metadata.OSD {
+		return
+	}
+	lines := metadataLines()
+	if len(lines) == 0 {
+		return
+	}
+
+	direction, gap := clay.TOP_TO_BOTTOM, dpu(2)
+	offset := clay.Vector2{X: dp(10), Y: -dp(10)}
+	attach := clay.FloatingAttachPoints{
+		Element: clay.ATTACH_POINT_LEFT_BOTTOM,
+		Parent:  clay.ATTACH_POINT_LEFT_BOTTOM,
+	}
+	if portraitLayout() {
+		direction, gap = clay.LEFT_TO_RIGHT, dpu(12)
+		offset = clay.Vector2{Y: dp(10)}
+		attach = clay.FloatingAttachPoints{
+			Element: clay.ATTACH_POINT_CENTER_TOP,
+			Parent:  clay.ATTACH_POINT_CENTER_TOP,
+		}
+	}
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("MetadataOSD"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: direction,
+			Padding:         clay.PaddingAll(dpu(6)),
+			ChildGap:        gap,
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:       offset,
+			ZIndex:       overlayZIndex,
+			AttachTo:     clay.ATTACH_TO_PARENT,
+			AttachPoints: attach,
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(4)),
+	}, func() {
+		for _, line := range lines {
+			safeText("metadata-line", line, clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.Text,
+			})
+		}
+	})
+}
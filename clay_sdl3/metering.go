@@ -0,0 +1,266 @@
+package main
+
+import (
+	"image"
+	"log"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// V4L2 exposure controls, which go4vl does not define
+const (
+	ctrlExposureAuto     v4l2.CtrlID = 0x009a0901
+	ctrlExposureAbsolute v4l2.CtrlID = 0x009a0902
+	ctrlGain             v4l2.CtrlID = 0x00980913
+)
+
+// Values of the exposure auto control
+const (
+	exposureManual           = 1
+	exposureAperturePriority = 3
+)
+
+const (
+	// aeTargetLuma is the mean luma the metering region is exposed for,
+	// roughly middle grey
+	aeTargetLuma = 118
+	// aeTolerance is how far the luma may be off target before the
+	// exposure is changed
+	aeTolerance = 12
+	// aeInterval gives the camera time to apply an exposure change before
+	// the region is metered again
+	aeInterval = 200 * time.Millisecond
+	// minMeteringSize is the smallest region, as a fraction of the frame;
+	// smaller drags clear the region instead
+	minMeteringSize = 0.02
+)
+
+// MeteringRegion is the part of the frame used for software auto exposure,
+// in coordinates normalized to the frame size. UVC cameras have no standard
+// control for an exposure window, so the app meters the region itself and
+// drives the exposure (or gain) control in manual mode.
+type MeteringRegion struct {
+	X, Y, W, H float32
+	// Luma is the mean luma of the region in the last metered frame
+	Luma       float32
+	lastAdjust time.Time
+}
+
+// MeteringDrag tracks a metering region being drawn over the main view
+type MeteringDrag struct {
+	Active         bool
+	StartX, StartY float32
+}
+
+// shiftHeld reports whether either shift key is down
+func shiftHeld(appData *CameraAppData) bool {
+	return appData.KeyStates[sdl.SCANCODE_LSHIFT] || appData.KeyStates[sdl.SCANCODE_RSHIFT]
+}
+
+// viewToFrame maps a point in the main view to normalized frame coordinates,
+// taking the zoom into account
+func viewToFrame(rect sdl.FRect, x, y float32) (float32, float32) {
+	u := min(max((x-rect.X)/rect.W, 0), 1)
+	v := min(max((y-rect.Y)/rect.H, 0), 1)
+	if touchMode.Zoom > 1 {
+		u = touchMode.CenterX + (u-0.5)/touchMode.Zoom
+		v = touchMode.CenterY + (v-0.5)/touchMode.Zoom
+	}
+	return u, v
+}
+
+// frameToView maps normalized frame coordinates to a point in the main view
+func frameToView(rect sdl.FRect, u, v float32) (float32, float32) {
+	if touchMode.Zoom > 1 {
+		u = (u-touchMode.CenterX)*touchMode.Zoom + 0.5
+		v = (v-touchMode.CenterY)*touchMode.Zoom + 0.5
+	}
+	return rect.X + u*rect.W, rect.Y + v*rect.H
+}
+
+// startMeteringDrag begins drawing a metering region when the main view is
+// shift-clicked. It reports whether the click started a drag.
+func startMeteringDrag(appData *CameraAppData, x, y float32) bool {
+	if !shiftHeld(appData) || appData.SelectedCamera >= len(appData.Cameras) {
+		return false
+	}
+	rect, ok := mainCameraRect()
+	if !ok || x < rect.X || x > rect.X+rect.W || y < rect.Y || y > rect.Y+rect.H {
+		return false
+	}
+	appData.MeteringDrag = MeteringDrag{Active: true, StartX: x, StartY: y}
+	return true
+}
+
+// finishMeteringDrag sets the selected camera's metering region to the
+// dragged rectangle, or clears it if the drag was too small
+func finishMeteringDrag(appData *CameraAppData, x, y float32) {
+	drag := &appData.MeteringDrag
+	if !drag.Active {
+		return
+	}
+	drag.Active = false
+	rect, ok := mainCameraRect()
+	if !ok || appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+
+	u0, v0 := viewToFrame(rect, drag.StartX, drag.StartY)
+	u1, v1 := viewToFrame(rect, x, y)
+	region := &MeteringRegion{
+		X: min(u0, u1),
+		Y: min(v0, v1),
+		W: abs32(u1 - u0),
+		H: abs32(v1 - v0),
+	}
+	if region.W < minMeteringSize || region.H < minMeteringSize {
+		if camera.Metering != nil {
+			clearMetering(camera)
+			appData.StatusText = tr("status.metering_off", camera.Info.Name)
+		}
+		return
+	}
+	if !camera.Active || camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+
+	if camera.Metering == nil && hasControl(camera, ctrlExposureAuto) {
+		if err := camera.Device.SetControlValue(ctrlExposureAuto, exposureManual); err != nil {
+			log.Printf("Failed to switch %s to manual exposure: %v", camera.Info.Name, err)
+		}
+	}
+	camera.FrameMutex.Lock()
+	camera.Metering = region
+	camera.FrameMutex.Unlock()
+	appData.StatusText = tr("status.metering", camera.Info.Name)
+}
+
+// clearMetering removes a camera's metering region and hands exposure back
+// to the camera
+func clearMetering(camera *CameraInstance) {
+	camera.FrameMutex.Lock()
+	camera.Metering = nil
+	camera.FrameMutex.Unlock()
+	if hasControl(camera, ctrlExposureAuto) {
+		if err := camera.Device.SetControlValue(ctrlExposureAuto, exposureAperturePriority); err != nil {
+			log.Printf("Failed to restore auto exposure on %s: %v", camera.Info.Name, err)
+		}
+	}
+}
+
+// meterFrame measures the mean luma of the camera's metering region and
+// steps the exposure towards the target. It is called with the decoded
+// frame while FrameMutex is held.
+func meterFrame(camera *CameraInstance, img *image.RGBA) {
+	region := camera.Metering
+	if region == nil {
+		return
+	}
+
+	bounds := img.Bounds()
+	x0 := bounds.Min.X + int(region.X*float32(bounds.Dx()))
+	y0 := bounds.Min.Y + int(region.Y*float32(bounds.Dy()))
+	x1 := x0 + max(int(region.W*float32(bounds.Dx())), 1)
+	y1 := y0 + max(int(region.H*float32(bounds.Dy())), 1)
+
+	// Every fourth pixel in each direction is plenty for a mean
+	var sum, count uint64
+	for y := y0; y < y1 && y < bounds.Max.Y; y += 4 {
+		row := img.Pix[img.PixOffset(x0, y):]
+		for x := 0; x < x1-x0 && x0+x < bounds.Max.X; x += 4 {
+			p := row[x*4 : x*4+3]
+			sum += (77*uint64(p[0]) + 150*uint64(p[1]) + 29*uint64(p[2])) >> 8
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	region.Luma = float32(sum) / float32(count)
+
+	if abs32(region.Luma-aeTargetLuma) <= aeTolerance || time.Since(region.lastAdjust) < aeInterval {
+		return
+	}
+	region.lastAdjust = time.Now()
+	adjustExposure(camera, region.Luma)
+}
+
+// adjustExposure scales the exposure time, or the gain if the camera has no
+// exposure control, by how far the metered luma is from the target
+func adjustExposure(camera *CameraInstance, luma float32) {
+	id := ctrlExposureAbsolute
+	if !hasControl(camera, id) {
+		id = ctrlGain
+		if !hasControl(camera, id) {
+			return
+		}
+	}
+	ctrl, err := camera.Device.GetControl(id)
+	if err != nil {
+		log.Printf("Failed to read control %#x on %s: %v", id, camera.Info.Name, err)
+		return
+	}
+
+	// Limit each step so the loop does not oscillate
+	ratio := min(max(aeTargetLuma/max(luma, 1), 0.5), 2)
+	value := int32(float32(ctrl.Value) * ratio)
+	if value == ctrl.Value {
+		// Small values would otherwise never move
+		if ratio > 1 {
+			value++
+		} else {
+			value--
+		}
+	}
+	value = min(max(value, ctrl.Minimum), ctrl.Maximum)
+	if value == ctrl.Value {
+		return
+	}
+
+	if err := camera.Device.SetControlValue(id, value); err != nil {
+		log.Printf("Failed to set %s on %s: %v", ctrl.Name, camera.Info.Name, err)
+	}
+}
+
+// renderMeteringRegion outlines the selected camera's metering region and
+// any region being drawn
+func renderMeteringRegion(appData *CameraAppData, pointer sdl.FPoint) {
+	rect, ok := mainCameraRect()
+	if !ok || appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	renderer := appData.Renderer
+	_ = renderer.SetDrawColor(uint8(theme.Accent.R), uint8(theme.Accent.G), uint8(theme.Accent.B), 255)
+
+	if drag := appData.MeteringDrag; drag.Active {
+		_ = renderer.RenderRect(&sdl.FRect{
+			X: min(drag.StartX, pointer.X),
+			Y: min(drag.StartY, pointer.Y),
+			W: abs32(pointer.X - drag.StartX),
+			H: abs32(pointer.Y - drag.StartY),
+		})
+		return
+	}
+
+	camera := &appData.Cameras[appData.SelectedCamera]
+	camera.FrameMutex.RLock()
+	region := camera.Metering
+	camera.FrameMutex.RUnlock()
+	if region == nil || camera.Disabled {
+		return
+	}
+
+	x0, y0 := frameToView(rect, region.X, region.Y)
+	x1, y1 := frameToView(rect, region.X+region.W, region.Y+region.H)
+	// Clip to the view when zoomed in
+	x0, y0 = max(x0, rect.X), max(y0, rect.Y)
+	x1, y1 = min(x1, rect.X+rect.W), min(y1, rect.Y+rect.H)
+	if x1 <= x0 || y1 <= y0 {
+		return
+	}
+	_ = renderer.RenderRect(&sdl.FRect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0})
+}
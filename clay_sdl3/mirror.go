@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sites without cloud access can mirror the recordings and snapshots to a
+// NAS mounted at -mirror: at every -mirror-cron minute (hourly by default)
+// the files that are missing or changed there, judged by size and
+// modification time as rsync does, are copied at no more than
+// -mirror-bwlimit bytes a second. A copy goes to a .part file that is read
+// back and compared with the checksum taken while copying before it gets
+// its final name, so a flaky share never leaves a damaged file that looks
+// complete. Recordings still being written wait for the next run. The
+// status bar shows the next run or the progress of the current one;
+// clicking it starts a run and shows how the last one went.
+
+// mirrorPartSuffix marks a file that is still being copied
+const mirrorPartSuffix = ".part"
+
+// mirrorChunk is how much is copied between bandwidth checks
+const mirrorChunk = 64 * 1024
+
+var (
+	// mirrorCron is when the mirror runs, set with -mirror-cron
+	mirrorCron = "0 * * * *"
+	// mirrorDirs are mirrored to the same names under the mirror
+	mirrorDirs = []string{recordingDir, snapshotDir}
+)
+
+// Mirror copies the recordings to another directory on a schedule
+type Mirror struct {
+	dir  string
+	spec cronSpec
+	// rate limits the copying in bytes a second, 0 for no limit
+	rate int64
+	// trigger starts a run outside the schedule
+	trigger chan struct{}
+
+	mu     sync.Mutex
+	status MirrorStatus
+}
+
+// MirrorStatus is what the status bar shows of the mirror
+type MirrorStatus struct {
+	// Running is set during a run, with Done of Total bytes to copy
+	Running     bool
+	Done, Total int64
+	// Next is when the next run starts
+	Next time.Time
+	// LastRun and LastResult describe the last run
+	LastRun    time.Time
+	LastResult string
+}
+
+// mirror is the running mirror, or nil without -mirror
+var mirror *Mirror
+
+// parseByteRate parses a number of bytes with an optional K, M or G binary
+// suffix, as in 512K or 2M
+func parseByteRate(text string) (int64, error) {
+	text = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(text)), "B")
+	unit := int64(1)
+	if n := len(text); n > 0 {
+		if i := strings.IndexByte("KMG", text[n-1]); i >= 0 {
+			unit = 1 << (10 * (i + 1))
+			text = text[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid byte rate %q, expected a number such as 512K or 2M", text)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// startMirror starts mirroring to dir at the times of cron, at most rate
+// bytes a second
+func startMirror(dir, cron, rate string) error {
+	spec, err := parseCron(cron)
+	if err != nil {
+		return fmt.Errorf("invalid -mirror-cron: %w", err)
+	}
+	limit, err := parseByteRate(rate)
+	if err != nil {
+		return fmt.Errorf("invalid -mirror-bwlimit: %w", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("mirror directory %s is not available: %v", dir, err)
+	}
+	mirror = &Mirror{dir: dir, spec: spec, rate: limit, trigger: make(chan struct{}, 1)}
+	log.Printf("Mirroring %s to %s at %q", strings.Join(mirrorDirs, ", "), dir, cron)
+	go mirror.run()
+	return nil
+}
+
+// Trigger starts a run now, or after the current one
+func (m *Mirror) Trigger() {
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the mirror's state
+func (m *Mirror) Status() MirrorStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// run mirrors at the scheduled times and when triggered, forever
+func (m *Mirror) run() {
+	for {
+		next := m.spec.next(time.Now())
+		m.mu.Lock()
+		m.status.Next = next
+		m.mu.Unlock()
+		// A schedule that never matches leaves the trigger
+		timer := time.NewTimer(time.Until(next))
+		if next.IsZero() {
+			timer.Stop()
+		}
+		select {
+		case <-timer.C:
+		case <-m.trigger:
+			timer.Stop()
+		}
+
+		copied, size, err := m.sync()
+		m.mu.Lock()
+		m.status.Running = false
+		m.status.LastRun = time.Now()
+		m.status.LastResult = tr("mirror.result", copied, formatBytes(uint64(size)))
+		if err != nil {
+			m.status.LastResult = err.Error()
+		}
+		m.mu.Unlock()
+		if err != nil {
+			log.Printf("Mirror to %s: %v", m.dir, err)
+			notify(toastWarning, tr("toast.mirror_failed", m.dir, err))
+		} else if copied > 0 {
+			log.Printf("Mirrored %d files (%s) to %s", copied, formatBytes(uint64(size)), m.dir)
+		}
+	}
+}
+
+// mirrorFile is a file the mirror copies
+type mirrorFile struct {
+	rel  string
+	info fs.FileInfo
+}
+
+// pending lists the files missing or changed in the mirror
+func (m *Mirror) pending() ([]mirrorFile, error) {
+	var files []mirrorFile
+	for _, dir := range mirrorDirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return filepath.SkipDir
+			}
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			if strings.HasSuffix(path, recordingMarkerSuffix) || recordingInProgress(path) {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if target, err := os.Stat(filepath.Join(m.dir, path)); err == nil &&
+				target.Size() == info.Size() && target.ModTime().Equal(info.ModTime()) {
+				return nil
+			}
+			files = append(files, mirrorFile{rel: path, info: info})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// recordingInProgress reports whether path is a recording still being
+// written or one of its sidecars, which share its name up to the extension
+func recordingInProgress(path string) bool {
+	stem := strings.TrimSuffix(strings.TrimSuffix(path, filepath.Ext(path)), ".meta")
+	markers, _ := filepath.Glob(globEscape(stem) + ".*" + recordingMarkerSuffix)
+	return len(markers) > 0
+}
+
+// globEscape escapes the pattern characters of a path for filepath.Glob
+func globEscape(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sync copies the pending files, returning how many it copied and their
+// bytes
+func (m *Mirror) sync() (int, int64, error) {
+	files, err := m.pending()
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, file := range files {
+		total += file.info.Size()
+	}
+	m.mu.Lock()
+	m.status.Running, m.status.Done, m.status.Total = true, 0, total
+	m.mu.Unlock()
+
+	throttle := &mirrorThrottle{rate: m.rate, start: time.Now()}
+	copied := 0
+	var size int64
+	var errs []error
+	for _, file := range files {
+		err := m.copy(file, throttle)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted meanwhile
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.rel, err))
+			continue
+		}
+		copied++
+		size += file.info.Size()
+	}
+	return copied, size, errors.Join(errs...)
+}
+
+// copy copies one file through a .part file and checks the copy against the
+// checksum of what was read
+func (m *Mirror) copy(file mirrorFile, throttle *mirrorThrottle) error {
+	source, err := os.Open(file.rel)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target := filepath.Join(m.dir, file.rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	part := target + mirrorPartSuffix
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(part)
+
+	sum := sha256.New()
+	buf := make([]byte, mirrorChunk)
+	for {
+		n, readErr := source.Read(buf)
+		if n > 0 {
+			sum.Write(buf[:n])
+			if _, err := out.Write(buf[:n]); err != nil {
+				out.Close()
+				return err
+			}
+			m.mu.Lock()
+			m.status.Done += int64(n)
+			m.mu.Unlock()
+			throttle.wait(int64(n))
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			out.Close()
+			return readErr
+		}
+	}
+	err = out.Sync()
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := verifyMirrorCopy(part, sum); err != nil {
+		return err
+	}
+	if err := os.Chtimes(part, file.info.ModTime(), file.info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(part, target)
+}
+
+// verifyMirrorCopy reads a copy back and compares it with the checksum of
+// the original
+func verifyMirrorCopy(path string, original hash.Hash) error {
+	copied, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer copied.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, copied); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum.Sum(nil), original.Sum(nil)) {
+		return errors.New("the copy does not match the original")
+	}
+	return nil
+}
+
+// mirrorThrottle keeps the copying of a run below rate bytes a second
+type mirrorThrottle struct {
+	rate  int64
+	start time.Time
+	bytes int64
+}
+
+// wait counts n copied bytes and sleeps while the run is ahead of its rate
+func (t *mirrorThrottle) wait(n int64) {
+	t.bytes += n
+	if t.rate <= 0 {
+		return
+	}
+	due := t.start.Add(time.Duration(float64(t.bytes) / float64(t.rate) * float64(time.Second)))
+	if ahead := time.Until(due); ahead > 0 {
+		time.Sleep(ahead)
+	}
+}
+
+// mirrorLabel is the status bar text of the mirror
+func mirrorLabel(status MirrorStatus, now time.Time) string {
+	if status.Running {
+		percent := int64(100)
+		if status.Total > 0 {
+			percent = status.Done * 100 / status.Total
+		}
+		return tr("status.bar_mirroring", percent)
+	}
+	if status.Next.IsZero() {
+		return tr("status.bar_mirror", tr("schedule.never"))
+	}
+	return tr("status.bar_mirror", formatScheduleTime(status.Next, now))
+}
+
+// mirrorDetail describes the mirror for the status message
+func mirrorDetail(dir string, status MirrorStatus, now time.Time) string {
+	detail := tr("status.mirror_detail", dir)
+	if status.Running {
+		detail += tr("status.mirror_progress", formatBytes(uint64(status.Done)), formatBytes(uint64(status.Total)))
+	}
+	if !status.LastRun.IsZero() {
+		detail += tr("schedule.last", formatScheduleTime(status.LastRun, now), status.LastResult)
+	}
+	return detail
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseByteRate(t *testing.T) {
+	for text, want := range map[string]int64{"0": 0, "1500": 1500, "512K": 512 << 10, "2M": 2 << 20, "1.5m": 3 << 19, "1GB": 1 << 30} {
+		if got, err := parseByteRate(text); err != nil || got != want {
+			t.Errorf("%s parsed as %d, %v, expected %d", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "fast", "-1M", "2T"} {
+		if _, err := parseByteRate(text); err == nil {
+			t.Errorf("%s was accepted", text)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	t.Chdir(t.TempDir())
+	target := t.TempDir()
+	files := map[string]string{
+		"recordings/bench_20250101_120000.000.mjpeg":                         "frames",
+		"recordings/bench_20250101_120000.000.csv":                           "index",
+		"recordings/bench_20250101_130000.000.mjpeg":                         "growing",
+		"recordings/bench_20250101_130000.000.csv":                           "growing",
+		"recordings/bench_20250101_130000.000.meta.jsonl":                    "growing",
+		"recordings/bench_20250101_130000.000.mjpeg" + recordingMarkerSuffix: "",
+		"snapshots/bench_20250101_120500.000.jpg":                            "jpeg",
+	}
+	for name, data := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &Mirror{dir: target, trigger: make(chan struct{}, 1)}
+
+	copied, size, err := m.sync()
+	if err != nil || copied != 3 || size != 15 {
+		t.Fatalf("copied %d files, %d bytes, %v", copied, size, err)
+	}
+	for _, name := range []string{"recordings/bench_20250101_120000.000.mjpeg", "recordings/bench_20250101_120000.000.csv", "snapshots/bench_20250101_120500.000.jpg"} {
+		data, err := os.ReadFile(filepath.Join(target, name))
+		if err != nil || string(data) != files[name] {
+			t.Errorf("%s mirrored as %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(target, "recordings/bench_20250101_130000.000.mjpeg")); err == nil {
+		t.Error("a recording in progress was mirrored")
+	}
+	if status := m.Status(); status.Done != 15 || status.Total != 15 {
+		t.Errorf("status %+v", status)
+	}
+
+	// Unchanged files are not copied again, changed ones are
+	if copied, _, err := m.sync(); err != nil || copied != 0 {
+		t.Errorf("copied %d unchanged files, %v", copied, err)
+	}
+	if err := os.WriteFile("snapshots/bench_20250101_120500.000.jpg", []byte("retouched"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if copied, _, err := m.sync(); err != nil || copied != 1 {
+		t.Errorf("copied %d files after a change, %v", copied, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "snapshots/bench_20250101_120500.000.jpg")); string(data) != "retouched" {
+		t.Errorf("changed snapshot mirrored as %q", data)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(target, "*", "*"+mirrorPartSuffix)); len(parts) > 0 {
+		t.Errorf("left %v", parts)
+	}
+}
+
+func TestMirrorThrottle(t *testing.T) {
+	throttle := &mirrorThrottle{rate: 100_000, start: time.Now()}
+	for range 4 {
+		throttle.wait(5_000)
+	}
+	if elapsed := time.Since(throttle.start); elapsed < 190*time.Millisecond {
+		t.Errorf("copied 20 kB at 100 kB/s in %v", elapsed)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Concatenated JPEGs, as rpicam-vid writes them, are split by walking the
+// JPEG markers rather than searching for the end marker: segments carry
+// their length, so an EXIF thumbnail's markers are skipped, and in the
+// entropy-coded data only a 0xFF not followed by a stuffed zero or a
+// restart marker is a marker. Damaged input is skipped up to the next start
+// marker, and a frame that outgrows maxMJPEGFrameSize is dropped, so broken
+// input can neither grow the buffer without bound nor produce garbage
+// frames.
+
+// maxMJPEGFrameSize bounds the size of a frame
+const maxMJPEGFrameSize = 16 << 20
+
+const (
+	jpegSOI = 0xd8
+	jpegEOI = 0xd9
+	jpegSOS = 0xda
+	jpegTEM = 0x01
+)
+
+// mjpegSplitter splits a stream of concatenated JPEGs into frames
+type mjpegSplitter struct {
+	// MaxFrame is the largest frame kept, maxMJPEGFrameSize if 0
+	MaxFrame int
+	// Discarded counts the bytes skipped as damaged or oversized
+	Discarded int
+
+	buf []byte
+	// synced is set once buf starts with a start marker; pos is where
+	// scanning resumes and entropy whether pos is in entropy-coded data
+	synced  bool
+	pos     int
+	entropy bool
+	scanned bool
+	// oversized is set once the frame outgrew MaxFrame; it is still
+	// scanned to its end, but not kept
+	oversized bool
+}
+
+type scanResult int
+
+const (
+	scanIncomplete scanResult = iota
+	scanComplete
+	scanDamaged
+)
+
+// Write adds data from the stream and calls emit with each frame it
+// completes. Frames are copies, owned by emit.
+func (s *mjpegSplitter) Write(data []byte, emit func(frame []byte)) {
+	maxFrame := s.MaxFrame
+	if maxFrame == 0 {
+		maxFrame = maxMJPEGFrameSize
+	}
+	s.buf = append(s.buf, data...)
+	for {
+		if !s.synced && !s.sync() {
+			return
+		}
+		switch s.scan() {
+		case scanComplete:
+			if s.scanned && !s.oversized && s.pos <= maxFrame {
+				emit(bytes.Clone(s.buf[:s.pos]))
+			} else {
+				// Too large, or markers without image data
+				s.Discarded += s.pos
+			}
+			s.skip(s.pos)
+		case scanDamaged:
+			// Resync from the damage, which may be the start marker of
+			// the next frame
+			s.Discarded += s.pos
+			s.skip(s.pos)
+		default:
+			if s.oversized || len(s.buf) > maxFrame {
+				// Drop what was scanned, but follow the frame to its end
+				// rather than resync inside it
+				s.oversized = true
+				n := min(s.pos, len(s.buf))
+				s.Discarded += n
+				s.buf = append(s.buf[:0], s.buf[n:]...)
+				s.pos -= n
+			}
+			return
+		}
+	}
+}
+
+// skip drops n bytes from the buffer and starts looking for the next frame
+func (s *mjpegSplitter) skip(n int) {
+	s.buf = append(s.buf[:0], s.buf[n:]...)
+	s.synced = false
+}
+
+// sync discards the data before the next start marker, keeping a trailing
+// 0xFF that may begin one, and reports whether one was found
+func (s *mjpegSplitter) sync() bool {
+	start := bytes.Index(s.buf, []byte{0xff, jpegSOI})
+	if start < 0 {
+		keep := 0
+		if len(s.buf) > 0 && s.buf[len(s.buf)-1] == 0xff {
+			keep = 1
+		}
+		s.Discarded += len(s.buf) - keep
+		s.skip(len(s.buf) - keep)
+		return false
+	}
+	s.Discarded += start
+	s.skip(start)
+	s.synced, s.pos, s.entropy, s.scanned, s.oversized = true, 2, false, false, false
+	return true
+}
+
+// scan walks the markers of the frame at the start of the buffer from pos
+func (s *mjpegSplitter) scan() scanResult {
+	buf := s.buf
+	for s.pos < len(buf) {
+		if s.entropy {
+			next := bytes.IndexByte(buf[s.pos:], 0xff)
+			if next < 0 {
+				s.pos = len(buf)
+				return scanIncomplete
+			}
+			s.pos += next
+			if s.pos+1 >= len(buf) {
+				return scanIncomplete
+			}
+			marker := buf[s.pos+1]
+			if marker == 0x00 || marker >= 0xd0 && marker <= 0xd7 {
+				// A stuffed 0xFF byte or a restart marker
+				s.pos += 2
+				continue
+			}
+			if marker == 0xff {
+				// Fill byte
+				s.pos++
+				continue
+			}
+			s.entropy = false
+		}
+
+		if buf[s.pos] != 0xff {
+			return scanDamaged
+		}
+		if s.pos+1 >= len(buf) {
+			return scanIncomplete
+		}
+		marker := buf[s.pos+1]
+		switch {
+		case marker == 0xff:
+			s.pos++
+		case marker == jpegEOI:
+			s.pos += 2
+			return scanComplete
+		case marker == jpegSOI || marker == 0x00:
+			// A frame cut off by the next one, or no marker at all
+			return scanDamaged
+		case marker == jpegTEM || marker >= 0xd0 && marker <= 0xd7:
+			s.pos += 2
+		default:
+			if s.pos+4 > len(buf) {
+				return scanIncomplete
+			}
+			length := int(binary.BigEndian.Uint16(buf[s.pos+2:]))
+			if length < 2 {
+				return scanDamaged
+			}
+			s.pos += 2 + length
+			if marker == jpegSOS {
+				s.entropy, s.scanned = true, true
+			}
+		}
+	}
+	return scanIncomplete
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testJPEG encodes a small image whose content depends on seed
+func testJPEG(t testing.TB, seed int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 32, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i*7 + seed*31)
+	}
+	img.SetGray(seed%32, 0, color.Gray{Y: 255})
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withThumbnail embeds a JPEG thumbnail in an EXIF segment of frame, as
+// cameras do; its markers must not end the frame
+func withThumbnail(frame, thumbnail []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), thumbnail...)
+	segment := []byte{0xff, 0xe1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	out := append([]byte{}, frame[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, frame[2:]...)
+}
+
+// restartFrame is a minimal frame whose entropy-coded data holds a stuffed
+// 0xFF byte, restart markers and fill bytes
+var restartFrame = []byte{
+	0xff, 0xd8,
+	0xff, 0xdd, 0x00, 0x04, 0x00, 0x01, // DRI
+	0xff, 0xda, 0x00, 0x02, // SOS
+	0x12, 0xff, 0x00, 0x34, 0xff, 0xd0, 0x56, 0xff, 0xd1, 0x78, 0xff, 0xff, 0xd2, 0x9a,
+	0xff, 0xd9,
+}
+
+// splitAll feeds data to a splitter in chunks of size chunk and returns
+// the frames
+func splitAll(s *mjpegSplitter, data []byte, chunk int) [][]byte {
+	var frames [][]byte
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		s.Write(data[:n], func(frame []byte) { frames = append(frames, frame) })
+		data = data[n:]
+	}
+	return frames
+}
+
+func TestMJPEGSplitterFrames(t *testing.T) {
+	want := [][]byte{
+		testJPEG(t, 1),
+		withThumbnail(testJPEG(t, 2), testJPEG(t, 3)),
+		restartFrame,
+		testJPEG(t, 4),
+	}
+	stream := bytes.Join(want, nil)
+	for _, chunk := range []int{1, 3, 64, 1000, len(stream)} {
+		var s mjpegSplitter
+		got := splitAll(&s, stream, chunk)
+		if len(got) != len(want) {
+			t.Fatalf("chunk %d: got %d frames, want %d", chunk, len(got), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Errorf("chunk %d: frame %d differs", chunk, i)
+			}
+		}
+		if s.Discarded != 0 {
+			t.Errorf("chunk %d: discarded %d bytes of a clean stream", chunk, s.Discarded)
+		}
+	}
+}
+
+func TestMJPEGSplitterResyncs(t *testing.T) {
+	first, second, third := testJPEG(t, 1), testJPEG(t, 2), testJPEG(t, 3)
+	var stream []byte
+	stream = append(stream, "garbage before the first frame"...)
+	stream = append(stream, first...)
+	// A frame cut off in its entropy-coded data by the next one
+	stream = append(stream, second[:len(second)-40]...)
+	stream = append(stream, third...)
+	// Markers without image data
+	stream = append(stream, 0xff, 0xd8, 0xff, 0xd9)
+	// A frame with a broken segment length
+	stream = append(stream, 0xff, 0xd8, 0xff, 0xe0, 0x00, 0x01, 0xff, 0xd9)
+	stream = append(stream, first...)
+
+	var s mjpegSplitter
+	got := splitAll(&s, stream, 100)
+	want := [][]byte{first, third, first}
+	if len(got) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("frame %d differs", i)
+		}
+	}
+	if s.Discarded == 0 {
+		t.Error("damaged data not counted")
+	}
+}
+
+func TestMJPEGSplitterDropsOversizedFrames(t *testing.T) {
+	small := testJPEG(t, 1)
+	large := withThumbnail(testJPEG(t, 2), bytes.Repeat(testJPEG(t, 3), 20))
+	s := mjpegSplitter{MaxFrame: len(large) - 1}
+	stream := bytes.Join([][]byte{small, large, small}, nil)
+	got := splitAll(&s, stream, 256)
+	if len(got) != 2 || !bytes.Equal(got[0], small) || !bytes.Equal(got[1], small) {
+		t.Fatalf("got %d frames, want the two small ones", len(got))
+	}
+	if s.Discarded != len(large) {
+		t.Errorf("discarded %d bytes, want %d", s.Discarded, len(large))
+	}
+}
+
+func FuzzMJPEGSplitter(f *testing.F) {
+	f.Add(bytes.Join([][]byte{testJPEG(f, 1), testJPEG(f, 2)}, nil), 7)
+	f.Add(withThumbnail(testJPEG(f, 1), testJPEG(f, 2)), 1)
+	f.Add(restartFrame, 3)
+	f.Add([]byte{0xff, 0xd8, 0xff, 0xda, 0x00, 0x02, 0xff, 0xff, 0xff, 0xd8, 0xff, 0xd9}, 2)
+	f.Add([]byte("\xff\xd8\xff\xe0\xff\xff\xff\xd8\xff\xd9"), 5)
+
+	const maxFrame = 4096
+	f.Fuzz(func(t *testing.T, data []byte, chunk int) {
+		chunk = max(chunk%512, 1)
+		s := mjpegSplitter{MaxFrame: maxFrame}
+		var frames [][]byte
+		emitted := 0
+		for rest := data; len(rest) > 0; {
+			n := min(chunk, len(rest))
+			s.Write(rest[:n], func(frame []byte) {
+				frames = append(frames, frame)
+				emitted += len(frame)
+			})
+			rest = rest[n:]
+			if len(s.buf) > maxFrame {
+				t.Fatalf("buffer grew to %d bytes", len(s.buf))
+			}
+		}
+
+		for _, frame := range frames {
+			if len(frame) > maxFrame {
+				t.Fatalf("emitted a %d byte frame", len(frame))
+			}
+			if !bytes.HasPrefix(frame, []byte{0xff, 0xd8}) || !bytes.HasSuffix(frame, []byte{0xff, 0xd9}) {
+				t.Fatalf("emitted a frame without start or end marker: % x", frame)
+			}
+		}
+		if total := emitted + s.Discarded + len(s.buf); total != len(data) {
+			t.Fatalf("%d bytes emitted, %d discarded and %d buffered of %d", emitted, s.Discarded, len(s.buf), len(data))
+		}
+
+		// Where the reads happen to end must not change the frames
+		whole := mjpegSplitter{MaxFrame: maxFrame}
+		if again := splitAll(&whole, data, len(data)+1); len(again) != len(frames) {
+			t.Fatalf("%d frames in chunks of %d, %d in one", len(frames), chunk, len(again))
+		} else {
+			for i := range again {
+				if !bytes.Equal(again[i], frames[i]) {
+					t.Fatalf("frame %d depends on chunking", i)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"log"
+	"slices"
+	"time"
+)
+
+// A moment is the last few seconds of a camera's history saved as an
+// animated GIF or WebP, small enough to drop into a chat where a video
+// attachment would be a nuisance. Frames are taken at momentFPS at most and
+// shrunk to fit momentMaxSize; each shows for as long as it did live.
+
+// momentFormats are the formats moments are saved in
+var momentFormats = []string{"gif", "webp"}
+
+// momentSeconds is how much of the history a moment covers, set with
+// -moment-seconds
+var momentSeconds = 5.0
+
+const (
+	// momentFPS bounds the frame rate of moments
+	momentFPS = 10
+	// momentMaxSize bounds the width and height of moments
+	momentMaxSize = 480
+)
+
+// momentFrames decodes and shrinks the frames of a moment, dropping those
+// beyond momentFPS, and works out how long each is shown in milliseconds
+func momentFrames(frames []Frame) ([]webpFrame, error) {
+	const interval = time.Second / momentFPS
+	var picked []Frame
+	for _, frame := range frames {
+		if len(picked) == 0 || frame.Captured.Sub(picked[len(picked)-1].Captured) >= interval {
+			picked = append(picked, frame)
+		}
+	}
+	moment := make([]webpFrame, 0, len(picked))
+	for i, frame := range picked {
+		img, err := decodeImage(frame)
+		if err != nil {
+			return nil, err
+		}
+		if size := img.Bounds().Size(); size.X > momentMaxSize || size.Y > momentMaxSize {
+			scale := float64(momentMaxSize) / float64(max(size.X, size.Y))
+			img = shrinkImage(img, max(int(float64(size.X)*scale), 1), max(int(float64(size.Y)*scale), 1))
+		}
+		duration := interval
+		if i+1 < len(picked) {
+			duration = picked[i+1].Captured.Sub(frame.Captured)
+		}
+		moment = append(moment, webpFrame{Image: img, Duration: int(duration.Milliseconds())})
+	}
+	return moment, nil
+}
+
+// encodeMoment writes frames of a camera's history as an animation in format
+func encodeMoment(w io.Writer, format string, frames []Frame) error {
+	if !validMomentFormat(format) {
+		return fmt.Errorf("unknown moment format %q, expected one of %v", format, momentFormats)
+	}
+	if len(frames) == 0 {
+		return trErr("error.no_history")
+	}
+	moment, err := momentFrames(frames)
+	if err != nil {
+		return err
+	}
+	if format == "webp" {
+		return encodeAnimatedWebP(w, moment)
+	}
+	return encodeGIF(w, moment)
+}
+
+// encodeGIF writes frames as a looping GIF, dithered to the web palette
+func encodeGIF(w io.Writer, frames []webpFrame) error {
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(frame.Image.Bounds(), palette.WebSafe)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame.Image, image.Point{})
+		anim.Image = append(anim.Image, paletted)
+		// GIF delays are in hundredths of a second
+		anim.Delay = append(anim.Delay, max((frame.Duration+5)/10, 1))
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// exportMoment saves the last seconds of a camera's history in format in
+// the background, reporting the outcome in a toast
+func exportMoment(camera *CameraInstance, format string, seconds float64) {
+	frames := camera.History.Since(time.Duration(seconds * float64(time.Second)))
+	if len(frames) == 0 {
+		notify(toastError, tr("toast.moment_failed", trErr("error.no_history")))
+		return
+	}
+	path, err := outputPath(snapshotDir, camera, format)
+	if err != nil {
+		notify(toastError, tr("toast.moment_failed", err))
+		return
+	}
+	name := camera.Info.Name
+	go func() {
+		var buf bytes.Buffer
+		if err := encodeMoment(&buf, format, frames); err != nil {
+			log.Printf("Saving a moment of %s: %v", name, err)
+			notify(toastError, tr("toast.moment_failed", err))
+			return
+		}
+		if err := writeOutputFile(path, buf.Bytes()); err != nil {
+			log.Printf("Saving a moment of %s: %v", name, err)
+			notify(toastError, tr("toast.moment_failed", err))
+			return
+		}
+		log.Printf("Saved %d frames of %s to %s", len(frames), name, path)
+		notify(toastInfo, tr("toast.moment_exported", path))
+		queueUpload(path)
+	}()
+}
+
+// validMomentFormat reports whether moments can be saved in format
+func validMomentFormat(format string) bool {
+	return slices.Contains(momentFormats, format)
+}
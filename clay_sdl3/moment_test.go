@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testHistory is a history of n test frames 40ms apart
+func testHistory(t *testing.T, n int) *frameHistory {
+	t.Helper()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := &frameHistory{}
+	for i := range n {
+		h.add(Frame{Data: testJPEG(t, i), Captured: start.Add(time.Duration(i) * 40 * time.Millisecond)})
+	}
+	return h
+}
+
+func TestMomentFrames(t *testing.T) {
+	frames := testHistory(t, 10).Since(time.Hour)
+	moment, err := momentFrames(frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// At 25 fps every third frame is taken
+	if len(moment) != 4 {
+		t.Fatalf("moment of %d frames", len(moment))
+	}
+	for i, frame := range moment {
+		if frame.Duration != 120 && i < 3 || frame.Duration != 100 && i == 3 {
+			t.Errorf("frame %d lasts %d ms", i, frame.Duration)
+		}
+	}
+
+	large := []Frame{{Raw: grayFrame(1280, 720)}}
+	if moment, err := momentFrames(large); err != nil || moment[0].Image.Bounds().Dx() != momentMaxSize || moment[0].Image.Bounds().Dy() != 270 {
+		t.Errorf("large frame shrunk to %v, %v", moment[0].Image.Bounds(), err)
+	}
+}
+
+func TestEncodeMomentGIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeMoment(&buf, "gif", testHistory(t, 10).Since(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 4 || anim.Delay[0] != 12 || anim.LoopCount != 0 || anim.Image[0].Bounds().Dx() != 32 {
+		t.Errorf("GIF of %d frames, delays %v, loop %d", len(anim.Image), anim.Delay, anim.LoopCount)
+	}
+
+	if err := encodeMoment(&buf, "gif", nil); err == nil {
+		t.Error("an empty moment was encoded")
+	}
+	if err := encodeMoment(&buf, "mp4", testHistory(t, 1).Since(time.Hour)); err == nil {
+		t.Error("a moment was encoded as MP4")
+	}
+}
+
+func TestAPIMoment(t *testing.T) {
+	appData := &CameraAppData{Cameras: []CameraInstance{
+		{Info: CameraInfo{Name: "bench"}, Active: true, History: testHistory(t, 10)},
+		{Info: CameraInfo{Name: "idle"}, Active: true},
+	}}
+	handler := serveAPI(t, appData)
+	for _, test := range []struct {
+		query  string
+		status int
+		kind   string
+	}{
+		{"/api/cameras/0/moment", http.StatusOK, "image/gif"},
+		{"/api/cameras/0/moment?format=webp&seconds=0.2", http.StatusOK, "image/webp"},
+		{"/api/cameras/0/moment?format=mp4", http.StatusBadRequest, ""},
+		{"/api/cameras/0/moment?seconds=-1", http.StatusBadRequest, ""},
+		{"/api/cameras/1/moment", http.StatusServiceUnavailable, ""},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.query, nil))
+		if w.Code != test.status || test.kind != "" && w.Header().Get("Content-Type") != test.kind {
+			t.Errorf("%s: %d %s", test.query, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
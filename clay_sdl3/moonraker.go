@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The app can stand in for mjpg-streamer, the webcam server 3D printer
+// frontends expect: with -api-listen, /?action=stream and /?action=snapshot
+// serve the first camera and /webcam/?action=... too, with /webcam2/,
+// /webcam3/ and so on up to maxWebcams for the others, so OctoPrint, Mainsail and Fluidd
+// work with their stock URLs. -moonraker http://printer:7125 also adds the
+// cameras to Moonraker's webcam list, from where Mainsail and Fluidd pick
+// them up; -moonraker-key gives Moonraker's API key, if it asks for one.
+
+const (
+	// moonrakerRetry is how long a failed registration waits before trying
+	// again
+	moonrakerRetry = 30 * time.Second
+	// moonrakerTimeout bounds a request to Moonraker
+	moonrakerTimeout = 10 * time.Second
+	// moonrakerFPS is the frame rate the frontends are told to expect
+	moonrakerFPS = 15
+	// maxWebcams is how many cameras are served at /webcamN/
+	maxWebcams = 16
+)
+
+// moonrakerWebcam is an entry of Moonraker's webcam list
+type moonrakerWebcam struct {
+	Name        string `json:"name"`
+	Location    string `json:"location"`
+	Service     string `json:"service"`
+	Enabled     bool   `json:"enabled"`
+	TargetFPS   int    `json:"target_fps"`
+	StreamURL   string `json:"stream_url"`
+	SnapshotURL string `json:"snapshot_url"`
+	AspectRatio string `json:"aspect_ratio"`
+}
+
+// handleRoot serves the web UI, or a frame or stream of the first camera
+// for mjpg-streamer's ?action= URLs
+func (s *APIServer) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("action") {
+		requireRole(roleViewer, s.handleMJPGStreamer(0))(w, r)
+		return
+	}
+	handleWebUI(w, r)
+}
+
+// handleMJPGStreamer returns the handler of mjpg-streamer's ?action=stream
+// and ?action=snapshot for the camera at position index of the camera list
+func (s *APIServer) handleMJPGStreamer(index int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveMJPGStreamer(w, r, index)
+	}
+}
+
+func (s *APIServer) serveMJPGStreamer(w http.ResponseWriter, r *http.Request, index int) {
+	s.mu.Lock()
+	var id int
+	found := index < len(s.cameras)
+	if found {
+		id = s.cameras[index].ID
+	}
+	s.mu.Unlock()
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.SetPathValue("id", strconv.Itoa(id))
+	switch action := r.URL.Query().Get("action"); action {
+	case "stream":
+		s.handleStream(w, r)
+	case "snapshot":
+		s.handleLatest(w, r)
+	default:
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("action %q is not supported, expected stream or snapshot", action))
+	}
+}
+
+// mjpgStreamerPath returns the path mjpg-streamer serves the camera at
+// position index of the camera list at
+func mjpgStreamerPath(index int) string {
+	if index == 0 {
+		return "/webcam/"
+	}
+	return fmt.Sprintf("/webcam%d/", index+1)
+}
+
+// startMoonraker adds the cameras to Moonraker's webcam list once the
+// camera list is known, retrying until Moonraker takes them. base is the
+// API's URL as the frontends reach it, derived from apiAddress if empty.
+func startMoonraker(moonraker, key, base, apiAddress string) error {
+	if base == "" {
+		_, port, err := net.SplitHostPort(apiAddress)
+		if err != nil {
+			return fmt.Errorf("invalid API address %q: %w", apiAddress, err)
+		}
+		base = "http://" + net.JoinHostPort(mdnsHostName()+".local", port)
+	}
+	base = strings.TrimRight(base, "/")
+	if err := startAPI(); err != nil {
+		return err
+	}
+	go func() {
+		events := api.subscribe()
+		var cameras []apiCamera
+		for event := range events.events {
+			if list, ok := event.Data.([]apiCamera); ok && len(list) > 0 {
+				cameras = list
+				break
+			}
+		}
+		api.unsubscribe(events)
+		if len(cameras) == 0 {
+			log.Printf("Moonraker: no cameras to add")
+			return
+		}
+		for {
+			err := registerMoonrakerWebcams(moonraker, key, base, cameras)
+			if err == nil {
+				log.Printf("Added %d cameras to Moonraker at %s", len(cameras), redactSecrets(moonraker))
+				return
+			}
+			log.Printf("Moonraker: %v", redactSecrets(err.Error()))
+			time.Sleep(moonrakerRetry)
+		}
+	}()
+	return nil
+}
+
+// moonrakerWebcams describes the cameras as webcams of Moonraker, reached at
+// base
+func moonrakerWebcams(base string, cameras []apiCamera) []moonrakerWebcam {
+	// Frontends cannot send tokens but in the URL
+	query := ""
+	for _, token := range apiTokens {
+		if token.role == roleViewer {
+			query = "&token=" + url.QueryEscape(token.Token)
+			break
+		}
+	}
+	if len(apiTokens) > 0 && query == "" {
+		log.Printf("Warning: the API has no viewer token for Moonraker's webcam URLs, so frontends will be refused")
+	}
+
+	cameras = cameras[:min(len(cameras), maxWebcams)]
+	webcams := make([]moonrakerWebcam, 0, len(cameras))
+	for i, camera := range cameras {
+		aspect := "4:3"
+		if camera.Height > 0 && float64(camera.Width)/float64(camera.Height) > 1.55 {
+			aspect = "16:9"
+		}
+		path := base + mjpgStreamerPath(i)
+		webcams = append(webcams, moonrakerWebcam{
+			Name:        camera.Name,
+			Location:    "printer",
+			Service:     "mjpegstreamer-adaptive",
+			Enabled:     true,
+			TargetFPS:   moonrakerFPS,
+			StreamURL:   path + "?action=stream" + query,
+			SnapshotURL: path + "?action=snapshot" + query,
+			AspectRatio: aspect,
+		})
+	}
+	return webcams
+}
+
+// registerMoonrakerWebcams adds or updates the cameras in Moonraker's webcam
+// list
+func registerMoonrakerWebcams(moonraker, key, base string, cameras []apiCamera) error {
+	client := &http.Client{Timeout: moonrakerTimeout}
+	endpoint := strings.TrimRight(remoteBaseURL(moonraker), "/") + "/server/webcams/item"
+	var errs []error
+	for _, webcam := range moonrakerWebcams(base, cameras) {
+		body, err := json.Marshal(webcam)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("X-Api-Key", key)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", webcam.Name, err)
+		}
+		reply, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			errs = append(errs, fmt.Errorf("failed to add %s: %s: %s", webcam.Name, resp.Status, bytes.TrimSpace(reply)))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMJPGStreamerURLs(t *testing.T) {
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "nozzle"}, Active: true}}}
+	s := newAPIServer()
+	serveAPIServer(t, s, appData)
+	s.Publish(0, &appData.Cameras[0], Frame{Data: []byte("jpeg"), Captured: time.Now()})
+	handler := s.routes()
+
+	for target, want := range map[string]int{
+		"/?action=snapshot":         http.StatusOK,
+		"/webcam/?action=snapshot":  http.StatusOK,
+		"/webcam2/?action=snapshot": http.StatusNotFound,
+		"/webcam/?action=zoom":      http.StatusBadRequest,
+		"/webcam1/?action=snapshot": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("%s answered %d, expected %d", target, w.Code, want)
+		} else if want == http.StatusOK && w.Body.String() != "jpeg" {
+			t.Errorf("%s sent %q", target, w.Body)
+		}
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("/ without an action served %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRegisterMoonrakerWebcams(t *testing.T) {
+	t.Cleanup(func() { apiTokens = nil })
+	apiTokens = []APIToken{{Token: "lobby", role: roleViewer}}
+	var added []moonrakerWebcam
+	moonraker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webcam moonrakerWebcam
+		if r.URL.Path != "/server/webcams/item" || r.Header.Get("X-Api-Key") != "key" || json.NewDecoder(r.Body).Decode(&webcam) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		added = append(added, webcam)
+	}))
+	t.Cleanup(moonraker.Close)
+
+	cameras := []apiCamera{{ID: 0, Name: "nozzle", Width: 640, Height: 480}, {ID: 2, Name: "bed", Width: 1920, Height: 1080}}
+	if err := registerMoonrakerWebcams(moonraker.URL, "key", "http://pi.local:8080", cameras); err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 {
+		t.Fatalf("added %+v", added)
+	}
+	if added[0].StreamURL != "http://pi.local:8080/webcam/?action=stream&token=lobby" || added[0].AspectRatio != "4:3" {
+		t.Errorf("first webcam %+v", added[0])
+	}
+	if added[1].SnapshotURL != "http://pi.local:8080/webcam2/?action=snapshot&token=lobby" || added[1].AspectRatio != "16:9" {
+		t.Errorf("second webcam %+v", added[1])
+	}
+	if err := registerMoonrakerWebcams(moonraker.URL, "wrong", "http://pi.local:8080", cameras); err == nil {
+		t.Error("a refused webcam was not reported")
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 packet types
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttSubscribe  = 8
+	mqttPingReq    = 12
+	mqttDisconnect = 14
+)
+
+const (
+	mqttKeepAlive   = 30 * time.Second
+	mqttDialTimeout = 5 * time.Second
+)
+
+// MQTTClient is a minimal MQTT 3.1.1 client: QoS 0 publish and subscribe,
+// which is all the app needs to read machine state and publish its own.
+type MQTTClient struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+	nextID  uint16
+}
+
+// MQTTWill is a message the broker publishes for a client when its
+// connection is lost
+type MQTTWill struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// dialMQTT connects to a broker given as "host[:port]" or
+// "user:password@host[:port]", leaving a will with it if given
+func dialMQTT(broker, clientID string, will *MQTTWill) (*MQTTClient, error) {
+	u, err := url.Parse("mqtt://" + broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker %q: %w", broker, err)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "1883")
+	}
+	conn, err := net.DialTimeout("tcp", address, mqttDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT broker %s: %w", address, err)
+	}
+	c := &MQTTClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	// Variable header: protocol name and level, flags and keep alive
+	flags := byte(0x02) // clean session
+	payload := mqttString(clientID)
+	if will != nil {
+		flags |= 0x04
+		if will.Retain {
+			flags |= 0x20
+		}
+		payload = append(payload, mqttString(will.Topic)...)
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(will.Payload)))
+		payload = append(payload, will.Payload...)
+	}
+	if user := u.User; user != nil {
+		flags |= 0x80
+		payload = append(payload, mqttString(user.Username())...)
+		if password, ok := user.Password(); ok {
+			flags |= 0x40
+			payload = append(payload, mqttString(password)...)
+		}
+	}
+	body := append(mqttString("MQTT"), 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = append(body, payload...)
+	if err := c.write(mqttConnect<<4, body); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(mqttDialTimeout))
+	kind, ack, err := c.read()
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no answer from MQTT broker %s: %w", address, err)
+	}
+	if kind != mqttConnAck || len(ack) < 2 || ack[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("MQTT broker %s refused the connection", address)
+	}
+	return c, nil
+}
+
+// mqttString encodes a length-prefixed UTF-8 string
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+// write sends a packet with its fixed header
+func (c *MQTTClient) write(header byte, body []byte) error {
+	packet := []byte{header}
+	// Remaining length, 7 bits per byte
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(mqttKeepAlive))
+	if _, err := c.conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to write to MQTT broker: %w", err)
+	}
+	return nil
+}
+
+// read returns the type and body of the next packet
+func (c *MQTTClient) read() (byte, []byte, error) {
+	header, err := c.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("malformed MQTT packet length")
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+// Subscribe asks for the messages of a topic filter at QoS 0. The broker's
+// acknowledgement arrives through Run.
+func (c *MQTTClient) Subscribe(filter string) error {
+	c.nextID++
+	body := binary.BigEndian.AppendUint16(nil, c.nextID)
+	body = append(body, mqttString(filter)...)
+	body = append(body, 0)
+	return c.write(mqttSubscribe<<4|0x02, body)
+}
+
+// Publish sends a message at QoS 0
+func (c *MQTTClient) Publish(topic string, payload []byte, retain bool) error {
+	header := byte(mqttPublish << 4)
+	if retain {
+		header |= 0x01
+	}
+	return c.write(header, append(mqttString(topic), payload...))
+}
+
+// Run delivers incoming messages to handle and keeps the connection alive
+// until it fails
+func (c *MQTTClient) Run(handle func(topic string, payload []byte)) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(mqttKeepAlive / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if c.write(mqttPingReq<<4, nil) != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(2 * mqttKeepAlive))
+		kind, body, err := c.read()
+		if err != nil {
+			return fmt.Errorf("MQTT connection lost: %w", err)
+		}
+		if kind != mqttPublish || len(body) < 2 {
+			// Acknowledgements and ping responses need no handling
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(body))
+		if len(body) < 2+n {
+			continue
+		}
+		// QoS 0 messages have no packet ID after the topic
+		handle(string(body[2:2+n]), body[2+n:])
+	}
+}
+
+// Close disconnects from the broker
+func (c *MQTTClient) Close() error {
+	_ = c.write(mqttDisconnect<<4, nil)
+	return c.conn.Close()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// Cameras can capture faster than the window shows them, for slow motion
+// recordings and analysis: -capture-fps max opens V4L2 cameras at the
+// fastest rate their mode offers (or -capture-fps N at N), and
+// -display-every N shows only every Nth frame. Recordings, streams and the
+// frame history still get every frame; only decoding and drawing are spared.
+
+// captureFastest asks for the fastest rate a mode offers
+const captureFastest = ^uint32(0)
+
+// captureFPS is the frame rate V4L2 cameras are opened with, 0 to leave it
+// to the driver or captureFastest, set with -capture-fps
+var captureFPS uint32
+
+// displayEvery is how many frames of a camera make one shown, set with
+// -display-every
+var displayEvery = 1
+
+// setCaptureFPS sets the capture frame rate, given as a number, "max" or
+// "auto" for the driver's default
+func setCaptureFPS(value string) error {
+	switch strings.ToLower(value) {
+	case "", "auto":
+		captureFPS = 0
+		return nil
+	case "max":
+		captureFPS = captureFastest
+		return nil
+	}
+	fps, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || fps == 0 {
+		return fmt.Errorf("invalid capture frame rate %q, expected a number, max or auto", value)
+	}
+	captureFPS = uint32(fps)
+	return nil
+}
+
+// captureRate returns the frame rate to open a device's mode at, 0 to leave
+// it to the driver
+func captureRate(dev videoDevice, mode deviceMode, format v4l2.FourCCType) uint32 {
+	if captureFPS != captureFastest {
+		return captureFPS
+	}
+	if rates := modeRates(dev, format, mode.Width, mode.Height); len(rates) > 0 {
+		return rates[0]
+	}
+	return 0
+}
+
+// frameQueueSize is how many frames a camera at fps may queue for the main
+// loop, enough for a fast camera to wait out a slow pass
+func frameQueueSize(fps uint32) int {
+	return max(10, int(fps/6))
+}
+
+// displayFrame reports whether the next frame taken from a camera is one to
+// show
+func displayFrame(camera *CameraInstance) bool {
+	show := camera.taken%uint64(max(displayEvery, 1)) == 0
+	camera.taken++
+	return show
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// useCaptureFPS sets -capture-fps for the rest of the test
+func useCaptureFPS(t *testing.T, value string) {
+	t.Helper()
+	saved := captureFPS
+	if err := setCaptureFPS(value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { captureFPS = saved })
+}
+
+func TestCaptureAtFastestRate(t *testing.T) {
+	useCaptureFPS(t, "max")
+	dev := newFakeDevice(1)
+	dev.Rates = []uint32{120, 60, 30}
+	camera := startFakeCamera(t, dev)
+	if camera.StreamFPS != 120 {
+		t.Errorf("streaming at %d fps, mode %q", camera.StreamFPS, camera.Mode)
+	}
+	receiveFrames(t, camera, 1)
+}
+
+func TestCaptureRateFallsBackSlower(t *testing.T) {
+	useCaptureFPS(t, "60")
+	dev := newFakeDevice(1)
+	dev.Rates = []uint32{120, 60, 30}
+	dev.Refuse = func(_ v4l2.PixFormat, fps uint32) bool { return fps == 60 }
+	camera := startFakeCamera(t, dev)
+	if camera.StreamFPS != 30 {
+		t.Errorf("streaming at %d fps, want 30", camera.StreamFPS)
+	}
+}
+
+func TestSetCaptureFPS(t *testing.T) {
+	useCaptureFPS(t, "auto")
+	for value, want := range map[string]uint32{"auto": 0, "MAX": captureFastest, "90": 90} {
+		if err := setCaptureFPS(value); err != nil || captureFPS != want {
+			t.Errorf("%q set %d, %v", value, captureFPS, err)
+		}
+	}
+	for _, value := range []string{"0", "-5", "fast"} {
+		if err := setCaptureFPS(value); err == nil {
+			t.Errorf("%q accepted", value)
+		}
+	}
+}
+
+func TestTakeFramesDisplaysEveryNth(t *testing.T) {
+	saved := displayEvery
+	displayEvery = 4
+	t.Cleanup(func() { displayEvery = saved })
+	appData := &CameraAppData{Cameras: []CameraInstance{{
+		Info:      CameraInfo{Name: "fast", Path: "/dev/fast"},
+		Active:    true,
+		FrameChan: make(chan Frame, 10),
+	}}}
+	camera := &appData.Cameras[0]
+	var shown []byte
+	for i := range 10 {
+		camera.FrameChan <- Frame{Data: []byte{byte(i)}}
+		if frame, ok := takeFrames(appData, 0); ok {
+			shown = append(shown, frame.Data[0])
+		}
+	}
+	if string(shown) != string([]byte{0, 4, 8}) {
+		t.Errorf("showed frames %v", shown)
+	}
+	// Every frame still reaches the history
+	if camera.History.Len() != 10 {
+		t.Errorf("history holds %d frames", camera.History.Len())
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// Cameras renamed in the UI keep their names across restarts and
+// replugging: names are saved in namesFile against a stable ID rather than
+// the /dev/video node, which changes with the order devices are found in.
+// The ID of a V4L2 camera is its /dev/v4l/by-id link, which udev builds from
+// the USB vendor, product and serial number, or else its bus and card name;
+// other sources use their path.
+
+// namesFile maps the stable IDs of cameras to the names given to them
+const namesFile = "camera_names.json"
+
+// byIDDir holds udev's links to video devices by hardware ID
+var byIDDir = "/dev/v4l/by-id"
+
+// cameraNames are the names given to cameras, by stable ID; nil until
+// namesFile is read
+var cameraNames map[string]string
+
+// stableID returns an ID for a V4L2 device that does not depend on its
+// /dev/video number
+func stableID(devicePath string, caps v4l2.Capability) string {
+	target, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		target = devicePath
+	}
+	links, _ := filepath.Glob(filepath.Join(byIDDir, "*"))
+	for _, link := range links {
+		if resolved, err := filepath.EvalSymlinks(link); err == nil && resolved == target {
+			return "by-id:" + filepath.Base(link)
+		}
+	}
+	if bus := strings.TrimRight(caps.BusInfo, "\x00"); bus != "" {
+		return "bus:" + bus + "/" + strings.TrimRight(caps.Card, "\x00")
+	}
+	return devicePath
+}
+
+// cameraID is the key a camera's name is saved under
+func cameraID(info CameraInfo) string {
+	if info.ID != "" {
+		return info.ID
+	}
+	return info.Path
+}
+
+// loadCameraNames reads namesFile, if there is one
+func loadCameraNames() error {
+	cameraNames = map[string]string{}
+	data, err := os.ReadFile(namesFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load camera names: %w", err)
+	}
+	if err := json.Unmarshal(data, &cameraNames); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", namesFile, err)
+	}
+	return nil
+}
+
+// applyCameraNames gives found cameras the names saved for them
+func applyCameraNames(devices []CameraInfo) {
+	if cameraNames == nil {
+		if err := loadCameraNames(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	for i := range devices {
+		if name, ok := cameraNames[cameraID(devices[i])]; ok && name != "" {
+			devices[i].Name = name
+		}
+	}
+}
+
+// saveCameraName remembers the name given to a camera
+func saveCameraName(info CameraInfo, name string) error {
+	if cameraNames == nil {
+		if err := loadCameraNames(); err != nil {
+			return err
+		}
+	}
+	cameraNames[cameraID(info)] = name
+	data, err := json.MarshalIndent(cameraNames, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode camera names: %w", err)
+	}
+	if err := os.WriteFile(namesFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save camera names: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+func TestStableID(t *testing.T) {
+	dir := t.TempDir()
+	device := filepath.Join(dir, "video2")
+	if err := os.WriteFile(device, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(old string) { byIDDir = old }(byIDDir)
+	byIDDir = filepath.Join(dir, "by-id")
+	if err := os.Mkdir(byIDDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	caps := v4l2.Capability{Card: "USB 2.0 Camera: USB 2.0 Camera", BusInfo: "usb-0000:00:14.0-2"}
+
+	if id := stableID(device, caps); id != "bus:usb-0000:00:14.0-2/USB 2.0 Camera: USB 2.0 Camera" {
+		t.Errorf("without a by-id link the ID is %q", id)
+	}
+	if err := os.Symlink("../video2", filepath.Join(byIDDir, "usb-Vendor_Cam_1234-video-index0")); err != nil {
+		t.Fatal(err)
+	}
+	if id := stableID(device, caps); id != "by-id:usb-Vendor_Cam_1234-video-index0" {
+		t.Errorf("with a by-id link the ID is %q", id)
+	}
+	if id := stableID(filepath.Join(dir, "video4"), v4l2.Capability{}); id != filepath.Join(dir, "video4") {
+		t.Errorf("without bus info the ID is %q", id)
+	}
+}
+
+func TestCameraNames(t *testing.T) {
+	t.Chdir(t.TempDir())
+	defer func() { cameraNames = nil }()
+
+	cam := CameraInfo{Path: "/dev/video0", Name: "USB 2.0 Camera", ID: "by-id:usb-cam"}
+	if err := saveCameraName(cam, "Spindle"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name follows the ID to another device node
+	cameraNames = nil
+	devices := []CameraInfo{
+		{Path: "/dev/video2", Name: "USB 2.0 Camera", ID: "by-id:usb-cam"},
+		{Path: "/dev/video0", Name: "Other Camera", ID: "by-id:usb-other"},
+	}
+	applyCameraNames(devices)
+	if devices[0].Name != "Spindle" || devices[1].Name != "Other Camera" {
+		t.Errorf("cameras are named %q and %q", devices[0].Name, devices[1].Name)
+	}
+}
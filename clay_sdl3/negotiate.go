@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// formatPreference is the order formats are tried in when a V4L2 camera is
+// opened, set with -format-preference. Compressed formats come first as
+// they leave the most USB bandwidth for other cameras.
+var formatPreference = []v4l2.FourCCType{
+	v4l2.PixelFmtMJPEG,
+	v4l2.PixelFmtJPEG,
+	v4l2.PixelFmtYUYV,
+	fourcc("NV12"),
+	v4l2.PixelFmtGrey,
+	fourcc("Y16 "),
+	fourcc("Y12 "),
+	fourcc("Y10 "),
+}
+
+// requestedWidth and requestedHeight are the frame size V4L2 cameras are
+// opened with, set with -resolution
+var requestedWidth, requestedHeight uint32 = 640, 480
+
+// setFormatPreference sets the formats to try, as a comma-separated list
+func setFormatPreference(list string) error {
+	var formats []v4l2.FourCCType
+	for _, name := range strings.Split(list, ",") {
+		format, err := lookupPixelFormat(name)
+		if err != nil {
+			return err
+		}
+		formats = append(formats, format)
+	}
+	formatPreference = formats
+	return nil
+}
+
+// setPixelFormat forces the format V4L2 cameras are opened with; "auto"
+// keeps the preference list
+func setPixelFormat(name string) error {
+	if strings.EqualFold(name, "auto") {
+		return nil
+	}
+	format, err := lookupPixelFormat(name)
+	if err != nil {
+		return err
+	}
+	formatPreference = []v4l2.FourCCType{format}
+	return nil
+}
+
+// setResolution sets the frame size to request, given as "WIDTHxHEIGHT"
+func setResolution(size string) error {
+	w, h, ok := strings.Cut(strings.ToLower(size), "x")
+	width, errW := strconv.ParseUint(w, 10, 32)
+	height, errH := strconv.ParseUint(h, 10, 32)
+	if !ok || errW != nil || errH != nil || width == 0 || height == 0 {
+		return fmt.Errorf("invalid resolution %q, expected WIDTHxHEIGHT", size)
+	}
+	requestedWidth, requestedHeight = uint32(width), uint32(height)
+	return nil
+}
+
+// frameSizeDistance measures how far a supported frame size is from the
+// requested one, 0 for a match. Stepwise sizes are clamped to their range.
+func frameSizeDistance(size v4l2.FrameSizeEnum, width, height uint32) (uint32, uint32, int) {
+	w := min(max(width, size.Size.MinWidth), size.Size.MaxWidth)
+	h := min(max(height, size.Size.MinHeight), size.Size.MaxHeight)
+	if step := size.Size.StepWidth; size.Type != v4l2.FrameSizeTypeDiscrete && step > 1 {
+		w -= (w - size.Size.MinWidth) % step
+	}
+	if step := size.Size.StepHeight; size.Type != v4l2.FrameSizeTypeDiscrete && step > 1 {
+		h -= (h - size.Size.MinHeight) % step
+	}
+	distance := int(w)*int(h) - int(width)*int(height)
+	if distance < 0 {
+		distance = -distance
+	}
+	if w != width || h != height {
+		// A different size of the same area is still a mismatch
+		distance++
+	}
+	return w, h, distance
+}
+
+// negotiateFormat picks the format and frame size of an open device: the
+// first format of the preference list the device offers at the requested
+// size, or failing that the preferred format at its closest size. The mode
+// is applied by startDeviceStream. A cropped device is asked for the size of its crop as is, since the sizes
+// it lists are those of its full-sensor modes.
+func negotiateFormat(dev videoDevice, name string, width, height uint32, cropped bool) (v4l2.PixFormat, error) {
+	descriptions, err := dev.GetFormatDescriptions()
+	offered := make(map[v4l2.FourCCType]bool)
+	var offeredNames []string
+	for _, description := range descriptions {
+		offered[description.PixelFormat] = true
+		offeredNames = append(offeredNames, formatName(description.PixelFormat))
+	}
+	if err != nil && len(descriptions) == 0 {
+		// Some drivers cannot enumerate; let the driver adjust the first
+		// preference instead
+		log.Printf("Camera %s: cannot list formats: %v", name, err)
+		for _, format := range formatPreference {
+			offered[format] = true
+		}
+	}
+
+	var chosen v4l2.PixFormat
+	found := false
+	for _, format := range formatPreference {
+		if !offered[format] {
+			continue
+		}
+		w, h, distance := width, height, 0
+		if sizes, err := dev.FrameSizes(format); err == nil && len(sizes) > 0 && !cropped {
+			distance = -1
+			for _, size := range sizes {
+				sw, sh, d := frameSizeDistance(size, width, height)
+				if distance < 0 || d < distance {
+					w, h, distance = sw, sh, d
+				}
+			}
+		}
+		if !found || distance == 0 {
+			chosen = v4l2.PixFormat{Width: w, Height: h, PixelFormat: format, Field: v4l2.FieldNone}
+			found = true
+		}
+		if distance == 0 {
+			break
+		}
+	}
+	if !found {
+		var wanted []string
+		for _, format := range formatPreference {
+			wanted = append(wanted, formatName(format))
+		}
+		return v4l2.PixFormat{}, fmt.Errorf("none of the formats %s is offered, the device has %s",
+			strings.Join(wanted, ", "), strings.Join(offeredNames, ", "))
+	}
+
+	log.Printf("Camera %s: chose %s %dx%d (requested %dx%d, device offers %s)", name,
+		formatName(chosen.PixelFormat), chosen.Width, chosen.Height,
+		width, height, strings.Join(offeredNames, ", "))
+	return chosen, nil
+}
+
+// maxFallbackModes bounds how many smaller modes are tried before a camera
+// counts as failed
+const maxFallbackModes = 12
+
+// deviceMode is a frame size and rate; FPS 0 leaves the rate to the driver
+type deviceMode struct {
+	Width, Height, FPS uint32
+}
+
+// modeRates lists the frame rates a device offers for a format at a size,
+// fastest first
+func modeRates(dev videoDevice, format v4l2.FourCCType, width, height uint32) []uint32 {
+	var rates []uint32
+	for index := uint32(0); ; index++ {
+		interval, err := dev.FrameInterval(index, format, width, height)
+		if err != nil || interval.Interval.Min.Numerator == 0 {
+			break
+		}
+		if interval.Type != v4l2.FrameIntervalTypeDiscrete {
+			// A range: try its fastest end and the slowest
+			fast := interval.Interval.Min.Denominator / interval.Interval.Min.Numerator
+			rates = append(rates, fast)
+			if interval.Interval.Max.Numerator > 0 {
+				if slow := interval.Interval.Max.Denominator / interval.Interval.Max.Numerator; slow > 0 && slow < fast {
+					rates = append(rates, slow)
+				}
+			}
+			break
+		}
+		rates = append(rates, interval.Interval.Min.Denominator/interval.Interval.Min.Numerator)
+	}
+	slices.Sort(rates)
+	slices.Reverse(rates)
+	return slices.Compact(rates)
+}
+
+// fallbackModes lists the modes to step down through when a device refuses
+// a mode: the mode itself at lower frame rates, then its smaller sizes,
+// largest first, each at its frame rates, fastest first
+func fallbackModes(dev videoDevice, mode v4l2.PixFormat) []deviceMode {
+	var sizes []deviceMode
+	if enumerated, err := dev.FrameSizes(mode.PixelFormat); err == nil && len(enumerated) > 0 &&
+		enumerated[0].Type == v4l2.FrameSizeTypeDiscrete {
+		for _, size := range enumerated {
+			if size.Size.MaxWidth*size.Size.MaxHeight <= mode.Width*mode.Height {
+				sizes = append(sizes, deviceMode{Width: size.Size.MaxWidth, Height: size.Size.MaxHeight})
+			}
+		}
+	} else {
+		// Stepwise or unknown sizes: halve until the frame gets too small
+		for w, h := mode.Width, mode.Height; w >= 160 && h >= 120; w, h = w/2&^7, h/2&^7 {
+			sizes = append(sizes, deviceMode{Width: w, Height: h})
+		}
+	}
+	slices.SortStableFunc(sizes, func(a, b deviceMode) int {
+		return int(b.Width*b.Height) - int(a.Width*a.Height)
+	})
+
+	var modes []deviceMode
+	for _, size := range slices.Compact(sizes) {
+		rates := modeRates(dev, mode.PixelFormat, size.Width, size.Height)
+		if len(rates) == 0 {
+			rates = []uint32{0}
+		}
+		for _, fps := range rates {
+			modes = append(modes, deviceMode{Width: size.Width, Height: size.Height, FPS: fps})
+		}
+	}
+	return modes[:min(len(modes), maxFallbackModes)]
+}
+
+// startDeviceStream applies a mode and starts streaming. Devices often
+// refuse a mode only once they stream, typically when several cameras share
+// the USB bandwidth, so while they refuse, smaller modes and lower frame
+// rates from their own lists are tried. The mode the device streams at ends
+// up in the camera's PixFormat and Mode.
+func startDeviceStream(ctx context.Context, camera *CameraInstance, mode v4l2.PixFormat) (<-chan []byte, error) {
+	dev := camera.Device
+	first := deviceMode{Width: mode.Width, Height: mode.Height}
+	first.FPS = captureRate(dev, first, mode.PixelFormat)
+	attempts := append([]deviceMode{first}, fallbackModes(dev, mode)...)
+	var firstErr error
+	for i, attempt := range attempts {
+		// Falling back never goes faster than the rate asked for
+		if i > 0 && (attempt == first || first.FPS > 0 && attempt.FPS > first.FPS) {
+			continue
+		}
+		format := v4l2.PixFormat{Width: attempt.Width, Height: attempt.Height, PixelFormat: mode.PixelFormat, Field: v4l2.FieldNone}
+		err := dev.SetPixFormat(format)
+		if err == nil && attempt.FPS > 0 {
+			err = dev.SetFrameRate(attempt.FPS)
+		}
+		var frames <-chan []byte
+		if err == nil {
+			frames, err = dev.Stream(ctx, camera)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			log.Printf("Camera %s: %s %dx%d refused: %v", camera.Info.Name, formatName(mode.PixelFormat), attempt.Width, attempt.Height, err)
+			continue
+		}
+
+		// The driver may have adjusted the mode; use what it settled on
+		actual, err := dev.GetPixFormat()
+		if err != nil {
+			actual = format
+		}
+		camera.PixFormat = actual
+		camera.Mode = fmt.Sprintf("%s %dx%d", formatName(actual.PixelFormat), actual.Width, actual.Height)
+		camera.StreamFPS = 0
+		if param, err := dev.GetStreamParam(); err == nil && param.Capture.TimePerFrame.Numerator > 0 {
+			camera.StreamFPS = param.Capture.TimePerFrame.Denominator / param.Capture.TimePerFrame.Numerator
+			camera.Mode += fmt.Sprintf(" @ %d fps", camera.StreamFPS)
+		}
+		if i > 0 {
+			camera.Mode = tr("mode.downgraded", camera.Mode, mode.Width, mode.Height)
+		}
+		log.Printf("Camera %s: streaming %s", camera.Info.Name, camera.Mode)
+		if _, ok := yuvFormats[actual.PixelFormat]; ok {
+			log.Printf("Camera %s: YUV in %s", camera.Info.Name, yuvColorSpace(actual))
+		}
+		return frames, nil
+	}
+	return nil, fmt.Errorf("failed to start camera: %w", firstErr)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// The offset protocol publishes the measurement results over TCP or a serial
+// port so a CNC sender can turn them into work offsets. It is line based and
+// ASCII, one feature per line:
+//
+//	CIRCLE X=+0.1250 Y=-0.0400 D=6.0000 U=MM
+//	LINE H A=+0.250 O=-0.1500 U=MM
+//	CIRCLE NONE
+//
+// X and Y are the offset of the feature from the crosshair with Y pointing up,
+// as on a machine table; A is the line's tilt in degrees (counterclockwise
+// positive) and O its distance from the crosshair, up for horizontal (H) and
+// right for vertical (V) lines. U is MM for calibrated cameras, PX otherwise.
+//
+// A client sends "?" to get the current results followed by "OK" ("NONE" when
+// no measurement tool is enabled), "WATCH" to receive every new result as it
+// is measured, and "UNWATCH" to stop.
+
+// offsetWatchQueue is how many lines a watching client may fall behind before
+// lines are dropped for it
+const offsetWatchQueue = 16
+
+// offsetClient is a connected TCP client or the serial port
+type offsetClient struct {
+	watch chan string
+}
+
+// OffsetPublisher serves the latest measurement results to its clients
+type OffsetPublisher struct {
+	mu      sync.Mutex
+	lines   []string
+	clients map[*offsetClient]bool
+}
+
+var offsetPublisher = &OffsetPublisher{clients: make(map[*offsetClient]bool)}
+
+// listenOffsets serves the offset protocol on a TCP address
+func listenOffsets(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for offset clients: %w", err)
+	}
+	log.Printf("Publishing measurement offsets on %s", listener.Addr())
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Printf("Offset listener stopped: %v", err)
+				return
+			}
+			go offsetPublisher.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// openOffsetSerial serves the offset protocol on a serial port, given as
+// "/dev/ttyUSB0" or "/dev/ttyUSB0:115200"
+func openOffsetSerial(spec string) error {
+	path, baud := spec, 115200
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		rate, err := strconv.Atoi(spec[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid serial baud rate %q", spec[i+1:])
+		}
+		path, baud = spec[:i], rate
+	}
+	port, err := openSerial(path, baud)
+	if err != nil {
+		return err
+	}
+	log.Printf("Publishing measurement offsets on %s at %d baud", path, baud)
+	go offsetPublisher.serve(port)
+	return nil
+}
+
+// serialSpeeds maps baud rates to their termios speeds
+var serialSpeeds = map[int]uint32{
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+// openSerial opens a serial port in raw 8N1 mode
+func openSerial(path string, baud int) (*os.File, error) {
+	speed, ok := serialSpeeds[baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported serial baud rate %d", baud)
+	}
+	port, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	fd := int(port.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		port.Close()
+		return nil, fmt.Errorf("%s is not a serial port: %w", path, err)
+	}
+	// Equivalent of cfmakeraw, then 8N1 at the requested speed
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB | unix.CBAUD
+	termios.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | speed
+	termios.Ispeed = speed
+	termios.Ospeed = speed
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		port.Close()
+		return nil, fmt.Errorf("failed to configure %s: %w", path, err)
+	}
+	return port, nil
+}
+
+// serve answers a client's requests until it disconnects
+func (p *OffsetPublisher) serve(conn io.ReadWriteCloser) {
+	client := &offsetClient{watch: make(chan string, offsetWatchQueue)}
+	done := make(chan struct{})
+	defer func() {
+		p.setWatching(client, false)
+		close(done)
+		conn.Close()
+	}()
+
+	// Writes come from both the requests and the watch queue
+	var writeMu sync.Mutex
+	write := func(lines ...string) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		for _, line := range lines {
+			if _, err := io.WriteString(conn, line+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	go func() {
+		for {
+			select {
+			case line := <-client.watch:
+				if write(line) != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var err error
+		switch strings.ToUpper(strings.TrimSpace(scanner.Text())) {
+		case "":
+			continue
+		case "?":
+			err = write(append(p.latest(), "OK")...)
+		case "WATCH":
+			p.setWatching(client, true)
+			err = write("OK")
+		case "UNWATCH":
+			p.setWatching(client, false)
+			err = write("OK")
+		default:
+			err = write("ERROR unknown command")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// setWatching adds or removes a client from the watchers
+func (p *OffsetPublisher) setWatching(client *offsetClient, watching bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if watching {
+		p.clients[client] = true
+	} else {
+		delete(p.clients, client)
+	}
+}
+
+// latest returns the lines of the last measurement
+func (p *OffsetPublisher) latest() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.lines) == 0 {
+		return []string{"NONE"}
+	}
+	return append([]string(nil), p.lines...)
+}
+
+// Publish stores the results of a measurement and sends them to the watching
+// clients
+func (p *OffsetPublisher) Publish(lines []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines = lines
+	for client := range p.clients {
+		for _, line := range lines {
+			select {
+			case client.watch <- line:
+			default:
+				// The client is not keeping up; it gets the next result
+			}
+		}
+	}
+}
+
+// publishOffsets sends the selected camera's measurements to the offset
+// clients
+func publishOffsets(camera *CameraInstance) {
+	unit, scale := "PX", float32(1)
+	if camera.PixelsPerMM > 0 {
+		unit, scale = "MM", 1/camera.PixelsPerMM
+	}
+
+	var lines []string
+	if lineFinder.Enabled {
+		if fit := lineFinder.Result; fit.Found {
+			// Frame Y points down; the protocol's points up, which mirrors
+			// horizontal lines
+			orientation, angle, offset := "H", -fit.AngleDeg, -fit.Offset
+			if fit.Vertical {
+				orientation, angle, offset = "V", fit.AngleDeg, fit.Offset
+			}
+			lines = append(lines, fmt.Sprintf("LINE %s A=%+.3f O=%+.4f U=%s", orientation, angle, offset*scale, unit))
+		} else {
+			lines = append(lines, "LINE NONE")
+		}
+	}
+	if circleFinder.Enabled {
+		if fit := circleFinder.Result; fit.Found {
+			lines = append(lines, fmt.Sprintf("CIRCLE X=%+.4f Y=%+.4f D=%.4f U=%s", fit.DX*scale, -fit.DY*scale, 2*fit.Radius*scale, unit))
+		} else {
+			lines = append(lines, "CIRCLE NONE")
+		}
+	}
+	offsetPublisher.Publish(lines)
+}
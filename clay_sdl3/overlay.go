@@ -0,0 +1,150 @@
+package main
+
+import "github.com/Zyko0/go-sdl3/sdl"
+
+type gridMode int
+
+const (
+	gridOff gridMode = iota
+	// gridPixels spaces the grid in frame pixels
+	gridPixels
+	// gridMillimeters spaces the grid in mm, for calibrated cameras
+	gridMillimeters
+)
+
+// safeAreas are the action-safe and title-safe rectangles, as fractions of
+// the frame
+var safeAreas = []float32{0.9, 0.8}
+
+// minGridGap is the smallest on-screen line spacing drawn, so a fine grid
+// does not turn into a solid fill
+const minGridGap = 4
+
+// Overlays are drawn over the main view to help with framing and repeatable
+// part placement. The grid is centered on the frame, so one line pair always
+// runs through the center mark.
+type Overlays struct {
+	Grid       gridMode `json:"grid"`
+	GridPixels float32  `json:"grid_px"`
+	GridMM     float32  `json:"grid_mm"`
+	CenterMark bool     `json:"center_mark"`
+	SafeAreas  bool     `json:"safe_areas"`
+}
+
+var overlays = Overlays{GridPixels: 50, GridMM: 1}
+
+// cycleGrid switches between no grid, a pixel grid and, if the selected
+// camera is calibrated, a mm grid
+func cycleGrid(appData *CameraAppData) {
+	overlays.Grid = (overlays.Grid + 1) % 3
+	if overlays.Grid == gridMillimeters && selectedPixelsPerMM(appData) <= 0 {
+		overlays.Grid = gridOff
+	}
+	switch overlays.Grid {
+	case gridOff:
+		appData.StatusText = tr("status.grid_off")
+	case gridPixels:
+		appData.StatusText = tr("status.grid_px", overlays.GridPixels)
+	case gridMillimeters:
+		appData.StatusText = tr("status.grid_mm", overlays.GridMM)
+	}
+}
+
+// scaleGrid doubles or halves the spacing of the current grid
+func scaleGrid(appData *CameraAppData, factor float32) {
+	switch overlays.Grid {
+	case gridPixels:
+		overlays.GridPixels = min(max(overlays.GridPixels*factor, 5), 1000)
+		appData.StatusText = tr("status.grid_px", overlays.GridPixels)
+	case gridMillimeters:
+		overlays.GridMM = min(max(overlays.GridMM*factor, 0.05), 500)
+		appData.StatusText = tr("status.grid_mm", overlays.GridMM)
+	}
+}
+
+// selectedPixelsPerMM returns the scale of the selected camera, or 0 if it
+// is not calibrated
+func selectedPixelsPerMM(appData *CameraAppData) float32 {
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return 0
+	}
+	return appData.Cameras[appData.SelectedCamera].PixelsPerMM
+}
+
+// renderOverlays draws the enabled overlays over the main view
+func renderOverlays(appData *CameraAppData) {
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	rect, ok := mainCameraRect()
+	if !ok || camera.Width <= 0 || camera.Height <= 0 || !camera.Active || camera.Disabled || mosaicShown(appData) {
+		return
+	}
+	renderer := appData.Renderer
+
+	// Zoomed-in overlays extend past the view
+	clipRect := sdl.Rect{X: int32(rect.X), Y: int32(rect.Y), W: int32(rect.W), H: int32(rect.H)}
+	_ = renderer.SetClipRect(&clipRect)
+	defer renderer.SetClipRect(nil)
+
+	spacing := float32(0)
+	switch overlays.Grid {
+	case gridPixels:
+		spacing = overlays.GridPixels
+	case gridMillimeters:
+		spacing = overlays.GridMM * camera.PixelsPerMM
+	}
+	if spacing > 0 {
+		_ = renderer.SetDrawColor(255, 255, 255, 90)
+		renderGridLines(renderer, rect, spacing/float32(camera.Width), true)
+		renderGridLines(renderer, rect, spacing/float32(camera.Height), false)
+	}
+
+	if overlays.SafeAreas {
+		_ = renderer.SetDrawColor(255, 200, 0, 160)
+		for _, size := range safeAreas {
+			margin := (1 - size) / 2
+			x0, y0 := frameToView(rect, margin, margin)
+			x1, y1 := frameToView(rect, 1-margin, 1-margin)
+			_ = renderer.RenderRect(&sdl.FRect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0})
+		}
+	}
+
+	// Measurements are taken from the center mark, so it is shown with them
+	if overlays.CenterMark || measuring() {
+		cx, cy := frameToView(rect, 0.5, 0.5)
+		arm := min(rect.W, rect.H) * 0.05
+		_ = renderer.SetDrawColor(uint8(theme.Accent.R), uint8(theme.Accent.G), uint8(theme.Accent.B), 255)
+		_ = renderer.RenderLine(cx-arm, cy, cx+arm, cy)
+		_ = renderer.RenderLine(cx, cy-arm, cx, cy+arm)
+		_ = renderer.RenderRect(&sdl.FRect{X: cx - arm/4, Y: cy - arm/4, W: arm / 2, H: arm / 2})
+	}
+}
+
+// renderGridLines draws vertical (or horizontal) grid lines every step, in
+// normalized frame coordinates, outwards from the frame center
+func renderGridLines(renderer *sdl.Renderer, rect sdl.FRect, step float32, vertical bool) {
+	size := rect.H
+	if vertical {
+		size = rect.W
+	}
+	if step*size*max(touchMode.Zoom, 1) < minGridGap {
+		return
+	}
+
+	line := func(pos float32) {
+		if vertical {
+			x, _ := frameToView(rect, pos, 0)
+			_ = renderer.RenderLine(x, rect.Y, x, rect.Y+rect.H)
+		} else {
+			_, y := frameToView(rect, 0, pos)
+			_ = renderer.RenderLine(rect.X, y, rect.X+rect.W, y)
+		}
+	}
+	line(0.5)
+	for offset := step; offset <= 0.5; offset += step {
+		line(0.5 - offset)
+		line(0.5 + offset)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Frame pacing decides how fast the main loop draws, apart from how fast the
+// cameras deliver: the loop takes every queued frame on each pass and shows
+// the newest, so a UI drawn slower than a camera neither lags behind it nor
+// loses frames from recordings. With vsync the loop waits for the display; a
+// UI frame rate caps it below that, and while the window is hidden the loop
+// only composes mosaics, recordings and API streams at the headless rate.
+
+// vsyncAdaptive is SDL_RENDERER_VSYNC_ADAPTIVE, which waits for vsync
+// unless a frame is late
+const vsyncAdaptive = -1
+
+// vsyncModes maps -vsync to SDL vsync intervals
+var vsyncModes = map[string]int32{
+	"off":      0,
+	"on":       1,
+	"adaptive": vsyncAdaptive,
+}
+
+// FramePacing is how the main loop paces itself
+type FramePacing struct {
+	// VSync is the SDL vsync interval of the main window
+	VSync int32
+	// UIFPS caps the frames drawn per second, 0 for no cap
+	UIFPS float64
+	// HeadlessFPS is how often the loop runs while the window is hidden
+	HeadlessFPS float64
+
+	// next is when the next pass of the loop is due
+	next time.Time
+}
+
+// pacing is set up with -vsync, -ui-fps and -headless-fps
+var pacing = FramePacing{VSync: 1, HeadlessFPS: 100}
+
+// configurePacing sets the frame pacing from the command line
+func configurePacing(vsync string, uiFPS, headlessFPS float64) error {
+	mode, ok := vsyncModes[vsync]
+	if !ok {
+		return fmt.Errorf("unknown vsync mode %q (on, off or adaptive)", vsync)
+	}
+	if uiFPS < 0 {
+		return fmt.Errorf("UI frame rate %g is negative", uiFPS)
+	}
+	if headlessFPS <= 0 {
+		return fmt.Errorf("headless frame rate %g is not positive", headlessFPS)
+	}
+	pacing.VSync = mode
+	pacing.UIFPS = uiFPS
+	pacing.HeadlessFPS = headlessFPS
+	return nil
+}
+
+// applyVSync sets the main window's vsync, falling back from adaptive to
+// plain vsync on drivers without it
+func applyVSync(renderer *sdl.Renderer) {
+	err := renderer.SetVSync(pacing.VSync)
+	if err != nil && pacing.VSync == vsyncAdaptive {
+		log.Printf("Adaptive vsync not supported, using vsync: %v", err)
+		pacing.VSync = 1
+		err = renderer.SetVSync(1)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to set vsync: %v", err)
+	}
+}
+
+// interval is the time between passes of the loop, 0 if the loop is not
+// paced by sleeping
+func (p *FramePacing) interval(hidden bool) time.Duration {
+	fps := p.UIFPS
+	if hidden {
+		fps = p.HeadlessFPS
+	}
+	if fps <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / fps)
+}
+
+// wait sleeps until the next pass of the loop is due. Passes keep a steady
+// rhythm instead of sleeping a fixed time after work that varies; a loop
+// that fell behind starts over rather than rushing to catch up.
+func (p *FramePacing) wait(hidden bool) {
+	interval := p.interval(hidden)
+	if interval <= 0 {
+		p.next = time.Time{}
+		return
+	}
+	now := time.Now()
+	if p.next.IsZero() || now.Sub(p.next) > interval {
+		p.next = now
+	}
+	p.next = p.next.Add(interval)
+	time.Sleep(time.Until(p.next))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTakeFramesDrainsQueue(t *testing.T) {
+	appData := &CameraAppData{Cameras: []CameraInstance{{
+		Info:      CameraInfo{Name: "paced", Path: "/dev/paced"},
+		Active:    true,
+		FrameChan: make(chan Frame, 4),
+	}}}
+	camera := &appData.Cameras[0]
+	for i := range 3 {
+		camera.FrameChan <- Frame{Data: []byte{byte(i)}}
+	}
+
+	frame, ok := takeFrames(appData, 0)
+	if !ok || frame.Data[0] != 2 {
+		t.Fatalf("took %v, %t; want the newest frame", frame.Data, ok)
+	}
+	if len(camera.FrameChan) != 0 {
+		t.Errorf("%d frames left queued", len(camera.FrameChan))
+	}
+	if _, ok := takeFrames(appData, 0); ok {
+		t.Error("took a frame from an empty queue")
+	}
+}
+
+func TestFramePacingInterval(t *testing.T) {
+	p := FramePacing{UIFPS: 100, HeadlessFPS: 50}
+	start := time.Now()
+	for range 5 {
+		p.wait(false)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("5 passes at 100 FPS took %s", elapsed)
+	}
+	if got := p.interval(true); got != 20*time.Millisecond {
+		t.Errorf("headless interval %s, want 20ms", got)
+	}
+	if got := (&FramePacing{}).interval(false); got != 0 {
+		t.Errorf("uncapped interval %s, want 0", got)
+	}
+}
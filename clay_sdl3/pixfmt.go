@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// fourcc builds a V4L2 pixel format code from its four characters
+func fourcc(code string) v4l2.FourCCType {
+	return v4l2.FourCCType(code[0]) | v4l2.FourCCType(code[1])<<8 | v4l2.FourCCType(code[2])<<16 | v4l2.FourCCType(code[3])<<24
+}
+
+// bayerFormat describes a raw Bayer format: the color filter pattern of the
+// top-left 2x2 block, the bits per sample and whether the samples are packed
+// (four 10-bit samples in five bytes, as MIPI CSI-2 sensors deliver them)
+type bayerFormat struct {
+	Pattern string
+	Bits    int
+	Packed  bool
+}
+
+// bayerFormats are the raw Bayer formats the app can demosaic
+var bayerFormats = map[v4l2.FourCCType]bayerFormat{
+	fourcc("RGGB"): {Pattern: "RGGB", Bits: 8},
+	fourcc("BA81"): {Pattern: "BGGR", Bits: 8},
+	fourcc("GBRG"): {Pattern: "GBRG", Bits: 8},
+	fourcc("GRBG"): {Pattern: "GRBG", Bits: 8},
+	fourcc("RG10"): {Pattern: "RGGB", Bits: 10},
+	fourcc("BG10"): {Pattern: "BGGR", Bits: 10},
+	fourcc("GB10"): {Pattern: "GBRG", Bits: 10},
+	fourcc("BA10"): {Pattern: "GRBG", Bits: 10},
+	fourcc("pRAA"): {Pattern: "RGGB", Bits: 10, Packed: true},
+	fourcc("pBAA"): {Pattern: "BGGR", Bits: 10, Packed: true},
+	fourcc("pGAA"): {Pattern: "GBRG", Bits: 10, Packed: true},
+	fourcc("pgAA"): {Pattern: "GRBG", Bits: 10, Packed: true},
+}
+
+// greyFormats are the greyscale formats of IR and thermal cameras, with the
+// bits per sample. Deeper samples come as 16-bit little-endian words.
+var greyFormats = map[v4l2.FourCCType]int{
+	v4l2.PixelFmtGrey: 8,
+	fourcc("Y10 "):    10,
+	fourcc("Y12 "):    12,
+	fourcc("Y16 "):    16,
+}
+
+// yuvFormats are the uncompressed YUV formats of webcams and capture cards,
+// with their chroma subsampling
+var yuvFormats = map[v4l2.FourCCType]image.YCbCrSubsampleRatio{
+	v4l2.PixelFmtYUYV: image.YCbCrSubsampleRatio422,
+	fourcc("NV12"):    image.YCbCrSubsampleRatio420,
+}
+
+// pixelFormatNames are the formats that can be named with -pixel-format and
+// -format-preference
+var pixelFormatNames = map[string]v4l2.FourCCType{
+	"mjpeg":    v4l2.PixelFmtMJPEG,
+	"jpeg":     v4l2.PixelFmtJPEG,
+	"yuyv":     v4l2.PixelFmtYUYV,
+	"nv12":     fourcc("NV12"),
+	"srggb8":   fourcc("RGGB"),
+	"sbggr8":   fourcc("BA81"),
+	"sgbrg8":   fourcc("GBRG"),
+	"sgrbg8":   fourcc("GRBG"),
+	"srggb10":  fourcc("RG10"),
+	"sbggr10":  fourcc("BG10"),
+	"sgbrg10":  fourcc("GB10"),
+	"sgrbg10":  fourcc("BA10"),
+	"srggb10p": fourcc("pRAA"),
+	"sbggr10p": fourcc("pBAA"),
+	"sgbrg10p": fourcc("pGAA"),
+	"sgrbg10p": fourcc("pgAA"),
+	"grey":     v4l2.PixelFmtGrey,
+	"y10":      fourcc("Y10 "),
+	"y12":      fourcc("Y12 "),
+	"y16":      fourcc("Y16 "),
+}
+
+// lookupPixelFormat finds a format by its -pixel-format name
+func lookupPixelFormat(name string) (v4l2.FourCCType, error) {
+	format, ok := pixelFormatNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		names := make([]string, 0, len(pixelFormatNames))
+		for name := range pixelFormatNames {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return 0, fmt.Errorf("unknown pixel format %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return format, nil
+}
+
+// formatSupported reports whether frames of a format can be decoded
+func formatSupported(format v4l2.FourCCType) bool {
+	_, bayer := bayerFormats[format]
+	_, grey := greyFormats[format]
+	_, yuv := yuvFormats[format]
+	return bayer || grey || yuv || format == v4l2.PixelFmtMJPEG || format == v4l2.PixelFmtJPEG
+}
+
+// formatName returns a format's four characters, for logs
+func formatName(format v4l2.FourCCType) string {
+	return strings.TrimSpace(string([]byte{byte(format), byte(format >> 8), byte(format >> 16), byte(format >> 24)}))
+}
+
+// SensorFrame is a frame as the device delivered it, for formats that are
+// not JPEG, kept so snapshots can save the unprocessed data
+type SensorFrame struct {
+	Data   []byte
+	Format v4l2.PixFormat
+}
+
+// deviceFrame turns the data of a V4L2 buffer into a frame. JPEG frames are
+// passed on; raw formats are converted to an image here, in the capture
+// goroutine, so the main loop does not pay for it.
+func deviceFrame(camera *CameraInstance, data []byte) (Frame, error) {
+	format := camera.PixFormat
+	var img image.Image
+	var err error
+	if bits, ok := greyFormats[format.PixelFormat]; ok {
+		img, err = greyImage(data, format, bits)
+	} else if bayer, ok := bayerFormats[format.PixelFormat]; ok {
+		img, err = demosaic(data, format, bayer)
+	} else if ratio, ok := yuvFormats[format.PixelFormat]; ok {
+		// YUV frames are 8-bit, the image holds all there is to save
+		img, err := yuvImage(data, format, ratio)
+		if err != nil {
+			return Frame{}, err
+		}
+		normalizeYCbCr(img.(*image.YCbCr), yuvColorSpace(format))
+		return Frame{Raw: img}, nil
+	} else {
+		return Frame{Data: data}, nil
+	}
+	if err != nil {
+		return Frame{}, err
+	}
+	return Frame{Raw: img, Sensor: &SensorFrame{Data: data, Format: format}}, nil
+}
+
+// bayerSamples unpacks the samples of a Bayer frame, one per pixel
+func bayerSamples(data []byte, format v4l2.PixFormat, bayer bayerFormat) ([]uint16, error) {
+	width, height := int(format.Width), int(format.Height)
+	stride := int(format.BytesPerLine)
+	rowBytes := width
+	switch {
+	case bayer.Packed:
+		rowBytes = width * 5 / 4
+	case bayer.Bits > 8:
+		rowBytes = width * 2
+	}
+	stride = max(stride, rowBytes)
+	if len(data) < stride*(height-1)+rowBytes {
+		return nil, fmt.Errorf("short raw frame: %d bytes for %dx%d", len(data), width, height)
+	}
+
+	samples := make([]uint16, width*height)
+	for y := range height {
+		row := data[y*stride:]
+		out := samples[y*width : (y+1)*width]
+		switch {
+		case bayer.Packed:
+			// Four high bytes, then one byte with the low two bits of each
+			for x := 0; x+3 < width; x += 4 {
+				block := row[x/4*5:]
+				low := block[4]
+				for i := range 4 {
+					out[x+i] = uint16(block[i])<<2 | uint16(low>>(2*i)&3)
+				}
+			}
+		case bayer.Bits > 8:
+			for x := range width {
+				out[x] = binary.LittleEndian.Uint16(row[2*x:])
+			}
+		default:
+			for x := range width {
+				out[x] = uint16(row[x])
+			}
+		}
+	}
+	return samples, nil
+}
+
+// demosaic converts a Bayer frame to color by bilinear interpolation: each
+// missing color of a pixel is the average of the neighbors that have it.
+// 8-bit frames become RGBA images, deeper ones RGBA64 so the extra bits
+// survive into lossless snapshots.
+func demosaic(data []byte, format v4l2.PixFormat, bayer bayerFormat) (image.Image, error) {
+	samples, err := bayerSamples(data, format, bayer)
+	if err != nil {
+		return nil, err
+	}
+	width, height := int(format.Width), int(format.Height)
+
+	// channel of each position in the 2x2 pattern: 0 red, 1 green, 2 blue
+	var channel [4]int
+	for i, c := range bayer.Pattern {
+		channel[i] = strings.IndexRune("RGB", c)
+	}
+
+	var rgba *image.RGBA
+	var rgba64 *image.RGBA64
+	if bayer.Bits <= 8 {
+		rgba = image.NewRGBA(image.Rect(0, 0, width, height))
+	} else {
+		rgba64 = image.NewRGBA64(image.Rect(0, 0, width, height))
+	}
+	shift := 16 - bayer.Bits
+
+	for y := range height {
+		for x := range width {
+			var sum [3]int
+			var count [3]int
+			for dy := -1; dy <= 1; dy++ {
+				sy := y + dy
+				if sy < 0 || sy >= height {
+					continue
+				}
+				for dx := -1; dx <= 1; dx++ {
+					sx := x + dx
+					if sx < 0 || sx >= width {
+						continue
+					}
+					c := channel[(sy&1)*2+(sx&1)]
+					sum[c] += int(samples[sy*width+sx])
+					count[c]++
+				}
+			}
+			own := channel[(y&1)*2+(x&1)]
+			var value [3]int
+			for c := range 3 {
+				if c == own {
+					value[c] = int(samples[y*width+x])
+				} else if count[c] > 0 {
+					value[c] = sum[c] / count[c]
+				}
+			}
+
+			if rgba != nil {
+				p := rgba.Pix[y*rgba.Stride+x*4:]
+				p[0], p[1], p[2], p[3] = uint8(value[0]), uint8(value[1]), uint8(value[2]), 0xff
+			} else {
+				p := rgba64.Pix[y*rgba64.Stride+x*8:]
+				for c := range 3 {
+					v := uint16(value[c]<<shift | value[c]>>(bayer.Bits-shift))
+					p[2*c], p[2*c+1] = uint8(v>>8), uint8(v)
+				}
+				p[6], p[7] = 0xff, 0xff
+			}
+		}
+	}
+	if rgba != nil {
+		return rgba, nil
+	}
+	return rgba64, nil
+}
+
+// greyImage converts a greyscale frame to an image: Gray for 8-bit samples,
+// Gray16 scaled to the full 16 bits for deeper ones
+func greyImage(data []byte, format v4l2.PixFormat, bits int) (image.Image, error) {
+	width, height := int(format.Width), int(format.Height)
+	rowBytes := width
+	if bits > 8 {
+		rowBytes = width * 2
+	}
+	stride := max(int(format.BytesPerLine), rowBytes)
+	if len(data) < stride*(height-1)+rowBytes {
+		return nil, fmt.Errorf("short greyscale frame: %d bytes for %dx%d", len(data), width, height)
+	}
+
+	if bits <= 8 {
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		for y := range height {
+			copy(gray.Pix[y*gray.Stride:(y+1)*gray.Stride], data[y*stride:])
+		}
+		return gray, nil
+	}
+	gray := image.NewGray16(image.Rect(0, 0, width, height))
+	shift := 16 - bits
+	for y := range height {
+		row := data[y*stride:]
+		out := gray.Pix[y*gray.Stride:]
+		for x := range width {
+			v := binary.LittleEndian.Uint16(row[2*x:])
+			if shift > 0 {
+				v = v<<shift | v>>(bits-shift)
+			}
+			out[2*x], out[2*x+1] = uint8(v>>8), uint8(v)
+		}
+	}
+	return gray, nil
+}
+
+// yuvImage converts a packed YUYV or semi-planar NV12 frame to planar YCbCr
+func yuvImage(data []byte, format v4l2.PixFormat, ratio image.YCbCrSubsampleRatio) (image.Image, error) {
+	width, height := int(format.Width), int(format.Height)
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), ratio)
+	switch ratio {
+	case image.YCbCrSubsampleRatio422:
+		stride := max(int(format.BytesPerLine), width*2)
+		if len(data) < stride*(height-1)+width*2 {
+			return nil, fmt.Errorf("short YUYV frame: %d bytes for %dx%d", len(data), width, height)
+		}
+		for y := range height {
+			row := data[y*stride:]
+			luma := img.Y[y*img.YStride:]
+			cb, cr := img.Cb[y*img.CStride:], img.Cr[y*img.CStride:]
+			for x := unpackYUYVBlocks(luma, cb, cr, row, width); x+1 < width; x += 2 {
+				p := row[x*2:]
+				luma[x], cb[x/2], luma[x+1], cr[x/2] = p[0], p[1], p[2], p[3]
+			}
+		}
+	default:
+		// NV12: the luma plane, then half-height rows of interleaved CbCr
+		stride := max(int(format.BytesPerLine), width)
+		chromaRows := (height + 1) / 2
+		if len(data) < stride*(height+chromaRows-1)+width {
+			return nil, fmt.Errorf("short NV12 frame: %d bytes for %dx%d", len(data), width, height)
+		}
+		for y := range height {
+			copy(img.Y[y*img.YStride:y*img.YStride+width], data[y*stride:])
+		}
+		chroma := data[stride*height:]
+		for y := range chromaRows {
+			row := chroma[y*stride:]
+			cb, cr := img.Cb[y*img.CStride:], img.Cr[y*img.CStride:]
+			for x := splitPairs(cb, cr, row, width/2) * 2; x+1 < width; x += 2 {
+				cb[x/2], cr[x/2] = row[x], row[x+1]
+			}
+		}
+	}
+	return img, nil
+}
+
+// toRGBA converts a decoded frame to 8-bit RGBA, with fast paths for the
+// images the sources produce. 16-bit greyscale frames are stretched to
+// their own range.
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	switch src := img.(type) {
+	case *image.RGBA:
+		dst := image.NewRGBA(bounds)
+		copy(dst.Pix, src.Pix)
+		return dst
+	case *image.RGBA64:
+		dst := image.NewRGBA(bounds)
+		for i := 0; i < len(dst.Pix); i++ {
+			dst.Pix[i] = src.Pix[2*i]
+		}
+		return dst
+	case *image.Gray:
+		dst := image.NewRGBA(bounds)
+		for i, v := range src.Pix {
+			p := dst.Pix[i*4:]
+			p[0], p[1], p[2], p[3] = v, v, v, 0xff
+		}
+		return dst
+	case *image.Gray16:
+		return stretchGrey16(src)
+	case *image.YCbCr:
+		// Off arm64 the standard library converts them, as it always did
+		if !neonYUV {
+			break
+		}
+		if dst := ycbcrToRGBA(src); dst != nil {
+			return dst
+		}
+	}
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Src)
+	return dst
+}
+
+// rawSnapshotInfo describes a raw snapshot for offline processing
+type rawSnapshotInfo struct {
+	Width        uint32 `json:"width"`
+	Height       uint32 `json:"height"`
+	PixelFormat  string `json:"pixel_format"`
+	Pattern      string `json:"bayer_pattern,omitempty"`
+	Bits         int    `json:"bits,omitempty"`
+	Packed       bool   `json:"packed,omitempty"`
+	BytesPerLine uint32 `json:"bytes_per_line"`
+}
+
+// writeRawSnapshot saves the unprocessed sensor data with a JSON file
+// describing its layout
+func writeRawSnapshot(path string, sensor *SensorFrame) error {
+	if err := writeOutputFile(path, sensor.Data); err != nil {
+		return err
+	}
+	code := sensor.Format.PixelFormat
+	info := rawSnapshotInfo{
+		Width:        sensor.Format.Width,
+		Height:       sensor.Format.Height,
+		PixelFormat:  string([]byte{byte(code), byte(code >> 8), byte(code >> 16), byte(code >> 24)}),
+		BytesPerLine: sensor.Format.BytesPerLine,
+	}
+	if bayer, ok := bayerFormats[code]; ok {
+		info.Pattern, info.Bits, info.Packed = bayer.Pattern, bayer.Bits, bayer.Packed
+	} else if bits, ok := greyFormats[code]; ok {
+		info.Bits = bits
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(strings.TrimSuffix(path, ".raw")+".json", data, 0o644)
+}
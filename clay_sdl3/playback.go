@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Recordings can be watched in the app: each -play file is shown as a
+// camera that plays the recording in a loop at the times its frames were
+// captured, read from the index sidecar. Encrypted recordings are decrypted
+// on the fly with -encryption-key. Playback can be paused, stepped and
+// sought, and a range of it exported as a clip.
+
+// playbackPrefix marks the path of a recording played back as a camera
+const playbackPrefix = "recording:"
+
+// playbackPoll is how often a paused or waiting playback looks for a seek
+const playbackPoll = 20 * time.Millisecond
+
+// playbackFiles are the recordings given with -play
+var playbackFiles []string
+
+// recordedFrame locates a frame of a recording
+type recordedFrame struct {
+	offset int64
+	size   int
+	// at is the capture time after the first frame
+	at       time.Duration
+	captured time.Time
+}
+
+// playbackControl is shared by a playback camera's capture and the main
+// loop
+type playbackControl struct {
+	mu     sync.Mutex
+	paused bool
+	// seek is the frame to show next, -1 to play on
+	seek int
+	// shown is the frame shown last
+	shown int
+	// In and Out are the first and last frame of the clip to export
+	In, Out int
+}
+
+// newPlaybackControl returns the control of a recording of n frames, with
+// all of it selected as the clip
+func newPlaybackControl(n int) *playbackControl {
+	return &playbackControl{seek: -1, Out: n - 1}
+}
+
+// State returns the frame shown last and whether playback is paused
+func (c *playbackControl) State() (shown int, paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.shown, c.paused
+}
+
+// Pause pauses or resumes playback
+func (c *playbackControl) Pause(paused bool) {
+	c.mu.Lock()
+	c.paused = paused
+	c.mu.Unlock()
+}
+
+// Seek shows a frame next, even while paused
+func (c *playbackControl) Seek(frame int) {
+	c.mu.Lock()
+	c.seek = frame
+	c.mu.Unlock()
+}
+
+// Clip returns the range selected for export
+func (c *playbackControl) Clip() (in, out int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.In, c.Out
+}
+
+// take returns a pending seek, -1 for none, and whether playback is paused
+func (c *playbackControl) take() (seek int, paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seek, c.seek = c.seek, -1
+	return seek, c.paused
+}
+
+// isPlaybackSource reports whether a camera plays back a recording
+func isPlaybackSource(camera *CameraInstance) bool {
+	return strings.HasPrefix(camera.Info.Path, playbackPrefix)
+}
+
+// findPlaybackCameras returns the recordings to play as camera entries
+func findPlaybackCameras(firstIndex int) []CameraInfo {
+	cameras := make([]CameraInfo, 0, len(playbackFiles))
+	for i, path := range playbackFiles {
+		cameras = append(cameras, CameraInfo{
+			Path:  playbackPrefix + path,
+			Name:  tr("camera.playback", strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))),
+			Index: firstIndex + i,
+		})
+	}
+	return cameras
+}
+
+// readRecordingIndex reads the frames of a recording from its index sidecar
+func readRecordingIndex(path string) ([]recordedFrame, error) {
+	file, err := os.Open(recordingIndexPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var frames []recordedFrame
+	var first int64
+	scanner := bufio.NewScanner(file)
+	for line := 0; scanner.Scan(); line++ {
+		if line == 0 {
+			switch scanner.Text() + "\n" {
+			case recordingIndexHeader:
+				continue
+			case videoIndexHeader:
+				return nil, fmt.Errorf("%s: %w", path, errVideoRecording)
+			}
+			return nil, fmt.Errorf("%s: not a recording index", recordingIndexPath(path))
+		}
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%s: invalid line %d", recordingIndexPath(path), line+1)
+		}
+		offset, errOffset := strconv.ParseInt(fields[1], 10, 64)
+		size, errSize := strconv.Atoi(fields[2])
+		captured, errCaptured := strconv.ParseInt(fields[3], 10, 64)
+		if errOffset != nil || errSize != nil || errCaptured != nil {
+			return nil, fmt.Errorf("%s: invalid line %d", recordingIndexPath(path), line+1)
+		}
+		if len(frames) == 0 {
+			first = captured
+		}
+		frames = append(frames, recordedFrame{offset: offset, size: size, at: time.Duration(captured - first), captured: time.Unix(0, captured)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("%s holds no frames", path)
+	}
+	return frames, nil
+}
+
+// initPlaybackCamera opens a recording, sizing the textures after its first
+// frame
+func initPlaybackCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
+	path := strings.TrimPrefix(camera.Info.Path, playbackPrefix)
+	frames, err := readRecordingIndex(path)
+	if err != nil {
+		return err
+	}
+	file, err := openInputFile(path)
+	if err != nil {
+		return err
+	}
+	first := make([]byte, frames[0].size)
+	if _, err := file.ReadAt(first, frames[0].offset); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(first))
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to decode the first frame of %s: %w", path, err)
+	}
+	camera.Width, camera.Height = config.Width, config.Height
+	if err := createCameraTextures(camera, renderer); err != nil {
+		file.Close()
+		return err
+	}
+
+	camera.Playback = file
+	camera.PlaybackFrames = frames
+	camera.PlaybackControl = newPlaybackControl(len(frames))
+	camera.Active = true
+	camera.FrameChan = make(chan Frame, 10)
+	log.Printf("Playing %d frames of %s (%dx%d)", len(frames), path, camera.Width, camera.Height)
+	return nil
+}
+
+// capturePlaybackFrames feeds the frames of a recording to the pipeline at
+// their recorded times, starting over after the last one, and follows the
+// pauses and seeks of its control
+func capturePlaybackFrames(camera *CameraInstance) {
+	file, frames, control := camera.Playback, camera.PlaybackFrames, camera.PlaybackControl
+	defer file.Close()
+	// The last frame stays up as long as a frame lasts on average
+	pause := folderPollInterval
+	if len(frames) > 1 {
+		pause = frames[len(frames)-1].at / time.Duration(len(frames)-1)
+	}
+	next := 0
+	// origin is when the first frame was, or would have been, shown
+	origin := time.Now()
+	for camera.Active {
+		seek, paused := control.take()
+		switch {
+		case seek >= 0:
+			next = min(max(seek, 0), len(frames)-1)
+			origin = time.Now().Add(-frames[next].at)
+		case paused:
+			time.Sleep(playbackPoll)
+			if next < len(frames) {
+				origin = time.Now().Add(-frames[next].at)
+			}
+			continue
+		case next == len(frames):
+			time.Sleep(pause)
+			next, origin = 0, time.Now()
+			continue
+		default:
+			if wait := time.Until(origin.Add(frames[next].at)); wait > 0 {
+				time.Sleep(min(wait, playbackPoll))
+				continue
+			}
+			if skipForMemory(camera) {
+				atomic.AddUint64(&camera.DroppedFrames, 1)
+				next++
+				continue
+			}
+		}
+		recorded := frames[next]
+		data := make([]byte, recorded.size)
+		if _, err := file.ReadAt(data, recorded.offset); err != nil {
+			log.Printf("Error reading a frame of %s: %v", camera.Info.Name, err)
+			return
+		}
+		select {
+		case camera.FrameChan <- Frame{Data: data, Captured: time.Now()}:
+			control.mu.Lock()
+			control.shown = next
+			control.mu.Unlock()
+		default:
+			atomic.AddUint64(&camera.DroppedFrames, 1)
+		}
+		next++
+	}
+}
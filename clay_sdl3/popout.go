@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// PopOutWindow shows a single camera in its own OS window, so it can be moved
+// to another monitor. Each window has its own renderer, and textures cannot
+// be shared between renderers, so it also keeps its own copy of the frame.
+type PopOutWindow struct {
+	Window   *sdl.Window
+	Renderer *sdl.Renderer
+	Texture  *sdl.Texture
+	ID       sdl.WindowID
+}
+
+// openPopOut detaches a camera into a new window
+func openPopOut(appData *CameraAppData, index int) error {
+	camera := &appData.Cameras[index]
+	if camera.PopOut != nil {
+		_ = camera.PopOut.Window.Raise()
+		return nil
+	}
+	if !camera.Active || camera.Width <= 0 || camera.Height <= 0 {
+		return trErr("error.not_stream", camera.Info.Name)
+	}
+
+	window, renderer, err := sdl.CreateWindowAndRenderer(camera.Info.Name, camera.Width, camera.Height,
+		sdl.WINDOW_RESIZABLE|sdl.WINDOW_HIGH_PIXEL_DENSITY)
+	if err != nil {
+		return fmt.Errorf("failed to create window for %s: %w", camera.Info.Name, err)
+	}
+	// Only the main window waits for vsync, so extra windows don't slow
+	// down the main loop
+	_ = renderer.SetVSync(0)
+
+	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STATIC, camera.Width, camera.Height)
+	if err != nil {
+		renderer.Destroy()
+		window.Destroy()
+		return fmt.Errorf("failed to create texture for %s: %w", camera.Info.Name, err)
+	}
+
+	id, err := window.ID()
+	if err != nil {
+		texture.Destroy()
+		renderer.Destroy()
+		window.Destroy()
+		return fmt.Errorf("failed to get window ID for %s: %w", camera.Info.Name, err)
+	}
+
+	camera.FrameMutex.Lock()
+	camera.PopOut = &PopOutWindow{
+		Window:   window,
+		Renderer: renderer,
+		Texture:  texture,
+		ID:       id,
+	}
+	camera.FrameMutex.Unlock()
+	return nil
+}
+
+// closePopOut destroys a camera's pop-out window, if it has one
+func closePopOut(camera *CameraInstance) {
+	camera.FrameMutex.Lock()
+	popOut := camera.PopOut
+	camera.PopOut = nil
+	camera.FrameMutex.Unlock()
+	if popOut == nil {
+		return
+	}
+
+	popOut.Texture.Destroy()
+	popOut.Renderer.Destroy()
+	popOut.Window.Destroy()
+}
+
+// findPopOut returns the index of the camera shown in the window with the
+// given ID, or -1
+func findPopOut(appData *CameraAppData, id sdl.WindowID) int {
+	for i := range appData.Cameras {
+		if popOut := appData.Cameras[i].PopOut; popOut != nil && popOut.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// renderPopOuts draws each pop-out window, letterboxing the frame to keep
+// its aspect ratio
+func renderPopOuts(appData *CameraAppData) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		camera.FrameMutex.RLock()
+		popOut := camera.PopOut
+		if popOut == nil {
+			camera.FrameMutex.RUnlock()
+			continue
+		}
+
+		renderer := popOut.Renderer
+		_ = renderer.SetDrawColor(0, 0, 0, 255)
+		_ = renderer.Clear()
+		if !camera.Disabled {
+			width, height, err := renderer.CurrentOutputSize()
+			if err == nil && width > 0 && height > 0 {
+				rect := fitRect(float32(width), float32(height), float32(camera.Width), float32(camera.Height))
+				if err := renderer.RenderTexture(popOut.Texture, nil, &rect); err != nil {
+					log.Printf("Error rendering pop-out for %s: %v", camera.Info.Name, err)
+				}
+			}
+		}
+		camera.FrameMutex.RUnlock()
+		_ = renderer.Present()
+	}
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of a w x h
+// frame centered in an outer width x height area
+func fitRect(width, height, w, h float32) sdl.FRect {
+	scale := min(width/w, height/h)
+	return sdl.FRect{
+		X: (width - w*scale) / 2,
+		Y: (height - h*scale) / 2,
+		W: w * scale,
+		H: h * scale,
+	}
+}
+
+// handlePopOutEvent handles events for pop-out windows, closing them on
+// request or Escape. It reports whether the event belonged to a pop-out, so
+// the main window's handlers can ignore it.
+func handlePopOutEvent(appData *CameraAppData, event *sdl.Event) bool {
+	window := event.Window()
+	if window == nil || window == appData.Window {
+		return false
+	}
+	id, err := window.ID()
+	if err != nil {
+		return true
+	}
+	index := findPopOut(appData, id)
+	if index < 0 {
+		return true
+	}
+
+	switch event.Type {
+	case sdl.EVENT_WINDOW_CLOSE_REQUESTED:
+		closePopOut(&appData.Cameras[index])
+	case sdl.EVENT_KEY_DOWN:
+		if event.KeyboardEvent().Scancode == sdl.SCANCODE_ESCAPE {
+			closePopOut(&appData.Cameras[index])
+		}
+	}
+	return true
+}
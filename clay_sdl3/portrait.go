@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Kiosk displays are often mounted vertically, where a camera view 70% of
+// the width wide and a column of thumbnails beside it leave most of the
+// screen empty. On a portrait display the main view takes the whole width,
+// the thumbnails run in a row along the top or bottom edge, and the readings
+// of the on-screen display are laid out across the view instead of down it.
+// -layout auto follows the orientation the display reports, or without one
+// the shape of the window, and switches when either changes; landscape,
+// portrait-top and portrait-bottom fix the layout.
+
+// stripEdge is the edge of the window the thumbnail strip runs along
+type stripEdge int
+
+const (
+	stripRight stripEdge = iota
+	stripTop
+	stripBottom
+)
+
+// layoutPresets are the values of -layout
+var layoutPresets = []string{"auto", "landscape", "portrait-top", "portrait-bottom"}
+
+// layoutPreset is the layout chosen with -layout
+var layoutPreset = "auto"
+
+// thumbnailEdge is where the thumbnail strip is in the current layout
+var thumbnailEdge = stripRight
+
+// setLayoutPreset sets the layout, one of layoutPresets
+func setLayoutPreset(name string) error {
+	name = strings.ToLower(name)
+	if !slices.Contains(layoutPresets, name) {
+		return fmt.Errorf("unknown layout %q, expected one of %s", name, strings.Join(layoutPresets, ", "))
+	}
+	layoutPreset = name
+	return nil
+}
+
+// presetEdge returns where a preset puts the thumbnails on a display of
+// the given orientation, with the window's size in pixels for displays
+// that report none
+func presetEdge(preset string, orientation sdl.DisplayOrientation, width, height int32) stripEdge {
+	switch preset {
+	case "landscape":
+		return stripRight
+	case "portrait-top":
+		return stripTop
+	case "portrait-bottom":
+		return stripBottom
+	}
+	switch orientation {
+	case sdl.ORIENTATION_PORTRAIT, sdl.ORIENTATION_PORTRAIT_FLIPPED:
+		return stripBottom
+	case sdl.ORIENTATION_UNKNOWN:
+		if height > width {
+			return stripBottom
+		}
+	}
+	return stripRight
+}
+
+// updateLayoutOrientation lays the window out for the orientation of the
+// display it is on
+func updateLayoutOrientation(window *sdl.Window) {
+	width, height, err := window.SizeInPixels()
+	if err != nil {
+		return
+	}
+	orientation := sdl.ORIENTATION_UNKNOWN
+	if display := sdl.GetDisplayForWindow(window); display != 0 {
+		orientation = display.CurrentDisplayOrientation()
+	}
+	edge := presetEdge(layoutPreset, orientation, width, height)
+	if edge == thumbnailEdge {
+		return
+	}
+	thumbnailEdge = edge
+	switch edge {
+	case stripTop:
+		log.Printf("Portrait layout, thumbnails at the top")
+	case stripBottom:
+		log.Printf("Portrait layout, thumbnails at the bottom")
+	default:
+		log.Printf("Landscape layout")
+	}
+}
+
+// portraitLayout reports whether the thumbnails run along the top or
+// bottom edge
+func portraitLayout() bool {
+	return thumbnailEdge != stripRight
+}
+
+// contentDirection is how the main panes and the thumbnail strip are
+// arranged
+func contentDirection() clay.LayoutDirection {
+	if portraitLayout() {
+		return clay.TOP_TO_BOTTOM
+	}
+	return clay.LEFT_TO_RIGHT
+}
+
+// stripAxis returns the coordinate of a point along the thumbnail strip
+func stripAxis(x, y float32) float32 {
+	if portraitLayout() {
+		return x
+	}
+	return y
+}
+
+// stripSpan returns where a box starts and how long it is along the strip
+func stripSpan(box clay.BoundingBox) (start, length float32) {
+	if portraitLayout() {
+		return box.X, box.Width
+	}
+	return box.Y, box.Height
+}
+
+// withinStrip reports whether a point lies in the stretch of the strip the
+// panel shows, which is where its thumbnails can be clicked
+func withinStrip(panel clay.BoundingBox, x, y float32) bool {
+	start, length := stripSpan(panel)
+	at := stripAxis(x, y)
+	return at >= start && at <= start+length
+}
+
+// stripScrollDelta turns the mouse wheel into sideways scrolling over a
+// strip that runs across the window
+func stripScrollDelta(delta clay.Vector2, x, y float32) clay.Vector2 {
+	if portraitLayout() && delta.X == 0 && pointInElement(SafeID("ThumbnailsPanel"), x, y) {
+		return clay.Vector2{X: delta.Y}
+	}
+	return delta
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+func TestPresetEdge(t *testing.T) {
+	for _, tc := range []struct {
+		preset        string
+		orientation   sdl.DisplayOrientation
+		width, height int32
+		want          stripEdge
+	}{
+		{"auto", sdl.ORIENTATION_LANDSCAPE, 1920, 1080, stripRight},
+		{"auto", sdl.ORIENTATION_PORTRAIT, 1080, 1920, stripBottom},
+		{"auto", sdl.ORIENTATION_PORTRAIT_FLIPPED, 1080, 1920, stripBottom},
+		// A window on a portrait display follows the display
+		{"auto", sdl.ORIENTATION_PORTRAIT, 800, 600, stripBottom},
+		// Without an orientation the window's shape decides
+		{"auto", sdl.ORIENTATION_UNKNOWN, 600, 1024, stripBottom},
+		{"auto", sdl.ORIENTATION_UNKNOWN, 1024, 600, stripRight},
+		{"landscape", sdl.ORIENTATION_PORTRAIT, 1080, 1920, stripRight},
+		{"portrait-top", sdl.ORIENTATION_LANDSCAPE, 1920, 1080, stripTop},
+		{"portrait-bottom", sdl.ORIENTATION_LANDSCAPE, 1920, 1080, stripBottom},
+	} {
+		if got := presetEdge(tc.preset, tc.orientation, tc.width, tc.height); got != tc.want {
+			t.Errorf("%s on %d %dx%d: got %d, want %d", tc.preset, tc.orientation, tc.width, tc.height, got, tc.want)
+		}
+	}
+}
+
+func TestSetLayoutPreset(t *testing.T) {
+	defer setLayoutPreset("auto")
+	if err := setLayoutPreset("Portrait-Top"); err != nil || layoutPreset != "portrait-top" {
+		t.Fatalf("got %q, %v", layoutPreset, err)
+	}
+	if err := setLayoutPreset("sideways"); err == nil {
+		t.Error("unknown layout accepted")
+	}
+}
+
+// TestStripAxis checks that points are compared along the strip: down the
+// column at the right, across the row of the portrait layout
+func TestStripAxis(t *testing.T) {
+	defer func() { thumbnailEdge = stripRight }()
+	panel := clay.BoundingBox{X: 800, Y: 0, Width: 100, Height: 600}
+	if !withinStrip(panel, 10, 300) || withinStrip(panel, 850, 700) {
+		t.Error("landscape strip compares the wrong axis")
+	}
+
+	thumbnailEdge = stripBottom
+	panel = clay.BoundingBox{X: 0, Y: 900, Width: 600, Height: 100}
+	if !withinStrip(panel, 300, 10) || withinStrip(panel, 700, 950) {
+		t.Error("portrait strip compares the wrong axis")
+	}
+	if start, length := stripSpan(panel); start != 0 || length != 600 {
+		t.Errorf("portrait span %v+%v", start, length)
+	}
+	if contentDirection() != clay.TOP_TO_BOTTOM {
+		t.Error("portrait panes are not stacked")
+	}
+}
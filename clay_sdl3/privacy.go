@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// Privacy mode is a master switch for shared spaces: it stops every camera,
+// ends their recordings, forgets their last frames so neither the window,
+// the API nor a snapshot shows anything, and puts a banner over the window
+// until it ends, when the cameras that were on start again. Cameras cannot
+// be enabled while it lasts. It is switched with P, the API's
+// POST /api/privacy?on=1, the schedule's privacy_on and privacy_off actions
+// for privacy windows, and a switch on a GPIO input given with
+// -privacy-gpio PIN[:low], which turns it on while the switch is closed.
+
+// privacyGPIOPoll is how often the privacy switch is read
+const privacyGPIOPoll = 200 * time.Millisecond
+
+// Privacy is the state of privacy mode
+type Privacy struct {
+	Enabled bool
+	Since   time.Time
+	// resume are the cameras privacy mode disabled, to enable again when it
+	// ends
+	resume []int
+}
+
+var privacy Privacy
+
+// privacyRequest carries a change asked for outside the main loop to
+// updatePrivacy: 0 for none, privacyRequestOn or privacyRequestOff
+var privacyRequest atomic.Int32
+
+const (
+	privacyRequestOn = iota + 1
+	privacyRequestOff
+)
+
+// requestPrivacy asks the main loop to switch privacy mode
+func requestPrivacy(on bool) {
+	if on {
+		privacyRequest.Store(privacyRequestOn)
+	} else {
+		privacyRequest.Store(privacyRequestOff)
+	}
+}
+
+// updatePrivacy applies a change requested from another goroutine
+func updatePrivacy(appData *CameraAppData) {
+	switch privacyRequest.Swap(0) {
+	case privacyRequestOn:
+		setPrivacyStatus(appData, true)
+	case privacyRequestOff:
+		setPrivacyStatus(appData, false)
+	}
+}
+
+// setPrivacy switches privacy mode on or off
+func setPrivacy(appData *CameraAppData, on bool) error {
+	if on == privacy.Enabled {
+		return nil
+	}
+	if on {
+		privacy = Privacy{Enabled: true, Since: time.Now()}
+		for i := range appData.Cameras {
+			camera := &appData.Cameras[i]
+			if camera.Disabled {
+				continue
+			}
+			disableCamera(camera)
+			privacy.resume = append(privacy.resume, i)
+		}
+		for i := range appData.Cameras {
+			camera := &appData.Cameras[i]
+			camera.FrameMutex.Lock()
+			camera.LastFrame, camera.LastRaw, camera.LastSensor = nil, nil, nil
+			camera.FrameMutex.Unlock()
+			camera.History.clear()
+			camera.Freeze = nil
+		}
+		api.forgetFrames()
+		log.Printf("Privacy mode on, stopped %d cameras", len(privacy.resume))
+	} else {
+		resume := privacy.resume
+		privacy = Privacy{}
+		var errs []error
+		for _, i := range resume {
+			if i < len(appData.Cameras) {
+				if err := enableCamera(&appData.Cameras[i], appData.Renderer); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		log.Printf("Privacy mode off, started %d cameras", len(resume)-len(errs))
+		if err := errors.Join(errs...); err != nil {
+			api.publishPrivacy()
+			return err
+		}
+	}
+	api.publishPrivacy()
+	return nil
+}
+
+// setPrivacyStatus switches privacy mode and reports it in the status bar
+// and as a toast
+func setPrivacyStatus(appData *CameraAppData, on bool) {
+	if on == privacy.Enabled {
+		return
+	}
+	if err := setPrivacy(appData, on); err != nil {
+		setErrorStatus(appData, err)
+		notify(toastWarning, err.Error())
+		return
+	}
+	appData.StatusText = tr("status.privacy_off")
+	if on {
+		appData.StatusText = tr("status.privacy_on")
+	}
+	appData.StatusColor = theme.Text
+	notify(toastInfo, appData.StatusText)
+}
+
+// privacyGPIO is a switch read from a sysfs GPIO input
+type privacyGPIO struct {
+	Pin       int
+	ActiveLow bool
+}
+
+// parsePrivacyGPIO parses a -privacy-gpio value, PIN[:low]
+func parsePrivacyGPIO(value string) (privacyGPIO, error) {
+	pin, polarity, _ := strings.Cut(value, ":")
+	n, err := strconv.Atoi(pin)
+	if err != nil || n < 0 || polarity != "" && polarity != "low" {
+		return privacyGPIO{}, fmt.Errorf("invalid privacy switch %q, expected PIN[:low]", value)
+	}
+	return privacyGPIO{Pin: n, ActiveLow: polarity == "low"}, nil
+}
+
+// read reports whether the switch is closed
+func (g privacyGPIO) read() (bool, error) {
+	data, err := os.ReadFile(filepath.Join(gpioDir, fmt.Sprintf("gpio%d", g.Pin), "value"))
+	if err != nil {
+		return false, fmt.Errorf("failed to read GPIO %d: %w", g.Pin, err)
+	}
+	return (strings.TrimSpace(string(data)) == "1") != g.ActiveLow, nil
+}
+
+// watchPrivacyGPIO sets the pin up as an input and switches privacy mode
+// whenever the switch is flipped, and at startup if it is closed
+func watchPrivacyGPIO(g privacyGPIO) error {
+	pin := filepath.Join(gpioDir, fmt.Sprintf("gpio%d", g.Pin))
+	if _, err := os.Stat(pin); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(filepath.Join(gpioDir, "export"), []byte(strconv.Itoa(g.Pin)), 0o644); err != nil {
+			return fmt.Errorf("failed to export GPIO %d: %w", g.Pin, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(pin, "direction"), []byte("in"), 0o644); err != nil {
+		return fmt.Errorf("failed to set up GPIO %d: %w", g.Pin, err)
+	}
+	closed, err := g.read()
+	if err != nil {
+		return err
+	}
+	if closed {
+		requestPrivacy(true)
+	}
+	go func() {
+		for {
+			time.Sleep(privacyGPIOPoll)
+			now, err := g.read()
+			if err != nil {
+				log.Printf("Privacy switch: %v", err)
+				continue
+			}
+			if now != closed {
+				closed = now
+				requestPrivacy(closed)
+			}
+		}
+	}()
+	return nil
+}
+
+// apiPrivacy is the privacy state the API reports
+type apiPrivacy struct {
+	Privacy bool       `json:"privacy"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// privacyState returns the privacy state, on the main loop
+func privacyState() apiPrivacy {
+	state := apiPrivacy{Privacy: privacy.Enabled}
+	if privacy.Enabled {
+		since := privacy.Since
+		state.Since = &since
+	}
+	return state
+}
+
+// forgetFrames drops the latest frames, so nothing is served in privacy mode
+func (s *APIServer) forgetFrames() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	clear(s.latest)
+	s.mu.Unlock()
+}
+
+// publishPrivacy tells event clients about a change of privacy mode
+func (s *APIServer) publishPrivacy() {
+	if s != nil {
+		s.publishEvent("privacy", privacyState())
+	}
+}
+
+func (s *APIServer) handlePrivacy(w http.ResponseWriter, r *http.Request) {
+	value, err := s.command(func(appData *CameraAppData) (any, error) {
+		return privacyState(), nil
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, value)
+}
+
+func (s *APIServer) handleSetPrivacy(w http.ResponseWriter, r *http.Request) {
+	on, err := strconv.ParseBool(r.URL.Query().Get("on"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("on must be 1 or 0"))
+		return
+	}
+	value, err := s.command(func(appData *CameraAppData) (any, error) {
+		setPrivacyStatus(appData, on)
+		return privacyState(), nil
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, value)
+}
+
+// createPrivacyLayout declares the privacy banner centered over the window
+func createPrivacyLayout() {
+	if !privacy.Enabled {
+		return
+	}
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("PrivacyBanner"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Padding:         clay.PaddingAll(dpu(16)),
+			ChildGap:        dpu(6),
+			ChildAlignment: clay.ChildAlignment{
+				X: clay.ALIGN_X_CENTER,
+			},
+		},
+		Floating: clay.FloatingElementConfig{
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_CENTER,
+				Parent:  clay.ATTACH_POINT_CENTER_CENTER,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(6)),
+		Border: clay.BorderElementConfig{
+			Color: theme.Error,
+			Width: clay.BorderOutside(dpu(3)),
+		},
+	}, func() {
+		safeText("privacy-title", tr("privacy.title"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  20,
+			TextColor: theme.Error,
+		})
+		safeText("privacy-since", tr("privacy.since", privacy.Since.Format("15:04")), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  12,
+			TextColor: theme.Text,
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resetPrivacy ends privacy mode after a test
+func resetPrivacy(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		privacy = Privacy{}
+		privacyRequest.Store(0)
+	})
+}
+
+func TestPrivacy(t *testing.T) {
+	resetPrivacy(t)
+	appData := &CameraAppData{Cameras: []CameraInstance{
+		{Info: CameraInfo{Name: "bench", Path: playbackPrefix + filepath.Join(t.TempDir(), "missing.mjpeg")}, LastFrame: []byte("frame")},
+		{Info: CameraInfo{Name: "door"}, Disabled: true, LastFrame: []byte("frame")},
+	}}
+
+	if err := setPrivacy(appData, true); err != nil {
+		t.Fatal(err)
+	}
+	for i := range appData.Cameras {
+		if camera := &appData.Cameras[i]; !camera.Disabled || camera.LastFrame != nil {
+			t.Errorf("%s disabled %v with a frame of %d bytes", camera.Info.Name, camera.Disabled, len(camera.LastFrame))
+		}
+	}
+	if len(privacy.resume) != 1 || privacy.resume[0] != 0 {
+		t.Errorf("resumes cameras %v", privacy.resume)
+	}
+	if err := enableCamera(&appData.Cameras[1], nil); err == nil {
+		t.Error("a camera was enabled in privacy mode")
+	}
+
+	// Only the camera privacy mode stopped starts again, here failing since
+	// its recording does not exist
+	err := setPrivacy(appData, false)
+	if privacy.Enabled || err == nil || !strings.Contains(err.Error(), "bench") {
+		t.Errorf("privacy %v after ending it, %v", privacy.Enabled, err)
+	}
+}
+
+func TestUpdatePrivacy(t *testing.T) {
+	resetPrivacy(t)
+	appData := &CameraAppData{}
+	requestPrivacy(true)
+	updatePrivacy(appData)
+	if !privacy.Enabled || appData.StatusText != tr("status.privacy_on") {
+		t.Errorf("privacy %v, status %q", privacy.Enabled, appData.StatusText)
+	}
+	requestPrivacy(false)
+	updatePrivacy(appData)
+	if privacy.Enabled {
+		t.Error("privacy mode did not end")
+	}
+}
+
+func TestParsePrivacyGPIO(t *testing.T) {
+	for value, want := range map[string]privacyGPIO{"17": {Pin: 17}, "4:low": {Pin: 4, ActiveLow: true}} {
+		if got, err := parsePrivacyGPIO(value); err != nil || got != want {
+			t.Errorf("%s parsed as %+v, %v", value, got, err)
+		}
+	}
+	for _, value := range []string{"", "pin", "-1", "4:high"} {
+		if _, err := parsePrivacyGPIO(value); err == nil {
+			t.Errorf("%q was accepted", value)
+		}
+	}
+}
+
+func TestPrivacyGPIORead(t *testing.T) {
+	dir := t.TempDir()
+	gpio := gpioDir
+	gpioDir = dir
+	t.Cleanup(func() { gpioDir = gpio })
+	if err := os.MkdirAll(filepath.Join(dir, "gpio4"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for value, want := range map[string][2]bool{"1\n": {true, false}, "0\n": {false, true}} {
+		os.WriteFile(filepath.Join(dir, "gpio4", "value"), []byte(value), 0o644)
+		high, _ := privacyGPIO{Pin: 4}.read()
+		low, _ := privacyGPIO{Pin: 4, ActiveLow: true}.read()
+		if high != want[0] || low != want[1] {
+			t.Errorf("value %q read as %v, %v active low", value, high, low)
+		}
+	}
+}
+
+func TestAPIPrivacy(t *testing.T) {
+	resetPrivacy(t)
+	s := newAPIServer()
+	s.latest[0] = Frame{Data: []byte("frame")}
+	api = s
+	t.Cleanup(func() { api = nil })
+	serveAPIServer(t, s, &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "bench"}}}})
+	handler := s.routes()
+	request := func(method, target string) (*httptest.ResponseRecorder, apiPrivacy) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		var state apiPrivacy
+		json.Unmarshal(w.Body.Bytes(), &state)
+		return w, state
+	}
+
+	if w, state := request(http.MethodPost, "/api/privacy?on=1"); w.Code != http.StatusOK || !state.Privacy || state.Since == nil {
+		t.Errorf("privacy on %d %s", w.Code, w.Body)
+	}
+	s.mu.Lock()
+	latest := len(s.latest)
+	s.mu.Unlock()
+	if latest != 0 {
+		t.Error("the API kept frames in privacy mode")
+	}
+	if _, state := request(http.MethodGet, "/api/privacy"); !state.Privacy {
+		t.Error("privacy mode is not reported")
+	}
+	if w, _ := request(http.MethodPost, "/api/privacy?on=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid value answered %d", w.Code)
+	}
+	if _, state := request(http.MethodPost, "/api/privacy?on=0"); state.Privacy {
+		t.Error("privacy mode did not end")
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "image"
+
+// processFrame runs the per-camera processing stages on a decoded frame
+// before it is uploaded to the textures. It is called with FrameMutex held.
+// Snapshots and recordings keep the camera's original JPEG frames.
+func processFrame(camera *CameraInstance, img *image.RGBA) {
+	applyDenoise(camera, img)
+	applyKeystone(camera, img)
+	measureSceneLuma(camera, img)
+	meterFrame(camera, img)
+	applyWhiteBalance(camera, img)
+	captureBracket(camera, img)
+	accumulateStack(camera, img)
+	applyPalette(camera, img)
+}
+
+// frameLuma computes the luma of every pixel of a frame, row by row, reusing
+// buf when it is large enough
+func frameLuma(img *image.RGBA, buf []uint8) []uint8 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if cap(buf) < width*height {
+		buf = make([]uint8, width*height)
+	}
+	buf = buf[:width*height]
+	for y := range height {
+		row := img.Pix[y*img.Stride:]
+		for x := range width {
+			p := row[x*4:]
+			buf[y*width+x] = uint8((77*uint32(p[0]) + 150*uint32(p[1]) + 29*uint32(p[2])) >> 8)
+		}
+	}
+	return buf
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A control profile is a named set of control values for a camera, with an
+// optional cap on its frame rate, such as a day profile with a short
+// exposure and a night profile with more gain at a lower rate. Profiles are
+// kept in profilesFile by the camera's stable ID, "*" holding the profiles
+// of cameras without their own:
+//
+//	{"*": {"night": {"controls": [{"name": "Gain", "value": 200}], "max_fps": 10}}}
+//
+// Controls are set by ID, or by name when the ID is 0 so that the file can be
+// written by hand, in the order given, so that auto modes can be switched
+// off before the values they would override.
+//
+// Profiles are also saved from the controls panel, with the values the
+// camera has at the time ("Microscope 40x", "PCB macro"), and switched from
+// its profile list or the API.
+
+// profilesFile keeps the control profiles of the cameras
+var profilesFile = "profiles.json"
+
+// ControlProfile is a named set of control values
+type ControlProfile struct {
+	Controls []SessionControl `json:"controls"`
+	// MaxFPS caps the frames taken from the camera, 0 for all of them
+	MaxFPS float64 `json:"max_fps,omitempty"`
+}
+
+// cameraProfiles are the profiles by camera ID and name; nil until
+// profilesFile is read
+var cameraProfiles map[string]map[string]ControlProfile
+
+// loadProfiles reads profilesFile, if there is one
+func loadProfiles() error {
+	cameraProfiles = map[string]map[string]ControlProfile{}
+	data, err := os.ReadFile(profilesFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load control profiles: %w", err)
+	}
+	if err := json.Unmarshal(data, &cameraProfiles); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", profilesFile, err)
+	}
+	return nil
+}
+
+// profileNames returns the names of the profiles a camera can use, sorted
+func profileNames(camera *CameraInstance) []string {
+	if cameraProfiles == nil {
+		if err := loadProfiles(); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	var names []string
+	for _, key := range []string{cameraID(camera.Info), "*"} {
+		for name := range cameraProfiles[key] {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// saveProfile saves the current control values and frame rate cap of a
+// camera as one of its own profiles. profilesFile is read again first, so
+// that edits made by hand since it was loaded are kept.
+func saveProfile(camera *CameraInstance, name string) error {
+	if camera.Device == nil {
+		return trErr("error.not_stream", camera.Info.Name)
+	}
+	if err := loadProfiles(); err != nil {
+		return err
+	}
+	profile := ControlProfile{Controls: captureControls(camera)}
+	// In ID order, which puts the UVC auto modes before the values they
+	// override
+	slices.SortFunc(profile.Controls, func(a, b SessionControl) int {
+		return int(a.ID) - int(b.ID)
+	})
+	if interval := camera.FrameInterval.Load(); interval > 0 {
+		profile.MaxFPS = math.Round(float64(time.Second)/float64(interval)*100) / 100
+	}
+	id := cameraID(camera.Info)
+	if cameraProfiles[id] == nil {
+		cameraProfiles[id] = make(map[string]ControlProfile)
+	}
+	cameraProfiles[id][name] = profile
+
+	data, err := json.MarshalIndent(cameraProfiles, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode control profile %q: %w", name, err)
+	}
+	if err := os.WriteFile(profilesFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save control profile %q: %w", name, err)
+	}
+	camera.Profile = name
+	return nil
+}
+
+// profileFor looks up a camera's profile by name
+func profileFor(camera *CameraInstance, name string) (ControlProfile, bool) {
+	if cameraProfiles == nil {
+		if err := loadProfiles(); err != nil {
+			return ControlProfile{}, false
+		}
+	}
+	if profile, ok := cameraProfiles[cameraID(camera.Info)][name]; ok {
+		return profile, true
+	}
+	profile, ok := cameraProfiles["*"][name]
+	return profile, ok
+}
+
+// applyProfile sets the controls and frame rate cap of a camera's profile
+func applyProfile(camera *CameraInstance, name string) error {
+	if cameraProfiles == nil {
+		if err := loadProfiles(); err != nil {
+			return err
+		}
+	}
+	profile, ok := profileFor(camera, name)
+	if !ok {
+		return trErr("error.no_profile", camera.Info.Name, name)
+	}
+	if camera.Device == nil && len(profile.Controls) > 0 {
+		return trErr("error.not_stream", camera.Info.Name)
+	}
+	camera.Profile = name
+
+	var errs []error
+	if len(profile.Controls) > 0 {
+		controls, err := camera.Device.QueryControls()
+		if err != nil {
+			return fmt.Errorf("failed to list the controls of %s: %w", camera.Info.Name, err)
+		}
+		for _, saved := range profile.Controls {
+			id := saved.ID
+			if id == 0 {
+				for _, ctrl := range controls {
+					if strings.EqualFold(ctrl.Name, saved.Name) {
+						id = ctrl.ID
+						break
+					}
+				}
+			}
+			if id == 0 {
+				errs = append(errs, fmt.Errorf("%s has no control %q", camera.Info.Name, saved.Name))
+				continue
+			}
+			if err := camera.Device.SetControlValue(id, saved.Value); err != nil {
+				errs = append(errs, trErr("error.set_control", saved.Name, err))
+			}
+		}
+	}
+	setMaxFPS(camera, profile.MaxFPS)
+	return errors.Join(errs...)
+}
+
+// setMaxFPS caps the frames taken from a camera, 0 for no cap
+func setMaxFPS(camera *CameraInstance, fps float64) {
+	var interval int64
+	if fps > 0 {
+		interval = int64(float64(time.Second) / fps)
+	}
+	camera.FrameInterval.Store(interval)
+}
+
+// skipForRate reports whether capture should skip a frame to keep under the
+// camera's frame rate cap. It is called by the capture goroutine.
+func skipForRate(camera *CameraInstance, now time.Time) bool {
+	interval := time.Duration(camera.FrameInterval.Load())
+	if interval <= 0 {
+		return false
+	}
+	// A little slack keeps a camera at exactly the cap from skipping
+	// every other frame on jitter
+	if now.Sub(camera.lastKept) < interval*9/10 {
+		return true
+	}
+	camera.lastKept = now
+	return false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+func TestSaveProfile(t *testing.T) {
+	saved := profilesFile
+	t.Cleanup(func() {
+		profilesFile = saved
+		cameraProfiles = nil
+	})
+	profilesFile = filepath.Join(t.TempDir(), "profiles.json")
+	cameraProfiles = nil
+
+	dev := newFakeDevice(0)
+	dev.Controls[ctrlGain] = v4l2.Control{ID: ctrlGain, Type: v4l2.CtrlTypeInt, Name: "Gain", Minimum: 0, Maximum: 255, Step: 1}
+	camera := &CameraInstance{Info: CameraInfo{Name: "scope", Path: "/dev/video0", ID: "usb-scope"}, Device: dev, Active: true}
+	if names := profileNames(camera); len(names) != 0 {
+		t.Fatalf("profiles before saving: %v", names)
+	}
+
+	if err := dev.SetControlValue(v4l2.CtrlBrightness, 90); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.SetControlValue(ctrlGain, 40); err != nil {
+		t.Fatal(err)
+	}
+	setMaxFPS(camera, 15)
+	if err := saveProfile(camera, "Microscope 40x"); err != nil {
+		t.Fatal(err)
+	}
+	if camera.Profile != "Microscope 40x" {
+		t.Errorf("profile after saving %q", camera.Profile)
+	}
+
+	// The saved profile comes back from the file, and a shared profile is
+	// listed with it
+	cameraProfiles = nil
+	if names := profileNames(camera); !slices.Equal(names, []string{"Microscope 40x"}) {
+		t.Errorf("profiles read back %v", names)
+	}
+	cameraProfiles["*"] = map[string]ControlProfile{"PCB macro": {}}
+	if names := profileNames(camera); !slices.Equal(names, []string{"Microscope 40x", "PCB macro"}) {
+		t.Errorf("profiles %v", names)
+	}
+
+	setMaxFPS(camera, 0)
+	if err := dev.SetControlValue(v4l2.CtrlBrightness, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.SetControlValue(ctrlGain, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProfile(camera, "PCB macro"); err != nil || camera.Profile != "PCB macro" {
+		t.Fatalf("PCB macro: %v, profile %q", err, camera.Profile)
+	}
+	if err := applyProfile(camera, "Microscope 40x"); err != nil {
+		t.Fatal(err)
+	}
+	brightness, _ := dev.GetControlValue(v4l2.CtrlBrightness)
+	gain, _ := dev.GetControlValue(ctrlGain)
+	if brightness != 90 || gain != 40 || camera.FrameInterval.Load() == 0 {
+		t.Errorf("applied brightness %d, gain %d, interval %d", brightness, gain, camera.FrameInterval.Load())
+	}
+	if err := applyProfile(camera, "missing"); err == nil {
+		t.Error("missing profile applied")
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/image/tiff"
+)
+
+const (
+	snapshotDir  = "snapshots"
+	recordingDir = "recordings"
+)
+
+// Frame is an encoded frame with the time it was received from the camera.
+// Sources that do not deliver JPEG, such as raw Bayer or 16-bit sensors, pass
+// the frame decoded at its full depth in Raw instead; lossless snapshots save
+// Raw when it is set.
+type Frame struct {
+	Data     []byte
+	Captured time.Time
+	Raw      image.Image
+	// Sensor is the unprocessed data of raw sources, for raw snapshots
+	Sensor *SensorFrame
+	// Decoded is the frame decoded ahead of the main loop by a decode
+	// worker, nil if it was not
+	Decoded *image.RGBA
+	// DecodeTo is the size the frame is shown at, which adaptive decoding
+	// may scale it down to, zero to decode it at full size
+	DecodeTo image.Point
+}
+
+// snapshotFormats are the file formats of snapshots, set with
+// -snapshot-format. PNG and TIFF are lossless and keep 16-bit frames at their
+// full depth; raw saves the unprocessed data of raw sources with a JSON
+// description, and the JPEG of other cameras.
+var snapshotFormats = []string{"jpg", "png", "tiff", "raw"}
+
+// snapshotFormat is the format new snapshots are saved in
+var snapshotFormat = "jpg"
+
+// setSnapshotFormat selects the snapshot format
+func setSnapshotFormat(format string) error {
+	format = strings.ToLower(format)
+	if format == "jpeg" {
+		format = "jpg"
+	} else if format == "tif" {
+		format = "tiff"
+	}
+	if !slices.Contains(snapshotFormats, format) {
+		return fmt.Errorf("unknown snapshot format %q, expected one of %s", format, strings.Join(snapshotFormats, ", "))
+	}
+	snapshotFormat = format
+	return nil
+}
+
+// Recorder writes the raw MJPEG frames of a camera to a file. The result is a
+// plain concatenation of JPEG images which ffmpeg/VLC play as an .mjpeg file.
+// A CSV sidecar next to it lists every frame with its byte range and capture
+// time, so recordings of several cameras can be aligned frame by frame.
+// With -record-codec h264 the frames go through video instead, see
+// videoencoder.go.
+type Recorder struct {
+	file   outputFile
+	video  VideoEncoder
+	index  *os.File
+	offset int64
+	// marker is locked while the recording is written, nil without one
+	marker *os.File
+	// meta logs the sensor readings as JSON lines whenever they change, if
+	// metadata sources are configured
+	meta        *os.File
+	metaVersion uint64
+
+	Path      string
+	Frames    int
+	StartedAt time.Time
+	// synced is when the files were last flushed to disk
+	synced time.Time
+}
+
+// recordingIndexHeader is the first line of a recording's sidecar. Capture
+// times are wall-clock nanoseconds since the Unix epoch, taken when the frame
+// arrives from the device, so they are comparable between cameras.
+const recordingIndexHeader = "frame,offset,size,capture_unix_ns,capture_time\n"
+
+// videoIndexHeader is the first line of an H.264 recording's sidecar, whose
+// frames have no byte range of their own in the video
+const videoIndexHeader = "frame,capture_unix_ns,capture_time\n"
+
+// errVideoRecording is why H.264 recordings are not played back or cut here
+var errVideoRecording = errors.New("H.264 recordings play and cut in a video player")
+
+// recordingIndexPath returns the path of a recording's sidecar
+func recordingIndexPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".csv"
+}
+
+// recordingMetadataPath returns the path of the readings logged with a
+// recording
+func recordingMetadataPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".meta.jsonl"
+}
+
+// fileSafeName turns a camera name into something usable in a file name
+func fileSafeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" {
+		return "camera"
+	}
+	return name
+}
+
+// outputPath builds a timestamped path for a camera inside dir, creating dir
+// if needed.
+func outputPath(dir string, camera *CameraInstance, ext string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	name := fmt.Sprintf("%s_%s.%s", fileSafeName(camera.Info.Name), time.Now().Format("20060102_150405.000"), ext)
+	return filepath.Join(dir, name), nil
+}
+
+// saveSnapshot writes the last received frame of the camera in the snapshot
+// format. JPEG frames are saved as received; lossless formats save the
+// frame's full bit depth when the source has more than 8 bits.
+func saveSnapshot(camera *CameraInstance) (string, error) {
+	camera.FrameMutex.RLock()
+	frame, raw, sensor := camera.LastFrame, camera.LastRaw, camera.LastSensor
+	camera.FrameMutex.RUnlock()
+
+	if len(frame) == 0 && raw == nil {
+		return "", fmt.Errorf("no frame received from %s yet", camera.Info.Name)
+	}
+
+	format := snapshotFormat
+	if format == "raw" && sensor == nil {
+		format = "jpg"
+	}
+	path, err := outputPath(snapshotDir, camera, format)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case format == "raw":
+		err = writeRawSnapshot(path, sensor)
+	case format == "jpg" && len(frame) > 0:
+		err = writeOutputFile(path, frame)
+	default:
+		err = writeSnapshotImage(path, frame, raw)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := saveMetadataSidecar(path, camera); err != nil {
+		log.Printf("Snapshot of %s: %v", camera.Info.Name, err)
+		notify(toastWarning, tr("toast.sidecar_failed", camera.Info.Name, err))
+	}
+
+	log.Printf("Saved snapshot of %s to %s", camera.Info.Name, path)
+	queueUpload(path, metadataSidecarPath(path))
+	return path, nil
+}
+
+// writeSnapshotImage encodes a frame in the snapshot format, preferring the
+// full-depth image over decoding the JPEG data
+func writeSnapshotImage(path string, data []byte, raw image.Image) error {
+	img := raw
+	if img == nil {
+		var err error
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return err
+		}
+	}
+	f, err := createOutputFile(path)
+	if err != nil {
+		return err
+	}
+	switch snapshotFormat {
+	case "png":
+		err = png.Encode(f, img)
+	case "tiff":
+		err = tiff.Encode(f, img, &tiff.Options{Compression: tiff.Deflate})
+	default:
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 95})
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// startRecording begins recording the camera's frames to a new file
+func startRecording(camera *CameraInstance) error {
+	if camera.Recorder != nil {
+		return nil
+	}
+
+	ext := "mjpeg"
+	if videoEncoder != nil {
+		ext = "mp4"
+	}
+	path, err := outputPath(recordingDir, camera, ext)
+	if err != nil {
+		return err
+	}
+	file, err := createOutputFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recording: %w", err)
+	}
+	var video VideoEncoder
+	if videoEncoder != nil {
+		if video, err = startVideoEncoder(*videoEncoder, file); err != nil {
+			file.Close()
+			os.Remove(path)
+			return fmt.Errorf("failed to start the video encoder: %w", err)
+		}
+	}
+	header := recordingIndexHeader
+	if video != nil {
+		header = videoIndexHeader
+	}
+	index, err := os.Create(recordingIndexPath(path))
+	if err == nil {
+		_, err = index.WriteString(header)
+	}
+	if err != nil {
+		if video != nil {
+			video.Close()
+		}
+		file.Close()
+		if index != nil {
+			index.Close()
+		}
+		return fmt.Errorf("failed to create recording index: %w", err)
+	}
+
+	// A fragmented MP4 that was cut off needs no repair, but its marker
+	// still tells the mirror it is being written
+	marker, err := createRecordingMarker(path)
+	if err != nil {
+		log.Printf("Recording %s without crash recovery: %v", camera.Info.Name, err)
+	}
+	recorder := &Recorder{
+		file:      file,
+		video:     video,
+		index:     index,
+		marker:    marker,
+		Path:      path,
+		StartedAt: time.Now(),
+		synced:    time.Now(),
+	}
+	if metadata.Enabled {
+		if recorder.meta, err = os.Create(recordingMetadataPath(path)); err != nil {
+			log.Printf("Recording %s without metadata: %v", camera.Info.Name, err)
+		}
+	}
+	camera.Recorder = recorder
+	log.Printf("Recording %s to %s", camera.Info.Name, path)
+	return nil
+}
+
+// stopRecording finishes the camera's recording, if any
+func stopRecording(camera *CameraInstance) error {
+	recorder := camera.Recorder
+	if recorder == nil {
+		return nil
+	}
+	camera.Recorder = nil
+
+	var err error
+	if recorder.video != nil {
+		err = recorder.video.Close()
+	}
+	if fileErr := recorder.file.Close(); err == nil {
+		err = fileErr
+	}
+	if indexErr := recorder.index.Close(); err == nil {
+		err = indexErr
+	}
+	if recorder.meta != nil {
+		if metaErr := recorder.meta.Close(); err == nil {
+			err = metaErr
+		}
+	}
+	if err != nil {
+		// The marker stays, so the next start repairs the recording
+		if recorder.marker != nil {
+			recorder.marker.Close()
+		}
+		return fmt.Errorf("failed to close recording: %w", err)
+	}
+	if recorder.marker != nil {
+		removeRecordingMarker(recorder.Path)
+		recorder.marker.Close()
+	}
+	log.Printf("Stopped recording %s: %d frames in %s", camera.Info.Name, recorder.Frames,
+		time.Since(recorder.StartedAt).Round(time.Second))
+	queueUpload(recorder.Path, recordingIndexPath(recorder.Path), recordingMetadataPath(recorder.Path))
+	return nil
+}
+
+// WriteFrame appends a JPEG frame to the recording and its capture time to
+// the sidecar
+func (r *Recorder) WriteFrame(frame Frame) error {
+	size := len(frame.Data)
+	captured := fmt.Sprintf("%d,%s", frame.Captured.UnixNano(), frame.Captured.Format(time.RFC3339Nano))
+	var err error
+	if r.video != nil {
+		// The frame has no byte range of its own in the video
+		if err := r.video.WriteFrame(frame); err != nil {
+			return fmt.Errorf("failed to encode frame for %s: %w", r.Path, err)
+		}
+		size = 0
+		_, err = fmt.Fprintf(r.index, "%d,%s\n", r.Frames, captured)
+	} else {
+		if _, err := r.file.Write(frame.Data); err != nil {
+			return fmt.Errorf("failed to write frame to %s: %w", r.Path, err)
+		}
+		_, err = fmt.Fprintf(r.index, "%d,%d,%d,%s\n", r.Frames, r.offset, size, captured)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write frame time to %s: %w", recordingIndexPath(r.Path), err)
+	}
+	if r.meta != nil {
+		if fields, version := metadata.Fields(); version != r.metaVersion {
+			r.metaVersion = version
+			frameNumber := r.Frames
+			record, _ := json.Marshal(metadataRecord{Frame: &frameNumber, Time: frame.Captured, Metadata: fields})
+			if _, err := r.meta.Write(append(record, '\n')); err != nil {
+				return fmt.Errorf("failed to write metadata for %s: %w", r.Path, err)
+			}
+		}
+	}
+	r.offset += int64(size)
+	r.Frames++
+	if time.Since(r.synced) >= recordingSyncInterval {
+		r.synced = time.Now()
+		// ffmpeg writes the video itself, from another goroutine
+		if r.video != nil {
+			_ = r.index.Sync()
+			return nil
+		}
+		if err := r.file.Sync(); err != nil {
+			return fmt.Errorf("failed to flush %s: %w", r.Path, err)
+		}
+		_ = r.index.Sync()
+	}
+	return nil
+}
+
+// toggleRecordingAll starts recording every streaming camera at once, or
+// stops all recordings if any is running. The sidecars of recordings started
+// together share the same clock for aligning them afterwards.
+func toggleRecordingAll(appData *CameraAppData) {
+	stopped := 0
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if camera.Recorder == nil {
+			continue
+		}
+		if err := stopRecording(camera); err != nil {
+			setErrorStatus(appData, err)
+			return
+		}
+		stopped++
+	}
+	if stopped > 0 {
+		appData.StatusText = tr("status.rec_all_stopped", stopped)
+		return
+	}
+
+	started := 0
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if !camera.Active || camera.Disabled {
+			continue
+		}
+		if err := startRecording(camera); err != nil {
+			setErrorStatus(appData, err)
+			return
+		}
+		started++
+	}
+	appData.StatusText = tr("status.rec_all", started)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Recordings are MJPEG streams with a frame index next to them, written as
+// frames arrive, so what a crash or power loss leaves behind is nearly
+// complete: at worst the last frame is cut off and the index is a line
+// behind or ends in half a line. A marker file exists while a recording is
+// open, locked by the instance writing it and holding its PID; at startup,
+// recordings whose marker is left and no longer locked are trimmed back to
+// the last frame that is both complete and indexed. The markers of another
+// instance recording into the same directory stay locked and are left
+// alone. H.264 recordings, fragmented MP4s that play however they end, get
+// a marker too, which the mirror waits for, and lose it without a repair.
+
+// recordingMarkerSuffix is appended to a recording's path for its marker
+const recordingMarkerSuffix = ".recording"
+
+// recordingSyncInterval is how often an open recording is flushed to disk,
+// bounding what a power loss can take
+const recordingSyncInterval = 2 * time.Second
+
+// createRecordingMarker marks a recording as open, returning the marker,
+// which stays locked until it is closed
+func createRecordingMarker(path string) (*os.File, error) {
+	marker, err := os.OpenFile(path+recordingMarkerSuffix, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(marker.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		marker.Close()
+		return nil, err
+	}
+	if _, err := marker.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		marker.Close()
+		return nil, err
+	}
+	return marker, nil
+}
+
+// lockRecordingMarker locks the marker of a recording nobody is writing,
+// failing with EWOULDBLOCK while another instance holds it
+func lockRecordingMarker(marker string) (*os.File, error) {
+	file, err := os.Open(marker)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// removeRecordingMarker marks a recording as cleanly closed
+func removeRecordingMarker(path string) {
+	if err := os.Remove(path + recordingMarkerSuffix); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove %s: %v", path+recordingMarkerSuffix, err)
+	}
+}
+
+// recoverRecordings repairs the recordings in dir that were not closed
+func recoverRecordings(dir string) {
+	markers, _ := filepath.Glob(filepath.Join(dir, "*"+recordingMarkerSuffix))
+	for _, marker := range markers {
+		path := strings.TrimSuffix(marker, recordingMarkerSuffix)
+		lock, err := lockRecordingMarker(marker)
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			owner, _ := os.ReadFile(marker)
+			log.Printf("Recording %s is still being written by PID %s", path, owner)
+			continue
+		} else if err != nil {
+			log.Printf("Failed to recover recording %s: %v", path, err)
+			continue
+		}
+		if videoRecording(path) {
+			log.Printf("Interrupted H.264 recording %s needs no repair", path)
+			removeRecordingMarker(path)
+			lock.Close()
+			continue
+		}
+		frames, err := recoverRecording(path)
+		if err != nil {
+			log.Printf("Failed to recover recording %s: %v", path, err)
+			lock.Close()
+			continue
+		}
+		log.Printf("Recovered interrupted recording %s: %d frames", path, frames)
+		removeRecordingMarker(path)
+		lock.Close()
+	}
+}
+
+// videoRecording reports whether a recording's index is an H.264 one
+func videoRecording(path string) bool {
+	file, err := os.Open(recordingIndexPath(path))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header := make([]byte, len(videoIndexHeader))
+	_, err = io.ReadFull(file, header)
+	return err == nil && string(header) == videoIndexHeader
+}
+
+// recoverRecording trims a recording, its index and its metadata to the
+// frames that made it to disk whole, returning how many there are
+func recoverRecording(path string) (int, error) {
+	data, err := readInputFile(path)
+	if err != nil {
+		return 0, err
+	}
+	indexPath := recordingIndexPath(path)
+	index, err := os.ReadFile(indexPath)
+	if err != nil {
+		return 0, err
+	}
+
+	if bytes.HasPrefix(index, []byte(videoIndexHeader)) {
+		return 0, fmt.Errorf("%s: %w", path, errVideoRecording)
+	}
+
+	// Keep the index lines up to the first that is cut off or points at a
+	// frame that is not all there
+	valid := []byte(recordingIndexHeader)
+	frames := 0
+	end := 0
+	lines := bytes.SplitAfter(bytes.TrimPrefix(index, []byte(recordingIndexHeader)), []byte("\n"))
+	for _, line := range lines {
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			break
+		}
+		fields := strings.Split(strings.TrimSpace(string(line)), ",")
+		if len(fields) != 5 {
+			break
+		}
+		offset, errOffset := strconv.Atoi(fields[1])
+		size, errSize := strconv.Atoi(fields[2])
+		if errOffset != nil || errSize != nil || offset != end || size < 4 || offset+size > len(data) {
+			break
+		}
+		frame := data[offset : offset+size]
+		if !bytes.HasPrefix(frame, []byte{0xff, 0xd8}) || !bytes.HasSuffix(frame, []byte{0xff, 0xd9}) {
+			break
+		}
+		valid = append(valid, line...)
+		end = offset + size
+		frames++
+	}
+
+	if isEncryptedFile(path) {
+		// Sealed chunks cannot be cut, so the frames are sealed again
+		if err := writeOutputFile(path+".tmp", data[:end]); err != nil {
+			return 0, err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return 0, err
+		}
+	} else if err := os.Truncate(path, int64(end)); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(indexPath, valid, 0o644); err != nil {
+		return 0, err
+	}
+	if err := recoverMetadata(recordingMetadataPath(path), frames); err != nil {
+		return 0, fmt.Errorf("metadata: %w", err)
+	}
+	return frames, nil
+}
+
+// recoverMetadata drops a metadata log's cut-off last line and the records
+// of frames that were trimmed from the recording
+func recoverMetadata(path string, frames int) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var kept []byte
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A line without its newline was cut off
+			break
+		}
+		var record struct {
+			Frame *int `json:"frame"`
+		}
+		if jsonErr := json.Unmarshal(line, &record); jsonErr != nil || record.Frame != nil && *record.Frame >= frames {
+			break
+		}
+		kept = append(kept, line...)
+	}
+	file.Close()
+	return os.WriteFile(path, kept, 0o644)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// A replay file holds the frames of a session as the sources delivered them,
+// before any conversion, so a bug seen with a camera can be reproduced
+// without it. After the header come records of
+//
+//	kind byte, camera uint16, time int64 (Unix ns), length uint32, payload
+//
+// where a camera record's payload describes the camera in JSON and comes
+// before its first frame, and a frame record's payload is the raw frame.
+
+const (
+	// replayPrefix marks the path of a camera played back from a replay file
+	replayPrefix = "replay:"
+	// replayHeader starts every replay file
+	replayHeader = "go-camApp replay 1\n"
+	// replayFlushInterval bounds what a crash loses of a replay recording
+	replayFlushInterval = time.Second
+
+	replayCameraRecord = 'C'
+	replayFrameRecord  = 'F'
+	replayRecordHeader = 15
+)
+
+// replayCamera describes a recorded camera
+type replayCamera struct {
+	Name   string
+	Path   string
+	Width  int
+	Height int
+	// Format is the V4L2 format of raw frames, zero for JPEG sources
+	Format v4l2.PixFormat
+}
+
+// replayWriter records frames to a replay file
+type replayWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	cameras map[string]uint16
+	err     error
+	done    chan struct{}
+}
+
+// replayRecorder is the recording started with -replay-record, nil if none
+var replayRecorder *replayWriter
+
+// startReplayRecording creates a replay file at path and records every frame
+// delivered by a camera to it until stopReplayRecording
+func startReplayRecording(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := &replayWriter{file: file, buf: bufio.NewWriterSize(file, 1<<20), cameras: map[string]uint16{}, done: make(chan struct{})}
+	if _, err := w.buf.WriteString(replayHeader); err != nil {
+		file.Close()
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(replayFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.mu.Lock()
+				if w.err == nil {
+					w.err = w.buf.Flush()
+				}
+				w.mu.Unlock()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	replayRecorder = w
+	log.Printf("Recording a replay to %s", path)
+	return nil
+}
+
+// stopReplayRecording writes out and closes the replay recording
+func stopReplayRecording() {
+	w := replayRecorder
+	if w == nil {
+		return
+	}
+	replayRecorder = nil
+	close(w.done)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = w.buf.Flush()
+	}
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		log.Printf("Error writing replay %s: %v", w.file.Name(), w.err)
+	}
+}
+
+// recordReplayFrame adds a frame as the camera delivered it to the replay
+// recording, if there is one. Frames of V4L2 cameras are recorded raw, with
+// the camera's format.
+func recordReplayFrame(camera *CameraInstance, data []byte, captured time.Time) {
+	w := replayRecorder
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	id, ok := w.cameras[camera.Info.Path]
+	if !ok {
+		id = uint16(len(w.cameras))
+		w.cameras[camera.Info.Path] = id
+		description := replayCamera{Name: camera.Info.Name, Path: camera.Info.Path, Width: camera.Width, Height: camera.Height}
+		if camera.Device != nil || isReplaySource(camera) {
+			description.Format = camera.PixFormat
+		}
+		payload, err := json.Marshal(description)
+		if err != nil {
+			w.err = err
+			return
+		}
+		w.writeRecord(replayCameraRecord, id, captured, payload)
+	}
+	w.writeRecord(replayFrameRecord, id, captured, data)
+}
+
+func (w *replayWriter) writeRecord(kind byte, camera uint16, at time.Time, payload []byte) {
+	var header [replayRecordHeader]byte
+	header[0] = kind
+	binary.LittleEndian.PutUint16(header[1:], camera)
+	binary.LittleEndian.PutUint64(header[3:], uint64(at.UnixNano()))
+	binary.LittleEndian.PutUint32(header[11:], uint32(len(payload)))
+	if _, err := w.buf.Write(header[:]); err != nil {
+		w.err = err
+		return
+	}
+	if _, err := w.buf.Write(payload); err != nil {
+		w.err = err
+	}
+}
+
+// replayFrame locates a recorded frame in the replay file
+type replayFrame struct {
+	at     time.Duration
+	offset int64
+	size   int
+}
+
+// replayFile is a replay file opened for playback
+type replayFile struct {
+	file    *os.File
+	cameras []replayCamera
+	frames  [][]replayFrame
+	// start is when playback began, shared by all cameras so they keep
+	// their recorded timing to one another
+	startOnce sync.Once
+	start     time.Time
+	finished  atomic.Int32
+}
+
+// replay is the file played back with -replay, nil if none
+var replay *replayFile
+
+// openReplay opens a replay file and indexes its frames. A recording cut
+// off by a crash plays up to its last complete frame.
+func openReplay(path string) (*replayFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &replayFile{file: file}
+	reader := bufio.NewReader(file)
+	header := make([]byte, len(replayHeader))
+	if _, err := io.ReadFull(reader, header); err != nil || string(header) != replayHeader {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a replay file", path)
+	}
+
+	offset := int64(len(replayHeader))
+	var first time.Time
+records:
+	for {
+		var record [replayRecordHeader]byte
+		if _, err := io.ReadFull(reader, record[:]); err != nil {
+			break
+		}
+		id := int(binary.LittleEndian.Uint16(record[1:]))
+		at := time.Unix(0, int64(binary.LittleEndian.Uint64(record[3:])))
+		size := int(binary.LittleEndian.Uint32(record[11:]))
+		offset += replayRecordHeader
+		if first.IsZero() {
+			first = at
+		}
+
+		switch record[0] {
+		case replayCameraRecord:
+			payload := make([]byte, size)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				break records
+			}
+			var camera replayCamera
+			if err := json.Unmarshal(payload, &camera); err != nil || id != len(r.cameras) {
+				file.Close()
+				return nil, fmt.Errorf("%s: damaged camera record at %d", path, offset)
+			}
+			r.cameras = append(r.cameras, camera)
+			r.frames = append(r.frames, nil)
+		case replayFrameRecord:
+			if _, err := reader.Discard(size); err != nil {
+				break records
+			}
+			if id >= len(r.cameras) {
+				file.Close()
+				return nil, fmt.Errorf("%s: frame of unknown camera %d at %d", path, id, offset)
+			}
+			r.frames[id] = append(r.frames[id], replayFrame{at: at.Sub(first), offset: offset, size: size})
+		default:
+			file.Close()
+			return nil, fmt.Errorf("%s: damaged record at %d", path, offset)
+		}
+		offset += int64(size)
+	}
+	if len(r.cameras) == 0 {
+		file.Close()
+		return nil, fmt.Errorf("%s holds no frames", path)
+	}
+	return r, nil
+}
+
+// isReplaySource reports whether a camera is played back from a replay file
+func isReplaySource(camera *CameraInstance) bool {
+	return strings.HasPrefix(camera.Info.Path, replayPrefix)
+}
+
+// findReplayCameras returns the cameras of the replay file as camera entries
+func findReplayCameras(firstIndex int) []CameraInfo {
+	cameras := make([]CameraInfo, 0, len(replay.cameras))
+	for i, camera := range replay.cameras {
+		cameras = append(cameras, CameraInfo{
+			Path:  replayPrefix + strconv.Itoa(i),
+			Name:  tr("camera.replay", camera.Name),
+			Index: firstIndex + i,
+		})
+	}
+	return cameras
+}
+
+// replayCameraIndex returns the index of a replay camera in the replay file
+func replayCameraIndex(camera *CameraInstance) (int, error) {
+	i, err := strconv.Atoi(strings.TrimPrefix(camera.Info.Path, replayPrefix))
+	if err != nil || replay == nil || i < 0 || i >= len(replay.cameras) {
+		return 0, fmt.Errorf("no replay camera %s", camera.Info.Path)
+	}
+	return i, nil
+}
+
+// initReplayCamera sets a replay camera up as the recorded camera was
+func initReplayCamera(camera *CameraInstance, renderer *sdl.Renderer) error {
+	i, err := replayCameraIndex(camera)
+	if err != nil {
+		return err
+	}
+	recorded := replay.cameras[i]
+	camera.PixFormat = recorded.Format
+	camera.Width, camera.Height = recorded.Width, recorded.Height
+	if camera.Width == 0 || camera.Height == 0 {
+		camera.Width, camera.Height = 640, 480
+	}
+	if err := createCameraTextures(camera, renderer); err != nil {
+		return err
+	}
+
+	camera.Active = true
+	camera.FrameChan = make(chan Frame, 10)
+	log.Printf("Replaying %d frames of %s (%dx%d)", len(replay.frames[i]), recorded.Name, camera.Width, camera.Height)
+	return nil
+}
+
+// captureReplayFrames feeds the recorded frames of a replay camera to the
+// pipeline at their recorded times, converting raw frames like the capture
+// goroutine of a V4L2 camera does
+func captureReplayFrames(camera *CameraInstance) {
+	i, err := replayCameraIndex(camera)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	r := replay
+	r.startOnce.Do(func() { r.start = time.Now() })
+
+	for _, recorded := range r.frames[i] {
+		if wait := time.Until(r.start.Add(recorded.at)); wait > 0 {
+			time.Sleep(wait)
+		}
+		if !camera.Active {
+			return
+		}
+		if skipForMemory(camera) {
+			atomic.AddUint64(&camera.DroppedFrames, 1)
+			continue
+		}
+		data := make([]byte, recorded.size)
+		if _, err := r.file.ReadAt(data, recorded.offset); err != nil {
+			log.Printf("Error reading replay frame of %s: %v", camera.Info.Name, err)
+			return
+		}
+
+		next := Frame{Data: data}
+		if camera.PixFormat.PixelFormat != 0 {
+			if next, err = deviceFrame(camera, data); err != nil {
+				log.Printf("Error converting frame from %s: %v", camera.Info.Name, err)
+				atomic.AddUint64(&camera.DroppedFrames, 1)
+				continue
+			}
+		}
+		next.Captured = time.Now()
+		select {
+		case camera.FrameChan <- next:
+		default:
+			atomic.AddUint64(&camera.DroppedFrames, 1)
+		}
+	}
+
+	if int(r.finished.Add(1)) == len(r.cameras) {
+		log.Printf("Replay finished after %s", time.Since(r.start).Round(time.Millisecond))
+	}
+	// Stay open on the last frame until the camera is stopped
+	for camera.Active {
+		time.Sleep(folderPollInterval)
+	}
+}
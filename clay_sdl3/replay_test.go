@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+func TestReplayRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.replay")
+	if err := startReplayRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stopReplayRecording)
+
+	const frames = 5
+	dev := newFakeDevice(frames)
+	delete(dev.Sizes, v4l2.PixelFmtMJPEG)
+	camera := startFakeCamera(t, dev)
+	recorded := receiveFrames(t, camera, frames)
+	stopReplayRecording()
+
+	r, err := openReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { replay = nil }()
+	replay = r
+	defer r.file.Close()
+	if len(r.cameras) != 1 || len(r.frames[0]) != frames {
+		t.Fatalf("replay has %d cameras, want 1 with %d frames", len(r.cameras), frames)
+	}
+	if r.cameras[0].Format.PixelFormat != v4l2.PixelFmtYUYV {
+		t.Errorf("recorded format %s, want YUYV", formatName(r.cameras[0].Format.PixelFormat))
+	}
+
+	// Played back, the raw frames convert to what the camera delivered
+	played := &CameraInstance{Info: findReplayCameras(0)[0], PixFormat: r.cameras[0].Format, Active: true, FrameChan: make(chan Frame, frames)}
+	start := time.Now()
+	// Once played, the camera idles on its last frame until the test ends
+	go captureReplayFrames(played)
+	got := receiveFrames(t, played, frames)
+	for i := range got {
+		if !bytes.Equal(toRGBA(got[i].Raw).Pix, toRGBA(recorded[i].Raw).Pix) {
+			t.Errorf("frame %d differs from the recorded one", i)
+		}
+	}
+	if last := r.frames[0][frames-1].at; time.Since(start) < last {
+		t.Errorf("played %d frames in %s, recorded over %s", frames, time.Since(start), last)
+	}
+
+	// A recording cut off mid-frame plays up to the last complete frame
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	cut, err := openReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut.file.Close()
+	if len(cut.frames[0]) != frames-1 {
+		t.Errorf("cut replay has %d frames, want %d", len(cut.frames[0]), frames-1)
+	}
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/websocket"
+)
+
+// The ROS 2 bridge makes the cameras ROS topics for robotics users, through
+// rosbridge_server (ros-<distro>-rosbridge-server) rather than linking ROS
+// itself: -ros-bridge ws://robot:9090 connects to it and, for each camera
+// under -ros-namespace, publishes
+//
+//	NS/NAME/image_raw/compressed  sensor_msgs/msg/CompressedImage (JPEG)
+//	NS/NAME/image_raw             sensor_msgs/msg/Image (rgb8, with -ros-raw)
+//	NS/NAME/camera_info           sensor_msgs/msg/CameraInfo
+//
+// and offers the services
+//
+//	NS/NAME/snapshot        std_srvs/srv/Trigger, save a snapshot
+//	NS/NAME/record          std_srvs/srv/SetBool, start or stop recording
+//	NS/NAME/set_parameters  rcl_interfaces/srv/SetParameters, set V4L2
+//	                        controls by name, e.g. "brightness"
+//
+// The camera info carries the frame size only, which ROS takes as an
+// uncalibrated camera. Frames are dropped while the bridge falls behind, and
+// the connection is retried every rosRetry.
+
+const (
+	// rosRetry is how long a lost bridge connection waits before
+	// connecting again
+	rosRetry = 5 * time.Second
+	// rosWriteTimeout bounds sending a message to the bridge
+	rosWriteTimeout = 10 * time.Second
+)
+
+var (
+	// rosNamespace prefixes the topics and services, set with
+	// -ros-namespace
+	rosNamespace = "/camapp"
+	// rosRaw also publishes decoded frames, set with -ros-raw
+	rosRaw bool
+)
+
+// rosTime is a builtin_interfaces/msg/Time
+type rosTime struct {
+	Sec     int64 `json:"sec"`
+	Nanosec int64 `json:"nanosec"`
+}
+
+// rosHeader is a std_msgs/msg/Header
+type rosHeader struct {
+	Stamp   rosTime `json:"stamp"`
+	FrameID string  `json:"frame_id"`
+}
+
+// rosCompressedImage is a sensor_msgs/msg/CompressedImage; rosbridge takes
+// byte arrays as base64, as encoding/json writes them
+type rosCompressedImage struct {
+	Header rosHeader `json:"header"`
+	Format string    `json:"format"`
+	Data   []byte    `json:"data"`
+}
+
+// rosImage is a sensor_msgs/msg/Image
+type rosImage struct {
+	Header      rosHeader `json:"header"`
+	Height      int       `json:"height"`
+	Width       int       `json:"width"`
+	Encoding    string    `json:"encoding"`
+	IsBigendian int       `json:"is_bigendian"`
+	Step        int       `json:"step"`
+	Data        []byte    `json:"data"`
+}
+
+// rosCameraInfo is a sensor_msgs/msg/CameraInfo of an uncalibrated camera
+type rosCameraInfo struct {
+	Header          rosHeader   `json:"header"`
+	Height          int         `json:"height"`
+	Width           int         `json:"width"`
+	DistortionModel string      `json:"distortion_model"`
+	D               []float64   `json:"d"`
+	K               [9]float64  `json:"k"`
+	R               [9]float64  `json:"r"`
+	P               [12]float64 `json:"p"`
+}
+
+// rosParameter is an rcl_interfaces/msg/Parameter; only integer, bool and
+// double values can set controls
+type rosParameter struct {
+	Name  string `json:"name"`
+	Value struct {
+		Type         int     `json:"type"`
+		BoolValue    bool    `json:"bool_value"`
+		IntegerValue int64   `json:"integer_value"`
+		DoubleValue  float64 `json:"double_value"`
+	} `json:"value"`
+}
+
+// rcl_interfaces/msg/ParameterType values
+const (
+	rosParameterBool    = 1
+	rosParameterInteger = 2
+	rosParameterDouble  = 3
+)
+
+// rosMessage is a message of the rosbridge protocol
+type rosMessage struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Service string          `json:"service,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Msg     any             `json:"msg,omitempty"`
+	Args    json.RawMessage `json:"args,omitempty"`
+	Values  any             `json:"values,omitempty"`
+	Result  *bool           `json:"result,omitempty"`
+}
+
+// rosBridge is a connection to rosbridge_server
+type rosBridge struct {
+	api     *APIServer
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	// names are the ROS names of the cameras by ID
+	names map[int]string
+}
+
+// startROSBridge publishes the cameras through a rosbridge_server,
+// reconnecting whenever the connection is lost
+func startROSBridge(address string) error {
+	if err := startAPI(); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			if err := runROSBridge(api, address); err != nil {
+				log.Printf("ROS bridge: %v", redactSecrets(err.Error()))
+			}
+			time.Sleep(rosRetry)
+		}
+	}()
+	return nil
+}
+
+// runROSBridge publishes the cameras until the connection fails or the
+// cameras change, which changes the topics
+func runROSBridge(s *APIServer, address string) error {
+	events := s.subscribe()
+	defer s.unsubscribe(events)
+	var cameras []apiCamera
+	for len(cameras) == 0 {
+		event, ok := <-events.events
+		if !ok {
+			return errors.New("fell behind the camera events")
+		}
+		if event.Type == "cameras" {
+			cameras = event.Data.([]apiCamera)
+		}
+	}
+
+	conn, err := websocket.Dial(address, "", "http://localhost/")
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+	b := &rosBridge{api: s, conn: conn}
+	b.names = rosNames(cameras)
+	named := make(map[int]string)
+	for _, camera := range cameras {
+		named[camera.ID] = camera.Name
+	}
+	for _, camera := range cameras {
+		base := rosNamespace + "/" + b.names[camera.ID]
+		messages := []rosMessage{
+			{Op: "advertise", Topic: base + "/image_raw/compressed", Type: "sensor_msgs/msg/CompressedImage"},
+			{Op: "advertise", Topic: base + "/camera_info", Type: "sensor_msgs/msg/CameraInfo"},
+			{Op: "advertise_service", Service: base + "/snapshot", Type: "std_srvs/srv/Trigger"},
+			{Op: "advertise_service", Service: base + "/record", Type: "std_srvs/srv/SetBool"},
+			{Op: "advertise_service", Service: base + "/set_parameters", Type: "rcl_interfaces/srv/SetParameters"},
+		}
+		if rosRaw {
+			messages = append(messages, rosMessage{Op: "advertise", Topic: base + "/image_raw", Type: "sensor_msgs/msg/Image"})
+		}
+		for _, message := range messages {
+			if err := b.send(message); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("Publishing %d cameras to ROS through %s", len(cameras), redactSecrets(address))
+
+	done := make(chan struct{})
+	defer close(done)
+	for _, camera := range cameras {
+		go b.publishFrames(camera, done)
+	}
+	lost := make(chan error, 1)
+	go func() {
+		for {
+			var message rosMessage
+			if err := websocket.JSON.Receive(conn, &message); err != nil {
+				lost <- fmt.Errorf("connection lost: %w", err)
+				return
+			}
+			if message.Op == "call_service" {
+				go b.callService(message)
+			}
+		}
+	}()
+	for {
+		select {
+		case err := <-lost:
+			return err
+		case event, ok := <-events.events:
+			if !ok {
+				return errors.New("fell behind the camera events")
+			}
+			camera, isCamera := event.Data.(apiCamera)
+			if event.Type == "cameras" || isCamera && camera.Name != named[camera.ID] {
+				log.Printf("ROS bridge: the cameras changed, advertising them again")
+				return nil
+			}
+		}
+	}
+}
+
+// rosName turns a name into one valid in ROS: lower case letters, digits
+// and underscores, not starting with a digit
+func rosName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, name)
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "camera"
+	}
+	if unicode.IsDigit(rune(name[0])) {
+		return "camera_" + name
+	}
+	return name
+}
+
+// rosNames gives the cameras unique ROS names by ID
+func rosNames(cameras []apiCamera) map[int]string {
+	names := make(map[int]string)
+	used := make(map[string]bool)
+	for _, camera := range cameras {
+		name := rosName(camera.Name)
+		if used[name] {
+			name = fmt.Sprintf("%s_%d", name, camera.ID)
+		}
+		used[name] = true
+		names[camera.ID] = name
+	}
+	return names
+}
+
+// send writes a message to the bridge
+func (b *rosBridge) send(message rosMessage) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	_ = b.conn.SetWriteDeadline(time.Now().Add(rosWriteTimeout))
+	return websocket.JSON.Send(b.conn, message)
+}
+
+// publishFrames publishes the frames of a camera until done is closed
+func (b *rosBridge) publishFrames(camera apiCamera, done chan struct{}) {
+	client := newStreamClient()
+	client.fixed = true
+	b.api.addStream(camera.ID, client)
+	defer b.api.removeStream(camera.ID, client)
+
+	name := b.names[camera.ID]
+	base := rosNamespace + "/" + name
+	for {
+		select {
+		case <-done:
+			return
+		case frame := <-client.frames:
+			header := rosHeader{
+				Stamp:   rosTime{Sec: frame.Captured.Unix(), Nanosec: int64(frame.Captured.Nanosecond())},
+				FrameID: name,
+			}
+			err := b.send(rosMessage{Op: "publish", Topic: base + "/image_raw/compressed", Msg: rosCompressedImage{
+				Header: header, Format: "jpeg", Data: frame.Data,
+			}})
+			config, configErr := jpeg.DecodeConfig(bytes.NewReader(frame.Data))
+			if err == nil && configErr == nil {
+				err = b.send(rosMessage{Op: "publish", Topic: base + "/camera_info", Msg: rosCameraInfo{
+					Header: header, Height: config.Height, Width: config.Width, DistortionModel: "plumb_bob", D: []float64{},
+				}})
+			}
+			if err == nil && rosRaw {
+				if image, decodeErr := rosRGBImage(header, frame.Data); decodeErr == nil {
+					err = b.send(rosMessage{Op: "publish", Topic: base + "/image_raw", Msg: image})
+				}
+			}
+			if err != nil {
+				// The receiving side notices the broken connection
+				b.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// rosRGBImage decodes a JPEG frame into an rgb8 image message
+func rosRGBImage(header rosHeader, data []byte) (rosImage, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return rosImage{}, err
+	}
+	bounds := img.Bounds()
+	message := rosImage{
+		Header: header, Height: bounds.Dy(), Width: bounds.Dx(), Encoding: "rgb8", Step: bounds.Dx() * 3,
+		Data: make([]byte, 0, bounds.Dx()*bounds.Dy()*3),
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			message.Data = append(message.Data, byte(r>>8), byte(g>>8), byte(b>>8))
+		}
+	}
+	return message, nil
+}
+
+// callService answers a service call of the bridge
+func (b *rosBridge) callService(call rosMessage) {
+	values, err := b.serve(call)
+	ok := err == nil
+	if err != nil {
+		values = map[string]any{"success": false, "message": err.Error()}
+	}
+	if err := b.send(rosMessage{Op: "service_response", ID: call.ID, Service: call.Service, Values: values, Result: &ok}); err != nil {
+		log.Printf("ROS bridge: failed to answer %s: %v", call.Service, err)
+	}
+}
+
+// serve runs a service call and returns the response values
+func (b *rosBridge) serve(call rosMessage) (any, error) {
+	rest, _ := strings.CutPrefix(call.Service, rosNamespace+"/")
+	name, service, _ := strings.Cut(rest, "/")
+	var camera apiCamera
+	found := false
+	for id, cameraName := range b.names {
+		if cameraName == name {
+			camera, found = b.api.cameraByID(id)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown service %s", call.Service)
+	}
+
+	switch service {
+	case "snapshot":
+		path, err := b.api.snapshot(camera)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"success": true, "message": path}, nil
+	case "record":
+		var args struct {
+			Data bool `json:"data"`
+		}
+		if err := json.Unmarshal(call.Args, &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+		path, err := b.api.record(camera.ID, args.Data)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"success": true, "message": path}, nil
+	case "set_parameters":
+		var args struct {
+			Parameters []rosParameter `json:"parameters"`
+		}
+		if err := json.Unmarshal(call.Args, &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+		return b.setParameters(camera.ID, args.Parameters)
+	}
+	return nil, fmt.Errorf("unknown service %s", call.Service)
+}
+
+// setParameters sets the controls named by parameters, reporting the
+// outcome of each
+func (b *rosBridge) setParameters(id int, parameters []rosParameter) (any, error) {
+	controls, err := b.api.controls(id)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		Successful bool   `json:"successful"`
+		Reason     string `json:"reason"`
+	}
+	results := make([]result, 0, len(parameters))
+	for _, parameter := range parameters {
+		var value int32
+		switch parameter.Value.Type {
+		case rosParameterBool:
+			if parameter.Value.BoolValue {
+				value = 1
+			}
+		case rosParameterInteger:
+			value = int32(parameter.Value.IntegerValue)
+		case rosParameterDouble:
+			value = int32(parameter.Value.DoubleValue)
+		default:
+			results = append(results, result{Reason: "controls take integer, bool or double values"})
+			continue
+		}
+		err := errors.New("no such control")
+		for _, ctrl := range controls {
+			if rosName(ctrl.Name) == parameter.Name {
+				err = b.api.setControl(id, ctrl.ID, value)
+				break
+			}
+		}
+		if err != nil {
+			results = append(results, result{Reason: err.Error()})
+			continue
+		}
+		results = append(results, result{Successful: true})
+	}
+	return map[string]any{"results": results}, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+	"golang.org/x/net/websocket"
+)
+
+func TestROSNames(t *testing.T) {
+	names := rosNames([]apiCamera{{ID: 0, Name: "Bench Cam"}, {ID: 1, Name: "bench-cam"}, {ID: 2, Name: "2nd"}, {ID: 3, Name: "äö"}})
+	want := map[int]string{0: "bench_cam", 1: "bench_cam_1", 2: "camera_2nd", 3: "camera"}
+	for id, name := range want {
+		if names[id] != name {
+			t.Errorf("camera %d named %q, expected %q", id, names[id], name)
+		}
+	}
+}
+
+func TestROSBridge(t *testing.T) {
+	dev := newFakeDevice(0)
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "Bench Cam"}, Device: dev, Active: true}}}
+	s := newAPIServer()
+	serveAPIServer(t, s, appData)
+
+	received := make(chan rosMessage, 64)
+	calls := make(chan rosMessage, 1)
+	bridge := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		go func() {
+			for call := range calls {
+				_ = websocket.JSON.Send(ws, call)
+			}
+		}()
+		for {
+			var message rosMessage
+			if err := websocket.JSON.Receive(ws, &message); err != nil {
+				return
+			}
+			select {
+			case received <- message:
+			default:
+			}
+		}
+	}))
+	t.Cleanup(bridge.Close)
+	t.Cleanup(func() { close(calls) })
+	go runROSBridge(s, "ws"+strings.TrimPrefix(bridge.URL, "http"))
+
+	next := func(op string) rosMessage {
+		t.Helper()
+		for {
+			select {
+			case message := <-received:
+				if message.Op == op {
+					return message
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no %s message", op)
+			}
+		}
+	}
+	if advertised := next("advertise"); advertised.Topic != "/camapp/bench_cam/image_raw/compressed" {
+		t.Errorf("advertised %+v", advertised)
+	}
+
+	// Frames go out once the bridge watches the camera
+	var frame bytes.Buffer
+	if err := jpeg.Encode(&frame, image.NewGray(image.Rect(0, 0, 32, 24)), nil); err != nil {
+		t.Fatal(err)
+	}
+	for !s.Watched(0) {
+		time.Sleep(time.Millisecond)
+	}
+	s.Publish(0, &appData.Cameras[0], Frame{Data: frame.Bytes(), Captured: time.Now()})
+	published := next("publish")
+	if published.Topic != "/camapp/bench_cam/image_raw/compressed" {
+		t.Errorf("published %+v", published)
+	}
+	info := next("publish")
+	if data, _ := json.Marshal(info.Msg); info.Topic != "/camapp/bench_cam/camera_info" || !strings.Contains(string(data), `"width":32`) {
+		t.Errorf("camera info %s %s", info.Topic, data)
+	}
+
+	calls <- rosMessage{Op: "call_service", ID: "1", Service: "/camapp/bench_cam/set_parameters",
+		Args: json.RawMessage(`{"parameters": [{"name": "brightness", "value": {"type": 2, "integer_value": 42}}, {"name": "zoom", "value": {"type": 2}}]}`)}
+	response := next("service_response")
+	if data, _ := json.Marshal(response.Values); response.ID != "1" || !strings.Contains(string(data), `{"reason":"","successful":true},{"reason":"no such control","successful":false}`) {
+		t.Errorf("response %s", data)
+	}
+	if value, _ := dev.GetControlValue(v4l2.CtrlBrightness); value != 42 {
+		t.Errorf("brightness %d", value)
+	}
+}
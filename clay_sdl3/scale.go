@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/Zyko0/go-sdl3/ttf"
+)
+
+// baseFontSize is the font size in points at a UI scale of 1
+const baseFontSize = 14
+
+// The layout works in physical pixels. uiScale converts the sizes written in
+// the layout code into pixels, and pixelDensity converts window coordinates
+// (used by mouse events) into pixels.
+var (
+	uiScale         float32 = 1
+	uiScaleOverride float32
+	pixelDensity    float32 = 1
+)
+
+// dp scales a size from the layout code to pixels
+func dp(v float32) float32 {
+	return v * uiScale
+}
+
+// dpu scales a padding, gap or border width to pixels
+func dpu(v uint16) uint16 {
+	return uint16(float32(v)*uiScale + 0.5)
+}
+
+// updateUIScale re-reads the window's pixel density and display scale,
+// resizing the font to match. A non-zero uiScaleOverride replaces the
+// detected display scale.
+func updateUIScale(window *sdl.Window, font *ttf.Font) {
+	density, err := window.PixelDensity()
+	if err != nil || density <= 0 {
+		density = 1
+	}
+	pixelDensity = density
+
+	scale := uiScaleOverride
+	if scale <= 0 {
+		// DisplayScale combines the pixel density with the desktop's
+		// content scale, e.g. 2.0 on a 4K display set to 200%
+		scale, err = window.DisplayScale()
+		if err != nil || scale <= 0 {
+			scale = density
+		}
+	}
+	uiScale = scale
+
+	if err := font.SetSize(baseFontSize * uiScale); err != nil {
+		log.Printf("Failed to resize font for UI scale %.2f: %v", uiScale, err)
+	}
+	clay.ResetMeasureTextCache()
+	textCache.Clear()
+	log.Printf("UI scale %.2f, pixel density %.2f", uiScale, pixelDensity)
+}
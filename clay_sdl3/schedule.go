@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scheduled actions run at times given as cron expressions, for unattended
+// cameras: a snapshot every hour, recording from 8:00 to 18:00 on weekdays,
+// cameras disabled overnight. They are read from -schedule, a JSON file
+// such as
+//
+//	{"actions": [
+//	  {"name": "hourly", "cron": "0 * * * *", "action": "snapshot"},
+//	  {"cron": "0 8 * * 1-5", "action": "record_start", "cameras": ["Bench"]},
+//	  {"cron": "0 22 * * *", "action": "disable", "cameras": ["/dev/video2"]},
+//	  {"cron": "0 9 * * 1-5", "action": "privacy_on"}
+//	]}
+//
+// Cameras are given by device path, name or stable ID; none means all of
+// them. Each action runs once in the minute its expression matches, on the
+// main loop, so actions also run with the window hidden. Missed minutes,
+// while the machine was suspended, are not caught up. The status bar shows
+// the next action; clicking it lists them all.
+
+// scheduleFile is read at startup, set with -schedule
+var scheduleFile = "schedule.json"
+
+// scheduleActions are the names of the actions a schedule can run
+var scheduleActions = []string{"snapshot", "record_start", "record_stop", "enable", "disable", "light_on", "light_off", "privacy_on", "privacy_off"}
+
+// cronSpec is a parsed cron expression, one bit per allowed value of each
+// field
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set for fields starting with *, since a day matches either day
+	// field unless one of them is *
+	domAny, dowAny bool
+}
+
+// ScheduledAction is an action of the schedule
+type ScheduledAction struct {
+	Name    string   `json:"name,omitempty"`
+	Cron    string   `json:"cron"`
+	Action  string   `json:"action"`
+	Cameras []string `json:"cameras,omitempty"`
+
+	spec cronSpec
+	// Next is when the action runs next, zero if never
+	Next time.Time `json:"-"`
+	// LastRun and LastResult describe the last run
+	LastRun    time.Time `json:"-"`
+	LastResult string    `json:"-"`
+}
+
+// Schedule holds the scheduled actions
+type Schedule struct {
+	Actions []*ScheduledAction `json:"actions"`
+	// checked is the last minute actions were run for
+	checked time.Time
+}
+
+var schedule Schedule
+
+// parseCronField parses a comma separated list of values, ranges and
+// steps within min and max
+func parseCronField(field string, low, high int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		values, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+		}
+		from, to := low, high
+		if values != "*" {
+			first, last, isRange := strings.Cut(values, "-")
+			var err error
+			if from, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				to = high
+			}
+		}
+		if from < low || to > high || from > to {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, low, high)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseCron parses a five field cron expression: minute, hour, day of the
+// month, month and day of the week, Sunday being 0 or 7
+func parseCron(expr string) (cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSpec{}, fmt.Errorf("invalid cron expression %q, expected 5 fields", expr)
+	}
+	var spec cronSpec
+	ranges := []struct {
+		set       *uint64
+		low, high int
+	}{
+		{&spec.minute, 0, 59},
+		{&spec.hour, 0, 23},
+		{&spec.dom, 1, 31},
+		{&spec.month, 1, 12},
+		{&spec.dow, 0, 7},
+	}
+	for i, r := range ranges {
+		set, err := parseCronField(fields[i], r.low, r.high)
+		if err != nil {
+			return cronSpec{}, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		*r.set = set
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	spec.domAny, spec.dowAny = strings.HasPrefix(fields[2], "*"), strings.HasPrefix(fields[4], "*")
+	return spec, nil
+}
+
+// matches reports whether the spec matches the minute of t
+func (s cronSpec) matches(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 && s.hour&(1<<t.Hour()) != 0 &&
+		s.month&(1<<int(t.Month())) != 0 && s.matchesDay(t)
+}
+
+// matchesDay reports whether the spec's day fields match the day of t
+func (s cronSpec) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t the spec matches, zero if there is
+// none within a few years, as for February 30
+func (s cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// loadSchedule reads the schedule from path, if there is one
+func loadSchedule(path string, now time.Time) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load the schedule: %w", err)
+	}
+	var loaded Schedule
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i, action := range loaded.Actions {
+		if !slices.Contains(scheduleActions, action.Action) {
+			return fmt.Errorf("%s: unknown action %q, expected one of %s", path, action.Action, strings.Join(scheduleActions, ", "))
+		}
+		if action.spec, err = parseCron(action.Cron); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if action.Name == "" {
+			action.Name = fmt.Sprintf("%s #%d", action.Action, i+1)
+		}
+		action.Next = action.spec.next(now)
+	}
+	loaded.checked = now.Truncate(time.Minute)
+	schedule = loaded
+	return nil
+}
+
+// scheduledCameras returns the indexes of the cameras an action applies to
+func scheduledCameras(appData *CameraAppData, action *ScheduledAction) []int {
+	var cameras []int
+	for i := range appData.Cameras {
+		info := appData.Cameras[i].Info
+		if len(action.Cameras) == 0 || slices.ContainsFunc(action.Cameras, func(name string) bool {
+			return name == info.Path || name == info.Name || name == cameraID(info)
+		}) {
+			cameras = append(cameras, i)
+		}
+	}
+	return cameras
+}
+
+// runScheduledAction runs an action on its cameras and returns what it did
+func runScheduledAction(appData *CameraAppData, action *ScheduledAction) (string, error) {
+	if action.Action == "privacy_on" || action.Action == "privacy_off" {
+		// Privacy mode covers every camera
+		if err := setPrivacy(appData, action.Action == "privacy_on"); err != nil {
+			return "", err
+		}
+		return tr("schedule.privacy_done", action.Name), nil
+	}
+	cameras := scheduledCameras(appData, action)
+	if len(cameras) == 0 {
+		return "", errors.New("no camera matches")
+	}
+	var errs []error
+	done := 0
+	for _, i := range cameras {
+		camera := &appData.Cameras[i]
+		var err error
+		switch action.Action {
+		case "snapshot":
+			if camera.Disabled || !camera.Active {
+				continue
+			}
+			requestSnapshot(appData, i)
+		case "record_start":
+			if camera.Disabled || !camera.Active {
+				continue
+			}
+			err = startRecording(camera)
+		case "record_stop":
+			err = stopRecording(camera)
+		case "enable":
+			if camera.Disabled {
+				err = enableCamera(camera, appData.Renderer)
+			}
+		case "disable":
+			if !camera.Disabled {
+				disableCamera(camera)
+			}
+		case "light_on", "light_off":
+			err = setLight(appData, i, action.Action == "light_on")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", camera.Info.Name, err))
+			continue
+		}
+		done++
+	}
+	return tr("schedule.done", action.Name, done), errors.Join(errs...)
+}
+
+// updateSchedule runs the actions due in the current minute
+func updateSchedule(appData *CameraAppData) {
+	minute := time.Now().Truncate(time.Minute)
+	if len(schedule.Actions) == 0 || !minute.After(schedule.checked) {
+		return
+	}
+	schedule.checked = minute
+	for _, action := range schedule.Actions {
+		if !action.spec.matches(minute) {
+			if action.Next.Before(minute) {
+				action.Next = action.spec.next(minute)
+			}
+			continue
+		}
+		result, err := runScheduledAction(appData, action)
+		action.LastRun = minute
+		action.Next = action.spec.next(minute)
+		if err != nil {
+			action.LastResult = err.Error()
+			notify(toastError, tr("schedule.failed", action.Name, err))
+			continue
+		}
+		action.LastResult = result
+		notify(toastInfo, result)
+	}
+}
+
+// nextScheduledAction returns the action that runs first, nil if none will
+func nextScheduledAction() *ScheduledAction {
+	var next *ScheduledAction
+	for _, action := range schedule.Actions {
+		if !action.Next.IsZero() && (next == nil || action.Next.Before(next.Next)) {
+			next = action
+		}
+	}
+	return next
+}
+
+// formatScheduleTime formats when an action runs, with the date unless it
+// is today
+func formatScheduleTime(t, now time.Time) string {
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	return t.Format("Mon 2 Jan 15:04")
+}
+
+// scheduleDetail lists the actions with their next and last runs for the
+// status message
+func scheduleDetail(now time.Time) string {
+	var parts []string
+	for _, action := range schedule.Actions {
+		next := tr("schedule.never")
+		if !action.Next.IsZero() {
+			next = formatScheduleTime(action.Next, now)
+		}
+		part := tr("schedule.entry", action.Name, next)
+		if !action.LastRun.IsZero() {
+			part += tr("schedule.last", formatScheduleTime(action.LastRun, now), action.LastResult)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2026, 3, 4, 10, 17, 30, 0, time.Local)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 * * * *", time.Date(2026, 3, 4, 11, 0, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 30, 0, 0, time.Local)},
+		{"0 8 * * 1-5", time.Date(2026, 3, 5, 8, 0, 0, 0, time.Local)},
+		{"30 9 * * 0", time.Date(2026, 3, 8, 9, 30, 0, 0, time.Local)},
+		{"30 9 * * 7", time.Date(2026, 3, 8, 9, 30, 0, 0, time.Local)},
+		{"0 0 1 */2 *", time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local)},
+		// Either day field matches when both are restricted
+		{"0 12 15 * 5", time.Date(2026, 3, 6, 12, 0, 0, 0, time.Local)},
+		{"5,40 22-23 * * *", time.Date(2026, 3, 4, 22, 5, 0, 0, time.Local)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		spec, err := parseCron(test.expr)
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if got := spec.next(now); !got.Equal(test.want) {
+			t.Errorf("%q next %v, want %v", test.expr, got, test.want)
+		}
+		if !test.want.IsZero() && !spec.matches(test.want) {
+			t.Errorf("%q does not match its next run", test.expr)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%q accepted", expr)
+		}
+	}
+}
+
+func TestLoadSchedule(t *testing.T) {
+	t.Cleanup(func() { schedule = Schedule{} })
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schedule.json")
+	now := time.Date(2026, 3, 4, 10, 17, 0, 0, time.Local)
+
+	if err := loadSchedule(filepath.Join(dir, "missing.json"), now); err != nil || len(schedule.Actions) != 0 {
+		t.Fatalf("missing schedule: %v", err)
+	}
+	data := `{"actions": [
+		{"name": "hourly", "cron": "0 * * * *", "action": "snapshot"},
+		{"cron": "0 8 * * *", "action": "record_start", "cameras": ["Bench"]}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadSchedule(path, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule.Actions) != 2 || schedule.Actions[1].Name != "record_start #2" {
+		t.Fatalf("loaded %+v", schedule.Actions)
+	}
+	if next := nextScheduledAction(); next == nil || next.Name != "hourly" || next.Next.Hour() != 11 {
+		t.Errorf("next action %+v", next)
+	}
+
+	appData := &CameraAppData{Cameras: []CameraInstance{
+		{Info: CameraInfo{Name: "Bench", Path: "/dev/video0"}},
+		{Info: CameraInfo{Name: "Door", Path: "/dev/video2"}},
+	}}
+	if cameras := scheduledCameras(appData, schedule.Actions[1]); len(cameras) != 1 || cameras[0] != 0 {
+		t.Errorf("record_start applies to %v", cameras)
+	}
+	if cameras := scheduledCameras(appData, schedule.Actions[0]); len(cameras) != 2 {
+		t.Errorf("snapshot applies to %v", cameras)
+	}
+
+	for _, bad := range []string{
+		`{"actions": [{"cron": "0 * * * *", "action": "explode"}]}`,
+		`{"actions": [{"cron": "0 * *", "action": "snapshot"}]}`,
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadSchedule(path, now); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
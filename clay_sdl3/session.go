@@ -0,0 +1,485 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+const sessionDir = "sessions"
+
+// Session is a named snapshot of the app state, so switching between setups
+// such as "PCB inspection" and "lathe monitoring" is a single click. Cameras
+// are matched by device path when a session is loaded.
+type Session struct {
+	Name           string          `json:"name"`
+	SavedAt        time.Time       `json:"saved_at"`
+	Theme          string          `json:"theme"`
+	Language       string          `json:"language"`
+	SelectedCamera string          `json:"selected_camera"`
+	Zoom           float32         `json:"zoom"`
+	ZoomCenterX    float32         `json:"zoom_center_x"`
+	ZoomCenterY    float32         `json:"zoom_center_y"`
+	Cameras        []SessionCamera `json:"cameras"`
+	// Mosaic is the alignment of two stitched cameras, if stitching
+	Mosaic *SessionMosaic `json:"mosaic,omitempty"`
+	// Overlays are the framing overlays, absent from older sessions
+	Overlays *Overlays `json:"overlays,omitempty"`
+}
+
+// SessionMosaic is a saved stitching alignment, so the cameras do not need
+// to be aligned again
+type SessionMosaic struct {
+	First   string  `json:"first"`
+	Second  string  `json:"second"`
+	OffsetX int     `json:"offset_x"`
+	OffsetY int     `json:"offset_y"`
+	Scale   float32 `json:"scale"`
+}
+
+// SessionCamera is the saved state of one camera
+type SessionCamera struct {
+	Path     string           `json:"path"`
+	Name     string           `json:"name"`
+	Disabled bool             `json:"disabled"`
+	PopOut   bool             `json:"pop_out"`
+	Controls []SessionControl `json:"controls,omitempty"`
+	// WhiteBalance holds the software white balance gains, if calibrated
+	WhiteBalance []float32 `json:"white_balance,omitempty"`
+	// Denoise is the temporal denoise level, 0 for off
+	Denoise int `json:"denoise,omitempty"`
+	// PixelsPerMM is the calibrated scale, 0 if uncalibrated
+	PixelsPerMM float32 `json:"pixels_per_mm,omitempty"`
+	// Keystone holds the perspective correction corners, if any
+	Keystone []sdl.FPoint `json:"keystone,omitempty"`
+	// Palette names the palette of a greyscale camera
+	Palette string `json:"palette,omitempty"`
+}
+
+// SessionControl is a saved V4L2 control value. The name is only there to
+// make the file readable; controls are restored by ID.
+type SessionControl struct {
+	ID    v4l2.CtrlID `json:"id"`
+	Name  string      `json:"name"`
+	Value int32       `json:"value"`
+}
+
+// SessionsPanelState tracks the floating panel listing the saved sessions
+type SessionsPanelState struct {
+	Open  bool
+	Names []string
+	// Bundles are the configuration bundles that can be imported
+	Bundles []string
+	// Naming is set while the name of a new session is being typed
+	Naming bool
+	Text   string
+}
+
+func sessionPath(name string) string {
+	return filepath.Join(sessionDir, fileSafeName(name)+".json")
+}
+
+// listSessions returns the names of the saved sessions, sorted
+func listSessions() ([]string, error) {
+	entries, err := os.ReadDir(sessionDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// captureSession records the current app state
+func captureSession(appData *CameraAppData, name string) Session {
+	session := Session{
+		Name:        name,
+		SavedAt:     time.Now(),
+		Theme:       theme.Name,
+		Language:    language,
+		Zoom:        touchMode.Zoom,
+		ZoomCenterX: touchMode.CenterX,
+		ZoomCenterY: touchMode.CenterY,
+	}
+	current := overlays
+	session.Overlays = &current
+	if appData.SelectedCamera < len(appData.Cameras) {
+		session.SelectedCamera = appData.Cameras[appData.SelectedCamera].Info.Path
+	}
+
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		saved := SessionCamera{
+			Path:        camera.Info.Path,
+			Name:        camera.Info.Name,
+			Disabled:    camera.Disabled,
+			PopOut:      camera.PopOut != nil,
+			Controls:    captureControls(camera),
+			PixelsPerMM: camera.PixelsPerMM,
+		}
+		if camera.WhiteBalance != nil {
+			saved.WhiteBalance = camera.WhiteBalance.Gains[:]
+		}
+		if camera.Denoise != nil {
+			saved.Denoise = camera.Denoise.Level
+		}
+		if camera.Keystone != nil {
+			saved.Keystone = camera.Keystone.Corners[:]
+		}
+		if isGreyCamera(camera) {
+			saved.Palette = paletteNames[camera.Palette]
+		}
+		session.Cameras = append(session.Cameras, saved)
+	}
+	if mosaic != nil && mosaic.Aligned {
+		session.Mosaic = &SessionMosaic{
+			First:   mosaic.Cameras[0].Info.Path,
+			Second:  mosaic.Cameras[1].Info.Path,
+			OffsetX: mosaic.OffsetX,
+			OffsetY: mosaic.OffsetY,
+			Scale:   mosaic.Scale,
+		}
+	}
+	return session
+}
+
+// captureControls reads the current values of a camera's writable controls
+func captureControls(camera *CameraInstance) []SessionControl {
+	if camera.Device == nil {
+		return nil
+	}
+	controls, err := camera.Device.QueryControls()
+	if err != nil {
+		log.Printf("Failed to query controls for %s: %v", camera.Info.Name, err)
+		return nil
+	}
+
+	var saved []SessionControl
+	for _, ctrl := range controls {
+		switch ctrl.Type {
+		case v4l2.CtrlTypeInt, v4l2.CtrlTypeBool, v4l2.CtrlTypeMenu, v4l2.CtrlTypeIntegerMenu:
+		default:
+			continue
+		}
+		value, err := camera.Device.GetControlValue(ctrl.ID)
+		if err != nil {
+			// Write-only or currently inactive
+			continue
+		}
+		saved = append(saved, SessionControl{ID: ctrl.ID, Name: ctrl.Name, Value: value})
+	}
+	return saved
+}
+
+// saveSession writes the current app state as a named session
+func saveSession(appData *CameraAppData, name string) (string, error) {
+	if err := os.MkdirAll(sessionDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", sessionDir, err)
+	}
+	data, err := json.MarshalIndent(captureSession(appData, name), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session %q: %w", name, err)
+	}
+	path := sessionPath(name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to save session %q: %w", name, err)
+	}
+	return path, nil
+}
+
+// loadSession reads a named session and applies it
+func loadSession(appData *CameraAppData, name string) error {
+	data, err := os.ReadFile(sessionPath(name))
+	if err != nil {
+		return fmt.Errorf("failed to load session %q: %w", name, err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return fmt.Errorf("failed to parse session %q: %w", name, err)
+	}
+	applySession(appData, &session)
+	return nil
+}
+
+// applySession restores a session. Cameras that are not connected are
+// skipped, and cameras the session does not mention are left as they are.
+func applySession(appData *CameraAppData, session *Session) {
+	if session.Theme != "" {
+		if err := setTheme(session.Theme); err != nil {
+			log.Printf("Session %q: %v", session.Name, err)
+		}
+	}
+	if session.Language != "" {
+		if err := setLanguage(session.Language); err != nil {
+			log.Printf("Session %q: %v", session.Name, err)
+		}
+	}
+
+	if session.Overlays != nil {
+		overlays = *session.Overlays
+	}
+
+	for _, saved := range session.Cameras {
+		index := -1
+		for i := range appData.Cameras {
+			if appData.Cameras[i].Info.Path == saved.Path {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			log.Printf("Session %q: camera %s is not connected", session.Name, saved.Path)
+			continue
+		}
+		camera := &appData.Cameras[index]
+
+		if saved.Name != "" {
+			camera.Info.Name = saved.Name
+		}
+		if saved.Disabled {
+			disableCamera(camera)
+		} else if camera.Disabled {
+			if err := enableCamera(camera, appData.Renderer); err != nil {
+				log.Printf("Session %q: %v", session.Name, err)
+			}
+		}
+		if camera.Device != nil {
+			for _, ctrl := range saved.Controls {
+				if err := camera.Device.SetControlValue(ctrl.ID, ctrl.Value); err != nil {
+					log.Printf("Session %q: failed to set %s on %s: %v", session.Name, ctrl.Name, camera.Info.Name, err)
+				}
+			}
+		}
+		camera.FrameMutex.Lock()
+		camera.WhiteBalance = nil
+		if len(saved.WhiteBalance) == 3 {
+			camera.WhiteBalance = newWhiteBalance([3]float32(saved.WhiteBalance))
+		}
+		camera.Denoise = newTemporalDenoise(saved.Denoise)
+		camera.PixelsPerMM = saved.PixelsPerMM
+		camera.Keystone = nil
+		if len(saved.Keystone) == 4 {
+			camera.Keystone = newKeystone([4]sdl.FPoint(saved.Keystone))
+		}
+		if palette, ok := parsePalette(saved.Palette); ok {
+			camera.Palette = palette
+		}
+		camera.FrameMutex.Unlock()
+		if saved.PopOut && camera.PopOut == nil {
+			if err := openPopOut(appData, index); err != nil {
+				log.Printf("Session %q: %v", session.Name, err)
+			}
+		} else if !saved.PopOut {
+			closePopOut(camera)
+		}
+		if saved.Path == session.SelectedCamera {
+			selectCamera(appData, index)
+		}
+	}
+
+	stopMosaic()
+	if saved := session.Mosaic; saved != nil {
+		restoreMosaic(appData, saved)
+	}
+
+	// Restore the zoom after selectCamera, which resets it
+	if session.Zoom >= 1 {
+		touchMode.Zoom = session.Zoom
+		touchMode.CenterX = session.ZoomCenterX
+		touchMode.CenterY = session.ZoomCenterY
+		touchMode.clampCenter()
+	}
+}
+
+// openSessionsPanel shows the list of saved sessions
+func openSessionsPanel(appData *CameraAppData) {
+	names, err := listSessions()
+	if err != nil {
+		setErrorStatus(appData, err)
+	}
+	appData.SessionsPanel = SessionsPanelState{Open: true, Names: names, Bundles: listBundles()}
+}
+
+// createSessionsPanelLayout declares the sessions panel as a floating element
+// centered over the window
+func createSessionsPanelLayout(data *CameraAppData) {
+	panel := &data.SessionsPanel
+	if !panel.Open {
+		return
+	}
+
+	textConfig := clay.TextElementConfig{
+		FontId:    FontIdBody16,
+		FontSize:  12,
+		TextColor: theme.Text,
+	}
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("SessionsPanel"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Sizing: clay.Sizing{
+				Width: clay.SizingFixed(tp(300)),
+			},
+			Padding:  clay.PaddingAll(dpu(12)),
+			ChildGap: dpu(6),
+		},
+		Floating: clay.FloatingElementConfig{
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_CENTER,
+				Parent:  clay.ATTACH_POINT_CENTER_CENTER,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(6)),
+		Border: clay.BorderElementConfig{
+			Color: theme.AccentBorder,
+			Width: clay.BorderOutside(dpu(2)),
+		},
+	}, func() {
+		safeText("sessions-title", tr("sessions.title"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+
+		if len(panel.Names) == 0 {
+			safeText("sessions-none", tr("sessions.none"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.TextDim,
+			})
+		}
+		for i, name := range panel.Names {
+			controlButton(fmt.Sprintf("Session%d", i), name)
+		}
+
+		if panel.Naming {
+			safeText("sessions-name", tr("sessions.name", panel.Text+"_"), textConfig)
+		} else {
+			controlButton("SessionSave", tr("sessions.save"))
+		}
+
+		safeText("sessions-bundles", tr("sessions.bundles"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+		for i, bundle := range panel.Bundles {
+			controlButton(fmt.Sprintf("SessionImport%d", i), tr("sessions.import", filepath.Base(bundle)))
+		}
+		controlButton("SessionExport", tr("sessions.export"))
+		controlButton("SessionsClose", tr("controls.close"))
+	})
+}
+
+// handleSessionsPanelClick loads the clicked session or starts naming a new
+// one. It reports whether the click landed on the panel.
+func handleSessionsPanelClick(appData *CameraAppData, x, y float32) bool {
+	panel := &appData.SessionsPanel
+	if !panel.Open || !pointInElement(SafeID("SessionsPanel"), x, y) {
+		return false
+	}
+
+	switch {
+	case pointInElement(SafeID("SessionsClose"), x, y):
+		closeSessionsPanel(appData)
+	case pointInElement(SafeID("SessionSave"), x, y):
+		panel.Naming = true
+		panel.Text = ""
+		if err := appData.Window.StartTextInput(); err != nil {
+			log.Printf("Failed to start text input: %v", err)
+		}
+	case pointInElement(SafeID("SessionExport"), x, y):
+		path, manifest, err := exportBundle(appData)
+		if err != nil {
+			setErrorStatus(appData, err)
+			break
+		}
+		appData.StatusText = tr("status.bundle_exported", path)
+		if manifest.Omitted > 0 {
+			appData.StatusText += tr("status.bundle_secrets", manifest.Omitted)
+		}
+		appData.StatusColor = theme.Success
+		panel.Bundles = listBundles()
+	default:
+		for i, bundle := range panel.Bundles {
+			if pointInElement(SafeID(fmt.Sprintf("SessionImport%d", i)), x, y) {
+				manifest, err := importBundle(appData, bundle)
+				reportBundleImport(appData, bundle, manifest, err)
+				closeSessionsPanel(appData)
+				return true
+			}
+		}
+		for i, name := range panel.Names {
+			if pointInElement(SafeID(fmt.Sprintf("Session%d", i)), x, y) {
+				if err := loadSession(appData, name); err != nil {
+					setErrorStatus(appData, err)
+				} else {
+					appData.StatusText = tr("sessions.loaded", name)
+				}
+				closeSessionsPanel(appData)
+				break
+			}
+		}
+	}
+	return true
+}
+
+// handleSessionNameKey edits the name of the session being saved
+func handleSessionNameKey(appData *CameraAppData, scancode sdl.Scancode) {
+	panel := &appData.SessionsPanel
+	switch scancode {
+	case sdl.SCANCODE_RETURN, sdl.SCANCODE_KP_ENTER:
+		name := strings.TrimSpace(panel.Text)
+		if name == "" {
+			return
+		}
+		path, err := saveSession(appData, name)
+		if err != nil {
+			setErrorStatus(appData, err)
+		} else {
+			appData.StatusText = tr("status.saved", path)
+		}
+		closeSessionsPanel(appData)
+	case sdl.SCANCODE_ESCAPE:
+		closeSessionsPanel(appData)
+	case sdl.SCANCODE_BACKSPACE:
+		text := []rune(panel.Text)
+		if len(text) > 0 {
+			panel.Text = string(text[:len(text)-1])
+		}
+	}
+}
+
+// closeSessionsPanel hides the sessions panel, ending any name entry
+func closeSessionsPanel(appData *CameraAppData) {
+	panel := &appData.SessionsPanel
+	if panel.Naming {
+		if err := appData.Window.StopTextInput(); err != nil {
+			log.Printf("Failed to stop text input: %v", err)
+		}
+	}
+	*panel = SessionsPanelState{}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// shutdownTimeout bounds how long exiting waits for capture to stop, so a
+// hung device cannot keep the app from exiting
+const shutdownTimeout = 5 * time.Second
+
+// captures tracks the running capture goroutines
+var captures sync.WaitGroup
+
+// startCapture runs a camera's capture goroutine
+func startCapture(camera *CameraInstance) {
+	captures.Add(1)
+	go func() {
+		defer captures.Done()
+		captureFramesForCamera(camera)
+	}()
+}
+
+// stopCaptures stops every camera's capture and waits until deadline for
+// the capture goroutines to exit. Frames still queued when capture stops
+// are written to the recordings rather than dropped.
+func stopCaptures(appData *CameraAppData, deadline time.Time) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		camera.Active = false
+		if camera.StopStream != nil {
+			camera.StopStream()
+			camera.StopStream = nil
+		}
+	}
+
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if camera.FrameChan == nil {
+			continue
+		}
+	drain:
+		for {
+			select {
+			case frame, ok := <-camera.FrameChan:
+				if !ok {
+					break drain
+				}
+				if camera.Recorder != nil {
+					recordFrame(appData, camera, encodeOutput(frame))
+				}
+			case <-time.After(time.Until(deadline)):
+				log.Printf("Timed out stopping capture for %s", camera.Info.Name)
+				break drain
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		captures.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Until(deadline)):
+		log.Printf("Timed out waiting for capture to stop")
+	}
+}
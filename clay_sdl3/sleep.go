@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// The screen is kept from blanking while the window is in view and a
+// camera is selected, so a monitor showing a print or a door does not go
+// dark for want of keyboard input. The window counts as in view unless it
+// is hidden in the tray, minimized or covered by other windows; once it is,
+// or once no camera is selected, the screensaver and power management take
+// over again. SDL inhibits them through the desktop's D-Bus screensaver
+// service on Linux and the system's own calls elsewhere. -keep-awake=false
+// leaves the screen to them entirely.
+
+// keepAwake allows keeping the screen on, set with -keep-awake
+var keepAwake = true
+
+// sleepInhibited is whether the screensaver is held off; SDL holds it off
+// from the start unless told otherwise
+var sleepInhibited = true
+
+// wantSleepInhibit reports whether the screen should stay on
+func wantSleepInhibit(appData *CameraAppData, flags sdl.WindowFlags) bool {
+	if !keepAwake || appData.Hidden || flags&(sdl.WINDOW_HIDDEN|sdl.WINDOW_MINIMIZED|sdl.WINDOW_OCCLUDED) != 0 {
+		return false
+	}
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return false
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	return !camera.Disabled && (camera.Active || camera.PlaybackControl != nil)
+}
+
+// updateSleepInhibit holds the screensaver off or lets it run as the window
+// and the selected camera change
+func updateSleepInhibit(appData *CameraAppData) {
+	if appData.Window == nil {
+		return
+	}
+	want := wantSleepInhibit(appData, appData.Window.Flags())
+	if want == sleepInhibited {
+		return
+	}
+	var err error
+	if want {
+		err = sdl.DisableScreenSaver()
+	} else {
+		err = sdl.EnableScreenSaver()
+	}
+	// Not retried every frame if it fails
+	sleepInhibited = want
+	if err != nil {
+		log.Printf("Failed to switch the screensaver: %v", err)
+		return
+	}
+	if want {
+		log.Printf("Keeping the screen on")
+	} else {
+		log.Printf("Letting the screen sleep")
+	}
+}
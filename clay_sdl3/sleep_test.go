@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+func TestWantSleepInhibit(t *testing.T) {
+	defer func() { keepAwake = true }()
+	appData := &CameraAppData{Cameras: []CameraInstance{{Active: true}, {Disabled: true}, {}}}
+	if !wantSleepInhibit(appData, 0) {
+		t.Error("screen sleeps with a live camera selected")
+	}
+	for _, flags := range []sdl.WindowFlags{sdl.WINDOW_MINIMIZED, sdl.WINDOW_OCCLUDED, sdl.WINDOW_HIDDEN} {
+		if wantSleepInhibit(appData, flags) {
+			t.Errorf("screen kept on with the window %#x", flags)
+		}
+	}
+	appData.Hidden = true
+	if wantSleepInhibit(appData, 0) {
+		t.Error("screen kept on with the window in the tray")
+	}
+	appData.Hidden = false
+
+	for _, selected := range []int{1, 2, 3} {
+		appData.SelectedCamera = selected
+		if wantSleepInhibit(appData, 0) {
+			t.Errorf("screen kept on with camera %d selected", selected)
+		}
+	}
+	appData.SelectedCamera = 0
+	keepAwake = false
+	if wantSleepInhibit(appData, 0) {
+		t.Error("screen kept on with -keep-awake=false")
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+// Thumbnails can be dragged with the mouse: dropped on a main pane, the
+// camera is shown there; dropped elsewhere in the thumbnails panel, it moves
+// to that place in the strip. The cameras keep their indexes, which pop-outs,
+// the API and the mosaic refer to; only the strip has its own order, which
+// the arrow and number keys follow. The order and the cameras of the panes
+// are saved by device path in layoutFile and restored at startup. A press
+// that does not move selects the camera when released; in touch mode,
+// dragging scrolls the panel instead.
+
+// layoutFile keeps the thumbnail order and the cameras of the panes
+const layoutFile = "layout.json"
+
+// dragThreshold is how far the pointer must move, in layout units, before
+// a press on a thumbnail becomes a drag
+const dragThreshold = 8
+
+// CameraLayout is the saved arrangement of the cameras, by device path
+type CameraLayout struct {
+	Order []string `json:"order"`
+	// Panes are the cameras of the left and, in the dual view, right pane
+	Panes []string `json:"panes"`
+	Dual  bool     `json:"dual"`
+}
+
+// ThumbnailDrag tracks a press on a thumbnail
+type ThumbnailDrag struct {
+	Active bool
+	// Dragging is set once the pointer moved past dragThreshold
+	Dragging bool
+	Camera   int
+	Start    sdl.FPoint
+}
+
+// thumbnailOrder is the camera indexes in the order of the strip
+var thumbnailOrder []int
+
+var thumbnailDrag ThumbnailDrag
+
+// stripOrder returns the strip order, adding cameras it does not have yet
+// at the end
+func stripOrder(appData *CameraAppData) []int {
+	if len(thumbnailOrder) == len(appData.Cameras) {
+		return thumbnailOrder
+	}
+	thumbnailOrder = slices.DeleteFunc(thumbnailOrder, func(i int) bool { return i >= len(appData.Cameras) })
+	for i := range appData.Cameras {
+		if !slices.Contains(thumbnailOrder, i) {
+			thumbnailOrder = append(thumbnailOrder, i)
+		}
+	}
+	return thumbnailOrder
+}
+
+// stripCamera returns the camera at a position of the strip, -1 if there is
+// none
+func stripCamera(appData *CameraAppData, position int) int {
+	order := stripOrder(appData)
+	if position < 0 || position >= len(order) {
+		return -1
+	}
+	return order[position]
+}
+
+// stepCamera selects the camera delta places along the strip from the
+// selected one
+func stepCamera(appData *CameraAppData, delta int) {
+	position := slices.Index(stripOrder(appData), appData.SelectedCamera)
+	if position < 0 {
+		return
+	}
+	selectCamera(appData, stripCamera(appData, position+delta))
+}
+
+// moveInOrder moves a camera to a position of the strip, counted before the
+// camera was taken out
+func moveInOrder(order []int, camera, position int) []int {
+	from := slices.Index(order, camera)
+	if from < 0 {
+		return order
+	}
+	order = slices.Delete(order, from, from+1)
+	if position > from {
+		position--
+	}
+	return slices.Insert(order, min(max(position, 0), len(order)), camera)
+}
+
+// startThumbnailDrag remembers a press on a thumbnail, which selects the
+// camera or drags it once released
+func startThumbnailDrag(index int, x, y float32) {
+	thumbnailDrag = ThumbnailDrag{Active: true, Camera: index, Start: sdl.FPoint{X: x, Y: y}}
+}
+
+// updateThumbnailDrag turns a press into a drag once the pointer moved far
+// enough, and forgets it if the button was released outside the window
+func updateThumbnailDrag(x, y float32, held bool) {
+	drag := &thumbnailDrag
+	if !drag.Active {
+		return
+	}
+	if !held {
+		*drag = ThumbnailDrag{}
+		return
+	}
+	if !drag.Dragging && !touchMode.Enabled && !kiosk.Locked && abs32(x-drag.Start.X)+abs32(y-drag.Start.Y) > dp(dragThreshold) {
+		drag.Dragging = true
+	}
+}
+
+// finishThumbnailDrag selects the pressed camera, or drops the dragged one
+// on the pane or strip position under the pointer
+func finishThumbnailDrag(appData *CameraAppData, x, y float32) {
+	drag := thumbnailDrag
+	thumbnailDrag = ThumbnailDrag{}
+	if !drag.Active || drag.Camera >= len(appData.Cameras) {
+		return
+	}
+	if !drag.Dragging {
+		selectCamera(appData, drag.Camera)
+		return
+	}
+
+	switch pane := paneAt(x, y); {
+	case pane == dualView.Focus || pane >= 0 && !dualView.Enabled:
+		selectCamera(appData, drag.Camera)
+	case pane >= 0:
+		dualView.Other = PaneView{Camera: drag.Camera, Zoom: 1, CenterX: 0.5, CenterY: 0.5, Overlays: dualView.Other.Overlays}
+	case pointInElement(SafeID("ThumbnailsPanel"), x, y):
+		thumbnailOrder = moveInOrder(stripOrder(appData), drag.Camera, dropPosition(appData, stripAxis(x, y)))
+	default:
+		return
+	}
+	saveLayoutStatus(appData)
+}
+
+// paneAt returns the main pane under the pointer, -1 if there is none
+func paneAt(x, y float32) int {
+	panes := 1
+	if dualView.Enabled {
+		panes = 2
+	}
+	for pane := range panes {
+		if pointInElement(paneID(pane), x, y) {
+			return pane
+		}
+	}
+	return -1
+}
+
+// dropPosition is the strip position a thumbnail dropped at a point along
+// the strip, see stripAxis, goes to
+func dropPosition(appData *CameraAppData, at float32) int {
+	position := 0
+	for _, i := range stripOrder(appData) {
+		thumbnail := clay.GetElementData(SafeID(fmt.Sprintf("Thumbnail%d", i)))
+		if start, length := stripSpan(thumbnail.BoundingBox); thumbnail.Found && at > start+length/2 {
+			position++
+		}
+	}
+	return position
+}
+
+// renderThumbnailDrag draws the dragged thumbnail under the pointer and
+// marks where it would be dropped
+func renderThumbnailDrag(appData *CameraAppData, x, y float32) {
+	drag := &thumbnailDrag
+	if !drag.Dragging || drag.Camera >= len(appData.Cameras) {
+		return
+	}
+	renderer := appData.Renderer
+	_ = renderer.SetDrawColor(uint8(theme.Accent.R), uint8(theme.Accent.G), uint8(theme.Accent.B), 255)
+	if pane := paneAt(x, y); pane >= 0 {
+		if rect, ok := paneRect(paneID(pane)); ok {
+			_ = renderer.RenderRect(&rect)
+			_ = renderer.RenderRect(&sdl.FRect{X: rect.X + 1, Y: rect.Y + 1, W: rect.W - 2, H: rect.H - 2})
+		}
+	} else if panel := clay.GetElementData(SafeID("ThumbnailsPanel")); panel.Found && pointInElement(SafeID("ThumbnailsPanel"), x, y) {
+		// The gap the thumbnail would go into
+		line, _ := stripSpan(panel.BoundingBox)
+		for _, i := range stripOrder(appData) {
+			thumbnail := clay.GetElementData(SafeID(fmt.Sprintf("Thumbnail%d", i)))
+			if start, length := stripSpan(thumbnail.BoundingBox); thumbnail.Found && stripAxis(x, y) > start+length/2 {
+				line = start + length + dp(6)
+			}
+		}
+		box := panel.BoundingBox
+		if portraitLayout() {
+			_ = renderer.RenderFillRect(&sdl.FRect{X: line - dp(1), Y: box.Y, W: dp(2), H: box.Height})
+		} else {
+			_ = renderer.RenderFillRect(&sdl.FRect{X: box.X, Y: line - dp(1), W: box.Width, H: dp(2)})
+		}
+	}
+
+	camera := &appData.Cameras[drag.Camera]
+	camera.FrameMutex.RLock()
+	defer camera.FrameMutex.RUnlock()
+	texture := camera.ThumbnailTexture
+	if texture == nil {
+		return
+	}
+	rect := sdl.FRect{X: x - tp(40), Y: y - tp(30), W: tp(80), H: tp(60)}
+	_ = texture.SetAlphaMod(160)
+	if err := renderer.RenderTexture(texture, nil, &rect); err != nil {
+		log.Printf("Error rendering dragged thumbnail: %v", err)
+	}
+	_ = texture.SetAlphaMod(255)
+}
+
+// captureLayout records the strip order and the cameras of the panes
+func captureLayout(appData *CameraAppData) CameraLayout {
+	var saved CameraLayout
+	for _, i := range stripOrder(appData) {
+		saved.Order = append(saved.Order, appData.Cameras[i].Info.Path)
+	}
+	panes := []int{appData.SelectedCamera}
+	if dualView.Enabled {
+		panes = append(panes, dualView.Other.Camera)
+		if dualView.Focus != 0 {
+			panes[0], panes[1] = panes[1], panes[0]
+		}
+		saved.Dual = true
+	}
+	for _, i := range panes {
+		if i < len(appData.Cameras) {
+			saved.Panes = append(saved.Panes, appData.Cameras[i].Info.Path)
+		}
+	}
+	return saved
+}
+
+// saveLayout writes the arrangement to layoutFile
+func saveLayout(appData *CameraAppData) error {
+	data, err := json.MarshalIndent(captureLayout(appData), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the camera layout: %w", err)
+	}
+	if err := os.WriteFile(layoutFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save the camera layout: %w", err)
+	}
+	return nil
+}
+
+// saveLayoutStatus saves the arrangement, showing a failure in the status
+// bar
+func saveLayoutStatus(appData *CameraAppData) {
+	if err := saveLayout(appData); err != nil {
+		setErrorStatus(appData, err)
+	}
+}
+
+// loadLayout restores the arrangement from layoutFile, if there is one.
+// Cameras that are not connected are skipped.
+func loadLayout(appData *CameraAppData) error {
+	data, err := os.ReadFile(layoutFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load the camera layout: %w", err)
+	}
+	var saved CameraLayout
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", layoutFile, err)
+	}
+	applyLayout(appData, &saved)
+	return nil
+}
+
+// applyLayout restores a saved arrangement
+func applyLayout(appData *CameraAppData, saved *CameraLayout) {
+	index := func(path string) int {
+		for i := range appData.Cameras {
+			if appData.Cameras[i].Info.Path == path {
+				return i
+			}
+		}
+		return -1
+	}
+	thumbnailOrder = nil
+	for _, path := range saved.Order {
+		if i := index(path); i >= 0 && !slices.Contains(thumbnailOrder, i) {
+			thumbnailOrder = append(thumbnailOrder, i)
+		}
+	}
+	stripOrder(appData)
+
+	if len(saved.Panes) > 0 {
+		if i := index(saved.Panes[0]); i >= 0 {
+			appData.SelectedCamera = i
+		}
+	}
+	if saved.Dual && !dualView.Enabled {
+		toggleDualView(appData)
+		if len(saved.Panes) > 1 {
+			if i := index(saved.Panes[1]); i >= 0 {
+				dualView.Other.Camera = i
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestMoveInOrder(t *testing.T) {
+	for _, c := range []struct {
+		camera, position int
+		want             []int
+	}{
+		{0, 0, []int{0, 1, 2, 3}},
+		{0, 2, []int{1, 0, 2, 3}},
+		{0, 4, []int{1, 2, 3, 0}},
+		{3, 0, []int{3, 0, 1, 2}},
+		{2, 1, []int{0, 2, 1, 3}},
+		{2, 3, []int{0, 1, 2, 3}},
+		{7, 0, []int{0, 1, 2, 3}},
+	} {
+		if got := moveInOrder([]int{0, 1, 2, 3}, c.camera, c.position); !slices.Equal(got, c.want) {
+			t.Errorf("moving %d to %d gives %v, want %v", c.camera, c.position, got, c.want)
+		}
+	}
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+	defer func() {
+		thumbnailOrder = nil
+		dualView = DualView{}
+	}()
+	newApp := func() *CameraAppData {
+		appData := &CameraAppData{Cameras: make([]CameraInstance, 3)}
+		for i, path := range []string{"/dev/video0", "/dev/video2", "/dev/video4"} {
+			appData.Cameras[i].Info.Path = path
+		}
+		return appData
+	}
+
+	appData := newApp()
+	thumbnailOrder = []int{2, 0, 1}
+	appData.SelectedCamera = 1
+	toggleDualView(appData)
+	dualView.Other.Camera = 2
+	if err := saveLayout(appData); err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnailOrder, dualView = nil, DualView{}
+	restored := newApp()
+	// A camera that is gone is skipped, and a new one goes last
+	restored.Cameras[0].Info.Path = "/dev/video6"
+	if err := loadLayout(restored); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(thumbnailOrder, []int{2, 1, 0}) {
+		t.Errorf("strip order is %v", thumbnailOrder)
+	}
+	if restored.SelectedCamera != 1 || !dualView.Enabled || dualView.Other.Camera != 2 {
+		t.Errorf("panes show cameras %d and %d", restored.SelectedCamera, dualView.Other.Camera)
+	}
+
+	if err := os.Remove(layoutFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadLayout(newApp()); err != nil {
+		t.Errorf("missing layout file: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// A slow-motion review plays the last -slowmo-seconds of a live camera's
+// history again at half speed (M) or quarter speed (Shift+M), under a REPLAY
+// indicator, and returns to live when it is over, like a sports replay of
+// what the machine just did. It is a paused live view that advances on its
+// own: Shift+Left and Shift+Right stop it to step through the frames and
+// Space returns to live at once.
+
+// slowMotionSeconds is how much of the history a slow-motion review plays,
+// set with -slowmo-seconds
+var slowMotionSeconds = 5.0
+
+// slowMotionHold is how long the last frame of a review stays before the
+// view returns to live
+const slowMotionHold = time.Second
+
+// startSlowMotion plays the recent history of a camera at speed
+func startSlowMotion(appData *CameraAppData, camera *CameraInstance, speed float64) {
+	frames := camera.History.Since(time.Duration(slowMotionSeconds * float64(time.Second)))
+	if len(frames) == 0 {
+		setErrorStatus(appData, trErr("error.no_history"))
+		return
+	}
+	camera.Freeze = &liveFreeze{Frames: frames, shown: -1, Speed: speed, started: time.Now()}
+	appData.StatusText = tr("status.slowmo", camera.Info.Name, formatSpeed(speed))
+}
+
+// advance moves a slow-motion review on to the frame due at now, reporting
+// false once it is over. A paused view stays where it is.
+func (f *liveFreeze) advance(now time.Time) bool {
+	if f.Speed == 0 {
+		return true
+	}
+	elapsed := time.Duration(float64(now.Sub(f.started)) * f.Speed)
+	at := f.Frames[0].Captured.Add(elapsed)
+	for f.Index+1 < len(f.Frames) && !f.Frames[f.Index+1].Captured.After(at) {
+		f.Index++
+	}
+	last := f.Frames[len(f.Frames)-1].Captured
+	return now.Sub(f.started) < time.Duration(float64(last.Sub(f.Frames[0].Captured))/f.Speed)+slowMotionHold
+}
+
+// formatSpeed returns a playback speed as a fraction, as in ½×
+func formatSpeed(speed float64) string {
+	switch speed {
+	case 0.5:
+		return "½×"
+	case 0.25:
+		return "¼×"
+	}
+	return tr("slowmo.speed", speed)
+}
+
+// createSlowMotionLayout declares the REPLAY indicator at the top left of
+// the pane while a slow-motion review plays
+func createSlowMotionLayout(freeze *liveFreeze) {
+	if freeze == nil || freeze.Speed == 0 {
+		return
+	}
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("SlowMotionIndicator"),
+		Layout: clay.LayoutConfig{
+			Padding: clay.Padding{Left: tpu(8), Right: tpu(8), Top: tpu(3), Bottom: tpu(3)},
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:   clay.Vector2{X: dp(10), Y: dp(10)},
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_PARENT,
+		},
+		BackgroundColor: theme.Error,
+		CornerRadius:    clay.CornerRadiusAll(dp(3)),
+	}, func() {
+		safeText("slowmo-indicator", tr("slowmo.indicator", formatSpeed(freeze.Speed)), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  16,
+			TextColor: theme.AccentText,
+		})
+	})
+}
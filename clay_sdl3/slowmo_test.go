@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowMotionAdvance(t *testing.T) {
+	appData := &CameraAppData{Cameras: []CameraInstance{{Info: CameraInfo{Name: "bench"}, Active: true, History: testHistory(t, 10)}}}
+	camera := &appData.Cameras[0]
+	startSlowMotion(appData, camera, 0.5)
+	freeze := camera.Freeze
+	if freeze == nil || len(freeze.Frames) != 10 {
+		t.Fatal("slow motion did not start")
+	}
+	if _, ok := freeze.take(); !ok || freeze.Index != 0 {
+		t.Fatal("slow motion does not start at the oldest frame")
+	}
+
+	// Frames 40ms apart follow each other every 80ms at half speed
+	start := freeze.started
+	for _, step := range []struct {
+		after time.Duration
+		index int
+	}{{79 * time.Millisecond, 0}, {80 * time.Millisecond, 1}, {250 * time.Millisecond, 3}, {720 * time.Millisecond, 9}} {
+		if !freeze.advance(start.Add(step.after)) || freeze.Index != step.index {
+			t.Errorf("after %v at frame %d, expected %d", step.after, freeze.Index, step.index)
+		}
+	}
+	if freeze.advance(start.Add(720*time.Millisecond + slowMotionHold)) {
+		t.Error("slow motion did not end")
+	}
+
+	// Stepping stops the review where it is
+	freeze.step(-2)
+	if !freeze.advance(start.Add(time.Hour)) || freeze.Index != 7 {
+		t.Errorf("stopped review at frame %d", freeze.Index)
+	}
+}
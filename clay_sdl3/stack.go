@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+)
+
+// stackFrames is how many frames a stack combines, set with -stack
+var stackFrames = 32
+
+type stackMode int
+
+const (
+	// stackAverage averages the frames, lowering the noise like a long
+	// exposure
+	stackAverage stackMode = iota
+	// stackMax keeps the brightest value of each pixel, for star trails or
+	// tracing a moving light
+	stackMax
+)
+
+// FrameStack combines consecutive frames of a camera into one still. While it
+// runs, the camera views show the stack accumulated so far.
+type FrameStack struct {
+	Mode   stackMode
+	Target int
+	Count  int
+	bounds image.Rectangle
+	// sum holds the channel totals for stackAverage, max the brightest
+	// values for stackMax
+	sum []uint32
+	max []uint8
+}
+
+// startStack begins stacking the next frames of a camera
+func startStack(appData *CameraAppData, index int, mode stackMode) {
+	camera := &appData.Cameras[index]
+	if !camera.Active || camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+	if overMemoryBudget() {
+		setErrorStatus(appData, trErr("error.memory_budget"))
+		return
+	}
+	camera.FrameMutex.Lock()
+	camera.Stack = &FrameStack{Mode: mode, Target: max(stackFrames, 1)}
+	camera.FrameMutex.Unlock()
+}
+
+// cancelStack stops stacking without saving
+func cancelStack(camera *CameraInstance) {
+	camera.FrameMutex.Lock()
+	camera.Stack = nil
+	camera.FrameMutex.Unlock()
+}
+
+// accumulateStack adds a frame to the camera's stack and replaces the frame
+// with the stack so far, as a live preview. It is called with FrameMutex
+// held.
+func accumulateStack(camera *CameraInstance, img *image.RGBA) {
+	s := camera.Stack
+	if s == nil || s.Count >= s.Target {
+		return
+	}
+	pix := img.Pix
+	if s.Count == 0 || img.Bounds() != s.bounds {
+		s.Count = 0
+		s.bounds = img.Bounds()
+		s.sum = nil
+		s.max = nil
+	}
+
+	switch s.Mode {
+	case stackAverage:
+		if s.sum == nil {
+			s.sum = make([]uint32, len(pix))
+		}
+		s.Count++
+		count := uint32(s.Count)
+		for i, v := range pix {
+			s.sum[i] += uint32(v)
+			pix[i] = uint8((s.sum[i] + count/2) / count)
+		}
+	case stackMax:
+		if s.max == nil {
+			s.max = make([]uint8, len(pix))
+		}
+		s.Count++
+		for i, v := range pix {
+			s.max[i] = max(s.max[i], v)
+			pix[i] = s.max[i]
+		}
+	}
+}
+
+// Image returns the combined frame. Averages are returned with 16 bits per
+// channel, since averaging gains precision beyond 8 bits.
+func (s *FrameStack) Image() image.Image {
+	if s.Mode == stackMax {
+		img := image.NewRGBA(s.bounds)
+		copy(img.Pix, s.max)
+		return img
+	}
+
+	img := image.NewRGBA64(s.bounds)
+	count := uint32(s.Count)
+	for i := 0; i+3 < len(s.sum); i += 4 {
+		p := i / 4
+		x := s.bounds.Min.X + p%s.bounds.Dx()
+		y := s.bounds.Min.Y + p/s.bounds.Dx()
+		img.SetRGBA64(x, y, color.RGBA64{
+			R: uint16(s.sum[i] * 257 / count),
+			G: uint16(s.sum[i+1] * 257 / count),
+			B: uint16(s.sum[i+2] * 257 / count),
+			A: 0xffff,
+		})
+	}
+	return img
+}
+
+// saveStack writes a finished stack as a PNG file in the snapshots folder
+func saveStack(camera *CameraInstance, s *FrameStack) (string, error) {
+	path, err := outputPath(snapshotDir, camera, "png")
+	if err != nil {
+		return "", err
+	}
+	f, err := createOutputFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to save stack: %w", err)
+	}
+	if err := png.Encode(f, s.Image()); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to encode stack: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to save stack: %w", err)
+	}
+	if err := saveMetadataSidecar(path, camera); err != nil {
+		log.Printf("Stack of %s: %v", camera.Info.Name, err)
+	}
+	return path, nil
+}
+
+// updateStacks shows the progress of running stacks and saves the finished
+// ones
+func updateStacks(appData *CameraAppData) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		camera.FrameMutex.RLock()
+		s := camera.Stack
+		camera.FrameMutex.RUnlock()
+		if s == nil {
+			continue
+		}
+		if s.Count < s.Target {
+			if i == appData.SelectedCamera {
+				appData.StatusText = tr("status.stacking", camera.Info.Name, s.Count, s.Target)
+			}
+			continue
+		}
+
+		cancelStack(camera)
+		path, err := saveStack(camera, s)
+		if err != nil {
+			setErrorStatus(appData, err)
+			continue
+		}
+		appData.StatusText = tr("status.saved", path)
+	}
+}
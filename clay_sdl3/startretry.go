@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cameras that fail to start are retried with a growing delay: at boot a
+// camera may enumerate seconds before it accepts streaming, and an app
+// started by systemd would otherwise leave it inactive until restarted.
+
+// retryPolicy is how often and how patiently a camera is retried. The delay
+// doubles after every attempt, up to maxRetryDelay.
+type retryPolicy struct {
+	Attempts int
+	Delay    time.Duration
+}
+
+// maxRetryDelay caps the delay between attempts
+const maxRetryDelay = 30 * time.Second
+
+// defaultRetryPolicy applies to cameras without their own -start-retry
+var defaultRetryPolicy = retryPolicy{Attempts: 5, Delay: time.Second}
+
+// cameraRetryPolicies are the policies set with -start-retry by device path
+var cameraRetryPolicies = make(map[string]retryPolicy)
+
+// parseRetryPolicy adds a -start-retry value, "[DEVICE=]ATTEMPTS[,DELAY]"
+func parseRetryPolicy(value string) error {
+	path, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		path, spec = "", value
+	}
+	attempts, delay, hasDelay := strings.Cut(spec, ",")
+	policy := retryPolicy{Delay: defaultRetryPolicy.Delay}
+	var err error
+	if policy.Attempts, err = strconv.Atoi(attempts); err != nil || policy.Attempts < 0 {
+		return fmt.Errorf("invalid start retry %q, expected [DEVICE=]ATTEMPTS[,DELAY]", value)
+	}
+	if hasDelay {
+		if policy.Delay, err = time.ParseDuration(delay); err != nil || policy.Delay <= 0 {
+			return fmt.Errorf("invalid start retry delay %q", delay)
+		}
+	}
+	if path == "" {
+		defaultRetryPolicy = policy
+	} else {
+		cameraRetryPolicies[path] = policy
+	}
+	return nil
+}
+
+// retryPolicyFor returns the policy of a camera
+func retryPolicyFor(path string) retryPolicy {
+	if policy, ok := cameraRetryPolicies[path]; ok {
+		return policy
+	}
+	return defaultRetryPolicy
+}
+
+// StartRetry tracks the retries of a camera that failed to start
+type StartRetry struct {
+	Attempt int
+	Next    time.Time
+	Delay   time.Duration
+}
+
+// scheduleStartRetry plans the first retry of a camera that failed to start.
+// It returns false if the camera's policy does not retry.
+func scheduleStartRetry(camera *CameraInstance) bool {
+	policy := retryPolicyFor(camera.Info.Path)
+	if policy.Attempts == 0 {
+		return false
+	}
+	camera.StartRetry = &StartRetry{Next: time.Now().Add(policy.Delay), Delay: policy.Delay}
+	return true
+}
+
+// retryFailedCameras retries the cameras whose next attempt is due. It is
+// called from the main loop, which owns the renderer the textures are
+// created with.
+func retryFailedCameras(appData *CameraAppData) {
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		retry := camera.StartRetry
+		if retry == nil {
+			continue
+		}
+		if camera.Active || camera.Disabled {
+			camera.StartRetry = nil
+			continue
+		}
+		if time.Now().Before(retry.Next) {
+			continue
+		}
+
+		policy := retryPolicyFor(camera.Info.Path)
+		retry.Attempt++
+		err := enableCamera(camera, appData.Renderer)
+		if err == nil {
+			camera.StartRetry = nil
+			log.Printf("Camera %s started on retry %d", camera.Info.Name, retry.Attempt)
+			appData.StatusText = tr("status.retry_started", camera.Info.Name)
+			appData.StatusColor = theme.Success
+			notify(toastInfo, appData.StatusText)
+			continue
+		}
+		if retry.Attempt >= policy.Attempts {
+			camera.StartRetry = nil
+			log.Printf("Giving up on camera %s: %v", camera.Info.Name, err)
+			setErrorStatus(appData, trErr("error.retry_failed", camera.Info.Name, retry.Attempt))
+			continue
+		}
+		retry.Delay = min(retry.Delay*2, maxRetryDelay)
+		retry.Next = time.Now().Add(retry.Delay)
+		log.Printf("Camera %s retry %d/%d failed, next in %s: %v", camera.Info.Name, retry.Attempt, policy.Attempts, retry.Delay, err)
+	}
+}
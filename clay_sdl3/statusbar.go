@@ -0,0 +1,329 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+	"golang.org/x/sys/unix"
+)
+
+// The status bar shows the last message on the left and, on the right, a
+// colored dot for the health of each camera, the UI frame rate, a recording
+// indicator, the free space left for recordings and the number of API
+// stream clients. Clicking one of them shows its details as the message.
+// The figures are sampled once a second, disk space less often.
+
+// statusSampleInterval is how often frame rates and frame loss are sampled
+const statusSampleInterval = time.Second
+
+// diskSampleInterval is how often the free disk space is checked
+const diskSampleInterval = 5 * time.Second
+
+// cameraHealth is what a camera's status dot shows
+type cameraHealth int
+
+const (
+	// healthOK cameras deliver frames without loss
+	healthOK cameraHealth = iota
+	// healthDegraded cameras lost or dropped frames in the last second, or
+	// have not delivered one yet
+	healthDegraded
+	// healthOffline cameras failed to start or stopped delivering
+	healthOffline
+	// healthOff cameras were disabled
+	healthOff
+)
+
+// cameraStatus is the sampled state of a camera
+type cameraStatus struct {
+	FPS float64
+	// Lossy is set if frames were lost or dropped since the last sample
+	Lossy bool
+
+	frames, lost, dropped int64
+}
+
+// StatusBar holds the sampled figures the status bar shows
+type StatusBar struct {
+	Cameras []cameraStatus
+	UIFPS   float64
+	// DiskFree and DiskTotal are the bytes of the file system recordings
+	// go to, 0 if unknown
+	DiskFree, DiskTotal uint64
+
+	draws      int
+	lastSample time.Time
+	lastDisk   time.Time
+}
+
+var statusBar StatusBar
+
+// update counts a drawn UI frame and samples the figures when due
+func (s *StatusBar) update(appData *CameraAppData) {
+	s.draws++
+	now := time.Now()
+	if len(s.Cameras) != len(appData.Cameras) {
+		s.Cameras = make([]cameraStatus, len(appData.Cameras))
+		s.lastSample = time.Time{}
+	}
+	if elapsed := now.Sub(s.lastSample); s.lastSample.IsZero() || elapsed >= statusSampleInterval {
+		seconds := elapsed.Seconds()
+		if s.lastSample.IsZero() {
+			seconds = 0
+		}
+		for i := range appData.Cameras {
+			s.Cameras[i].sample(&appData.Cameras[i], seconds)
+		}
+		if seconds > 0 {
+			s.UIFPS = float64(s.draws) / seconds
+		}
+		s.draws = 0
+		s.lastSample = now
+	}
+	if now.Sub(s.lastDisk) >= diskSampleInterval {
+		s.DiskFree, s.DiskTotal = diskSpace(recordingDir)
+		toasts.checkDisk(s.DiskFree, s.DiskTotal)
+		s.lastDisk = now
+	}
+}
+
+// sample updates a camera's frame rate and loss from its counters, seconds
+// after the last sample, or only takes the counters if seconds is 0
+func (c *cameraStatus) sample(camera *CameraInstance, seconds float64) {
+	var frames int64
+	if counter, ok := framesReceived.Get(camera.Info.Path).(*expvar.Int); ok {
+		frames = counter.Value()
+	}
+	lost := int64(atomic.LoadUint64(&camera.LostFrames))
+	dropped := int64(atomic.LoadUint64(&camera.DroppedFrames))
+	if seconds > 0 {
+		c.FPS = float64(frames-c.frames) / seconds
+		c.Lossy = lost > c.lost || dropped > c.dropped
+	}
+	c.frames, c.lost, c.dropped = frames, lost, dropped
+}
+
+// diskSpace returns the free and total bytes of the file system dir is on,
+// or the working directory's if dir does not exist yet
+func diskSpace(dir string) (free, total uint64) {
+	var stat unix.Statfs_t
+	if unix.Statfs(dir, &stat) != nil && unix.Statfs(".", &stat) != nil {
+		return 0, 0
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize)
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, prefix := float64(bytes), 0
+	for value >= unit && prefix < 4 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix-1])
+}
+
+// healthOf is a camera's health, from its state and its last sample
+func healthOf(camera *CameraInstance, status cameraStatus) cameraHealth {
+	switch {
+	case camera.Disabled:
+		return healthOff
+	case !camera.Active || camera.Offline:
+		return healthOffline
+	case status.Lossy || camera.LastFrameAt.IsZero():
+		return healthDegraded
+	}
+	return healthOK
+}
+
+// healthColor is the color of a status dot
+func healthColor(health cameraHealth) clay.Color {
+	switch health {
+	case healthOK:
+		return theme.Success
+	case healthDegraded:
+		return theme.Warning
+	case healthOffline:
+		return theme.Error
+	}
+	return theme.TextDim
+}
+
+// recordingCameras are the cameras being recorded
+func recordingCameras(appData *CameraAppData) []*CameraInstance {
+	var cameras []*CameraInstance
+	for i := range appData.Cameras {
+		if appData.Cameras[i].Recorder != nil {
+			cameras = append(cameras, &appData.Cameras[i])
+		}
+	}
+	return cameras
+}
+
+// createStatusIndicatorsLayout declares the indicators on the right of the
+// status bar
+func createStatusIndicatorsLayout(data *CameraAppData) {
+	textConfig := clay.TextElementConfig{
+		FontId:    FontIdBody16,
+		FontSize:  12,
+		TextColor: theme.Text,
+	}
+	indicator := func(id string, contents func()) {
+		clay.UI()(clay.ElementDeclaration{
+			Id: SafeID(id),
+			Layout: clay.LayoutConfig{
+				Padding:        clay.Padding{Left: dpu(4), Right: dpu(4)},
+				ChildGap:       dpu(4),
+				ChildAlignment: clay.ChildAlignment{Y: clay.ALIGN_Y_CENTER},
+			},
+			BackgroundColor: func() clay.Color {
+				if clay.Hovered() {
+					return theme.ItemHover
+				}
+				return clay.Color{}
+			}(),
+			CornerRadius: clay.CornerRadiusAll(dp(4)),
+		}, contents)
+	}
+	dot := func(color clay.Color) {
+		clay.UI()(clay.ElementDeclaration{
+			Layout: clay.LayoutConfig{
+				Sizing: clay.Sizing{Width: clay.SizingFixed(dp(10)), Height: clay.SizingFixed(dp(10))},
+			},
+			BackgroundColor: color,
+			CornerRadius:    clay.CornerRadiusAll(dp(5)),
+		}, func() {})
+	}
+
+	if privacy.Enabled {
+		indicator("StatusPrivacy", func() {
+			dot(theme.Error)
+			safeText("status_privacy", tr("status.bar_privacy"), textConfig)
+		})
+	}
+	for i := range data.Cameras {
+		if i >= len(statusBar.Cameras) {
+			break
+		}
+		health := healthOf(&data.Cameras[i], statusBar.Cameras[i])
+		indicator(fmt.Sprintf("StatusCamera%d", i), func() { dot(healthColor(health)) })
+	}
+	indicator("StatusFPS", func() {
+		safeText("status_fps", tr("status.bar_fps", statusBar.UIFPS), textConfig)
+	})
+	if recording := len(recordingCameras(data)); recording > 0 {
+		indicator("StatusRecording", func() {
+			dot(theme.Error)
+			safeText("status_rec", tr("status.bar_rec", recording), textConfig)
+		})
+	}
+	if statusBar.DiskTotal > 0 {
+		indicator("StatusDisk", func() {
+			safeText("status_disk", tr("status.bar_disk", formatBytes(statusBar.DiskFree)), textConfig)
+		})
+	}
+	if next := nextScheduledAction(); next != nil {
+		indicator("StatusSchedule", func() {
+			safeText("status_schedule", tr("status.bar_schedule", next.Name, formatScheduleTime(next.Next, time.Now())), textConfig)
+		})
+	}
+	if mirror != nil {
+		label := mirrorLabel(mirror.Status(), time.Now())
+		indicator("StatusMirror", func() {
+			safeText("status_mirror", label, textConfig)
+		})
+	}
+	if _, history := toasts.snapshot(); len(history) > 0 {
+		indicator("StatusToasts", func() {
+			safeText("status_toasts", tr("status.bar_toasts", len(history)), textConfig)
+		})
+	}
+	if api != nil {
+		indicator("StatusClients", func() {
+			safeText("status_clients", tr("status.bar_clients", api.Clients()), textConfig)
+		})
+	}
+	if kiosk.PIN != "" {
+		label := tr("status.bar_unlocked")
+		if kiosk.Locked {
+			label = tr("status.bar_locked")
+		}
+		indicator("StatusKiosk", func() {
+			safeText("status_kiosk", label, textConfig)
+		})
+	}
+}
+
+// handleStatusBarClick shows the details of the clicked indicator as the
+// status message, and reports whether an indicator was clicked
+func handleStatusBarClick(appData *CameraAppData, x, y float32) bool {
+	if !pointInElement(SafeID("StatusBar"), x, y) {
+		return false
+	}
+	for i := range appData.Cameras {
+		if i < len(statusBar.Cameras) && pointInElement(SafeID(fmt.Sprintf("StatusCamera%d", i)), x, y) {
+			appData.StatusText = cameraHealthDetail(&appData.Cameras[i], statusBar.Cameras[i])
+			appData.StatusColor = healthColor(healthOf(&appData.Cameras[i], statusBar.Cameras[i]))
+			return true
+		}
+	}
+	switch {
+	case pointInElement(SafeID("StatusFPS"), x, y):
+		total := 0.0
+		for _, camera := range statusBar.Cameras {
+			total += camera.FPS
+		}
+		appData.StatusText = tr("status.fps_detail", statusBar.UIFPS, total)
+	case pointInElement(SafeID("StatusRecording"), x, y):
+		var files []string
+		for _, camera := range recordingCameras(appData) {
+			files = append(files, fmt.Sprintf("%s (%s)", camera.Info.Name, filepath.Base(camera.Recorder.Path)))
+		}
+		appData.StatusText = tr("status.rec_detail", strings.Join(files, ", "))
+	case pointInElement(SafeID("StatusDisk"), x, y):
+		appData.StatusText = tr("status.disk_detail", formatBytes(statusBar.DiskFree), formatBytes(statusBar.DiskTotal), recordingDir)
+	case pointInElement(SafeID("StatusSchedule"), x, y):
+		appData.StatusText = scheduleDetail(time.Now())
+	case pointInElement(SafeID("StatusPrivacy"), x, y):
+		appData.StatusText = tr("status.privacy_detail", privacy.Since.Format("15:04"), len(privacy.resume))
+	case pointInElement(SafeID("StatusMirror"), x, y):
+		status := mirror.Status()
+		if !status.Running {
+			mirror.Trigger()
+		}
+		appData.StatusText = mirrorDetail(mirror.dir, status, time.Now())
+	case pointInElement(SafeID("StatusToasts"), x, y):
+		toggleToastDrawer()
+		return true
+	case pointInElement(SafeID("StatusClients"), x, y):
+		appData.StatusText = tr("status.clients_detail", api.Clients())
+	default:
+		return false
+	}
+	appData.StatusColor = theme.Text
+	return true
+}
+
+// cameraHealthDetail describes a camera's health for the status message
+func cameraHealthDetail(camera *CameraInstance, status cameraStatus) string {
+	name := sanitizeText(camera.Info.Name)
+	switch healthOf(camera, status) {
+	case healthOff:
+		return tr("status.health_off", name)
+	case healthOffline:
+		return tr("status.health_offline", name)
+	case healthDegraded:
+		return tr("status.health_degraded", name, status.FPS,
+			atomic.LoadUint64(&camera.LostFrames), atomic.LoadUint64(&camera.DroppedFrames))
+	}
+	return tr("status.health_ok", name, status.FPS)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCameraHealth(t *testing.T) {
+	camera := &CameraInstance{Info: CameraInfo{Path: "test:health"}, Active: true}
+	var status cameraStatus
+	status.sample(camera, 0)
+	if health := healthOf(camera, status); health != healthDegraded {
+		t.Errorf("camera without frames is %d", health)
+	}
+
+	camera.LastFrameAt = time.Now()
+	framesReceived.Add(camera.Info.Path, 30)
+	status.sample(camera, 1)
+	if health := healthOf(camera, status); health != healthOK || status.FPS != 30 {
+		t.Errorf("camera at %g fps is %d", status.FPS, health)
+	}
+
+	atomic.AddUint64(&camera.DroppedFrames, 2)
+	framesReceived.Add(camera.Info.Path, 15)
+	status.sample(camera, 0.5)
+	if health := healthOf(camera, status); health != healthDegraded || status.FPS != 30 {
+		t.Errorf("camera dropping frames at %g fps is %d", status.FPS, health)
+	}
+	status.sample(camera, 1)
+	if health := healthOf(camera, status); health != healthOK {
+		t.Errorf("camera recovered from frame loss is %d", health)
+	}
+
+	camera.Offline = true
+	if health := healthOf(camera, status); health != healthOffline {
+		t.Errorf("offline camera is %d", health)
+	}
+	camera.Disabled = true
+	if health := healthOf(camera, status); health != healthOff {
+		t.Errorf("disabled camera is %d", health)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for bytes, want := range map[uint64]string{
+		0:                 "0 B",
+		1023:              "1023 B",
+		1536:              "1.5 KiB",
+		5 << 30:           "5.0 GiB",
+		3<<40 + 512<<30:   "3.5 TiB",
+		2048 << 40:        "2048.0 TiB",
+		(1 << 20) * 1000:  "1000.0 MiB",
+		(1 << 20) * 1024:  "1.0 GiB",
+		(1 << 10) * 1023:  "1023.0 KiB",
+		(1 << 10) * 10240: "10.0 MiB",
+	} {
+		if got := formatBytes(bytes); got != want {
+			t.Errorf("%d bytes formatted as %q, want %q", bytes, got, want)
+		}
+	}
+}
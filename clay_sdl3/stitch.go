@@ -0,0 +1,385 @@
+package main
+
+import (
+	"image"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// stitchCoarse is the downsampling of the coarse alignment search
+	stitchCoarse = 8
+	// stitchMinOverlap is the smallest overlap tried, as a fraction of the
+	// smaller frame
+	stitchMinOverlap = 0.1
+	// stitchMinScore is the lowest normalized cross-correlation accepted as
+	// a match
+	stitchMinScore = 0.5
+)
+
+// Mosaic stitches two overlapping overhead cameras into one view of a work
+// area too large for either. The second camera's frame is placed at an
+// offset from the first, found once by correlating the overlap, and the
+// latest frames of both are composed into the mosaic as they arrive. Each
+// camera owns the overlap on its side of the line halfway between the frame
+// centers. Perspective correction and calibration should be done first, so
+// the frames only differ by a shift and scale.
+type Mosaic struct {
+	Cameras [2]*CameraInstance
+	Aligned bool
+	// OffsetX and OffsetY are where the second frame starts in the first
+	// frame's pixels, and Scale converts the second camera's pixels to the
+	// first's
+	OffsetX, OffsetY int
+	Scale            float32
+
+	// pending holds the frames used for the alignment
+	pending [2]*image.RGBA
+	canvas  *image.RGBA
+	// plans map the pixels each camera owns from its frame into the canvas
+	plans   [2]mosaicPlan
+	dirty   bool
+	texture *sdl.Texture
+}
+
+// mosaicPlan copies a camera's pixels into the canvas: dst[i] in the canvas
+// comes from src[i] in the frame, both byte offsets
+type mosaicPlan struct {
+	width, height int
+	src, dst      []int32
+}
+
+// mosaic is the active mosaic, nil when not stitching
+var mosaic *Mosaic
+
+// mosaicScale returns how much the second camera's frame is scaled to match
+// the first's, from their calibration
+func mosaicScale(first, second *CameraInstance) float32 {
+	if first.PixelsPerMM > 0 && second.PixelsPerMM > 0 {
+		return first.PixelsPerMM / second.PixelsPerMM
+	}
+	return 1
+}
+
+// startMosaic begins stitching two cameras; the alignment runs once a frame
+// of each has arrived
+func startMosaic(appData *CameraAppData, first, second int) {
+	for _, index := range []int{first, second} {
+		camera := &appData.Cameras[index]
+		if !camera.Active || camera.Disabled {
+			setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+			return
+		}
+	}
+	stopMosaic()
+	a, b := &appData.Cameras[first], &appData.Cameras[second]
+	mosaic = &Mosaic{Cameras: [2]*CameraInstance{a, b}, Scale: mosaicScale(a, b)}
+	selectCamera(appData, first)
+	appData.StatusText = tr("status.stitch_aligning", a.Info.Name, b.Info.Name)
+}
+
+// restoreMosaic stitches two cameras with a saved alignment
+func restoreMosaic(appData *CameraAppData, saved *SessionMosaic) {
+	var cameras [2]*CameraInstance
+	for i := range appData.Cameras {
+		switch appData.Cameras[i].Info.Path {
+		case saved.First:
+			cameras[0] = &appData.Cameras[i]
+		case saved.Second:
+			cameras[1] = &appData.Cameras[i]
+		}
+	}
+	if cameras[0] == nil || cameras[1] == nil || saved.Scale <= 0 {
+		log.Printf("Stitched cameras %s and %s are not connected", saved.First, saved.Second)
+		return
+	}
+	mosaic = &Mosaic{
+		Cameras: cameras,
+		Aligned: true,
+		OffsetX: saved.OffsetX,
+		OffsetY: saved.OffsetY,
+		Scale:   saved.Scale,
+	}
+}
+
+// stopMosaic ends stitching, if active
+func stopMosaic() {
+	if mosaic == nil {
+		return
+	}
+	if mosaic.texture != nil {
+		mosaic.texture.Destroy()
+	}
+	mosaic = nil
+}
+
+// mosaicShown reports whether the main view shows the mosaic instead of the
+// selected camera
+func mosaicShown(appData *CameraAppData) bool {
+	if mosaic == nil || !mosaic.Aligned || appData.SelectedCamera >= len(appData.Cameras) {
+		return false
+	}
+	selected := &appData.Cameras[appData.SelectedCamera]
+	return selected == mosaic.Cameras[0] || selected == mosaic.Cameras[1]
+}
+
+// AddFrame takes a processed frame of either stitched camera. It is called
+// with the camera's FrameMutex held.
+func (m *Mosaic) AddFrame(camera *CameraInstance, img *image.RGBA) {
+	for i, stitched := range m.Cameras {
+		if stitched != camera {
+			continue
+		}
+		if !m.Aligned {
+			m.pending[i] = img
+			return
+		}
+		m.compose(i, img)
+	}
+}
+
+// updateMosaic aligns the cameras once both have delivered a frame
+func updateMosaic(appData *CameraAppData) {
+	m := mosaic
+	if m == nil || m.Aligned || m.pending[0] == nil || m.pending[1] == nil {
+		return
+	}
+	started := time.Now()
+	second := scaleFrame(m.pending[1], m.Scale)
+	dx, dy, score, ok := alignFrames(m.pending[0], second)
+	m.pending = [2]*image.RGBA{}
+	if !ok {
+		stopMosaic()
+		setErrorStatus(appData, trErr("error.stitch_align", max(score, 0)))
+		return
+	}
+	log.Printf("Stitched %s and %s at offset %d,%d (score %.2f, %v)",
+		m.Cameras[0].Info.Name, m.Cameras[1].Info.Name, dx, dy, score, time.Since(started).Round(time.Millisecond))
+	m.OffsetX, m.OffsetY = dx, dy
+	m.Aligned = true
+	appData.StatusText = tr("status.stitched", m.Cameras[0].Info.Name, m.Cameras[1].Info.Name, dx, dy)
+}
+
+// scaleFrame resizes a frame by a factor with nearest-neighbour sampling
+func scaleFrame(img *image.RGBA, scale float32) *image.RGBA {
+	if scale == 1 {
+		return img
+	}
+	bounds := img.Bounds()
+	width := max(int(float32(bounds.Dx())*scale), 1)
+	height := max(int(float32(bounds.Dy())*scale), 1)
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		sy := min(int(float32(y)/scale), bounds.Dy()-1)
+		for x := range width {
+			sx := min(int(float32(x)/scale), bounds.Dx()-1)
+			copy(out.Pix[y*out.Stride+x*4:y*out.Stride+x*4+4], img.Pix[sy*img.Stride+sx*4:])
+		}
+	}
+	return out
+}
+
+// alignFrames finds where b starts in a's coordinates by maximizing the
+// normalized cross-correlation of their luma over the overlap: an exhaustive
+// search on downsampled frames, refined at full resolution
+func alignFrames(a, b *image.RGBA) (dx, dy int, score float64, ok bool) {
+	aw, ah := a.Bounds().Dx(), a.Bounds().Dy()
+	bw, bh := b.Bounds().Dx(), b.Bounds().Dy()
+	la, lb := frameLuma(a, nil), frameLuma(b, nil)
+	minArea := stitchMinOverlap * float64(min(aw*ah, bw*bh))
+
+	ca, caw, cah := downsample(la, aw, ah, stitchCoarse)
+	cb, cbw, cbh := downsample(lb, bw, bh, stitchCoarse)
+	coarseArea := minArea / (stitchCoarse * stitchCoarse)
+	best := math.Inf(-1)
+	for y := -cbh + 1; y < cah; y++ {
+		for x := -cbw + 1; x < caw; x++ {
+			if s, n := correlate(ca, caw, cah, cb, cbw, cbh, x, y, 1); float64(n) >= coarseArea && s > best {
+				best, dx, dy = s, x, y
+			}
+		}
+	}
+	if math.IsInf(best, -1) {
+		return 0, 0, 0, false
+	}
+
+	// Refine around the coarse match, sampling every other pixel
+	cx, cy := dx*stitchCoarse, dy*stitchCoarse
+	best = math.Inf(-1)
+	for y := cy - stitchCoarse; y <= cy+stitchCoarse; y++ {
+		for x := cx - stitchCoarse; x <= cx+stitchCoarse; x++ {
+			if s, n := correlate(la, aw, ah, lb, bw, bh, x, y, 2); float64(n)*4 >= minArea && s > best {
+				best, dx, dy = s, x, y
+			}
+		}
+	}
+	return dx, dy, best, best >= stitchMinScore
+}
+
+// downsample box-filters a luma image by an integer factor
+func downsample(luma []uint8, width, height, factor int) ([]uint8, int, int) {
+	ow, oh := width/factor, height/factor
+	out := make([]uint8, ow*oh)
+	for y := range oh {
+		for x := range ow {
+			sum := 0
+			for sy := y * factor; sy < (y+1)*factor; sy++ {
+				row := luma[sy*width+x*factor:]
+				for sx := range factor {
+					sum += int(row[sx])
+				}
+			}
+			out[y*ow+x] = uint8(sum / (factor * factor))
+		}
+	}
+	return out, ow, oh
+}
+
+// correlate returns the normalized cross-correlation of the overlap of a
+// and b with b placed at (dx, dy), and how many pixels were compared
+func correlate(a []uint8, aw, ah int, b []uint8, bw, bh int, dx, dy, step int) (float64, int) {
+	x0, x1 := max(0, dx), min(aw, dx+bw)
+	y0, y1 := max(0, dy), min(ah, dy+bh)
+	if x1-x0 < 2 || y1-y0 < 2 {
+		return math.Inf(-1), 0
+	}
+	var sa, sb, saa, sbb, sab float64
+	n := 0
+	for y := y0; y < y1; y += step {
+		rowA, rowB := y*aw, (y-dy)*bw-dx
+		for x := x0; x < x1; x += step {
+			va, vb := float64(a[rowA+x]), float64(b[rowB+x])
+			sa += va
+			sb += vb
+			saa += va * va
+			sbb += vb * vb
+			sab += va * vb
+			n++
+		}
+	}
+	count := float64(n)
+	cov := sab - sa*sb/count
+	varA := saa - sa*sa/count
+	varB := sbb - sb*sb/count
+	if varA <= 0 || varB <= 0 {
+		return math.Inf(-1), n
+	}
+	return cov / math.Sqrt(varA*varB), n
+}
+
+// compose draws a camera's frame into the canvas, building the canvas and
+// the copy plans on the first frame of each size
+func (m *Mosaic) compose(index int, img *image.RGBA) {
+	if index == 1 {
+		img = scaleFrame(img, m.Scale)
+	}
+	bounds := img.Bounds()
+	plan := &m.plans[index]
+	if m.canvas == nil || plan.width != bounds.Dx() || plan.height != bounds.Dy() {
+		m.layout(index, bounds.Dx(), bounds.Dy())
+	}
+	for i, dst := range plan.dst {
+		src := plan.src[i]
+		copy(m.canvas.Pix[dst:dst+3], img.Pix[src:src+3])
+	}
+	m.dirty = true
+}
+
+// layout sizes the canvas for both frames and plans which pixels each
+// camera draws
+func (m *Mosaic) layout(index, width, height int) {
+	m.plans[index].width, m.plans[index].height = width, height
+
+	// Frame rectangles in the first frame's coordinates; a camera without a
+	// frame yet is assumed to have the same size as the other
+	var rects [2]image.Rectangle
+	for i, plan := range m.plans {
+		w, h := plan.width, plan.height
+		if w == 0 {
+			w, h = width, height
+		}
+		rects[i] = image.Rect(0, 0, w, h)
+	}
+	rects[1] = rects[1].Add(image.Pt(m.OffsetX, m.OffsetY))
+	union := rects[0].Union(rects[1])
+
+	if m.canvas == nil || m.canvas.Bounds().Size() != union.Size() {
+		m.canvas = image.NewRGBA(image.Rect(0, 0, union.Dx(), union.Dy()))
+		for i := 3; i < len(m.canvas.Pix); i += 4 {
+			m.canvas.Pix[i] = 255
+		}
+		if m.texture != nil {
+			m.texture.Destroy()
+			m.texture = nil
+		}
+	}
+
+	// The seam is the perpendicular bisector of the frame centers
+	centers := [2]image.Point{
+		rects[0].Min.Add(rects[0].Max).Div(2),
+		rects[1].Min.Add(rects[1].Max).Div(2),
+	}
+	mid := centers[0].Add(centers[1]).Div(2)
+	axis := centers[1].Sub(centers[0])
+	owns := func(i int, p image.Point) bool {
+		other := rects[1-i]
+		if !p.In(other) {
+			return true
+		}
+		side := (p.X-mid.X)*axis.X + (p.Y-mid.Y)*axis.Y
+		if i == 0 {
+			return side <= 0
+		}
+		return side > 0
+	}
+
+	for i, plan := range m.plans {
+		if plan.width == 0 {
+			continue
+		}
+		plan.src, plan.dst = plan.src[:0], plan.dst[:0]
+		for y := range plan.height {
+			for x := range plan.width {
+				p := rects[i].Min.Add(image.Pt(x, y))
+				if !owns(i, p) {
+					continue
+				}
+				c := p.Sub(union.Min)
+				plan.src = append(plan.src, int32(y*plan.width*4+x*4))
+				plan.dst = append(plan.dst, int32(c.Y*m.canvas.Stride+c.X*4))
+			}
+		}
+		m.plans[i] = plan
+	}
+}
+
+// renderMosaic draws the mosaic into the main view, uploading it first if
+// a frame has arrived since the last upload
+func renderMosaic(appData *CameraAppData, rect sdl.FRect) {
+	m := mosaic
+	if m.canvas == nil {
+		return
+	}
+	if m.texture == nil {
+		texture, err := appData.Renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STATIC,
+			m.canvas.Bounds().Dx(), m.canvas.Bounds().Dy())
+		if err != nil {
+			log.Printf("Failed to create mosaic texture: %v", err)
+			return
+		}
+		m.texture = texture
+		m.dirty = true
+	}
+	if m.dirty {
+		if err := m.texture.Update(nil, m.canvas.Pix, int32(m.canvas.Stride)); err != nil {
+			log.Printf("Failed to update mosaic texture: %v", err)
+		}
+		m.dirty = false
+	}
+	if err := appData.Renderer.RenderTexture(m.texture, touchMode.zoomSourceRect(m.texture), &rect); err != nil {
+		log.Printf("Error rendering mosaic texture: %v", err)
+	}
+}
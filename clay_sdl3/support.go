@@ -0,0 +1,224 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// A support bundle is one zip file an operator can attach to a bug report:
+// the recent log, the command line with secrets redacted, what every camera
+// reports it can do and the last frame of each.
+
+const supportDir = "support"
+
+// logHistorySize is how much of the log is kept for support bundles
+const logHistorySize = 256 << 10
+
+// logHistory keeps the tail of the log in memory
+type logHistory struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+var recentLog logHistory
+
+func (h *logHistory) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.data = append(h.data, p...)
+	if excess := len(h.data) - logHistorySize; excess > 0 {
+		h.data = append(h.data[:0], h.data[excess:]...)
+	}
+	return len(p), nil
+}
+
+func (h *logHistory) Bytes() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return bytes.Clone(h.data)
+}
+
+// keepLogHistory copies everything logged from now on into recentLog
+func keepLogHistory() {
+	log.SetOutput(io.MultiWriter(os.Stderr, &recentLog))
+}
+
+var (
+	// credentialsPattern matches user:password@ in URLs and broker addresses
+	credentialsPattern = regexp.MustCompile(`([^\s:/@=]+):[^\s/@]+@`)
+	// secretFlagPattern matches flags whose value is a secret as a whole
+	secretFlagPattern = regexp.MustCompile(`(?i)^--?[a-z-]*(password|token|secret|key|pin)[a-z-]*$`)
+)
+
+// redactSecrets replaces the passwords in an argument or log text
+func redactSecrets(arg string) string {
+	return credentialsPattern.ReplaceAllString(arg, "$1:REDACTED@")
+}
+
+// redactedArgs returns the command line with passwords, tokens and keys
+// replaced
+func redactedArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	secretNext := false
+	for i, arg := range args {
+		name, _, hasValue := strings.Cut(arg, "=")
+		switch {
+		case secretNext:
+			redacted[i] = "REDACTED"
+			secretNext = false
+		case secretFlagPattern.MatchString(name) && hasValue:
+			redacted[i] = name + "=REDACTED"
+		case secretFlagPattern.MatchString(name):
+			redacted[i] = arg
+			secretNext = true
+		default:
+			redacted[i] = redactSecrets(arg)
+		}
+	}
+	return redacted
+}
+
+// describeDevice dumps what a V4L2 camera reports: its driver, formats,
+// frame sizes and rates, and controls
+func describeDevice(w io.Writer, camera *CameraInstance) {
+	dev := camera.Device
+	capability := dev.Capability()
+	fmt.Fprintf(w, "driver: %s, card: %s, bus: %s\n", capability.Driver, capability.Card, capability.BusInfo)
+	if format, err := dev.GetPixFormat(); err == nil {
+		fmt.Fprintf(w, "current format: %s %dx%d\n", formatName(format.PixelFormat), format.Width, format.Height)
+	}
+	if camera.Mode != "" {
+		fmt.Fprintf(w, "mode: %s\n", camera.Mode)
+	}
+
+	descriptions, err := dev.GetFormatDescriptions()
+	if err != nil {
+		fmt.Fprintf(w, "formats: %v\n", err)
+	}
+	for _, description := range descriptions {
+		fmt.Fprintf(w, "format %s (%s)\n", formatName(description.PixelFormat), description.Description)
+		sizes, err := dev.FrameSizes(description.PixelFormat)
+		if err != nil {
+			fmt.Fprintf(w, "  sizes: %v\n", err)
+			continue
+		}
+		for _, size := range sizes {
+			if size.Type != v4l2.FrameSizeTypeDiscrete {
+				fmt.Fprintf(w, "  %dx%d to %dx%d, step %dx%d\n", size.Size.MinWidth, size.Size.MinHeight,
+					size.Size.MaxWidth, size.Size.MaxHeight, size.Size.StepWidth, size.Size.StepHeight)
+				continue
+			}
+			fmt.Fprintf(w, "  %dx%d @ %v fps\n", size.Size.MaxWidth, size.Size.MaxHeight,
+				modeRates(dev, description.PixelFormat, size.Size.MaxWidth, size.Size.MaxHeight))
+		}
+	}
+
+	controls, err := dev.QueryControls()
+	if err != nil {
+		fmt.Fprintf(w, "controls: %v\n", err)
+	}
+	for _, control := range controls {
+		fmt.Fprintf(w, "control %q = %d (%d..%d step %d, default %d)\n", control.Name, control.Value,
+			control.Minimum, control.Maximum, control.Step, control.Default)
+	}
+}
+
+// createSupportBundle writes a support bundle and returns its path
+func createSupportBundle(appData *CameraAppData) (string, error) {
+	if err := os.MkdirAll(supportDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", supportDir, err)
+	}
+	path := filepath.Join(supportDir, "support_"+time.Now().Format("20060102_150405")+".zip")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	archive := zip.NewWriter(file)
+
+	add := func(name string, data []byte) error {
+		w, err := archive.Create(name)
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		return err
+	}
+
+	var summary bytes.Buffer
+	fmt.Fprintf(&summary, "version: %s\n", currentBuild())
+	fmt.Fprintf(&summary, "created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&summary, "command line: %s\n", strings.Join(redactedArgs(os.Args), " "))
+	fmt.Fprintf(&summary, "theme: %s, language: %s\n", theme.Name, language)
+
+	var devices bytes.Buffer
+	var frames []struct {
+		name string
+		data []byte
+	}
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		name := redactSecrets(camera.Info.Name)
+		fmt.Fprintf(&summary, "camera %d: %s (%s) active=%t disabled=%t offline=%t lost=%d dropped=%d\n",
+			i, name, redactSecrets(camera.Info.Path), camera.Active, camera.Disabled, camera.Offline,
+			atomic.LoadUint64(&camera.LostFrames), atomic.LoadUint64(&camera.DroppedFrames))
+
+		if camera.Device != nil {
+			fmt.Fprintf(&devices, "== %s (%s)\n", name, camera.Info.Path)
+			describeDevice(&devices, camera)
+			devices.WriteString("\n")
+		}
+
+		camera.FrameMutex.RLock()
+		data, raw := camera.LastFrame, camera.LastRaw
+		camera.FrameMutex.RUnlock()
+		if len(data) == 0 && raw != nil {
+			var encoded bytes.Buffer
+			if err := jpeg.Encode(&encoded, raw, &jpeg.Options{Quality: 90}); err == nil {
+				data = encoded.Bytes()
+			}
+		}
+		if len(data) > 0 {
+			frames = append(frames, struct {
+				name string
+				data []byte
+			}{fmt.Sprintf("frames/%d_%s.jpg", i, fileSafeName(name)), data})
+		}
+	}
+
+	err = add("summary.txt", summary.Bytes())
+	if err == nil {
+		err = add("devices.txt", devices.Bytes())
+	}
+	if err == nil {
+		err = add("app.log", []byte(redactSecrets(string(recentLog.Bytes()))))
+	}
+	for _, frame := range frames {
+		if err == nil {
+			err = add(frame.name, frame.data)
+		}
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	log.Printf("Created support bundle %s", path)
+	return path, nil
+}
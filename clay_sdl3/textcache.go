@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"unsafe"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/TotallyGamerJet/clay/renderers/sdl3"
+	"github.com/Zyko0/go-sdl3/ttf"
+)
+
+// The Clay renderer shapes every text anew on every frame. The UI instead
+// keeps the shaped texts by font and string and only draws them, so static
+// labels cost nothing per frame; texts that were not drawn for a while,
+// like old values of a counter, are dropped.
+
+// textCacheKeep is how many frames a text that is not drawn stays cached
+const textCacheKeep = 120
+
+// textKey identifies a shaped text
+type textKey struct {
+	font uint16
+	text string
+}
+
+// cachedText is a shaped text and the frame it was last drawn in
+type cachedText struct {
+	text     *ttf.Text
+	lastUsed uint64
+}
+
+// TextCache keeps the shaped texts of the UI between frames
+type TextCache struct {
+	entries map[textKey]*cachedText
+	frame   uint64
+}
+
+var textCache = TextCache{entries: map[textKey]*cachedText{}}
+
+// renderClay draws render commands like sdl3.ClayRender, with the texts
+// taken from the cache
+func renderClay(rendererData *sdl3.RendererData, commands clay.RenderCommandArray) error {
+	if commands.Length == 0 {
+		return nil
+	}
+	all := unsafe.Slice(commands.InternalArray, commands.Length)
+	// Everything between texts goes to the Clay renderer in one batch
+	start := 0
+	flush := func(end int) error {
+		if end == start {
+			return nil
+		}
+		batch := clay.RenderCommandArray{Length: int32(end - start), Capacity: int32(end - start), InternalArray: &all[start]}
+		return sdl3.ClayRender(rendererData, batch)
+	}
+	for i := range all {
+		if all[i].CommandType != clay.RENDER_COMMAND_TYPE_TEXT {
+			continue
+		}
+		if err := flush(i); err != nil {
+			return err
+		}
+		start = i + 1
+		if err := textCache.draw(rendererData, &all[i]); err != nil {
+			return err
+		}
+	}
+	return flush(len(all))
+}
+
+// draw draws a text command, shaping the text only if it is not cached
+func (c *TextCache) draw(rendererData *sdl3.RendererData, command *clay.RenderCommand) error {
+	config := &command.RenderData.Text
+	key := textKey{font: config.FontId, text: config.StringContents.String()}
+	entry, ok := c.entries[key]
+	if !ok {
+		// The command's string lives in Clay's arena
+		key.text = strings.Clone(key.text)
+		text, err := rendererData.TextEngine.CreateText(rendererData.Fonts[config.FontId], key.text)
+		if err != nil {
+			return err
+		}
+		entry = &cachedText{text: text}
+		c.entries[key] = entry
+	}
+	entry.lastUsed = c.frame
+	if err := entry.text.SetColor(uint8(config.TextColor.R), uint8(config.TextColor.G),
+		uint8(config.TextColor.B), uint8(config.TextColor.A)); err != nil {
+		return err
+	}
+	return entry.text.DrawRenderer(command.BoundingBox.X, command.BoundingBox.Y)
+}
+
+// EndFrame counts a drawn frame and now and then drops the texts that were
+// not drawn in the last textCacheKeep frames
+func (c *TextCache) EndFrame() {
+	c.frame++
+	if c.frame%textCacheKeep != 0 {
+		return
+	}
+	for key, entry := range c.entries {
+		if c.frame-entry.lastUsed > textCacheKeep {
+			entry.text.Destroy()
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Clear drops every cached text, e.g. after the font changed
+func (c *TextCache) Clear() {
+	for key, entry := range c.entries {
+		entry.text.Destroy()
+		delete(c.entries, key)
+	}
+}
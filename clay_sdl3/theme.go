@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// Theme is a named palette for the Clay layout
+type Theme struct {
+	Name         string
+	Background   clay.Color
+	Header       clay.Color
+	Panel        clay.Color
+	Sidebar      clay.Color
+	StatusBar    clay.Color
+	Item         clay.Color
+	ItemHover    clay.Color
+	Accent       clay.Color
+	AccentBorder clay.Color
+	AccentText   clay.Color
+	Popup        clay.Color
+	PopupBorder  clay.Color
+	Text         clay.Color
+	TextDim      clay.Color
+	Error        clay.Color
+	Success      clay.Color
+	Warning      clay.Color
+}
+
+var themes = []Theme{
+	{
+		Name:         "dark",
+		Background:   clay.Color{R: 20, G: 20, B: 20, A: 255},
+		Header:       clay.Color{R: 100, G: 50, B: 50, A: 200},
+		Panel:        clay.Color{R: 40, G: 40, B: 40, A: 255},
+		Sidebar:      clay.Color{R: 30, G: 30, B: 30, A: 200},
+		StatusBar:    clay.Color{R: 40, G: 40, B: 40, A: 200},
+		Item:         clay.Color{R: 50, G: 50, B: 50, A: 255},
+		ItemHover:    clay.Color{R: 60, G: 60, B: 60, A: 255},
+		Accent:       clay.Color{R: 0, G: 100, B: 200, A: 255},
+		AccentBorder: clay.Color{R: 0, G: 150, B: 255, A: 255},
+		AccentText:   clay.Color{R: 255, G: 255, B: 255, A: 255},
+		Popup:        clay.Color{R: 45, G: 45, B: 45, A: 250},
+		PopupBorder:  clay.Color{R: 90, G: 90, B: 90, A: 255},
+		Text:         clay.Color{R: 255, G: 255, B: 255, A: 255},
+		TextDim:      clay.Color{R: 160, G: 160, B: 160, A: 255},
+		Error:        clay.Color{R: 255, G: 100, B: 100, A: 255},
+		Success:      clay.Color{R: 100, G: 255, B: 100, A: 255},
+		Warning:      clay.Color{R: 255, G: 255, B: 0, A: 255},
+	},
+	{
+		Name:         "light",
+		Background:   clay.Color{R: 235, G: 235, B: 235, A: 255},
+		Header:       clay.Color{R: 200, G: 130, B: 130, A: 255},
+		Panel:        clay.Color{R: 210, G: 210, B: 210, A: 255},
+		Sidebar:      clay.Color{R: 220, G: 220, B: 220, A: 255},
+		StatusBar:    clay.Color{R: 210, G: 210, B: 210, A: 255},
+		Item:         clay.Color{R: 190, G: 190, B: 190, A: 255},
+		ItemHover:    clay.Color{R: 170, G: 170, B: 170, A: 255},
+		Accent:       clay.Color{R: 0, G: 110, B: 220, A: 255},
+		AccentBorder: clay.Color{R: 0, G: 90, B: 200, A: 255},
+		AccentText:   clay.Color{R: 255, G: 255, B: 255, A: 255},
+		Popup:        clay.Color{R: 245, G: 245, B: 245, A: 255},
+		PopupBorder:  clay.Color{R: 150, G: 150, B: 150, A: 255},
+		Text:         clay.Color{R: 20, G: 20, B: 20, A: 255},
+		TextDim:      clay.Color{R: 90, G: 90, B: 90, A: 255},
+		Error:        clay.Color{R: 200, G: 0, B: 0, A: 255},
+		Success:      clay.Color{R: 0, G: 140, B: 0, A: 255},
+		Warning:      clay.Color{R: 170, G: 120, B: 0, A: 255},
+	},
+	{
+		// Pure black and white with yellow highlights, readable in sunlight
+		Name:         "high-contrast",
+		Background:   clay.Color{R: 0, G: 0, B: 0, A: 255},
+		Header:       clay.Color{R: 0, G: 0, B: 0, A: 255},
+		Panel:        clay.Color{R: 0, G: 0, B: 0, A: 255},
+		Sidebar:      clay.Color{R: 0, G: 0, B: 0, A: 255},
+		StatusBar:    clay.Color{R: 0, G: 0, B: 0, A: 255},
+		Item:         clay.Color{R: 40, G: 40, B: 40, A: 255},
+		ItemHover:    clay.Color{R: 90, G: 90, B: 0, A: 255},
+		Accent:       clay.Color{R: 255, G: 255, B: 0, A: 255},
+		AccentBorder: clay.Color{R: 255, G: 255, B: 0, A: 255},
+		AccentText:   clay.Color{R: 0, G: 0, B: 0, A: 255},
+		Popup:        clay.Color{R: 0, G: 0, B: 0, A: 255},
+		PopupBorder:  clay.Color{R: 255, G: 255, B: 255, A: 255},
+		Text:         clay.Color{R: 255, G: 255, B: 255, A: 255},
+		TextDim:      clay.Color{R: 255, G: 255, B: 255, A: 255},
+		Error:        clay.Color{R: 255, G: 80, B: 80, A: 255},
+		Success:      clay.Color{R: 0, G: 255, B: 0, A: 255},
+		Warning:      clay.Color{R: 255, G: 255, B: 0, A: 255},
+	},
+}
+
+// theme is the active palette
+var theme = &themes[0]
+
+// themeNames lists the available themes for help text
+func themeNames() string {
+	names := make([]string, len(themes))
+	for i := range themes {
+		names[i] = themes[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// setTheme activates the theme with the given name
+func setTheme(name string) error {
+	for i := range themes {
+		if themes[i].Name == name {
+			theme = &themes[i]
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q (available: %s)", name, themeNames())
+}
+
+// cycleTheme switches to the next theme and returns its name
+func cycleTheme() string {
+	for i := range themes {
+		if theme == &themes[i] {
+			theme = &themes[(i+1)%len(themes)]
+			break
+		}
+	}
+	return theme.Name
+}
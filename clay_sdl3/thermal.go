@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// thermalPalette colors the frames of greyscale cameras. Thermal cameras
+// deliver temperatures as brightness, which are far easier to read mapped
+// to a color ramp.
+type thermalPalette int
+
+const (
+	paletteGrey thermalPalette = iota
+	// paletteIronbow runs from black through purple and orange to white,
+	// the usual look of thermal imagers
+	paletteIronbow
+	// paletteRainbow runs from blue through green to red, spreading small
+	// temperature differences over more distinct colors
+	paletteRainbow
+	paletteCount
+)
+
+// paletteNames are the names accepted by -palette, in palette order
+var paletteNames = [paletteCount]string{"grey", "ironbow", "rainbow"}
+
+// defaultPalette is the palette greyscale cameras start with, set with
+// -palette
+var defaultPalette = paletteGrey
+
+// setDefaultPalette selects the palette greyscale cameras start with
+func setDefaultPalette(name string) error {
+	palette, ok := parsePalette(name)
+	if !ok {
+		return fmt.Errorf("unknown palette %q, expected one of %s", name, strings.Join(paletteNames[:], ", "))
+	}
+	defaultPalette = palette
+	return nil
+}
+
+// parsePalette looks up a palette by name
+func parsePalette(name string) (thermalPalette, bool) {
+	for i, n := range paletteNames {
+		if strings.EqualFold(name, n) {
+			return thermalPalette(i), true
+		}
+	}
+	return paletteGrey, false
+}
+
+// colorRamp interpolates a 256-entry palette between evenly spaced stops
+func colorRamp(stops [][3]float32) (palette [256][3]uint8) {
+	for i := range palette {
+		pos := float32(i) / 255 * float32(len(stops)-1)
+		s := min(int(pos), len(stops)-2)
+		t := pos - float32(s)
+		for c := range 3 {
+			palette[i][c] = uint8(stops[s][c]*(1-t) + stops[s+1][c]*t)
+		}
+	}
+	return palette
+}
+
+var thermalPalettes = [paletteCount][256][3]uint8{
+	paletteIronbow: colorRamp([][3]float32{
+		{0, 0, 0},
+		{30, 0, 110},
+		{150, 0, 150},
+		{230, 60, 40},
+		{255, 150, 0},
+		{255, 225, 50},
+		{255, 255, 255},
+	}),
+	paletteRainbow: colorRamp([][3]float32{
+		{0, 0, 140},
+		{0, 0, 255},
+		{0, 255, 255},
+		{0, 255, 0},
+		{255, 255, 0},
+		{255, 0, 0},
+	}),
+}
+
+// isGreyCamera reports whether a camera delivers greyscale frames
+func isGreyCamera(camera *CameraInstance) bool {
+	_, ok := greyFormats[camera.PixFormat.PixelFormat]
+	return ok
+}
+
+// paletteName returns the translated name of a camera's palette
+func paletteName(camera *CameraInstance) string {
+	return tr("palette." + paletteNames[camera.Palette])
+}
+
+// cyclePalette switches a greyscale camera to the next palette
+func cyclePalette(camera *CameraInstance) {
+	camera.FrameMutex.Lock()
+	camera.Palette = (camera.Palette + 1) % paletteCount
+	camera.FrameMutex.Unlock()
+}
+
+// applyPalette colors a greyscale frame in place. It is called with
+// FrameMutex held.
+func applyPalette(camera *CameraInstance, img *image.RGBA) {
+	if camera.Palette == paletteGrey {
+		return
+	}
+	palette := &thermalPalettes[camera.Palette]
+	pix := img.Pix
+	for i := 0; i+3 < len(pix); i += 4 {
+		c := palette[pix[i]]
+		pix[i], pix[i+1], pix[i+2] = c[0], c[1], c[2]
+	}
+}
+
+// stretchGrey16 converts a 16-bit greyscale frame to 8-bit RGBA, stretching
+// the range between the darkest and brightest 0.5% of the pixels over the
+// full range. Thermal scenes often span only a few hundred of the 65536
+// levels and would otherwise show as flat grey.
+func stretchGrey16(src *image.Gray16) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(bounds)
+	if width == 0 || height == 0 {
+		return dst
+	}
+
+	// Histogram on the top 12 bits is precise enough to pick the limits
+	var histogram [4096]int
+	for y := range height {
+		row := src.Pix[y*src.Stride:]
+		for x := range width {
+			histogram[(uint16(row[2*x])<<8|uint16(row[2*x+1]))>>4]++
+		}
+	}
+	clip := width * height / 200
+	low, high := 0, len(histogram)-1
+	for count := 0; low < high && count+histogram[low] <= clip; low++ {
+		count += histogram[low]
+	}
+	for count := 0; high > low && count+histogram[high] <= clip; high-- {
+		count += histogram[high]
+	}
+	lo, hi := low<<4, high<<4|0xf
+	span := max(hi-lo, 1)
+
+	for y := range height {
+		row := src.Pix[y*src.Stride:]
+		out := dst.Pix[y*dst.Stride:]
+		for x := range width {
+			v := int(row[2*x])<<8 | int(row[2*x+1])
+			g := uint8(min(max(v-lo, 0)*255/span, 255))
+			p := out[x*4:]
+			p[0], p[1], p[2], p[3] = g, g, g, 0xff
+		}
+	}
+	return dst
+}
@@ -0,0 +1,52 @@
+package main
+
+import "log"
+
+// Thumbnails are uploaded apart from the frames that change them: a new
+// frame only leaves its image pending for the camera's thumbnail, and each
+// pass of the main loop scales and uploads a few pending thumbnails, taking
+// turns, so that many cameras delivering at once don't make one UI frame
+// much slower than the next. Thumbnails scrolled out of view stay pending
+// until they are shown again.
+
+// thumbnailUpdates is how many thumbnails a pass of the main loop uploads
+// at most, 0 for all pending ones; set with -thumbnail-updates
+var thumbnailUpdates = 2
+
+// thumbnailCursor is the camera the next pass starts looking at
+var thumbnailCursor int
+
+// updateThumbnails uploads pending thumbnails of visible cameras, round-robin
+// from where the last pass stopped
+func updateThumbnails(appData *CameraAppData) {
+	if backgroundPaused() {
+		return
+	}
+	count := len(appData.Cameras)
+	updated := 0
+	for k := range count {
+		if thumbnailUpdates > 0 && updated >= thumbnailUpdates {
+			return
+		}
+		i := (thumbnailCursor + k) % count
+		camera := &appData.Cameras[i]
+		if !camera.ThumbnailVisible || camera.ThumbnailTexture == nil {
+			continue
+		}
+		camera.FrameMutex.Lock()
+		source := camera.ThumbnailPending
+		camera.ThumbnailPending = nil
+		camera.FrameMutex.Unlock()
+		if source == nil {
+			continue
+		}
+
+		// Scale down the image for thumbnail
+		thumbnailImg := scaleImage(source, 4)
+		if err := camera.ThumbnailTexture.Update(nil, thumbnailImg.Pix, int32(thumbnailImg.Stride)); err != nil {
+			log.Printf("Error updating thumbnail of camera %s: %v", camera.Info.Name, err)
+		}
+		updated++
+		thumbnailCursor = i + 1
+	}
+}
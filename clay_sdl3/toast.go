@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// Problems that used to show only in the log, such as a camera that stops
+// capturing, a failed recording or a disk running full, pop up as toasts
+// above the status bar. They go away by themselves after a few seconds, or
+// when clicked, and stay in a history drawer opened from the status bar or
+// with N. notify may be called from the capture goroutines; the same
+// message repeated while its toast is up bumps a counter instead of
+// stacking up.
+
+// toastDuration is how long info and warning toasts stay up
+const toastDuration = 5 * time.Second
+
+// errorToastDuration is how long error toasts stay up
+const errorToastDuration = 8 * time.Second
+
+// maxToasts is how many toasts are shown at once; the oldest go first
+const maxToasts = 4
+
+// toastHistorySize is how many toasts the history drawer keeps
+const toastHistorySize = 100
+
+// lowDiskSpace is the free space below which a toast warns that recordings
+// are about to fail
+const lowDiskSpace = 1 << 30
+
+// toastSeverity is how serious a toast is, which sets its color
+type toastSeverity int
+
+const (
+	toastInfo toastSeverity = iota
+	toastWarning
+	toastError
+)
+
+// Toast is a notification
+type Toast struct {
+	Severity toastSeverity
+	Message  string
+	// At is when the message was last raised
+	At time.Time
+	// Count is how many times it was raised while up
+	Count int
+}
+
+// Toasts holds the toasts shown and the history
+type Toasts struct {
+	mu      sync.Mutex
+	active  []*Toast
+	history []*Toast
+	// DrawerOpen shows the history drawer
+	DrawerOpen bool
+	// diskLow is set while the low disk space warning is due
+	diskLow bool
+}
+
+var toasts Toasts
+
+// notify raises a toast; it is safe to call from any goroutine
+func notify(severity toastSeverity, message string) {
+	toasts.add(severity, message, time.Now())
+}
+
+// add raises a toast at a time, or refreshes the one up with the same
+// message
+func (t *Toasts) add(severity toastSeverity, message string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, toast := range t.active {
+		if toast.Message == message && toast.Severity == severity {
+			toast.At = at
+			toast.Count++
+			return
+		}
+	}
+	toast := &Toast{Severity: severity, Message: message, At: at, Count: 1}
+	t.active = append(t.active, toast)
+	if len(t.active) > maxToasts {
+		t.active = t.active[len(t.active)-maxToasts:]
+	}
+	t.history = append(t.history, toast)
+	if len(t.history) > toastHistorySize {
+		t.history = t.history[len(t.history)-toastHistorySize:]
+	}
+}
+
+// expire takes down the toasts that were up long enough at now
+func (t *Toasts) expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := t.active[:0]
+	for _, toast := range t.active {
+		if now.Sub(toast.At) < toast.duration() {
+			kept = append(kept, toast)
+		}
+	}
+	clear(t.active[len(kept):])
+	t.active = kept
+}
+
+// dismiss takes down a shown toast
+func (t *Toasts) dismiss(index int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if index >= 0 && index < len(t.active) {
+		t.active = append(t.active[:index], t.active[index+1:]...)
+	}
+}
+
+// snapshot copies the toasts shown and the history, newest last
+func (t *Toasts) snapshot() (active, history []Toast) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, toast := range t.active {
+		active = append(active, *toast)
+	}
+	for _, toast := range t.history {
+		history = append(history, *toast)
+	}
+	return active, history
+}
+
+// checkDisk warns once each time the free space for recordings drops below
+// lowDiskSpace
+func (t *Toasts) checkDisk(free, total uint64) {
+	if total == 0 {
+		return
+	}
+	low := free < lowDiskSpace
+	if low && !t.diskLow {
+		notify(toastWarning, tr("toast.disk_low", formatBytes(free), recordingDir))
+	}
+	t.diskLow = low
+}
+
+// duration is how long a toast stays up
+func (toast *Toast) duration() time.Duration {
+	if toast.Severity == toastError {
+		return errorToastDuration
+	}
+	return toastDuration
+}
+
+// severityColor is the color of a toast's bar
+func severityColor(severity toastSeverity) clay.Color {
+	switch severity {
+	case toastError:
+		return theme.Error
+	case toastWarning:
+		return theme.Warning
+	}
+	return theme.Accent
+}
+
+// toastText is a toast's message with its repeat count
+func toastText(toast Toast) string {
+	text := sanitizeText(toast.Message)
+	if toast.Count > 1 {
+		text = tr("toast.repeated", text, toast.Count)
+	}
+	return text
+}
+
+// toggleToastDrawer opens or closes the history drawer
+func toggleToastDrawer() {
+	toasts.DrawerOpen = !toasts.DrawerOpen
+}
+
+// createToastsLayout declares the toasts above the right end of the status
+// bar, and the history drawer when open
+func createToastsLayout(data *CameraAppData) {
+	toasts.expire(time.Now())
+	active, history := toasts.snapshot()
+	textConfig := clay.TextElementConfig{
+		FontId:    FontIdBody16,
+		FontSize:  12,
+		TextColor: theme.Text,
+	}
+	entry := func(id string, toast Toast) {
+		clay.UI()(clay.ElementDeclaration{
+			Id: SafeID(id),
+			Layout: clay.LayoutConfig{
+				Sizing:         clay.Sizing{Width: clay.SizingGrow(0)},
+				ChildGap:       dpu(8),
+				ChildAlignment: clay.ChildAlignment{Y: clay.ALIGN_Y_CENTER},
+			},
+		}, func() {
+			clay.UI()(clay.ElementDeclaration{
+				Layout: clay.LayoutConfig{
+					Sizing: clay.Sizing{Width: clay.SizingFixed(dp(4)), Height: clay.SizingFixed(dp(20))},
+				},
+				BackgroundColor: severityColor(toast.Severity),
+				CornerRadius:    clay.CornerRadiusAll(dp(2)),
+			}, func() {})
+			safeText(id+"-text", toastText(toast), textConfig)
+		})
+	}
+
+	if len(active) > 0 && !toasts.DrawerOpen {
+		clay.UI()(clay.ElementDeclaration{
+			Id: SafeID("Toasts"),
+			Layout: clay.LayoutConfig{
+				LayoutDirection: clay.TOP_TO_BOTTOM,
+				Sizing:          clay.Sizing{Width: clay.SizingFixed(tp(360))},
+				ChildGap:        dpu(6),
+			},
+			Floating: clay.FloatingElementConfig{
+				Offset:   clay.Vector2{X: -dp(16), Y: -dp(56)},
+				ZIndex:   overlayZIndex,
+				AttachTo: clay.ATTACH_TO_ROOT,
+				AttachPoints: clay.FloatingAttachPoints{
+					Element: clay.ATTACH_POINT_RIGHT_BOTTOM,
+					Parent:  clay.ATTACH_POINT_RIGHT_BOTTOM,
+				},
+			},
+		}, func() {
+			for i, toast := range active {
+				clay.UI()(clay.ElementDeclaration{
+					Id: SafeID(fmt.Sprintf("Toast%d", i)),
+					Layout: clay.LayoutConfig{
+						Sizing:  clay.Sizing{Width: clay.SizingGrow(0)},
+						Padding: clay.PaddingAll(dpu(8)),
+					},
+					BackgroundColor: theme.Popup,
+					CornerRadius:    clay.CornerRadiusAll(dp(6)),
+					Border: clay.BorderElementConfig{
+						Color: severityColor(toast.Severity),
+						Width: clay.BorderOutside(dpu(1)),
+					},
+				}, func() {
+					entry(fmt.Sprintf("ToastEntry%d", i), toast)
+				})
+			}
+		})
+	}
+
+	if !toasts.DrawerOpen {
+		return
+	}
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("ToastDrawer"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Sizing: clay.Sizing{
+				Width:  clay.SizingFixed(tp(420)),
+				Height: clay.SizingFit(0, dp(400)),
+			},
+			Padding:  clay.PaddingAll(dpu(12)),
+			ChildGap: dpu(6),
+		},
+		Floating: clay.FloatingElementConfig{
+			Offset:   clay.Vector2{X: -dp(16), Y: -dp(56)},
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_RIGHT_BOTTOM,
+				Parent:  clay.ATTACH_POINT_RIGHT_BOTTOM,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(6)),
+		Border: clay.BorderElementConfig{
+			Color: theme.AccentBorder,
+			Width: clay.BorderOutside(dpu(2)),
+		},
+	}, func() {
+		safeText("toast-drawer-title", tr("toast.history"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+		if len(history) == 0 {
+			safeText("toast-drawer-empty", tr("toast.none"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.TextDim,
+			})
+		}
+		clay.UI()(clay.ElementDeclaration{
+			Id: SafeID("ToastHistory"),
+			Layout: clay.LayoutConfig{
+				LayoutDirection: clay.TOP_TO_BOTTOM,
+				Sizing:          clay.Sizing{Width: clay.SizingGrow(0), Height: clay.SizingGrow(0)},
+				ChildGap:        dpu(4),
+			},
+			Clip: clay.ClipElementConfig{Vertical: true, ChildOffset: clay.GetScrollOffset()},
+		}, func() {
+			// Newest first
+			for i := len(history) - 1; i >= 0; i-- {
+				toast := history[i]
+				toast.Message = tr("toast.at", toast.At.Format("15:04:05"), toast.Message)
+				entry(fmt.Sprintf("ToastHistory%d", i), toast)
+			}
+		})
+		clay.UI()(clay.ElementDeclaration{
+			Id: SafeID("ToastDrawerClose"),
+			Layout: clay.LayoutConfig{
+				Padding: clay.Padding{Left: dpu(8), Right: dpu(8), Top: dpu(4), Bottom: dpu(4)},
+			},
+			BackgroundColor: func() clay.Color {
+				if clay.Hovered() {
+					return theme.ItemHover
+				}
+				return theme.Item
+			}(),
+			CornerRadius: clay.CornerRadiusAll(dp(4)),
+		}, func() {
+			safeText("toast-drawer-close", tr("toast.close"), clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.Text,
+			})
+		})
+	})
+}
+
+// handleToastsClick dismisses a clicked toast and handles clicks in the
+// history drawer, and reports whether the click was on one of them
+func handleToastsClick(x, y float32) bool {
+	if toasts.DrawerOpen && pointInElement(SafeID("ToastDrawer"), x, y) {
+		if pointInElement(SafeID("ToastDrawerClose"), x, y) {
+			toasts.DrawerOpen = false
+		}
+		return true
+	}
+	if toasts.DrawerOpen || !pointInElement(SafeID("Toasts"), x, y) {
+		return false
+	}
+	for i := range maxToasts {
+		if pointInElement(SafeID(fmt.Sprintf("Toast%d", i)), x, y) {
+			toasts.dismiss(i)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestToasts(t *testing.T) {
+	var queue Toasts
+	start := time.Now()
+	queue.add(toastError, "capture stopped", start)
+	queue.add(toastError, "capture stopped", start.Add(time.Second))
+	queue.add(toastInfo, "camera started", start.Add(time.Second))
+
+	active, history := queue.snapshot()
+	if len(active) != 2 || active[0].Count != 2 || len(history) != 2 {
+		t.Fatalf("repeated toast not merged: %+v", active)
+	}
+
+	// The info toast goes first, the error stays up longer
+	queue.expire(start.Add(time.Second + toastDuration))
+	if active, _ = queue.snapshot(); len(active) != 1 || active[0].Severity != toastError {
+		t.Errorf("after %s: %+v", toastDuration, active)
+	}
+	queue.expire(start.Add(time.Second + errorToastDuration))
+	if active, history = queue.snapshot(); len(active) != 0 || len(history) != 2 {
+		t.Errorf("expired toasts: %d shown, %d in the history", len(active), len(history))
+	}
+
+	// Once down, the same message raises a new toast
+	queue.add(toastError, "capture stopped", start.Add(time.Minute))
+	if active, _ = queue.snapshot(); len(active) != 1 || active[0].Count != 1 {
+		t.Errorf("raised again: %+v", active)
+	}
+	queue.dismiss(0)
+	if active, _ = queue.snapshot(); len(active) != 0 {
+		t.Errorf("dismissed toast still up: %+v", active)
+	}
+}
+
+func TestToastLimits(t *testing.T) {
+	var queue Toasts
+	now := time.Now()
+	for i := range toastHistorySize + 10 {
+		queue.add(toastWarning, fmt.Sprintf("warning %d", i), now)
+	}
+	active, history := queue.snapshot()
+	if len(active) != maxToasts || active[maxToasts-1].Message != fmt.Sprintf("warning %d", toastHistorySize+9) {
+		t.Errorf("%d toasts shown, newest %q", len(active), active[len(active)-1].Message)
+	}
+	if len(history) != toastHistorySize || history[0].Message != "warning 10" {
+		t.Errorf("history of %d starts with %q", len(history), history[0].Message)
+	}
+}
+
+func TestLowDiskToast(t *testing.T) {
+	toasts = Toasts{}
+	defer func() { toasts = Toasts{} }()
+
+	toasts.checkDisk(lowDiskSpace/2, 8*lowDiskSpace)
+	toasts.checkDisk(lowDiskSpace/4, 8*lowDiskSpace)
+	if _, history := toasts.snapshot(); len(history) != 1 || history[0].Severity != toastWarning {
+		t.Fatalf("low disk warned %d times", len(history))
+	}
+	toasts.checkDisk(2*lowDiskSpace, 8*lowDiskSpace)
+	toasts.checkDisk(lowDiskSpace/8, 8*lowDiskSpace)
+	if _, history := toasts.snapshot(); len(history) != 2 {
+		t.Errorf("low disk after freeing space warned %d times in all", len(history))
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"math"
+
+	"github.com/TotallyGamerJet/clay"
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// touchTargetScale enlarges clickable elements in touch mode
+	touchTargetScale = 1.75
+	// swipeDistance is how far a finger must travel across the main view,
+	// in layout units, to switch cameras
+	swipeDistance = 80
+	maxZoom       = 8
+)
+
+// TouchState tracks touch mode and the gestures on the main camera view
+type TouchState struct {
+	Enabled bool
+
+	// fingers holds the position of each finger on screen, in pixels
+	fingers map[sdl.FingerID]sdl.FPoint
+	// swipeStart is where the first finger went down. swipe is set while
+	// the gesture started over the main view and can still become a swipe.
+	swipeStart sdl.FPoint
+	swipe      bool
+	// pinchDistance is the distance between the two fingers of a pinch
+	pinchDistance float32
+
+	// Zoom and the pan center, as a fraction of the frame, apply to the
+	// main camera view
+	Zoom    float32
+	CenterX float32
+	CenterY float32
+}
+
+// touchMode is read by the layout code to size hit targets
+var touchMode = &TouchState{Zoom: 1, CenterX: 0.5, CenterY: 0.5}
+
+// tp scales the size of a clickable element to pixels, enlarging it in touch
+// mode so it is easy to hit with a finger
+func tp(v float32) float32 {
+	if touchMode.Enabled {
+		return dp(v * touchTargetScale)
+	}
+	return dp(v)
+}
+
+// tpu is tp for paddings
+func tpu(v uint16) uint16 {
+	if touchMode.Enabled {
+		return dpu(uint16(float32(v)*touchTargetScale + 0.5))
+	}
+	return dpu(v)
+}
+
+// resetZoom shows the whole frame in the main view
+func (t *TouchState) resetZoom() {
+	t.Zoom, t.CenterX, t.CenterY = 1, 0.5, 0.5
+}
+
+// handleTouchEvent handles a finger event. The first touch switches the UI
+// into touch mode. Touches also arrive as synthesized mouse events, so taps
+// go through the normal click handling.
+func handleTouchEvent(appData *CameraAppData, event *sdl.Event) {
+	t := touchMode
+	e := event.TouchFingerEvent()
+	if !t.Enabled {
+		t.Enabled = true
+		appData.StatusText = tr("status.touch")
+	}
+	if t.fingers == nil {
+		t.fingers = make(map[sdl.FingerID]sdl.FPoint)
+	}
+
+	// Finger positions are normalized to the window
+	width, height, err := appData.Window.SizeInPixels()
+	if err != nil {
+		return
+	}
+	point := sdl.FPoint{X: e.X * float32(width), Y: e.Y * float32(height)}
+
+	switch event.Type {
+	case sdl.EVENT_FINGER_DOWN:
+		t.fingers[e.FingerID] = point
+		switch len(t.fingers) {
+		case 1:
+			t.swipeStart = point
+			t.swipe = pointInElement(focusedPaneID(), point.X, point.Y)
+		case 2:
+			// A second finger turns the gesture into a pinch
+			t.swipe = false
+			t.pinchDistance = t.fingerDistance()
+		}
+
+	case sdl.EVENT_FINGER_MOTION:
+		previous, ok := t.fingers[e.FingerID]
+		if !ok {
+			return
+		}
+		t.fingers[e.FingerID] = point
+		switch len(t.fingers) {
+		case 1:
+			if t.Zoom > 1 {
+				// Drag to pan a zoomed view instead of swiping
+				t.swipe = false
+				t.pan(point.X-previous.X, point.Y-previous.Y)
+			}
+		case 2:
+			distance := t.fingerDistance()
+			if t.pinchDistance > 0 && distance > 0 {
+				t.setZoom(t.Zoom * distance / t.pinchDistance)
+			}
+			t.pinchDistance = distance
+		}
+
+	case sdl.EVENT_FINGER_UP, sdl.EVENT_FINGER_CANCELED:
+		delete(t.fingers, e.FingerID)
+		if event.Type == sdl.EVENT_FINGER_UP && t.swipe && len(t.fingers) == 0 {
+			dx, dy := point.X-t.swipeStart.X, point.Y-t.swipeStart.Y
+			if abs32(dx) >= dp(swipeDistance) && abs32(dx) > 2*abs32(dy) {
+				if dx < 0 {
+					stepCamera(appData, 1)
+				} else {
+					stepCamera(appData, -1)
+				}
+			}
+		}
+		t.swipe = false
+		t.pinchDistance = 0
+	}
+}
+
+// fingerDistance returns the distance between the first two fingers down
+func (t *TouchState) fingerDistance() float32 {
+	var points []sdl.FPoint
+	for _, p := range t.fingers {
+		points = append(points, p)
+	}
+	if len(points) < 2 {
+		return 0
+	}
+	return float32(math.Hypot(float64(points[0].X-points[1].X), float64(points[0].Y-points[1].Y)))
+}
+
+// setZoom changes the zoom of the main view, keeping the visible region
+// inside the frame
+func (t *TouchState) setZoom(zoom float32) {
+	t.Zoom = min(max(zoom, 1), maxZoom)
+	t.clampCenter()
+}
+
+// pan moves a zoomed main view by a finger movement in pixels
+func (t *TouchState) pan(dx, dy float32) {
+	view := clay.GetElementData(focusedPaneID())
+	if !view.Found || view.BoundingBox.Width <= 0 || view.BoundingBox.Height <= 0 {
+		return
+	}
+	// Moving the finger right drags the image right, showing what is left
+	t.CenterX -= dx / view.BoundingBox.Width / t.Zoom
+	t.CenterY -= dy / view.BoundingBox.Height / t.Zoom
+	t.clampCenter()
+}
+
+func (t *TouchState) clampCenter() {
+	half := 0.5 / t.Zoom
+	t.CenterX = min(max(t.CenterX, half), 1-half)
+	t.CenterY = min(max(t.CenterY, half), 1-half)
+}
+
+// zoomSourceRect returns the region of a texture shown at the current zoom,
+// or nil for the whole texture
+func (t *TouchState) zoomSourceRect(texture *sdl.Texture) *sdl.FRect {
+	if t.Zoom <= 1 {
+		return nil
+	}
+	w, h := float32(texture.W)/t.Zoom, float32(texture.H)/t.Zoom
+	return &sdl.FRect{
+		X: t.CenterX*float32(texture.W) - w/2,
+		Y: t.CenterY*float32(texture.H) - h/2,
+		W: w,
+		H: h,
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// createTouchToolbarLayout declares the on-screen buttons shown in the header
+// in touch mode, for switching cameras, snapshots and recording without a
+// keyboard
+func createTouchToolbarLayout(data *CameraAppData) {
+	if !touchMode.Enabled || data.SelectedCamera >= len(data.Cameras) {
+		return
+	}
+	camera := &data.Cameras[data.SelectedCamera]
+
+	recordLabel := tr("menu.start_rec")
+	if camera.Recorder != nil {
+		recordLabel = tr("menu.stop_rec")
+	}
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("TouchToolbar"),
+		Layout: clay.LayoutConfig{
+			ChildGap: tpu(8),
+			ChildAlignment: clay.ChildAlignment{
+				Y: clay.ALIGN_Y_CENTER,
+			},
+		},
+	}, func() {
+		controlButton("TouchPrev", tr("touch.prev"))
+		controlButton("TouchNext", tr("touch.next"))
+		if !kiosk.Locked {
+			controlButton("TouchSnapshot", tr("menu.snapshot"))
+			controlButton("TouchRecord", recordLabel)
+		}
+	})
+}
+
+// handleTouchToolbarClick runs the toolbar button under the pointer. It
+// reports whether the click landed on a button.
+func handleTouchToolbarClick(appData *CameraAppData, x, y float32) bool {
+	if !touchMode.Enabled || appData.SelectedCamera >= len(appData.Cameras) {
+		return false
+	}
+
+	switch {
+	case pointInElement(SafeID("TouchPrev"), x, y):
+		stepCamera(appData, -1)
+	case pointInElement(SafeID("TouchNext"), x, y):
+		stepCamera(appData, 1)
+	case kiosk.Locked:
+		return false
+	case pointInElement(SafeID("TouchSnapshot"), x, y):
+		runContextMenuAction(appData, appData.SelectedCamera, menuSnapshot)
+	case pointInElement(SafeID("TouchRecord"), x, y):
+		runContextMenuAction(appData, appData.SelectedCamera, menuToggleRecording)
+	default:
+		return false
+	}
+	return true
+}
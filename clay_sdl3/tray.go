@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+	"github.com/ebitengine/purego"
+)
+
+// SDL_TrayEntryFlags
+const (
+	trayEntryButton = 0x00000001
+)
+
+// trayFunctions are the SDL tray functions, which go-sdl3 does not bind yet.
+// They are looked up in the SDL library already loaded by binsdl.
+var trayFunctions struct {
+	loaded bool
+
+	CreateTray           func(icon uintptr, tooltip string) uintptr
+	CreateTrayMenu       func(tray uintptr) uintptr
+	InsertTrayEntryAt    func(menu uintptr, pos int32, label string, flags uint32) uintptr
+	SetTrayEntryLabel    func(entry uintptr, label string)
+	SetTrayEntryCallback func(entry uintptr, callback uintptr, userdata uintptr)
+	DestroyTray          func(tray uintptr)
+	GetError             func() string
+}
+
+// trayCallback is the C callback shared by all tray entries; purego has a
+// fixed number of callback slots, so it is only created once.
+var trayCallback uintptr
+
+// Tray is the system tray icon. While it exists, closing the window hides it
+// and the app keeps streaming and recording in the background.
+type Tray struct {
+	handle      uintptr
+	windowEntry uintptr
+	recordEntry uintptr
+	actions     map[uintptr]func()
+
+	// Current entry labels, so Update only calls into SDL on changes
+	windowLabel string
+	recordLabel string
+}
+
+// tray is the active tray icon, or nil when it is disabled or unavailable
+var tray *Tray
+
+func loadTrayFunctions() (err error) {
+	if trayFunctions.loaded {
+		return nil
+	}
+	// purego panics on missing symbols and unsupported platforms
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("system tray unavailable on %s/%s: %v", runtime.GOOS, runtime.GOARCH, r)
+		}
+	}()
+
+	// binsdl has already loaded SDL, so dlopen finds it by its soname
+	lib, err := purego.Dlopen("libSDL3.so.0", purego.RTLD_NOW|purego.RTLD_GLOBAL)
+	if err != nil {
+		return fmt.Errorf("system tray unavailable: %w", err)
+	}
+	purego.RegisterLibFunc(&trayFunctions.CreateTray, lib, "SDL_CreateTray")
+	purego.RegisterLibFunc(&trayFunctions.CreateTrayMenu, lib, "SDL_CreateTrayMenu")
+	purego.RegisterLibFunc(&trayFunctions.InsertTrayEntryAt, lib, "SDL_InsertTrayEntryAt")
+	purego.RegisterLibFunc(&trayFunctions.SetTrayEntryLabel, lib, "SDL_SetTrayEntryLabel")
+	purego.RegisterLibFunc(&trayFunctions.SetTrayEntryCallback, lib, "SDL_SetTrayEntryCallback")
+	purego.RegisterLibFunc(&trayFunctions.DestroyTray, lib, "SDL_DestroyTray")
+	purego.RegisterLibFunc(&trayFunctions.GetError, lib, "SDL_GetError")
+	trayCallback = purego.NewCallback(func(userdata, entry uintptr) {
+		if tray != nil {
+			if action, ok := tray.actions[entry]; ok {
+				action()
+			}
+		}
+	})
+	trayFunctions.loaded = true
+	return nil
+}
+
+// createTray adds the tray icon with entries to show the window, take a
+// snapshot or toggle recording of the selected camera, and quit. While the
+// kiosk is locked the entries do nothing but show the window.
+func createTray(appData *CameraAppData) (*Tray, error) {
+	if err := loadTrayFunctions(); err != nil {
+		return nil, err
+	}
+
+	// No icon: the desktop shows its default application icon
+	handle := trayFunctions.CreateTray(0, tr("app.title"))
+	if handle == 0 {
+		return nil, fmt.Errorf("failed to create tray icon: %s", trayFunctions.GetError())
+	}
+	menu := trayFunctions.CreateTrayMenu(handle)
+
+	t := &Tray{handle: handle, actions: make(map[uintptr]func())}
+	add := func(label string, action func()) uintptr {
+		entry := trayFunctions.InsertTrayEntryAt(menu, -1, label, trayEntryButton)
+		if entry != 0 {
+			trayFunctions.SetTrayEntryCallback(entry, trayCallback, 0)
+			t.actions[entry] = action
+		}
+		return entry
+	}
+
+	t.windowEntry = add(tr("tray.hide"), func() {
+		if !appData.Hidden && kiosk.Locked {
+			return
+		}
+		setWindowHidden(appData, !appData.Hidden)
+	})
+	add(tr("menu.snapshot"), func() {
+		if !kiosk.Locked && appData.SelectedCamera < len(appData.Cameras) {
+			runContextMenuAction(appData, appData.SelectedCamera, menuSnapshot)
+		}
+	})
+	t.recordEntry = add(tr("menu.start_rec"), func() {
+		if !kiosk.Locked && appData.SelectedCamera < len(appData.Cameras) {
+			runContextMenuAction(appData, appData.SelectedCamera, menuToggleRecording)
+		}
+	})
+	add(tr("tray.quit"), func() {
+		if kiosk.Locked {
+			return
+		}
+		if err := sdl.PushEvent(&sdl.Event{Type: sdl.EVENT_QUIT}); err != nil {
+			log.Printf("Failed to request quit: %v", err)
+		}
+	})
+
+	// Closing the window now hides it instead of quitting
+	if err := sdl.SetHint(sdl.HINT_QUIT_ON_LAST_WINDOW_CLOSE, "0"); err != nil {
+		log.Printf("Failed to keep running on window close: %v", err)
+	}
+	return t, nil
+}
+
+// Update refreshes the labels of the entries that depend on app state. It is
+// cheap enough to call every frame.
+func (t *Tray) Update(appData *CameraAppData) {
+	if t == nil {
+		return
+	}
+	windowLabel := tr("tray.hide")
+	if appData.Hidden {
+		windowLabel = tr("tray.show")
+	}
+	recordLabel := tr("menu.start_rec")
+	if appData.SelectedCamera < len(appData.Cameras) && appData.Cameras[appData.SelectedCamera].Recorder != nil {
+		recordLabel = tr("menu.stop_rec")
+	}
+	if t.windowEntry != 0 && windowLabel != t.windowLabel {
+		trayFunctions.SetTrayEntryLabel(t.windowEntry, windowLabel)
+		t.windowLabel = windowLabel
+	}
+	if t.recordEntry != 0 && recordLabel != t.recordLabel {
+		trayFunctions.SetTrayEntryLabel(t.recordEntry, recordLabel)
+		t.recordLabel = recordLabel
+	}
+}
+
+// Destroy removes the tray icon
+func (t *Tray) Destroy() {
+	if t == nil {
+		return
+	}
+	trayFunctions.DestroyTray(t.handle)
+}
+
+// setWindowHidden hides the window and any pop-outs to run in the
+// background, or shows them again. Cameras keep streaming and recording
+// while hidden.
+func setWindowHidden(appData *CameraAppData, hidden bool) {
+	appData.Hidden = hidden
+	windows := []*sdl.Window{appData.Window}
+	for i := range appData.Cameras {
+		if popOut := appData.Cameras[i].PopOut; popOut != nil {
+			windows = append(windows, popOut.Window)
+		}
+	}
+	for _, window := range windows {
+		if hidden {
+			if err := window.Hide(); err != nil {
+				log.Printf("Failed to hide window: %v", err)
+			}
+		} else {
+			if err := window.Show(); err != nil {
+				log.Printf("Failed to show window: %v", err)
+			}
+		}
+	}
+	if !hidden {
+		_ = appData.Window.Raise()
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/vladimirvivien/go4vl/device"
+	"github.com/vladimirvivien/go4vl/v4l2"
+	"golang.org/x/sys/unix"
+)
+
+// The capture loop of V4L2 devices is run here rather than by go4vl's
+// Device.Start, which hands out only the frame data: the buffer's sequence
+// number is what tells frames the kernel or the camera lost (a USB link
+// without the bandwidth, a full buffer queue) from frames the app dropped
+// because it could not keep up.
+
+// deviceStreamPoll is how often the capture loop checks for cancellation
+// while no frame arrives
+const deviceStreamPoll = 200
+
+// streamDevice starts streaming from a camera's open device. Frames arrive
+// on the returned channel, which is closed once ctx is cancelled and the
+// buffers are released. Frames missing from the sequence numbers are counted
+// in the camera's LostFrames, frames the channel has no room for in its
+// DroppedFrames.
+func streamDevice(ctx context.Context, dev *device.Device, camera *CameraInstance) (<-chan []byte, error) {
+	request, err := v4l2.InitBuffers(dev)
+	if err != nil {
+		return nil, err
+	}
+	buffers := make([][]byte, request.Count)
+	release := func() {
+		for _, buffer := range buffers {
+			if buffer != nil {
+				_ = unix.Munmap(buffer)
+			}
+		}
+		_, _ = v4l2.ResetBuffers(dev)
+	}
+	fd := dev.Fd()
+	for i := range buffers {
+		info, err := v4l2.GetBuffer(dev, uint32(i))
+		if err == nil {
+			buffers[i], err = unix.Mmap(int(fd), int64(info.Info.Offset), int(info.Length), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+		}
+		if err == nil {
+			_, err = v4l2.QueueBuffer(fd, v4l2.IOTypeMMAP, v4l2.BufTypeVideoCapture, uint32(i))
+		}
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("failed to set up buffer %d: %w", i, err)
+		}
+	}
+	if err := v4l2.StreamOn(dev); err != nil {
+		release()
+		return nil, err
+	}
+
+	out := make(chan []byte, len(buffers))
+	go func() {
+		defer close(out)
+		defer release()
+		defer func() { _ = v4l2.StreamOff(dev) }()
+
+		poll := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		var sequence uint32
+		first := true
+		for ctx.Err() == nil {
+			n, err := unix.Poll(poll, deviceStreamPoll)
+			if n == 0 || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Capture from %s stopped: %v", dev.Name(), err)
+				notify(toastError, tr("toast.capture_stopped", camera.Info.Name, err))
+				return
+			}
+			buffer, err := v4l2.DequeueBuffer(fd, v4l2.IOTypeMMAP, v4l2.BufTypeVideoCapture)
+			if errors.Is(err, unix.EAGAIN) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Capture from %s stopped: %v", dev.Name(), err)
+				notify(toastError, tr("toast.capture_stopped", camera.Info.Name, err))
+				return
+			}
+			// Corrupt frames are not passed on; the gap they leave in the
+			// sequence numbers counts them as lost
+			if buffer.Flags&v4l2.BufFlagError == 0 && int(buffer.Index) < len(buffers) {
+				if gap := buffer.Sequence - sequence; !first && gap > 1 {
+					atomic.AddUint64(&camera.LostFrames, uint64(gap-1))
+				}
+				sequence, first = buffer.Sequence, false
+
+				data := make([]byte, buffer.BytesUsed)
+				copy(data, buffers[buffer.Index])
+				select {
+				case out <- data:
+				default:
+					atomic.AddUint64(&camera.DroppedFrames, 1)
+				}
+			}
+			if _, err := v4l2.QueueBuffer(fd, v4l2.IOTypeMMAP, v4l2.BufTypeVideoCapture, buffer.Index); err != nil {
+				log.Printf("Capture from %s stopped: %v", dev.Name(), err)
+				notify(toastError, tr("toast.capture_stopped", camera.Info.Name, err))
+				return
+			}
+		}
+	}()
+	return out, nil
+}
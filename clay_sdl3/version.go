@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TotallyGamerJet/clay"
+)
+
+// Field installs report what they run through -version, the About panel
+// (F1) and GET /api/version. Release builds set the version with
+// -ldflags "-X main.version=v1.2.3"; the commit and build time come from
+// the VCS stamp Go embeds in binaries built from a checkout.
+
+// version is the release the binary was built as
+var version = "dev"
+
+// releasesURL is where -check-updates looks for the latest release
+const releasesURL = "https://api.github.com/repos/amken3d/go-camApp/releases/latest"
+
+// updateCheckTimeout bounds the update check, which must not hold up an
+// install without internet access
+const updateCheckTimeout = 10 * time.Second
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuild reads the build info of the running binary
+func currentBuild() BuildInfo {
+	build := BuildInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.Time = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
+
+// String formats the build info as one line, e.g.
+// "v1.2.3 (3cca9e9, 2026-10-01T12:00:00Z) go1.24.2 linux/arm64"
+func (b BuildInfo) String() string {
+	var details []string
+	if b.Revision != "" {
+		revision := b.Revision[:min(len(b.Revision), 7)]
+		if b.Modified {
+			revision += "+dirty"
+		}
+		details = append(details, revision)
+	}
+	if b.Time != "" {
+		details = append(details, b.Time)
+	}
+	line := b.Version
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	return line + " " + b.GoVersion + " " + b.Platform
+}
+
+// UpdateCheck holds the outcome of the -check-updates lookup, written by
+// its goroutine and read by the UI
+type UpdateCheck struct {
+	mu      sync.Mutex
+	done    bool
+	latest  string
+	url     string
+	err     error
+	shown   bool
+	enabled bool
+}
+
+var updateCheck UpdateCheck
+
+// startUpdateCheck looks up the latest release in the background
+func startUpdateCheck() {
+	updateCheck.mu.Lock()
+	updateCheck.enabled = true
+	updateCheck.mu.Unlock()
+	go func() {
+		latest, url, err := fetchLatestRelease()
+		if err != nil {
+			log.Printf("Update check failed: %v", err)
+		} else if newerVersion(latest, version) {
+			log.Printf("Update available: %s (running %s), %s", latest, version, url)
+		} else {
+			log.Printf("Up to date: latest release is %s", latest)
+		}
+		updateCheck.mu.Lock()
+		updateCheck.done = true
+		updateCheck.latest, updateCheck.url, updateCheck.err = latest, url, err
+		updateCheck.mu.Unlock()
+	}()
+}
+
+// fetchLatestRelease returns the tag and page of the latest release
+func fetchLatestRelease() (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("User-Agent", "go-camApp/"+version)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("%s: %s", releasesURL, response.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&release); err != nil {
+		return "", "", err
+	}
+	if release.TagName == "" {
+		return "", "", fmt.Errorf("%s: no release tag", releasesURL)
+	}
+	return release.TagName, release.HTMLURL, nil
+}
+
+// parseVersion splits "v1.2.3" or "1.2.3-rc1" into its numbers; ok is false
+// for versions that are not numbered releases, such as "dev"
+func parseVersion(v string) (parts [3]int, ok bool) {
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-")
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// newerVersion reports whether latest is a later release than current.
+// Development builds are not compared; they are never told to update.
+func newerVersion(latest, current string) bool {
+	l, okLatest := parseVersion(latest)
+	c, okCurrent := parseVersion(current)
+	if !okLatest || !okCurrent {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// updateStatus describes the update check for the About panel, empty if
+// it was not enabled
+func updateStatus() string {
+	updateCheck.mu.Lock()
+	defer updateCheck.mu.Unlock()
+	switch {
+	case !updateCheck.enabled:
+		return ""
+	case !updateCheck.done:
+		return tr("about.checking")
+	case updateCheck.err != nil:
+		return tr("about.check_failed")
+	case newerVersion(updateCheck.latest, version):
+		return tr("about.update_available", updateCheck.latest, updateCheck.url)
+	default:
+		return tr("about.up_to_date", updateCheck.latest)
+	}
+}
+
+// announceUpdate shows a found update in the status bar once. It is called
+// from the main loop.
+func announceUpdate(appData *CameraAppData) {
+	updateCheck.mu.Lock()
+	defer updateCheck.mu.Unlock()
+	if !updateCheck.done || updateCheck.shown || updateCheck.err != nil || !newerVersion(updateCheck.latest, version) {
+		return
+	}
+	updateCheck.shown = true
+	appData.StatusText = tr("status.update_available", updateCheck.latest)
+	appData.StatusColor = theme.Accent
+}
+
+// AboutPanelState tracks the floating About panel
+type AboutPanelState struct {
+	Open bool
+}
+
+// createAboutPanelLayout declares the About panel as a floating element
+// centered on the window
+func createAboutPanelLayout(data *CameraAppData) {
+	if !data.AboutPanel.Open {
+		return
+	}
+
+	textConfig := clay.TextElementConfig{
+		FontId:    FontIdBody16,
+		FontSize:  12,
+		TextColor: theme.Text,
+	}
+	build := currentBuild()
+
+	clay.UI()(clay.ElementDeclaration{
+		Id: SafeID("AboutPanel"),
+		Layout: clay.LayoutConfig{
+			LayoutDirection: clay.TOP_TO_BOTTOM,
+			Sizing: clay.Sizing{
+				Width: clay.SizingFixed(tp(340)),
+			},
+			Padding:  clay.PaddingAll(dpu(12)),
+			ChildGap: dpu(6),
+		},
+		Floating: clay.FloatingElementConfig{
+			ZIndex:   overlayZIndex,
+			AttachTo: clay.ATTACH_TO_ROOT,
+			AttachPoints: clay.FloatingAttachPoints{
+				Element: clay.ATTACH_POINT_CENTER_CENTER,
+				Parent:  clay.ATTACH_POINT_CENTER_CENTER,
+			},
+		},
+		BackgroundColor: theme.Popup,
+		CornerRadius:    clay.CornerRadiusAll(dp(6)),
+		Border: clay.BorderElementConfig{
+			Color: theme.AccentBorder,
+			Width: clay.BorderOutside(dpu(2)),
+		},
+	}, func() {
+		safeText("about-title", tr("about.title"), clay.TextElementConfig{
+			FontId:    FontIdBody16,
+			FontSize:  14,
+			TextColor: theme.Text,
+		})
+		safeText("about-version", tr("about.version", build.Version), textConfig)
+		if build.Revision != "" {
+			revision := build.Revision
+			if build.Modified {
+				revision += "+dirty"
+			}
+			safeText("about-revision", tr("about.revision", revision), textConfig)
+		}
+		if build.Time != "" {
+			safeText("about-time", tr("about.built", build.Time), textConfig)
+		}
+		safeText("about-go", tr("about.runtime", build.GoVersion, build.Platform), textConfig)
+		if status := updateStatus(); status != "" {
+			safeText("about-update", status, clay.TextElementConfig{
+				FontId:    FontIdBody16,
+				FontSize:  12,
+				TextColor: theme.TextDim,
+			})
+		}
+		controlButton("AboutSupport", tr("about.support_bundle"))
+		controlButton("AboutClose", tr("controls.close"))
+	})
+}
+
+// handleAboutPanelClick creates a support bundle or closes the About panel.
+// It reports whether the click landed on the panel.
+func handleAboutPanelClick(appData *CameraAppData, x, y float32) bool {
+	if !appData.AboutPanel.Open || !pointInElement(SafeID("AboutPanel"), x, y) {
+		return false
+	}
+	switch {
+	case pointInElement(SafeID("AboutSupport"), x, y):
+		path, err := createSupportBundle(appData)
+		if err != nil {
+			setErrorStatus(appData, err)
+		} else {
+			appData.StatusText = tr("status.support_bundle", path)
+			appData.StatusColor = theme.Success
+		}
+	case pointInElement(SafeID("AboutClose"), x, y):
+		appData.AboutPanel.Open = false
+	}
+	return true
+}
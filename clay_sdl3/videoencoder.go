@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Recordings are MJPEG unless -record-codec h264 compresses them to H.264
+// in a fragmented MP4, which takes a fraction of the space. The encoding is
+// done by ffmpeg with the first encoder this machine has of VAAPI (Intel and
+// AMD graphics), NVENC (NVIDIA), V4L2 memory-to-memory (Raspberry Pi and
+// other SoCs) and libx264 in software, or the one -video-encoder names.
+// Frames reach the encoder as the output JPEGs, so the output settings and
+// the watermark apply. Their index keeps every frame's capture time but no
+// byte ranges, so H.264 recordings are not played back or cut in the app.
+
+// VideoEncoder compresses the frames of a camera into a video stream
+type VideoEncoder interface {
+	// WriteFrame queues a JPEG frame for encoding
+	WriteFrame(frame Frame) error
+	// Close encodes what is queued and finishes the stream
+	Close() error
+}
+
+// recordCodecs are the codecs recordings are written in, set with
+// -record-codec
+var recordCodecs = []string{"mjpeg", "h264"}
+
+// videoEncoderBackend is a way of encoding H.264 with ffmpeg
+type videoEncoderBackend struct {
+	Name string
+	// Encoder is ffmpeg's name of the encoder
+	Encoder string
+	// InputArgs come before ffmpeg's input, OutputArgs select the encoder
+	InputArgs  []string
+	OutputArgs []string
+	// Filter hands the converted frames to the encoder in its pixel format
+	Filter string
+	// present reports whether the hardware is there, nil if the encoder
+	// needs none
+	present func() bool
+}
+
+// videoEncoderBackends are the encoders in the order they are preferred
+var videoEncoderBackends = []videoEncoderBackend{
+	{
+		Name:       "vaapi",
+		Encoder:    "h264_vaapi",
+		InputArgs:  []string{"-vaapi_device", vaapiDevice},
+		OutputArgs: []string{"-c:v", "h264_vaapi"},
+		Filter:     "format=nv12,hwupload",
+		present:    func() bool { return fileExists(vaapiDevice) },
+	},
+	{
+		Name:       "nvenc",
+		Encoder:    "h264_nvenc",
+		OutputArgs: []string{"-c:v", "h264_nvenc", "-preset", "p4"},
+		Filter:     "format=yuv420p",
+		present:    func() bool { return fileExists("/dev/nvidia0") },
+	},
+	{
+		Name:       "v4l2m2m",
+		Encoder:    "h264_v4l2m2m",
+		OutputArgs: []string{"-c:v", "h264_v4l2m2m", "-b:v", "4M"},
+		Filter:     "format=yuv420p",
+		present:    m2mEncoderPresent,
+	},
+	{
+		Name:       "x264",
+		Encoder:    "libx264",
+		OutputArgs: []string{"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency"},
+		Filter:     "format=yuv420p",
+	},
+}
+
+// vaapiDevice is the render node VAAPI encodes on
+const vaapiDevice = "/dev/dri/renderD128"
+
+// videoEncoderQueue is how many frames may wait for the encoder before
+// frames are dropped, so a slow encoder does not hold up the main loop
+const videoEncoderQueue = 30
+
+// videoEncoder is the backend H.264 recordings are encoded with, nil while
+// recordings are MJPEG
+var videoEncoder *videoEncoderBackend
+
+// fileExists reports whether a path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// m2mEncoderPresent reports whether a V4L2 device is a hardware encoder, by
+// the names drivers give them, such as bcm2835-codec-encode
+func m2mEncoderPresent() bool {
+	names, _ := filepath.Glob("/sys/class/video4linux/video*/name")
+	for _, path := range names {
+		if name, err := os.ReadFile(path); err == nil && strings.Contains(strings.ToLower(string(name)), "enc") {
+			return true
+		}
+	}
+	return false
+}
+
+// ffmpegPath is the ffmpeg run to encode
+var ffmpegPath = "ffmpeg"
+
+// listFFmpegEncoders returns what ffmpeg -encoders prints, the encoders
+// ffmpeg was built with
+var listFFmpegEncoders = func() (string, error) {
+	out, err := exec.Command(ffmpegPath, "-hide_banner", "-encoders").Output()
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg is needed for H.264 recordings: %w", err)
+	}
+	return string(out), nil
+}
+
+// configureVideoEncoder sets the codec of recordings and, for H.264, picks
+// the encoder: the one named, or for "auto" the first available
+func configureVideoEncoder(codec, name string) error {
+	codec = strings.ToLower(codec)
+	if !slices.Contains(recordCodecs, codec) {
+		return fmt.Errorf("unknown recording codec %q, expected one of %s", codec, strings.Join(recordCodecs, ", "))
+	}
+	videoEncoder = nil
+	if codec == "mjpeg" {
+		return nil
+	}
+	encoders, err := listFFmpegEncoders()
+	if err != nil {
+		return err
+	}
+	backend, err := selectVideoEncoder(strings.ToLower(name), encoders)
+	if err != nil {
+		return err
+	}
+	log.Printf("Recording H.264 with %s (%s)", backend.Name, backend.Encoder)
+	videoEncoder = &backend
+	return nil
+}
+
+// selectVideoEncoder picks the backend name stands for, or for "auto" the
+// first one that ffmpeg has, among the encoders it listed, and whose
+// hardware is present
+func selectVideoEncoder(name, encoders string) (videoEncoderBackend, error) {
+	var names []string
+	for _, backend := range videoEncoderBackends {
+		names = append(names, backend.Name)
+		if name != "auto" && name != backend.Name {
+			continue
+		}
+		if !hasFFmpegEncoder(encoders, backend.Encoder) {
+			if name == "auto" {
+				continue
+			}
+			return videoEncoderBackend{}, fmt.Errorf("ffmpeg has no %s encoder", backend.Encoder)
+		}
+		if name == "auto" && backend.present != nil && !backend.present() {
+			continue
+		}
+		return backend, nil
+	}
+	if name == "auto" {
+		return videoEncoderBackend{}, errors.New("ffmpeg has none of the H.264 encoders " + strings.Join(names, ", "))
+	}
+	return videoEncoderBackend{}, fmt.Errorf("unknown video encoder %q, expected auto or one of %s", name, strings.Join(names, ", "))
+}
+
+// hasFFmpegEncoder reports whether ffmpeg -encoders listed an encoder, on a
+// line of its flags followed by the name
+func hasFFmpegEncoder(encoders, encoder string) bool {
+	for line := range strings.Lines(encoders) {
+		if fields := strings.Fields(line); len(fields) >= 2 && fields[1] == encoder {
+			return true
+		}
+	}
+	return false
+}
+
+// ffmpegArgs returns the command line encoding MJPEG on stdin to a
+// fragmented MP4 on stdout, which stays playable if it is cut off
+func (b videoEncoderBackend) ffmpegArgs() []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, b.InputArgs...)
+	// Frames come as fast as the camera delivers them, so they are timed as
+	// they arrive
+	args = append(args, "-f", "mjpeg", "-use_wallclock_as_timestamps", "1", "-i", "pipe:0")
+	// The JPEGs are full-range BT.601; players expect limited range, in the
+	// matrix the stream is tagged with, see colorspace.go
+	args = append(args, "-vf", fmt.Sprintf("scale=in_color_matrix=bt601:in_range=full:out_color_matrix=%s:out_range=tv,%s",
+		outputMatrix.Name, b.Filter))
+	args = append(args, b.OutputArgs...)
+	args = append(args, "-colorspace", outputMatrix.tag(), "-color_primaries", "bt709", "-color_trc", "bt709", "-color_range", "tv")
+	return append(args, "-fps_mode", "passthrough", "-f", "mp4", "-movflags", "frag_keyframe+empty_moov+default_base_moof", "pipe:1")
+}
+
+// ffmpegEncoder is a VideoEncoder running ffmpeg, fed from a queue
+type ffmpegEncoder struct {
+	cmd    *exec.Cmd
+	frames chan []byte
+	stderr bytes.Buffer
+	// fed is closed once the feeder finished writing to ffmpeg, with its
+	// error in feedErr; failed is set as soon as a write fails
+	fed     chan struct{}
+	feedErr error
+	failed  atomic.Bool
+	// dropped counts the frames the queue had no room for
+	dropped int
+}
+
+// startVideoEncoder starts encoding with a backend, writing the video to w
+func startVideoEncoder(backend videoEncoderBackend, w io.Writer) (*ffmpegEncoder, error) {
+	e := &ffmpegEncoder{
+		cmd:    exec.Command(ffmpegPath, backend.ffmpegArgs()...),
+		frames: make(chan []byte, videoEncoderQueue),
+		fed:    make(chan struct{}),
+	}
+	e.cmd.Stdout = w
+	e.cmd.Stderr = &e.stderr
+	stdin, err := e.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	go func() {
+		defer close(e.fed)
+		for data := range e.frames {
+			if e.feedErr == nil {
+				if _, err := stdin.Write(data); err != nil {
+					e.feedErr = err
+					e.failed.Store(true)
+				}
+			}
+		}
+		stdin.Close()
+	}()
+	return e, nil
+}
+
+func (e *ffmpegEncoder) WriteFrame(frame Frame) error {
+	if e.failed.Load() {
+		return errors.New("ffmpeg stopped encoding")
+	}
+	select {
+	case e.frames <- frame.Data:
+	default:
+		e.dropped++
+	}
+	return nil
+}
+
+func (e *ffmpegEncoder) Close() error {
+	close(e.frames)
+	<-e.fed
+	err := e.cmd.Wait()
+	if e.dropped > 0 {
+		log.Printf("The video encoder fell behind and dropped %d frames", e.dropped)
+	}
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(e.stderr.String()))
+	}
+	return e.feedErr
+}
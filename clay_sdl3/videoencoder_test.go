@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ffmpegEncoders is what ffmpeg -encoders prints, shortened
+const ffmpegEncoders = `Encoders:
+ V..... = Video
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ V....D h264_nvenc           NVIDIA NVENC H.264 encoder (codec h264)
+ V....D h264_vaapi           H.264/AVC (VAAPI) (codec h264)
+`
+
+func TestSelectVideoEncoder(t *testing.T) {
+	saved := videoEncoderBackends
+	t.Cleanup(func() { videoEncoderBackends = saved })
+	videoEncoderBackends = slices.Clone(saved)
+	videoEncoderBackends[0].present = func() bool { return false }
+	videoEncoderBackends[1].present = func() bool { return true }
+
+	// VAAPI is listed but its device is missing, V4L2 M2M is not listed
+	for name, want := range map[string]string{"auto": "nvenc", "vaapi": "vaapi", "x264": "x264"} {
+		if backend, err := selectVideoEncoder(name, ffmpegEncoders); err != nil || backend.Name != want {
+			t.Errorf("%s selected %q, %v", name, backend.Name, err)
+		}
+	}
+	for _, name := range []string{"v4l2m2m", "qsv"} {
+		if _, err := selectVideoEncoder(name, ffmpegEncoders); err == nil {
+			t.Errorf("%s selected", name)
+		}
+	}
+	if backend, err := selectVideoEncoder("auto", "Encoders:\n V....D libx264 libx264\n"); err != nil || backend.Name != "x264" {
+		t.Errorf("software fallback %q, %v", backend.Name, err)
+	}
+	if _, err := selectVideoEncoder("auto", "Encoders:\n V....D mjpeg MJPEG\n"); err == nil {
+		t.Error("selected an encoder ffmpeg does not have")
+	}
+}
+
+func TestRecordWithVideoEncoder(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// A stand-in for ffmpeg passes the frames through
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec cat\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	savedPath, savedEncoder := ffmpegPath, videoEncoder
+	ffmpegPath, videoEncoder = script, &videoEncoderBackends[len(videoEncoderBackends)-1]
+	t.Cleanup(func() { ffmpegPath, videoEncoder = savedPath, savedEncoder })
+
+	camera := &CameraInstance{Info: CameraInfo{Name: "bench"}}
+	if err := startRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+	path := camera.Recorder.Path
+	start := time.Now()
+	var want []byte
+	for i := range 5 {
+		data := testJPEG(t, i)
+		want = append(want, data...)
+		if err := camera.Recorder.WriteFrame(Frame{Data: data, Captured: start.Add(time.Duration(i) * time.Millisecond)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !recordingInProgress(path) || !recordingInProgress(recordingIndexPath(path)) {
+		t.Error("the mirror would copy the open recording")
+	}
+	if err := stopRecording(camera); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(path, ".mp4") {
+		t.Errorf("recorded to %s", path)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, want) {
+		t.Errorf("encoder got %d bytes, expected %d", len(got), len(want))
+	}
+	index, _ := os.ReadFile(recordingIndexPath(path))
+	last := fmt.Sprintf("4,%d,", start.Add(4*time.Millisecond).UnixNano())
+	if !strings.HasPrefix(string(index), videoIndexHeader) || strings.Count(string(index), "\n") != 6 || !strings.Contains(string(index), "\n"+last) {
+		t.Errorf("index %q", index)
+	}
+	if _, err := readRecordingIndex(path); !errors.Is(err, errVideoRecording) {
+		t.Errorf("read as an MJPEG index: %v", err)
+	}
+	if _, err := exportClip(path, path+".clip.mp4", 0, 4, false); !errors.Is(err, errVideoRecording) {
+		t.Errorf("clip exported: %v", err)
+	}
+	if _, err := os.Stat(path + recordingMarkerSuffix); !os.IsNotExist(err) {
+		t.Error("a recovery marker was left")
+	}
+
+	// Cut off by a crash, it keeps its frames and loses the marker quietly
+	if err := os.WriteFile(path+recordingMarkerSuffix, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recoverRecordings(recordingDir)
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, want) {
+		t.Errorf("recovery left %d bytes, expected %d", len(got), len(want))
+	}
+	if _, err := os.Stat(path + recordingMarkerSuffix); !os.IsNotExist(err) {
+		t.Error("the marker of an interrupted H.264 recording was left")
+	}
+}
+
+func TestConfigureVideoEncoder(t *testing.T) {
+	saved, savedList := videoEncoder, listFFmpegEncoders
+	t.Cleanup(func() { videoEncoder, listFFmpegEncoders = saved, savedList })
+	listFFmpegEncoders = func() (string, error) { return ffmpegEncoders, nil }
+	if err := configureVideoEncoder("H264", "x264"); err != nil || videoEncoder == nil || videoEncoder.Encoder != "libx264" {
+		t.Errorf("selected %v, %v", videoEncoder, err)
+	}
+	if err := configureVideoEncoder("mjpeg", "x264"); err != nil || videoEncoder != nil {
+		t.Errorf("MJPEG recordings encoded with %v, %v", videoEncoder, err)
+	}
+	if err := configureVideoEncoder("hevc", "auto"); err == nil {
+		t.Error("recording HEVC")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import "image"
+
+type viewMode int
+
+const (
+	viewNormal viewMode = iota
+	// viewEdges shows Sobel edge strength, for focusing and checking
+	// machined edges
+	viewEdges
+	// viewThreshold shows pixels brighter than thresholdLevel as white
+	viewThreshold
+	// viewFalseColor maps brightness to a color ramp, making exposure
+	// levels easy to compare
+	viewFalseColor
+	// viewDifference shows how far each pixel is from the camera's frozen
+	// baseline as a heatmap over the dimmed live frame
+	viewDifference
+	viewModeCount
+)
+
+// differenceFloor is the luma difference ignored as sensor noise in the
+// difference view
+const differenceFloor = 12
+
+// Baseline is a frame frozen for the difference view. Luma is nil until the
+// next frame of the camera has been captured into it.
+type Baseline struct {
+	Luma          []uint8
+	Width, Height int
+}
+
+// Visualization is the analysis mode of the main view. It only changes what
+// the main view shows, not thumbnails, pop-outs or saved frames.
+type Visualization struct {
+	Mode           viewMode
+	ThresholdLevel uint8
+
+	luma []uint8
+	out  *image.RGBA
+}
+
+var visualization = Visualization{ThresholdLevel: 128}
+
+// falseColorPalette maps luma to a blue-cyan-green-yellow-red ramp, with
+// clipped blacks and whites marked in purple and white
+var falseColorPalette = func() [256][3]uint8 {
+	palette := colorRamp([][3]float32{
+		{0, 0, 255},
+		{0, 255, 255},
+		{0, 255, 0},
+		{255, 255, 0},
+		{255, 0, 0},
+	})
+	for i := range 4 {
+		palette[i] = [3]uint8{128, 0, 128}
+		palette[255-i] = [3]uint8{255, 255, 255}
+	}
+	return palette
+}()
+
+// viewModeName returns the translated name of a view mode
+func viewModeName(mode viewMode) string {
+	return tr([]string{"view.normal", "view.edges", "view.threshold", "view.false_color", "view.difference"}[mode])
+}
+
+// cycleViewMode switches the main view to the next visualization
+func cycleViewMode() string {
+	visualization.Mode = (visualization.Mode + 1) % viewModeCount
+	return viewModeName(visualization.Mode)
+}
+
+// adjustThreshold moves the threshold level, clamped to the byte range
+func adjustThreshold(delta int) uint8 {
+	visualization.ThresholdLevel = uint8(min(max(int(visualization.ThresholdLevel)+delta, 0), 255))
+	return visualization.ThresholdLevel
+}
+
+// freezeBaseline makes the camera's next frame the baseline of the
+// difference view and switches to it
+func freezeBaseline(appData *CameraAppData) {
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	camera.FrameMutex.Lock()
+	camera.Baseline = &Baseline{}
+	camera.FrameMutex.Unlock()
+	visualization.Mode = viewDifference
+	appData.StatusText = tr("status.baseline", camera.Info.Name)
+}
+
+// clearBaseline drops the selected camera's baseline
+func clearBaseline(appData *CameraAppData) {
+	if appData.SelectedCamera >= len(appData.Cameras) {
+		return
+	}
+	camera := &appData.Cameras[appData.SelectedCamera]
+	camera.FrameMutex.Lock()
+	camera.Baseline = nil
+	camera.FrameMutex.Unlock()
+	appData.StatusText = tr("status.baseline_cleared", camera.Info.Name)
+}
+
+// Apply returns the camera's frame rendered in the current mode, or the
+// frame itself in normal mode. The result is reused by the next call. It is
+// called with the camera's FrameMutex held.
+func (v *Visualization) Apply(camera *CameraInstance, img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if b := camera.Baseline; b != nil && b.Luma == nil {
+		b.Luma = frameLuma(img, nil)
+		b.Width, b.Height = width, height
+	}
+
+	baseline := camera.Baseline
+	if v.Mode == viewNormal ||
+		v.Mode == viewDifference && (baseline == nil || baseline.Width != width || baseline.Height != height) {
+		return img
+	}
+
+	if v.out == nil || v.out.Bounds() != bounds {
+		v.out = image.NewRGBA(bounds)
+	}
+	v.luma = frameLuma(img, v.luma)
+
+	out := v.out.Pix
+	switch v.Mode {
+	case viewEdges:
+		sobel(v.luma, width, height, out)
+	case viewThreshold:
+		for i, l := range v.luma {
+			value := uint8(0)
+			if l >= v.ThresholdLevel {
+				value = 255
+			}
+			out[i*4], out[i*4+1], out[i*4+2], out[i*4+3] = value, value, value, 255
+		}
+	case viewFalseColor:
+		for i, l := range v.luma {
+			c := falseColorPalette[l]
+			out[i*4], out[i*4+1], out[i*4+2], out[i*4+3] = c[0], c[1], c[2], 255
+		}
+	case viewDifference:
+		for i, l := range v.luma {
+			d := abs32i(int32(l) - int32(baseline.Luma[i]))
+			if d <= differenceFloor {
+				dim := l / 3
+				out[i*4], out[i*4+1], out[i*4+2], out[i*4+3] = dim, dim, dim, 255
+				continue
+			}
+			// Skip the palette's clipping markers at both ends
+			c := falseColorPalette[min(4+(d-differenceFloor)*3, 251)]
+			out[i*4], out[i*4+1], out[i*4+2], out[i*4+3] = c[0], c[1], c[2], 255
+		}
+	}
+	return v.out
+}
+
+// sobel writes the gradient magnitude of a luma image as grey RGBA pixels,
+// leaving the one-pixel border black
+func sobel(luma []uint8, width, height int, out []uint8) {
+	clear(out)
+	for i := 3; i < len(out); i += 4 {
+		out[i] = 255
+	}
+	for y := 1; y < height-1; y++ {
+		above, row, below := (y-1)*width, y*width, (y+1)*width
+		for x := 1; x < width-1; x++ {
+			tl, t, tr := int32(luma[above+x-1]), int32(luma[above+x]), int32(luma[above+x+1])
+			l, r := int32(luma[row+x-1]), int32(luma[row+x+1])
+			bl, b, br := int32(luma[below+x-1]), int32(luma[below+x]), int32(luma[below+x+1])
+			gx := tr + 2*r + br - tl - 2*l - bl
+			gy := bl + 2*b + br - tl - 2*t - tr
+			// |gx| + |gy| approximates the magnitude without a square root
+			magnitude := min((abs32i(gx)+abs32i(gy))/2, 255)
+			i := (row + x) * 4
+			out[i], out[i+1], out[i+2] = uint8(magnitude), uint8(magnitude), uint8(magnitude)
+		}
+	}
+}
+
+func abs32i(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The watchdog keeps unattended installs running when the pipeline stalls
+// beyond what the per-camera recovery catches. The main loop reports each
+// iteration and each rendered frame; a goroutine watches both and logs a
+// stall, after which the main loop restarts every camera once it runs again.
+// A main loop that does not recover ends the app, so its service manager
+// starts it again. Cameras are also restarted when the loop runs but no
+// streaming camera delivers a frame.
+
+// watchdogExitAfter is how many timeouts a stuck main loop gets before the
+// app exits
+const watchdogExitAfter = 3
+
+// watchdogExitCode is the exit status after a stuck main loop
+const watchdogExitCode = 3
+
+// Watchdog is the state of the application watchdog
+type Watchdog struct {
+	// Timeout is how long the pipeline may stall, 0 if the watchdog is off
+	Timeout time.Duration
+	// DumpGoroutines writes the stacks of all goroutines to the support
+	// directory on every stall
+	DumpGoroutines bool
+	// Restarts counts the capture restarts
+	Restarts int
+
+	loopBeat   atomic.Int64
+	renderBeat atomic.Int64
+	// restart is set by the watchdog goroutine for the main loop
+	restart     atomic.Bool
+	lastRestart time.Time
+}
+
+// watchdog is set up with -watchdog
+var watchdog Watchdog
+
+// startWatchdog starts watching the main loop
+func startWatchdog() {
+	if watchdog.Timeout <= 0 {
+		return
+	}
+	now := time.Now().UnixNano()
+	watchdog.loopBeat.Store(now)
+	watchdog.renderBeat.Store(now)
+	watchdog.lastRestart = time.Now()
+	log.Printf("Watchdog restarts the pipeline after %s without progress", watchdog.Timeout)
+
+	go func() {
+		ticker := time.NewTicker(watchdog.Timeout / 4)
+		defer ticker.Stop()
+		var stalled string
+		for range ticker.C {
+			loop := time.Since(time.Unix(0, watchdog.loopBeat.Load()))
+			render := time.Since(time.Unix(0, watchdog.renderBeat.Load()))
+			var reason string
+			switch {
+			case loop > watchdog.Timeout:
+				reason = fmt.Sprintf("no event loop progress for %s", loop.Round(time.Second))
+			case render > watchdog.Timeout:
+				reason = fmt.Sprintf("no frame rendered for %s", render.Round(time.Second))
+			}
+			if reason == "" {
+				if stalled != "" {
+					log.Printf("Watchdog: main loop recovered")
+				}
+				stalled = ""
+				continue
+			}
+			if stalled == "" {
+				stalled = reason
+				watchdog.restart.Store(true)
+				reportStall(reason, fmt.Sprintf("last loop %s ago, last render %s ago",
+					loop.Round(time.Millisecond), render.Round(time.Millisecond)))
+			}
+			if min(loop, render) > watchdogExitAfter*watchdog.Timeout {
+				log.Printf("Watchdog: %s, exiting so the app is restarted", reason)
+				os.Exit(watchdogExitCode)
+			}
+		}
+	}()
+}
+
+// watchdogRendered reports a frame drawn by the main loop, or one it had no
+// need to draw while the window is hidden
+func watchdogRendered() {
+	watchdog.renderBeat.Store(time.Now().UnixNano())
+}
+
+// reportStall logs a stall with the process state and dumps the goroutines
+// if enabled
+func reportStall(reason, state string) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	log.Printf("Watchdog: %s; %s; %d goroutines, %d MB heap", reason, state,
+		runtime.NumGoroutine(), mem.HeapAlloc>>20)
+	if !watchdog.DumpGoroutines {
+		return
+	}
+	path, err := dumpGoroutines()
+	if err != nil {
+		log.Printf("Watchdog: failed to dump goroutines: %v", err)
+		return
+	}
+	log.Printf("Watchdog: goroutines dumped to %s", path)
+}
+
+// dumpGoroutines writes the stacks of all goroutines to the support
+// directory and returns the file's path
+func dumpGoroutines() (string, error) {
+	if err := os.MkdirAll(supportDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(supportDir, "goroutines_"+time.Now().Format("20060102_150405")+".txt")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(file, 2); err != nil {
+		file.Close()
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// checkPipeline reports progress of the main loop to the watchdog and
+// restarts every camera after a stall, or when streaming cameras are active
+// but none of them delivered a frame within the watchdog timeout. Watch
+// folders and replays are left out, since they only send frames now and
+// then.
+func checkPipeline(appData *CameraAppData) {
+	if watchdog.Timeout <= 0 {
+		return
+	}
+	watchdog.loopBeat.Store(time.Now().UnixNano())
+	if watchdog.restart.Swap(false) {
+		restartCaptures(appData)
+		return
+	}
+	if time.Since(watchdog.lastRestart) < watchdog.Timeout {
+		return
+	}
+	streaming := false
+	var newest time.Time
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if !camera.Active || camera.Disabled || isFolderSource(camera) || isReplaySource(camera) || isPlaybackSource(camera) {
+			continue
+		}
+		streaming = true
+		if camera.LastFrameAt.After(newest) {
+			newest = camera.LastFrameAt
+		}
+	}
+	if !streaming {
+		return
+	}
+	if newest.IsZero() {
+		// No frame yet since the cameras started
+		newest = watchdog.lastRestart
+	}
+	if since := time.Since(newest); since > watchdog.Timeout {
+		reportStall(fmt.Sprintf("no camera frame for %s", since.Round(time.Second)), describeCameras(appData))
+		restartCaptures(appData)
+	}
+}
+
+// describeCameras summarizes the state of every camera for the log
+func describeCameras(appData *CameraAppData) string {
+	parts := make([]string, 0, len(appData.Cameras))
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		last := "never"
+		if !camera.LastFrameAt.IsZero() {
+			last = time.Since(camera.LastFrameAt).Round(time.Millisecond).String() + " ago"
+		}
+		parts = append(parts, fmt.Sprintf("%s: active %t, last frame %s, %d dropped",
+			camera.Info.Name, camera.Active, last, atomic.LoadUint64(&camera.DroppedFrames)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// restartCaptures stops every camera and starts the ones that were running
+// again, keeping their recordings open. Cameras that do not come back are
+// retried like cameras that fail to start.
+func restartCaptures(appData *CameraAppData) {
+	watchdog.Restarts++
+	watchdog.lastRestart = time.Now()
+	log.Printf("Watchdog: restarting capture (restart %d)", watchdog.Restarts)
+
+	running := make([]bool, len(appData.Cameras))
+	for i := range appData.Cameras {
+		running[i] = appData.Cameras[i].Active && !appData.Cameras[i].Disabled
+	}
+	stopCaptures(appData, time.Now().Add(shutdownTimeout))
+
+	restarted := 0
+	for i := range appData.Cameras {
+		camera := &appData.Cameras[i]
+		if camera.Device != nil {
+			camera.Device.Close()
+			camera.Device = nil
+		}
+		if !running[i] {
+			continue
+		}
+		camera.LastFrameAt = time.Time{}
+		camera.Offline = false
+		if err := enableCamera(camera, appData.Renderer); err != nil {
+			log.Printf("Watchdog: %v", err)
+			if scheduleStartRetry(camera) {
+				log.Printf("Retrying camera %s in %s", camera.Info.Name, camera.StartRetry.Delay)
+			}
+			continue
+		}
+		restarted++
+	}
+	appData.StatusText = tr("status.watchdog_restart", restarted)
+	appData.StatusColor = theme.Warning
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Recorded and streamed frames can carry a watermark, for attribution when
+// clips are shared: a line of text (-watermark-text), a PNG logo
+// (-watermark-logo) or both, the logo above the text, in a corner or the
+// center of the frame (-watermark-position), blended at -watermark-opacity.
+// It is drawn as frames are encoded for output, so the window keeps showing
+// them unmarked. The mark is sized after the frame, the text a 24th of its
+// height and the logo at most a quarter of its width, so it looks the same
+// at every -output-size.
+
+// watermarkPositions are the places a watermark can go
+var watermarkPositions = []string{"top-left", "top-right", "bottom-left", "bottom-right", "center"}
+
+// watermarkFont is the face watermark text is drawn in
+var watermarkFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(gobold.TTF)
+})
+
+// Watermark is drawn over every frame that leaves the app
+type Watermark struct {
+	Text     string
+	Logo     image.Image
+	Position string
+	Opacity  float64
+
+	// mark is the watermark rendered for frames of size
+	mu   sync.Mutex
+	size image.Point
+	mark *image.RGBA
+}
+
+// configureWatermark sets the watermark of output frames, none if neither
+// text nor a logo is given
+func configureWatermark(text, logoPath, position string, opacity float64) error {
+	if text == "" && logoPath == "" {
+		outputEncoding.Watermark = nil
+		return nil
+	}
+	if !slices.Contains(watermarkPositions, position) {
+		return fmt.Errorf("invalid watermark position %q, expected %s", position, strings.Join(watermarkPositions, ", "))
+	}
+	if opacity <= 0 || opacity > 1 {
+		return fmt.Errorf("invalid watermark opacity %g, expected more than 0 up to 1", opacity)
+	}
+	w := &Watermark{Text: text, Position: position, Opacity: opacity}
+	if logoPath != "" {
+		file, err := os.Open(logoPath)
+		if err != nil {
+			return fmt.Errorf("failed to open the watermark logo: %w", err)
+		}
+		defer file.Close()
+		if w.Logo, err = png.Decode(file); err != nil {
+			return fmt.Errorf("failed to decode the watermark logo %s: %w", logoPath, err)
+		}
+	}
+	if text != "" {
+		if _, err := watermarkFont(); err != nil {
+			return fmt.Errorf("failed to load the watermark font: %w", err)
+		}
+	}
+	outputEncoding.Watermark = w
+	return nil
+}
+
+// Draw blends the watermark into a frame
+func (w *Watermark) Draw(dst *image.RGBA) {
+	bounds := dst.Bounds()
+	mark := w.render(bounds.Size())
+	margin := watermarkMargin(bounds.Size())
+	size := mark.Bounds().Size()
+	at := bounds.Min.Add(image.Pt(margin, margin))
+	switch w.Position {
+	case "top-right":
+		at.X = bounds.Max.X - margin - size.X
+	case "bottom-left":
+		at.Y = bounds.Max.Y - margin - size.Y
+	case "bottom-right":
+		at = bounds.Max.Sub(image.Pt(margin, margin)).Sub(size)
+	case "center":
+		at = bounds.Min.Add(bounds.Size().Sub(size).Div(2))
+	}
+	alpha := image.NewUniform(color.Alpha{A: uint8(w.Opacity*255 + 0.5)})
+	draw.DrawMask(dst, mark.Bounds().Add(at), mark, image.Point{}, alpha, image.Point{}, draw.Over)
+}
+
+// watermarkMargin is the space between a watermark and the frame's edges
+func watermarkMargin(frame image.Point) int {
+	return max(frame.Y/48, 2)
+}
+
+// render returns the watermark for frames of a size, drawn once per size
+func (w *Watermark) render(frame image.Point) *image.RGBA {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.mark != nil && w.size == frame {
+		return w.mark
+	}
+
+	var logo *image.RGBA
+	if w.Logo != nil {
+		b := w.Logo.Bounds()
+		logo = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(logo, logo.Bounds(), w.Logo, b.Min, draw.Src)
+		if limit := max(frame.X/4, 1); b.Dx() > limit {
+			logo = shrinkImage(logo, limit, max(b.Dy()*limit/b.Dx(), 1))
+		}
+	}
+	var face font.Face
+	var textSize image.Point
+	var ascent int
+	if w.Text != "" {
+		face = newTextFace(max(frame.Y/24, 8))
+		defer face.Close()
+		ascent = face.Metrics().Ascent.Ceil()
+		textSize = measureShadowedText(face, w.Text)
+	}
+
+	gap := 0
+	var logoSize image.Point
+	if logo != nil {
+		logoSize = logo.Bounds().Size()
+		if face != nil {
+			gap = watermarkMargin(frame) / 2
+		}
+	}
+	mark := image.NewRGBA(image.Rect(0, 0, max(logoSize.X, textSize.X), logoSize.Y+gap+textSize.Y))
+	// Logo and text line up on the side of the frame the mark is on
+	align := func(width int) int {
+		switch {
+		case strings.HasSuffix(w.Position, "right"):
+			return mark.Bounds().Dx() - width
+		case w.Position == "center":
+			return (mark.Bounds().Dx() - width) / 2
+		}
+		return 0
+	}
+	if logo != nil {
+		draw.Draw(mark, logo.Bounds().Add(image.Pt(align(logoSize.X), 0)), logo, image.Point{}, draw.Src)
+	}
+	if face != nil {
+		drawShadowedText(mark, face, align(textSize.X), logoSize.Y+gap+ascent, w.Text)
+	}
+	w.size, w.mark = frame, mark
+	return mark
+}
+
+// newTextFace returns the watermark font at a height in pixels. The font
+// was checked by configureWatermark, or is checked by the first call.
+func newTextFace(height int) font.Face {
+	f, err := watermarkFont()
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: float64(height), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+// textShadow is how far the shadow of text in a face is offset
+func textShadow(face font.Face) int {
+	return max(face.Metrics().Height.Ceil()/16, 1)
+}
+
+// measureShadowedText returns the size of text drawn with its shadow
+func measureShadowedText(face font.Face, text string) image.Point {
+	metrics, shadow := face.Metrics(), textShadow(face)
+	return image.Pt(font.MeasureString(face, text).Ceil()+shadow, metrics.Ascent.Ceil()+metrics.Descent.Ceil()+shadow)
+}
+
+// drawShadowedText draws white text with its baseline at y and a dark
+// shadow, which keeps it legible on light scenes
+func drawShadowedText(dst draw.Image, face font.Face, x, y int, text string) {
+	shadow := textShadow(face)
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(color.RGBA{A: 160}), Face: face, Dot: fixed.P(x+shadow, y+shadow)}
+	d.DrawString(text)
+	d.Src, d.Dot = image.White, fixed.P(x, y)
+	d.DrawString(text)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// grayFrame is a frame of one gray level
+func grayFrame(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 100
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+// markedPixels counts the pixels of a region that differ from grayFrame
+func markedPixels(img image.Image, region image.Rectangle) int {
+	n := 0
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if max(r, g, b)>>8 > 110 || min(r, g, b)>>8 < 90 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func withWatermark(t *testing.T, text, logo, position string, opacity float64) *Watermark {
+	t.Helper()
+	t.Cleanup(func() { outputEncoding = OutputEncoding{} })
+	if err := configureWatermark(text, logo, position, opacity); err != nil {
+		t.Fatal(err)
+	}
+	return outputEncoding.Watermark
+}
+
+func TestConfigureWatermark(t *testing.T) {
+	t.Cleanup(func() { outputEncoding = OutputEncoding{} })
+	if err := configureWatermark("", "", "nowhere", 0); err != nil || outputEncoding.Watermark != nil {
+		t.Errorf("no watermark configured as %v, %v", outputEncoding.Watermark, err)
+	}
+	missing := filepath.Join(t.TempDir(), "logo.png")
+	for _, c := range []struct {
+		text, logo, position string
+		opacity              float64
+	}{
+		{"© bench", "", "middle", 0.5},
+		{"© bench", "", "center", 0},
+		{"© bench", "", "center", 1.5},
+		{"", missing, "top-left", 1},
+	} {
+		if err := configureWatermark(c.text, c.logo, c.position, c.opacity); err == nil {
+			t.Errorf("%+v was accepted", c)
+		}
+	}
+}
+
+func TestWatermarkText(t *testing.T) {
+	w := withWatermark(t, "© go-camApp", "", "bottom-right", 1)
+	img := grayFrame(640, 480)
+	w.Draw(img)
+	if n := markedPixels(img, image.Rect(320, 400, 640, 480)); n < 100 {
+		t.Errorf("%d pixels marked in the bottom right corner", n)
+	}
+	if n := markedPixels(img, image.Rect(0, 0, 640, 400)); n != 0 {
+		t.Errorf("%d pixels marked outside the bottom right corner", n)
+	}
+	// The text grows with the frame
+	if small, large := w.render(image.Pt(320, 240)).Bounds().Dy(), w.render(image.Pt(1280, 960)).Bounds().Dy(); large < 3*small {
+		t.Errorf("text %d pixels high on a small frame, %d on a large one", small, large)
+	}
+}
+
+func TestWatermarkLogo(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for i := range logo.Pix {
+		logo.Pix[i] = []uint8{255, 0, 0, 255}[i%4]
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, logo)
+	path := filepath.Join(t.TempDir(), "logo.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w := withWatermark(t, "", path, "top-left", 0.5)
+
+	img := grayFrame(640, 480)
+	w.Draw(img)
+	margin := watermarkMargin(image.Pt(640, 480))
+	// The logo is shrunk to a quarter of the frame's width and blended
+	if got := img.RGBAAt(margin, margin); got.R < 170 || got.R > 185 || got.G < 45 || got.G > 55 {
+		t.Errorf("logo pixel %v", got)
+	}
+	if got := img.RGBAAt(margin+160, margin); got != (color.RGBA{100, 100, 100, 255}) {
+		t.Errorf("pixel right of the logo %v", got)
+	}
+	if got := img.RGBAAt(margin, margin+40); got != (color.RGBA{100, 100, 100, 255}) {
+		t.Errorf("pixel below the logo %v", got)
+	}
+}
+
+func TestEncodeWatermark(t *testing.T) {
+	withWatermark(t, "© go-camApp", "", "top-left", 1)
+	raw := grayFrame(320, 240)
+	output := encodeOutput(Frame{Raw: raw})
+	img, err := jpeg.Decode(bytes.NewReader(output.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := markedPixels(img, image.Rect(0, 0, 160, 40)); n < 50 {
+		t.Errorf("%d pixels marked in the output", n)
+	}
+	// The frame the display shows stays unmarked
+	if n := markedPixels(raw, raw.Bounds()); n != 0 {
+		t.Errorf("%d pixels marked in the displayed frame", n)
+	}
+}
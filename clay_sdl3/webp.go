@@ -0,0 +1,528 @@
+package main
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// Animated WebP is written by a small lossless encoder, since the standard
+// library and golang.org/x/image only decode WebP. Every frame is a VP8L
+// image, see the WebP lossless bitstream specification, with the subtract
+// green and predictor transforms, LZ77 backward references and a single set
+// of prefix codes, in the animated WebP container described at
+// https://developers.google.com/speed/webp/docs/riff_container.
+
+const (
+	vp8lSignature = 0x2f
+	// vp8lPredictorBits is the log-2 side of the predictor's tiles, the
+	// largest allowed, since every tile uses the same mode
+	vp8lPredictorBits = 9
+	// vp8lPredictorMode predicts pixels as the average of the ones to their
+	// left and above
+	vp8lPredictorMode = 7
+	vp8lLengthCodes   = 24
+	vp8lDistanceCodes = 40
+	vp8lMaxLength     = 4096
+	vp8lMaxDistance   = 1<<20 - 120
+	// vp8lMinMatch is the shortest backward reference worth its codes
+	vp8lMinMatch = 3
+	// vp8lHashBits sizes the table of recent pixel pairs LZ77 looks matches
+	// up in, and vp8lChainLimit bounds how many it tries
+	vp8lHashBits   = 16
+	vp8lChainLimit = 32
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lDistanceMap are the pixel offsets of the 120 short distance codes, as
+// rows up and columns left
+var vp8lDistanceMap = [120]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// webpFrame is a frame of an animation and how long it shows
+type webpFrame struct {
+	Image    *image.RGBA
+	Duration int // milliseconds
+}
+
+// encodeAnimatedWebP writes frames of equal size as a looping animated WebP
+func encodeAnimatedWebP(w io.Writer, frames []webpFrame) error {
+	size := frames[0].Image.Bounds().Size()
+	var body []byte
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 // animation
+	putUint24(vp8x[4:], size.X-1)
+	putUint24(vp8x[7:], size.Y-1)
+	body = appendChunk(body, "VP8X", vp8x)
+	// White background, looping forever
+	body = appendChunk(body, "ANIM", []byte{0xff, 0xff, 0xff, 0xff, 0, 0})
+	for _, frame := range frames {
+		header := make([]byte, 16)
+		putUint24(header[6:], size.X-1)
+		putUint24(header[9:], size.Y-1)
+		putUint24(header[12:], min(max(frame.Duration, 0), 1<<24-1))
+		header[15] = 0x02 // no blending, no disposal
+		body = appendChunk(body, "ANMF", appendChunk(header, "VP8L", encodeVP8L(frame.Image)))
+	}
+	riff := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)))...)
+	riff = append(riff, "WEBP"...)
+	_, err := w.Write(append(riff, body...))
+	return err
+}
+
+// appendChunk appends a RIFF chunk, padded to an even size
+func appendChunk(dst []byte, fourCC string, payload []byte) []byte {
+	dst = append(dst, fourCC...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = append(dst, payload...)
+	if len(payload)%2 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+// putUint24 stores a little-endian 24-bit number
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// encodeVP8L encodes an opaque image as a VP8L bitstream
+func encodeVP8L(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	w := &bitWriter{}
+	w.write(vp8lSignature, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(0, 1) // no alpha
+	w.write(0, 3) // version
+
+	// Subtract green, then predict every pixel from its neighbors
+	argb := make([]uint32, width*height)
+	for y := range height {
+		row := img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+		for x := range width {
+			r, g, b := uint32(row[x*4]), uint32(row[x*4+1]), uint32(row[x*4+2])
+			argb[y*width+x] = 0xff000000 | (r-g)&0xff<<16 | g<<8 | (b-g)&0xff
+		}
+	}
+	w.write(1, 1)
+	w.write(2, 2) // subtract green
+	w.write(1, 1)
+	w.write(0, 2) // predictor
+	w.write(vp8lPredictorBits-2, 3)
+	tiles := make([]uint32, vp8lTiles(width)*vp8lTiles(height))
+	for i := range tiles {
+		tiles[i] = 0xff000000 | vp8lPredictorMode<<8
+	}
+	writeVP8LImage(w, tiles, vp8lTiles(width), false)
+	w.write(0, 1) // no more transforms
+
+	writeVP8LImage(w, vp8lResiduals(argb, width), width, true)
+	return w.bytes()
+}
+
+// vp8lTiles is the number of predictor tiles across size pixels
+func vp8lTiles(size int) int {
+	return (size + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+}
+
+// vp8lResiduals returns what is left of each pixel after the prediction of
+// vp8lPredictorMode, which the first row and column replace with the pixel
+// to the left and above
+func vp8lResiduals(argb []uint32, width int) []uint32 {
+	residuals := make([]uint32, len(argb))
+	for i, pixel := range argb {
+		x, y := i%width, i/width
+		var predicted uint32
+		switch {
+		case i == 0:
+			predicted = 0xff000000
+		case y == 0:
+			predicted = argb[i-1]
+		case x == 0:
+			predicted = argb[i-width]
+		default:
+			left, top := argb[i-1], argb[i-width]
+			for shift := 0; shift < 32; shift += 8 {
+				predicted |= (left>>shift&0xff + top>>shift&0xff) / 2 << shift
+			}
+		}
+		for shift := 0; shift < 32; shift += 8 {
+			residuals[i] |= (pixel>>shift - predicted>>shift) & 0xff << shift
+		}
+	}
+	return residuals
+}
+
+// vp8lToken is a literal pixel, or a backward reference if length is set
+type vp8lToken struct {
+	pixel    uint32
+	length   int
+	distance int
+}
+
+// vp8lBackwardReferences turns pixels into literals and references to
+// earlier runs of pixels
+func vp8lBackwardReferences(argb []uint32, width int) []vp8lToken {
+	distanceCodes := vp8lShortDistances(width)
+	head := make([]int32, 1<<vp8lHashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	chain := make([]int32, len(argb))
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd ^ argb[i+1]*0x9e3779b1) >> (32 - vp8lHashBits)
+	}
+	insert := func(i int) {
+		if i+1 < len(argb) {
+			h := hash(i)
+			chain[i], head[h] = head[h], int32(i)
+		}
+	}
+	matchLength := func(i, from int) int {
+		n := 0
+		for limit := min(len(argb)-i, vp8lMaxLength); n < limit && argb[from+n] == argb[i+n]; n++ {
+		}
+		return n
+	}
+
+	var tokens []vp8lToken
+	for i := 0; i < len(argb); {
+		best, bestFrom := 0, 0
+		// The pixel to the left and the one above are tried first, as they
+		// repeat most often and have the shortest codes
+		for _, from := range []int{i - 1, i - width} {
+			if from >= 0 {
+				if n := matchLength(i, from); n > best {
+					best, bestFrom = n, from
+				}
+			}
+		}
+		if i+1 < len(argb) {
+			for from, tries := head[hash(i)], 0; from >= 0 && tries < vp8lChainLimit && i-int(from) <= vp8lMaxDistance; from, tries = chain[from], tries+1 {
+				if n := matchLength(i, int(from)); n > best {
+					best, bestFrom = n, int(from)
+				}
+			}
+		}
+		if best < vp8lMinMatch {
+			tokens = append(tokens, vp8lToken{pixel: argb[i]})
+			insert(i)
+			i++
+			continue
+		}
+		distance := i - bestFrom
+		code, ok := distanceCodes[distance]
+		if !ok {
+			code = distance + len(vp8lDistanceMap)
+		}
+		tokens = append(tokens, vp8lToken{length: best, distance: code})
+		for end := i + best; i < end; i++ {
+			insert(i)
+		}
+	}
+	return tokens
+}
+
+// vp8lShortDistances maps the distances the short distance codes stand for
+// in an image of a width to the smallest of them
+func vp8lShortDistances(width int) map[int]int {
+	codes := make(map[int]int, len(vp8lDistanceMap))
+	for i, offset := range vp8lDistanceMap {
+		distance := int(offset>>4)*width + 8 - int(offset&0xf)
+		if _, ok := codes[distance]; distance >= 1 && !ok {
+			codes[distance] = i + 1
+		}
+	}
+	return codes
+}
+
+// vp8lPrefix splits a length or distance code into its prefix symbol and
+// extra bits
+func vp8lPrefix(value int) (symbol, extraBits, extra int) {
+	v := value - 1
+	if v < 4 {
+		return v, 0, 0
+	}
+	high := bits.Len(uint(v)) - 1
+	second := v >> (high - 1) & 1
+	return 2*high + second, high - 1, v & (1<<(high-1) - 1)
+}
+
+// writeVP8LImage writes pixels as entropy-coded image data
+func writeVP8LImage(w *bitWriter, argb []uint32, width int, topLevel bool) {
+	w.write(0, 1) // no color cache
+	if topLevel {
+		w.write(0, 1) // a single set of prefix codes
+	}
+	tokens := vp8lBackwardReferences(argb, width)
+	green := make([]int, 256+vp8lLengthCodes)
+	red, blue, alpha := make([]int, 256), make([]int, 256), make([]int, 256)
+	distance := make([]int, vp8lDistanceCodes)
+	for _, token := range tokens {
+		if token.length == 0 {
+			alpha[token.pixel>>24]++
+			red[token.pixel>>16&0xff]++
+			green[token.pixel>>8&0xff]++
+			blue[token.pixel&0xff]++
+			continue
+		}
+		symbol, _, _ := vp8lPrefix(token.length)
+		green[256+symbol]++
+		symbol, _, _ = vp8lPrefix(token.distance)
+		distance[symbol]++
+	}
+	codes := make([]*prefixCode, 5)
+	for i, counts := range [][]int{green, red, blue, alpha, distance} {
+		codes[i] = newPrefixCode(counts, 15)
+		codes[i].writeLengths(w)
+	}
+	for _, token := range tokens {
+		if token.length == 0 {
+			codes[0].write(w, int(token.pixel>>8&0xff))
+			codes[1].write(w, int(token.pixel>>16&0xff))
+			codes[2].write(w, int(token.pixel&0xff))
+			codes[3].write(w, int(token.pixel>>24))
+			continue
+		}
+		symbol, extraBits, extra := vp8lPrefix(token.length)
+		codes[0].write(w, 256+symbol)
+		w.write(uint32(extra), uint(extraBits))
+		symbol, extraBits, extra = vp8lPrefix(token.distance)
+		codes[4].write(w, symbol)
+		w.write(uint32(extra), uint(extraBits))
+	}
+}
+
+// bitWriter writes a bitstream least significant bit first
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	bits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.acc |= uint64(value) << w.bits
+	w.bits += n
+	for w.bits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.bits -= 8
+	}
+}
+
+// bytes returns what was written, padded to whole bytes
+func (w *bitWriter) bytes() []byte {
+	if w.bits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.bits = 0, 0
+	}
+	return w.buf
+}
+
+// prefixCode is a canonical prefix code of an alphabet
+type prefixCode struct {
+	lengths []int
+	// codes are bit reversed, as they are written
+	codes []uint32
+	// single is set if only one symbol is used, which takes no bits
+	single bool
+}
+
+// newPrefixCode builds the prefix code of symbols counted in counts, with
+// codes no longer than maxLength bits
+func newPrefixCode(counts []int, maxLength int) *prefixCode {
+	c := &prefixCode{lengths: make([]int, len(counts)), codes: make([]uint32, len(counts))}
+	var used []int
+	for symbol, count := range counts {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) <= 1 {
+		// An unused code still needs a symbol
+		c.single = true
+		c.lengths[append(used, 0)[0]] = 1
+		return c
+	}
+	weights := slices.Clone(counts)
+	for !huffmanLengths(weights, c.lengths, maxLength) {
+		// Flattening the counts shortens the longest codes
+		for i, weight := range weights {
+			if weight > 0 {
+				weights[i] = (weight + 1) / 2
+			}
+		}
+	}
+
+	var next [16]uint32
+	code := uint32(0)
+	for length := 1; length <= maxLength; length++ {
+		for _, l := range c.lengths {
+			if l == length-1 && l > 0 {
+				code++
+			}
+		}
+		code <<= 1
+		next[length] = code
+	}
+	for symbol, length := range c.lengths {
+		if length > 0 {
+			c.codes[symbol] = bits.Reverse32(next[length]) >> (32 - length)
+			next[length]++
+		}
+	}
+	return c
+}
+
+// huffmanLengths sets the Huffman code lengths of the symbols weighted by
+// weights, reporting whether none is longer than maxLength
+func huffmanLengths(weights, lengths []int, maxLength int) bool {
+	type node struct {
+		weight      int
+		left, right int
+	}
+	var nodes []node
+	for _, weight := range weights {
+		nodes = append(nodes, node{weight: weight, left: -1, right: -1})
+	}
+	var leaves []int
+	for symbol, weight := range weights {
+		if weight > 0 {
+			leaves = append(leaves, symbol)
+		}
+	}
+	slices.SortStableFunc(leaves, func(a, b int) int { return weights[a] - weights[b] })
+	// Two queues, leaves by weight and the merged nodes in the order they
+	// were made, which is by weight as well
+	var merged []int
+	take := func() int {
+		if len(merged) == 0 || len(leaves) > 0 && nodes[leaves[0]].weight <= nodes[merged[0]].weight {
+			n := leaves[0]
+			leaves = leaves[1:]
+			return n
+		}
+		n := merged[0]
+		merged = merged[1:]
+		return n
+	}
+	for len(leaves)+len(merged) > 1 {
+		a, b := take(), take()
+		nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, left: a, right: b})
+		merged = append(merged, len(nodes)-1)
+	}
+
+	clear(lengths)
+	ok := true
+	var walk func(n, depth int)
+	walk = func(n, depth int) {
+		if nodes[n].left < 0 {
+			lengths[n] = depth
+			ok = ok && depth <= maxLength
+			return
+		}
+		walk(nodes[n].left, depth+1)
+		walk(nodes[n].right, depth+1)
+	}
+	walk(merged[0], 0)
+	return ok
+}
+
+// write writes a symbol
+func (c *prefixCode) write(w *bitWriter, symbol int) {
+	if !c.single {
+		w.write(c.codes[symbol], uint(c.lengths[symbol]))
+	}
+}
+
+// writeLengths writes the code, as a simple code for up to two symbols
+// below 256, otherwise as code lengths coded themselves with a prefix code
+func (c *prefixCode) writeLengths(w *bitWriter) {
+	var used []int
+	for symbol, length := range c.lengths {
+		if length > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		w.write(1, 1)
+		w.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			w.write(uint32(used[1]), 8)
+		}
+		return
+	}
+
+	// Runs of lengths are coded as repeats: 16 repeats the previous length
+	// 3-6 times, 17 and 18 repeat zero 3-10 and 11-138 times
+	type run struct{ symbol, extraBits, extra int }
+	var runs []run
+	for i := 0; i < len(c.lengths); {
+		length, n := c.lengths[i], 1
+		for i+n < len(c.lengths) && c.lengths[i+n] == length {
+			n++
+		}
+		i += n
+		if length == 0 {
+			for ; n >= 11; n -= min(n, 138) {
+				runs = append(runs, run{18, 7, min(n, 138) - 11})
+			}
+			if n >= 3 {
+				runs = append(runs, run{17, 3, n - 3})
+				n = 0
+			}
+		} else {
+			runs = append(runs, run{symbol: length})
+			n--
+			for ; n >= 3; n -= min(n, 6) {
+				runs = append(runs, run{16, 2, min(n, 6) - 3})
+			}
+		}
+		for ; n > 0; n-- {
+			runs = append(runs, run{symbol: length})
+		}
+	}
+	counts := make([]int, len(vp8lCodeLengthOrder))
+	for _, r := range runs {
+		counts[r.symbol]++
+	}
+	lengthCode := newPrefixCode(counts, 7)
+	n := len(vp8lCodeLengthOrder)
+	for n > 4 && lengthCode.lengths[vp8lCodeLengthOrder[n-1]] == 0 {
+		n--
+	}
+	w.write(0, 1)
+	w.write(uint32(n-4), 4)
+	for _, symbol := range vp8lCodeLengthOrder[:n] {
+		w.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	w.write(0, 1) // lengths for the whole alphabet
+	for _, r := range runs {
+		lengthCode.write(w, r.symbol)
+		w.write(uint32(r.extra), uint(r.extraBits))
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/image/vp8l"
+)
+
+// checkVP8L decodes an encoded image and compares it with the original
+func checkVP8L(t *testing.T, name string, img *image.RGBA) {
+	t.Helper()
+	decoded, err := vp8l.Decode(bytes.NewReader(encodeVP8L(img)))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Fatalf("%s: decoded %v", name, decoded.Bounds())
+	}
+	for y := range img.Bounds().Dy() {
+		for x := range img.Bounds().Dx() {
+			r, g, b, _ := decoded.At(x, y).RGBA()
+			want := img.RGBAAt(x, y)
+			if uint8(r>>8) != want.R || uint8(g>>8) != want.G || uint8(b>>8) != want.B {
+				t.Fatalf("%s: pixel %d,%d decoded as %d,%d,%d, expected %v", name, x, y, r>>8, g>>8, b>>8, want)
+			}
+		}
+	}
+}
+
+func TestEncodeVP8L(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	noise := image.NewRGBA(image.Rect(0, 0, 61, 23))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(rng.IntN(256))
+		if i%4 == 3 {
+			noise.Pix[i] = 255
+		}
+	}
+	checkVP8L(t, "noise", noise)
+
+	flat := image.NewRGBA(image.Rect(0, 0, 600, 40))
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	checkVP8L(t, "flat", flat)
+	checkVP8L(t, "pixel", image.NewRGBA(image.Rect(0, 0, 1, 1)))
+
+	photo, err := jpeg.Decode(bytes.NewReader(testJPEG(t, 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := image.NewRGBA(image.Rect(0, 0, 640, 300))
+	for y := 0; y < 300; y += 16 {
+		for x := 0; x < 640; x += 32 {
+			draw.Draw(large, image.Rect(x, y, x+32, y+16), photo, image.Point{}, draw.Src)
+		}
+	}
+	checkVP8L(t, "tiled", large)
+}
+
+func TestEncodeAnimatedWebP(t *testing.T) {
+	var frames []webpFrame
+	for i := range 3 {
+		img := image.NewRGBA(image.Rect(0, 0, 33, 17))
+		for p := range img.Pix {
+			img.Pix[p] = uint8(p*i + i)
+			if p%4 == 3 {
+				img.Pix[p] = 255
+			}
+		}
+		frames = append(frames, webpFrame{Image: img, Duration: 100 * (i + 1)})
+	}
+	var buf bytes.Buffer
+	if err := encodeAnimatedWebP(&buf, frames); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" || int(binary.LittleEndian.Uint32(data[4:])) != len(data)-8 {
+		t.Fatalf("invalid RIFF header %q", data[:12])
+	}
+
+	// Walk the chunks, decoding every frame
+	var chunks []string
+	n := 0
+	for p := 12; p < len(data); {
+		fourCC, size := string(data[p:p+4]), int(binary.LittleEndian.Uint32(data[p+4:]))
+		payload := data[p+8 : p+8+size]
+		chunks = append(chunks, fourCC)
+		if fourCC == "ANMF" {
+			if duration := int(payload[12]) | int(payload[13])<<8 | int(payload[14])<<16; duration != frames[n].Duration {
+				t.Errorf("frame %d lasts %d ms", n, duration)
+			}
+			if string(payload[16:20]) != "VP8L" {
+				t.Fatalf("frame %d holds %q", n, payload[16:20])
+			}
+			decoded, err := vp8l.Decode(bytes.NewReader(payload[24:]))
+			if err != nil {
+				t.Fatalf("frame %d: %v", n, err)
+			}
+			if r, _, _, _ := decoded.At(5, 5).RGBA(); uint8(r>>8) != frames[n].Image.RGBAAt(5, 5).R {
+				t.Errorf("frame %d decoded wrong", n)
+			}
+			n++
+		}
+		p += 8 + size + size%2
+	}
+	if len(chunks) != 5 || chunks[0] != "VP8X" || chunks[1] != "ANIM" || n != 3 {
+		t.Errorf("chunks %v", chunks)
+	}
+}
+
+func TestPrefixCode(t *testing.T) {
+	// Skewed counts would need codes longer than the limit
+	counts := make([]int, 40)
+	for i := range counts {
+		counts[i] = 1 << min(i, 30)
+	}
+	code := newPrefixCode(counts, 15)
+	kraft := 0.0
+	for _, length := range code.lengths {
+		if length > 15 {
+			t.Fatalf("code of %d bits", length)
+		}
+		if length > 0 {
+			kraft += 1 / float64(int(1)<<length)
+		}
+	}
+	if kraft != 1 {
+		t.Errorf("code is not complete, Kraft sum %v", kraft)
+	}
+}
+
+func TestVP8LPrefix(t *testing.T) {
+	for value, want := range map[int][3]int{1: {0, 0, 0}, 4: {3, 0, 0}, 5: {4, 1, 0}, 6: {4, 1, 1}, 7: {5, 1, 0}, 4096: {23, 10, 1023}} {
+		symbol, extraBits, extra := vp8lPrefix(value)
+		if [3]int{symbol, extraBits, extra} != want {
+			t.Errorf("%d coded as %d %d %d, expected %v", value, symbol, extraBits, extra, want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The web UI is a single page served by the API for phones and tablets: the
+// live stream of a camera picked from the list, snapshots, recording,
+// profiles and the camera's controls, all through the API. A token given
+// as ?token= in the page's address, or typed in when the API refuses the
+// page's requests, is kept in the browser.
+
+//go:embed web/index.html
+var webUIPage []byte
+
+// handleWebUI serves the web UI
+func handleWebUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(webUIPage)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"github.com/Zyko0/go-sdl3/sdl"
+)
+
+const (
+	// wbPatchSize is the size of the area averaged around the clicked
+	// point, as a fraction of the frame
+	wbPatchSize = 0.04
+	// wbMinLevel and wbMaxLevel reject patches too dark or too close to
+	// clipping to tell the channels apart
+	wbMinLevel = 16
+	wbMaxLevel = 245
+	// wbMaxGain limits the correction of any one channel
+	wbMaxGain = 4
+)
+
+// WhiteBalance is a software white balance applied to a camera's frames,
+// for cameras whose hardware AWB is poor. The per-channel gains come from a
+// gray-card calibration and are applied through lookup tables.
+type WhiteBalance struct {
+	Gains [3]float32
+	lut   [3][256]uint8
+}
+
+// WhiteBalancePickState is set while waiting for a click on a neutral area
+// of the main view
+type WhiteBalancePickState struct {
+	Active bool
+	Camera int
+}
+
+// newWhiteBalance builds the lookup tables for the given RGB gains
+func newWhiteBalance(gains [3]float32) *WhiteBalance {
+	wb := &WhiteBalance{Gains: gains}
+	for c, gain := range gains {
+		for v := range wb.lut[c] {
+			wb.lut[c][v] = uint8(min(float32(v)*gain+0.5, 255))
+		}
+	}
+	return wb
+}
+
+// startWhiteBalancePick shows a camera in the main view and waits for a
+// click on something that should be neutral grey
+func startWhiteBalancePick(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+	if !camera.Active || camera.Disabled {
+		setErrorStatus(appData, trErr("error.not_stream", camera.Info.Name))
+		return
+	}
+	selectCamera(appData, index)
+	appData.WhiteBalancePick = WhiteBalancePickState{Active: true, Camera: index}
+	appData.StatusText = tr("status.wb_pick", camera.Info.Name)
+}
+
+// handleWhiteBalancePick takes the calibration sample from a click on the
+// main view; a click anywhere else cancels the calibration. It reports
+// whether the click was consumed.
+func handleWhiteBalancePick(appData *CameraAppData, x, y float32) bool {
+	pick := &appData.WhiteBalancePick
+	if !pick.Active {
+		return false
+	}
+	pick.Active = false
+	if pick.Camera >= len(appData.Cameras) || pick.Camera != appData.SelectedCamera {
+		return false
+	}
+	camera := &appData.Cameras[pick.Camera]
+
+	rect, ok := mainCameraRect()
+	if !ok || x < rect.X || x > rect.X+rect.W || y < rect.Y || y > rect.Y+rect.H {
+		appData.StatusText = tr("status.ready")
+		return false
+	}
+	u, v := viewToFrame(rect, x, y)
+
+	// The gains are computed from the next decoded frame
+	camera.FrameMutex.Lock()
+	camera.WhiteBalanceSample = &sdl.FPoint{X: u, Y: v}
+	camera.FrameMutex.Unlock()
+	appData.StatusText = tr("status.wb_set", camera.Info.Name)
+	return true
+}
+
+// resetWhiteBalance removes a camera's software white balance
+func resetWhiteBalance(appData *CameraAppData, index int) {
+	camera := &appData.Cameras[index]
+	camera.FrameMutex.Lock()
+	camera.WhiteBalance = nil
+	camera.WhiteBalanceSample = nil
+	camera.FrameMutex.Unlock()
+	appData.StatusText = tr("status.wb_reset", camera.Info.Name)
+}
+
+// applyWhiteBalance calibrates the white balance from a pending sample and
+// applies the current gains to the frame. It is called with FrameMutex held.
+func applyWhiteBalance(camera *CameraInstance, img *image.RGBA) {
+	if sample := camera.WhiteBalanceSample; sample != nil {
+		camera.WhiteBalanceSample = nil
+		if gains, ok := grayCardGains(img, sample.X, sample.Y); ok {
+			camera.WhiteBalance = newWhiteBalance(gains)
+			log.Printf("White balance for %s: gains %.2f", camera.Info.Name, gains)
+		} else {
+			log.Printf("White balance for %s: sample is too dark or clipped", camera.Info.Name)
+		}
+	}
+
+	wb := camera.WhiteBalance
+	if wb == nil {
+		return
+	}
+	pix := img.Pix
+	for i := 0; i+3 < len(pix); i += 4 {
+		pix[i] = wb.lut[0][pix[i]]
+		pix[i+1] = wb.lut[1][pix[i+1]]
+		pix[i+2] = wb.lut[2][pix[i+2]]
+	}
+}
+
+// grayCardGains averages each channel over a patch around (u, v), in
+// normalized frame coordinates, and returns the gains that make the patch
+// neutral while keeping its brightness
+func grayCardGains(img *image.RGBA, u, v float32) ([3]float32, bool) {
+	bounds := img.Bounds()
+	halfW := max(int(wbPatchSize*float32(bounds.Dx())/2), 1)
+	halfH := max(int(wbPatchSize*float32(bounds.Dy())/2), 1)
+	cx := bounds.Min.X + int(u*float32(bounds.Dx()))
+	cy := bounds.Min.Y + int(v*float32(bounds.Dy()))
+	patch := image.Rect(cx-halfW, cy-halfH, cx+halfW, cy+halfH).Intersect(bounds)
+	if patch.Empty() {
+		return [3]float32{}, false
+	}
+
+	var sums [3]uint64
+	for y := patch.Min.Y; y < patch.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(patch.Min.X, y):]
+		for x := 0; x < patch.Dx(); x++ {
+			sums[0] += uint64(row[x*4])
+			sums[1] += uint64(row[x*4+1])
+			sums[2] += uint64(row[x*4+2])
+		}
+	}
+
+	count := float32(patch.Dx() * patch.Dy())
+	var means [3]float32
+	for c := range means {
+		means[c] = float32(sums[c]) / count
+		if means[c] < wbMinLevel || means[c] > wbMaxLevel {
+			return [3]float32{}, false
+		}
+	}
+
+	gray := (means[0] + means[1] + means[2]) / 3
+	var gains [3]float32
+	for c := range gains {
+		gains[c] = min(gray/means[c], wbMaxGain)
+	}
+	return gains, true
+}
@@ -0,0 +1,81 @@
+package main
+
+import "image"
+
+// YUV frames are converted in 16-bit fixed point arithmetic, which NEON does
+// eight lanes at a time on arm64 (yuv_arm64.s): channels carry yuvShift
+// fractional bits, and each chroma term is a rounded product with a
+// coefficient scaled by 1<<(yuvShift+8), as SQRDMULH computes it. The
+// portable code below does the same arithmetic, so both give the same
+// pixels, within 1 of the exact BT.601 conversion. Build with -tags purego
+// to leave the assembly out.
+
+// yuvShift is the number of fractional bits of a channel
+const yuvShift = 6
+
+// The BT.601 coefficients of Cr for red, Cb and Cr for green and Cb for
+// blue, scaled by 1<<(yuvShift+8)
+const (
+	yuvRedCr   = 22970
+	yuvGreenCb = 5638
+	yuvGreenCr = 11700
+	yuvBlueCb  = 29032
+)
+
+// yuvTerm is a chroma sample's contribution to a channel, with yuvShift
+// fractional bits
+func yuvTerm(chroma, coefficient int32) int32 {
+	return (chroma*coefficient + 128) >> 8
+}
+
+// yuvClamp scales a fixed point channel value back and clamps it to a byte
+func yuvClamp(v int32) uint8 {
+	v >>= yuvShift
+	if uint32(v) < 256 {
+		return uint8(v)
+	}
+	// 0 below the range, 255 above it
+	return uint8(^(v >> 31))
+}
+
+// ycbcrRow converts the pixels from x, which is even, to width of a row with
+// one chroma sample per pixel pair to RGBA
+func ycbcrRow(dst, luma, cb, cr []byte, x, width int) {
+	for ; x < width; x += 2 {
+		b, r := int32(cb[x/2])-128, int32(cr[x/2])-128
+		red, green, blue := yuvTerm(r, yuvRedCr), -(yuvTerm(b, yuvGreenCb) + yuvTerm(r, yuvGreenCr)), yuvTerm(b, yuvBlueCb)
+		yuvPixel(dst[x*4:x*4+4:x*4+4], luma[x], red, green, blue)
+		if x+1 < width {
+			yuvPixel(dst[x*4+4:x*4+8:x*4+8], luma[x+1], red, green, blue)
+		}
+	}
+}
+
+// yuvPixel sets an RGBA pixel from its luma and the chroma terms
+func yuvPixel(p []byte, luma uint8, red, green, blue int32) {
+	y := int32(luma)<<yuvShift + 1<<(yuvShift-1)
+	p[0] = yuvClamp(y + red)
+	p[1] = yuvClamp(y + green)
+	p[2] = yuvClamp(y + blue)
+	p[3] = 0xff
+}
+
+// ycbcrToRGBA converts a 4:2:2 or 4:2:0 image, as YUYV and NV12 frames and
+// most JPEGs are, to RGBA. It returns nil for other layouts.
+func ycbcrToRGBA(src *image.YCbCr) *image.RGBA {
+	bounds := src.Bounds()
+	if src.SubsampleRatio != image.YCbCrSubsampleRatio422 && src.SubsampleRatio != image.YCbCrSubsampleRatio420 ||
+		bounds.Min.X%2 != 0 {
+		return nil
+	}
+	dst := image.NewRGBA(bounds)
+	width := bounds.Dx()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		yi, ci := src.YOffset(bounds.Min.X, y), src.COffset(bounds.Min.X, y)
+		chroma := (width + 1) / 2
+		row := dst.Pix[(y-bounds.Min.Y)*dst.Stride:]
+		luma, cb, cr := src.Y[yi:yi+width], src.Cb[ci:ci+chroma], src.Cr[ci:ci+chroma]
+		ycbcrRow(row, luma, cb, cr, ycbcrBlocks(row, luma, cb, cr, width), width)
+	}
+	return dst
+}
@@ -0,0 +1,48 @@
+//go:build !purego
+
+package main
+
+// neonYUV converts YCbCr frames to RGBA with ycbcrToRGBA
+const neonYUV = true
+
+//go:noescape
+func ycbcrBlocksNEON(dst, y, cb, cr *byte, blocks int)
+
+//go:noescape
+func unpackYUYVBlocksNEON(y, cb, cr, src *byte, blocks int)
+
+//go:noescape
+func splitPairsNEON(a, b, src *byte, blocks int)
+
+// ycbcrBlocks converts the whole 32-pixel blocks of a row to RGBA and
+// returns how many pixels it converted
+func ycbcrBlocks(dst, luma, cb, cr []byte, width int) int {
+	blocks := width / 32
+	if blocks == 0 {
+		return 0
+	}
+	ycbcrBlocksNEON(&dst[0], &luma[0], &cb[0], &cr[0], blocks)
+	return blocks * 32
+}
+
+// unpackYUYVBlocks splits the whole 32-pixel blocks of a YUYV row into
+// planes and returns how many pixels it unpacked
+func unpackYUYVBlocks(luma, cb, cr, src []byte, width int) int {
+	blocks := width / 32
+	if blocks == 0 {
+		return 0
+	}
+	unpackYUYVBlocksNEON(&luma[0], &cb[0], &cr[0], &src[0], blocks)
+	return blocks * 32
+}
+
+// splitPairs splits the whole 16-pair blocks of n interleaved byte pairs
+// into a and b and returns how many pairs it split
+func splitPairs(a, b, src []byte, n int) int {
+	blocks := n / 16
+	if blocks == 0 {
+		return 0
+	}
+	splitPairsNEON(&a[0], &b[0], &src[0], blocks)
+	return blocks * 16
+}
@@ -0,0 +1,142 @@
+//go:build !purego
+
+#include "go_asm.h"
+#include "textflag.h"
+
+// NEON kernels for the YUV conversions in yuv.go. Each block is 32 pixels;
+// the Go wrappers convert whole blocks here and leave the rest of a row to
+// the portable code, which computes the same values.
+
+// YUV_HALF converts the chroma in V4 (Cb) and V5 (Cr), widened to 16 bits,
+// and the luma in V9 (even pixels) and V10 (odd pixels), shifted left by 6
+// with the rounding bias added, into R, G and B of the even pixels in V12,
+// V13, V14 and of the odd pixels in V15, V16, V17, narrowing with NARROW
+// into the lanes given by ARR. The chroma terms are SQRDMULH of the chroma,
+// shifted left by 7, with the coefficients in V21 to V24, which the Go
+// assembler has no mnemonic for:
+//
+//	0x6e75b4a6	SQRDMULH V6.8H, V5.8H, V21.8H	red from Cr
+//	0x6e77b487	SQRDMULH V7.8H, V4.8H, V23.8H	green from Cb
+//	0x6e78b4b2	SQRDMULH V18.8H, V5.8H, V24.8H	green from Cr
+//	0x6e76b488	SQRDMULH V8.8H, V4.8H, V22.8H	blue from Cb
+#define YUV_HALF(NARROW, ARR) \
+	VSUB	V20.H8, V4.H8, V4.H8; \
+	VSUB	V20.H8, V5.H8, V5.H8; \
+	VSHL	$7, V4.H8, V4.H8; \
+	VSHL	$7, V5.H8, V5.H8; \
+	WORD	$0x6e75b4a6; \
+	WORD	$0x6e77b487; \
+	WORD	$0x6e78b4b2; \
+	WORD	$0x6e76b488; \
+	VADD	V18.H8, V7.H8, V7.H8; \
+	VADD	V6.H8, V9.H8, V11.H8; \
+	VSSHR	$6, V11.H8, V11.H8; \
+	NARROW	V11.H8, V12.ARR; \
+	VSUB	V7.H8, V9.H8, V11.H8; \
+	VSSHR	$6, V11.H8, V11.H8; \
+	NARROW	V11.H8, V13.ARR; \
+	VADD	V8.H8, V9.H8, V11.H8; \
+	VSSHR	$6, V11.H8, V11.H8; \
+	NARROW	V11.H8, V14.ARR; \
+	VADD	V6.H8, V10.H8, V11.H8; \
+	VSSHR	$6, V11.H8, V11.H8; \
+	NARROW	V11.H8, V15.ARR; \
+	VSUB	V7.H8, V10.H8, V11.H8; \
+	VSSHR	$6, V11.H8, V11.H8; \
+	NARROW	V11.H8, V16.ARR; \
+	VADD	V8.H8, V10.H8, V11.H8; \
+	VSSHR	$6, V11.H8, V11.H8; \
+	NARROW	V11.H8, V17.ARR
+
+// func ycbcrBlocksNEON(dst, y, cb, cr *byte, blocks int)
+TEXT ·ycbcrBlocksNEON(SB), NOSPLIT, $0-40
+	MOVD	dst+0(FP), R0
+	MOVD	y+8(FP), R1
+	MOVD	cb+16(FP), R2
+	MOVD	cr+24(FP), R3
+	MOVD	blocks+32(FP), R4
+
+	MOVD	$128, R5
+	VDUP	R5, V20.H8
+	MOVD	$const_yuvRedCr, R5
+	VDUP	R5, V21.H8
+	MOVD	$const_yuvBlueCb, R5
+	VDUP	R5, V22.H8
+	MOVD	$const_yuvGreenCb, R5
+	VDUP	R5, V23.H8
+	MOVD	$const_yuvGreenCr, R5
+	VDUP	R5, V24.H8
+	MOVD	$32, R5
+	VDUP	R5, V25.H8
+	VMOVI	$255, V30.B16
+
+loop:
+	// Even luma in V0, odd luma in V1, one chroma sample per pair
+	VLD2.P	32(R1), [V0.B16, V1.B16]
+	VLD1.P	16(R2), [V2.B16]
+	VLD1.P	16(R3), [V3.B16]
+
+	VUXTL	V2.B8, V4.H8
+	VUXTL	V3.B8, V5.H8
+	VUSHLL	$6, V0.B8, V9.H8
+	VADD	V25.H8, V9.H8, V9.H8
+	VUSHLL	$6, V1.B8, V10.H8
+	VADD	V25.H8, V10.H8, V10.H8
+	YUV_HALF(VSQXTUN, B8)
+
+	VUXTL2	V2.B16, V4.H8
+	VUXTL2	V3.B16, V5.H8
+	VUSHLL2	$6, V0.B16, V9.H8
+	VADD	V25.H8, V9.H8, V9.H8
+	VUSHLL2	$6, V1.B16, V10.H8
+	VADD	V25.H8, V10.H8, V10.H8
+	YUV_HALF(VSQXTUN2, B16)
+
+	// Interleave even and odd pixels and store them as RGBA
+	VZIP1	V15.B16, V12.B16, V27.B16
+	VZIP1	V16.B16, V13.B16, V28.B16
+	VZIP1	V17.B16, V14.B16, V29.B16
+	VST4.P	[V27.B16, V28.B16, V29.B16, V30.B16], 64(R0)
+	VZIP2	V15.B16, V12.B16, V27.B16
+	VZIP2	V16.B16, V13.B16, V28.B16
+	VZIP2	V17.B16, V14.B16, V29.B16
+	VST4.P	[V27.B16, V28.B16, V29.B16, V30.B16], 64(R0)
+
+	SUBS	$1, R4, R4
+	BNE	loop
+	RET
+
+// func unpackYUYVBlocksNEON(y, cb, cr, src *byte, blocks int)
+TEXT ·unpackYUYVBlocksNEON(SB), NOSPLIT, $0-40
+	MOVD	y+0(FP), R0
+	MOVD	cb+8(FP), R1
+	MOVD	cr+16(FP), R2
+	MOVD	src+24(FP), R3
+	MOVD	blocks+32(FP), R4
+
+unpack:
+	// Y0 in V0, Cb in V1, Y1 in V2, Cr in V3
+	VLD4.P	64(R3), [V0.B16, V1.B16, V2.B16, V3.B16]
+	VMOV	V1.B16, V4.B16
+	VMOV	V2.B16, V1.B16
+	VST2.P	[V0.B16, V1.B16], 32(R0)
+	VST1.P	[V4.B16], 16(R1)
+	VST1.P	[V3.B16], 16(R2)
+	SUBS	$1, R4, R4
+	BNE	unpack
+	RET
+
+// func splitPairsNEON(a, b, src *byte, blocks int)
+TEXT ·splitPairsNEON(SB), NOSPLIT, $0-32
+	MOVD	a+0(FP), R0
+	MOVD	b+8(FP), R1
+	MOVD	src+16(FP), R2
+	MOVD	blocks+24(FP), R3
+
+split:
+	VLD2.P	32(R2), [V0.B16, V1.B16]
+	VST1.P	[V0.B16], 16(R0)
+	VST1.P	[V1.B16], 16(R1)
+	SUBS	$1, R3, R3
+	BNE	split
+	RET
@@ -0,0 +1,13 @@
+//go:build !arm64 || purego
+
+package main
+
+// Without NEON, the portable loops do all the work, and frames are
+// converted to RGBA by the standard library
+const neonYUV = false
+
+func ycbcrBlocks(dst, luma, cb, cr []byte, width int) int { return 0 }
+
+func unpackYUYVBlocks(luma, cb, cr, src []byte, width int) int { return 0 }
+
+func splitPairs(a, b, src []byte, n int) int { return 0 }
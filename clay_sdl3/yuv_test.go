@@ -0,0 +1,153 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// randomYCbCr returns an image of random samples, with full-range extremes
+// along the first row to exercise clamping
+func randomYCbCr(rng *rand.Rand, width, height int, ratio image.YCbCrSubsampleRatio) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), ratio)
+	for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+		for i := range plane {
+			plane[i] = byte(rng.Intn(256))
+		}
+		for i := 0; i < len(plane) && i < width; i++ {
+			plane[i] = byte(i%2) * 255
+		}
+	}
+	return img
+}
+
+func TestYCbCrToRGBAMatchesPortable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, ratio := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420} {
+		for _, width := range []int{1, 2, 31, 32, 33, 64, 95, 130} {
+			img := randomYCbCr(rng, width, 5, ratio)
+			got := ycbcrToRGBA(img)
+			for y := range 5 {
+				want := make([]byte, width*4)
+				yi, ci := img.YOffset(0, y), img.COffset(0, y)
+				ycbcrRow(want, img.Y[yi:], img.Cb[ci:], img.Cr[ci:], 0, width)
+				if row := got.Pix[y*got.Stride : y*got.Stride+width*4]; string(row) != string(want) {
+					t.Fatalf("%v, width %d, row %d differs from the portable conversion", ratio, width, y)
+				}
+			}
+		}
+	}
+}
+
+func TestYCbCrToRGBAMatchesBT601(t *testing.T) {
+	// Every sample combination, one pixel pair each
+	img := image.NewYCbCr(image.Rect(0, 0, 512, 256*256), image.YCbCrSubsampleRatio422)
+	for row := range 256 * 256 {
+		for x := range 256 {
+			img.Y[row*img.YStride+2*x], img.Y[row*img.YStride+2*x+1] = byte(x), byte(x)
+		}
+		for x := range 256 {
+			img.Cb[row*img.CStride+x], img.Cr[row*img.CStride+x] = byte(row>>8), byte(row)
+		}
+	}
+	got := ycbcrToRGBA(img)
+	worst := 0
+	for row := range 256 * 256 {
+		for x := range 256 {
+			r, g, b := color.YCbCrToRGB(byte(x), byte(row>>8), byte(row))
+			p := got.Pix[row*got.Stride+8*x:]
+			worst = max(worst, absDiff(p[0], r), absDiff(p[1], g), absDiff(p[2], b))
+		}
+	}
+	if worst > 1 {
+		t.Errorf("differs from BT.601 by up to %d", worst)
+	}
+}
+
+func TestYCbCrToRGBASubImage(t *testing.T) {
+	img := randomYCbCr(rand.New(rand.NewSource(2)), 100, 20, image.YCbCrSubsampleRatio420)
+	sub := img.SubImage(image.Rect(34, 3, 98, 19)).(*image.YCbCr)
+	got := ycbcrToRGBA(sub)
+	whole := ycbcrToRGBA(img)
+	for y := 3; y < 19; y++ {
+		for x := 34; x < 98; x++ {
+			if got.RGBAAt(x, y) != whole.RGBAAt(x, y) {
+				t.Fatalf("pixel %d,%d of the sub-image differs", x, y)
+			}
+		}
+	}
+	if odd := img.SubImage(image.Rect(1, 0, 10, 10)).(*image.YCbCr); ycbcrToRGBA(odd) != nil {
+		t.Error("converted an image whose chroma does not start on a pixel pair")
+	}
+}
+
+func TestUnpackYUV(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, width := range []int{2, 30, 64, 100} {
+		const height = 4
+		yuyv := make([]byte, width*height*2)
+		rng.Read(yuyv)
+		img, err := yuvImage(yuyv, v4l2.PixFormat{Width: uint32(width), Height: height}, image.YCbCrSubsampleRatio422)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ycbcr := img.(*image.YCbCr)
+		for y := range height {
+			for x := range width {
+				p := yuyv[(y*width+x)*2:]
+				if ycbcr.Y[y*ycbcr.YStride+x] != p[0] {
+					t.Fatalf("width %d: luma %d,%d differs", width, x, y)
+				}
+				c := yuyv[(y*width+x/2*2)*2:]
+				if ycbcr.Cb[y*ycbcr.CStride+x/2] != c[1] || ycbcr.Cr[y*ycbcr.CStride+x/2] != c[3] {
+					t.Fatalf("width %d: chroma %d,%d differs", width, x, y)
+				}
+			}
+		}
+
+		nv12 := make([]byte, width*height*3/2)
+		rng.Read(nv12)
+		img, err = yuvImage(nv12, v4l2.PixFormat{Width: uint32(width), Height: height}, image.YCbCrSubsampleRatio420)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ycbcr = img.(*image.YCbCr)
+		for y := range height / 2 {
+			for x := range width / 2 {
+				p := nv12[width*height+y*width+2*x:]
+				if ycbcr.Cb[y*ycbcr.CStride+x] != p[0] || ycbcr.Cr[y*ycbcr.CStride+x] != p[1] {
+					t.Fatalf("width %d: NV12 chroma %d,%d differs", width, x, y)
+				}
+			}
+		}
+	}
+}
+
+// The benchmarks convert a frame as the capture goroutine and the main loop
+// do, from the device's data to RGBA; compare with -tags purego
+func benchmarkYUV(b *testing.B, format v4l2.FourCCType, width, height int) {
+	pixFormat := v4l2.PixFormat{Width: uint32(width), Height: uint32(height), PixelFormat: format}
+	ratio := yuvFormats[format]
+	size := width * height * 2
+	if ratio == image.YCbCrSubsampleRatio420 {
+		size = width * height * 3 / 2
+	}
+	data := make([]byte, size)
+	rand.New(rand.NewSource(4)).Read(data)
+	b.SetBytes(int64(size))
+	for b.Loop() {
+		img, err := yuvImage(data, pixFormat, ratio)
+		if err != nil {
+			b.Fatal(err)
+		}
+		toRGBA(img)
+	}
+}
+
+func BenchmarkYUYV640x480(b *testing.B)   { benchmarkYUV(b, v4l2.PixelFmtYUYV, 640, 480) }
+func BenchmarkYUYV1920x1080(b *testing.B) { benchmarkYUV(b, v4l2.PixelFmtYUYV, 1920, 1080) }
+func BenchmarkNV12640x480(b *testing.B)   { benchmarkYUV(b, fourcc("NV12"), 640, 480) }
+func BenchmarkNV121920x1080(b *testing.B) { benchmarkYUV(b, fourcc("NV12"), 1920, 1080) }
@@ -3,11 +3,13 @@ package main
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/jpeg"
 	"log"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -21,9 +23,9 @@ import (
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/paint"
+	"gioui.org/widget"
 	"gioui.org/widget/material"
 	"github.com/aarzilli/nucular"
-	"github.com/aarzilli/nucular/style"
 	"github.com/vladimirvivien/go4vl/device"
 	"github.com/vladimirvivien/go4vl/v4l2"
 )
@@ -57,20 +59,43 @@ type CameraApp struct {
 	ShowCamera  bool
 	GioWindow   *app.Window
 	Theme       *material.Theme
+
+	// Combined mode widgets, used when the controls are drawn in the Gio window
+	ToggleCameraBtn widget.Clickable
+	ThemeBtn        widget.Clickable
+	CameraButtons   []widget.Clickable
 }
 
 var cameraApp CameraApp
 
 func main() {
+	splitWindows := flag.Bool("split", false, "show the Nucular control panel in a separate window")
+	themeName := flag.String("theme", themes[0].Name, "UI theme: "+themeNames())
+	flag.Parse()
+	if err := setTheme(*themeName); err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize cameras
 	initAllCameras()
 
+	if !*splitWindows {
+		// Single Gio window with the control panel embedded next to the feed
+		go func() {
+			runCombinedWindow()
+			cleanupCameras()
+			os.Exit(0)
+		}()
+		app.Main()
+		return
+	}
+
 	// Start both Gio window for smooth camera rendering and Nucular for controls
 	go runGioWindow()
 
 	// Start nucular control window
 	wnd := nucular.NewMasterWindow(nucular.WindowClosable, "Camera Controls", updatefn)
-	wnd.SetStyle(style.FromTheme(style.RedTheme, 2.0))
+	wnd.SetStyle(nucularStyle(2.0))
 	wnd.Main()
 
 	// Cleanup when exiting
@@ -91,6 +116,7 @@ func runGioWindow() {
 			return
 		case app.FrameEvent:
 			gtx := app.NewContext(&ops, e)
+			applyGioTheme(gtx)
 
 			if cameraApp.ShowCamera && cameraApp.SelectedCam < len(cameraApp.Cameras) {
 				renderCameraWithGio(gtx)
@@ -202,6 +228,16 @@ func updatefn(w *nucular.Window) {
 		}
 	}
 
+	// Theme switch, applied to both windows
+	w.Row(30).Dynamic(1)
+	if w.ButtonText(fmt.Sprintf("Theme: %s", theme.Name)) {
+		cycleTheme()
+		w.Master().SetStyle(nucularStyle(2.0))
+		if cameraApp.GioWindow != nil {
+			cameraApp.GioWindow.Invalidate()
+		}
+	}
+
 	// Camera selection
 	if len(cameraApp.Cameras) > 0 {
 		w.Row(30).Dynamic(1)
@@ -254,7 +290,8 @@ func updatefn(w *nucular.Window) {
 	w.Row(30).Dynamic(1)
 	w.Label("Available Cameras:", "LC")
 
-	for i, camera := range cameraApp.Cameras {
+	for i := range cameraApp.Cameras {
+		camera := &cameraApp.Cameras[i]
 		w.Row(25).Dynamic(1)
 		status := "Inactive"
 		if camera.Active {
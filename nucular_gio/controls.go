@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// runCombinedWindow runs a single Gio window with the control panel on the
+// left and the camera feed on the right, replacing the separate Nucular
+// control window.
+func runCombinedWindow() {
+	gioWindow := new(app.Window)
+	gioWindow.Option(app.Title("Camera App"))
+	cameraApp.GioWindow = gioWindow
+	cameraApp.Theme = material.NewTheme()
+	cameraApp.CameraButtons = make([]widget.Clickable, len(cameraApp.Cameras))
+
+	var ops op.Ops
+
+	for {
+		switch e := gioWindow.Event().(type) {
+		case app.DestroyEvent:
+			return
+		case app.FrameEvent:
+			gtx := app.NewContext(&ops, e)
+
+			handleControlEvents(gtx)
+			applyGioTheme(gtx)
+
+			layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+				layout.Flexed(0.25, renderControlPanel),
+				layout.Flexed(0.75, func(gtx layout.Context) layout.Dimensions {
+					if cameraApp.ShowCamera && cameraApp.SelectedCam < len(cameraApp.Cameras) {
+						return renderCameraWithGio(gtx)
+					}
+					return renderPlaceholder(gtx)
+				}),
+			)
+
+			e.Frame(gtx.Ops)
+		}
+	}
+}
+
+func handleControlEvents(gtx layout.Context) {
+	if cameraApp.ToggleCameraBtn.Clicked(gtx) {
+		cameraApp.ShowCamera = !cameraApp.ShowCamera
+	}
+
+	if cameraApp.ThemeBtn.Clicked(gtx) {
+		cycleTheme()
+	}
+
+	for i := range cameraApp.CameraButtons {
+		if cameraApp.CameraButtons[i].Clicked(gtx) {
+			cameraApp.SelectedCam = i
+		}
+	}
+}
+
+// renderControlPanel is the Gio equivalent of the Nucular updatefn panel.
+func renderControlPanel(gtx layout.Context) layout.Dimensions {
+	th := cameraApp.Theme
+
+	children := []layout.FlexChild{
+		layout.Rigid(material.Body2(th, cameraApp.StatusText).Layout),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+		layout.Rigid(material.H6(th, "Camera Controls").Layout),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			text := fmt.Sprintf("Camera Display: %s", map[bool]string{true: "ON", false: "OFF"}[cameraApp.ShowCamera])
+			return material.Button(th, &cameraApp.ToggleCameraBtn, text).Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+			return material.Button(th, &cameraApp.ThemeBtn, fmt.Sprintf("Theme: %s", theme.Name)).Layout(gtx)
+		}),
+		layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
+	}
+
+	if len(cameraApp.Cameras) == 0 {
+		children = append(children, layout.Rigid(material.Body2(th, "No cameras found").Layout))
+	} else {
+		children = append(children, layout.Rigid(material.Body2(th, "Select Camera:").Layout))
+		for i := range cameraApp.CameraButtons {
+			i := i
+			children = append(children, layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Inset{Top: unit.Dp(3)}.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					btn := material.Button(th, &cameraApp.CameraButtons[i], fmt.Sprintf("Cam %d", i))
+					if i == cameraApp.SelectedCam {
+						btn.Background = th.Palette.ContrastBg
+					}
+					return btn.Layout(gtx)
+				})
+			}))
+		}
+
+		if cameraApp.SelectedCam < len(cameraApp.Cameras) {
+			camera := &cameraApp.Cameras[cameraApp.SelectedCam]
+			info := []string{
+				fmt.Sprintf("Current: %s", camera.Info.Name),
+				fmt.Sprintf("Resolution: %dx%d", camera.Width, camera.Height),
+				fmt.Sprintf("Status: %s", map[bool]string{true: "Active", false: "Inactive"}[camera.Active]),
+				fmt.Sprintf("Dropped frames: %d", atomic.LoadUint64(&camera.DroppedFrames)),
+			}
+			children = append(children, layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout))
+			for _, line := range info {
+				children = append(children, layout.Rigid(material.Caption(th, line).Layout))
+			}
+		}
+	}
+
+	return layout.UniformInset(unit.Dp(10)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, children...)
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+	"github.com/aarzilli/nucular/style"
+)
+
+// Theme is a named palette for the nucular controls and the Gio window
+type Theme struct {
+	Name string
+	// Nucular is the built-in nucular theme, used when Table is nil
+	Nucular style.Theme
+	Table   *style.ColorTable
+
+	// Palette is applied to the Gio material theme
+	Palette material.Palette
+}
+
+// highContrastTable is a black/white/yellow nucular palette readable in
+// sunlight
+var highContrastTable = style.ColorTable{
+	ColorText:                  color.RGBA{255, 255, 255, 255},
+	ColorWindow:                color.RGBA{0, 0, 0, 255},
+	ColorHeader:                color.RGBA{0, 0, 0, 255},
+	ColorHeaderFocused:         color.RGBA{40, 40, 40, 255},
+	ColorBorder:                color.RGBA{255, 255, 255, 255},
+	ColorButton:                color.RGBA{0, 0, 0, 255},
+	ColorButtonHover:           color.RGBA{90, 90, 0, 255},
+	ColorButtonActive:          color.RGBA{160, 160, 0, 255},
+	ColorToggle:                color.RGBA{40, 40, 40, 255},
+	ColorToggleHover:           color.RGBA{90, 90, 0, 255},
+	ColorToggleCursor:          color.RGBA{255, 255, 0, 255},
+	ColorSelect:                color.RGBA{40, 40, 40, 255},
+	ColorSelectActive:          color.RGBA{160, 160, 0, 255},
+	ColorSlider:                color.RGBA{40, 40, 40, 255},
+	ColorSliderCursor:          color.RGBA{255, 255, 0, 255},
+	ColorSliderCursorHover:     color.RGBA{255, 255, 128, 255},
+	ColorSliderCursorActive:    color.RGBA{255, 255, 255, 255},
+	ColorProperty:              color.RGBA{40, 40, 40, 255},
+	ColorEdit:                  color.RGBA{0, 0, 0, 255},
+	ColorEditCursor:            color.RGBA{255, 255, 0, 255},
+	ColorCombo:                 color.RGBA{0, 0, 0, 255},
+	ColorChart:                 color.RGBA{40, 40, 40, 255},
+	ColorChartColor:            color.RGBA{255, 255, 0, 255},
+	ColorChartColorHighlight:   color.RGBA{255, 255, 255, 255},
+	ColorScrollbar:             color.RGBA{40, 40, 40, 255},
+	ColorScrollbarCursor:       color.RGBA{255, 255, 0, 255},
+	ColorScrollbarCursorHover:  color.RGBA{255, 255, 128, 255},
+	ColorScrollbarCursorActive: color.RGBA{255, 255, 255, 255},
+	ColorTabHeader:             color.RGBA{40, 40, 40, 255},
+}
+
+var themes = []Theme{
+	{
+		Name:    "red",
+		Nucular: style.RedTheme,
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+			Fg:         color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+			ContrastBg: color.NRGBA{R: 63, G: 81, B: 181, A: 255},
+			ContrastFg: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	},
+	{
+		Name:    "dark",
+		Nucular: style.DarkTheme,
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 30, G: 30, B: 30, A: 255},
+			Fg:         color.NRGBA{R: 230, G: 230, B: 230, A: 255},
+			ContrastBg: color.NRGBA{R: 0, G: 100, B: 200, A: 255},
+			ContrastFg: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	},
+	{
+		Name:    "light",
+		Nucular: style.WhiteTheme,
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 240, G: 240, B: 240, A: 255},
+			Fg:         color.NRGBA{R: 20, G: 20, B: 20, A: 255},
+			ContrastBg: color.NRGBA{R: 0, G: 90, B: 200, A: 255},
+			ContrastFg: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	},
+	{
+		Name:  "high-contrast",
+		Table: &highContrastTable,
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+			Fg:         color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+			ContrastBg: color.NRGBA{R: 255, G: 255, B: 0, A: 255},
+			ContrastFg: color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+		},
+	},
+}
+
+// theme is the active palette
+var theme = &themes[0]
+
+// themeNames lists the available themes for help text
+func themeNames() string {
+	names := make([]string, len(themes))
+	for i := range themes {
+		names[i] = themes[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// setTheme activates the theme with the given name
+func setTheme(name string) error {
+	for i := range themes {
+		if themes[i].Name == name {
+			theme = &themes[i]
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q (available: %s)", name, themeNames())
+}
+
+// cycleTheme switches to the next theme
+func cycleTheme() {
+	for i := range themes {
+		if theme == &themes[i] {
+			theme = &themes[(i+1)%len(themes)]
+			return
+		}
+	}
+}
+
+// nucularStyle builds the nucular style for the active theme
+func nucularStyle(scaling float64) *style.Style {
+	if theme.Table != nil {
+		return style.FromTable(*theme.Table, scaling)
+	}
+	return style.FromTheme(theme.Nucular, scaling)
+}
+
+// applyGioTheme copies the active palette into the material theme and fills
+// the window background with it. It is called at the start of every frame so
+// runtime theme switches from either window take effect on the next redraw.
+func applyGioTheme(gtx layout.Context) {
+	cameraApp.Theme.Palette = theme.Palette
+	paint.Fill(gtx.Ops, theme.Palette.Bg)
+}
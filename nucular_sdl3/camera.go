@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	"github.com/Zyko0/go-sdl3/bin/binsdl"
 
@@ -11,6 +12,7 @@ import (
 	"image/jpeg"
 	"io"
 	"log"
+	"math"
 	"os/exec"
 	"path/filepath"
 	"regexp"
@@ -23,7 +25,6 @@ import (
 
 	"github.com/Zyko0/go-sdl3/sdl"
 	"github.com/aarzilli/nucular"
-	"github.com/aarzilli/nucular/style"
 	"github.com/vladimirvivien/go4vl/device"
 	"github.com/vladimirvivien/go4vl/v4l2"
 )
@@ -54,14 +55,29 @@ type CameraApp struct {
 	StatusText         string
 	SelectedCam        int
 	ShowCamera         bool
+	GridMode           bool
 	Renderer           *sdl.Renderer
 	Window             *sdl.Window
 	PlaceholderTexture *sdl.Texture
+	// ViewRects holds the on-screen rectangle of every camera drawn in the
+	// last frame, indexed like Cameras, for mouse hit-testing.
+	ViewRects []sdl.FRect
 }
 
+const (
+	thumbnailStripHeight = 90
+	thumbnailPadding     = 6
+)
+
 var app CameraApp
 
 func main() {
+	themeName := flag.String("theme", themes[0].Name, "UI theme: "+themeNames())
+	flag.Parse()
+	if err := setTheme(*themeName); err != nil {
+		log.Fatal(err)
+	}
+
 	defer binsdl.Load().Unload()
 
 	// Initialize SDL for camera display
@@ -95,7 +111,7 @@ func main() {
 	// Start nucular control window in separate goroutine
 	go func() {
 		wnd := nucular.NewMasterWindow(0, "Camera Controls", updatefn)
-		wnd.SetStyle(style.FromTheme(style.RedTheme, 2.0))
+		wnd.SetStyle(nucularStyle(2.0))
 		wnd.Main()
 	}()
 
@@ -107,7 +123,12 @@ func main() {
 			case sdl.EVENT_QUIT:
 				return sdl.EndLoop
 			case sdl.EVENT_KEY_DOWN:
-				println("Key pressed:")
+				handleKeyPress(event.KeyboardEvent().Scancode)
+			case sdl.EVENT_MOUSE_BUTTON_DOWN:
+				e := event.MouseButtonEvent()
+				if e.Button == uint8(sdl.BUTTON_LEFT) {
+					handleMouseClick(e.X, e.Y, e.Clicks)
+				}
 			}
 		}
 
@@ -152,6 +173,19 @@ func updatefn(w *nucular.Window) {
 		}
 	}
 
+	// Grid/single view toggle
+	w.Row(30).Dynamic(1)
+	if w.ButtonText(fmt.Sprintf("Grid View: %s", map[bool]string{true: "ON", false: "OFF"}[app.GridMode])) {
+		app.GridMode = !app.GridMode
+	}
+
+	// Theme switch, applied to both windows
+	w.Row(30).Dynamic(1)
+	if w.ButtonText(fmt.Sprintf("Theme: %s", theme.Name)) {
+		cycleTheme()
+		w.Master().SetStyle(nucularStyle(2.0))
+	}
+
 	// Camera selection
 	if len(app.Cameras) > 0 {
 		w.Row(30).Dynamic(1)
@@ -189,7 +223,8 @@ func updatefn(w *nucular.Window) {
 	w.Row(30).Dynamic(1)
 	w.Label("Available Cameras:", "LC")
 
-	for i, camera := range app.Cameras {
+	for i := range app.Cameras {
+		camera := &app.Cameras[i]
 		w.Row(25).Dynamic(1)
 		status := "Inactive"
 		if camera.Active {
@@ -200,47 +235,160 @@ func updatefn(w *nucular.Window) {
 }
 
 func renderCamera() {
-	app.Renderer.SetDrawColor(0, 0, 0, 255)
+	bg := theme.Background
+	app.Renderer.SetDrawColor(bg.R, bg.G, bg.B, bg.A)
 	app.Renderer.Clear()
 
-	if app.SelectedCam < len(app.Cameras) && app.ShowCamera {
-		camera := &app.Cameras[app.SelectedCam]
+	if len(app.ViewRects) != len(app.Cameras) {
+		app.ViewRects = make([]sdl.FRect, len(app.Cameras))
+	}
+	for i := range app.ViewRects {
+		app.ViewRects[i] = sdl.FRect{}
+	}
+
+	if app.ShowCamera && len(app.Cameras) > 0 {
+		w, h, _ := app.Window.Size()
+		if app.GridMode {
+			renderGrid(float32(w), float32(h))
+		} else {
+			renderSingleWithThumbnails(float32(w), float32(h))
+		}
+	}
 
-		if camera.Active && camera.Texture != nil {
-			camera.FrameMutex.Lock()
+	app.Renderer.Present()
+}
 
-			// Get window size
-			w, h, _ := app.Window.Size()
-
-			// Calculate aspect ratio preserving scaling
-			cameraAspect := float32(camera.Width) / float32(camera.Height)
-			windowAspect := float32(w) / float32(h)
-
-			var dstRect sdl.FRect
-			if cameraAspect > windowAspect {
-				// Camera is wider, fit to width
-				dstRect.W = float32(w)
-				dstRect.H = float32(w) / cameraAspect
-				dstRect.X = 0
-				dstRect.Y = (float32(h) - dstRect.H) / 2
-			} else {
-				// Camera is taller, fit to height
-				dstRect.H = float32(h)
-				dstRect.W = float32(h) * cameraAspect
-				dstRect.Y = 0
-				dstRect.X = (float32(w) - dstRect.W) / 2
+// renderSingleWithThumbnails draws the selected camera above a strip of
+// thumbnails for every camera.
+func renderSingleWithThumbnails(w, h float32) {
+	mainArea := sdl.FRect{W: w, H: h}
+	if len(app.Cameras) > 1 {
+		mainArea.H = h - thumbnailStripHeight
+
+		thumbW := (w - thumbnailPadding) / float32(len(app.Cameras))
+		for i := range app.Cameras {
+			cell := sdl.FRect{
+				X: float32(i)*thumbW + thumbnailPadding,
+				Y: mainArea.H + thumbnailPadding,
+				W: thumbW - thumbnailPadding,
+				H: thumbnailStripHeight - 2*thumbnailPadding,
 			}
+			app.ViewRects[i] = cell
+			renderCameraInRect(&app.Cameras[i], cell)
 
-			app.Renderer.RenderTexture(camera.Texture, nil, &dstRect)
-			camera.FrameMutex.Unlock()
-		} else {
-			// Draw "No Signal" text
-			app.Renderer.SetDrawColor(64, 64, 64, 255)
-			app.Renderer.RenderFillRect(&sdl.FRect{X: 0, Y: 0, W: 640, H: 480})
+			if i == app.SelectedCam {
+				c := theme.Selected
+				app.Renderer.SetDrawColor(c.R, c.G, c.B, c.A)
+				app.Renderer.RenderRect(&cell)
+			}
 		}
 	}
 
-	app.Renderer.Present()
+	if app.SelectedCam < len(app.Cameras) {
+		app.ViewRects[app.SelectedCam] = mainArea
+		renderCameraInRect(&app.Cameras[app.SelectedCam], mainArea)
+	}
+}
+
+// renderGrid tiles every camera into a near-square grid filling the window.
+func renderGrid(w, h float32) {
+	n := len(app.Cameras)
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	rows := (n + cols - 1) / cols
+
+	cellW := w / float32(cols)
+	cellH := h / float32(rows)
+	for i := range app.Cameras {
+		cell := sdl.FRect{
+			X: float32(i%cols)*cellW + thumbnailPadding/2,
+			Y: float32(i/cols)*cellH + thumbnailPadding/2,
+			W: cellW - thumbnailPadding,
+			H: cellH - thumbnailPadding,
+		}
+		app.ViewRects[i] = cell
+		renderCameraInRect(&app.Cameras[i], cell)
+
+		if i == app.SelectedCam {
+			c := theme.Selected
+			app.Renderer.SetDrawColor(c.R, c.G, c.B, c.A)
+			app.Renderer.RenderRect(&cell)
+		}
+	}
+}
+
+// renderCameraInRect draws a camera's texture letterboxed into area, or a
+// grey "no signal" block if the camera has nothing to show.
+func renderCameraInRect(camera *CameraInstance, area sdl.FRect) {
+	if !camera.Active || camera.Texture == nil {
+		c := theme.NoSignal
+		app.Renderer.SetDrawColor(c.R, c.G, c.B, c.A)
+		app.Renderer.RenderFillRect(&area)
+		return
+	}
+
+	camera.FrameMutex.Lock()
+	dstRect := fitRect(camera.Width, camera.Height, area)
+	app.Renderer.RenderTexture(camera.Texture, nil, &dstRect)
+	camera.FrameMutex.Unlock()
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of a
+// width x height frame that fits centred inside area.
+func fitRect(width, height int, area sdl.FRect) sdl.FRect {
+	if width <= 0 || height <= 0 || area.H <= 0 {
+		return area
+	}
+
+	cameraAspect := float32(width) / float32(height)
+	areaAspect := area.W / area.H
+
+	var dstRect sdl.FRect
+	if cameraAspect > areaAspect {
+		// Camera is wider, fit to width
+		dstRect.W = area.W
+		dstRect.H = area.W / cameraAspect
+		dstRect.X = area.X
+		dstRect.Y = area.Y + (area.H-dstRect.H)/2
+	} else {
+		// Camera is taller, fit to height
+		dstRect.H = area.H
+		dstRect.W = area.H * cameraAspect
+		dstRect.Y = area.Y
+		dstRect.X = area.X + (area.W-dstRect.W)/2
+	}
+	return dstRect
+}
+
+func handleKeyPress(scancode sdl.Scancode) {
+	switch scancode {
+	case sdl.SCANCODE_G:
+		app.GridMode = !app.GridMode
+	case sdl.SCANCODE_LEFT:
+		if app.SelectedCam > 0 {
+			app.SelectedCam--
+		}
+	case sdl.SCANCODE_RIGHT:
+		if app.SelectedCam < len(app.Cameras)-1 {
+			app.SelectedCam++
+		}
+	}
+}
+
+// handleMouseClick selects the camera under the cursor. Double-clicking a
+// camera in grid mode switches back to the single view on that camera.
+func handleMouseClick(x, y float32, clicks uint8) {
+	for i, r := range app.ViewRects {
+		if r.W == 0 || r.H == 0 {
+			continue
+		}
+		if x >= r.X && x <= r.X+r.W && y >= r.Y && y <= r.Y+r.H {
+			app.SelectedCam = i
+			if app.GridMode && clicks >= 2 {
+				app.GridMode = false
+			}
+			return
+		}
+	}
 }
 
 // [Include all the camera detection and management functions from your original example]
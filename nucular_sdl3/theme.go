@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/aarzilli/nucular/style"
+)
+
+// Theme is a named palette for the nucular controls and the SDL camera window
+type Theme struct {
+	Name string
+	// Nucular is the built-in nucular theme, used when Table is nil
+	Nucular style.Theme
+	Table   *style.ColorTable
+
+	Background color.RGBA
+	Selected   color.RGBA
+	NoSignal   color.RGBA
+}
+
+// highContrastTable is a black/white/yellow nucular palette readable in
+// sunlight
+var highContrastTable = style.ColorTable{
+	ColorText:                  color.RGBA{255, 255, 255, 255},
+	ColorWindow:                color.RGBA{0, 0, 0, 255},
+	ColorHeader:                color.RGBA{0, 0, 0, 255},
+	ColorHeaderFocused:         color.RGBA{40, 40, 40, 255},
+	ColorBorder:                color.RGBA{255, 255, 255, 255},
+	ColorButton:                color.RGBA{0, 0, 0, 255},
+	ColorButtonHover:           color.RGBA{90, 90, 0, 255},
+	ColorButtonActive:          color.RGBA{160, 160, 0, 255},
+	ColorToggle:                color.RGBA{40, 40, 40, 255},
+	ColorToggleHover:           color.RGBA{90, 90, 0, 255},
+	ColorToggleCursor:          color.RGBA{255, 255, 0, 255},
+	ColorSelect:                color.RGBA{40, 40, 40, 255},
+	ColorSelectActive:          color.RGBA{160, 160, 0, 255},
+	ColorSlider:                color.RGBA{40, 40, 40, 255},
+	ColorSliderCursor:          color.RGBA{255, 255, 0, 255},
+	ColorSliderCursorHover:     color.RGBA{255, 255, 128, 255},
+	ColorSliderCursorActive:    color.RGBA{255, 255, 255, 255},
+	ColorProperty:              color.RGBA{40, 40, 40, 255},
+	ColorEdit:                  color.RGBA{0, 0, 0, 255},
+	ColorEditCursor:            color.RGBA{255, 255, 0, 255},
+	ColorCombo:                 color.RGBA{0, 0, 0, 255},
+	ColorChart:                 color.RGBA{40, 40, 40, 255},
+	ColorChartColor:            color.RGBA{255, 255, 0, 255},
+	ColorChartColorHighlight:   color.RGBA{255, 255, 255, 255},
+	ColorScrollbar:             color.RGBA{40, 40, 40, 255},
+	ColorScrollbarCursor:       color.RGBA{255, 255, 0, 255},
+	ColorScrollbarCursorHover:  color.RGBA{255, 255, 128, 255},
+	ColorScrollbarCursorActive: color.RGBA{255, 255, 255, 255},
+	ColorTabHeader:             color.RGBA{40, 40, 40, 255},
+}
+
+var themes = []Theme{
+	{
+		Name:       "red",
+		Nucular:    style.RedTheme,
+		Background: color.RGBA{0, 0, 0, 255},
+		Selected:   color.RGBA{0, 150, 255, 255},
+		NoSignal:   color.RGBA{64, 64, 64, 255},
+	},
+	{
+		Name:       "dark",
+		Nucular:    style.DarkTheme,
+		Background: color.RGBA{0, 0, 0, 255},
+		Selected:   color.RGBA{0, 150, 255, 255},
+		NoSignal:   color.RGBA{64, 64, 64, 255},
+	},
+	{
+		Name:       "light",
+		Nucular:    style.WhiteTheme,
+		Background: color.RGBA{235, 235, 235, 255},
+		Selected:   color.RGBA{0, 90, 200, 255},
+		NoSignal:   color.RGBA{190, 190, 190, 255},
+	},
+	{
+		Name:       "high-contrast",
+		Table:      &highContrastTable,
+		Background: color.RGBA{0, 0, 0, 255},
+		Selected:   color.RGBA{255, 255, 0, 255},
+		NoSignal:   color.RGBA{40, 40, 40, 255},
+	},
+}
+
+// theme is the active palette
+var theme = &themes[0]
+
+// themeNames lists the available themes for help text
+func themeNames() string {
+	names := make([]string, len(themes))
+	for i := range themes {
+		names[i] = themes[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// setTheme activates the theme with the given name
+func setTheme(name string) error {
+	for i := range themes {
+		if themes[i].Name == name {
+			theme = &themes[i]
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q (available: %s)", name, themeNames())
+}
+
+// cycleTheme switches to the next theme
+func cycleTheme() {
+	for i := range themes {
+		if theme == &themes[i] {
+			theme = &themes[(i+1)%len(themes)]
+			return
+		}
+	}
+}
+
+// nucularStyle builds the nucular style for the active theme
+func nucularStyle(scaling float64) *style.Style {
+	if theme.Table != nil {
+		return style.FromTable(*theme.Table, scaling)
+	}
+	return style.FromTheme(theme.Nucular, scaling)
+}
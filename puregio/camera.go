@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"image"
 	"log"
@@ -15,7 +16,6 @@ import (
 	"time"
 
 	"gioui.org/app"
-	"gioui.org/f32"
 
 	"gioui.org/layout"
 	"gioui.org/op"
@@ -62,9 +62,20 @@ type CameraApp struct {
 	ShowCamera  bool
 	Theme       *material.Theme
 
+	// Panes are the main panes, the second one only shown in the dual view;
+	// Focus is the pane the controls act on
+	Panes    [2]Pane
+	Focus    int
+	DualView bool
+
 	// UI widgets
 	IncrementBtn    widget.Clickable
 	ToggleCameraBtn widget.Clickable
+	ThemeBtn        widget.Clickable
+	DualViewBtn     widget.Clickable
+	ZoomInBtn       widget.Clickable
+	ZoomOutBtn      widget.Clickable
+	CenterMarkBtn   widget.Clickable
 	CameraButtons   []widget.Clickable
 	Count           int
 
@@ -83,6 +94,12 @@ type CameraApp struct {
 var cameraApp CameraApp
 
 func main() {
+	themeName := flag.String("theme", themes[0].Name, "UI theme: "+themeNames())
+	flag.Parse()
+	if err := setTheme(*themeName); err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("Starting optimized pure Gio camera app...")
 
 	// Initialize cameras
@@ -114,10 +131,13 @@ func runGioWindow() {
 		for range ticker.C {
 			updateCameraFramesFromProcessed()
 
-			if cameraApp.ShowCamera && cameraApp.SelectedCam < len(cameraApp.Cameras) {
-				camera := &cameraApp.Cameras[cameraApp.SelectedCam]
-				if atomic.LoadInt32(&camera.TextureUpdated) == 1 {
+			if !cameraApp.ShowCamera {
+				continue
+			}
+			for i := range cameraApp.Cameras {
+				if shownInPane(i) && atomic.LoadInt32(&cameraApp.Cameras[i].TextureUpdated) == 1 {
 					gioWindow.Invalidate()
+					break
 				}
 			}
 		}
@@ -135,11 +155,13 @@ func runGioWindow() {
 
 			// Handle UI interactions
 			handleUIEvents(gtx)
+			applyGioTheme(gtx)
 
 			// Render the main layout
 			renderMainLayout(gtx)
 
 			e.Frame(gtx.Ops)
+			endLabelFrame()
 
 			// Performance tracking
 			atomic.AddUint64(&cameraApp.FrameCounter, 1)
@@ -163,13 +185,28 @@ func handleUIEvents(gtx layout.Context) {
 		log.Printf("Camera display toggled: %v", cameraApp.ShowCamera)
 	}
 
+	if cameraApp.ThemeBtn.Clicked(gtx) {
+		cycleTheme()
+	}
+
+	if cameraApp.DualViewBtn.Clicked(gtx) {
+		toggleDualView()
+	}
+	if cameraApp.ZoomInBtn.Clicked(gtx) {
+		zoomPane(1.25)
+	}
+	if cameraApp.ZoomOutBtn.Clicked(gtx) {
+		zoomPane(0.8)
+	}
+	if cameraApp.CenterMarkBtn.Clicked(gtx) {
+		focusedPane().CenterMark = !focusedPane().CenterMark
+	}
+	handlePaneEvents(gtx)
+
 	// Handle camera selection buttons
 	for i := range cameraApp.CameraButtons {
 		if cameraApp.CameraButtons[i].Clicked(gtx) {
-			if i != cameraApp.SelectedCam {
-				cameraApp.SelectedCam = i
-				log.Printf("Selected camera: %d", i)
-			}
+			selectCamera(i)
 		}
 	}
 }
@@ -196,7 +233,7 @@ func renderControlPanel(gtx layout.Context) layout.Dimensions {
 		}.Layout(gtx,
 			// Status
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				return material.Body2(cameraApp.Theme, cameraApp.StatusText).Layout(gtx)
+				return layoutLabel(gtx, material.Body2(cameraApp.Theme, cameraApp.StatusText))
 			}),
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				return renderAppInfo(gtx)
@@ -206,7 +243,7 @@ func renderControlPanel(gtx layout.Context) layout.Dimensions {
 
 			// Camera controls header
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-				return material.H6(cameraApp.Theme, "Camera Controls").Layout(gtx)
+				return layoutLabel(gtx, material.H6(cameraApp.Theme, "Camera Controls"))
 			}),
 
 			layout.Rigid(layout.Spacer{Height: unit.Dp(10)}.Layout),
@@ -220,12 +257,49 @@ func renderControlPanel(gtx layout.Context) layout.Dimensions {
 				return material.Button(cameraApp.Theme, &cameraApp.ToggleCameraBtn, text).Layout(gtx)
 			}),
 
+			layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+
+			// Theme switch
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return material.Button(cameraApp.Theme, &cameraApp.ThemeBtn, fmt.Sprintf("Theme: %s", theme.Name)).Layout(gtx)
+			}),
+
+			layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+
+			// Dual view
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				text := "Dual View: OFF"
+				if cameraApp.DualView {
+					text = "Dual View: ON"
+				}
+				return material.Button(cameraApp.Theme, &cameraApp.DualViewBtn, text).Layout(gtx)
+			}),
+
+			layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
+
+			// Zoom and overlays of the focused pane
+			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
+				return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
+					layout.Flexed(1, material.Button(cameraApp.Theme, &cameraApp.ZoomOutBtn, "Zoom -").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(5)}.Layout),
+					layout.Flexed(1, material.Button(cameraApp.Theme, &cameraApp.ZoomInBtn, "Zoom +").Layout),
+					layout.Rigid(layout.Spacer{Width: unit.Dp(5)}.Layout),
+					layout.Flexed(1, func(gtx layout.Context) layout.Dimensions {
+						btn := material.Button(cameraApp.Theme, &cameraApp.CenterMarkBtn, "Center")
+						if focusedPane().CenterMark {
+							btn.Background = cameraApp.Theme.Palette.ContrastBg
+						}
+						return btn.Layout(gtx)
+					}),
+				)
+			}),
+
 			layout.Rigid(layout.Spacer{Height: unit.Dp(15)}.Layout),
 
 			// Camera selection
 			layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 				if len(cameraApp.Cameras) == 0 {
-					return material.Body2(cameraApp.Theme, "No cameras found").Layout(gtx)
+					return layoutLabel(gtx, material.Body2(cameraApp.Theme, "No cameras found"))
 				}
 
 				return layout.Flex{
@@ -233,7 +307,7 @@ func renderControlPanel(gtx layout.Context) layout.Dimensions {
 				}.Layout(gtx,
 					// Camera selection header
 					layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-						return material.Body2(cameraApp.Theme, "Select Camera:").Layout(gtx)
+						return layoutLabel(gtx, material.Body2(cameraApp.Theme, "Select Camera:"))
 					}),
 
 					layout.Rigid(layout.Spacer{Height: unit.Dp(5)}.Layout),
@@ -289,22 +363,23 @@ func renderCameraButtons(gtx layout.Context) layout.Dimensions {
 func renderCameraInfo(gtx layout.Context, camera *CameraInstance) layout.Dimensions {
 	return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return material.Caption(cameraApp.Theme, fmt.Sprintf("Camera: %s", camera.Info.Name)).Layout(gtx)
+			return layoutLabel(gtx, material.Caption(cameraApp.Theme, fmt.Sprintf("Camera: %s", camera.Info.Name)))
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			fps := atomic.LoadInt32(&camera.FPS)
-			return material.Caption(cameraApp.Theme, fmt.Sprintf("FPS: %d", fps)).Layout(gtx)
+			return layoutLabel(gtx, material.Caption(cameraApp.Theme, fmt.Sprintf("FPS: %d", fps)))
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			droppedFrames := atomic.LoadUint64(&camera.DroppedFrames)
-			return material.Caption(cameraApp.Theme, fmt.Sprintf("Dropped: %d", droppedFrames)).Layout(gtx)
+			return layoutLabel(gtx, material.Caption(cameraApp.Theme, fmt.Sprintf("Dropped: %d", droppedFrames)))
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			if !camera.LastFrameTime.IsZero() {
 				timeSince := time.Since(camera.LastFrameTime)
+				// Changes every frame, so not worth caching
 				return material.Caption(cameraApp.Theme, fmt.Sprintf("Last frame: %v ago", timeSince.Truncate(time.Millisecond))).Layout(gtx)
 			}
-			return material.Caption(cameraApp.Theme, "No frames yet").Layout(gtx)
+			return layoutLabel(gtx, material.Caption(cameraApp.Theme, "No frames yet"))
 		}),
 		// layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 		// 	return material.Caption(cameraApp.Theme, fmt.Sprintf("Dropped: %d", droppedFrames)).Layout(gtx)
@@ -322,16 +397,12 @@ func renderCameraPanel(gtx layout.Context) layout.Dimensions {
 			return renderPlaceholder(gtx, "Invalid Camera Selection")
 		}
 
-		return renderCameraWithGio(gtx)
+		return renderPanes(gtx)
 	})
 }
 
-func renderCameraWithGio(gtx layout.Context) layout.Dimensions {
-	if cameraApp.SelectedCam >= len(cameraApp.Cameras) {
-		return renderPlaceholder(gtx, "Camera Index Out of Range")
-	}
-
-	camera := &cameraApp.Cameras[cameraApp.SelectedCam]
+func renderCameraWithGio(gtx layout.Context, pane *Pane) layout.Dimensions {
+	camera := &cameraApp.Cameras[pane.Camera]
 
 	if !camera.Active {
 		return renderPlaceholder(gtx, "Camera Not Active")
@@ -380,22 +451,19 @@ func renderCameraWithGio(gtx layout.Context) layout.Dimensions {
 		scaledWidth := int(float32(imgSize.X) * scale)
 		scaledHeight := int(float32(imgSize.Y) * scale)
 
-		// Apply scaling transformation
-		defer op.Affine(f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(scale, scale))).Push(gtx.Ops).Pop()
-
 		// Render the image
-		camera.TextureOp.Add(gtx.Ops)
-		paint.PaintOp{}.Add(gtx.Ops)
+		size := image.Pt(scaledWidth, scaledHeight)
+		renderZoomedFrame(gtx, camera.TextureOp, pane, size, scale)
 
 		return layout.Dimensions{
-			Size: image.Pt(scaledWidth, scaledHeight),
+			Size: size,
 		}
 	})
 }
 
 func renderPlaceholder(gtx layout.Context, message string) layout.Dimensions {
 	return layout.Center.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
-		return material.H4(cameraApp.Theme, message).Layout(gtx)
+		return layoutLabel(gtx, material.H4(cameraApp.Theme, message))
 	})
 }
 
@@ -714,13 +782,13 @@ func renderAppInfo(gtx layout.Context) layout.Dimensions {
 	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx,
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			appFPS := atomic.LoadInt32(&cameraApp.AppFPS)
-			return material.Caption(cameraApp.Theme, fmt.Sprintf("App FPS: %d", appFPS)).Layout(gtx)
+			return layoutLabel(gtx, material.Caption(cameraApp.Theme, fmt.Sprintf("App FPS: %d", appFPS)))
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
 			return layout.Spacer{Width: unit.Dp(20)}.Layout(gtx)
 		}),
 		layout.Rigid(func(gtx layout.Context) layout.Dimensions {
-			return material.Caption(cameraApp.Theme, cameraApp.StatusText).Layout(gtx)
+			return layoutLabel(gtx, material.Caption(cameraApp.Theme, cameraApp.StatusText))
 		}),
 	)
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"image"
+	"log"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+)
+
+// With the dual view the camera panel is split into two panes side by side,
+// each showing its own camera with its own zoom and center mark, for two
+// operators sharing a screen. The camera buttons and the zoom and overlay
+// controls act on the pane with the focus; clicking a pane gives it the
+// focus. SelectedCam is always the camera of the focused pane.
+
+// maxPaneZoom is the largest zoom of a pane
+const maxPaneZoom = 8
+
+// Pane is what a main pane shows
+type Pane struct {
+	Camera int
+	// Zoom magnifies the center of the frame, 1 for the whole frame
+	Zoom       float32
+	CenterMark bool
+	Click      widget.Clickable
+}
+
+// focusedPane is the pane the controls act on
+func focusedPane() *Pane {
+	return &cameraApp.Panes[cameraApp.Focus]
+}
+
+// selectCamera shows a camera in the focused pane
+func selectCamera(i int) {
+	if i == cameraApp.SelectedCam {
+		return
+	}
+	cameraApp.SelectedCam = i
+	focusedPane().Camera = i
+	focusedPane().Zoom = 1
+	log.Printf("Selected camera: %d", i)
+}
+
+// setFocus gives a pane the focus
+func setFocus(pane int) {
+	cameraApp.Focus = pane
+	cameraApp.SelectedCam = focusedPane().Camera
+}
+
+// toggleDualView splits the camera panel, the new pane showing the camera
+// after the selected one, or joins it again, keeping the left pane
+func toggleDualView() {
+	if cameraApp.DualView {
+		cameraApp.DualView = false
+		setFocus(0)
+		return
+	}
+	cameraApp.Panes[0].Camera = cameraApp.SelectedCam
+	camera := 0
+	if len(cameraApp.Cameras) > 0 {
+		camera = (cameraApp.SelectedCam + 1) % len(cameraApp.Cameras)
+	}
+	cameraApp.Panes[1] = Pane{Camera: camera, Zoom: 1}
+	cameraApp.DualView = true
+}
+
+// zoomPane zooms the focused pane in or out by factor
+func zoomPane(factor float32) {
+	pane := focusedPane()
+	pane.Zoom = min(max(pane.Zoom*factor, 1), maxPaneZoom)
+}
+
+// shownInPane reports whether a camera is shown in a pane
+func shownInPane(i int) bool {
+	return i == cameraApp.Panes[0].Camera || cameraApp.DualView && i == cameraApp.Panes[1].Camera
+}
+
+// handlePaneEvents gives the focus to a clicked pane
+func handlePaneEvents(gtx layout.Context) {
+	for i := range cameraApp.Panes {
+		if cameraApp.Panes[i].Click.Clicked(gtx) && cameraApp.DualView {
+			setFocus(i)
+		}
+	}
+}
+
+// renderPanes lays out the single pane, or both panes side by side with the
+// focused one outlined
+func renderPanes(gtx layout.Context) layout.Dimensions {
+	if !cameraApp.DualView {
+		return renderPane(gtx, 0)
+	}
+	pane := func(i int) layout.FlexChild {
+		return layout.Flexed(0.5, func(gtx layout.Context) layout.Dimensions {
+			return layout.UniformInset(unit.Dp(2)).Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+				if i != cameraApp.Focus {
+					return renderPane(gtx, i)
+				}
+				border := widget.Border{Color: cameraApp.Theme.Palette.ContrastBg, Width: unit.Dp(2)}
+				return border.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+					return renderPane(gtx, i)
+				})
+			})
+		})
+	}
+	return layout.Flex{Axis: layout.Horizontal}.Layout(gtx, pane(0), pane(1))
+}
+
+// renderPane draws a pane's camera; clicking it gives the pane the focus
+func renderPane(gtx layout.Context, i int) layout.Dimensions {
+	pane := &cameraApp.Panes[i]
+	return pane.Click.Layout(gtx, func(gtx layout.Context) layout.Dimensions {
+		gtx.Constraints.Min = gtx.Constraints.Max
+		if pane.Camera >= len(cameraApp.Cameras) {
+			return renderPlaceholder(gtx, "Camera Index Out of Range")
+		}
+		return renderCameraWithGio(gtx, pane)
+	})
+}
+
+// renderZoomedFrame draws a camera texture scaled to size, magnified about
+// its center by the pane's zoom, with the pane's overlays
+func renderZoomedFrame(gtx layout.Context, texture paint.ImageOp, pane *Pane, size image.Point, scale float32) {
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+
+	zoom := max(pane.Zoom, 1)
+	offset := f32.Pt(float32(size.X)*(1-zoom)/2, float32(size.Y)*(1-zoom)/2)
+	transform := op.Affine(f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(scale*zoom, scale*zoom)).Offset(offset)).Push(gtx.Ops)
+	texture.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	transform.Pop()
+
+	if pane.CenterMark {
+		center := size.Div(2)
+		arm := min(size.X, size.Y) / 20
+		width := max(gtx.Dp(unit.Dp(1)), 1)
+		color := cameraApp.Theme.Palette.ContrastBg
+		paint.FillShape(gtx.Ops, color, clip.Rect{Min: image.Pt(center.X-arm, center.Y-width/2), Max: image.Pt(center.X+arm, center.Y+width-width/2)}.Op())
+		paint.FillShape(gtx.Ops, color, clip.Rect{Min: image.Pt(center.X-width/2, center.Y-arm), Max: image.Pt(center.X+width-width/2, center.Y+arm)}.Op())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+// Labels are shaped and recorded once into operation lists of their own and
+// replayed on later frames, so static labels cost nothing per frame. A label
+// whose text changes, like the FPS counter, gets an entry per text; entries
+// that were not drawn for a while are dropped.
+
+// labelCacheKeep is how many frames an unused label stays cached
+const labelCacheKeep = 120
+
+// labelKey identifies a label by its text, style and the space it was laid
+// out in
+type labelKey struct {
+	style       material.LabelStyle
+	constraints layout.Constraints
+	metric      unit.Metric
+}
+
+// cachedLabel is a recorded label
+type cachedLabel struct {
+	ops      op.Ops
+	call     op.CallOp
+	dims     layout.Dimensions
+	lastUsed uint64
+}
+
+// labelCache holds the recorded labels; frame counts the frames drawn
+var labelCache = struct {
+	entries map[labelKey]*cachedLabel
+	frame   uint64
+}{entries: map[labelKey]*cachedLabel{}}
+
+// layoutLabel lays out a label, replaying its recording if it was drawn
+// before with the same text and style in the same space
+func layoutLabel(gtx layout.Context, label material.LabelStyle) layout.Dimensions {
+	key := labelKey{style: label, constraints: gtx.Constraints, metric: gtx.Metric}
+	entry, ok := labelCache.entries[key]
+	if !ok {
+		entry = new(cachedLabel)
+		recording := gtx
+		recording.Ops = &entry.ops
+		macro := op.Record(&entry.ops)
+		entry.dims = label.Layout(recording)
+		entry.call = macro.Stop()
+		labelCache.entries[key] = entry
+	}
+	entry.lastUsed = labelCache.frame
+	entry.call.Add(gtx.Ops)
+	return entry.dims
+}
+
+// endLabelFrame counts a drawn frame and now and then drops the labels that
+// were not drawn in the last labelCacheKeep frames
+func endLabelFrame() {
+	labelCache.frame++
+	if labelCache.frame%labelCacheKeep != 0 {
+		return
+	}
+	for key, entry := range labelCache.entries {
+		if labelCache.frame-entry.lastUsed > labelCacheKeep {
+			delete(labelCache.entries, key)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/widget/material"
+)
+
+// Theme is a named palette for the Gio material theme
+type Theme struct {
+	Name    string
+	Palette material.Palette
+}
+
+var themes = []Theme{
+	{
+		// Gio's default material palette
+		Name: "light",
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+			Fg:         color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+			ContrastBg: color.NRGBA{R: 63, G: 81, B: 181, A: 255},
+			ContrastFg: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	},
+	{
+		Name: "dark",
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 30, G: 30, B: 30, A: 255},
+			Fg:         color.NRGBA{R: 230, G: 230, B: 230, A: 255},
+			ContrastBg: color.NRGBA{R: 0, G: 100, B: 200, A: 255},
+			ContrastFg: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	},
+	{
+		// Black and white with yellow highlights, readable in sunlight
+		Name: "high-contrast",
+		Palette: material.Palette{
+			Bg:         color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+			Fg:         color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+			ContrastBg: color.NRGBA{R: 255, G: 255, B: 0, A: 255},
+			ContrastFg: color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+		},
+	},
+}
+
+// theme is the active palette
+var theme = &themes[0]
+
+// themeNames lists the available themes for help text
+func themeNames() string {
+	names := make([]string, len(themes))
+	for i := range themes {
+		names[i] = themes[i].Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// setTheme activates the theme with the given name
+func setTheme(name string) error {
+	for i := range themes {
+		if themes[i].Name == name {
+			theme = &themes[i]
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown theme %q (available: %s)", name, themeNames())
+}
+
+// cycleTheme switches to the next theme
+func cycleTheme() {
+	for i := range themes {
+		if theme == &themes[i] {
+			theme = &themes[(i+1)%len(themes)]
+			return
+		}
+	}
+}
+
+// applyGioTheme copies the active palette into the material theme and fills
+// the window background with it
+func applyGioTheme(gtx layout.Context) {
+	cameraApp.Theme.Palette = theme.Palette
+	paint.Fill(gtx.Ops, theme.Palette.Bg)
+}
@@ -23,8 +23,17 @@ type UIButton struct {
 	isHovered   bool
 	isPressed   bool
 	onClick     func()
+
+	// Size and text scale at a UI scale of 1; Layout derives Width, Height
+	// and TextScale from these
+	baseWidth     float32
+	baseHeight    float32
+	baseTextScale float32
 }
 
+// uiVertexFloats is the number of floats per UI vertex: x, y, r, g, b, a
+const uiVertexFloats = 6
+
 // UIManager handles all UI elements and rendering
 type UIManager struct {
 	font         *glfont.Font
@@ -35,6 +44,17 @@ type UIManager struct {
 	cursorX      float64
 	cursorY      float64
 	mousePressed bool
+	scale        float32
+
+	// Persistent geometry for all UI rectangles, rebuilt into vertices and
+	// uploaded once per frame so the whole UI is a single draw call.
+	vao               uint32
+	vbo               uint32
+	vboCapacity       int
+	vertices          []float32
+	projectionUniform int32
+	positionAttrib    uint32
+	colorAttrib       uint32
 }
 
 // NewUIManager creates a new UI manager
@@ -51,34 +71,96 @@ func NewUIManager(fontPath string, fontSize float32, windowWidth, windowHeight i
 	//	return nil, fmt.Errorf("failed to load font: %v", err)
 	//}
 
-	return &UIManager{
+	ui := &UIManager{
 		//font:         font,
 		buttons:      make([]*UIButton, 0),
 		uiProgram:    uiProgram,
 		windowWidth:  windowWidth,
 		windowHeight: windowHeight,
-	}, nil
+		scale:        1,
+	}
+
+	ui.projectionUniform = gl.GetUniformLocation(uiProgram, gl.Str("projection\x00"))
+	ui.positionAttrib = uint32(gl.GetAttribLocation(uiProgram, gl.Str("position\x00")))
+	ui.colorAttrib = uint32(gl.GetAttribLocation(uiProgram, gl.Str("color\x00")))
+
+	// Create the VAO/VBO once and record the vertex layout in the VAO
+	gl.GenVertexArrays(1, &ui.vao)
+	gl.BindVertexArray(ui.vao)
+	gl.GenBuffers(1, &ui.vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, ui.vbo)
+
+	gl.EnableVertexAttribArray(ui.positionAttrib)
+	gl.VertexAttribPointerWithOffset(ui.positionAttrib, 2, gl.FLOAT, false, uiVertexFloats*4, 0)
+	gl.EnableVertexAttribArray(ui.colorAttrib)
+	gl.VertexAttribPointerWithOffset(ui.colorAttrib, 4, gl.FLOAT, false, uiVertexFloats*4, 2*4)
+
+	gl.BindVertexArray(0)
+
+	return ui, nil
 }
 
 // AddButton adds a new button to the UI
 func (ui *UIManager) AddButton(x, y, width, height float32, label string, onClick func()) *UIButton {
 	button := &UIButton{
-		X:           x,
-		Y:           y,
-		Width:       width,
-		Height:      height,
+		X:           x * ui.scale,
+		Y:           y * ui.scale,
+		Width:       width * ui.scale,
+		Height:      height * ui.scale,
 		Label:       label,
 		NormalColor: mgl32.Vec4{0.2, 0.2, 0.2, 0.8},
 		HoverColor:  mgl32.Vec4{0.3, 0.3, 0.3, 0.8},
 		PressColor:  mgl32.Vec4{0.1, 0.1, 0.1, 0.8},
 		TextColor:   mgl32.Vec3{1.0, 1.0, 1.0},
-		TextScale:   1.0,
+		TextScale:   ui.scale,
 		onClick:     onClick,
+
+		baseWidth:     width,
+		baseHeight:    height,
+		baseTextScale: 1.0,
 	}
 	ui.buttons = append(ui.buttons, button)
 	return button
 }
 
+// Resize updates the window size used for the orthographic projection and
+// re-lays out the buttons.
+func (ui *UIManager) Resize(windowWidth, windowHeight int) {
+	ui.windowWidth = windowWidth
+	ui.windowHeight = windowHeight
+	ui.Layout()
+}
+
+// SetScale changes the UI scale factor, resizing the buttons and their
+// padding, and re-lays out the buttons.
+func (ui *UIManager) SetScale(scale float32) {
+	if scale <= 0 {
+		scale = 1
+	}
+	ui.scale = scale
+	ui.Layout()
+}
+
+// Layout stacks the buttons vertically down the left edge of the window,
+// wrapping into a new column when a button would fall off the bottom.
+func (ui *UIManager) Layout() {
+	padding := 10 * ui.scale
+
+	x, y := padding, padding
+	for _, button := range ui.buttons {
+		button.Width = button.baseWidth * ui.scale
+		button.Height = button.baseHeight * ui.scale
+		button.TextScale = button.baseTextScale * ui.scale
+		if y+button.Height > float32(ui.windowHeight)-padding && y > padding {
+			x += button.Width + padding
+			y = padding
+		}
+		button.X = x
+		button.Y = y
+		y += button.Height + padding
+	}
+}
+
 // Update updates the UI state based on cursor position and mouse buttons
 func (ui *UIManager) Update(window *glfw.Window) {
 	// Get cursor position
@@ -109,18 +191,49 @@ func (ui *UIManager) Update(window *glfw.Window) {
 
 // Draw renders all UI elements
 func (ui *UIManager) Draw() {
+	// Collect the rectangles of all buttons into the vertex batch
+	ui.vertices = ui.vertices[:0]
+	for _, button := range ui.buttons {
+		ui.drawButton(button)
+	}
+
 	// Enable blending for transparent UI elements
 	gl.Enable(gl.BLEND)
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 
-	// Draw all buttons
-	for _, button := range ui.buttons {
-		ui.drawButton(button)
-	}
+	ui.flush()
 
 	gl.Disable(gl.BLEND)
 }
 
+// flush uploads the batched rectangles and draws them in one call.
+func (ui *UIManager) flush() {
+	if len(ui.vertices) == 0 {
+		return
+	}
+
+	gl.UseProgram(ui.uiProgram)
+
+	// Set projection uniform (orthographic projection)
+	projection := mgl32.Ortho(0, float32(ui.windowWidth), float32(ui.windowHeight), 0, -1, 1)
+	gl.UniformMatrix4fv(ui.projectionUniform, 1, false, &projection[0])
+
+	gl.BindVertexArray(ui.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, ui.vbo)
+
+	size := len(ui.vertices) * 4
+	if size > ui.vboCapacity {
+		// Grow the buffer; it is reused as-is on later frames
+		gl.BufferData(gl.ARRAY_BUFFER, size, gl.Ptr(ui.vertices), gl.DYNAMIC_DRAW)
+		ui.vboCapacity = size
+	} else {
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, size, gl.Ptr(ui.vertices))
+	}
+
+	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(ui.vertices)/uiVertexFloats))
+	gl.BindVertexArray(0)
+}
+
 // drawButton renders a single button
 func (ui *UIManager) drawButton(button *UIButton) {
 	// Choose color based on button state
@@ -149,53 +262,18 @@ func (ui *UIManager) drawButton(button *UIButton) {
 	//ui.font.Printf(textX, textY, button.TextScale, button.Label)
 }
 
-// drawRectangle draws a colored rectangle
+// drawRectangle appends a colored rectangle to the current frame's batch
 func (ui *UIManager) drawRectangle(x, y, width, height float32, color mgl32.Vec4) {
-	gl.UseProgram(ui.uiProgram)
-
-	// Set color uniform
-	colorUniform := gl.GetUniformLocation(ui.uiProgram, gl.Str("color\x00"))
-	gl.Uniform4fv(colorUniform, 1, &color[0])
-
-	// Set projection uniform (orthographic projection)
-	projection := mgl32.Ortho(0, float32(ui.windowWidth), float32(ui.windowHeight), 0, -1, 1)
-	projectionUniform := gl.GetUniformLocation(ui.uiProgram, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
-
-	// Create vertices for rectangle
-	vertices := []float32{
-		x, y, // Bottom left
-		x + width, y, // Bottom right
-		x + width, y + height, // Top right
-
-		x, y, // Bottom left
-		x + width, y + height, // Top right
-		x, y + height, // Top left
-	}
-
-	// Create and bind VAO
-	var vao uint32
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-	defer gl.DeleteVertexArrays(1, &vao)
-
-	// Create and bind VBO
-	var vbo uint32
-	gl.GenBuffers(1, &vbo)
-	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
-	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*4, gl.Ptr(vertices), gl.STATIC_DRAW)
-	defer gl.DeleteBuffers(1, &vbo)
-
-	// Set vertex attributes
-	vertAttrib := uint32(gl.GetAttribLocation(ui.uiProgram, gl.Str("position\x00")))
-	gl.EnableVertexAttribArray(vertAttrib)
-	gl.VertexAttribPointerWithOffset(vertAttrib, 2, gl.FLOAT, false, 2*4, 0)
-
-	// Draw rectangle
-	gl.DrawArrays(gl.TRIANGLES, 0, 6)
-
-	// Cleanup
-	gl.DisableVertexAttribArray(vertAttrib)
+	r, g, b, a := color[0], color[1], color[2], color[3]
+	ui.vertices = append(ui.vertices,
+		x, y, r, g, b, a, // Bottom left
+		x+width, y, r, g, b, a, // Bottom right
+		x+width, y+height, r, g, b, a, // Top right
+
+		x, y, r, g, b, a, // Bottom left
+		x+width, y+height, r, g, b, a, // Top right
+		x, y+height, r, g, b, a, // Top left
+	)
 }
 
 // DrawText draws text at the specified position
@@ -214,6 +292,8 @@ func (ui *UIManager) DrawTextFormatted(x, y float32, scale float32, color mgl32.
 func (ui *UIManager) Cleanup() {
 	// Note: glfont doesn't have a Release method, so we just delete the shader program
 	gl.DeleteProgram(ui.uiProgram)
+	gl.DeleteBuffers(1, &ui.vbo)
+	gl.DeleteVertexArrays(1, &ui.vao)
 
 	// The font texture will be automatically cleaned up by OpenGL when the context is destroyed
 }
@@ -245,10 +325,14 @@ func (ui *UIManager) Cleanup() {
 var uiVertexShader = `
 #version 100
 attribute vec2 position;
+attribute vec4 color;
 
 uniform mat4 projection;
 
+varying vec4 fragColor;
+
 void main() {
+    fragColor = color;
     gl_Position = projection * vec4(position, 0.0, 1.0);
 }
 ` + "\x00"
@@ -257,10 +341,10 @@ var uiFragmentShader = `
 #version 100
 precision mediump float;
 
-uniform vec4 color;
+varying vec4 fragColor;
 
 void main() {
-    gl_FragColor = color;
+    gl_FragColor = fragColor;
 }
 ` + "\x00"
 
@@ -0,0 +1,374 @@
+package main
+
+/*
+#cgo LDFLAGS: -lEGL
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+static EGLImageKHR createDMABufImage(EGLDisplay display, const EGLint *attribs) {
+	PFNEGLCREATEIMAGEKHRPROC create = (PFNEGLCREATEIMAGEKHRPROC)eglGetProcAddress("eglCreateImageKHR");
+	if (create == NULL) {
+		return EGL_NO_IMAGE_KHR;
+	}
+	return create(display, EGL_NO_CONTEXT, EGL_LINUX_DMA_BUF_EXT, NULL, attribs);
+}
+
+static void destroyDMABufImage(EGLDisplay display, EGLImageKHR image) {
+	PFNEGLDESTROYIMAGEKHRPROC destroy = (PFNEGLDESTROYIMAGEKHRPROC)eglGetProcAddress("eglDestroyImageKHR");
+	if (destroy != NULL) {
+		destroy(display, image);
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	gl "github.com/go-gl/gl/v3.1/gles2"
+	"github.com/vladimirvivien/go4vl/device"
+	"github.com/vladimirvivien/go4vl/v4l2"
+)
+
+// With -dmabuf, raw-format cameras skip decoding and copying: their V4L2
+// buffers are exported as DMA-BUFs and imported as EGLImages, which the GPU
+// samples directly, converting YUV on the way. Each new frame is drawn into
+// the camera's regular texture, so LUTs, gamma and the layout work as for
+// decoded frames. The window then needs an EGL context and the
+// EGL_EXT_image_dma_buf_import and GL_OES_EGL_image_external extensions;
+// without them, or for cameras that only offer MJPEG, frames are decoded.
+
+const (
+	// dmabufBufferCount is how many V4L2 buffers a zero-copy camera cycles
+	// through; one is held while shown
+	dmabufBufferCount = 4
+
+	// vidiocExpbuf is VIDIOC_EXPBUF, _IOWR('V', 16, struct v4l2_exportbuffer)
+	vidiocExpbuf = 0xc0405610
+
+	// pixelFmtNV12 is V4L2_PIX_FMT_NV12; V4L2 and DRM share the fourcc codes
+	// of the formats imported here
+	pixelFmtNV12 v4l2.FourCCType = 'N' | 'V'<<8 | '1'<<16 | '2'<<24
+)
+
+// v4l2ExportBuffer is struct v4l2_exportbuffer
+type v4l2ExportBuffer struct {
+	Type     uint32
+	Index    uint32
+	Plane    uint32
+	Flags    uint32
+	Fd       int32
+	Reserved [11]uint32
+}
+
+// dmabufStream is a camera streaming into DMA-BUFs shown as EGLImages
+type dmabufStream struct {
+	dev    *device.Device
+	format v4l2.PixFormat
+	// fds, images and textures are per V4L2 buffer
+	fds      []int
+	images   []C.EGLImageKHR
+	textures []uint32
+	// held is the buffer last drawn, kept from the driver until the next
+	// one is drawn so the GPU never reads a buffer being filled; -1 if none
+	held int
+}
+
+// Zero-copy state, set up by initDMABuf
+var (
+	dmabufEnabled bool
+	dmabufDisplay C.EGLDisplay
+	dmabufStreams = map[int]*dmabufStream{}
+
+	dmabufProgram, dmabufVAO, dmabufFBO uint32
+	// dmabufTargets holds the sizes the camera textures were allocated at
+	// for drawing frames into them
+	dmabufTargets = map[uint32]image.Point{}
+)
+
+// initDMABuf checks that the current context can import DMA-BUFs and sets up
+// drawing them into textures
+func initDMABuf() error {
+	dmabufDisplay = C.eglGetCurrentDisplay()
+	if dmabufDisplay == 0 {
+		return errors.New("the window has no EGL context")
+	}
+	eglExtensions := C.GoString(C.eglQueryString(dmabufDisplay, C.EGL_EXTENSIONS))
+	if !strings.Contains(eglExtensions, "EGL_EXT_image_dma_buf_import") {
+		return errors.New("EGL_EXT_image_dma_buf_import is not supported")
+	}
+	if !strings.Contains(gl.GoStr(gl.GetString(gl.EXTENSIONS)), "GL_OES_EGL_image_external") {
+		return errors.New("GL_OES_EGL_image_external is not supported")
+	}
+
+	program, err := newProgram(dmabufVertexShader, dmabufFragmentShader)
+	if err != nil {
+		return err
+	}
+	dmabufProgram = program
+	gl.UseProgram(program)
+	gl.Uniform1i(gl.GetUniformLocation(program, gl.Str("tex\x00")), 0)
+
+	gl.GenVertexArrays(1, &dmabufVAO)
+	gl.BindVertexArray(dmabufVAO)
+	var vbo uint32
+	gl.GenBuffers(1, &vbo)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(dmabufQuad)*4, gl.Ptr(dmabufQuad), gl.STATIC_DRAW)
+	posAttrib := uint32(gl.GetAttribLocation(program, gl.Str("pos\x00")))
+	gl.EnableVertexAttribArray(posAttrib)
+	gl.VertexAttribPointerWithOffset(posAttrib, 2, gl.FLOAT, false, 2*4, 0)
+
+	gl.GenFramebuffers(1, &dmabufFBO)
+	return nil
+}
+
+// openDMABufCamera starts a camera in a raw format with its buffers exported
+// as EGLImages
+func openDMABufCamera(camInfo CameraInfo, width, height int) (*dmabufStream, error) {
+	dev, err := device.Open(camInfo.Path,
+		device.WithIOType(v4l2.IOTypeMMAP),
+		device.WithPixFormat(v4l2.PixFormat{
+			Width:       uint32(width),
+			Height:      uint32(height),
+			PixelFormat: v4l2.PixelFmtYUYV,
+			Field:       v4l2.FieldNone,
+		}),
+		device.WithBufferSize(dmabufBufferCount),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open camera device %s: %w", camInfo.Path, err)
+	}
+	s := &dmabufStream{dev: dev, held: -1}
+	if err := s.start(); err != nil {
+		s.close()
+		dev.Close()
+		return nil, fmt.Errorf("camera %s: %w", camInfo.Path, err)
+	}
+	return s, nil
+}
+
+// start exports the camera's buffers, imports them and starts streaming
+func (s *dmabufStream) start() error {
+	var err error
+	if s.format, err = s.dev.GetPixFormat(); err != nil {
+		return err
+	}
+	if s.format.PixelFormat != v4l2.PixelFmtYUYV && s.format.PixelFormat != pixelFmtNV12 {
+		return fmt.Errorf("delivers %s, not a raw YUV format", v4l2.PixelFormats[s.format.PixelFormat])
+	}
+	req, err := v4l2.InitBuffers(s.dev)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < req.Count; i++ {
+		fd, err := exportBuffer(s.dev, i)
+		if err != nil {
+			return err
+		}
+		s.fds = append(s.fds, fd)
+		img, err := s.importBuffer(fd)
+		if err != nil {
+			return err
+		}
+		s.images = append(s.images, img)
+
+		var texture uint32
+		gl.GenTextures(1, &texture)
+		gl.BindTexture(gl.TEXTURE_EXTERNAL_OES, texture)
+		gl.TexParameteri(gl.TEXTURE_EXTERNAL_OES, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_EXTERNAL_OES, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+		gl.TexParameteri(gl.TEXTURE_EXTERNAL_OES, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+		gl.TexParameteri(gl.TEXTURE_EXTERNAL_OES, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+		gl.EGLImageTargetTexture2DOES(gl.TEXTURE_EXTERNAL_OES, unsafe.Pointer(img))
+		s.textures = append(s.textures, texture)
+
+		if _, err := v4l2.QueueBuffer(s.dev.Fd(), v4l2.IOTypeMMAP, s.dev.BufferType(), i); err != nil {
+			return err
+		}
+	}
+	return v4l2.StreamOn(s.dev)
+}
+
+// exportBuffer exports a V4L2 buffer as a DMA-BUF file descriptor
+func exportBuffer(dev *device.Device, index uint32) (int, error) {
+	exp := v4l2ExportBuffer{
+		Type:  dev.BufferType(),
+		Index: index,
+		Flags: syscall.O_CLOEXEC | syscall.O_RDONLY,
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dev.Fd(), vidiocExpbuf, uintptr(unsafe.Pointer(&exp))); errno != 0 {
+		return -1, fmt.Errorf("failed to export buffer %d: %w", index, errno)
+	}
+	return int(exp.Fd), nil
+}
+
+// importBuffer creates an EGLImage of a DMA-BUF holding a frame in the
+// stream's format
+func (s *dmabufStream) importBuffer(fd int) (C.EGLImageKHR, error) {
+	attribs := []C.EGLint{
+		C.EGL_WIDTH, C.EGLint(s.format.Width),
+		C.EGL_HEIGHT, C.EGLint(s.format.Height),
+		C.EGL_LINUX_DRM_FOURCC_EXT, C.EGLint(s.format.PixelFormat),
+		C.EGL_DMA_BUF_PLANE0_FD_EXT, C.EGLint(fd),
+		C.EGL_DMA_BUF_PLANE0_OFFSET_EXT, 0,
+		C.EGL_DMA_BUF_PLANE0_PITCH_EXT, C.EGLint(s.format.BytesPerLine),
+	}
+	if s.format.PixelFormat == pixelFmtNV12 {
+		// The interleaved chroma follows the luma in the same buffer
+		attribs = append(attribs,
+			C.EGL_DMA_BUF_PLANE1_FD_EXT, C.EGLint(fd),
+			C.EGL_DMA_BUF_PLANE1_OFFSET_EXT, C.EGLint(s.format.BytesPerLine*s.format.Height),
+			C.EGL_DMA_BUF_PLANE1_PITCH_EXT, C.EGLint(s.format.BytesPerLine),
+		)
+	}
+	attribs = append(attribs,
+		C.EGL_YUV_COLOR_SPACE_HINT_EXT, C.EGL_ITU_REC601_EXT,
+		C.EGL_SAMPLE_RANGE_HINT_EXT, C.EGL_YUV_NARROW_RANGE_EXT,
+		C.EGL_NONE,
+	)
+	img := C.createDMABufImage(dmabufDisplay, &attribs[0])
+	if img == nil {
+		return nil, fmt.Errorf("failed to import %s buffer: EGL error %#x",
+			v4l2.PixelFormats[s.format.PixelFormat], int(C.eglGetError()))
+	}
+	return img, nil
+}
+
+// update draws the newest frame of the stream into texture. Frames replaced
+// by a newer one before they were drawn count as dropped.
+func (s *dmabufStream) update(texture uint32, droppedFrames *uint64) bool {
+	newest := -1
+	for {
+		buf, err := v4l2.DequeueBuffer(s.dev.Fd(), v4l2.IOTypeMMAP, s.dev.BufferType())
+		if err != nil {
+			if !errors.Is(err, syscall.EAGAIN) {
+				atomic.AddUint64(droppedFrames, 1)
+			}
+			break
+		}
+		if buf.Flags&v4l2.BufFlagError != 0 {
+			atomic.AddUint64(droppedFrames, 1)
+			s.requeue(int(buf.Index))
+			continue
+		}
+		if newest >= 0 {
+			atomic.AddUint64(droppedFrames, 1)
+			s.requeue(newest)
+		}
+		newest = int(buf.Index)
+	}
+	if newest < 0 {
+		return false
+	}
+
+	s.draw(texture, newest)
+	if s.held >= 0 {
+		s.requeue(s.held)
+	}
+	s.held = newest
+	return true
+}
+
+// draw renders a buffer into texture, allocating it at the frame size
+func (s *dmabufStream) draw(texture uint32, index int) {
+	size := image.Pt(int(s.format.Width), int(s.format.Height))
+	gl.ActiveTexture(gl.TEXTURE0)
+	if dmabufTargets[texture] != size {
+		gl.BindTexture(gl.TEXTURE_2D, texture)
+		gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(size.X), int32(size.Y), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+		dmabufTargets[texture] = size
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, dmabufFBO)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, texture, 0)
+	gl.Viewport(0, 0, int32(size.X), int32(size.Y))
+	gl.Disable(gl.DEPTH_TEST)
+
+	gl.UseProgram(dmabufProgram)
+	gl.BindVertexArray(dmabufVAO)
+	gl.BindTexture(gl.TEXTURE_EXTERNAL_OES, s.textures[index])
+	gl.DrawArrays(gl.TRIANGLE_STRIP, 0, 4)
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.Viewport(0, 0, int32(fbWidth), int32(fbHeight))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// requeue hands a buffer back to the driver
+func (s *dmabufStream) requeue(index int) {
+	v4l2.QueueBuffer(s.dev.Fd(), v4l2.IOTypeMMAP, s.dev.BufferType(), uint32(index))
+}
+
+// close stops streaming and releases the imported buffers; the device itself
+// is closed by the caller
+func (s *dmabufStream) close() {
+	v4l2.StreamOff(s.dev)
+	if len(s.textures) > 0 {
+		gl.DeleteTextures(int32(len(s.textures)), &s.textures[0])
+	}
+	for _, img := range s.images {
+		C.destroyDMABufImage(dmabufDisplay, img)
+	}
+	for _, fd := range s.fds {
+		syscall.Close(fd)
+	}
+	s.textures, s.images, s.fds = nil, nil, nil
+	v4l2.ResetBuffers(s.dev)
+}
+
+// updateCameraTexture updates a camera's texture with its newest frame,
+// from its DMA-BUFs if it streams into them
+func updateCameraTexture(index int, texture uint32) bool {
+	if s := dmabufStreams[index]; s != nil {
+		return s.update(texture, &droppedFrames)
+	}
+	if !updateTextureWithCameraFrame(activeCameras[index], texture, &droppedFrames) {
+		return false
+	}
+	// The upload reallocated the texture
+	delete(dmabufTargets, texture)
+	return true
+}
+
+// dmabufQuad covers the target texture as a triangle strip
+var dmabufQuad = []float32{
+	-1, -1,
+	1, -1,
+	-1, 1,
+	1, 1,
+}
+
+var dmabufVertexShader = `
+#version 100
+attribute vec2 pos;
+
+varying vec2 fragTexCoord;
+
+void main() {
+    // Texture rows go top down like uploaded frames
+    fragTexCoord = pos * 0.5 + 0.5;
+    gl_Position = vec4(pos, 0, 1);
+}
+` + "\x00"
+
+// The driver converts YUV to RGB when sampling an external texture
+var dmabufFragmentShader = `
+#version 100
+#extension GL_OES_EGL_image_external : require
+precision mediump float;
+
+uniform samplerExternalOES tex;
+
+varying vec2 fragTexCoord;
+
+void main() {
+    gl_FragColor = texture2D(tex, fragTexCoord);
+}
+` + "\x00"
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gl "github.com/go-gl/gl/v3.1/gles2"
+)
+
+// lutTextureUnit is the texture unit the LUT of the camera being drawn is
+// bound to; the camera frame uses unit 0
+const lutTextureUnit = 1
+
+// maxCubeSize bounds the LUT sizes accepted from .cube files
+const maxCubeSize = 256
+
+// LUT is a color lookup table loaded from an Adobe/Resolve .cube file. 3D
+// tables map RGB to RGB; 1D tables map each channel separately. Entries are
+// stored with red changing fastest, then green, then blue.
+type LUT struct {
+	Title     string
+	Size      int
+	Is3D      bool
+	DomainMin [3]float32
+	DomainMax [3]float32
+	Data      [][3]float32
+}
+
+// LUTs and gamma applied to the camera views, set from the command line
+var (
+	cameraLUTs  map[int]*cameraLUT
+	lutsEnabled         = true
+	gamma       float32 = 1
+
+	lutUniform, lutSizeUniform, lutEnabledUniform, gammaUniform int32
+)
+
+// cameraLUT is a LUT uploaded to the GPU for one camera
+type cameraLUT struct {
+	texture uint32
+	size    int
+}
+
+// loadCubeLUT reads a .cube file
+func loadCubeLUT(path string) (*LUT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LUT: %w", err)
+	}
+	defer f.Close()
+
+	lut, err := parseCubeLUT(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return lut, nil
+}
+
+// parseCubeLUT parses the .cube format: keyword lines followed by one line
+// of three values per table entry
+func parseCubeLUT(r io.Reader) (*LUT, error) {
+	lut := &LUT{DomainMax: [3]float32{1, 1, 1}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+
+		switch fields[0] {
+		case "TITLE":
+			lut.Title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(text, "TITLE")), `"`)
+		case "LUT_3D_SIZE", "LUT_1D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed %s", line, fields[0])
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 2 || size > maxCubeSize {
+				return nil, fmt.Errorf("line %d: invalid LUT size %q", line, fields[1])
+			}
+			lut.Size = size
+			lut.Is3D = fields[0] == "LUT_3D_SIZE"
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			values, err := parseTriple(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				lut.DomainMin = values
+			} else {
+				lut.DomainMax = values
+			}
+		default:
+			if fields[0][0] >= 'A' && fields[0][0] <= 'Z' {
+				// Other keywords, such as LUT_3D_INPUT_RANGE, are not needed
+				continue
+			}
+			values, err := parseTriple(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			lut.Data = append(lut.Data, values)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read LUT: %w", err)
+	}
+
+	if lut.Size == 0 {
+		return nil, fmt.Errorf("missing LUT_3D_SIZE or LUT_1D_SIZE")
+	}
+	want := lut.Size
+	if lut.Is3D {
+		want = lut.Size * lut.Size * lut.Size
+	}
+	if len(lut.Data) != want {
+		return nil, fmt.Errorf("expected %d entries, found %d", want, len(lut.Data))
+	}
+	for c := range 3 {
+		if lut.DomainMax[c] <= lut.DomainMin[c] {
+			return nil, fmt.Errorf("invalid domain")
+		}
+	}
+	return lut, nil
+}
+
+func parseTriple(fields []string) ([3]float32, error) {
+	var values [3]float32
+	if len(fields) != 3 {
+		return values, fmt.Errorf("expected 3 values, found %d", len(fields))
+	}
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return values, fmt.Errorf("invalid value %q", field)
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+// Lookup maps an RGB color in [0, 1] through the table, interpolating
+// between entries
+func (l *LUT) Lookup(rgb [3]float32) [3]float32 {
+	// Position of the color in table coordinates
+	var pos [3]float32
+	for c := range 3 {
+		v := (rgb[c] - l.DomainMin[c]) / (l.DomainMax[c] - l.DomainMin[c])
+		pos[c] = min(max(v, 0), 1) * float32(l.Size-1)
+	}
+
+	if !l.Is3D {
+		var out [3]float32
+		for c := range 3 {
+			i0, t := splitIndex(pos[c], l.Size)
+			out[c] = l.Data[i0][c]*(1-t) + l.Data[i0+1][c]*t
+		}
+		return out
+	}
+
+	r0, tr := splitIndex(pos[0], l.Size)
+	g0, tg := splitIndex(pos[1], l.Size)
+	b0, tb := splitIndex(pos[2], l.Size)
+	at := func(r, g, b int) [3]float32 {
+		return l.Data[r+g*l.Size+b*l.Size*l.Size]
+	}
+
+	// Trilinear interpolation over the surrounding cube
+	var out [3]float32
+	for c := range 3 {
+		c00 := at(r0, g0, b0)[c]*(1-tr) + at(r0+1, g0, b0)[c]*tr
+		c10 := at(r0, g0+1, b0)[c]*(1-tr) + at(r0+1, g0+1, b0)[c]*tr
+		c01 := at(r0, g0, b0+1)[c]*(1-tr) + at(r0+1, g0, b0+1)[c]*tr
+		c11 := at(r0, g0+1, b0+1)[c]*(1-tr) + at(r0+1, g0+1, b0+1)[c]*tr
+		c0 := c00*(1-tg) + c10*tg
+		c1 := c01*(1-tg) + c11*tg
+		out[c] = c0*(1-tb) + c1*tb
+	}
+	return out
+}
+
+// splitIndex splits a table coordinate into the lower entry index and the
+// interpolation weight of the next entry
+func splitIndex(pos float32, size int) (int, float32) {
+	i := min(int(pos), size-2)
+	return i, pos - float32(i)
+}
+
+// texels samples the LUT on a size^3 grid over [0, 1] and lays the blue
+// slices side by side in a (size*size) x size RGB image. GLES2 has no 3D
+// textures, so the shader interpolates between slices itself.
+func (l *LUT) texels(size int) []byte {
+	pix := make([]byte, size*size*size*3)
+	step := 1 / float32(size-1)
+	for b := range size {
+		for g := range size {
+			for r := range size {
+				out := l.Lookup([3]float32{float32(r) * step, float32(g) * step, float32(b) * step})
+				i := (g*size*size + b*size + r) * 3
+				for c := range 3 {
+					pix[i+c] = uint8(min(max(out[c], 0), 1)*255 + 0.5)
+				}
+			}
+		}
+	}
+	return pix
+}
+
+// createLUTTexture uploads a LUT, resampling it to fit the GPU's maximum
+// texture width, and 1D tables to a 3D grid
+func createLUTTexture(lut *LUT) (*cameraLUT, error) {
+	var maxTexture int32
+	gl.GetIntegerv(gl.MAX_TEXTURE_SIZE, &maxTexture)
+
+	size := lut.Size
+	if !lut.Is3D {
+		size = min(size, 33)
+	}
+	for size*size > int(maxTexture) {
+		size--
+	}
+	if size < 2 {
+		return nil, fmt.Errorf("maximum texture size %d is too small for a LUT", maxTexture)
+	}
+	if lut.Is3D && size != lut.Size {
+		log.Printf("Resampling %d^3 LUT to %d^3 to fit the GPU", lut.Size, size)
+	}
+
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE0 + lutTextureUnit)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	// Rows of RGB texels are not 4-byte aligned for odd sizes
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+
+	pix := lut.texels(size)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGB,
+		int32(size*size),
+		int32(size),
+		0,
+		gl.RGB,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(pix),
+	)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	return &cameraLUT{texture: texture, size: size}, nil
+}
+
+// parseLUTFlag parses the -lut flag: either a single .cube file applied to
+// every camera, or a comma-separated list of index=file pairs
+func parseLUTFlag(value string) (map[int]string, error) {
+	paths := make(map[int]string)
+	if value == "" {
+		return paths, nil
+	}
+	if !strings.Contains(value, "=") {
+		for i := range cameras {
+			paths[i] = value
+		}
+		return paths, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		index, path, ok := strings.Cut(entry, "=")
+		i, err := strconv.Atoi(strings.TrimSpace(index))
+		if !ok || err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid -lut entry %q, expected <camera index>=<file.cube>", entry)
+		}
+		paths[i] = strings.TrimSpace(path)
+	}
+	return paths, nil
+}
+
+// initLUTs loads and uploads the LUTs given on the command line and looks up
+// the shader uniforms. Cameras sharing a file share the texture.
+func initLUTs(program uint32, flagValue string) error {
+	lutUniform = gl.GetUniformLocation(program, gl.Str("lut\x00"))
+	lutSizeUniform = gl.GetUniformLocation(program, gl.Str("lutSize\x00"))
+	lutEnabledUniform = gl.GetUniformLocation(program, gl.Str("lutEnabled\x00"))
+	gammaUniform = gl.GetUniformLocation(program, gl.Str("gamma\x00"))
+	gl.Uniform1i(lutUniform, lutTextureUnit)
+
+	paths, err := parseLUTFlag(flagValue)
+	if err != nil {
+		return err
+	}
+	cameraLUTs = make(map[int]*cameraLUT)
+	loaded := make(map[string]*cameraLUT)
+	for index, path := range paths {
+		if index >= len(cameras) {
+			log.Printf("Ignoring LUT for camera %d: no such camera", index)
+			continue
+		}
+		if texture, ok := loaded[path]; ok {
+			cameraLUTs[index] = texture
+			continue
+		}
+		lut, err := loadCubeLUT(path)
+		if err != nil {
+			return err
+		}
+		texture, err := createLUTTexture(lut)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		log.Printf("Loaded LUT %q (%s) for camera %d", lut.Title, filepath.Base(path), index)
+		loaded[path] = texture
+		cameraLUTs[index] = texture
+	}
+	return nil
+}
+
+// useCameraLUT sets the shader up to draw a camera's frame with its LUT, if
+// it has one, and the current gamma. The program must be in use.
+func useCameraLUT(index int) {
+	gl.Uniform1f(gammaUniform, gamma)
+
+	lut, ok := cameraLUTs[index]
+	if !ok || !lutsEnabled {
+		gl.Uniform1f(lutEnabledUniform, 0)
+		return
+	}
+	gl.Uniform1f(lutEnabledUniform, 1)
+	gl.Uniform1f(lutSizeUniform, float32(lut.size))
+	gl.ActiveTexture(gl.TEXTURE0 + lutTextureUnit)
+	gl.BindTexture(gl.TEXTURE_2D, lut.texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+}
+
+// deleteLUTs frees the LUT textures
+func deleteLUTs() {
+	deleted := make(map[uint32]bool)
+	for _, lut := range cameraLUTs {
+		if !deleted[lut.texture] {
+			gl.DeleteTextures(1, &lut.texture)
+			deleted[lut.texture] = true
+		}
+	}
+	cameraLUTs = nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// The UI is laid out in window (screen) coordinates. uiScale converts the
+// sizes written in the layout code into screen coordinates.
+var (
+	uiScale         float32 = 1
+	uiScaleOverride float32
+)
+
+// dp scales a size from the layout code to screen coordinates
+func dp(v float32) float32 {
+	return v * uiScale
+}
+
+// updateUIScale re-reads the window's content scale and applies it to the UI.
+// A non-zero uiScaleOverride replaces the detected scale.
+func updateUIScale(window *glfw.Window, ui *UIManager) {
+	scale := uiScaleOverride
+	if scale <= 0 {
+		scale, _ = window.GetContentScale()
+
+		// On macOS and Wayland screen coordinates are already scaled (the
+		// framebuffer is larger than the window), so only the remainder of
+		// the content scale applies to the UI
+		ww, _ := window.GetSize()
+		fw, _ := window.GetFramebufferSize()
+		if ww > 0 && fw > ww {
+			scale /= float32(fw) / float32(ww)
+		}
+		if scale <= 0 {
+			scale = 1
+		}
+	}
+	uiScale = scale
+
+	ui.SetScale(uiScale)
+	log.Printf("UI scale %.2f", uiScale)
+}
@@ -8,6 +8,7 @@ package main
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
 	gl "github.com/go-gl/gl/v3.1/gles2"
 	"github.com/go-gl/glfw/v3.3/glfw"
@@ -18,7 +19,7 @@ import (
 	"image/jpeg"
 	"io"
 	"log"
-
+	"math"
 	"path/filepath"
 	"regexp"
 	"runtime"
@@ -49,6 +50,8 @@ type PerformanceMetrics struct {
 const (
 	windowWidth       = 800
 	windowHeight      = 600
+	cameraDistance    = 3.0
+	fieldOfView       = 45.0
 	frameWidth        = 640
 	frameHeight       = 480
 	smallFrameWidth   = 160
@@ -69,6 +72,11 @@ var (
 	droppedFrames  uint64
 	lastUpdate     time.Time
 	currentFPS     float64
+
+	// Current window size in screen coordinates (used for UI layout and
+	// cursor hit-testing) and framebuffer size in pixels (used for glViewport).
+	winWidth, winHeight int = windowWidth, windowHeight
+	fbWidth, fbHeight   int = windowWidth, windowHeight
 )
 
 func init() {
@@ -77,6 +85,14 @@ func init() {
 }
 
 func main() {
+	scaleFlag := flag.Float64("ui-scale", 0, "UI scale factor (0 = detect from display DPI)")
+	lutFlag := flag.String("lut", "", "3D LUT (.cube) for all cameras, or <index>=<file>,... per camera")
+	gammaFlag := flag.Float64("gamma", 1, "display gamma correction applied to the camera views")
+	dmabufFlag := flag.Bool("dmabuf", false, "show raw-format cameras without decoding or copying, via DMA-BUF and EGLImage")
+	flag.Parse()
+	uiScaleOverride = float32(*scaleFlag)
+	gamma = float32(*gammaFlag)
+
 	// Initialize GLFW and OpenGL
 	if err := glfw.Init(); err != nil {
 		log.Fatalln("failed to initialize glfw:", err)
@@ -103,11 +119,15 @@ func main() {
 	activeCameras = make([]*device.Device, len(cameras))
 
 	// Set up window and OpenGL context
-	glfw.WindowHint(glfw.Resizable, glfw.False)
+	glfw.WindowHint(glfw.Resizable, glfw.True)
 	glfw.WindowHint(glfw.ContextVersionMajor, 4)
 	glfw.WindowHint(glfw.ContextVersionMinor, 1)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	if *dmabufFlag {
+		// EGLImages can only be imported into an EGL context
+		glfw.WindowHint(glfw.ContextCreationAPI, glfw.EGLContextAPI)
+	}
 
 	window, err := glfw.CreateWindow(windowWidth, windowHeight, "V4L2 Multi-Camera", nil, nil)
 	if err != nil {
@@ -126,6 +146,14 @@ func main() {
 	version := gl.GoStr(gl.GetString(gl.VERSION))
 	fmt.Println("OpenGL version", version)
 
+	if *dmabufFlag {
+		if err := initDMABuf(); err != nil {
+			log.Printf("Zero-copy frames unavailable, decoding frames instead: %v", err)
+		} else {
+			dmabufEnabled = true
+		}
+	}
+
 	// Configure the vertex and fragment shaders
 	program, err := newProgram(vertexShader, fragmentShader)
 	if err != nil {
@@ -173,12 +201,11 @@ func main() {
 		},
 	)
 
-	// Camera selection buttons (up to 4 buttons vertically)
-	for i := 0; i < len(cameras) && i < 4; i++ {
+	// Camera selection buttons, stacked vertically for as many as fit
+	for i := 0; i < len(cameras); i++ {
 		index := i // Capture for closure
 		uiManager.AddButton(
-			padding,
-			padding*2+camButtonHeight+float32(i)*(camButtonHeight+padding),
+			0, 0,
 			camButtonWidth,
 			camButtonHeight,
 			fmt.Sprintf("Camera %d", i),
@@ -190,13 +217,30 @@ func main() {
 			},
 		)
 	}
+	updateUIScale(window, uiManager)
+	window.SetContentScaleCallback(func(w *glfw.Window, x, y float32) {
+		updateUIScale(w, uiManager)
+	})
 
 	// Set up camera matrix and view
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 10.0)
 	projectionUniform := gl.GetUniformLocation(program, gl.Str("projection\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
+	updateProjection(program, projectionUniform)
 
-	camera := mgl32.LookAtV(mgl32.Vec3{0, 0, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+	// Track window and framebuffer size changes
+	w, h := window.GetSize()
+	fw, fh := window.GetFramebufferSize()
+	resizeViewport(program, projectionUniform, uiManager, w, h, fw, fh)
+
+	window.SetSizeCallback(func(w *glfw.Window, width, height int) {
+		fw, fh := w.GetFramebufferSize()
+		resizeViewport(program, projectionUniform, uiManager, width, height, fw, fh)
+	})
+	window.SetFramebufferSizeCallback(func(w *glfw.Window, width, height int) {
+		ww, wh := w.GetSize()
+		resizeViewport(program, projectionUniform, uiManager, ww, wh, width, height)
+	})
+
+	camera := mgl32.LookAtV(mgl32.Vec3{0, 0, cameraDistance}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
 	cameraUniform := gl.GetUniformLocation(program, gl.Str("camera\x00"))
 	gl.UniformMatrix4fv(cameraUniform, 1, false, &camera[0])
 
@@ -207,6 +251,11 @@ func main() {
 	textureUniform := gl.GetUniformLocation(program, gl.Str("tex\x00"))
 	gl.Uniform1i(textureUniform, 0)
 
+	if err := initLUTs(program, *lutFlag); err != nil {
+		log.Fatalf("Failed to load LUTs: %v", err)
+	}
+	defer deleteLUTs()
+
 	//gl.BindFragDataLocation(program, 0, gl.Str("outputColor\x00"))
 
 	// Initialize the main camera (camera at index 0)
@@ -294,7 +343,7 @@ func main() {
 
 		// Update main camera texture
 		if activeCameras[selectedCamera] != nil {
-			updateTextureWithCameraFrame(activeCameras[selectedCamera], mainTexture, &droppedFrames)
+			updateCameraTexture(selectedCamera, mainTexture)
 		}
 
 		// Render main camera view
@@ -308,7 +357,7 @@ func main() {
 				}
 
 				// Update texture for this camera
-				updateTextureWithCameraFrame(cam, smallTextures[i], &droppedFrames)
+				updateCameraTexture(i, smallTextures[i])
 			}
 
 			// Render the small preview cameras
@@ -320,18 +369,18 @@ func main() {
 
 		// Draw performance metrics and status text using formatted text
 		uiManager.DrawTextFormatted(
-			float32(windowWidth-150),
-			float32(20),
-			1.0,
+			float32(winWidth)-dp(150),
+			dp(20),
+			uiScale,
 			mgl32.Vec3{1, 1, 1},
 			"FPS: %.1f",
 			currentFPS,
 		)
 
 		uiManager.DrawTextFormatted(
-			float32(windowWidth-150),
-			float32(50),
-			1.0,
+			float32(winWidth)-dp(150),
+			dp(50),
+			uiScale,
 			mgl32.Vec3{1, 1, 1},
 			"Camera: %s",
 			cameras[selectedCamera].Name,
@@ -339,9 +388,9 @@ func main() {
 
 		// Show dropped frames count
 		uiManager.DrawTextFormatted(
-			float32(windowWidth-150),
-			float32(80),
-			1.0,
+			float32(winWidth)-dp(150),
+			dp(80),
+			uiScale,
 			mgl32.Vec3{1, 1, 0},
 			"Dropped: %d",
 			atomic.LoadUint64(&droppedFrames),
@@ -358,6 +407,40 @@ func main() {
 	}
 }
 
+// resizeViewport applies a new window/framebuffer size: the GL viewport
+// follows the framebuffer (pixels), while the perspective projection and UI
+// layout follow the window size in screen coordinates.
+func resizeViewport(program uint32, projectionUniform int32, ui *UIManager, width, height, fbw, fbh int) {
+	if width <= 0 || height <= 0 || fbw <= 0 || fbh <= 0 {
+		// Minimized
+		return
+	}
+
+	winWidth, winHeight = width, height
+	fbWidth, fbHeight = fbw, fbh
+
+	gl.Viewport(0, 0, int32(fbWidth), int32(fbHeight))
+	updateProjection(program, projectionUniform)
+	ui.Resize(winWidth, winHeight)
+}
+
+// updateProjection uploads a perspective projection matching the current
+// window aspect ratio.
+func updateProjection(program uint32, projectionUniform int32) {
+	aspect := float32(winWidth) / float32(winHeight)
+	projection := mgl32.Perspective(mgl32.DegToRad(fieldOfView), aspect, 0.1, 10.0)
+
+	gl.UseProgram(program)
+	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
+}
+
+// viewExtents returns the half width and half height of the visible area of
+// the z=0 plane, in world units, for the current window aspect ratio.
+func viewExtents() (float32, float32) {
+	halfHeight := float32(cameraDistance * math.Tan(float64(mgl32.DegToRad(fieldOfView))/2))
+	return halfHeight * float32(winWidth) / float32(winHeight), halfHeight
+}
+
 // Find all available camera devices
 func findCameraDevices() ([]CameraInfo, error) {
 	var cameras []CameraInfo
@@ -441,6 +524,16 @@ func initCamera(index int) error {
 		height = smallFrameHeight
 	}
 
+	if dmabufEnabled {
+		stream, err := openDMABufCamera(camInfo, width, height)
+		if err == nil {
+			activeCameras[index] = stream.dev
+			dmabufStreams[index] = stream
+			return nil
+		}
+		log.Printf("No zero-copy frames, decoding: %v", err)
+	}
+
 	dev, err := device.Open(camInfo.Path,
 		device.WithIOType(v4l2.IOTypeMMAP),
 		device.WithPixFormat(v4l2.PixFormat{
@@ -473,6 +566,10 @@ func closeCamera(index int) {
 		return
 	}
 
+	if s := dmabufStreams[index]; s != nil {
+		s.close()
+		delete(dmabufStreams, index)
+	}
 	activeCameras[index].Stop()
 	activeCameras[index].Close()
 	activeCameras[index] = nil
@@ -573,6 +670,7 @@ func renderMainCameraView(vao uint32, program uint32, modelUniform int32) {
 	// Set up model matrix for main view
 	model := mgl32.Ident4()
 	gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+	useCameraLUT(selectedCamera)
 
 	gl.BindVertexArray(vao)
 	gl.ActiveTexture(gl.TEXTURE0)
@@ -607,9 +705,10 @@ func renderPreviewCameras(vao uint32, program uint32, modelUniform int32) {
 		activeCount = maxPreviewCameras
 	}
 
-	// Place the preview windows in the bottom-right corner
-	startX := 1.0 - previewSize - padding
-	startY := -1.0 + padding
+	// Place the preview windows in the bottom-right corner of the visible area
+	halfWidth, halfHeight := viewExtents()
+	startX := halfWidth - previewSize - padding
+	startY := -halfHeight + previewSize + padding
 
 	// Keep track of how many we've rendered
 	rendered := 0
@@ -627,7 +726,7 @@ func renderPreviewCameras(vao uint32, program uint32, modelUniform int32) {
 
 		// Calculate position for this preview
 		x := startX
-		y := startY + (float32(rendered) * (previewSize + padding))
+		y := startY + (float32(rendered) * (2*previewSize + padding))
 
 		// Create model matrix for this preview camera
 		model := mgl32.Ident4()
@@ -635,6 +734,7 @@ func renderPreviewCameras(vao uint32, program uint32, modelUniform int32) {
 		model = model.Mul4(mgl32.Scale3D(previewSize, previewSize, 1))
 
 		gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
+		useCameraLUT(i)
 
 		// Draw this preview
 		gl.ActiveTexture(gl.TEXTURE0)
@@ -659,6 +759,20 @@ func keyCallback(window *glfw.Window, key glfw.Key, scancode int, action glfw.Ac
 		// Toggle multi-view mode
 		showMultiView = !showMultiView
 
+	case glfw.KeyL:
+		// Compare with and without the LUTs
+		lutsEnabled = !lutsEnabled
+		log.Printf("LUTs enabled: %v", lutsEnabled)
+
+	case glfw.KeyLeftBracket, glfw.KeyRightBracket:
+		// Adjust the display gamma
+		if key == glfw.KeyLeftBracket {
+			gamma = max(gamma-0.1, 0.1)
+		} else {
+			gamma = min(gamma+0.1, 5)
+		}
+		log.Printf("Gamma: %.1f", gamma)
+
 	case glfw.Key1, glfw.Key2, glfw.Key3, glfw.Key4, glfw.Key5, glfw.Key6, glfw.Key7, glfw.Key8, glfw.Key9:
 		// Switch to camera 0-8 when pressing 1-9 keys
 		newIndex := int(key) - int(glfw.Key1)
@@ -801,16 +915,45 @@ void main() {
 }
 ` + "\x00"
 
+// The fragment shader applies the camera's LUT, laid out as blue slices side
+// by side in a 2D texture, and the display gamma
 var fragmentShader = `
 #version 100
+#ifdef GL_FRAGMENT_PRECISION_HIGH
+precision highp float;
+#else
 precision mediump float;
+#endif
 
 uniform sampler2D tex;
+uniform sampler2D lut;
+uniform float lutSize;
+uniform float lutEnabled;
+uniform float gamma;
 
 varying vec2 fragTexCoord;
 
+vec3 applyLUT(vec3 color) {
+    float n = lutSize;
+    float b = color.b * (n - 1.0);
+    float b0 = floor(b);
+    float b1 = min(b0 + 1.0, n - 1.0);
+    // Red and green are interpolated by the texture filtering within a
+    // slice, blue between the two nearest slices
+    float x = (color.r * (n - 1.0) + 0.5) / (n * n);
+    float y = (color.g * (n - 1.0) + 0.5) / n;
+    vec3 c0 = texture2D(lut, vec2(x + b0 / n, y)).rgb;
+    vec3 c1 = texture2D(lut, vec2(x + b1 / n, y)).rgb;
+    return mix(c0, c1, b - b0);
+}
+
 void main() {
-    gl_FragColor = texture2D(tex, fragTexCoord);
+    vec4 color = texture2D(tex, fragTexCoord);
+    if (lutEnabled > 0.5) {
+        color.rgb = applyLUT(color.rgb);
+    }
+    color.rgb = pow(color.rgb, vec3(1.0 / gamma));
+    gl_FragColor = color;
 }
 ` + "\x00"
 